@@ -45,8 +45,11 @@
 //
 // While this scheme protects passwords from observation and replay in transit (but not
 // man-in-the-middle or other more sophisticated attacks unless further protections are
-// placed on the connection itself), the passwords themselves are handled on both client
-// and server in plaintext form.
+// placed on the connection itself, such as running the server with TLS enabled), the
+// passwords themselves are handled by the client in plaintext form. The server may
+// keep them in plaintext too, or (preferably) may store only keys derived from them
+// in a hashed password file (see PasswordFile), so that the file does not reveal the
+// passwords if it is leaked.
 //
 // In case you missed it above, DO NOT USE this authenticator for ANYTHING that is worth
 // protecting. We only use it to play a game together.
@@ -82,6 +85,10 @@
 //
 // This returns the response to send back to the server in order to log in.
 //
+// If the server also sent a salt and key iteration count (because it keeps a hashed
+// password file), the client uses AcceptChallengeBytesWithSalt instead so its password
+// is first turned into the same derived key the server has on file.
+//
 // # SERVER-SIDE OPERATION
 //
 // A server which accepts a client connection should create an Authenticator
@@ -135,23 +142,37 @@
 //
 // D is the response to send to the server for validation.
 //
+// If the server keeps a hashed password file, it also sends a salt S and key
+// iteration count k. In that case, before step (2) the client replaces P with the
+// derived key K (which is what the server has on file), calculated as:
+//
+//	(a) Calculate K=h(S‖P).
+//	(b) Repeat k times: K'=h(P‖K); let K=K'
+//
 // # PROTOCOL
 //
 // Although the auth package itself isn't involved in the client/server protocol
 // directly, the way it is used by the map server and its clients uses the following
 // protocol:
 //
-//	(server->client) OK {"Protocol":<v>, "Challenge":"<challenge>", "Iterations":<iterations>}
+//	(server->client) OK {"Protocol":<v>, "Challenge":"<challenge>", "Iterations":<iterations>, "Salt":"<salt>", "KeyIterations":<k>, "PersonalSalts":<bool>}
 //
 // The server's greeting to the client includes this line which gives the server's
 // protocol version (<v>) and a base-64 encoding of the binary challenge value (C in the
-// algorithm described above)
+// algorithm described above). The Salt and KeyIterations fields are only present if the
+// server's passwords are stored as derived keys, and PersonalSalts is true if personal
+// passwords are derived with each user's own salt (see UserSalt).
 //
-//	(server<-client) AUTH {"Response":"<response>", "User":"<user>", "Client":"<client>"}
+//	(server<-client) AUTH {"Response":"<response>", "PersonalResponse":"<presponse>", "User":"<user>", "Client":"<client>", "Protocol":<p>}
 //
 // The client's response is sent with this line, where <response> is the base-64
 // encoded representation of the response to the challenge (D above), and the optional <user> and
 // <client> values are the desired user name and description of the client program.
+// If PersonalSalts was true, <presponse> is the response computed from the key derived
+// with the user's own salt, which the server checks instead of <response> if the user
+// has a personal password. <p> is the protocol version the client implements;
+// a server whose passwords are stored as derived keys denies access to clients
+// which don't report at least protocol 417, since they can't derive the keys.
 //
 //	(server->client) DENIED {"Reason":"<message>"}
 //
@@ -195,6 +216,21 @@ type Authenticator struct {
 	Challenge  []byte
 	Iterations int
 
+	// If Salt is set, the secrets are not used directly to compute
+	// responses but are first run through DeriveKey with this salt and
+	// KeyIterations rounds. On the server, Secret and GmSecret already
+	// hold the derived keys (see PasswordFile); on the client, Secret
+	// holds the password and the key is derived from it when accepting
+	// the challenge.
+	Salt          []byte
+	KeyIterations int
+
+	// If PersonalSalts is also set, personal passwords are derived
+	// with each user's own salt (see UserSalt) instead of Salt, so
+	// the client sends a second response computed that way (see
+	// PersonalResponse) in case its password is a personal one.
+	PersonalSalts bool
+
 	// text description of client program/version
 	Client string
 
@@ -421,6 +457,47 @@ func (a *Authenticator) calcResponse(secret []byte) ([]byte, error) {
 	return d, nil
 }
 
+// DeriveKey computes the key which is stored in a hashed password file
+// in place of the password itself. It uses the same hashing scheme as the
+// challenge response:
+//
+//	K = H(P || H(P || ... H(S||P)))
+//
+// where S is the salt, P is the password, and the outer hash is repeated
+// the given number of iterations.
+func DeriveKey(secret, salt []byte, iterations int) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(secret)
+	d := h.Sum(nil)
+	for i := 0; i < iterations; i++ {
+		h.Reset()
+		h.Write(secret)
+		h.Write(d)
+		d = h.Sum(nil)
+	}
+	return d
+}
+
+// UserSalt returns the salt used to derive the key for the named user's
+// personal password: the password file's salt followed by the user name.
+// This gives every user a different salt which the client can work out
+// for itself, since the server sends its challenge before it knows who
+// is logging in.
+func UserSalt(salt []byte, user string) []byte {
+	return append(append([]byte{}, salt...), user...)
+}
+
+// clientKey returns the secret the client should use to calculate its
+// response: the password itself, or the key derived from it if the
+// server gave us a salt.
+func (a *Authenticator) clientKey() []byte {
+	if len(a.Salt) == 0 || len(a.Secret) == 0 {
+		return a.Secret
+	}
+	return DeriveKey(a.Secret, a.Salt, a.KeyIterations)
+}
+
 // AcceptChallenge takes a server's challenge, stores it internally, and generates an appropriate
 // response to it, which is returned as a base-64 encoded string. (CLIENT)
 //
@@ -465,6 +542,42 @@ func (a *Authenticator) AcceptChallengeWithIterations(challenge string, iteratio
 	return base64.StdEncoding.EncodeToString(response), nil
 }
 
+// AcceptChallengeBytesWithSalt is like AcceptChallengeBytesWithIterations but is used
+// when the server stores only derived keys rather than passwords. The
+// salt and keyIterations values sent by the server are remembered in the
+// Authenticator and used to derive the key from our password before
+// computing the response. (CLIENT)
+//
+// If salt is empty, this is the same as AcceptChallengeBytesWithIterations.
+//
+// This refuses to derive a key with more than MaximumKeyIterations rounds.
+func (a *Authenticator) AcceptChallengeBytesWithSalt(challenge []byte, iterations int, salt []byte, keyIterations int) ([]byte, error) {
+	if keyIterations < 0 || keyIterations > MaximumKeyIterations {
+		return nil, fmt.Errorf("server asked for %d key iterations; the limit is %d", keyIterations, MaximumKeyIterations)
+	}
+	a.Salt = salt
+	a.KeyIterations = keyIterations
+	return a.AcceptChallengeBytesWithIterations(challenge, iterations)
+}
+
+// PersonalResponse returns the response to the challenge last accepted by
+// AcceptChallengeBytesWithSalt as it would be computed if our password were
+// a personal password derived with our own user salt (see UserSalt). The
+// client should send this along with the regular response if PersonalSalts
+// is set, since it can't tell whether the password it was given is a
+// personal password or the group password. If PersonalSalts is not set
+// (or there is no salt or user name), this returns nil. (CLIENT)
+func (a *Authenticator) PersonalResponse() ([]byte, error) {
+	if !a.PersonalSalts || len(a.Salt) == 0 || len(a.Secret) == 0 || a.Username == "" {
+		return nil, nil
+	}
+	response, err := a.calcResponse(DeriveKey(a.Secret, UserSalt(a.Salt, a.Username), a.KeyIterations))
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate response: %v", err)
+	}
+	return response, nil
+}
+
 // AcceptChallengeBytes is like AcceptChallenge but takes the raw binary
 // challenge and emits the raw binay response as []byte slices.
 //
@@ -472,7 +585,7 @@ func (a *Authenticator) AcceptChallengeWithIterations(challenge string, iteratio
 func (a *Authenticator) AcceptChallengeBytes(challenge []byte) ([]byte, error) {
 	a.Challenge = challenge
 	a.Iterations = (int(challenge[0]) << 8) | int(challenge[1])
-	response, err := a.calcResponse(a.Secret)
+	response, err := a.calcResponse(a.clientKey())
 	if err != nil {
 		return nil, fmt.Errorf("unable to generate response: %v", err)
 	}
@@ -488,7 +601,7 @@ func (a *Authenticator) AcceptChallengeBytes(challenge []byte) ([]byte, error) {
 func (a *Authenticator) AcceptChallengeBytesWithIterations(challenge []byte, iterations int) ([]byte, error) {
 	a.Challenge = challenge
 	a.Iterations = iterations
-	response, err := a.calcResponse(a.Secret)
+	response, err := a.calcResponse(a.clientKey())
 	if err != nil {
 		return nil, fmt.Errorf("unable to generate response: %v", err)
 	}
@@ -0,0 +1,419 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Password files for the map server.
+//
+
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HashedPasswordFileHeader is the first word on the first line of a password
+// file which holds derived keys rather than plaintext passwords.
+const HashedPasswordFileHeader = "%GMA-HASHED-PASSWORDS"
+
+// DefaultKeyIterations is the number of hashing rounds used by DeriveKey
+// when creating new hashed password files.
+const DefaultKeyIterations = 4096
+
+// MaximumKeyIterations is the largest number of hashing rounds we will use
+// for DeriveKey. Clients refuse to derive keys with more rounds than this
+// so a server can't make them spend an unreasonable amount of time doing so.
+const MaximumKeyIterations = 1 << 20
+
+// A PasswordFile holds the set of passwords the map server accepts.
+//
+// There are two formats for this file. The original plaintext format
+// has the group password on the first line, the GM password on the second,
+// and any number of personal passwords following those, one per line,
+// in the form
+//
+//	username:password
+//
+// The hashed format starts with a header line
+//
+//	%GMA-HASHED-PASSWORDS 2 <iterations> <salt>
+//
+// followed by the same lines as the plaintext format except that each
+// password is replaced by the base-64 encoding of the key derived from it
+// by DeriveKey using the salt (also base-64 encoded) and number of
+// iterations given in the header. Personal passwords are instead derived
+// using each user's own salt as given by UserSalt, so users who choose the
+// same password don't end up with the same key. (Files with version 1 in
+// the header use the header's salt for the personal passwords too; these
+// are still accepted.) A server using a hashed file never sees the actual
+// passwords.
+//
+// Note that the derived keys are all that a client needs to answer the
+// server's challenge. Hashing the passwords keeps the passwords themselves
+// secret (so they can't be tried on other systems where the users may have
+// used them), but anyone who obtains a copy of the hashed file can still
+// log in to the server, so it must be protected as carefully as a plaintext
+// file.
+//
+// In either format, an empty GM password disables GM logins.
+type PasswordFile struct {
+	// True if the secrets below are derived keys rather than passwords.
+	Hashed bool
+
+	// The salt and hashing rounds used to derive the keys (if Hashed).
+	Salt          []byte
+	KeyIterations int
+
+	// True if the personal keys are derived using each user's own salt
+	// (see UserSalt) rather than Salt itself.
+	PersonalSalts bool
+
+	// The group (player), GM, and personal secrets.
+	GroupSecret     []byte
+	GmSecret        []byte
+	PersonalSecrets map[string][]byte
+
+	// Problems found while reading the file which were not serious enough
+	// to reject it, such as malformed personal password lines (which are
+	// ignored).
+	Warnings []string
+}
+
+// NewHashedPasswordFile creates a new, empty PasswordFile in hashed format
+// with a random salt and the given number of key iterations (usually
+// DefaultKeyIterations).
+func NewHashedPasswordFile(iterations int) (*PasswordFile, error) {
+	p := &PasswordFile{
+		PersonalSecrets: make(map[string][]byte),
+	}
+	if err := p.ConvertToHashed(iterations); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ReadPasswordFile reads a password file in either the plaintext or hashed
+// format from the named file.
+func ReadPasswordFile(path string) (*PasswordFile, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return ParsePasswordFile(fp)
+}
+
+// ParsePasswordFile reads a password file in either the plaintext or hashed
+// format from an io.Reader.
+func ParsePasswordFile(r io.Reader) (*PasswordFile, error) {
+	p := &PasswordFile{
+		GroupSecret:     []byte{},
+		GmSecret:        []byte{},
+		PersonalSecrets: make(map[string][]byte),
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 1
+	nextLine := func() bool {
+		if scanner.Scan() {
+			line++
+			return true
+		}
+		return false
+	}
+
+	if !scanner.Scan() {
+		return p, scanner.Err()
+	}
+	if f := strings.Fields(scanner.Text()); len(f) > 0 && f[0] == HashedPasswordFileHeader {
+		if len(f) != 4 {
+			return nil, fmt.Errorf("line 1: malformed header (expected \"%s 2 <iterations> <salt>\")", HashedPasswordFileHeader)
+		}
+		switch f[1] {
+		case "1":
+		case "2":
+			p.PersonalSalts = true
+		default:
+			return nil, fmt.Errorf("line 1: unsupported hashed password file version %s", f[1])
+		}
+		iter, err := strconv.Atoi(f[2])
+		if err != nil || iter < 0 || iter > MaximumKeyIterations {
+			return nil, fmt.Errorf("line 1: invalid iteration count %s", f[2])
+		}
+		salt, err := base64.StdEncoding.DecodeString(f[3])
+		if err != nil || len(salt) == 0 {
+			return nil, fmt.Errorf("line 1: invalid salt value")
+		}
+		p.Hashed = true
+		p.Salt = salt
+		p.KeyIterations = iter
+		if !nextLine() {
+			return p, scanner.Err()
+		}
+	}
+
+	decode := func(s string) ([]byte, error) {
+		if !p.Hashed {
+			return []byte(s), nil
+		}
+		k, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid key value: %v", line, err)
+		}
+		return k, nil
+	}
+
+	var err error
+	if p.GroupSecret, err = decode(scanner.Text()); err != nil {
+		return nil, err
+	}
+	if nextLine() {
+		if p.GmSecret, err = decode(scanner.Text()); err != nil {
+			return nil, err
+		}
+		for nextLine() {
+			pp := strings.SplitN(scanner.Text(), ":", 2)
+			if len(pp) != 2 {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("line %d: ignoring personal password: missing delimiter", line))
+				continue
+			}
+			if p.PersonalSecrets[pp[0]], err = decode(pp[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ConvertToHashed replaces all of the plaintext passwords in the PasswordFile
+// with keys derived from them, using a new random salt and the given number
+// of iterations. If the file is already hashed, this does nothing.
+func (p *PasswordFile) ConvertToHashed(iterations int) error {
+	if p.Hashed {
+		return nil
+	}
+	if iterations < 0 || iterations > MaximumKeyIterations {
+		return fmt.Errorf("iteration count must be from 0 to %d", MaximumKeyIterations)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	p.Salt = salt
+	p.KeyIterations = iterations
+	p.Hashed = true
+	p.PersonalSalts = true
+	p.GroupSecret = p.secret(p.GroupSecret, p.Salt)
+	p.GmSecret = p.secret(p.GmSecret, p.Salt)
+	for user, pass := range p.PersonalSecrets {
+		p.PersonalSecrets[user] = p.secret(pass, p.userSalt(user))
+	}
+	return nil
+}
+
+// secret returns the value we should store for the given password:
+// the password itself in a plaintext file, or its key derived with
+// the given salt in a hashed file. Empty passwords are stored as empty values.
+func (p *PasswordFile) secret(password, salt []byte) []byte {
+	if len(password) == 0 {
+		return []byte{}
+	}
+	if p.Hashed {
+		return DeriveKey(password, salt, p.KeyIterations)
+	}
+	return append([]byte{}, password...)
+}
+
+// userSalt returns the salt for the named user's personal password.
+func (p *PasswordFile) userSalt(user string) []byte {
+	if p.PersonalSalts {
+		return UserSalt(p.Salt, user)
+	}
+	return p.Salt
+}
+
+// SetGroupPassword changes the password shared by all players.
+func (p *PasswordFile) SetGroupPassword(password []byte) {
+	p.GroupSecret = p.secret(password, p.Salt)
+}
+
+// SetGmPassword changes the GM's password. An empty password disables GM logins.
+func (p *PasswordFile) SetGmPassword(password []byte) {
+	p.GmSecret = p.secret(password, p.Salt)
+}
+
+// SetPersonalPassword adds a personal password for the named user, or changes
+// it if they already have one.
+func (p *PasswordFile) SetPersonalPassword(user string, password []byte) error {
+	if user == "" || strings.ContainsAny(user, ":\n") || strings.TrimSpace(user) != user {
+		return fmt.Errorf("invalid user name \"%s\"", user)
+	}
+	if len(password) == 0 {
+		return fmt.Errorf("personal passwords may not be empty")
+	}
+	if bytes.ContainsRune(password, '\n') {
+		return fmt.Errorf("passwords may not contain newlines")
+	}
+	if p.PersonalSecrets == nil {
+		p.PersonalSecrets = make(map[string][]byte)
+	}
+	p.PersonalSecrets[user] = p.secret(password, p.userSalt(user))
+	return nil
+}
+
+// RemovePersonalPassword removes the named user's personal password, returning
+// false if they did not have one.
+func (p *PasswordFile) RemovePersonalPassword(user string) bool {
+	if _, ok := p.PersonalSecrets[user]; !ok {
+		return false
+	}
+	delete(p.PersonalSecrets, user)
+	return true
+}
+
+// Users returns the sorted list of users with personal passwords.
+func (p *PasswordFile) Users() []string {
+	var users []string
+	for user := range p.PersonalSecrets {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users
+}
+
+// Write writes the PasswordFile to an io.Writer in the plaintext or hashed
+// format as appropriate.
+func (p *PasswordFile) Write(w io.Writer) error {
+	encode := func(s []byte) string {
+		if p.Hashed {
+			return base64.StdEncoding.EncodeToString(s)
+		}
+		return string(s)
+	}
+
+	if p.Hashed {
+		version := 1
+		if p.PersonalSalts {
+			version = 2
+		}
+		if _, err := fmt.Fprintf(w, "%s %d %d %s\n", HashedPasswordFileHeader, version, p.KeyIterations, base64.StdEncoding.EncodeToString(p.Salt)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "%s\n%s\n", encode(p.GroupSecret), encode(p.GmSecret)); err != nil {
+		return err
+	}
+	for _, user := range p.Users() {
+		if _, err := fmt.Fprintf(w, "%s:%s\n", user, encode(p.PersonalSecrets[user])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the PasswordFile to the named file, readable only by its
+// owner. The new contents are written to a temporary file first which
+// then replaces the original, so the server never sees a partially-written
+// file.
+func (p *PasswordFile) Save(path string) error {
+	fp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmpName := fp.Name()
+	if err = p.Write(fp); err == nil {
+		err = fp.Chmod(0600)
+	}
+	if cerr := fp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, path)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+	}
+	return err
+}
+
+// ServerAuthenticator returns a new Authenticator for a server to use
+// with a connecting client, holding the group and GM secrets from this
+// PasswordFile (and the salt and iteration count if they are derived keys).
+func (p *PasswordFile) ServerAuthenticator() *Authenticator {
+	a := &Authenticator{
+		Secret:   p.GroupSecret,
+		GmSecret: p.GmSecret,
+	}
+	if p.Hashed {
+		a.Salt = p.Salt
+		a.KeyIterations = p.KeyIterations
+		a.PersonalSalts = p.PersonalSalts
+	}
+	return a
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,243 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Unit tests for the password file code
+//
+
+package auth
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlaintextPasswordFile(t *testing.T) {
+	p, err := ParsePasswordFile(strings.NewReader("players\ngmpass\nalice:secret\nbogus\nbob:a:b\n"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if p.Hashed {
+		t.Errorf("plaintext file read as hashed")
+	}
+	if string(p.GroupSecret) != "players" || string(p.GmSecret) != "gmpass" {
+		t.Errorf("group/gm passwords %q/%q", p.GroupSecret, p.GmSecret)
+	}
+	if len(p.PersonalSecrets) != 2 || string(p.PersonalSecrets["alice"]) != "secret" || string(p.PersonalSecrets["bob"]) != "a:b" {
+		t.Errorf("personal passwords %q", p.PersonalSecrets)
+	}
+	if len(p.Warnings) != 1 || !strings.HasPrefix(p.Warnings[0], "line 4:") {
+		t.Errorf("warnings %q", p.Warnings)
+	}
+
+	var b bytes.Buffer
+	if err := p.Write(&b); err != nil {
+		t.Fatalf("write error %v", err)
+	}
+	if b.String() != "players\ngmpass\nalice:secret\nbob:a:b\n" {
+		t.Errorf("wrote %q", b.String())
+	}
+}
+
+func TestHashedPasswordFile(t *testing.T) {
+	p, err := ParsePasswordFile(strings.NewReader("players\n\nalice:secret\n"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := p.ConvertToHashed(100); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !p.Hashed || !p.PersonalSalts || len(p.Salt) == 0 || p.KeyIterations != 100 {
+		t.Fatalf("conversion result %v", p)
+	}
+	if !bytes.Equal(p.GroupSecret, DeriveKey([]byte("players"), p.Salt, 100)) {
+		t.Errorf("group key not derived from password")
+	}
+	if len(p.GmSecret) != 0 {
+		t.Errorf("empty gm password became %q", p.GmSecret)
+	}
+	if err := p.SetPersonalPassword("bob", []byte("hunter2")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := p.SetPersonalPassword("x:y", []byte("hunter2")); err == nil {
+		t.Errorf("invalid user name accepted")
+	}
+	if !p.RemovePersonalPassword("alice") || p.RemovePersonalPassword("alice") {
+		t.Errorf("remove did not work as expected")
+	}
+
+	path := filepath.Join(t.TempDir(), "passwords")
+	if err := p.Save(path); err != nil {
+		t.Fatalf("save error %v", err)
+	}
+	q, err := ReadPasswordFile(path)
+	if err != nil {
+		t.Fatalf("read error %v", err)
+	}
+	if !q.Hashed || !q.PersonalSalts || !bytes.Equal(q.Salt, p.Salt) || q.KeyIterations != 100 ||
+		!bytes.Equal(q.GroupSecret, p.GroupSecret) || len(q.GmSecret) != 0 ||
+		len(q.PersonalSecrets) != 1 || !bytes.Equal(q.PersonalSecrets["bob"], DeriveKey([]byte("hunter2"), UserSalt(p.Salt, "bob"), 100)) {
+		t.Errorf("read back %v, expected %v", q, p)
+	}
+
+	// Version 1 files use the same salt for everyone
+	v1, err := ParsePasswordFile(strings.NewReader(HashedPasswordFileHeader + " 1 100 c2FsdA==\ncGxheWVycw==\n\n"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if v1.PersonalSalts {
+		t.Errorf("version 1 file uses personal salts")
+	}
+	if err := v1.SetPersonalPassword("bob", []byte("hunter2")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !bytes.Equal(v1.PersonalSecrets["bob"], DeriveKey([]byte("hunter2"), []byte("salt"), 100)) {
+		t.Errorf("version 1 personal key not derived from the file's salt")
+	}
+	var buf bytes.Buffer
+	if err := v1.Write(&buf); err != nil || !strings.HasPrefix(buf.String(), HashedPasswordFileHeader+" 1 100 c2FsdA==\n") {
+		t.Errorf("version 1 file written as %q (%v)", buf.String(), err)
+	}
+
+	if err := p.SetPersonalPassword("carol", []byte("hunter2")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if bytes.Equal(p.PersonalSecrets["bob"], p.PersonalSecrets["carol"]) {
+		t.Errorf("users with the same password have the same key")
+	}
+
+	if _, err := NewHashedPasswordFile(MaximumKeyIterations + 1); err == nil {
+		t.Errorf("excessive iteration count accepted")
+	}
+
+	for _, bad := range []string{
+		HashedPasswordFileHeader + " 1 100\nabc\n",
+		HashedPasswordFileHeader + " 3 100 c2FsdA==\n",
+		HashedPasswordFileHeader + " 2 2000000 c2FsdA==\n",
+		HashedPasswordFileHeader + " 1 x c2FsdA==\n",
+		HashedPasswordFileHeader + " 1 100 c2FsdA==\nnot base 64!\n",
+	} {
+		if _, err := ParsePasswordFile(strings.NewReader(bad)); err == nil {
+			t.Errorf("file %q accepted", bad)
+		}
+	}
+}
+
+func TestHashedAuthentication(t *testing.T) {
+	p, err := NewHashedPasswordFile(DefaultKeyIterations)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	p.SetGroupPassword([]byte("players"))
+	p.SetGmPassword([]byte("gmpass"))
+	if err := p.SetPersonalPassword("bob", []byte("bobpass")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, tc := range []struct {
+		user     string
+		password string
+		ok, gm   bool
+	}{
+		{"alice", "players", true, false},
+		{"alice", "gmpass", true, true},
+		{"alice", "wrong", false, false},
+		{"alice", "bobpass", false, false},
+		{"bob", "bobpass", true, false},
+		{"bob", "players", false, false},
+		{"carol", "bobpass", false, false},
+	} {
+		// This is how the map server and client use these
+		server := p.ServerAuthenticator()
+		personal, hasPersonal := p.PersonalSecrets[tc.user]
+		if hasPersonal {
+			server.SetSecret(personal)
+		}
+		challenge, iterations, err := server.GenerateChallengeBytesWithIterations()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		client := NewClientAuthenticator(tc.user, []byte(tc.password), "test")
+		client.PersonalSalts = server.PersonalSalts
+		response, err := client.AcceptChallengeBytesWithSalt(challenge, iterations, server.Salt, server.KeyIterations)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		personalResponse, err := client.PersonalResponse()
+		if err != nil || personalResponse == nil {
+			t.Fatalf("personal response %v, error %v", personalResponse, err)
+		}
+		if hasPersonal {
+			response = personalResponse
+		}
+		ok, err := server.ValidateResponseBytes(response)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if ok != tc.ok || server.GmMode != tc.gm {
+			t.Errorf("%s password %s: ok=%v gm=%v, expected %v, %v", tc.user, tc.password, ok, server.GmMode, tc.ok, tc.gm)
+		}
+	}
+
+	client := NewClientAuthenticator("alice", []byte("players"), "test")
+	if _, err := client.AcceptChallengeBytesWithSalt([]byte("challenge"), 10, p.Salt, MaximumKeyIterations+1); err == nil {
+		t.Errorf("client accepted excessive key iteration count")
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -44,8 +44,8 @@ Its input and output is not designed to be user-friendly, but rather to make it
 (Otherwise)
    map-console -h
    map-console -help
-   map-console [-Dm] [-C configfile] [-c calendar] [-H host] [-l logfile] [-P password] [-p port] [-S profile] [-u user]
-   map-console [-calendar calendar] [-config configfile] [-debug] [-help] [-host host] [-log logfile] [-mono] [-password password] [-port port] [-select profile] [-username user]
+   map-console [-Dm] [-C configfile] [-c calendar] [-campaign name] [-H host] [-l logfile] [-P password] [-p port] [-reconnect] [-S profile] [-u user]
+   map-console [-calendar calendar] [-campaign name] [-config configfile] [-debug] [-help] [-host host] [-log logfile] [-mono] [-password password] [-port port] [-reconnect] [-select profile] [-username user]
 
 # OPTIONS
 
@@ -59,6 +59,10 @@ You may not combine multiple single-letter options into a single composite argum
   -c, -calendar name
       Override server's advertised campaign calendar name.
 
+  -campaign name
+      Join the named campaign on a server which hosts several of them,
+      rather than the server's default campaign.
+
   -C, -config file
       The named file is read to set the same options as documented here
       for command-line parameters as option=value pairs, one per line.
@@ -105,6 +109,11 @@ You may not combine multiple single-letter options into a single composite argum
   -p, -port port
       Specifies the server's TCP port number.
 
+  -reconnect
+      If the connection to the server is lost, keep trying to reconnect
+      (waiting longer between each attempt), then catch up on the game
+      state and any chat messages missed in the meantime.
+
   -S, -select profile
       Selects a server profile to use from the user's saved mapper preferences.
 
@@ -134,6 +143,13 @@ Each typed command line must conform to the Tcl list syntax (space-separated lis
   DD/ regex                 Delete presets matching regex
   DDD {name name ...}       Set delegate list to the specified names
   DR                        Retrieve die-roll presets
+  DT filename               Replace random tables with those in file (GM only)
+  DT+ filename              Same as DT but add to random tables (GM only)
+  DT/ regex                 Delete random tables matching regex (GM only)
+  DT?                       Retrieve random tables
+  DV {name value ...}       Replace your die-roll variables
+  DV+ {name value ...}      Same as DV but add to variables ("" deletes)
+  DV?                       Retrieve die-roll variables
   EXIT|QUIT                 Exit map-console
   HELP|?                    Prints out a command summary
   L filename                Load contents of local map file
@@ -141,6 +157,11 @@ Each typed command line must conform to the Tcl list syntax (space-separated lis
   M filename                As L but merge contents with existing map
   M? id                     Tell clients to cache server map file
   M@ id                     As M but using a server map file
+  MAP-LOAD name             Load map saved on the server into the game (GM only)
+  MAP-MERGE name            As MAP-LOAD but merge with existing map (GM only)
+  MAP-SAVE name [loc [comment]]
+                            Save current map on the server (GM only)
+  MAP?                      List maps saved on the server (GM only)
   MARK x y                  Show visible marker at (x,y)
   OA id {k1 v1 k2 v2 ...}   Set object attributes to new values
   OA+ id k {v1 v2 v3 ...}   Add values to a list-valued object attribute
@@ -148,9 +169,11 @@ Each typed command line must conform to the Tcl list syntax (space-separated lis
   POLO                      Send POLO packet to server
   PS id color name area size player|monster x y reach
                             Place a creature token on the map
+  REDO [n]                  Re-apply the last n undone map changes (GM only)
   SYNC                      Retrieve full game state
   SYNC CHAT [target]        Retrieve chat message history
   TO recips|*|% message     Send chat message (*=to all, %=to GM)
+  UNDO [n]                  Undo the last n changes to the map (GM only)
   /CONN                     Retrieve list of connected clients
 
 You may also type any arbitrary server command with its JSON parameter payload using the syntax
@@ -189,6 +212,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -213,6 +237,12 @@ var Fdebug string
 var Flog string
 var Fselect string
 var Flist bool
+var Ftls bool
+var FtlsCA string
+var FtlsCert string
+var FtlsKey string
+var Fcampaign string
+var Freconnect bool
 
 func init() {
 	const (
@@ -229,7 +259,7 @@ func init() {
 		defaultLog      = ""
 	)
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [-h] [-m] [-C configfile] [-c calendar] [-D list] [-H host] [-l logfile] [-P password] [-p port] [-S profile] [-u user] [-list-profiles]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-h] [-m] [-C configfile] [-c calendar] [-D list] [-H host] [-l logfile] [-P password] [-p port] [-reconnect] [-S profile] [-u user] [-list-profiles] [-campaign name] [-tls] [-tls-ca file] [-tls-cert file -tls-key file]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  An option 'x' with a value may be set by '-x value', '-x=value', '--x value', or '--x=value'.\n")
 		fmt.Fprintf(os.Stderr, "  A flag 'x' may be set by '-x', '--x', '-x=true|false' or '--x=true|false'\n")
 		fmt.Fprintf(os.Stderr, "  Options may NOT be combined into a single argument (use '-h -m', not '-hm').\n")
@@ -267,6 +297,13 @@ func init() {
 
 	flag.StringVar(&Flog, "log", defaultLog, "Logfile ('-' is standard output)")
 	flag.StringVar(&Flog, "l", defaultLog, "(same as -log)")
+
+	flag.BoolVar(&Ftls, "tls", false, "Connect to the server over TLS")
+	flag.StringVar(&FtlsCA, "tls-ca", "", "PEM file of certificate authorities trusted to sign the server's certificate")
+	flag.StringVar(&FtlsCert, "tls-cert", "", "PEM file holding a client certificate to identify you to the server")
+	flag.StringVar(&FtlsKey, "tls-key", "", "PEM file holding the private key for -tls-cert")
+	flag.StringVar(&Fcampaign, "campaign", "", "Join the named campaign on a server which hosts several")
+	flag.BoolVar(&Freconnect, "reconnect", false, "Keep trying to reconnect if the server connection is lost")
 }
 
 func main() {
@@ -354,10 +391,13 @@ func main() {
 			mapper.UpdateCoreData,
 			mapper.UpdateCoreIndex,
 			mapper.UpdateDicePresets,
+			mapper.UpdateDiceVariables,
 			mapper.UpdateInitiative,
+			mapper.UpdateMaps,
 			mapper.UpdateObjAttributes,
 			mapper.UpdatePeerList,
 			mapper.UpdateProgress,
+			mapper.UpdateRandomTables,
 			mapper.UpdateStatusMarker,
 			mapper.UpdateTurn,
 		),
@@ -370,6 +410,22 @@ func main() {
 			fmt.Sprintf("map-console %s", GoVersionNumber))
 		conOpts = append(conOpts, mapper.WithAuthenticator(a))
 	}
+	if profile := prefs.Prefs.Profiles[prefs.SelectedIdx]; profile.TLS {
+		tlsConfig, err := mapper.NewClientTLSConfig(profile.TLSCAFile, profile.TLSCertFile, profile.TLSKeyFile)
+		if err != nil {
+			log.Fatalf("unable to set up TLS: %v", err)
+		}
+		conOpts = append(conOpts, mapper.WithTLS(tlsConfig))
+	}
+	if campaign := prefs.Prefs.Profiles[prefs.SelectedIdx].Campaign; campaign != "" {
+		conOpts = append(conOpts, mapper.WithCampaign(campaign))
+	}
+	if Freconnect {
+		conOpts = append(conOpts,
+			mapper.StayConnected(true),
+			mapper.WithRetries(0),
+			mapper.WithSubscription(messages, mapper.Reconnected))
+	}
 	server, conerr := mapper.NewConnection(fmt.Sprintf("%s:%d",
 		prefs.Prefs.Profiles[prefs.SelectedIdx].Host,
 		prefs.Prefs.Profiles[prefs.SelectedIdx].Port),
@@ -927,6 +983,11 @@ func describeIncomingMessage(msg mapper.MessagePayload, mono bool, cal gma.Calen
 			)
 		}
 
+	case mapper.ReconnectedMessagePayload:
+		printFields(mono, "Reconnected",
+			fieldDesc{"since", m.LastMessageID},
+		)
+
 	case mapper.RemoveObjAttributesMessagePayload:
 		printFields(mono, "RemoveObjAttributes",
 			fieldDesc{"objID", m.ObjID},
@@ -996,6 +1057,19 @@ func describeIncomingMessage(msg mapper.MessagePayload, mono bool, cal gma.Calen
 			fieldDesc{"IsDone", m.IsDone},
 		)
 
+	case mapper.UpdateMapsMessagePayload:
+		printFields(mono, "UpdateMaps",
+			fieldDesc{"RequestID", m.RequestID},
+		)
+		for _, saved := range m.Maps {
+			printFields(mono, colorize("  ", "Blue", mono),
+				fieldDesc{"name", saved.Name},
+				fieldDesc{"location", saved.Location},
+				fieldDesc{"comment", saved.Comment},
+				fieldDesc{"saved", time.Unix(saved.Timestamp, 0).Format(time.DateTime)},
+			)
+		}
+
 	case mapper.UpdateDicePresetsMessagePayload:
 		printFields(mono, "UpdateDicePresets",
 			fieldDesc{"for", m.For},
@@ -1010,6 +1084,44 @@ func describeIncomingMessage(msg mapper.MessagePayload, mono bool, cal gma.Calen
 			)
 		}
 
+	case mapper.UpdateRandomTablesMessagePayload:
+		printFields(mono, "UpdateRandomTables")
+		for _, t := range m.Tables {
+			printFields(mono, colorize("  ", "Blue", mono),
+				fieldDesc{"name", t.Name},
+				fieldDesc{"desc", t.Description},
+				fieldDesc{"spec", t.DieRollSpec},
+			)
+			for _, e := range t.Entries {
+				var roll string
+				switch {
+				case e.Low != 0 || e.High != 0:
+					roll = fmt.Sprintf("%d-%d", e.Low, e.High)
+				case e.Weight != 0:
+					roll = fmt.Sprintf("x%d", e.Weight)
+				}
+				printFields(mono, colorize("    ", "Blue", mono),
+					fieldDesc{"roll", roll},
+					fieldDesc{"result", e.Result},
+				)
+			}
+		}
+
+	case mapper.UpdateDiceVariablesMessagePayload:
+		printFields(mono, "UpdateDiceVariables",
+			fieldDesc{"for", m.For},
+		)
+		names := make([]string, 0, len(m.Variables))
+		for name := range m.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			printFields(mono, colorize("  $"+name, "Blue", mono),
+				fieldDesc{"value", m.Variables[name]},
+			)
+		}
+
 	case mapper.UpdateInitiativeMessagePayload:
 		printFields(mono, "UpdateInitiative")
 		printFields(mono, "",
@@ -1231,6 +1343,21 @@ func configureApp() (AppPreferences, error) {
 	if Fuser != "" {
 		prefs.Prefs.Profiles[prefs.SelectedIdx].UserName = Fuser
 	}
+	if Ftls {
+		prefs.Prefs.Profiles[prefs.SelectedIdx].TLS = true
+	}
+	if FtlsCA != "" {
+		prefs.Prefs.Profiles[prefs.SelectedIdx].TLSCAFile = FtlsCA
+	}
+	if FtlsCert != "" {
+		prefs.Prefs.Profiles[prefs.SelectedIdx].TLSCertFile = FtlsCert
+	}
+	if FtlsKey != "" {
+		prefs.Prefs.Profiles[prefs.SelectedIdx].TLSKeyFile = FtlsKey
+	}
+	if Fcampaign != "" {
+		prefs.Prefs.Profiles[prefs.SelectedIdx].Campaign = Fcampaign
+	}
 	if Fmono {
 		prefs.Mono = true
 	}
@@ -1375,12 +1502,23 @@ DD {{<name> <desc> <dice>} ...}         Replace dice preset list
 DD+ {{<name> <desc> <dice>} ...}        Add to dice preset list
 DD/ <regex>                             Delete all presets whose names match RE
 DR                                      Request die roll preset
+DT <filename>                           Replace random tables with those in file
+DT+ <filename>                          Add random tables in file
+DT/ <regex>                             Delete all random tables whose names match RE
+DT?                                     Request random tables
+DV {<name> <value> ...}                 Replace die-roll variables
+DV+ {<name> <value> ...}                Add to die-roll variables (empty value deletes)
+DV?                                     Request die-roll variables
 L <filename>                            Tell clients to load local file to mapper
 L@ <filename>                           Tell clients to load server file
 LS <filename>                           Upload contents of local file to all
 M <filename>                            Tell clients to merge local file to mapper
 M? <serverid>                           Ensure local cache of server file
 M@ <serverid>                           Tell clients to merge contents of server file to canvas
+MAP-LOAD <name>                         Load map saved on server into game state
+MAP-MERGE <name>                        Merge map saved on server into game state
+MAP-SAVE <name> [<loc> [<comment>]]     Save current map on server
+MAP?                                    List maps saved on server
 MARK <x> <y>                            Show visual marker at coordinates
 OA <id> {<k0> <v0> ... <kN> <vN>}       Set object attribute(s)
 OA+ <id> <key> {<v0> <v1> ... <vN>}     Add to list-type object attribute
@@ -1388,12 +1526,14 @@ OA- <id> <key> {<v0> <v1> ... <vN>}     Remove from list-type object attribute
 POLO                                    Answer server ping request
 PS <id> <color> <name> <area> <size> player|monster <x> <y> <reach>  
 QUIT|EXIT                               Exit the client
+REDO [<n>]                              Re-apply last <n> undone map changes
 SYNC [CHAT [<target>]]                  Sync server content / chat history
 TO {<recip>|@|*|% ...} <message>        Send chat message
+UNDO [<n>]                              Undo last <n> map changes
 /CONN`)
 			case "//":
 				// ignore
-			case "AC", "ACCEPT", "DENIED", "DSM", "GRANTED", "I", "IL", "MARCO", "OK", "PRIV", "ROLL", "CONN", "CONN:", "CONN.":
+			case "AC", "ACCEPT", "DENIED", "DSM", "GRANTED", "I", "IL", "MAP=", "MARCO", "OK", "PRIV", "ROLL", "CONN", "CONN:", "CONN.":
 				// server messages
 				fmt.Println(colorize(fmt.Sprintf("ERROR: %s is not for clients to send.", fields[0]), "Red", mono))
 
@@ -1652,6 +1792,95 @@ TO {<recip>|@|*|% ...} <message>        Send chat message
 					break
 				}
 
+			case "DT", "DT+":
+				// DT filename
+				// DT+ filename
+				if len(fields) != 2 {
+					fmt.Println(colorize("usage ERROR: wrong number of fields: DT[+] <file>", "Red", mono))
+					break
+				}
+				tables, _, err := dice.ReadRandomTableFile(fields[1])
+				if err != nil {
+					fmt.Println(colorize(fmt.Sprintf("ERROR reading random tables: %v", err), "Red", mono))
+					break
+				}
+				if fields[0] == "DT" {
+					if err := server.DefineRandomTables(tables); err != nil {
+						fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+						break
+					}
+				} else {
+					if err := server.AddRandomTables(tables); err != nil {
+						fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+						break
+					}
+				}
+
+			case "DT/":
+				// DT/ regex
+				if len(fields) != 2 {
+					fmt.Println(colorize("usage ERROR: wrong number of fields: DT/ <regex>", "Red", mono))
+					break
+				}
+				if err := server.FilterRandomTables(fields[1]); err != nil {
+					fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+					break
+				}
+
+			case "DT?":
+				// DT?
+				if len(fields) != 1 {
+					fmt.Println(colorize("usage ERROR: wrong number of fields: DT?", "Red", mono))
+					break
+				}
+				if err := server.QueryRandomTables(); err != nil {
+					fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+					break
+				}
+
+			case "DV", "DV+":
+				// DV {name value ...}
+				// DV+ {name value ...}
+				if len(fields) != 2 {
+					fmt.Println(colorize("usage ERROR: wrong number of fields: DV[+] {<name> <value> ...}", "Red", mono))
+					break
+				}
+				vlist, err := tcllist.ParseTclList(fields[1])
+				if err != nil {
+					fmt.Println(colorize(fmt.Sprintf("ERROR in variable list: %v", err), "Red", mono))
+					break
+				}
+				if (len(vlist) % 2) != 0 {
+					fmt.Println(colorize("usage ERROR: variable list must have an even number of elements", "Red", mono))
+					break
+				}
+				vars := make(map[string]string)
+				for i := 0; i < len(vlist); i += 2 {
+					vars[vlist[i]] = vlist[i+1]
+				}
+				if fields[0] == "DV" {
+					if err := server.DefineDiceVariables(vars); err != nil {
+						fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+						break
+					}
+				} else {
+					if err := server.AddDiceVariables(vars); err != nil {
+						fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+						break
+					}
+				}
+
+			case "DV?":
+				// DV?
+				if len(fields) != 1 {
+					fmt.Println(colorize("usage ERROR: wrong number of fields: DV?", "Red", mono))
+					break
+				}
+				if err := server.QueryDiceVariables(); err != nil {
+					fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+					break
+				}
+
 			case "L":
 				// L filename
 				if len(fields) != 2 {
@@ -1776,6 +2005,39 @@ TO {<recip>|@|*|% ...} <message>        Send chat message
 					break
 				}
 
+			case "MAP-LOAD", "MAP-MERGE":
+				// MAP-LOAD name
+				// MAP-MERGE name
+				if len(fields) != 2 {
+					fmt.Println(colorize(fmt.Sprintf("usage ERROR: wrong number of fields: %s <name>", fields[0]), "Red", mono))
+					break
+				}
+				if err := server.LoadMap(fields[1], fields[0] == "MAP-MERGE"); err != nil {
+					fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+					break
+				}
+
+			case "MAP-SAVE":
+				// MAP-SAVE name [location [comment]]
+				if len(fields) < 2 || len(fields) > 4 {
+					fmt.Println(colorize("usage ERROR: MAP-SAVE <name> [<location> [<comment>]]", "Red", mono))
+					break
+				}
+				for len(fields) < 4 {
+					fields = append(fields, "")
+				}
+				if err := server.SaveMap(fields[1], fields[2], fields[3]); err != nil {
+					fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+					break
+				}
+
+			case "MAP?":
+				// MAP?
+				if err := server.QueryMaps(); err != nil {
+					fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+					break
+				}
+
 			case "MARK":
 				// MARK x y
 				v, err := tcllist.ConvertTypes(fields, "sff")
@@ -1903,6 +2165,35 @@ TO {<recip>|@|*|% ...} <message>        Send chat message
 					fmt.Println(colorize("usage ERROR: creature type must be \"monster\" or \"player\"", "Red", mono))
 				}
 
+			case "REDO", "UNDO":
+				// REDO [n]
+				// UNDO [n]
+				count := 1
+				switch len(fields) {
+				case 1:
+				case 2:
+					v, err := tcllist.ConvertTypes(fields, "si")
+					if err != nil {
+						fmt.Println(colorize(fmt.Sprintf("usage ERROR: %v", err), "Red", mono))
+						break handle_input
+					}
+					count = v[1].(int)
+				default:
+					fmt.Println(colorize(fmt.Sprintf("usage ERROR: %s [<n>]", fields[0]), "Red", mono))
+					break handle_input
+				}
+				if fields[0] == "UNDO" {
+					if err := server.Undo(count); err != nil {
+						fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+						break handle_input
+					}
+				} else {
+					if err := server.Redo(count); err != nil {
+						fmt.Println(colorize(fmt.Sprintf("server ERROR: %v", err), "Red", mono))
+						break handle_input
+					}
+				}
+
 			case "SYNC":
 				// SYNC [CHAT [target]]
 				switch len(fields) {
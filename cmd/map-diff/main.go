@@ -0,0 +1,286 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+#
+# Adapted for the Pathfinder RPG, which is what we're playing now
+# (and this software is primarily for our own use in our play group,
+# anyway, but could be generalized later as a stand-alone product).
+#
+# Copyright (c) 2025 by Steven L. Willoughby, Aloha, Oregon, USA.
+# All Rights Reserved.
+# Licensed under the terms and conditions of the BSD 3-Clause license.
+#
+# Based on earlier code by the same author, unreleased for the author's
+# personal use; copyright (c) 1992-2019.
+#
+########################################################################
+*/
+
+/*
+Map-diff compares two GMA map files, or merges the changes made to a map file
+by two people, so that maps kept under version control may be reviewed and
+combined. Objects in the maps are matched up by their IDs (images and server
+map files by their names) and compared attribute by attribute.
+
+When comparing maps, each object added to the new map is listed with a leading "+",
+each object removed from the old map with a leading "-", and each object changed
+with a leading "~" followed by the attributes which changed, showing their old and new values.
+Any change to the map's location or comment is listed as well.
+
+With -merge, three map files are given: the original (base) map and two maps
+(ours and theirs) which were each changed from it. The changes made in both are
+combined, attribute by attribute, into a new map file. If both changed the same
+attribute of an object to different values, or one deleted an object which the other changed,
+the conflict is reported and the merged map keeps our version of that object.
+The merged map takes its location and comment from ours unless -location or -comment
+are given.
+
+The exit status is 0 if the maps are the same (or merged without conflicts),
+1 if they differ (or there were conflicts), and 2 if there was a problem.
+
+# SYNOPSIS
+
+(If using the full GMA core tool suite)
+
+	gma go map-diff ...
+
+(Otherwise)
+
+	map-diff -help
+	map-diff [-q] old.map new.map
+	map-diff -merge [-comment text] [-location text] [-o output.map] base.map ours.map theirs.map
+
+# OPTIONS
+
+	-comment text
+	   Give the merged map this comment instead of the one in ours.map.
+
+	-location text
+	   Give the merged map this location instead of the one in ours.map.
+
+	-merge
+	   Merge the changes made in ours.map and theirs.map to base.map.
+
+	-o path
+	   Write the merged map to the named file instead of the standard output.
+
+	-q
+	   Only list which objects were added, removed, or changed, without listing the attributes which changed.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+func main() {
+	var fMerge = flag.Bool("merge", false, "merge the changes made in two maps to a common base map")
+	var fOutput = flag.String("o", "", "write the merged map to this file instead of the standard output")
+	var fLocation = flag.String("location", "", "location for the merged map (default: from ours)")
+	var fComment = flag.String("comment", "", "comment for the merged map (default: from ours)")
+	var fQuiet = flag.Bool("q", false, "don't list the attributes which changed")
+	flag.Parse()
+
+	var status int
+	var err error
+	if *fMerge {
+		if flag.NArg() != 3 {
+			fmt.Fprintf(os.Stderr, "usage: map-diff -merge [-comment text] [-location text] [-o output] base.map ours.map theirs.map\n")
+			os.Exit(2)
+		}
+		status, err = merge(flag.Arg(0), flag.Arg(1), flag.Arg(2), *fOutput, *fLocation, *fComment)
+	} else {
+		if flag.NArg() != 2 {
+			fmt.Fprintf(os.Stderr, "usage: map-diff [-q] old.map new.map\n")
+			os.Exit(2)
+		}
+		status, err = diff(os.Stdout, flag.Arg(0), flag.Arg(1), *fQuiet)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "map-diff: %v\n", err)
+		os.Exit(2)
+	}
+	os.Exit(status)
+}
+
+// readMap reads a map file, adding its name to any error.
+func readMap(path string) ([]any, mapper.MapMetaData, error) {
+	objs, meta, err := mapper.ReadMapFile(path)
+	if err != nil {
+		return nil, meta, fmt.Errorf("%s: %v", path, err)
+	}
+	return objs, meta, nil
+}
+
+// diff prints the differences between two map files, returning the exit status.
+func diff(out io.Writer, oldPath, newPath string, quiet bool) (int, error) {
+	oldObjs, oldMeta, err := readMap(oldPath)
+	if err != nil {
+		return 0, err
+	}
+	newObjs, newMeta, err := readMap(newPath)
+	if err != nil {
+		return 0, err
+	}
+	diffs, err := mapper.DiffMaps(oldObjs, newObjs)
+	if err != nil {
+		return 0, err
+	}
+
+	status := 0
+	if oldMeta.Location != newMeta.Location {
+		fmt.Fprintf(out, "Location: %q -> %q\n", oldMeta.Location, newMeta.Location)
+		status = 1
+	}
+	if oldMeta.Comment != newMeta.Comment {
+		fmt.Fprintf(out, "Comment: %q -> %q\n", oldMeta.Comment, newMeta.Comment)
+		status = 1
+	}
+	for _, d := range diffs {
+		status = 1
+		switch d.Kind {
+		case mapper.ObjectAdded:
+			fmt.Fprintf(out, "+ %s\n", describe(d.Type, d.Key, d.New))
+		case mapper.ObjectRemoved:
+			fmt.Fprintf(out, "- %s\n", describe(d.Type, d.Key, d.Old))
+		case mapper.ObjectChanged:
+			fmt.Fprintf(out, "~ %s\n", describe(d.Type, d.Key, d.New))
+			if !quiet {
+				for _, c := range d.Changes {
+					fmt.Fprintf(out, "    %s: %s -> %s\n", c.Name, formatValue(c.Old), formatValue(c.New))
+				}
+			}
+		}
+	}
+	return status, nil
+}
+
+// describe names a map object for the user.
+func describe(recordType, key string, obj any) string {
+	if c, ok := obj.(mapper.CreatureToken); ok && c.Name != "" {
+		return fmt.Sprintf("%s %s (%s)", recordType, key, c.Name)
+	}
+	return fmt.Sprintf("%s %s", recordType, key)
+}
+
+// formatValue represents an attribute value as it appears in the map file.
+func formatValue(v any) string {
+	if v == nil {
+		return "(unset)"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// merge combines the changes made in two map files to their common base, writing
+// the merged map and reporting any conflicts. It returns the exit status.
+func merge(basePath, ourPath, theirPath, outPath, location, comment string) (int, error) {
+	base, _, err := readMap(basePath)
+	if err != nil {
+		return 0, err
+	}
+	ours, meta, err := readMap(ourPath)
+	if err != nil {
+		return 0, err
+	}
+	theirs, _, err := readMap(theirPath)
+	if err != nil {
+		return 0, err
+	}
+
+	merged, conflicts, err := mapper.MergeMaps(base, ours, theirs)
+	if err != nil {
+		return 0, err
+	}
+
+	meta = mapper.MapMetaData{Location: meta.Location, Comment: meta.Comment}
+	if location != "" {
+		meta.Location = location
+	}
+	if comment != "" {
+		meta.Comment = comment
+	}
+	if outPath == "" {
+		err = mapper.SaveMapFile(os.Stdout, merged, meta)
+	} else {
+		err = mapper.WriteMapFile(outPath, merged, meta)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to write merged map: %v", err)
+	}
+
+	for _, c := range conflicts {
+		if len(c.Attributes) > 0 {
+			fmt.Fprintf(os.Stderr, "CONFLICT %s: %s (%s); kept our version\n", c.Key, c.Reason, strings.Join(c.Attributes, ", "))
+		} else {
+			fmt.Fprintf(os.Stderr, "CONFLICT %s: %s; kept our version\n", c.Key, c.Reason)
+		}
+	}
+	if len(conflicts) > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,267 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+#
+# Adapted for the Pathfinder RPG, which is what we're playing now
+# (and this software is primarily for our own use in our play group,
+# anyway, but could be generalized later as a stand-alone product).
+#
+# Copyright (c) 2025 by Steven L. Willoughby, Aloha, Oregon, USA.
+# All Rights Reserved.
+# Licensed under the terms and conditions of the BSD 3-Clause license.
+#
+# Based on earlier code by the same author, unreleased for the author's
+# personal use; copyright (c) 1992-2019.
+#
+########################################################################
+*/
+
+/*
+Map-import converts a Universal VTT map file, as exported by Dungeondraft (.dd2vtt)
+and other map-making tools (.uvtt, .df2vtt), into a GMA map file.
+
+The map's background image becomes a tile covering the map, scaled to the mapper's
+grid. The image is written to a separate PNG file which the map file refers to (or,
+with -image-id, to which the map refers by the ID the server knows it by once it has been
+uploaded there), or may be stored in the map file itself with -embed.
+The walls and other objects which block line of sight become lines (or polygons,
+where they close on themselves), doors become thin rectangles across their openings
+(filled if closed, dashed outlines if open), and light sources become areas of effect
+showing the area they light, or text labels.
+
+# SYNOPSIS
+
+(If using the full GMA core tool suite)
+
+	gma go map-import ...
+
+(Otherwise)
+
+	map-import -help
+	map-import [-comment text] [-door-color color] [-embed] [-image-file path] [-image-id id] [-image-name name] [-lights area|text|none] [-location text] [-o path] [-origin x,y] [-wall-color color] file.dd2vtt
+
+# OPTIONS
+
+	-comment text
+	   Comment to save in the map file (by default, noting the file it was imported from).
+
+	-door-color color
+	   Draw doors in this color instead of saddlebrown.
+
+	-embed
+	   Store the background image in the map file itself instead of in a separate file.
+	   This makes the map file much larger.
+
+	-image-file path
+	   Write the background image to this file instead of one named for the output file with a .png suffix.
+
+	-image-id id
+	   Refer to the background image by this server ID instead of as a local file. The image is still
+	   written to the image file so that it may be uploaded to the server.
+
+	-image-name name
+	   Name the background image this in the mapper instead of the input file's base name.
+
+	-lights area|text|none
+	   Show light sources as areas of effect covering the area they light (the default), as text labels,
+	   or not at all.
+
+	-location text
+	   Location to save in the map file.
+
+	-o path
+	   Write the map to this file instead of one named for the input file with a .map suffix.
+
+	-origin x,y
+	   Place the upper-left corner of the map at these mapper coordinates instead of (0,0).
+	   The coordinates are in map pixels (10 per foot), or in grid squares with a "g" suffix, as in "10g,4g".
+
+	-wall-color color
+	   Draw walls in this color instead of black.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	"github.com/MadScienceZone/go-gma/v5/uvtt"
+)
+
+func main() {
+	var fComment = flag.String("comment", "", "comment to save in the map file")
+	var fDoorColor = flag.String("door-color", "saddlebrown", "color to draw doors")
+	var fEmbed = flag.Bool("embed", false, "store the background image in the map file itself")
+	var fImageFile = flag.String("image-file", "", "write the background image to this file")
+	var fImageID = flag.String("image-id", "", "refer to the background image by this server ID")
+	var fImageName = flag.String("image-name", "", "name of the background image in the mapper")
+	var fLights = flag.String("lights", "area", "how to show light sources: area, text, or none")
+	var fLocation = flag.String("location", "", "location to save in the map file")
+	var fOutput = flag.String("o", "", "write the map to this file")
+	var fOrigin = flag.String("origin", "", "mapper coordinates x,y of the upper-left corner of the map (in map pixels, or grid squares with a \"g\" suffix)")
+	var fWallColor = flag.String("wall-color", "black", "color to draw walls")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: map-import [options] file.dd2vtt\n")
+		os.Exit(1)
+	}
+
+	inPath := flag.Arg(0)
+	outPath := *fOutput
+	if outPath == "" {
+		outPath = strings.TrimSuffix(inPath, filepath.Ext(inPath)) + ".map"
+	}
+	imagePath := *fImageFile
+	if imagePath == "" {
+		imagePath = strings.TrimSuffix(outPath, filepath.Ext(outPath)) + ".png"
+	}
+	imageName := *fImageName
+	if imageName == "" {
+		imageName = strings.TrimSuffix(filepath.Base(inPath), filepath.Ext(inPath))
+	}
+	comment := *fComment
+	if comment == "" {
+		comment = "Imported from " + filepath.Base(inPath)
+	}
+
+	m, err := uvtt.ReadFile(inPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "map-import: %s: %v\n", inPath, err)
+		os.Exit(1)
+	}
+
+	options := []uvtt.ImportOption{
+		uvtt.WithWallColor(*fWallColor),
+		uvtt.WithDoorColor(*fDoorColor),
+	}
+	switch *fLights {
+	case "area":
+		options = append(options, uvtt.WithLights(uvtt.LightsAsAreas))
+	case "text":
+		options = append(options, uvtt.WithLights(uvtt.LightsAsText))
+	case "none":
+		options = append(options, uvtt.WithLights(uvtt.NoLights))
+	default:
+		fmt.Fprintf(os.Stderr, "map-import: invalid -lights value \"%s\" (must be area, text, or none)\n", *fLights)
+		os.Exit(1)
+	}
+	if *fOrigin != "" {
+		x, y, err := parseCoordinates(*fOrigin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "map-import: %v\n", err)
+			os.Exit(1)
+		}
+		options = append(options, uvtt.WithOrigin(x, y))
+	}
+
+	if m.Image != "" && !*fEmbed {
+		data, err := m.BackgroundImage(1)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "map-import: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(imagePath, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "map-import: unable to write image: %v\n", err)
+			os.Exit(1)
+		}
+		if *fImageID != "" {
+			options = append(options, uvtt.WithImage(imageName, *fImageID, false))
+		} else {
+			options = append(options, uvtt.WithImage(imageName, imagePath, true))
+		}
+	} else if m.Image != "" {
+		options = append(options, uvtt.WithImage(imageName, "", false))
+	}
+
+	objects, err := m.MapObjects(options...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "map-import: %v\n", err)
+		os.Exit(1)
+	}
+	if err := mapper.WriteMapFile(outPath, objects, mapper.MapMetaData{Location: *fLocation, Comment: comment}); err != nil {
+		fmt.Fprintf(os.Stderr, "map-import: unable to write map: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseCoordinates interprets a pair of coordinates given as x,y, where each value
+// is in map pixels, or in grid squares if followed by "g".
+func parseCoordinates(s string) (float64, float64, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("coordinates \"%s\" must be given as x,y", s)
+	}
+	var values [2]float64
+	for i, f := range fields {
+		f = strings.TrimSpace(f)
+		unit := 1.0
+		if g, ok := strings.CutSuffix(f, "g"); ok {
+			f, unit = g, uvtt.GridSize
+		}
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("coordinates \"%s\" have invalid value \"%s\"", s, fields[i])
+		}
+		values[i] = v * unit
+	}
+	return values[0], values[1], nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
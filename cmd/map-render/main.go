@@ -0,0 +1,262 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+#
+# Adapted for the Pathfinder RPG, which is what we're playing now
+# (and this software is primarily for our own use in our play group,
+# anyway, but could be generalized later as a stand-alone product).
+#
+# Copyright (c) 2025 by Steven L. Willoughby, Aloha, Oregon, USA.
+# All Rights Reserved.
+# Licensed under the terms and conditions of the BSD 3-Clause license.
+#
+# Based on earlier code by the same author, unreleased for the author's
+# personal use; copyright (c) 1992-2019.
+#
+########################################################################
+*/
+
+/*
+Map-render draws a GMA map file as an SVG document or PNG image, so that the map
+may be viewed, printed, or posted without running the mapper client.
+
+Map elements are drawn in order of their Z coordinates, with creature tokens
+on top of them showing their names, health, elevation, and (optionally) the
+areas they threaten. Hidden elements and creatures are left off the map unless
+the GM's view is asked for, in which case they are drawn partially transparent.
+Tiles are drawn with their images if they can be found (see -images), or as
+placeholders showing their bounding boxes and image names if not.
+
+By default the image includes everything on the map. The -region option
+crops it to part of the map instead.
+
+# SYNOPSIS
+
+(If using the full GMA core tool suite)
+
+	gma go map-render ...
+
+(Otherwise)
+
+	map-render -help
+	map-render [-background color] [-format svg|png] [-gm] [-grid] [-grid-color color] [-images dir] [-margin n] [-o path] [-region x,y,w,h] [-scale factor] [-threat-zones] file.map
+
+# OPTIONS
+
+	-background color
+	   Fill the background with this color instead of white. If "none", the background is left transparent.
+
+	-format svg|png
+	   Write the map in this format. By default, this is taken from the output file's suffix, or is svg if writing to the standard output.
+
+	-gm
+	   Draw the map as the GM sees it, including hidden elements and creatures,
+	   and with creatures' health shown without blurring.
+
+	-grid
+	   Overlay the 5-foot grid on the map.
+
+	-grid-color color
+	   Draw the grid in this color instead of blue (implies -grid).
+
+	-images dir
+	   Look for tile images in this directory. Local image files named with relative paths
+	   are found relative to it, and images known by their server IDs are looked for there
+	   under that ID with a .png, .gif, or .jpg suffix.
+
+	-margin n
+	   Leave n map pixels (10 per foot) of space around the objects on the map (default 10).
+
+	-o path
+	   Write the image to the named file instead of the standard output.
+
+	-region x,y,w,h
+	   Only draw the region of the map w map pixels wide and h high whose upper-left corner is at (x, y).
+	   The coordinates may also be given in grid squares by adding a "g" suffix to each, as in "2g,4g,10g,8g".
+
+	-scale factor
+	   Draw the map at this scale (default 1, where each 5-foot grid square is 50 pixels).
+
+	-threat-zones
+	   Draw the areas each creature threatens with their natural or extended reach (or both).
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	"github.com/MadScienceZone/go-gma/v5/maprender"
+)
+
+func main() {
+	var fBackground = flag.String("background", "white", "background color, or \"none\" for a transparent background")
+	var fFormat = flag.String("format", "", "output format (svg or png; default from the output file name)")
+	var fGM = flag.Bool("gm", false, "draw the GM's view of the map, including hidden objects")
+	var fGrid = flag.Bool("grid", false, "overlay the 5-foot grid")
+	var fGridColor = flag.String("grid-color", "", "color of the grid")
+	var fImages = flag.String("images", "", "directory holding tile images")
+	var fMargin = flag.Float64("margin", 10, "space left around the map objects, in map pixels")
+	var fOutput = flag.String("o", "", "write the image to this file instead of the standard output")
+	var fRegion = flag.String("region", "", "only draw the region x,y,w,h of the map (in map pixels, or grid squares with a \"g\" suffix)")
+	var fScale = flag.Float64("scale", 1, "output pixels per map pixel")
+	var fThreatZones = flag.Bool("threat-zones", false, "draw the areas creatures threaten")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: map-render [options] file.map\n")
+		os.Exit(1)
+	}
+	if err := render(flag.Arg(0), *fOutput, *fFormat, *fBackground, *fGridColor, *fImages, *fRegion, *fMargin, *fScale, *fGM, *fGrid, *fThreatZones); err != nil {
+		fmt.Fprintf(os.Stderr, "map-render: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func render(mapPath, outPath, format, background, gridColor, imageDir, region string, margin, scale float64, gm, grid, threatZones bool) error {
+	if format == "" {
+		format = "svg"
+		if strings.EqualFold(filepath.Ext(outPath), ".png") {
+			format = "png"
+		}
+	}
+	format = strings.ToLower(format)
+	if format != "svg" && format != "png" {
+		return fmt.Errorf("unsupported output format \"%s\" (must be svg or png)", format)
+	}
+
+	objs, _, err := mapper.ReadMapFile(mapPath)
+	if err != nil {
+		return fmt.Errorf("%s: %v", mapPath, err)
+	}
+
+	if background == "none" {
+		background = ""
+	}
+	options := []func(*maprender.Renderer) error{
+		maprender.WithBackground(background),
+		maprender.WithMargin(margin),
+		maprender.WithScale(scale),
+		maprender.WithImageDirectory(imageDir),
+	}
+	if gm {
+		options = append(options, maprender.WithGMView())
+	}
+	if grid || gridColor != "" {
+		options = append(options, maprender.WithGrid(gridColor))
+	}
+	if threatZones {
+		options = append(options, maprender.WithThreatZones())
+	}
+	if region != "" {
+		r, err := parseRegion(region)
+		if err != nil {
+			return err
+		}
+		options = append(options, maprender.WithRegion(r))
+	}
+
+	renderer, err := maprender.New(objs, options...)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if outPath != "" {
+		if out, err = os.Create(outPath); err != nil {
+			return err
+		}
+	}
+	if format == "png" {
+		err = renderer.WritePNG(out)
+	} else {
+		err = renderer.WriteSVG(out)
+	}
+	if outPath != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// parseRegion interprets a region given as x,y,w,h, where each value
+// is in map pixels, or in grid squares if followed by "g".
+func parseRegion(s string) (maprender.Region, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 4 {
+		return maprender.Region{}, fmt.Errorf("region \"%s\" must be given as x,y,w,h", s)
+	}
+	var values [4]float64
+	for i, f := range fields {
+		f = strings.TrimSpace(f)
+		unit := 1.0
+		if g, ok := strings.CutSuffix(f, "g"); ok {
+			f, unit = g, maprender.GridSize
+		}
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return maprender.Region{}, fmt.Errorf("region \"%s\" has invalid value \"%s\"", s, fields[i])
+		}
+		values[i] = v * unit
+	}
+	return maprender.Region{X: values[0], Y: values[1], Width: values[2], Height: values[3]}, nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
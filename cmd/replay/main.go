@@ -0,0 +1,428 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+#
+# Adapted for the Pathfinder RPG, which is what we're playing now
+# (and this software is primarily for our own use in our play group,
+# anyway, but could be generalized later as a stand-alone product).
+#
+# Copyright (c) 2025 by Steven L. Willoughby, Aloha, Oregon, USA.
+# All Rights Reserved.
+# Licensed under the terms and conditions of the BSD 3-Clause license.
+#
+# Based on earlier code by the same author, unreleased for the author's
+# personal use; copyright (c) 1992-2019.
+#
+########################################################################
+*/
+
+/*
+Replay plays back a session journal recorded by the server's -journal option, so that a game session
+can be reviewed after the fact, or a lost map recovered.
+
+The journal may be replayed in one of three ways:
+
+With -list, each message in the journal is printed to the standard output along with the time it was
+recorded and (for messages received from clients) who sent it.
+
+With -endpoint, replay connects to a (usually fresh) server as a client and sends it all of the messages
+which changed the map or game state (adding, moving, and removing objects, combat mode, initiative,
+the game clock, chat messages, die rolls, and the like) which the clients sent to the original server.
+These are all sent from the user replay logs in as (normally the GM). Note that die rolls are rolled
+again by the new server so they will not have the same results as the original ones did.
+
+With -listen, replay acts as a server to which mapper clients may connect in order to watch the session
+exactly as the original server presented it to its clients. Replay begins as soon as the first client
+connects. Clients may join later, but will only see the messages replayed after they connected. When
+the replay is finished, the clients remain connected until replay is interrupted.
+
+OPTIONS
+
+	−endpoint [hostname]:port
+	   Replay the session into the server at the specified TCP port.
+
+	−list
+	   Print the contents of the journal.
+
+	−listen [hostname]:port
+	   Accept mapper clients on the specified TCP port and replay the session to them.
+
+	−max-pause duration
+	   Never wait longer than this between messages (e.g., "10s"), to skip over breaks in the game.
+
+	−pass password
+	   Log in to the server with the specified password (with −endpoint).
+
+	−speed factor
+	   Replay the session this many times faster than it was originally played (default 1, meaning to
+	   replay in real time). If 0, the messages are replayed as fast as possible. (With −list, the default
+	   is 0.)
+
+	−user username
+	   Log in to the server with the specified username (default “GM”).
+*/
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/auth"
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+// replayable lists the messages from clients which we send to the server
+// when replaying a journal into it.
+var replayable = map[mapper.ServerMessage]bool{
+	mapper.AddImage:                    true,
+	mapper.AddObjAttributes:            true,
+	mapper.AdjustView:                  true,
+	mapper.ChatMessage:                 true,
+	mapper.Clear:                       true,
+	mapper.ClearFrom:                   true,
+	mapper.CombatMode:                  true,
+	mapper.LoadFrom:                    true,
+	mapper.LoadArcObject:               true,
+	mapper.LoadCircleObject:            true,
+	mapper.LoadLineObject:              true,
+	mapper.LoadPolygonObject:           true,
+	mapper.LoadRectangleObject:         true,
+	mapper.LoadSpellAreaOfEffectObject: true,
+	mapper.LoadTextObject:              true,
+	mapper.LoadTileObject:              true,
+	mapper.Mark:                        true,
+	mapper.PlaceSomeone:                true,
+	mapper.RemoveObjAttributes:         true,
+	mapper.RollDice:                    true,
+	mapper.Toolbar:                     true,
+	mapper.UpdateClock:                 true,
+	mapper.UpdateInitiative:            true,
+	mapper.UpdateObjAttributes:         true,
+	mapper.UpdateStatusMarker:          true,
+	mapper.UpdateTurn:                  true,
+}
+
+func main() {
+	var fEndpoint = flag.String("endpoint", "", "replay the session into the server at this endpoint")
+	var fListen = flag.String("listen", "", "replay the session to mapper clients connecting to this endpoint")
+	var fList = flag.Bool("list", false, "print the contents of the journal")
+	var fUser = flag.String("user", "GM", "username to log in to server as [default=GM]")
+	var fPass = flag.String("pass", "", "password to log in to server")
+	var fSpeed = flag.Float64("speed", -1, "replay speed factor (1=real time, 0=as fast as possible)")
+	var fMaxPause = flag.Duration("max-pause", 0, "maximum time to wait between messages")
+
+	flag.Parse()
+	if flag.NArg() != 1 || countTrue(*fList, *fEndpoint != "", *fListen != "") != 1 {
+		fmt.Fprintf(os.Stderr, "usage: replay -list|-endpoint [host]:port|-listen [host]:port [options] journal\n")
+		os.Exit(1)
+	}
+
+	speed := *fSpeed
+	if speed < 0 {
+		if *fList {
+			speed = 0
+		} else {
+			speed = 1
+		}
+	}
+
+	journal, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		os.Exit(1)
+	}
+	defer journal.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	reader := mapper.NewJournalReader(journal)
+	switch {
+	case *fList:
+		err = listJournal(ctx, reader, speed, *fMaxPause)
+	case *fEndpoint != "":
+		err = replayToServer(ctx, reader, speed, *fMaxPause, *fEndpoint, *fUser, *fPass)
+	default:
+		err = replayToClients(ctx, reader, speed, *fMaxPause, *fListen)
+	}
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
+
+func listJournal(ctx context.Context, reader *mapper.JournalReader, speed float64, maxPause time.Duration) error {
+	return mapper.ReplayJournal(ctx, reader, speed, maxPause, func(e mapper.JournalEntry) error {
+		if e.Direction == mapper.JournalReceived {
+			fmt.Printf("%s %s <%s> %s\n", e.Time.Local().Format("2006-01-02 15:04:05.000"), e.Direction, e.User, e.Message)
+		} else {
+			fmt.Printf("%s %s %s\n", e.Time.Local().Format("2006-01-02 15:04:05.000"), e.Direction, e.Message)
+		}
+		return nil
+	})
+}
+
+//
+// Replaying into a server
+//
+
+func replayToServer(ctx context.Context, reader *mapper.JournalReader, speed float64, maxPause time.Duration, endpoint, user, pass string) error {
+	echoed := make(chan mapper.MessagePayload, 1)
+	ready := make(chan byte, 1)
+
+	server, err := mapper.NewConnection(endpoint,
+		mapper.WithAuthenticator(auth.NewClientAuthenticator(user, []byte(pass), "replay")),
+		mapper.WithSubscription(echoed, mapper.Echo),
+		mapper.WhenReady(ready),
+	)
+	if err != nil {
+		return fmt.Errorf("can't set up server connection: %v", err)
+	}
+	go server.Dial()
+	fmt.Printf("Waiting for server to be ready\n")
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	sent := 0
+	err = mapper.ReplayJournal(ctx, reader, speed, maxPause, func(e mapper.JournalEntry) error {
+		if e.Direction != mapper.JournalReceived {
+			return nil
+		}
+		p, err := e.Payload()
+		if err != nil {
+			fmt.Printf("WARNING: skipping message recorded at %v: %v\n", e.Time, err)
+			return nil
+		}
+		if !replayable[p.MessageType()] {
+			return nil
+		}
+		sent++
+		return server.UNSAFEsendRaw(e.Message)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Replayed %d messages. Server sync...\n", sent)
+	if err := server.EchoString("replay"); err != nil {
+		return fmt.Errorf("can't send echo to server: %v", err)
+	}
+	select {
+	case <-echoed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	fmt.Printf("Done.\n")
+	return nil
+}
+
+//
+// Replaying to mapper clients
+//
+
+// clientQueueSize is how many messages we'll let pile up for a client
+// which isn't keeping up before we disconnect them.
+const clientQueueSize = 1024
+
+type replayClients struct {
+	lock      sync.Mutex
+	clients   map[net.Conn]chan string
+	connected chan byte
+}
+
+func (rc *replayClients) add(conn net.Conn) chan string {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	ch := make(chan string, clientQueueSize)
+	rc.clients[conn] = ch
+	select {
+	case rc.connected <- 0:
+	default:
+	}
+	return ch
+}
+
+func (rc *replayClients) remove(conn net.Conn) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if ch, ok := rc.clients[conn]; ok {
+		close(ch)
+		delete(rc.clients, conn)
+		conn.Close()
+	}
+}
+
+func (rc *replayClients) sendToAll(message string) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	for conn, ch := range rc.clients {
+		select {
+		case ch <- message:
+		default:
+			fmt.Printf("client %v is not keeping up; disconnecting\n", conn.RemoteAddr())
+			close(ch)
+			delete(rc.clients, conn)
+			conn.Close()
+		}
+	}
+}
+
+// serveClient sends the server's greeting to a newly-connected client and
+// then whatever messages are replayed from that point on. Anything the client
+// sends to us is ignored.
+func (rc *replayClients) serveClient(conn net.Conn) {
+	ch := rc.add(conn)
+	fmt.Printf("client connected from %v\n", conn.RemoteAddr())
+
+	go func() {
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+		}
+		fmt.Printf("client %v disconnected\n", conn.RemoteAddr())
+		rc.remove(conn)
+	}()
+
+	greeting, err := mapper.FormatMessage(mapper.Challenge, mapper.ChallengeMessagePayload{
+		Protocol:   mapper.GMAMapperProtocol,
+		ServerTime: time.Now(),
+	})
+	if err != nil {
+		fmt.Printf("unable to greet client: %v\n", err)
+		rc.remove(conn)
+		return
+	}
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "PROTOCOL %d\n%s\nREADY\n", mapper.GMAMapperProtocol, greeting)
+	if err := w.Flush(); err != nil {
+		rc.remove(conn)
+		return
+	}
+
+	for message := range ch {
+		w.WriteString(message)
+		w.WriteByte('\n')
+		if len(ch) == 0 {
+			if err := w.Flush(); err != nil {
+				rc.remove(conn)
+				return
+			}
+		}
+	}
+}
+
+func replayToClients(ctx context.Context, reader *mapper.JournalReader, speed float64, maxPause time.Duration, endpoint string) error {
+	incoming, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	defer incoming.Close()
+
+	rc := &replayClients{
+		clients:   make(map[net.Conn]chan string),
+		connected: make(chan byte, 1),
+	}
+	go func() {
+		for {
+			conn, err := incoming.Accept()
+			if err != nil {
+				return
+			}
+			go rc.serveClient(conn)
+		}
+	}()
+
+	fmt.Printf("Waiting for a client to connect to %s\n", endpoint)
+	select {
+	case <-rc.connected:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	err = mapper.ReplayJournal(ctx, reader, speed, maxPause, func(e mapper.JournalEntry) error {
+		if e.Direction == mapper.JournalSent && !strings.HasPrefix(e.Message, "//") {
+			rc.sendToAll(e.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Replay finished. Interrupt to disconnect clients and exit.\n")
+	<-ctx.Done()
+	return nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -43,7 +43,7 @@ It can be used interactively by users or it can be embedded in scripts for web-b
 
 	roll -help
 	roll -syntax
-	roll [-seed value] [-dice spec] [-json]
+	roll [-seed value] [-dice spec] [-json] [-odds]
 
 # OPTIONS
 
@@ -60,6 +60,13 @@ Options which take parameter values may have the value separated from the option
 	  -json
 	      Print die-roll results in JSON format.
 
+	  -odds
+	      Instead of rolling the dice, print the probability of each possible result, along with the
+	      mean and standard deviation, and the chance of success if the expression includes a "| dc"
+	      option. This is useful for comparing options (such as using Power Attack or not) before
+	      committing to a roll. In interactive mode, the same report may be obtained by typing "odds"
+	      followed by the die-roll expression.
+
 	  -seed value
 	      Instead of using a random seed value, base the die roll results on the given value.
 		  Value is a 64-bit integer expressed in decimal digits.
@@ -72,6 +79,7 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -91,7 +99,7 @@ func main() {
 	var seedUsed int64
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [-help] [-dice spec] [-json] [-seed value] [-syntax]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-help] [-dice spec] [-json] [-odds] [-seed value] [-syntax]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  An option 'x' with a value may be set by '-x value', '-x=value', '--x value', or '--x=value'.\n")
 		fmt.Fprintf(os.Stderr, "  A flag 'x' may be set by '-x', '--x', '-x=true|false' or '--x=true|false'\n")
 		fmt.Fprintf(os.Stderr, "  Options may NOT be combined into a single argument (use '-h -m', not '-hm').\n")
@@ -101,6 +109,7 @@ func main() {
 	help := flag.Bool("help", false, "list command-line options and exit")
 	rollSpec := flag.String("dice", "", "die-roll expression(s) to be rolled (semicolon-separated) (interactive if this is not given)")
 	asJSON := flag.Bool("json", false, "print results in JSON")
+	showOdds := flag.Bool("odds", false, "print the probability distribution of the results instead of rolling")
 	seedValue := flag.Int64("seed", 0, "seed value (0 for random)")
 	syntaxHelp := flag.Bool("syntax", false, "print die-roll syntax description and exit")
 	flag.Parse()
@@ -140,16 +149,29 @@ func main() {
 
 	if *rollSpec != "" {
 		for i, thisRoll := range strings.Split(*rollSpec, ";") {
-			title, results, err := roller.DoRoll(thisRoll)
-			if err != nil {
-				fmt.Printf("Error in die-roll expression #%d: %v\n", i+1, err)
-				os.Exit(1)
-			}
-			r := ReportedResultSet{
-				Title:   title,
-				Results: results,
+			var r ReportedResultSet
+			if *showOdds {
+				title, dists, err := roller.Distribution(thisRoll)
+				if err != nil {
+					reportError(fmt.Sprintf("Error in die-roll expression #%d", i+1), err)
+					os.Exit(1)
+				}
+				r = ReportedResultSet{
+					Title:         title,
+					Distributions: dists,
+				}
+			} else {
+				title, results, err := roller.DoRoll(thisRoll)
+				if err != nil {
+					reportError(fmt.Sprintf("Error in die-roll expression #%d", i+1), err)
+					os.Exit(1)
+				}
+				r = ReportedResultSet{
+					Title:   title,
+					Results: results,
+				}
+				r.CalculateStats()
 			}
-			r.CalculateStats()
 			report.AddResult(r)
 		}
 
@@ -162,7 +184,7 @@ func main() {
 			report.WriteText(os.Stdout)
 		}
 	} else {
-		fmt.Println("Enter each die-roll expression below.\nType \"help\" to see a syntax description.\nEOF terminates.")
+		fmt.Println("Enter each die-roll expression below.\nType \"help\" to see a syntax description.\nType \"odds\" before an expression to see its probability distribution.\nEOF terminates.")
 		scanner := bufio.NewScanner(os.Stdin)
 
 		for scanner.Scan() {
@@ -172,10 +194,21 @@ func main() {
 				} else {
 					fmt.Printf("Unable to print help text: %v\n", err)
 				}
+			} else if spec, isOdds := strings.CutPrefix(scanner.Text(), "odds "); isOdds || *showOdds {
+				title, dists, err := roller.Distribution(spec)
+				if err != nil {
+					reportError("ERROR", err)
+				} else {
+					r := ReportedResultSet{
+						Title:         title,
+						Distributions: dists,
+					}
+					r.WriteText(os.Stdout)
+				}
 			} else {
 				title, results, err := roller.DoRoll(scanner.Text())
 				if err != nil {
-					fmt.Printf("ERROR: %v\n", err)
+					reportError("ERROR", err)
 				} else {
 					r := ReportedResultSet{
 						Title:   title,
@@ -189,6 +222,18 @@ func main() {
 	}
 }
 
+//
+// reportError prints an error in a die-roll expression, along with
+// a marker pointing to where the problem was found if we know that.
+//
+func reportError(prefix string, err error) {
+	fmt.Printf("%s: %v\n", prefix, err)
+	var se *dice.SyntaxError
+	if errors.As(err, &se) {
+		fmt.Println(se.Marker())
+	}
+}
+
 //
 // ReportedData describes the full output of an invocation of the die roller.
 // This includes overall metadata such as the PRNG seed value and a slice of
@@ -216,9 +261,10 @@ type ResultStats struct {
 // may involve multiple dice being rolled.
 //
 type ReportedResultSet struct {
-	Title   string `json:",omitempty"`
-	Results []dice.StructuredResult
-	Stats   *ResultStats `json:",omitempty"`
+	Title         string `json:",omitempty"`
+	Results       []dice.StructuredResult
+	Stats         *ResultStats            `json:",omitempty"`
+	Distributions []dice.RollDistribution `json:",omitempty"`
 }
 
 //
@@ -339,6 +385,10 @@ func (rs ReportedResultSet) WriteText(o io.Writer) {
 		o.Write([]byte("\033[1m\"" + rs.Title + "\":\033[0m\n"))
 	}
 
+	for _, dist := range rs.Distributions {
+		writeDistributionText(o, dist)
+	}
+
 	for i, res := range rs.Results {
 		if len(rs.Results) > 1 {
 			o.Write([]byte(fmt.Sprintf("\033[1;34mRoll #%d: \033[0m", i+1)))
@@ -372,3 +422,28 @@ func (rs ReportedResultSet) WriteText(o io.Writer) {
 			rs.Stats.Sum)))
 	}
 }
+
+//
+// writeDistributionText outputs the probability distribution of a die roll
+// in plain text format, with a bar graph of the odds of each result.
+//
+func writeDistributionText(o io.Writer, dist dice.RollDistribution) {
+	o.Write([]byte(fmt.Sprintf("\033[1m%s\033[0m: %d-%d, μ=%.2f, σ=%.2f\n",
+		dist.Expression, dist.Min, dist.Max, dist.Mean, dist.StdDev())))
+	if dist.DC != 0 {
+		o.Write([]byte(fmt.Sprintf("\033[1;32mDC %d: %.2f%% chance of success\033[0m\n", dist.DC, dist.Success*100)))
+	}
+
+	var largest float64
+	for _, p := range dist.P {
+		largest = max(largest, p)
+	}
+	for i, p := range dist.P {
+		if p == 0 {
+			continue
+		}
+		v := dist.Min + i
+		o.Write([]byte(fmt.Sprintf("%6d %7.3f%% \033[36m≥%7.3f%%\033[0m %s\n",
+			v, p*100, dist.AtLeast(v)*100, strings.Repeat("\u2588", int(math.Round(p/largest*40))))))
+	}
+}
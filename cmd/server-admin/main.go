@@ -0,0 +1,341 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+#
+# Adapted for the Pathfinder RPG, which is what we're playing now
+# (and this software is primarily for our own use in our play group,
+# anyway, but could be generalized later as a stand-alone product).
+#
+# Copyright (c) 2025 by Steven L. Willoughby, Aloha, Oregon, USA.
+# All Rights Reserved.
+# Licensed under the terms and conditions of the BSD 3-Clause license.
+#
+# Based on earlier code by the same author, unreleased for the author's
+# personal use; copyright (c) 1992-2019.
+#
+########################################################################
+*/
+
+/*
+Server-admin controls a running map server through its administrative control channel,
+the Unix-domain socket named by the server's -admin-socket option. Anyone who can
+connect to that socket (normally only the user running the server) may use this program.
+
+# SYNOPSIS
+
+	server-admin -socket path [-campaign name] [-json] command [arguments...]
+
+# OPTIONS
+
+	−socket path
+	   The path of the server's administrative socket.
+
+	−campaign name
+	   If the server hosts several campaigns, act upon the named one instead of the
+	   server's main campaign.
+
+	−json
+	   Print the server's full response as JSON instead of summarizing it.
+
+# COMMANDS
+
+	clients
+	   List the connected clients, with their network addresses, user names, client
+	   programs, and how long ago they last answered a ping.
+
+	kick client
+	   Disconnect the client with the given network address, or all clients logged in
+	   with the given user name.
+
+	mute client
+	   Stop passing on chat messages and die rolls from the client(s), as for kick.
+
+	unmute client
+	   Allow chat messages and die rolls from the client(s) again.
+
+	reload
+	   Re-read the server's initialization and password files (and its configuration
+	   file, if it has one).
+
+	debug [flags]
+	   Print the server's debugging flags, first setting them to the comma-separated
+	   list of flags if given (as for the server's -debug option).
+
+	qos [limits]
+	   Print the server's QoS limits, first setting them if limits are given (as a JSON
+	   object in the form of the QOS command in the server's initialization file).
+	   New limits apply to clients which connect after they are set.
+
+	notice text...
+	   Send the text to all clients as a chat message.
+
+	state
+	   Print the current game state as JSON.
+
+	roll message-id
+	   Print the audit record for the die roll whose result was sent as the chat message
+	   with the given ID: who rolled it, the roll specification and die-roll variables
+	   used, who it was sent to, the random seed and the commitment hash sent with the
+	   result, and the results. The server rolls the dice again with the same seed to
+	   verify that the record is consistent, and reports an error if it is not.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+func main() {
+	var fSocket = flag.String("socket", "", "path of the server's administrative socket")
+	var fCampaign = flag.String("campaign", "", "act upon the named campaign")
+	var fJSON = flag.Bool("json", false, "print the server's full response as JSON")
+
+	flag.Parse()
+	if *fSocket == "" || flag.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "usage: server-admin -socket path [-campaign name] [-json] clients|kick client|mute client|unmute client|reload|debug [flags]|qos [limits]|notice text...|state|roll message-id\n")
+		os.Exit(1)
+	}
+	if err := run(*fSocket, *fCampaign, *fJSON, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "server-admin: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(socket, campaign string, asJSON bool, args []string) error {
+	request, err := makeRequest(args)
+	if err != nil {
+		return err
+	}
+	request.Campaign = campaign
+
+	response, err := mapper.AdminCommand(socket, request)
+	// a die roll which fails verification is still reported
+	if err != nil && response.DieRoll == nil {
+		return err
+	}
+
+	if asJSON {
+		if jerr := printJSON(response); jerr != nil {
+			return jerr
+		}
+		return err
+	}
+
+	switch request.Command {
+	case mapper.AdminListClients:
+		out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(out, "ADDRESS\tUSER\tCLIENT\tLAST PING\tNOTES")
+		for _, c := range response.Clients {
+			var notes []string
+			if !c.IsAuthenticated {
+				notes = append(notes, "not logged in")
+			}
+			if c.GM {
+				notes = append(notes, "GM")
+			}
+			if c.CertificateUser != "" {
+				notes = append(notes, "certificate "+c.CertificateUser)
+			}
+			if c.Muted {
+				notes = append(notes, "muted")
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s\t%.0fs ago\t%s\n", c.Addr, c.User, c.Client, c.LastPolo, strings.Join(notes, ", "))
+		}
+		out.Flush()
+		fmt.Println(response.Message)
+
+	case mapper.AdminDebug:
+		fmt.Println(response.DebugFlags)
+
+	case mapper.AdminQoS:
+		if response.Message != "" {
+			fmt.Println(response.Message)
+		}
+		return printJSON(response.QoS)
+
+	case mapper.AdminDumpState:
+		return printJSON(response.GameState)
+
+	case mapper.AdminDieRoll:
+		printDieRoll(response.DieRoll)
+		if err != nil {
+			return err
+		}
+		fmt.Println(response.Message)
+
+	default:
+		fmt.Println(response.Message)
+	}
+	return nil
+}
+
+// makeRequest builds the request for the command given on the command line.
+func makeRequest(args []string) (mapper.AdminRequest, error) {
+	request := mapper.AdminRequest{Command: args[0]}
+	args = args[1:]
+
+	switch request.Command {
+	case mapper.AdminListClients, mapper.AdminReload, mapper.AdminDumpState:
+		if len(args) != 0 {
+			return request, fmt.Errorf("%s takes no arguments", request.Command)
+		}
+
+	case mapper.AdminKick, mapper.AdminMute, mapper.AdminUnmute:
+		if len(args) != 1 {
+			return request, fmt.Errorf("%s requires a client address or user name", request.Command)
+		}
+		request.Client = args[0]
+
+	case mapper.AdminDebug:
+		if len(args) > 1 {
+			return request, fmt.Errorf("%s takes at most one list of flags", request.Command)
+		}
+		if len(args) == 1 {
+			request.DebugFlags = args[0]
+		}
+
+	case mapper.AdminQoS:
+		if len(args) > 1 {
+			return request, fmt.Errorf("%s takes at most one set of limits", request.Command)
+		}
+		if len(args) == 1 {
+			if !json.Valid([]byte(args[0])) {
+				return request, fmt.Errorf("QoS limits must be given as a JSON object")
+			}
+			request.QoS = json.RawMessage(args[0])
+		}
+
+	case mapper.AdminNotice:
+		if len(args) == 0 {
+			return request, fmt.Errorf("%s requires the text to send", request.Command)
+		}
+		request.Text = strings.Join(args, " ")
+
+	case mapper.AdminDieRoll:
+		if len(args) != 1 {
+			return request, fmt.Errorf("%s requires a message ID", request.Command)
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil || id <= 0 {
+			return request, fmt.Errorf("invalid message ID \"%s\"", args[0])
+		}
+		request.MessageID = id
+
+	default:
+		return request, fmt.Errorf("unknown command \"%s\"", request.Command)
+	}
+	return request, nil
+}
+
+// printDieRoll describes a die-roll audit record.
+func printDieRoll(audit *mapper.DieRollAudit) {
+	var to string
+	switch {
+	case audit.ToGM:
+		to = "GM"
+	case audit.ToAll:
+		to = "all"
+	default:
+		to = strings.Join(append([]string{audit.Requester}, audit.Recipients...), ", ")
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(out, "Rolled:\t%s\n", audit.Sent.Format(time.RFC3339))
+	fmt.Fprintf(out, "By:\t%s\n", audit.Requester)
+	fmt.Fprintf(out, "Sent to:\t%s\n", to)
+	fmt.Fprintf(out, "Roll:\t%s\n", audit.RollSpec)
+	names := make([]string, 0, len(audit.Variables))
+	for name := range audit.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "\t$%s=%s\n", name, audit.Variables[name])
+	}
+	fmt.Fprintf(out, "Seed:\t%d\n", audit.Seed)
+	fmt.Fprintf(out, "Commitment:\t%s\n", audit.Commitment)
+	for i, r := range audit.Results {
+		id := 0
+		if i < len(audit.MessageIDs) {
+			id = audit.MessageIDs[i]
+		}
+		details, err := r.Details.Text()
+		if err != nil {
+			details = fmt.Sprintf("(%v)", err)
+		}
+		fmt.Fprintf(out, "Result #%d:\t%s\n", id, details)
+	}
+	out.Flush()
+}
+
+func printJSON(data any) error {
+	out, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,266 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+#
+# Adapted for the Pathfinder RPG, which is what we're playing now
+# (and this software is primarily for our own use in our play group,
+# anyway, but could be generalized later as a stand-alone product).
+#
+# Copyright (c) 2025 by Steven L. Willoughby, Aloha, Oregon, USA.
+# All Rights Reserved.
+# Licensed under the terms and conditions of the BSD 3-Clause license.
+#
+# Based on earlier code by the same author, unreleased for the author's
+# personal use; copyright (c) 1992-2019.
+#
+########################################################################
+*/
+
+/*
+Server-passwd maintains the password file used by the map server's -password-file option.
+It can create a new file which stores only salted, hashed keys derived from the passwords,
+convert an existing plaintext password file into that form, and add, change, or remove
+the passwords in it.
+
+The file is rewritten by replacing it with a new copy which is readable only by its owner.
+A running server reads the new passwords when it is sent a USR1 signal.
+
+Passwords are read from the standard input, one per line. If the standard input is a terminal,
+a prompt is printed first (but note that the password will be echoed as it is typed).
+
+# OPTIONS
+
+Exactly one of the following actions must be given, followed by the name of the password file.
+
+	−init
+	   Create a new hashed password file, replacing any that already exists. The group (player)
+	   password and GM password are read from the standard input.
+
+	−migrate
+	   Convert an existing plaintext password file into a hashed password file. The original file is
+	   kept with the same name plus a .bak suffix.
+
+	−group
+	   Change the group (player) password.
+
+	−gm
+	   Change the GM password. An empty password disables GM logins.
+
+	−set username
+	   Add a personal password for username, or change the one they already have.
+
+	−remove username
+	   Remove the personal password for username.
+
+	−list
+	   List the users who have personal passwords.
+
+Additionally, the following option may be given with −init or −migrate:
+
+	−iterations n
+	   Use n rounds of hashing to derive the keys from the passwords (default 4096). Clients must
+	   perform this many hash rounds each time they log in.
+
+If a plaintext password file is changed with −group, −gm, −set, or −remove, it remains in
+plaintext form.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/MadScienceZone/go-gma/v5/auth"
+)
+
+func main() {
+	var fInit = flag.Bool("init", false, "create a new hashed password file")
+	var fMigrate = flag.Bool("migrate", false, "convert a plaintext password file to hashed form")
+	var fGroup = flag.Bool("group", false, "change the group (player) password")
+	var fGm = flag.Bool("gm", false, "change the GM password")
+	var fSet = flag.String("set", "", "add or change the personal password for a user")
+	var fRemove = flag.String("remove", "", "remove the personal password for a user")
+	var fList = flag.Bool("list", false, "list users with personal passwords")
+	var fIterations = flag.Int("iterations", auth.DefaultKeyIterations, "number of hash rounds for -init and -migrate")
+
+	flag.Parse()
+	actions := 0
+	for _, set := range []bool{*fInit, *fMigrate, *fGroup, *fGm, *fSet != "", *fRemove != "", *fList} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 || flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: server-passwd -init|-migrate|-group|-gm|-set user|-remove user|-list [-iterations n] file\n")
+		os.Exit(1)
+	}
+	if err := run(flag.Arg(0), *fInit, *fMigrate, *fGroup, *fGm, *fSet, *fRemove, *fList, *fIterations); err != nil {
+		fmt.Fprintf(os.Stderr, "server-passwd: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(filename string, initFile, migrate, group, gm bool, set, remove string, list bool, iterations int) error {
+	input := bufio.NewScanner(os.Stdin)
+
+	if initFile {
+		passwords, err := auth.NewHashedPasswordFile(iterations)
+		if err != nil {
+			return err
+		}
+		groupPass, err := readPassword(input, "Group (player) password: ")
+		if err != nil {
+			return err
+		}
+		if len(groupPass) == 0 {
+			return fmt.Errorf("the group password may not be empty")
+		}
+		gmPass, err := readPassword(input, "GM password (empty to disable GM logins): ")
+		if err != nil {
+			return err
+		}
+		passwords.SetGroupPassword(groupPass)
+		passwords.SetGmPassword(gmPass)
+		return passwords.Save(filename)
+	}
+
+	passwords, err := auth.ReadPasswordFile(filename)
+	if err != nil {
+		return err
+	}
+	for _, w := range passwords.Warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s, %s (this line will be dropped when the file is rewritten)\n", filename, w)
+	}
+
+	switch {
+	case migrate:
+		if passwords.Hashed {
+			return fmt.Errorf("%s is already a hashed password file", filename)
+		}
+		if err = passwords.ConvertToHashed(iterations); err != nil {
+			return err
+		}
+		if err = os.Link(filename, filename+".bak"); err != nil {
+			return fmt.Errorf("unable to keep backup copy: %v", err)
+		}
+		fmt.Printf("Converted %s to hashed form (original kept as %s.bak)\n", filename, filename)
+
+	case group:
+		p, err := readPassword(input, "New group (player) password: ")
+		if err != nil {
+			return err
+		}
+		if len(p) == 0 {
+			return fmt.Errorf("the group password may not be empty")
+		}
+		passwords.SetGroupPassword(p)
+
+	case gm:
+		p, err := readPassword(input, "New GM password (empty to disable GM logins): ")
+		if err != nil {
+			return err
+		}
+		passwords.SetGmPassword(p)
+
+	case set != "":
+		p, err := readPassword(input, fmt.Sprintf("New password for %s: ", set))
+		if err != nil {
+			return err
+		}
+		if err = passwords.SetPersonalPassword(set, p); err != nil {
+			return err
+		}
+
+	case remove != "":
+		if !passwords.RemovePersonalPassword(remove) {
+			return fmt.Errorf("%s does not have a personal password", remove)
+		}
+
+	case list:
+		if passwords.Hashed {
+			fmt.Printf("%s: hashed, %d key iterations\n", filename, passwords.KeyIterations)
+		} else {
+			fmt.Printf("%s: plaintext\n", filename)
+		}
+		if len(passwords.GmSecret) == 0 {
+			fmt.Println("GM logins are disabled")
+		}
+		for _, user := range passwords.Users() {
+			fmt.Println(user)
+		}
+		return nil
+	}
+
+	return passwords.Save(filename)
+}
+
+// readPassword reads a line from the standard input, prompting for it
+// first if that is a terminal.
+func readPassword(input *bufio.Scanner, prompt string) ([]byte, error) {
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Print(prompt)
+	}
+	if !input.Scan() {
+		if err := input.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("no password given")
+	}
+	return append([]byte{}, input.Bytes()...), nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
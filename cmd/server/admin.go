@@ -0,0 +1,319 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// The administrative control channel, by which a local administrator
+// may inspect and adjust the running server.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	"github.com/MadScienceZone/go-gma/v5/util"
+)
+
+// listenForAdmin opens the Unix-domain socket on which we accept
+// administrative commands. Since anyone who can connect to it has full
+// control of the server, the socket is made accessible only to the
+// user running the server.
+func (a *Application) listenForAdmin() (net.Listener, error) {
+	if info, err := os.Lstat(a.AdminSocket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", a.AdminSocket)
+		}
+		// left over from a previous run
+		if err := os.Remove(a.AdminSocket); err != nil {
+			return nil, err
+		}
+	}
+	incoming, err := net.Listen("unix", a.AdminSocket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open administrative socket %s: %v", a.AdminSocket, err)
+	}
+	if err := os.Chmod(a.AdminSocket, 0600); err != nil {
+		incoming.Close()
+		return nil, fmt.Errorf("unable to restrict access to administrative socket %s: %v", a.AdminSocket, err)
+	}
+	a.Logf("Listening for administrative commands on %s", a.AdminSocket)
+	return incoming, nil
+}
+
+// acceptAdminConnections serves each administrator who connects to the
+// incoming socket until it is closed.
+func acceptAdminConnections(incoming net.Listener, app *Application) {
+	for {
+		conn, err := incoming.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			app.Logf("incoming administrative connection: %v", err)
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			app.Debug(DebugIO, "administrative client connected")
+			if err := mapper.ServeAdminConnection(conn, app.HandleAdminRequest); err != nil {
+				app.Logf("administrative connection: %v", err)
+			}
+			app.Debug(DebugIO, "administrative client disconnected")
+		}()
+	}
+}
+
+// HandleAdminRequest carries out a request received on the administrative
+// control channel.
+func (a *Application) HandleAdminRequest(request mapper.AdminRequest) mapper.AdminResponse {
+	var response mapper.AdminResponse
+
+	failed := func(format string, args ...any) mapper.AdminResponse {
+		response.Error = fmt.Sprintf(format, args...)
+		a.Logf("administrative %s request failed: %s", request.Command, response.Error)
+		return response
+	}
+
+	c := a
+	if request.Campaign != "" {
+		var ok bool
+		if a.campaigns != nil {
+			c, ok = a.campaigns.byName[request.Campaign]
+		}
+		if !ok {
+			return failed("there is no campaign called \"%s\" on this server", request.Campaign)
+		}
+	}
+	a.Debugf(DebugMessages, "administrative request %v", request)
+
+	switch request.Command {
+	case mapper.AdminListClients:
+		for _, client := range c.GetClients() {
+			description := mapper.AdminClient{
+				Peer:            describePeer(client),
+				CertificateUser: client.CertificateUser,
+				Muted:           c.isMuted(client),
+			}
+			if client.Auth != nil {
+				description.GM = client.Auth.GmMode
+			}
+			response.Clients = append(response.Clients, description)
+		}
+		response.Message = fmt.Sprintf("%d %s connected", len(response.Clients), util.PluralizeString("client", len(response.Clients)))
+
+	case mapper.AdminKick, mapper.AdminMute, mapper.AdminUnmute:
+		clients := c.matchingClients(request.Client)
+		if len(clients) == 0 {
+			return failed("no connected client matches \"%s\"", request.Client)
+		}
+		for _, client := range clients {
+			switch request.Command {
+			case mapper.AdminKick:
+				c.Logf("administrator disconnected client %v", client.IdTag())
+				client.Conn.Close()
+			case mapper.AdminMute:
+				c.Logf("administrator muted client %v", client.IdTag())
+				c.setMuted(client, true)
+			case mapper.AdminUnmute:
+				c.Logf("administrator unmuted client %v", client.IdTag())
+				c.setMuted(client, false)
+			}
+		}
+		response.Message = fmt.Sprintf("%s %d %s", map[string]string{
+			mapper.AdminKick:   "disconnected",
+			mapper.AdminMute:   "muted",
+			mapper.AdminUnmute: "unmuted",
+		}[request.Command], len(clients), util.PluralizeString("client", len(clients)))
+
+	case mapper.AdminReload:
+		c.Log("administrator requested configuration reload")
+		if err := c.ReloadConfigFile(); err != nil {
+			c.Logf("WARNING: %v", err)
+			return failed("%v", err)
+		}
+		response.Message = "configuration reloaded"
+
+	case mapper.AdminDebug:
+		if request.DebugFlags != "" {
+			level, err := NamedDebugFlags(request.DebugFlags)
+			if err != nil {
+				return failed("%v", err)
+			}
+			c.setDebugLevel(level)
+			clientLevel, _ := mapper.NamedDebugFlags(DebugFlagNameSlice(level)...)
+			for _, client := range c.GetClients() {
+				client.DebuggingLevel = clientLevel
+			}
+			c.Logf("administrator set debugging flags to %s", DebugFlagNames(level))
+		}
+		response.DebugFlags = DebugFlagNames(c.currentDebugLevel())
+
+	case mapper.AdminQoS:
+		if len(request.QoS) > 0 {
+			var limits QoSLimitsDescription
+			if err := json.Unmarshal(request.QoS, &limits); err != nil {
+				return failed("invalid QoS limits: %v", err)
+			}
+			if err := c.setQoSLimits(limits); err != nil {
+				return failed("invalid QoS limits: %v", err)
+			}
+			response.Message = "new limits apply to clients connecting from now on"
+		}
+		limits, err := json.Marshal(c.currentQoSLimits())
+		if err != nil {
+			return failed("unable to report QoS limits: %v", err)
+		}
+		response.QoS = limits
+
+	case mapper.AdminNotice:
+		if request.Text == "" {
+			return failed("no notice text given")
+		}
+		if err := c.SendNotice(request.Text); err != nil {
+			return failed("%v", err)
+		}
+		response.Message = "notice sent"
+
+	case mapper.AdminDumpState:
+		state, err := c.DumpGameState()
+		if err != nil {
+			return failed("unable to collect game state: %v", err)
+		}
+		response.GameState = make(map[string]mapper.AdminGameStateEntry)
+		for key, line := range state {
+			if response.GameState[key], err = mapper.NewAdminGameStateEntry(line); err != nil {
+				return failed("unable to represent game state element %s: %v", key, err)
+			}
+		}
+
+	case mapper.AdminDieRoll:
+		audit, err := c.QueryDieRollAudit(request.MessageID)
+		if err != nil {
+			return failed("%v", err)
+		}
+		response.DieRoll = &audit
+		if err := audit.Verify(); err != nil {
+			return failed("die roll %d FAILED verification: %v", request.MessageID, err)
+		}
+		response.Message = fmt.Sprintf("die roll %d verified", request.MessageID)
+
+	default:
+		return failed("unknown command")
+	}
+	return response
+}
+
+// matchingClients returns the connected clients whose network address
+// or user name is the given string.
+func (a *Application) matchingClients(target string) []*mapper.ClientConnection {
+	var clients []*mapper.ClientConnection
+	if target == "" {
+		return nil
+	}
+	for _, client := range a.GetClients() {
+		if client.Address == target || (client.Auth != nil && client.Auth.Username == target) {
+			clients = append(clients, client)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Address < clients[j].Address })
+	return clients
+}
+
+// isMuted returns true if the administrator has muted the client.
+func (a *Application) isMuted(c *mapper.ClientConnection) bool {
+	a.muted.lock.Lock()
+	defer a.muted.lock.Unlock()
+	return a.muted.clients[c]
+}
+
+// setMuted mutes or unmutes the client.
+func (a *Application) setMuted(c *mapper.ClientConnection, muted bool) {
+	a.muted.lock.Lock()
+	defer a.muted.lock.Unlock()
+	if !muted {
+		delete(a.muted.clients, c)
+		return
+	}
+	if a.muted.clients == nil {
+		a.muted.clients = make(map[*mapper.ClientConnection]bool)
+	}
+	a.muted.clients[c] = true
+}
+
+// SendNotice sends a chat message from the server to all clients.
+func (a *Application) SendNotice(text string) error {
+	notice := mapper.ChatMessageMessagePayload{
+		ChatCommon: mapper.ChatCommon{
+			MessageID: <-a.MessageIDGenerator,
+			Sent:      time.Now(),
+			ToAll:     true,
+		},
+		Text: text,
+	}
+	if err := a.AddToChatHistory(notice.MessageID, mapper.ChatMessage, notice); err != nil {
+		a.Logf("unable to add notice to chat history: %v", err)
+	}
+	a.Logf("sending notice to all clients: %s", text)
+	return a.SendToAll(mapper.ChatMessage, notice)
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -19,6 +19,7 @@ package main
 
 import (
 	"bufio"
+	"crypto/tls"
 	"database/sql"
 	"encoding/json"
 	"flag"
@@ -35,6 +36,7 @@ import (
 	"github.com/MadScienceZone/go-gma/v5/mapper"
 	"github.com/MadScienceZone/go-gma/v5/util"
 	"github.com/newrelic/go-agent/v3/newrelic"
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
@@ -153,21 +155,50 @@ type Application struct {
 
 	// If DeLugLevel is 0, no extra debugging output will be logged.
 	// Otherwise, it gives a set of debugging topics to report.
+	// Since this may be changed while the server is running, once
+	// the server has started it must only be accessed via the
+	// currentDebugLevel and setDebugLevel methods.
 	DebugLevel DebugFlags
+	debugLock  sync.RWMutex
 
 	// Endpoint is the "[host]:port" string which specifies where our
 	// incoming socket is listening.
 	Endpoint string
 
+	// The name of the campaign we serve. This is empty for the server's
+	// default campaign.
+	CampaignName string
+
+	// If the server hosts several campaigns, this holds all of them.
+	campaigns *campaignRegistry
+
+	// If not empty, this is the "[host]:port" string where we also
+	// accept clients connecting over WebSocket (e.g., from a web browser).
+	WebSocketEndpoint string
+
+	// If non-nil, incoming connections are made over TLS using this
+	// configuration.
+	TLSConfig *tls.Config
+
 	// If not empty, this gives the filename from which we are to read in
 	// the initial client command set.
 	InitFile string
 
+	// If not empty, we read our settings from this configuration file,
+	// and reread it whenever we receive a SIGHUP.
+	ConfigFile string
+	config     struct {
+		data     *ServerConfig
+		loaded   *ServerConfig
+		explicit map[string]bool
+		lock     sync.Mutex
+	}
+
 	// Information given to each connecting client at the start of
 	// their session
 	clientPreamble struct {
 		data   mapper.ClientPreamble
-		reload chan byte
+		reload chan *clientPreambleData
 		fetch  chan *mapper.ClientPreamble
 	}
 
@@ -175,16 +206,21 @@ type Application struct {
 	// from this file.
 	PasswordFile string
 	clientAuth   struct {
-		groupPassword     []byte
-		gmPassword        []byte
-		personalPasswords map[string][]byte
-		lock              sync.RWMutex
+		passwords *auth.PasswordFile
+		lock      sync.RWMutex
 	}
 
 	// Pathname for database file.
 	DatabaseName string
 	sqldb        *sql.DB
 
+	// Pathname of the GMA core database, if any, and our read-only handle to it.
+	CoreDatabaseName string
+	coredb           *sql.DB
+
+	// If not nil, we record the messages passing through the server here.
+	Journal *mapper.Journal
+
 	clientData struct {
 		add       chan *mapper.ClientConnection
 		remove    chan *mapper.ClientConnection
@@ -197,10 +233,30 @@ type Application struct {
 
 	// Current game state
 	gameState struct {
-		sync   chan *mapper.ClientConnection
-		update chan *mapper.MessagePayload
+		sync       chan *mapper.ClientConnection
+		update     chan *mapper.MessagePayload
+		checkpoint chan chan error
+		undo       chan undoRequest
+		dump       chan chan gameStateDump
+		together   chan gameStateChanges
 	}
 
+	// How often to checkpoint the game state to the database.
+	// If zero, the game state is only saved when the server shuts down.
+	SaveInterval time.Duration
+
+	// If true, we start with an empty game state rather than
+	// restoring the one last saved in the database.
+	ResetGameState bool
+
+	// The number of changes to the map which may be undone.
+	// If zero, the undo history is disabled.
+	UndoLimit int
+
+	// If not empty, the GM may save maps to (and load them from)
+	// this directory.
+	MapDirectory string
+
 	// Last time we sent out a ping to all clients.
 	// If this goes too long, it may indicate that the server
 	// has become deadlocked.
@@ -216,6 +272,21 @@ type Application struct {
 
 	// The QoS settings as configured for the server
 	QoSLimits QoSLimitsDescription
+
+	// If not empty, we accept administrative commands on a Unix-domain
+	// socket at this path.
+	AdminSocket string
+
+	// If not empty, this is the "[host]:port" string where we answer
+	// HTTP requests for our operational statistics at /metrics.
+	MetricsEndpoint string
+	metrics         *metricsRegistry
+
+	// Clients whose chat messages and die rolls are being ignored.
+	muted struct {
+		clients map[*mapper.ClientConnection]bool
+		lock    sync.Mutex
+	}
 }
 
 type QoSLimitsDescription struct {
@@ -235,6 +306,53 @@ type QoSLimitsDescription struct {
 	}
 }
 
+// setQoSLimits changes the QoS limits imposed on clients connecting to
+// the server from now on. Durations are taken from the WindowString
+// fields; if those are empty the existing durations are kept.
+func (a *Application) setQoSLimits(data QoSLimitsDescription) error {
+	var err error
+
+	a.QoSLimits.QueryImage.Count = data.QueryImage.Count
+	if data.QueryImage.WindowString != "" {
+		if a.QoSLimits.QueryImage.window, err = time.ParseDuration(data.QueryImage.WindowString); err != nil {
+			a.Debugf(DebugInit, "ERROR in QOS QueryImage Window duration: %v", err)
+			return err
+		}
+	}
+	a.QoSLimits.MessageRate.Count = data.MessageRate.Count
+	if data.MessageRate.WindowString != "" {
+		if a.QoSLimits.MessageRate.window, err = time.ParseDuration(data.MessageRate.WindowString); err != nil {
+			a.Debugf(DebugInit, "ERROR in QOS MessageRate Window duration: %v", err)
+			return err
+		}
+	}
+	if data.Log.WindowString != "" {
+		if a.QoSLimits.Log.window, err = time.ParseDuration(data.Log.WindowString); err != nil {
+			a.Debugf(DebugInit, "ERROR in QOS Log Window duration: %v", err)
+			return err
+		}
+	}
+	a.Logf("Set QoS Limits img=%d/%s rate=%d/%s log=%s",
+		a.QoSLimits.QueryImage.Count,
+		a.QoSLimits.QueryImage.window.String(),
+		a.QoSLimits.MessageRate.Count,
+		a.QoSLimits.MessageRate.window.String(),
+		a.QoSLimits.Log.window.String(),
+	)
+	return nil
+}
+
+// currentQoSLimits returns the QoS limits in effect, with the WindowString
+// fields filled in so they may be reported in the same form as they are
+// given in the initialization file.
+func (a *Application) currentQoSLimits() QoSLimitsDescription {
+	limits := a.QoSLimits
+	limits.QueryImage.WindowString = limits.QueryImage.window.String()
+	limits.MessageRate.WindowString = limits.MessageRate.window.String()
+	limits.Log.WindowString = limits.Log.window.String()
+	return limits
+}
+
 func (a *Application) GetClientPreamble() *mapper.ClientPreamble {
 	a.Debug(DebugInit, "fetching client preamble from generator channel")
 	return <-a.clientPreamble.fetch
@@ -264,9 +382,17 @@ func (a *Application) DropAllClients() {
 	}
 }
 
+// ReloadConfiguration re-reads the initialization file and password file
+// and resets the message ID generator. If either file could not be
+// loaded, an error is returned and neither is changed.
+func (a *Application) ReloadConfiguration() error {
+	return a.applyConfiguration(a.currentConfig(), false)
+}
+
 // RemoveClients removes the given client from the list of connections.
 func (a *Application) RemoveClient(c *mapper.ClientConnection) {
 	a.clientData.remove <- c
+	a.setMuted(c, false)
 	//a.SendPeerListToAll()
 }
 
@@ -347,11 +473,25 @@ func (a *Application) manageClientList() {
 	}
 }
 
+// currentDebugLevel returns the debugging flags currently in effect.
+func (a *Application) currentDebugLevel() DebugFlags {
+	a.debugLock.RLock()
+	defer a.debugLock.RUnlock()
+	return a.DebugLevel
+}
+
+// setDebugLevel changes the debugging flags in effect.
+func (a *Application) setDebugLevel(level DebugFlags) {
+	a.debugLock.Lock()
+	defer a.debugLock.Unlock()
+	a.DebugLevel = level
+}
+
 // Debug logs messages conditionally based on the currently set
 // debug level. It acts just like fmt.Println as far as formatting
 // its arguments.
 func (a *Application) Debug(level DebugFlags, message ...any) {
-	if a != nil && a.Logger != nil && (a.DebugLevel&level) != 0 {
+	if a != nil && a.Logger != nil && (a.currentDebugLevel()&level) != 0 {
 		var dmessage []any
 		dmessage = append(dmessage, DebugFlagNames(level))
 		dmessage = append(dmessage, message...)
@@ -380,7 +520,7 @@ func (a *Application) Logf(format string, args ...any) {
 // Debugf works like Debug, but takes a format string and argument
 // list just like fmt.Printf does.
 func (a *Application) Debugf(level DebugFlags, format string, args ...any) {
-	if a != nil && a.Logger != nil && (a.DebugLevel&level) != 0 {
+	if a != nil && a.Logger != nil && (a.currentDebugLevel()&level) != 0 {
 		a.Logger.Printf(DebugFlagNames(level)+" "+format, args...)
 	}
 }
@@ -388,21 +528,82 @@ func (a *Application) Debugf(level DebugFlags, format string, args ...any) {
 // GetAppOptions configures the application by reading command-line options.
 func (a *Application) GetAppOptions() error {
 
+	var configFile = flag.String("config", "", "Read server settings from the named configuration file (reloaded on SIGHUP)")
 	var initFile = flag.String("init-file", "", "Load initial client commands from named file path")
 	var logFile = flag.String("log-file", "-", "Write log to given pathname (stderr if '-'); special % tokens allowed in path")
 	var passFile = flag.String("password-file", "", "Require authentication with named password file")
 	var endPoint = flag.String("endpoint", ":2323", "Incoming connection endpoint ([host]:port)")
-	//	var saveInterval = flag.String("save-interval", "10m", "Save internal state this often")
+	var wsEndPoint = flag.String("websocket-endpoint", "", "Also accept WebSocket clients at this endpoint ([host]:port)")
+	var tlsCert = flag.String("tls-cert", "", "Accept only TLS connections, using the server certificate in the named PEM file")
+	var tlsKey = flag.String("tls-key", "", "PEM file holding the private key for the -tls-cert certificate")
+	var tlsClientCA = flag.String("tls-client-ca", "", "Accept client certificates signed by the authorities in the named PEM file")
+	var tlsRequireClientCert = flag.Bool("tls-require-client-cert", false, "Refuse TLS clients which don't present a valid client certificate")
+	var saveInterval = flag.String("save-interval", "1m", "Save game state to the database this often (0 to save only at shutdown)")
+	var resetState = flag.Bool("reset-state", false, "Start with an empty game state instead of restoring the last saved one")
+	var undoLimit = flag.Int("undo-limit", DefaultUndoLimit, "Remember this many changes to the map so the GM can undo them (0 disables undo)")
+	var mapDir = flag.String("map-dir", "", "Allow the GM to save and load map files in the named directory")
 	var sqlDbName = flag.String("sqlite", "", "Specify filename for sqlite database to use")
+	var coreDbName = flag.String("coredb", "", "Answer client queries from the specified GMA core database")
+	var campaignList campaignFlags
+	flag.Var(&campaignList, "campaign", "Also host the campaign whose files are in the given directory (name=dir[,[host]:port]); may be repeated")
+	var metricsEndPoint = flag.String("metrics-endpoint", "", "Report operational statistics via HTTP at /metrics on this endpoint ([host]:port)")
+	var adminSocket = flag.String("admin-socket", "", "Accept administrative commands on a Unix-domain socket at the named path")
+	var journalFile = flag.String("journal", "", "Record a journal of the session's messages in the named file; special % tokens allowed in path")
 	var debugFlags = flag.String("debug", "", "List the debugging trace types to enable")
 	var nrLogger = flag.String("telemetry-log", "", "Debugging log for telemetry collection")
 	var nrAppName = flag.String("telemetry-name", "", "Application name for telemetry collection (default: \"gma-server\")")
 	var profFile = flag.String("cpuprofile", "", "CPU Profiling output file (default: no profiling)")
 	flag.Parse()
 
+	if *configFile != "" {
+		config, err := LoadServerConfig(*configFile)
+		if err != nil {
+			return fmt.Errorf("unable to load configuration file: %v", err)
+		}
+
+		// Options given on the command line override the configuration file.
+		explicit := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		configure := func(name string, dst *string, value string) {
+			if value != "" && !explicit[name] {
+				*dst = value
+			}
+		}
+		configure("endpoint", endPoint, config.Endpoint)
+		configure("websocket-endpoint", wsEndPoint, config.WebSocketEndpoint)
+		configure("metrics-endpoint", metricsEndPoint, config.MetricsEndpoint)
+		configure("admin-socket", adminSocket, config.AdminSocket)
+		configure("sqlite", sqlDbName, config.Database)
+		configure("coredb", coreDbName, config.CoreDatabase)
+		configure("log-file", logFile, config.LogFile)
+		configure("journal", journalFile, config.Journal)
+		configure("debug", debugFlags, config.Debug)
+		configure("init-file", initFile, config.InitFile)
+		configure("password-file", passFile, config.PasswordFile)
+		configure("save-interval", saveInterval, config.SaveInterval)
+		configure("map-dir", mapDir, config.MapDirectory)
+		if config.UndoLimit != nil && !explicit["undo-limit"] {
+			*undoLimit = *config.UndoLimit
+		}
+		if config.TLS != nil {
+			configure("tls-cert", tlsCert, config.TLS.Cert)
+			configure("tls-key", tlsKey, config.TLS.Key)
+			configure("tls-client-ca", tlsClientCA, config.TLS.ClientCA)
+			if !explicit["tls-require-client-cert"] {
+				*tlsRequireClientCert = config.TLS.RequireClientCert
+			}
+		}
+
+		a.ConfigFile = *configFile
+		a.config.data = config
+		a.config.loaded = config
+		a.config.explicit = explicit
+	}
+
 	if *debugFlags != "" {
-		a.DebugLevel, _ = NamedDebugFlags(*debugFlags)
-		a.Debugf(DebugInit, "debugging flags set to %#v%s", a.DebugLevel, DebugFlagNames(a.DebugLevel))
+		level, _ := NamedDebugFlags(*debugFlags)
+		a.setDebugLevel(level)
+		a.Debugf(DebugInit, "debugging flags set to %#v%s", level, DebugFlagNames(level))
 	}
 
 	if *logFile == "" {
@@ -424,6 +625,10 @@ func (a *Application) GetAppOptions() error {
 		}
 	}
 
+	if a.ConfigFile != "" {
+		a.Logf("read server configuration from \"%s\"", a.ConfigFile)
+	}
+
 	if *profFile != "" {
 		a.CPUProfileFile = *profFile
 	}
@@ -477,19 +682,77 @@ func (a *Application) GetAppOptions() error {
 		return fmt.Errorf("non-empty tcp [host]:port value required")
 	}
 
-	/*
-		if *saveInterval == "" {
-			a.SaveInterval = 10 * time.Minute
-			a.Logf("defaulting state save interval to 10 minutes")
-		} else {
-			d, err := time.ParseDuration(*saveInterval)
-			if err != nil {
-				return fmt.Errorf("invalid save-time interval: %v", err)
+	if *wsEndPoint != "" {
+		a.WebSocketEndpoint = *wsEndPoint
+		a.Logf("configured to accept WebSocket clients on \"%s\"", a.WebSocketEndpoint)
+	}
+
+	if *tlsCert != "" || *tlsKey != "" {
+		if *tlsCert == "" || *tlsKey == "" {
+			return fmt.Errorf("-tls-cert and -tls-key must be specified together")
+		}
+		config, err := mapper.NewServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA, *tlsRequireClientCert)
+		if err != nil {
+			return err
+		}
+		a.TLSConfig = config
+		a.Logf("accepting TLS connections with certificate \"%s\"", *tlsCert)
+		if *tlsClientCA != "" {
+			if *tlsRequireClientCert {
+				a.Logf("requiring client certificates issued by \"%s\"", *tlsClientCA)
+			} else {
+				a.Logf("accepting client certificates issued by \"%s\"", *tlsClientCA)
 			}
-			a.SaveInterval = d
-			a.Logf("saving state to disk every %v", a.SaveInterval)
 		}
-	*/
+	} else if *tlsClientCA != "" || *tlsRequireClientCert {
+		return fmt.Errorf("client certificate options require -tls-cert and -tls-key")
+	}
+
+	if *saveInterval == "" {
+		a.SaveInterval = time.Minute
+		a.Logf("defaulting state save interval to 1 minute")
+	} else {
+		d, err := time.ParseDuration(*saveInterval)
+		if err != nil {
+			return fmt.Errorf("invalid save-time interval: %v", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("invalid save-time interval: %v may not be negative", d)
+		}
+		a.SaveInterval = d
+		if d == 0 {
+			a.Log("saving game state to database only at shutdown")
+		} else {
+			a.Logf("saving game state to database every %v", a.SaveInterval)
+		}
+	}
+
+	a.ResetGameState = *resetState
+	if a.ResetGameState {
+		a.Log("discarding previously saved game state")
+	}
+
+	if *undoLimit < 0 {
+		return fmt.Errorf("invalid undo limit %d: may not be negative", *undoLimit)
+	}
+	a.UndoLimit = *undoLimit
+	if a.UndoLimit == 0 {
+		a.Log("undo history disabled")
+	} else {
+		a.Logf("remembering the last %d map %s for undo", a.UndoLimit, util.PluralizeString("change", a.UndoLimit))
+	}
+
+	if *mapDir != "" {
+		info, err := os.Stat(*mapDir)
+		if err != nil {
+			return fmt.Errorf("unable to use map directory: %v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("unable to use map directory: %s is not a directory", *mapDir)
+		}
+		a.MapDirectory = *mapDir
+		a.Logf("saving and loading map files in \"%s\"", a.MapDirectory)
+	}
 
 	if *sqlDbName == "" {
 		return fmt.Errorf("database name is required")
@@ -497,6 +760,42 @@ func (a *Application) GetAppOptions() error {
 	a.DatabaseName = *sqlDbName
 	a.Logf("using database \"%s\" to store internal state", a.DatabaseName)
 
+	if *coreDbName != "" {
+		a.CoreDatabaseName = *coreDbName
+		a.Logf("using core database \"%s\" to answer client queries", a.CoreDatabaseName)
+	}
+
+	if *journalFile != "" {
+		path, err := util.FancyFileName(*journalFile, nil)
+		if err != nil {
+			return fmt.Errorf("unable to understand journal file path \"%s\": %v", *journalFile, err)
+		}
+		if a.Journal, err = mapper.CreateJournal(path); err != nil {
+			return fmt.Errorf("unable to open journal file: %v", err)
+		}
+		a.Logf("recording session journal in \"%s\"", path)
+	}
+
+	if *metricsEndPoint != "" {
+		a.MetricsEndpoint = *metricsEndPoint
+		a.metrics = newMetricsRegistry()
+		a.Logf("reporting statistics at http://%s/metrics", a.MetricsEndpoint)
+	}
+
+	if *adminSocket != "" {
+		a.AdminSocket = *adminSocket
+		a.Logf("accepting administrative commands on \"%s\"", a.AdminSocket)
+	}
+
+	for _, spec := range campaignList {
+		if err := a.addCampaign(spec); err != nil {
+			return err
+		}
+	}
+	if err := a.checkCampaignAccess(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -508,7 +807,10 @@ func (a *Application) GetPersonalCredentials(user string) []byte {
 		a.clientAuth.lock.RUnlock()
 	}()
 	a.Debug(DebugAuth, "acquired read lock; proceeding")
-	secret, ok := a.clientAuth.personalPasswords[user]
+	if a.clientAuth.passwords == nil {
+		return nil
+	}
+	secret, ok := a.clientAuth.passwords.PersonalSecrets[user]
 	if !ok {
 		return nil
 	}
@@ -528,13 +830,13 @@ func (a *Application) newClientAuthenticator(user string) (*auth.Authenticator,
 	}()
 	a.Debug(DebugAuth, "acquired read lock; proceeding")
 
-	cauth := &auth.Authenticator{
-		Secret:   a.clientAuth.groupPassword,
-		GmSecret: a.clientAuth.gmPassword,
+	if a.clientAuth.passwords == nil {
+		return nil, fmt.Errorf("no passwords loaded from %s", a.PasswordFile)
 	}
+	cauth := a.clientAuth.passwords.ServerAuthenticator()
 
 	if user != "" {
-		personalPass, ok := a.clientAuth.personalPasswords[user]
+		personalPass, ok := a.clientAuth.passwords.PersonalSecrets[user]
 		if ok {
 			cauth.SetSecret(personalPass)
 			a.Debugf(DebugAuth, "using personal password for %s", user)
@@ -547,8 +849,19 @@ func (a *Application) newClientAuthenticator(user string) (*auth.Authenticator,
 }
 
 func (a *Application) refreshAuthenticator() error {
+	passwords, err := a.readPasswordFile()
+	if err != nil {
+		return err
+	}
+	a.installPasswords(passwords)
+	return nil
+}
+
+// installPasswords puts newly-read password data into effect, if we
+// are using a password file.
+func (a *Application) installPasswords(passwords *auth.PasswordFile) {
 	if a.PasswordFile == "" {
-		return nil
+		return
 	}
 
 	a.Debug(DebugInit, "acquiring a write lock on the password data")
@@ -558,56 +871,36 @@ func (a *Application) refreshAuthenticator() error {
 		a.clientAuth.lock.Unlock()
 	}()
 	a.Debug(DebugInit, "acquired write lock; proceeding")
+	a.clientAuth.passwords = passwords
+}
 
-	fp, err := os.Open(a.PasswordFile)
-	if err != nil {
-		a.Logf("unable to open password file \"%s\": %v", a.PasswordFile, err)
-		return err
+// readPasswordFile reads our password file without putting it into effect.
+// If we aren't using a password file, it returns nil.
+func (a *Application) readPasswordFile() (*auth.PasswordFile, error) {
+	if a.PasswordFile == "" {
+		return nil, nil
 	}
-	defer func() {
-		if err := fp.Close(); err != nil {
-			a.Logf("error closing %s: %v", a.PasswordFile, err)
-		}
-	}()
 
-	a.clientAuth.groupPassword = []byte{}
-	a.clientAuth.gmPassword = []byte{}
-	a.clientAuth.personalPasswords = make(map[string][]byte)
-
-	scanner := bufio.NewScanner(fp)
-	if scanner.Scan() {
-		// first line is the group password
-		a.clientAuth.groupPassword = scanner.Bytes()
-		a.Debug(DebugInit, "set group password")
-		if scanner.Scan() {
-			// next line, if any, is the gm-specific password
-			a.clientAuth.gmPassword = scanner.Bytes()
-			a.Debug(DebugInit, "set GM password")
-
-			// following lines are <user>:<password> for individual passwords
-			line := 3
-			for scanner.Scan() {
-				pp := strings.SplitN(scanner.Text(), ":", 2)
-				if len(pp) != 2 {
-					a.Logf("WARNING: %s, line %d: ignoring personal password: missing delimiter", a.PasswordFile, line)
-				} else {
-					a.clientAuth.personalPasswords[pp[0]] = []byte(pp[1])
-					a.Debugf(DebugInit, "set personal password for %s", pp[0])
-				}
-				line++
-			}
-		}
+	passwords, err := auth.ReadPasswordFile(a.PasswordFile)
+	if err != nil {
+		a.Logf("unable to read password file \"%s\": %v", a.PasswordFile, err)
+		return nil, err
 	}
-	if err := scanner.Err(); err != nil {
-		a.Logf("error reading %s: %v", a.PasswordFile, err)
-		return err
+	for _, w := range passwords.Warnings {
+		a.Logf("WARNING: %s, %s", a.PasswordFile, w)
 	}
-
-	return nil
+	if passwords.Hashed {
+		a.Debugf(DebugInit, "loaded hashed passwords (%d key iterations) with %d personal password(s)", passwords.KeyIterations, len(passwords.PersonalSecrets))
+	} else {
+		a.Logf("WARNING: %s holds plaintext passwords; consider converting it with \"server-passwd -migrate\"", a.PasswordFile)
+		a.Debugf(DebugInit, "loaded plaintext passwords with %d personal password(s)", len(passwords.PersonalSecrets))
+	}
+	return passwords, nil
 }
 
 func (a *Application) HandleServerMessage(payload mapper.MessagePayload, requester *mapper.ClientConnection) {
 	a.Debugf(DebugMessages, "HandleServerMessage received %T %v", payload, payload)
+	a.recordReceived(payload, requester)
 	switch p := payload.(type) {
 	case mapper.AddImageMessagePayload:
 		for _, instance := range p.Sizes {
@@ -721,8 +1014,42 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 			})
 			return
 		}
+		if a.isMuted(requester) {
+			a.Logf("refusing to accept die roll from muted client %v", requester.IdTag())
+			requester.Conn.Send(mapper.RollResult, mapper.RollResultMessagePayload{
+				ChatCommon: mapper.ChatCommon{
+					MessageID: <-a.MessageIDGenerator,
+					Sent:      time.Now(),
+				},
+				RequestID: p.RequestID,
+				Result: dice.StructuredResult{
+					InvalidRequest: true,
+					Details: dice.StructuredDescriptionSet{
+						{Type: "error", Value: "You have been muted by the server administrator."},
+					},
+				},
+			})
+			return
+		}
+
+		a.countDieRoll(requester.Auth.Username)
+		vars, err := a.QueryDiceVariables(requester.Auth.Username)
+		if err != nil {
+			a.Logf("unable to retrieve die-roll variables for %s: %v", requester.Auth.Username, err)
+		}
+		if err := requester.D.SetVariables(vars); err != nil {
+			a.Logf("unable to use die-roll variables for %s: %v", requester.Auth.Username, err)
+			vars = nil
+		}
 
-		label, results, err := requester.D.DoRoll(p.RollSpec)
+		// Each roll is made with its own random seed, which we keep in the
+		// audit log so the roll can be verified later if anyone asks.
+		tables, err := a.QueryRandomTables()
+		if err != nil {
+			a.Logf("unable to retrieve random tables: %v", err)
+		}
+		audit, err := mapper.RollAudited(p.RollSpec, vars, tables)
+		label, results := audit.Title, audit.Results
 		if err != nil {
 			// Bad request. Notify the requester
 			requester.Conn.Send(mapper.RollResult, mapper.RollResultMessagePayload{
@@ -762,8 +1089,9 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 				ToGM:       p.ToGM,
 				Sent:       time.Now(),
 			},
-			Title:     label,
-			RequestID: p.RequestID,
+			Title:      label,
+			RequestID:  p.RequestID,
+			Commitment: audit.Commitment,
 		}
 
 		if p.ToGM {
@@ -807,6 +1135,7 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 			if err := a.AddToChatHistory(receiptMessageID, mapper.RollResult, receiptPayload); err != nil {
 				a.Logf("unable to add RollResult receipt to chat history: %v", err)
 			}
+			a.recordSent(mapper.RollResult, receiptPayload)
 			for _, peer := range a.GetClients() {
 				if peer.Auth == nil || !peer.Auth.GmMode {
 					if !peer.Features.DiceColorBoxes {
@@ -820,14 +1149,27 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 			}
 		}
 
+		audit.Requester = requester.Auth.Username
+		audit.RequestID = p.RequestID
+		audit.Recipients = p.Recipients
+		audit.ToAll = p.ToAll
+		audit.ToGM = p.ToGM
+		for range results {
+			audit.MessageIDs = append(audit.MessageIDs, <-a.MessageIDGenerator)
+		}
+		if err := a.AddDieRollAudit(audit); err != nil {
+			a.Logf("unable to add die roll to audit log: %v", err)
+		}
+
 		for seq, r := range results {
-			response.MessageID = <-a.MessageIDGenerator
+			response.MessageID = audit.MessageIDs[seq]
 			response.Result = r
 			response.MoreResults = seq+1 < len(results)
 
 			if err := a.AddToChatHistory(response.MessageID, mapper.RollResult, response); err != nil {
 				a.Logf("unable to add RollResult event to chat history: %v", err)
 			}
+			a.recordSent(mapper.RollResult, response)
 
 			for _, peer := range a.GetClients() {
 				if p.ToGM {
@@ -867,6 +1209,29 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 			a.Logf("error syncing chat history (target=%d): %v", p.Target, err)
 		}
 
+	case mapper.QueryCoreDataMessagePayload:
+		if err := a.QueryCoreData(p, requester); err != nil {
+			a.Logf("error answering core data query %v: %v", p, err)
+		}
+
+	case mapper.QueryCoreIndexMessagePayload:
+		if err := a.QueryCoreIndex(p, requester); err != nil {
+			a.Logf("error answering core index query %v: %v", p, err)
+		}
+
+	case mapper.FilterCoreDataMessagePayload:
+		if requester.Auth == nil || !requester.Auth.GmMode {
+			requester.Conn.Send(mapper.Priv, mapper.PrivMessagePayload{
+				Command: p.RawMessage(),
+				Reason:  "You are not authorized to change which core data entries are visible.",
+			})
+			a.Logf("refusing to allow non-GM user to send a CORE/ message")
+			return
+		}
+		if err := a.FilterCoreData(p); err != nil {
+			a.Logf("error filtering core data %v: %v", p, err)
+		}
+
 	case mapper.DefineDicePresetsMessagePayload:
 		if requester.Auth == nil {
 			a.Logf("Unable to store die-roll preset for unauthenticated user")
@@ -981,6 +1346,54 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 			a.Logf("error sending die-roll presets after changing them: %v", err)
 		}
 
+	case mapper.DefineDiceVariablesMessagePayload:
+		if requester.Auth == nil {
+			a.Logf("Unable to store die-roll variables for unauthenticated user")
+			return
+		}
+
+		target, ok := a.diceVariableTarget(requester, p, p.For, "change the die-roll variables")
+		if !ok {
+			return
+		}
+		if err := a.StoreDiceVariables(target, p.Variables, true); err != nil {
+			a.Logf("error storing die-roll variables: %v", err)
+		}
+		if err := a.SendDiceVariables(target); err != nil {
+			a.Logf("error sending die-roll variables after changing them: %v", err)
+		}
+
+	case mapper.AddDiceVariablesMessagePayload:
+		if requester.Auth == nil {
+			a.Logf("Unable to store die-roll variables for unauthenticated user")
+			return
+		}
+
+		target, ok := a.diceVariableTarget(requester, p, p.For, "add to the die-roll variables")
+		if !ok {
+			return
+		}
+		if err := a.StoreDiceVariables(target, p.Variables, false); err != nil {
+			a.Logf("error adding to die-roll variables: %v", err)
+		}
+		if err := a.SendDiceVariables(target); err != nil {
+			a.Logf("error sending die-roll variables after changing them: %v", err)
+		}
+
+	case mapper.QueryDiceVariablesMessagePayload:
+		if requester.Auth == nil {
+			a.Logf("Unable to query die-roll variables for unauthenticated user")
+			return
+		}
+
+		target, ok := a.diceVariableTarget(requester, p, p.For, "retrieve the die-roll variables")
+		if !ok {
+			return
+		}
+		if err := a.SendDiceVariables(target); err != nil {
+			a.Logf("error sending die-roll variables: %v", err)
+		}
+
 	case mapper.EchoMessagePayload:
 		if err := requester.Conn.SendEchoWithTimestamp(mapper.Echo, p); err != nil {
 			a.Logf("Error sending ECHO: %v", err)
@@ -1083,6 +1496,48 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 			a.Logf("error filtering images with /%s/: %v", p.Filter, err)
 		}
 
+	case mapper.DefineRandomTablesMessagePayload, mapper.AddRandomTablesMessagePayload, mapper.FilterRandomTablesMessagePayload:
+		if requester.Auth == nil {
+			a.Logf("Unable to change random tables for unauthenticated user")
+			return
+		}
+
+		if !requester.Auth.GmMode {
+			a.Logf("Rejecting unauthorized DT command from user %s", requester.Auth.Username)
+			requester.Conn.Send(mapper.Priv, mapper.PrivMessagePayload{
+				Command: p.RawMessage(),
+				Reason:  "Only the GM may change the random tables",
+			})
+			return
+		}
+
+		var err error
+		switch r := p.(type) {
+		case mapper.DefineRandomTablesMessagePayload:
+			err = a.StoreRandomTables(r.Tables, true)
+		case mapper.AddRandomTablesMessagePayload:
+			err = a.StoreRandomTables(r.Tables, false)
+		case mapper.FilterRandomTablesMessagePayload:
+			err = a.FilterRandomTables(r)
+		}
+		if err != nil {
+			a.Logf("error changing random tables: %v", err)
+			return
+		}
+		if err := a.SendRandomTables(nil); err != nil {
+			a.Logf("error sending random tables after changing them: %v", err)
+		}
+
+	case mapper.QueryRandomTablesMessagePayload:
+		if requester.Auth == nil {
+			a.Logf("Unable to query random tables for unauthenticated user")
+			return
+		}
+
+		if err := a.SendRandomTables(requester); err != nil {
+			a.Logf("error sending random tables: %v", err)
+		}
+
 	case mapper.QueryDicePresetsMessagePayload:
 		if requester.Auth == nil {
 			a.Logf("Unable to query die-roll preset for unauthenticated user")
@@ -1135,6 +1590,17 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 			})
 			return
 		}
+		if a.isMuted(requester) {
+			a.Logf("refusing to pass on chat message from muted client %v", requester.IdTag())
+			_ = requester.Conn.Send(mapper.ChatMessage, mapper.ChatMessageMessagePayload{
+				ChatCommon: mapper.ChatCommon{
+					MessageID: <-a.MessageIDGenerator,
+					Sent:      time.Now(),
+				},
+				Text: "You have been muted by the server administrator.",
+			})
+			return
+		}
 
 		p.Sender = requester.Auth.Username
 		p.MessageID = <-a.MessageIDGenerator
@@ -1143,6 +1609,7 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 		if err := a.AddToChatHistory(p.MessageID, mapper.ChatMessage, p); err != nil {
 			a.Logf("unable to add ChatMessage event to chat history: %v", err)
 		}
+		a.recordSent(mapper.ChatMessage, p)
 
 		for _, peer := range a.GetClients() {
 			if p.ToGM {
@@ -1228,6 +1695,15 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 		a.SendToAllExcept(requester, payload.MessageType(), payload)
 		a.UpdateGameState(&payload)
 
+	case mapper.UndoMessagePayload:
+		a.undoMapChanges(requester, p, p.Count, false)
+
+	case mapper.RedoMessagePayload:
+		a.undoMapChanges(requester, p, p.Count, true)
+
+	case mapper.SaveMapMessagePayload, mapper.QueryMapsMessagePayload, mapper.LoadMapMessagePayload:
+		a.handleMapFileRequest(requester, payload)
+
 	case mapper.SyncMessagePayload:
 		a.SendGameState(requester)
 
@@ -1236,22 +1712,95 @@ func (a *Application) HandleServerMessage(payload mapper.MessagePayload, request
 	}
 }
 
+// undoMapChanges carries out a GM's request to undo (or redo) the last count
+// changes to the map, sending the resulting corrections to all clients.
+func (a *Application) undoMapChanges(requester *mapper.ClientConnection, p mapper.MessagePayload, count int, redo bool) {
+	if requester == nil || requester.Auth == nil || !requester.Auth.GmMode {
+		a.Logf("refusing to execute privileged command %v for non-GM user", p.MessageType())
+		requester.Conn.Send(mapper.Priv, mapper.PrivMessagePayload{
+			Command: p.RawMessage(),
+			Reason:  "You are not the GM.",
+		})
+		return
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	action := "undo"
+	if redo {
+		action = "redo"
+	}
+	changes, messages := a.UndoGameState(count, redo)
+	if changes == 0 {
+		requester.Conn.Send(mapper.Failed, mapper.FailedMessagePayload{
+			IsError: true,
+			Command: p.RawMessage(),
+			Reason:  fmt.Sprintf("There are no map changes to %s.", action),
+		})
+		return
+	}
+	a.Logf("%s requested %s of %d map %s", requester.Auth.Username, action, changes, util.PluralizeString("change", changes))
+	for _, m := range messages {
+		a.SendToAll(gameStateMessageType(m), m)
+	}
+}
+
+// diceVariableTarget determines whose die-roll variables a request
+// from an authenticated requester applies to. If forUser names someone else,
+// the requester must be the GM or one of that user's die-roll preset delegates.
+// If they are not, a PRIV response is sent back to them and ok is false.
+func (a *Application) diceVariableTarget(requester *mapper.ClientConnection, p mapper.MessagePayload, forUser, action string) (target string, ok bool) {
+	target = requester.Auth.Username
+	if forUser == "" || forUser == target {
+		return target, true
+	}
+	if requester.Auth.GmMode {
+		a.Debugf(DebugIO, "GM requests to %s for %s", action, forUser)
+		return forUser, true
+	}
+
+	delegates, err := a.QueryPresetDelegates(forUser)
+	if err != nil {
+		a.Logf("error getting delegate list for %s: %v", forUser, err)
+		requester.Conn.Send(mapper.Priv, mapper.PrivMessagePayload{
+			Command: p.RawMessage(),
+			Reason:  "we were unable to verify if you are a delegate for the target user",
+		})
+		return "", false
+	}
+	if slices.Contains(delegates, requester.Auth.Username) {
+		a.Debugf(DebugIO, "Delegate %s requests to %s for %s", requester.Auth.Username, action, forUser)
+		return forUser, true
+	}
+	a.Logf("refusing to execute privileged command %v %v for non-GM, non-delegate user %s", p.MessageType(), p, requester.Auth.Username)
+	requester.Conn.Send(mapper.Priv, mapper.PrivMessagePayload{
+		Command: p.RawMessage(),
+		Reason:  fmt.Sprintf("You are not authorized to %s for that user", action),
+	})
+	return "", false
+}
+
+// describePeer reports what we know about a connected client.
+func describePeer(peer *mapper.ClientConnection) mapper.Peer {
+	thisPeer := mapper.Peer{
+		Addr:     peer.Address,
+		LastPolo: time.Since(peer.LastPoloTime).Seconds(),
+	}
+	if peer.Auth != nil {
+		thisPeer.User = peer.Auth.Username
+		thisPeer.Client = peer.Auth.Client
+		thisPeer.IsAuthenticated = peer.Auth.Username != ""
+	}
+	return thisPeer
+}
+
 func (a *Application) SendPeerListToAll() {
 	allClients := a.GetClients()
 	var peers mapper.UpdatePeerListMessagePayload
 
 	for _, peer := range allClients {
-		thisPeer := mapper.Peer{
-			Addr:     peer.Address,
-			LastPolo: time.Since(peer.LastPoloTime).Seconds(),
-			IsMe:     false,
-		}
-		if peer.Auth != nil {
-			thisPeer.User = peer.Auth.Username
-			thisPeer.Client = peer.Auth.Client
-			thisPeer.IsAuthenticated = peer.Auth.Username != ""
-		}
-		peers.PeerList = append(peers.PeerList, thisPeer)
+		peers.PeerList = append(peers.PeerList, describePeer(peer))
 	}
 
 	for i, peer := range allClients {
@@ -1266,16 +1815,8 @@ func (a *Application) SendPeerListToAll() {
 func (a *Application) SendPeerListTo(requester *mapper.ClientConnection) {
 	var peers mapper.UpdatePeerListMessagePayload
 	for _, peer := range a.GetClients() {
-		thisPeer := mapper.Peer{
-			Addr:     peer.Address,
-			LastPolo: time.Since(peer.LastPoloTime).Seconds(),
-			IsMe:     peer == requester,
-		}
-		if peer.Auth != nil {
-			thisPeer.User = peer.Auth.Username
-			thisPeer.Client = peer.Auth.Client
-			thisPeer.IsAuthenticated = peer.Auth.Username != ""
-		}
+		thisPeer := describePeer(peer)
+		thisPeer.IsMe = peer == requester
 		peers.PeerList = append(peers.PeerList, thisPeer)
 	}
 	if err := requester.Conn.Send(mapper.UpdatePeerList, peers); err != nil {
@@ -1289,6 +1830,7 @@ func (a *Application) SendToAllExcept(c *mapper.ClientConnection, cmd mapper.Ser
 	} else {
 		a.Debugf(DebugIO|DebugMessages, "sending %v %v to all clients except %v", cmd, data, c.IdTag())
 	}
+	a.recordSent(cmd, data)
 	var reportedError error
 
 	for _, peer := range a.GetClients() {
@@ -1308,6 +1850,34 @@ func (a *Application) SendToAll(cmd mapper.ServerMessage, data any) error {
 	return a.SendToAllExcept(nil, cmd, data)
 }
 
+// recordReceived adds a message received from a client to the session
+// journal, if we're keeping one.
+func (a *Application) recordReceived(payload mapper.MessagePayload, requester *mapper.ClientConnection) {
+	if a.Journal == nil || payload.MessageType() == mapper.Polo {
+		return
+	}
+	var user string
+	if requester != nil && requester.Auth != nil {
+		user = requester.Auth.Username
+	}
+	if err := a.Journal.Record(mapper.JournalReceived, user, payload.RawMessage()); err != nil {
+		a.Logf("unable to record %v in journal: %v", payload.MessageType(), err)
+	}
+}
+
+// recordSent adds a message sent to all clients (or, for chat messages
+// and die rolls, to their intended recipients) to the session journal,
+// if we're keeping one. (The periodic MARCO pings aren't interesting to
+// anyone reading the journal, so we leave those out.)
+func (a *Application) recordSent(cmd mapper.ServerMessage, data any) {
+	if a.Journal == nil || cmd == mapper.Marco {
+		return
+	}
+	if err := a.Journal.RecordMessage(mapper.JournalSent, "", cmd, data); err != nil {
+		a.Logf("unable to record %v in journal: %v", cmd, err)
+	}
+}
+
 // NewApplication creates and initializes a new Application value.
 func NewApplication() *Application {
 	app := Application{
@@ -1315,10 +1885,14 @@ func NewApplication() *Application {
 		MessageIDGenerator: make(chan int),
 		MessageIDReset:     make(chan int),
 	}
-	app.clientPreamble.reload = make(chan byte, 1)
+	app.clientPreamble.reload = make(chan *clientPreambleData, 1)
 	app.clientPreamble.fetch = make(chan *mapper.ClientPreamble, 1)
 	app.gameState.sync = make(chan *mapper.ClientConnection, 1)
 	app.gameState.update = make(chan *mapper.MessagePayload, 1)
+	app.gameState.checkpoint = make(chan chan error)
+	app.gameState.undo = make(chan undoRequest)
+	app.gameState.dump = make(chan chan gameStateDump)
+	app.gameState.together = make(chan gameStateChanges)
 	app.clientData.add = make(chan *mapper.ClientConnection, 1)
 	app.clientData.remove = make(chan *mapper.ClientConnection, 1)
 	app.clientData.fetch = make(chan []*mapper.ClientConnection, 1)
@@ -1326,12 +1900,23 @@ func NewApplication() *Application {
 	return &app
 }
 
-// managePreambleData centralizes access to the common preamble data
-// in a single goroutine, providing goroutine-safe access to it via
-// channels.
-func (a *Application) managePreambleData() {
-	a.Log("preamble data manager started")
-	defer a.Log("preamble data manager stopped")
+// clientPreambleData holds everything we take from the client initialization
+// file, along with the configuration file settings which stand in for it.
+// It is all loaded at once by loadClientPreamble and put into effect at once
+// by the preamble data manager.
+type clientPreambleData struct {
+	preamble       mapper.ClientPreamble
+	allowedClients []mapper.PackageUpdate
+	qosLimits      []QoSLimitsDescription
+}
+
+// loadClientPreamble reads the client initialization file (if any), adding
+// its commands to those implied by the given configuration (which may be nil).
+// This does not change anything in the Application itself. If there is a problem
+// with either one, it returns an error along with whatever it loaded before
+// finding the problem.
+func (a *Application) loadClientPreamble(config *ServerConfig) (*clientPreambleData, error) {
+	var d clientPreambleData
 
 	commitInitCommand := func(cmd string, src strings.Builder, dst *[]string) error {
 		var b []byte
@@ -1522,54 +2107,33 @@ func (a *Application) managePreambleData() {
 
 		case "QOS":
 			var data QoSLimitsDescription
-			if err = json.Unmarshal(s, &data); err == nil {
-				a.QoSLimits.QueryImage.Count = data.QueryImage.Count
-				if data.QueryImage.WindowString != "" {
-					if a.QoSLimits.QueryImage.window, err = time.ParseDuration(data.QueryImage.WindowString); err != nil {
-						a.Debugf(DebugInit, "ERROR in QOS QueryImage Window duration: %v", err)
-						return err
-					}
-				}
-				a.QoSLimits.MessageRate.Count = data.MessageRate.Count
-				if data.MessageRate.WindowString != "" {
-					if a.QoSLimits.MessageRate.window, err = time.ParseDuration(data.MessageRate.WindowString); err != nil {
-						a.Debugf(DebugInit, "ERROR in QOS MessageRate Window duration: %v", err)
-						return err
-					}
-				}
-				if data.Log.WindowString != "" {
-					if a.QoSLimits.Log.window, err = time.ParseDuration(data.Log.WindowString); err != nil {
-						a.Debugf(DebugInit, "ERROR in QOS Log Window duration: %v", err)
-						return err
+			if err = json.Unmarshal(s, &data); err != nil {
+				return err
+			}
+			for _, window := range []string{data.QueryImage.WindowString, data.MessageRate.WindowString, data.Log.WindowString} {
+				if window != "" {
+					if _, err = time.ParseDuration(window); err != nil {
+						return fmt.Errorf("QOS window duration: %v", err)
 					}
 				}
 			}
-			a.Logf("Set QoS Limits img=%d/%s rate=%d/%s log=%s",
-				a.QoSLimits.QueryImage.Count,
-				a.QoSLimits.QueryImage.window.String(),
-				a.QoSLimits.MessageRate.Count,
-				a.QoSLimits.MessageRate.window.String(),
-				a.QoSLimits.Log.window.String(),
-			)
-
+			d.qosLimits = append(d.qosLimits, data)
 			return nil
 
 		case "UPDATES":
 			var data mapper.UpdateVersionsMessagePayload
 			if err = json.Unmarshal(s, &data); err == nil {
-				a.AllowedClients = data.Packages
-				if a.AllowedClients != nil {
-					for i, aClient := range a.AllowedClients {
+				d.allowedClients = data.Packages
+				if d.allowedClients != nil {
+					for i, aClient := range d.allowedClients {
 						if aClient.VersionPattern != "" {
-							a.AllowedClients[i].VersionRegex, err = regexp.Compile(aClient.VersionPattern)
+							d.allowedClients[i].VersionRegex, err = regexp.Compile(aClient.VersionPattern)
 							if err != nil {
 								a.Debugf(DebugInit, "ERROR in %s VersionPattern \"%s\": %v; will not limit this client", aClient.Name, aClient.VersionPattern, err)
-								a.AllowedClients[i].VersionRegex = nil
-							}
-							nSubs := len(a.AllowedClients[i].VersionRegex.SubexpNames())
-							if nSubs != 2 {
+								d.allowedClients[i].VersionRegex = nil
+							} else if nSubs := len(d.allowedClients[i].VersionRegex.SubexpNames()); nSubs != 2 {
 								a.Debugf(DebugInit, "ERROR in %s VersionPattern \"%s\": must have exactly 1 capturing group; this expression has %d; will not limit this client", aClient.Name, aClient.VersionPattern, nSubs-1)
-								a.AllowedClients[i].VersionRegex = nil
+								d.allowedClients[i].VersionRegex = nil
 							}
 						}
 						if aClient.MinimumVersion != "" && aClient.VersionPattern == "" {
@@ -1589,7 +2153,7 @@ func (a *Application) managePreambleData() {
 				b, err = json.Marshal(mapper.UpdateVersionsMessagePayload{
 					Packages: cpkg,
 				})
-				a.Debugf(DebugInit, "allowed client list is now %v", a.AllowedClients)
+				a.Debugf(DebugInit, "allowed client list will be %v", d.allowedClients)
 			}
 
 		case "REDIRECT":
@@ -1614,104 +2178,128 @@ func (a *Application) managePreambleData() {
 		return err
 	}
 
-	updateClientPreamble := func() {
-		if a.InitFile == "" {
-			return
-		}
-
-		f, err := os.Open(a.InitFile)
+	if config != nil {
+		commands, err := config.preambleCommands()
 		if err != nil {
-			a.Logf("error opening initial command file %v: %v", a.InitFile, err)
-			return
+			return &d, fmt.Errorf("error in configuration file %s: %v", a.ConfigFile, err)
 		}
-		defer f.Close()
-
-		recordPattern := regexp.MustCompile("^(\\w+)\\s+({.*)")
-		continuationPattern := regexp.MustCompile("^\\s+")
-		endOfRecordPattern := regexp.MustCompile("^}")
-		commandPattern := regexp.MustCompile("^(\\w+)\\s*$")
+		for _, command := range commands {
+			var data strings.Builder
+			data.WriteString(command[1])
+			if err := commitInitCommand(command[0], data, &d.preamble.Preamble); err != nil {
+				return &d, fmt.Errorf("error in configuration file %s: %v", a.ConfigFile, err)
+			}
+		}
+	}
 
-		a.clientPreamble.data.Preamble = nil
-		a.clientPreamble.data.PostAuth = nil
-		a.clientPreamble.data.PostReady = nil
-		a.clientPreamble.data.SyncData = false
-		currentPreamble := &a.clientPreamble.data.Preamble
+	if a.InitFile == "" {
+		return &d, nil
+	}
 
-		scanner := bufio.NewScanner(f)
-	outerScan:
-		for scanner.Scan() {
-		rescan:
-			if strings.TrimSpace(scanner.Text()) == "" {
-				continue
-			}
-			if strings.HasPrefix(scanner.Text(), "//") {
-				*currentPreamble = append(*currentPreamble, scanner.Text())
-				continue
+	f, err := os.Open(a.InitFile)
+	if err != nil {
+		return &d, fmt.Errorf("error opening initial command file %v: %v", a.InitFile, err)
+	}
+	defer f.Close()
+
+	recordPattern := regexp.MustCompile("^(\\w+)\\s+({.*)")
+	continuationPattern := regexp.MustCompile("^\\s+")
+	endOfRecordPattern := regexp.MustCompile("^}")
+	commandPattern := regexp.MustCompile("^(\\w+)\\s*$")
+
+	currentPreamble := &d.preamble.Preamble
+
+	scanner := bufio.NewScanner(f)
+outerScan:
+	for scanner.Scan() {
+	rescan:
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		if strings.HasPrefix(scanner.Text(), "//") {
+			*currentPreamble = append(*currentPreamble, scanner.Text())
+			continue
+		}
+		if f := commandPattern.FindStringSubmatch(scanner.Text()); f != nil {
+			// dataless command f[1]
+			switch f[1] {
+			case "AUTH":
+				currentPreamble = &d.preamble.PostAuth
+			case "READY":
+				currentPreamble = &d.preamble.PostReady
+			case "SYNC":
+				d.preamble.SyncData = true
+			default:
+				return &d, fmt.Errorf("invalid command \"%v\" in init file %s", scanner.Text(), a.InitFile)
 			}
-			if f := commandPattern.FindStringSubmatch(scanner.Text()); f != nil {
-				// dataless command f[1]
-				switch f[1] {
-				case "AUTH":
-					currentPreamble = &a.clientPreamble.data.PostAuth
-				case "READY":
-					currentPreamble = &a.clientPreamble.data.PostReady
-				case "SYNC":
-					a.clientPreamble.data.SyncData = true
-				default:
-					a.Logf("invalid command \"%v\" in init file %s", scanner.Text(), a.InitFile)
-					return
-				}
-			} else if f := recordPattern.FindStringSubmatch(scanner.Text()); f != nil {
-				// start of record type f[1] with start of JSON string f[2]
-				// collect rest of string
-				var dataPacket strings.Builder
-				dataPacket.WriteString(f[2])
-
-				for scanner.Scan() {
-					if continuationPattern.MatchString(scanner.Text()) {
+		} else if f := recordPattern.FindStringSubmatch(scanner.Text()); f != nil {
+			// start of record type f[1] with start of JSON string f[2]
+			// collect rest of string
+			var dataPacket strings.Builder
+			dataPacket.WriteString(f[2])
+
+			for scanner.Scan() {
+				if continuationPattern.MatchString(scanner.Text()) {
+					dataPacket.WriteString(scanner.Text())
+				} else {
+					if endOfRecordPattern.MatchString(scanner.Text()) {
 						dataPacket.WriteString(scanner.Text())
+					}
+					if err := commitInitCommand(f[1], dataPacket, currentPreamble); err != nil {
+						return &d, fmt.Errorf("error in initial command file %s: %v", a.InitFile, err)
+					}
+					if !endOfRecordPattern.MatchString(scanner.Text()) {
+						// We already read into next record
+						goto rescan
 					} else {
-						if endOfRecordPattern.MatchString(scanner.Text()) {
-							dataPacket.WriteString(scanner.Text())
-						}
-						if err := commitInitCommand(f[1], dataPacket, currentPreamble); err != nil {
-							a.Logf("error in initial command file: %v", err)
-							return
-						}
-						if !endOfRecordPattern.MatchString(scanner.Text()) {
-							// We already read into next record
-							goto rescan
-						} else {
-							continue outerScan
-						}
+						continue outerScan
 					}
 				}
-				// We reached EOF while scanning with a command in progress
-				if err := commitInitCommand(f[1], dataPacket, currentPreamble); err != nil {
-					a.Logf("error in initial command file: %v", err)
-					return
-				}
-				break
 			}
+			// We reached EOF while scanning with a command in progress
+			if err := commitInitCommand(f[1], dataPacket, currentPreamble); err != nil {
+				return &d, fmt.Errorf("error in initial command file %s: %v", a.InitFile, err)
+			}
+			break
 		}
+	}
 
-		if err := scanner.Err(); err != nil {
-			a.Logf("error in initial command file: %v", err)
-			return
+	if err := scanner.Err(); err != nil {
+		return &d, fmt.Errorf("error in initial command file %s: %v", a.InitFile, err)
+	}
+
+	if (a.currentDebugLevel() & DebugInit) != 0 {
+		a.Debugf(DebugInit, "client initial commands from %v", a.InitFile)
+		a.Debugf(DebugInit, "client sync: %v", d.preamble.SyncData)
+
+		for i, p := range d.preamble.Preamble {
+			a.Debugf(DebugInit, "client preamble #%d: %s", i, p)
+		}
+		for i, p := range d.preamble.PostAuth {
+			a.Debugf(DebugInit, "client post-auth #%d: %s", i, p)
+		}
+		for i, p := range d.preamble.PostReady {
+			a.Debugf(DebugInit, "client post-ready #%d: %s", i, p)
 		}
+	}
+	return &d, nil
+}
 
-		if (a.DebugLevel & DebugInit) != 0 {
-			a.Debugf(DebugInit, "client initial commands from %v", a.InitFile)
-			a.Debugf(DebugInit, "client sync: %v", a.clientPreamble.data.SyncData)
+// managePreambleData centralizes access to the common preamble data
+// in a single goroutine, providing goroutine-safe access to it via
+// channels. New preamble data (which must already have been loaded by
+// loadClientPreamble) is sent to it via the reload channel.
+func (a *Application) managePreambleData() {
+	a.Log("preamble data manager started")
+	defer a.Log("preamble data manager stopped")
 
-			for i, p := range a.clientPreamble.data.Preamble {
-				a.Debugf(DebugInit, "client preamble #%d: %s", i, p)
-			}
-			for i, p := range a.clientPreamble.data.PostAuth {
-				a.Debugf(DebugInit, "client post-auth #%d: %s", i, p)
-			}
-			for i, p := range a.clientPreamble.data.PostReady {
-				a.Debugf(DebugInit, "client post-ready #%d: %s", i, p)
+	installClientPreamble := func(d *clientPreambleData) {
+		a.clientPreamble.data = d.preamble
+		a.AllowedClients = d.allowedClients
+		a.Debugf(DebugInit, "allowed client list is now %v", a.AllowedClients)
+		for _, limits := range d.qosLimits {
+			if err := a.setQoSLimits(limits); err != nil {
+				a.Logf("unable to set QoS limits: %v", err)
 			}
 		}
 	}
@@ -1732,15 +2320,19 @@ func (a *Application) managePreambleData() {
 		}
 	}
 
-	updateClientPreamble()
+	initial, err := a.loadClientPreamble(a.currentConfig())
+	if err != nil {
+		a.Logf("%v", err)
+	}
+	installClientPreamble(initial)
 	nextValue := copyCurrentPreambleData()
 	a.Debugf(DebugInit, "staged preamble data %p, pre=%p, pa=%p, pr=%p", nextValue, &nextValue.Preamble, &nextValue.PostAuth, &nextValue.PostReady)
 
 	for {
 		select {
-		case <-a.clientPreamble.reload:
-			a.Debug(DebugInit, "reloading client preamble data from file")
-			updateClientPreamble()
+		case d := <-a.clientPreamble.reload:
+			a.Debug(DebugInit, "installing reloaded client preamble data")
+			installClientPreamble(d)
 
 			select {
 			case <-a.clientPreamble.fetch:
@@ -1782,9 +2374,138 @@ func (a *Application) manageGameState() {
 	//   usf:<name>			unload remote file
 	eventHistory := make(map[string]*mapper.MessagePayload)
 
+	// undo tracks the recent changes to the map so the GM can reverse them.
+	undo := undoHistory{limit: a.UndoLimit}
+
 	a.Log("game state manager started")
 	defer a.Log("game state manager stopped")
 
+	// restoreState reloads the game state from the last checkpoint saved
+	// in the database. The keys are the same as for eventHistory, plus
+	// these which hold the rest of the state:
+	//   combat				combat mode
+	//   toolbar			toolbar visibility
+	//   view				map view position and grid
+	//   turn				current turn
+	//   initiative			initiative list
+	//   clock				game clock
+	//   dsm:<condition>	status marker definition
+	restoreState := func() {
+		saved, err := a.QueryGameState()
+		if err != nil {
+			a.Logf("unable to restore saved game state: %v", err)
+			return
+		}
+		for k, e := range saved {
+			switch p := e.(type) {
+			case mapper.CombatModeMessagePayload:
+				isInCombatMode = p.Enabled
+			case mapper.ToolbarMessagePayload:
+				toolbarHidden = !p.Enabled
+			case mapper.AdjustViewMessagePayload:
+				viewx = p.XView
+				viewy = p.YView
+				viewg = p.Grid
+			case mapper.UpdateTurnMessagePayload:
+				currentTurn = &p
+			case mapper.UpdateInitiativeMessagePayload:
+				currentInitiativeList = &p
+			case mapper.UpdateClockMessagePayload:
+				currentTime = &p
+			case mapper.UpdateStatusMarkerMessagePayload:
+				newStatusMarkers[p.Condition] = p
+			default:
+				event := e
+				eventHistory[k] = &event
+			}
+		}
+		a.Logf("restored %d saved game state %s", len(saved), util.PluralizeString("record", len(saved)))
+	}
+
+	// snapshotState represents the current game state as a set of protocol
+	// messages, as they are saved in the database (see restoreState).
+	snapshotState := func() (map[string]string, error) {
+		state := make(map[string]string)
+		addRecord := func(key string, cmd mapper.ServerMessage, data any) error {
+			line, err := mapper.FormatMessage(cmd, data)
+			if err != nil {
+				return fmt.Errorf("unable to save %s: %v", key, err)
+			}
+			state[key] = line
+			return nil
+		}
+
+		if err := addRecord("combat", mapper.CombatMode, mapper.CombatModeMessagePayload{Enabled: isInCombatMode}); err != nil {
+			return nil, err
+		}
+		if err := addRecord("toolbar", mapper.Toolbar, mapper.ToolbarMessagePayload{Enabled: !toolbarHidden}); err != nil {
+			return nil, err
+		}
+		if err := addRecord("view", mapper.AdjustView, mapper.AdjustViewMessagePayload{Grid: viewg, XView: viewx, YView: viewy}); err != nil {
+			return nil, err
+		}
+		if currentTurn != nil {
+			if err := addRecord("turn", mapper.UpdateTurn, *currentTurn); err != nil {
+				return nil, err
+			}
+		}
+		if currentInitiativeList != nil {
+			if err := addRecord("initiative", mapper.UpdateInitiative, *currentInitiativeList); err != nil {
+				return nil, err
+			}
+		}
+		if currentTime != nil {
+			if err := addRecord("clock", mapper.UpdateClock, *currentTime); err != nil {
+				return nil, err
+			}
+		}
+		for condition, marker := range newStatusMarkers {
+			if err := addRecord("dsm:"+condition, mapper.UpdateStatusMarker, marker); err != nil {
+				return nil, err
+			}
+		}
+		for k, e := range eventHistory {
+			if err := addRecord(k, gameStateMessageType(*e), *e); err != nil {
+				return nil, err
+			}
+		}
+		return state, nil
+	}
+
+	// saveState writes a checkpoint of the current game state to the database.
+	saveState := func() error {
+		if InstrumentCode {
+			if a.NrApp != nil {
+				defer a.NrApp.StartTransaction("checkpoint").End()
+			}
+		}
+		state, err := snapshotState()
+		if err != nil {
+			return err
+		}
+		return a.StoreGameState(state)
+	}
+
+	if a.ResetGameState {
+		if err := a.StoreGameState(nil); err != nil {
+			a.Logf("unable to clear saved game state: %v", err)
+		}
+	} else {
+		restoreState()
+	}
+
+	// If we're not saving periodically, we'll still need a ticker
+	// value for the select statement below, so we'll make a stopped one.
+	var stateChanged bool
+	var checkpointTicker *time.Ticker
+	if a.SaveInterval > 0 {
+		checkpointTicker = time.NewTicker(a.SaveInterval)
+	} else {
+		checkpointTicker = time.NewTicker(100 * time.Second)
+		checkpointTicker.Stop()
+	}
+	defer checkpointTicker.Stop()
+
 	recordElement := func(id string, e *mapper.MessagePayload) {
 		if InstrumentCode {
 			if a.NrApp != nil {
@@ -1799,276 +2520,414 @@ func (a *Application) manageGameState() {
 		eventHistory["new:"+id] = e
 	}
 
-	for {
-		select {
-		case event := <-a.gameState.update:
-			if event == nil {
-				a.Log("received nil event to update game state")
-				continue
-			}
-			a.Debugf(DebugState, "updating game state from event %v", *event)
-			switch p := (*event).(type) {
-			case mapper.AddObjAttributesMessagePayload:
-				func() {
-					if InstrumentCode {
-						if a.NrApp != nil {
-							defer a.NrApp.StartTransaction("track-add-obj-attributes").End()
-						}
+	// applyEvent updates the game state to reflect the change described by event.
+	applyEvent := func(event *mapper.MessagePayload) {
+		switch p := (*event).(type) {
+		case mapper.AddObjAttributesMessagePayload:
+			func() {
+				if InstrumentCode {
+					if a.NrApp != nil {
+						defer a.NrApp.StartTransaction("track-add-obj-attributes").End()
 					}
+				}
 
-					// TODO this could be more efficient
-					if o, ok := eventHistory["del:"+p.ObjID+":"+p.AttrName]; ok {
-						obj, valid := (*o).(mapper.RemoveObjAttributesMessagePayload)
-						if !valid {
-							a.Logf("value of eventHistory[del:%s:%s] is of type %T (removed)", p.ObjID, p.AttrName, o)
-							delete(eventHistory, "del:"+p.ObjID+":"+p.AttrName)
-						} else {
-							for _, addedValue := range p.Values {
-								if pos := slices.Index(obj.Values, addedValue); pos >= 0 {
-									// we previously tracked deletion of this, so remove from the delete list now
-									slices.Delete(obj.Values, pos, pos+1)
-								}
+				// TODO this could be more efficient
+				if o, ok := eventHistory["del:"+p.ObjID+":"+p.AttrName]; ok {
+					obj, valid := (*o).(mapper.RemoveObjAttributesMessagePayload)
+					if !valid {
+						a.Logf("value of eventHistory[del:%s:%s] is of type %T (removed)", p.ObjID, p.AttrName, o)
+						delete(eventHistory, "del:"+p.ObjID+":"+p.AttrName)
+					} else {
+						values := slices.Clone(obj.Values)
+						for _, addedValue := range p.Values {
+							if pos := slices.Index(values, addedValue); pos >= 0 {
+								// we previously tracked deletion of this, so remove from the delete list now
+								values = slices.Delete(values, pos, pos+1)
 							}
 						}
+						obj.Values = values
+						var pl mapper.MessagePayload = obj
+						eventHistory["del:"+p.ObjID+":"+p.AttrName] = &pl
 					}
-					for _, addedValue := range p.Values {
-						if o, ok := eventHistory["add:"+p.ObjID+":"+p.AttrName]; ok {
-							obj, valid := (*o).(mapper.AddObjAttributesMessagePayload)
-							if !valid {
-								a.Logf("value of eventHistory[add:%s:%s] is of type %T (removed)", p.ObjID, p.AttrName, o)
-								delete(eventHistory, "add:"+p.ObjID+":"+p.AttrName)
-							} else {
-								if slices.Contains(obj.Values, addedValue) {
-									// we already have a note to add this value, do nothing
-								} else {
-									// add this to our existing add: record
-									obj.Values = append(obj.Values, addedValue)
-								}
-							}
+				}
+				for _, addedValue := range p.Values {
+					if o, ok := eventHistory["add:"+p.ObjID+":"+p.AttrName]; ok {
+						obj, valid := (*o).(mapper.AddObjAttributesMessagePayload)
+						if !valid {
+							a.Logf("value of eventHistory[add:%s:%s] is of type %T (removed)", p.ObjID, p.AttrName, o)
+							delete(eventHistory, "add:"+p.ObjID+":"+p.AttrName)
 						} else {
-							// we need a new add: record for this attribute
-							var pl mapper.MessagePayload
-							pl = mapper.AddObjAttributesMessagePayload{
-								ObjID:    p.ObjID,
-								AttrName: p.AttrName,
-								Values: []string{
-									addedValue,
-								},
+							if slices.Contains(obj.Values, addedValue) {
+								// we already have a note to add this value, do nothing
+							} else {
+								// add this to our existing add: record
+								obj.Values = append(slices.Clone(obj.Values), addedValue)
+								var pl mapper.MessagePayload = obj
+								eventHistory["add:"+p.ObjID+":"+p.AttrName] = &pl
 							}
-							eventHistory["add:"+p.ObjID+":"+p.AttrName] = &pl
 						}
+					} else {
+						// we need a new add: record for this attribute
+						var pl mapper.MessagePayload
+						pl = mapper.AddObjAttributesMessagePayload{
+							ObjID:    p.ObjID,
+							AttrName: p.AttrName,
+							Values: []string{
+								addedValue,
+							},
+						}
+						eventHistory["add:"+p.ObjID+":"+p.AttrName] = &pl
 					}
-				}()
+				}
+			}()
 
-			case mapper.AdjustViewMessagePayload:
-				viewx = p.XView
-				viewy = p.YView
-				viewg = p.Grid
-			case mapper.ClearMessagePayload:
-				func() {
-					if InstrumentCode {
-						if a.NrApp != nil {
-							defer a.NrApp.StartTransaction("track-clear").End()
-						}
+		case mapper.AdjustViewMessagePayload:
+			viewx = p.XView
+			viewy = p.YView
+			viewg = p.Grid
+		case mapper.ClearMessagePayload:
+			func() {
+				if InstrumentCode {
+					if a.NrApp != nil {
+						defer a.NrApp.StartTransaction("track-clear").End()
 					}
-					switch p.ObjID {
-					case "*":
-						viewx = 0.0
-						viewy = 0.0
-						viewg = ""
-						eventHistory = make(map[string]*mapper.MessagePayload)
-
-					case "E*":
-						for k, v := range eventHistory {
-							if !strings.HasPrefix(k, "new:") {
-								delete(eventHistory, k)
-							} else if _, isCreature := (*v).(mapper.PlaceSomeoneMessagePayload); !isCreature {
-								delete(eventHistory, k)
-							}
+				}
+				switch p.ObjID {
+				case "*":
+					viewx = 0.0
+					viewy = 0.0
+					viewg = ""
+					eventHistory = make(map[string]*mapper.MessagePayload)
+
+				case "E*":
+					for k, v := range eventHistory {
+						if !strings.HasPrefix(k, "new:") {
+							delete(eventHistory, k)
+						} else if _, isCreature := (*v).(mapper.PlaceSomeoneMessagePayload); !isCreature {
+							delete(eventHistory, k)
 						}
+					}
 
-					case "M*":
-						for k, v := range eventHistory {
-							if strings.HasPrefix(k, "new:") {
-								if creature, ok := (*v).(mapper.PlaceSomeoneMessagePayload); ok {
-									if creature.CreatureType != 2 {
-										delete(eventHistory, k)
-									}
+				case "M*":
+					for k, v := range eventHistory {
+						if strings.HasPrefix(k, "new:") {
+							if creature, ok := (*v).(mapper.PlaceSomeoneMessagePayload); ok {
+								if creature.CreatureType != 2 {
+									delete(eventHistory, k)
 								}
 							}
 						}
+					}
 
-					case "P*":
-						for k, v := range eventHistory {
-							if strings.HasPrefix(k, "new:") {
-								if creature, ok := (*v).(mapper.PlaceSomeoneMessagePayload); ok {
-									if creature.CreatureType == 2 {
-										delete(eventHistory, k)
-									}
+				case "P*":
+					for k, v := range eventHistory {
+						if strings.HasPrefix(k, "new:") {
+							if creature, ok := (*v).(mapper.PlaceSomeoneMessagePayload); ok {
+								if creature.CreatureType == 2 {
+									delete(eventHistory, k)
 								}
 							}
 						}
+					}
 
-					default:
-						if pos := strings.IndexRune(p.ObjID, '='); pos > 0 {
-							p.ObjID = p.ObjID[pos+1:]
-						}
+				default:
+					if pos := strings.IndexRune(p.ObjID, '='); pos > 0 {
+						p.ObjID = p.ObjID[pos+1:]
+					}
 
-						for k, v := range eventHistory {
-							if creature, ok := (*v).(mapper.PlaceSomeoneMessagePayload); ok {
-								if creature.Name == p.ObjID {
-									delete(eventHistory, k)
-									continue
-								}
-							}
-							f := strings.Split(k, ":")
-							if len(f) > 1 && f[1] == p.ObjID {
+					for k, v := range eventHistory {
+						if creature, ok := (*v).(mapper.PlaceSomeoneMessagePayload); ok {
+							if creature.Name == p.ObjID {
 								delete(eventHistory, k)
+								continue
 							}
 						}
+						f := strings.Split(k, ":")
+						if len(f) > 1 && f[1] == p.ObjID {
+							delete(eventHistory, k)
+						}
 					}
-				}()
-
-			case mapper.ClearFromMessagePayload:
-				if p.IsLocalFile {
-					delete(eventHistory, "llf:"+p.File)
-					eventHistory["ulf:"+p.File] = event
-				} else {
-					delete(eventHistory, "lsf:"+p.File)
-					eventHistory["usf:"+p.File] = event
 				}
+			}()
 
-			case mapper.CombatModeMessagePayload:
-				isInCombatMode = p.Enabled
+		case mapper.ClearFromMessagePayload:
+			if p.IsLocalFile {
+				delete(eventHistory, "llf:"+p.File)
+				eventHistory["ulf:"+p.File] = event
+			} else {
+				delete(eventHistory, "lsf:"+p.File)
+				eventHistory["usf:"+p.File] = event
+			}
+
+		case mapper.CombatModeMessagePayload:
+			isInCombatMode = p.Enabled
+
+		case mapper.LoadArcObjectMessagePayload:
+			recordElement(p.ID, event)
+		case mapper.LoadCircleObjectMessagePayload:
+			recordElement(p.ID, event)
+		case mapper.LoadLineObjectMessagePayload:
+			recordElement(p.ID, event)
+		case mapper.LoadPolygonObjectMessagePayload:
+			recordElement(p.ID, event)
+		case mapper.LoadRectangleObjectMessagePayload:
+			recordElement(p.ID, event)
+		case mapper.LoadSpellAreaOfEffectObjectMessagePayload:
+			recordElement(p.ID, event)
+		case mapper.LoadTextObjectMessagePayload:
+			recordElement(p.ID, event)
+		case mapper.LoadTileObjectMessagePayload:
+			recordElement(p.ID, event)
+		case mapper.PlaceSomeoneMessagePayload:
+			recordElement(p.ID, event)
+
+		case mapper.LoadFromMessagePayload:
+			if p.IsLocalFile {
+				delete(eventHistory, "ulf:"+p.File)
+				eventHistory["llf:"+p.File] = event
+			} else {
+				delete(eventHistory, "usf:"+p.File)
+				eventHistory["lsf:"+p.File] = event
+			}
 
-			case mapper.LoadArcObjectMessagePayload:
-				recordElement(p.ID, event)
-			case mapper.LoadCircleObjectMessagePayload:
-				recordElement(p.ID, event)
-			case mapper.LoadLineObjectMessagePayload:
-				recordElement(p.ID, event)
-			case mapper.LoadPolygonObjectMessagePayload:
-				recordElement(p.ID, event)
-			case mapper.LoadRectangleObjectMessagePayload:
-				recordElement(p.ID, event)
-			case mapper.LoadSpellAreaOfEffectObjectMessagePayload:
-				recordElement(p.ID, event)
-			case mapper.LoadTextObjectMessagePayload:
-				recordElement(p.ID, event)
-			case mapper.LoadTileObjectMessagePayload:
-				recordElement(p.ID, event)
-			case mapper.PlaceSomeoneMessagePayload:
-				recordElement(p.ID, event)
-
-			case mapper.LoadFromMessagePayload:
-				if p.IsLocalFile {
-					delete(eventHistory, "ulf:"+p.File)
-					eventHistory["llf:"+p.File] = event
-				} else {
-					delete(eventHistory, "usf:"+p.File)
-					eventHistory["lsf:"+p.File] = event
+		case mapper.RemoveObjAttributesMessagePayload:
+			func() {
+				if InstrumentCode {
+					if a.NrApp != nil {
+						defer a.NrApp.StartTransaction("track-remove-obj-attributes").End()
+					}
 				}
-
-			case mapper.RemoveObjAttributesMessagePayload:
-				func() {
-					if InstrumentCode {
-						if a.NrApp != nil {
-							defer a.NrApp.StartTransaction("track-remove-obj-attributes").End()
+				// TODO this could be more efficient
+				if o, ok := eventHistory["add:"+p.ObjID+":"+p.AttrName]; ok {
+					obj, valid := (*o).(mapper.AddObjAttributesMessagePayload)
+					if !valid {
+						a.Logf("value of eventHistory[add:%s:%s] is of type %T (removed)", p.ObjID, p.AttrName, o)
+						delete(eventHistory, "add:"+p.ObjID+":"+p.AttrName)
+					} else {
+						values := slices.Clone(obj.Values)
+						for _, addedValue := range p.Values {
+							if pos := slices.Index(values, addedValue); pos >= 0 {
+								// we previously tracked addition of this, so remove from the add list now
+								values = slices.Delete(values, pos, pos+1)
+							}
 						}
+						obj.Values = values
+						var pl mapper.MessagePayload = obj
+						eventHistory["add:"+p.ObjID+":"+p.AttrName] = &pl
 					}
-					// TODO this could be more efficient
-					if o, ok := eventHistory["add:"+p.ObjID+":"+p.AttrName]; ok {
-						obj, valid := (*o).(mapper.AddObjAttributesMessagePayload)
+				}
+				for _, addedValue := range p.Values {
+					if o, ok := eventHistory["del:"+p.ObjID+":"+p.AttrName]; ok {
+						obj, valid := (*o).(mapper.RemoveObjAttributesMessagePayload)
 						if !valid {
-							a.Logf("value of eventHistory[add:%s:%s] is of type %T (removed)", p.ObjID, p.AttrName, o)
-							delete(eventHistory, "add:"+p.ObjID+":"+p.AttrName)
+							a.Logf("value of eventHistory[del:%s:%s] is of type %T (removed)", p.ObjID, p.AttrName, o)
+							delete(eventHistory, "del:"+p.ObjID+":"+p.AttrName)
 						} else {
-							for _, addedValue := range p.Values {
-								if pos := slices.Index(obj.Values, addedValue); pos >= 0 {
-									// we previously tracked addition of this, so remove from the add list now
-									slices.Delete(obj.Values, pos, pos+1)
-								}
-							}
-						}
-					}
-					for _, addedValue := range p.Values {
-						if o, ok := eventHistory["del:"+p.ObjID+":"+p.AttrName]; ok {
-							obj, valid := (*o).(mapper.RemoveObjAttributesMessagePayload)
-							if !valid {
-								a.Logf("value of eventHistory[del:%s:%s] is of type %T (removed)", p.ObjID, p.AttrName, o)
-								delete(eventHistory, "del:"+p.ObjID+":"+p.AttrName)
+							if slices.Contains(obj.Values, addedValue) {
+								// we already have a note to remove this value, do nothing
 							} else {
-								if slices.Contains(obj.Values, addedValue) {
-									// we already have a note to remove this value, do nothing
-								} else {
-									// add this to our existing del: record
-									obj.Values = append(obj.Values, addedValue)
-								}
-							}
-						} else {
-							// we need a new del: record for this attribute
-							var pl mapper.MessagePayload
-							pl = mapper.RemoveObjAttributesMessagePayload{
-								ObjID:    p.ObjID,
-								AttrName: p.AttrName,
-								Values: []string{
-									addedValue,
-								},
+								// add this to our existing del: record
+								obj.Values = append(slices.Clone(obj.Values), addedValue)
+								var pl mapper.MessagePayload = obj
+								eventHistory["del:"+p.ObjID+":"+p.AttrName] = &pl
 							}
-							eventHistory["del:"+p.ObjID+":"+p.AttrName] = &pl
 						}
+					} else {
+						// we need a new del: record for this attribute
+						var pl mapper.MessagePayload
+						pl = mapper.RemoveObjAttributesMessagePayload{
+							ObjID:    p.ObjID,
+							AttrName: p.AttrName,
+							Values: []string{
+								addedValue,
+							},
+						}
+						eventHistory["del:"+p.ObjID+":"+p.AttrName] = &pl
 					}
-				}()
+				}
+			}()
 
-			case mapper.ToolbarMessagePayload:
-				toolbarHidden = !p.Enabled
+		case mapper.ToolbarMessagePayload:
+			toolbarHidden = !p.Enabled
 
-			case mapper.UpdateObjAttributesMessagePayload:
-				func() {
-					if InstrumentCode {
-						if a.NrApp != nil {
-							defer a.NrApp.StartTransaction("track-update-obj-attributes").End()
-						}
+		case mapper.UpdateObjAttributesMessagePayload:
+			func() {
+				if InstrumentCode {
+					if a.NrApp != nil {
+						defer a.NrApp.StartTransaction("track-update-obj-attributes").End()
 					}
-					if o, ok := eventHistory["mod:"+p.ObjID]; ok {
-						old, valid := (*o).(mapper.UpdateObjAttributesMessagePayload)
-						if !valid {
-							a.Logf("value of eventHistory[mod:%s] is of type %T (removed)", p.ObjID, o)
-							delete(eventHistory, "mod:"+p.ObjID)
-						} else {
-							// we already have a record for this; edit in place
-							for attrName, attrValue := range p.NewAttrs {
-								old.NewAttrs[attrName] = attrValue
-								// If we have add: or del: events for this object, this supercedes them
-								delete(eventHistory, "add:"+p.ObjID+":"+attrName)
-								delete(eventHistory, "del:"+p.ObjID+":"+attrName)
-							}
-						}
+				}
+				if o, ok := eventHistory["mod:"+p.ObjID]; ok {
+					old, valid := (*o).(mapper.UpdateObjAttributesMessagePayload)
+					if !valid {
+						a.Logf("value of eventHistory[mod:%s] is of type %T (removed)", p.ObjID, o)
+						delete(eventHistory, "mod:"+p.ObjID)
 					} else {
-						eventHistory["mod:"+p.ObjID] = event
-						for attrName, _ := range p.NewAttrs {
+						// we already have a record for this; replace it with an updated copy
+						// (the undo history may still refer to the old one)
+						old.NewAttrs = maps.Clone(old.NewAttrs)
+						for attrName, attrValue := range p.NewAttrs {
+							old.NewAttrs[attrName] = attrValue
 							// If we have add: or del: events for this object, this supercedes them
 							delete(eventHistory, "add:"+p.ObjID+":"+attrName)
 							delete(eventHistory, "del:"+p.ObjID+":"+attrName)
 						}
+						var pl mapper.MessagePayload = old
+						eventHistory["mod:"+p.ObjID] = &pl
+					}
+				} else {
+					// keep our own copy of the attributes since the sender still has theirs
+					p.NewAttrs = maps.Clone(p.NewAttrs)
+					var pl mapper.MessagePayload = p
+					eventHistory["mod:"+p.ObjID] = &pl
+					for attrName, _ := range p.NewAttrs {
+						// If we have add: or del: events for this object, this supercedes them
+						delete(eventHistory, "add:"+p.ObjID+":"+attrName)
+						delete(eventHistory, "del:"+p.ObjID+":"+attrName)
 					}
-				}()
+				}
+			}()
 
-			case mapper.UpdateStatusMarkerMessagePayload:
-				newStatusMarkers[p.Condition] = p
+		case mapper.UpdateStatusMarkerMessagePayload:
+			newStatusMarkers[p.Condition] = p
 
-			case mapper.UpdateTurnMessagePayload:
-				currentTurn = &p
+		case mapper.UpdateTurnMessagePayload:
+			currentTurn = &p
 
-			case mapper.UpdateInitiativeMessagePayload:
-				currentInitiativeList = &p
+		case mapper.UpdateInitiativeMessagePayload:
+			currentInitiativeList = &p
 
-			case mapper.UpdateClockMessagePayload:
-				currentTime = &p
+		case mapper.UpdateClockMessagePayload:
+			currentTime = &p
 
-			default:
-				a.Logf("unknown event %v (can't update game state)", *event)
+		default:
+			a.Logf("unknown event %v (can't update game state)", *event)
+		}
+	}
+
+	// applyChange is like applyEvent, but if event is a change to the map which can be
+	// undone, it also returns the messages which reverse it.
+	applyChange := func(event *mapper.MessagePayload) ([]mapper.MessagePayload, bool) {
+		if undo.limit <= 0 || !isUndoableChange(*event) {
+			applyEvent(event)
+			return nil, false
+		}
+
+		before := copyHistory(eventHistory)
+		view := mapper.AdjustViewMessagePayload{Grid: viewg, XView: viewx, YView: viewy}
+		inverse := inverseOfChange(eventHistory, *event)
+
+		applyEvent(event)
+
+		if view.Grid != viewg || view.XView != viewx || view.YView != viewy {
+			inverse = append(inverse, view)
+		}
+		inverse = append(inverse, restoredEntries(before, eventHistory)...)
+		if len(inverse) == 0 {
+			a.Debugf(DebugState, "change %v has no effect to undo", *event)
+			return nil, false
+		}
+		return inverse, true
+	}
+
+	// applyChanges applies a series of events to the game state, returning
+	// the record needed to undo all of them together, if any can be undone.
+	applyChanges := func(events []mapper.MessagePayload) (undoRecord, bool) {
+		var r undoRecord
+		if undo.limit > 0 {
+			r.history = copyHistory(eventHistory)
+			r.view = mapper.AdjustViewMessagePayload{Grid: viewg, XView: viewx, YView: viewy}
+		}
+		for _, e := range events {
+			event := e
+			if inverse, ok := applyChange(&event); ok {
+				r.changes = append(r.changes, event)
+				r.inverse = append(inverse, r.inverse...)
 			}
+		}
+		return r, len(r.changes) > 0
+	}
+
+	for {
+		select {
+		case <-checkpointTicker.C:
+			if stateChanged {
+				a.Debug(DebugState, "saving game state checkpoint")
+				if err := saveState(); err != nil {
+					a.Logf("unable to save game state: %v", err)
+				} else {
+					stateChanged = false
+				}
+			}
+
+		case reply := <-a.gameState.dump:
+			state, err := snapshotState()
+			reply <- gameStateDump{state: state, err: err}
+
+		case reply := <-a.gameState.checkpoint:
+			a.Debug(DebugState, "saving game state checkpoint on request")
+			err := saveState()
+			if err == nil {
+				stateChanged = false
+			}
+			reply <- err
+
+		case event := <-a.gameState.update:
+			if event == nil {
+				a.Log("received nil event to update game state")
+				continue
+			}
+			a.Debugf(DebugState, "updating game state from event %v", *event)
+			stateChanged = true
+			if r, ok := applyChanges([]mapper.MessagePayload{*event}); ok {
+				undo.push(r)
+				undo.undone = nil
+			}
+
+		case req := <-a.gameState.together:
+			a.Debugf(DebugState, "updating game state from %d %s", len(req.events), util.PluralizeString("event", len(req.events)))
+			stateChanged = true
+			if r, ok := applyChanges(req.events); ok {
+				undo.push(r)
+				undo.undone = nil
+			}
+			close(req.done)
+
+		case req := <-a.gameState.undo:
+			var result undoResult
+			for result.changes < req.count {
+				if req.redo {
+					if len(undo.undone) == 0 {
+						break
+					}
+					r := undo.undone[len(undo.undone)-1]
+					undo.undone = undo.undone[:len(undo.undone)-1]
+					if redone, ok := applyChanges(r.changes); ok {
+						undo.push(redone)
+					}
+					result.messages = append(result.messages, r.changes...)
+				} else {
+					if len(undo.done) == 0 {
+						break
+					}
+					r := undo.done[len(undo.done)-1]
+					undo.done = undo.done[:len(undo.done)-1]
+					// The clients get the inverse messages, but we can simply
+					// go back to the game state we had before.
+					eventHistory = copyHistory(r.history)
+					viewx, viewy, viewg = r.view.XView, r.view.YView, r.view.Grid
+					undo.undone = append(undo.undone, r)
+					result.messages = append(result.messages, r.inverse...)
+				}
+				result.changes++
+			}
+			if result.changes > 0 {
+				stateChanged = true
+			}
+			a.Debugf(DebugState, "%d %s (%d %s) for undo request (redo=%v)",
+				result.changes, util.PluralizeString("change", result.changes),
+				len(result.messages), util.PluralizeString("message", len(result.messages)), req.redo)
+			req.reply <- result
 
 		case client := <-a.gameState.sync:
 			func() {
@@ -2102,25 +2961,25 @@ func (a *Application) manageGameState() {
 
 				for k, e := range eventHistory {
 					if strings.HasPrefix(k, "llf:") || strings.HasPrefix(k, "lsf:") {
-						client.Conn.Send((*e).MessageType(), *e)
+						client.Conn.Send(gameStateMessageType(*e), *e)
 					}
 				}
 
 				for k, e := range eventHistory {
 					if strings.HasPrefix(k, "ulf:") || strings.HasPrefix(k, "usf:") {
-						client.Conn.Send((*e).MessageType(), *e)
+						client.Conn.Send(gameStateMessageType(*e), *e)
 					}
 				}
 
 				for k, e := range eventHistory {
 					if strings.HasPrefix(k, "new:") {
-						client.Conn.Send((*e).MessageType(), *e)
+						client.Conn.Send(gameStateMessageType(*e), *e)
 					}
 				}
 
 				for k, e := range eventHistory {
 					if strings.HasPrefix(k, "add:") || strings.HasPrefix(k, "del:") || strings.HasPrefix(k, "mod:") {
-						client.Conn.Send((*e).MessageType(), *e)
+						client.Conn.Send(gameStateMessageType(*e), *e)
 					}
 				}
 
@@ -2134,6 +2993,113 @@ func (a *Application) UpdateGameState(event *mapper.MessagePayload) {
 	a.gameState.update <- event
 }
 
+// gameStateChanges is sent to the game state manager to apply several
+// events together. The manager closes done when it has finished.
+type gameStateChanges struct {
+	events []mapper.MessagePayload
+	done   chan struct{}
+}
+
+// UpdateGameStateTogether applies a series of events to the game state
+// so that they are undone (and redone) as a single change to the map.
+// It waits until the game state has been updated.
+func (a *Application) UpdateGameStateTogether(events []mapper.MessagePayload) {
+	done := make(chan struct{})
+	a.gameState.together <- gameStateChanges{events: events, done: done}
+	<-done
+}
+
+// UndoGameState reverses up to count of the most recent changes to the map
+// (or, if redo is true, re-applies up to count of the changes most recently
+// reversed). It returns the number of changes actually undone or redone,
+// and the messages which must be sent to all clients to bring them into
+// agreement with the updated game state.
+func (a *Application) UndoGameState(count int, redo bool) (int, []mapper.MessagePayload) {
+	reply := make(chan undoResult, 1)
+	a.gameState.undo <- undoRequest{count: count, redo: redo, reply: reply}
+	result := <-reply
+	return result.changes, result.messages
+}
+
+// gameStateDump is the reply to a request for a copy of the game state.
+type gameStateDump struct {
+	state map[string]string
+	err   error
+}
+
+// DumpGameState returns a copy of the current game state, as the set of
+// protocol messages which would be saved to the database if it were
+// checkpointed now.
+func (a *Application) DumpGameState() (map[string]string, error) {
+	reply := make(chan gameStateDump, 1)
+	a.gameState.dump <- reply
+	result := <-reply
+	return result.state, result.err
+}
+
+// SaveGameState writes a checkpoint of the current game state to the
+// database immediately, waiting for that to complete.
+func (a *Application) SaveGameState() error {
+	reply := make(chan error, 1)
+	a.gameState.checkpoint <- reply
+	return <-reply
+}
+
+// gameStateMessageType returns the server message type for a payload
+// tracked in the game state. We can't rely on the payload's own MessageType
+// method for this since some of the payloads we track are synthesized
+// by the game state manager itself rather than being received from a client.
+func gameStateMessageType(p mapper.MessagePayload) mapper.ServerMessage {
+	switch p.(type) {
+	case mapper.AddObjAttributesMessagePayload:
+		return mapper.AddObjAttributes
+	case mapper.AdjustViewMessagePayload:
+		return mapper.AdjustView
+	case mapper.ClearMessagePayload:
+		return mapper.Clear
+	case mapper.ClearFromMessagePayload:
+		return mapper.ClearFrom
+	case mapper.CombatModeMessagePayload:
+		return mapper.CombatMode
+	case mapper.LoadArcObjectMessagePayload:
+		return mapper.LoadArcObject
+	case mapper.LoadCircleObjectMessagePayload:
+		return mapper.LoadCircleObject
+	case mapper.LoadFromMessagePayload:
+		return mapper.LoadFrom
+	case mapper.LoadLineObjectMessagePayload:
+		return mapper.LoadLineObject
+	case mapper.LoadPolygonObjectMessagePayload:
+		return mapper.LoadPolygonObject
+	case mapper.LoadRectangleObjectMessagePayload:
+		return mapper.LoadRectangleObject
+	case mapper.LoadSpellAreaOfEffectObjectMessagePayload:
+		return mapper.LoadSpellAreaOfEffectObject
+	case mapper.LoadTextObjectMessagePayload:
+		return mapper.LoadTextObject
+	case mapper.LoadTileObjectMessagePayload:
+		return mapper.LoadTileObject
+	case mapper.PlaceSomeoneMessagePayload:
+		return mapper.PlaceSomeone
+	case mapper.RemoveObjAttributesMessagePayload:
+		return mapper.RemoveObjAttributes
+	case mapper.ToolbarMessagePayload:
+		return mapper.Toolbar
+	case mapper.UpdateClockMessagePayload:
+		return mapper.UpdateClock
+	case mapper.UpdateInitiativeMessagePayload:
+		return mapper.UpdateInitiative
+	case mapper.UpdateObjAttributesMessagePayload:
+		return mapper.UpdateObjAttributes
+	case mapper.UpdateStatusMarkerMessagePayload:
+		return mapper.UpdateStatusMarker
+	case mapper.UpdateTurnMessagePayload:
+		return mapper.UpdateTurn
+	default:
+		return p.MessageType()
+	}
+}
+
 func (a *Application) SendGameState(client *mapper.ClientConnection) {
 	a.gameState.sync <- client
 }
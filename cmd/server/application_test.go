@@ -0,0 +1,146 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Helpers shared by the server's unit tests
+//
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/MadScienceZone/go-gma/v5/auth"
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+// newTestApplication returns an Application with a fresh database
+// which is removed when the test ends.
+func newTestApplication(t *testing.T) *Application {
+	t.Helper()
+	a := NewApplication()
+	a.Logger = log.New(io.Discard, "", 0)
+	a.DatabaseName = filepath.Join(t.TempDir(), "test.db")
+	if err := a.dbOpen(); err != nil {
+		t.Fatalf("unable to open test database: %v", err)
+	}
+	t.Cleanup(func() { a.dbClose() })
+	return a
+}
+
+// testClient is a client connection whose outgoing messages can be
+// examined by the test.
+type testClient struct {
+	*mapper.ClientConnection
+	received chan mapper.MessagePayload
+}
+
+// endOfMessages marks the end of the messages collected by testClient.messages.
+const endOfMessages = "end of test messages"
+
+// newTestClient returns a client logged in as the given user (if any).
+func newTestClient(t *testing.T, username string, gm bool) *testClient {
+	t.Helper()
+	server, client := net.Pipe()
+	c := &testClient{
+		ClientConnection: &mapper.ClientConnection{Conn: mapper.NewMapConnection(server)},
+		received:         make(chan mapper.MessagePayload, 100),
+	}
+	if username != "" {
+		c.Auth = &auth.Authenticator{Username: username, GmMode: gm}
+	}
+	go func() {
+		defer close(c.received)
+		scanner := bufio.NewScanner(client)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if msg, err := mapper.ParseMessage(scanner.Text()); err == nil {
+				c.received <- msg
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return c
+}
+
+// messages returns the messages sent to the client since the last call.
+func (c *testClient) messages(t *testing.T) []mapper.MessagePayload {
+	t.Helper()
+	if err := c.Conn.Send(mapper.Echo, mapper.EchoMessagePayload{S: endOfMessages}); err != nil {
+		t.Fatalf("unable to mark end of messages: %v", err)
+	}
+	if err := c.Conn.Flush(); err != nil {
+		t.Fatalf("unable to flush messages to client: %v", err)
+	}
+	var msgs []mapper.MessagePayload
+	for msg := range c.received {
+		if e, ok := msg.(mapper.EchoMessagePayload); ok && e.S == endOfMessages {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,356 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Support for hosting several campaigns in a single server process.
+// Each additional campaign is served by its own Application, with its
+// own database (and therefore chat history and game state), client
+// initialization file, and passwords. Clients choose a campaign when
+// they log in, or by connecting to the campaign's own endpoint.
+//
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MadScienceZone/go-gma/v5/auth"
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+// These are the names of the files we look for in a campaign's directory.
+const (
+	CampaignDatabaseFile = "game.db"
+	CampaignInitFile     = "init"
+	CampaignPasswordFile = "passwords"
+	CampaignMapDirectory = "maps"
+)
+
+// campaignSpec is the description of an additional campaign given on the
+// command line as name=dir[,[host]:port].
+type campaignSpec struct {
+	Name      string
+	Directory string
+	Endpoint  string
+}
+
+// campaignFlags collects the -campaign options, which may be repeated.
+type campaignFlags []campaignSpec
+
+func (f *campaignFlags) String() string {
+	var specs []string
+	for _, c := range *f {
+		if c.Endpoint != "" {
+			specs = append(specs, c.Name+"="+c.Directory+","+c.Endpoint)
+		} else {
+			specs = append(specs, c.Name+"="+c.Directory)
+		}
+	}
+	return strings.Join(specs, " ")
+}
+
+func (f *campaignFlags) Set(value string) error {
+	name, dir, ok := strings.Cut(value, "=")
+	if !ok || name == "" || dir == "" {
+		return fmt.Errorf("campaign must be specified as name=directory[,[host]:port]")
+	}
+	if strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("campaign name \"%s\" may not contain spaces", name)
+	}
+	for _, c := range *f {
+		if c.Name == name {
+			return fmt.Errorf("campaign \"%s\" specified more than once", name)
+		}
+	}
+
+	spec := campaignSpec{Name: name, Directory: dir}
+	if pos := strings.LastIndexByte(dir, ','); pos >= 0 && strings.ContainsRune(dir[pos+1:], ':') {
+		spec.Directory = dir[:pos]
+		spec.Endpoint = dir[pos+1:]
+	}
+	*f = append(*f, spec)
+	return nil
+}
+
+// campaignRegistry holds all of the campaigns the server hosts, by name.
+// The default campaign (configured by the -sqlite, -init-file, and
+// -password-file options) has the name "". This is set up when the
+// server starts and not changed after that.
+type campaignRegistry struct {
+	byName map[string]*Application
+	names  []string
+}
+
+// addCampaign creates the Application which will serve an additional
+// campaign, taking its general configuration from ours.
+func (a *Application) addCampaign(spec campaignSpec) error {
+	if a.campaigns == nil {
+		a.campaigns = &campaignRegistry{byName: map[string]*Application{"": a}}
+	}
+
+	info, err := os.Stat(spec.Directory)
+	if err != nil {
+		return fmt.Errorf("campaign \"%s\": %v", spec.Name, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("campaign \"%s\": %s is not a directory", spec.Name, spec.Directory)
+	}
+
+	c := NewApplication()
+	c.CampaignName = spec.Name
+	c.campaigns = a.campaigns
+	if a.Logger == nil {
+		c.Logger = nil
+	} else {
+		c.Logger = log.New(a.Logger.Writer(), strings.TrimSuffix(a.Logger.Prefix(), ": ")+"["+spec.Name+"]: ", a.Logger.Flags())
+	}
+	c.DebugLevel = a.currentDebugLevel()
+	c.NrLogFile = a.NrLogFile
+	c.NrAppName = a.NrAppName
+	c.Endpoint = spec.Endpoint
+	c.TLSConfig = a.TLSConfig
+	c.CoreDatabaseName = a.CoreDatabaseName
+	c.SaveInterval = a.SaveInterval
+	c.ResetGameState = a.ResetGameState
+	c.UndoLimit = a.UndoLimit
+	c.QoSLimits = a.QoSLimits
+	c.metrics = a.metrics
+	c.DatabaseName = filepath.Join(spec.Directory, CampaignDatabaseFile)
+
+	if path := filepath.Join(spec.Directory, CampaignInitFile); fileExists(path) {
+		c.InitFile = path
+	}
+	if path := filepath.Join(spec.Directory, CampaignPasswordFile); fileExists(path) {
+		c.PasswordFile = path
+		if err := c.refreshAuthenticator(); err != nil {
+			return fmt.Errorf("campaign \"%s\": unable to set up authentication: %v", spec.Name, err)
+		}
+	}
+	if path := filepath.Join(spec.Directory, CampaignMapDirectory); fileExists(path) {
+		c.MapDirectory = path
+	}
+
+	a.Logf("hosting campaign \"%s\" from \"%s\"", spec.Name, spec.Directory)
+	if c.InitFile != "" {
+		c.Logf("reading client initial command set from \"%s\"", c.InitFile)
+	}
+	if c.PasswordFile != "" {
+		c.Logf("authentication enabled via \"%s\"", c.PasswordFile)
+	} else {
+		c.Log("WARNING: authentication not enabled!")
+	}
+	c.Logf("using database \"%s\" to store internal state", c.DatabaseName)
+	if c.MapDirectory != "" {
+		c.Logf("saving and loading map files in \"%s\"", c.MapDirectory)
+	}
+	if c.Endpoint != "" {
+		c.Logf("configured to listen on \"%s\"", c.Endpoint)
+	}
+
+	a.campaigns.byName[spec.Name] = c
+	a.campaigns.names = append(a.campaigns.names, spec.Name)
+	sort.Strings(a.campaigns.names)
+	return nil
+}
+
+// checkCampaignAccess makes sure clients will be able to reach all of
+// the campaigns we host. A campaign without its own endpoint can only be
+// joined by logging in to the main campaign and naming it in the response
+// to the login challenge, but we don't issue a challenge if the main
+// campaign has no password file.
+func (a *Application) checkCampaignAccess() error {
+	if a.PasswordFile != "" {
+		return nil
+	}
+	for _, c := range a.otherCampaigns() {
+		if c.Endpoint == "" {
+			return fmt.Errorf("campaign \"%s\" has no endpoint of its own, so clients can only join it by logging in to the main campaign, which requires a password file", c.CampaignName)
+		}
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// otherCampaigns returns the Applications serving the campaigns other than
+// our own, in order by name.
+func (a *Application) otherCampaigns() []*Application {
+	var others []*Application
+	if a.campaigns != nil {
+		for _, name := range a.campaigns.names {
+			if c := a.campaigns.byName[name]; c != a {
+				others = append(others, c)
+			}
+		}
+	}
+	return others
+}
+
+// allCampaigns returns the Applications serving every campaign on the server,
+// starting with our own.
+func (a *Application) allCampaigns() []*Application {
+	return append([]*Application{a}, a.otherCampaigns()...)
+}
+
+// startCampaigns starts up the background tasks and opens the databases
+// for the additional campaigns, and begins listening on their own endpoints
+// (if they have them).
+func (a *Application) startCampaigns() error {
+	for _, c := range a.otherCampaigns() {
+		c.NrApp = a.NrApp
+		c.ServerStarted = a.ServerStarted
+		c.LastPing = a.LastPing
+		go generateMessageIDs(c.Logf, c.MessageIDGenerator, c.MessageIDReset)
+		go c.managePreambleData()
+		go c.manageClientList()
+		go c.announceClients()
+		if err := c.dbOpen(); err != nil {
+			return fmt.Errorf("unable to open database for campaign \"%s\": %v", c.CampaignName, err)
+		}
+		if err := c.coreOpen(); err != nil {
+			return fmt.Errorf("unable to open core database for campaign \"%s\": %v", c.CampaignName, err)
+		}
+		go c.manageGameState()
+
+		if c.Endpoint != "" {
+			incoming, err := c.listen(c.Endpoint)
+			if err != nil {
+				return err
+			}
+			go acceptIncomingConnections(incoming, c)
+		}
+	}
+	return nil
+}
+
+// stopCampaigns saves the game state of the additional campaigns and
+// closes their databases.
+func (a *Application) stopCampaigns() {
+	for _, c := range a.otherCampaigns() {
+		if err := c.SaveGameState(); err != nil {
+			c.Logf("unable to save game state: %v", err)
+		}
+		if err := c.coreClose(); err != nil {
+			c.Logf("error closing core database: %v", err)
+		}
+		if err := c.dbClose(); err != nil {
+			c.Logf("error closing database: %v", err)
+		}
+	}
+}
+
+// listen opens an incoming TCP port (using TLS if configured) on which
+// to accept clients.
+func (a *Application) listen(endpoint string) (net.Listener, error) {
+	incoming, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open incoming TCP %s: %v", endpoint, err)
+	}
+	if a.TLSConfig != nil {
+		incoming = tls.NewListener(incoming, a.TLSConfig)
+		a.Logf("Listening on %s (TLS)", endpoint)
+	} else {
+		a.Logf("Listening on %s", endpoint)
+	}
+	return incoming, nil
+}
+
+// Campaigns lists the campaigns, other than our own, which clients
+// may choose to join when they log in to us.
+func (a *Application) Campaigns() []mapper.CampaignDescription {
+	var list []mapper.CampaignDescription
+	for _, c := range a.otherCampaigns() {
+		desc := mapper.CampaignDescription{Name: c.CampaignName}
+		c.clientAuth.lock.RLock()
+		if c.clientAuth.passwords != nil && c.clientAuth.passwords.Hashed {
+			desc.Salt = c.clientAuth.passwords.Salt
+			desc.KeyIterations = c.clientAuth.passwords.KeyIterations
+			desc.PersonalSalts = c.clientAuth.passwords.PersonalSalts
+		}
+		c.clientAuth.lock.RUnlock()
+		list = append(list, desc)
+	}
+	return list
+}
+
+// SelectCampaign finds the Application serving the named campaign, for a
+// client who asked to join it, and prepares an authenticator for them.
+func (a *Application) SelectCampaign(name string) (mapper.MapServer, *auth.Authenticator, error) {
+	if a.campaigns == nil {
+		return nil, nil, fmt.Errorf("this server hosts only one campaign")
+	}
+	c, ok := a.campaigns.byName[name]
+	if !ok || name == "" {
+		return nil, nil, fmt.Errorf("there is no campaign called \"%s\" on this server", name)
+	}
+	cauth, err := c.newClientAuthenticator("")
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, cauth, nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,359 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for hosting several campaigns in one server
+//
+
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/auth"
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+func TestCampaignFlags(t *testing.T) {
+	var f campaignFlags
+	for i, test := range []struct {
+		value    string
+		ok       bool
+		expected campaignSpec
+	}{
+		{"alpha=/srv/alpha", true, campaignSpec{Name: "alpha", Directory: "/srv/alpha"}},
+		{"beta=/srv/beta,:2324", true, campaignSpec{Name: "beta", Directory: "/srv/beta", Endpoint: ":2324"}},
+		{"gamma=/srv/a,b,localhost:2325", true, campaignSpec{Name: "gamma", Directory: "/srv/a,b", Endpoint: "localhost:2325"}},
+		{"delta=/srv/a,b", true, campaignSpec{Name: "delta", Directory: "/srv/a,b"}},
+		{"alpha=/srv/other", false, campaignSpec{}},
+		{"=/srv/x", false, campaignSpec{}},
+		{"epsilon=", false, campaignSpec{}},
+		{"/srv/x", false, campaignSpec{}},
+		{"two words=/srv/x", false, campaignSpec{}},
+	} {
+		n := len(f)
+		err := f.Set(test.value)
+		if test.ok {
+			if err != nil {
+				t.Errorf("test %d: %q error %v", i, test.value, err)
+			} else if len(f) != n+1 || f[n] != test.expected {
+				t.Errorf("test %d: %q gave %v, expected %v", i, test.value, f[len(f)-1], test.expected)
+			}
+		} else if err == nil {
+			t.Errorf("test %d: %q accepted, expected an error", i, test.value)
+		} else if len(f) != n {
+			t.Errorf("test %d: %q added to the list even though it was rejected", i, test.value)
+		}
+	}
+	if s := f.String(); s != "alpha=/srv/alpha beta=/srv/beta,:2324 gamma=/srv/a,b,localhost:2325 delta=/srv/a,b" {
+		t.Errorf("campaign list as string was %q", s)
+	}
+}
+
+func writeTestPasswordFile(t *testing.T, path, groupPassword string, hashed bool) {
+	t.Helper()
+	p := &auth.PasswordFile{PersonalSecrets: make(map[string][]byte)}
+	if hashed {
+		var err error
+		if p, err = auth.NewHashedPasswordFile(16); err != nil {
+			t.Fatalf("unable to create password file: %v", err)
+		}
+	}
+	p.SetGroupPassword([]byte(groupPassword))
+	if err := p.Save(path); err != nil {
+		t.Fatalf("unable to save password file: %v", err)
+	}
+}
+
+// newTestCampaigns sets up a server whose main campaign has the password
+// "swordfish", also hosting campaigns "alpha" (whose password is "xyzzy")
+// and "beta" (which has no password).
+func newTestCampaigns(t *testing.T) *Application {
+	t.Helper()
+	a := newTestApplication(t)
+	a.PasswordFile = filepath.Join(t.TempDir(), "passwords")
+	writeTestPasswordFile(t, a.PasswordFile, "swordfish", false)
+	if err := a.refreshAuthenticator(); err != nil {
+		t.Fatalf("unable to load passwords: %v", err)
+	}
+
+	for _, name := range []string{"beta", "alpha"} {
+		dir := filepath.Join(t.TempDir(), name)
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatalf("unable to create campaign directory: %v", err)
+		}
+		if name == "alpha" {
+			writeTestPasswordFile(t, filepath.Join(dir, CampaignPasswordFile), "xyzzy", true)
+		}
+		if err := a.addCampaign(campaignSpec{Name: name, Directory: dir}); err != nil {
+			t.Fatalf("unable to add campaign %s: %v", name, err)
+		}
+	}
+	for _, c := range a.allCampaigns() {
+		go c.managePreambleData()
+	}
+	return a
+}
+
+func TestAddCampaign(t *testing.T) {
+	a := newTestCampaigns(t)
+	if err := a.addCampaign(campaignSpec{Name: "gamma", Directory: filepath.Join(t.TempDir(), "nonexistent")}); err == nil {
+		t.Errorf("added a campaign with a nonexistent directory")
+	}
+
+	others := a.otherCampaigns()
+	if len(others) != 2 || others[0].CampaignName != "alpha" || others[1].CampaignName != "beta" {
+		t.Fatalf("other campaigns are %v", others)
+	}
+	alpha := others[0]
+	if alpha.PasswordFile == "" || others[1].PasswordFile != "" {
+		t.Errorf("campaign password files are %q and %q", alpha.PasswordFile, others[1].PasswordFile)
+	}
+	if alpha.DatabaseName != filepath.Join(filepath.Dir(alpha.PasswordFile), CampaignDatabaseFile) {
+		t.Errorf("alpha campaign database is %q", alpha.DatabaseName)
+	}
+	if alpha.campaigns != a.campaigns || a.campaigns.byName[""] != a {
+		t.Errorf("campaigns don't share the registry")
+	}
+	if all := a.allCampaigns(); len(all) != 3 || all[0] != a {
+		t.Errorf("all campaigns are %v", all)
+	}
+
+	list := a.Campaigns()
+	if len(list) != 2 || list[0].Name != "alpha" || list[1].Name != "beta" {
+		t.Fatalf("campaign list is %v", list)
+	}
+	if len(list[0].Salt) == 0 || list[0].KeyIterations != 16 || !list[0].PersonalSalts {
+		t.Errorf("alpha campaign description is %v", list[0])
+	}
+	if len(list[1].Salt) != 0 || list[1].KeyIterations != 0 {
+		t.Errorf("beta campaign description is %v", list[1])
+	}
+}
+
+func TestSelectCampaign(t *testing.T) {
+	a := newTestCampaigns(t)
+	for _, test := range []struct {
+		name string
+		ok   bool
+		auth bool
+	}{
+		{"alpha", true, true},
+		{"beta", true, false},
+		{"gamma", false, false},
+		{"", false, false},
+	} {
+		server, cauth, err := a.SelectCampaign(test.name)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("selected nonexistent campaign %q", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unable to select campaign %q: %v", test.name, err)
+			continue
+		}
+		if c, ok := server.(*Application); !ok || c.CampaignName != test.name {
+			t.Errorf("selecting campaign %q gave server %v", test.name, server)
+		}
+		if (cauth != nil) != test.auth {
+			t.Errorf("selecting campaign %q gave authenticator %v", test.name, cauth)
+		}
+	}
+
+	if _, _, err := newTestApplication(t).SelectCampaign("alpha"); err == nil {
+		t.Errorf("selected a campaign on a server without any")
+	}
+}
+
+func TestCheckCampaignAccess(t *testing.T) {
+	a := newTestCampaigns(t)
+	if err := a.checkCampaignAccess(); err != nil {
+		t.Errorf("campaigns rejected with a main password file: %v", err)
+	}
+	a.PasswordFile = ""
+	if err := a.checkCampaignAccess(); err == nil {
+		t.Errorf("campaigns accepted without any way for clients to reach them")
+	}
+	for _, c := range a.otherCampaigns() {
+		c.Endpoint = "localhost:0"
+	}
+	if err := a.checkCampaignAccess(); err != nil {
+		t.Errorf("campaigns with their own endpoints rejected: %v", err)
+	}
+}
+
+// loginToCampaign logs in to the server as a client would, asking to
+// join the named campaign. It returns the server's answer (GRANTED or DENIED)
+// and, if granted, the Application serving the campaign the client was
+// added to.
+func loginToCampaign(t *testing.T, a *Application, campaign, password string, protocol int) (mapper.MessagePayload, *Application) {
+	t.Helper()
+	s, c := net.Pipe()
+	t.Cleanup(func() {
+		c.Close()
+		s.Close()
+	})
+	go startClientSession(s, a)
+
+	client := mapper.NewMapConnection(c)
+	scanner := bufio.NewScanner(c)
+	for scanner.Scan() {
+		msg, err := mapper.ParseMessage(scanner.Text())
+		if err != nil {
+			continue
+		}
+		switch p := msg.(type) {
+		case mapper.ChallengeMessagePayload:
+			salt, keyIterations := p.Salt, p.KeyIterations
+			for _, desc := range p.Campaigns {
+				if desc.Name == campaign {
+					salt, keyIterations = desc.Salt, desc.KeyIterations
+				}
+			}
+			cauth := auth.NewClientAuthenticator("fred", []byte(password), "test")
+			response, err := cauth.AcceptChallengeBytesWithSalt(p.Challenge, p.Iterations, salt, keyIterations)
+			if err != nil {
+				t.Fatalf("unable to answer challenge: %v", err)
+			}
+			if err := client.Send(mapper.Auth, mapper.AuthMessagePayload{Response: response, User: "fred", Client: "test", Campaign: campaign, Protocol: protocol}); err != nil {
+				t.Fatalf("unable to send response: %v", err)
+			}
+			if err := client.Flush(); err != nil {
+				t.Fatalf("unable to send response: %v", err)
+			}
+		case mapper.DeniedMessagePayload:
+			return p, nil
+		case mapper.GrantedMessagePayload:
+			// keep reading so the server can finish logging us in
+			go func() {
+				for scanner.Scan() {
+				}
+			}()
+			deadline := time.After(time.Second)
+			for {
+				for _, app := range a.allCampaigns() {
+					select {
+					case <-app.clientData.add:
+						return p, app
+					default:
+					}
+				}
+				select {
+				case <-deadline:
+					return p, nil
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}
+	}
+	t.Fatalf("server closed connection during login")
+	return nil, nil
+}
+
+func TestLoginToCampaign(t *testing.T) {
+	a := newTestCampaigns(t)
+	for _, test := range []struct {
+		campaign string
+		password string
+		granted  bool
+	}{
+		{"", "swordfish", true},
+		{"", "xyzzy", false},
+		{"alpha", "xyzzy", true},
+		{"alpha", "swordfish", false},
+		{"beta", "anything", true},
+		{"gamma", "swordfish", false},
+	} {
+		reply, c := loginToCampaign(t, a, test.campaign, test.password, mapper.GMAMapperProtocol)
+		if !test.granted {
+			if _, ok := reply.(mapper.DeniedMessagePayload); !ok {
+				t.Errorf("login to %q with %q gave %v, expected it to be denied", test.campaign, test.password, reply)
+			}
+			continue
+		}
+		granted, ok := reply.(mapper.GrantedMessagePayload)
+		if !ok {
+			t.Errorf("login to %q with %q gave %v, expected it to be granted", test.campaign, test.password, reply)
+			continue
+		}
+		if granted.User != "fred" || granted.Campaign != test.campaign {
+			t.Errorf("login to %q granted as %q to campaign %q", test.campaign, granted.User, granted.Campaign)
+		}
+		if c == nil || c.CampaignName != test.campaign {
+			t.Errorf("login to %q added client to campaign %v", test.campaign, c)
+		}
+	}
+
+	// clients which don't know to derive keys from their passwords are
+	// told why they can't sign on to a campaign which stores keys.
+	reply, _ := loginToCampaign(t, a, "alpha", "xyzzy", 0)
+	if denied, ok := reply.(mapper.DeniedMessagePayload); !ok || !strings.Contains(denied.Reason, "protocol 417") {
+		t.Errorf("login from an old client gave %v, expected it to be denied", reply)
+	}
+	reply, _ = loginToCampaign(t, a, "beta", "anything", 0)
+	if _, ok := reply.(mapper.GrantedMessagePayload); !ok {
+		t.Errorf("login from an old client to a campaign without keys gave %v, expected it to be granted", reply)
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,501 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Support for the server's structured configuration file. This is a
+// JSON document which may be used in place of (or in addition to) the
+// command-line options, and which also holds the QoS limits, allowed
+// client versions, status markers, and world settings otherwise given
+// in the client initialization file. It may be reloaded while the
+// server is running by sending it a SIGHUP.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+// ServerConfigVersion is the version of the configuration file format
+// understood by this server.
+const ServerConfigVersion = 1
+
+// ServerConfig describes the contents of the server configuration file.
+// Settings which are omitted (or empty) are left at their default values
+// (or as given on the command line). Any option explicitly given on the
+// command line overrides the corresponding setting in this file.
+type ServerConfig struct {
+	// The version of the file format; must be ServerConfigVersion.
+	Version int
+
+	// Where to listen for incoming connections, as with -endpoint,
+	// -websocket-endpoint, -metrics-endpoint, and -admin-socket.
+	Endpoint          string `json:",omitempty"`
+	WebSocketEndpoint string `json:",omitempty"`
+	MetricsEndpoint   string `json:",omitempty"`
+	AdminSocket       string `json:",omitempty"`
+
+	// Databases, as with -sqlite and -coredb.
+	Database     string `json:",omitempty"`
+	CoreDatabase string `json:",omitempty"`
+
+	// Logging, as with -log-file, -journal, and -debug.
+	LogFile string `json:",omitempty"`
+	Journal string `json:",omitempty"`
+	Debug   string `json:",omitempty"`
+
+	// Client initialization and password files, as with -init-file
+	// and -password-file.
+	InitFile     string `json:",omitempty"`
+	PasswordFile string `json:",omitempty"`
+
+	// Game state management, as with -save-interval, -undo-limit,
+	// and -map-dir.
+	SaveInterval string `json:",omitempty"`
+	UndoLimit    *int   `json:",omitempty"`
+	MapDirectory string `json:",omitempty"`
+
+	// TLS settings, as with the -tls-* options.
+	TLS *ServerTLSConfig `json:",omitempty"`
+
+	// Limits imposed on clients, as with the QOS init file command.
+	QoS *QoSLimitsDescription `json:",omitempty"`
+
+	// Client software versions offered to (and required of) clients,
+	// as with the UPDATES init file command.
+	AllowedClients []mapper.PackageUpdate `json:",omitempty"`
+
+	// Creature status markers defined for all clients, as with the DSM
+	// init file command.
+	StatusMarkers []mapper.StatusMarkerDefinition `json:",omitempty"`
+
+	// Campaign world settings, as with the WORLD init file command.
+	World *mapper.WorldMessagePayload `json:",omitempty"`
+}
+
+// ServerTLSConfig holds the TLS settings in the server configuration file.
+type ServerTLSConfig struct {
+	Cert              string `json:",omitempty"`
+	Key               string `json:",omitempty"`
+	ClientCA          string `json:",omitempty"`
+	RequireClientCert bool   `json:",omitempty"`
+}
+
+// validStatusMarkerShapes lists the shapes a status marker may have.
+var validStatusMarkerShapes = map[string]bool{
+	"|v": true, "v|": true, "|o": true, "o|": true, "|<>": true, "<>|": true,
+	"/": true, "\\": true, "//": true, "\\\\": true, "-": true, "=": true,
+	"|": true, "||": true, "+": true, "#": true, "V": true, "^": true,
+	"<>": true, "O": true,
+}
+
+// LoadServerConfig reads and validates the server configuration file at
+// the given path. Any errors found are reported with the file name and,
+// where possible, the line and column or setting name at fault.
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseServerConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s%v", path, err)
+	}
+	return config, nil
+}
+
+// ParseServerConfig decodes and validates the server configuration data.
+// Errors begin with ":line:column: " or ": setting: " so that the caller
+// may prefix them with the file name.
+func ParseServerConfig(data []byte) (*ServerConfig, error) {
+	var config ServerConfig
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+
+		switch {
+		case errors.As(err, &syntaxErr):
+			// The offset is just past the offending character.
+			return nil, fmt.Errorf("%s: %v", configPosition(data, syntaxErr.Offset-1), err)
+		case errors.As(err, &typeErr):
+			return nil, fmt.Errorf("%s: %s: expected %v but found JSON %s", settingPosition(data, typeErr.Offset, typeErr.Field), typeErr.Field, typeErr.Type, typeErr.Value)
+		case errors.Is(err, io.EOF):
+			return nil, fmt.Errorf(": file is empty")
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, fmt.Errorf("%s: unexpected end of file", configPosition(data, int64(len(data))))
+		default:
+			// This includes unknown fields, for which the decoder has
+			// already read the field's value as well as its name.
+			if name, found := strings.CutPrefix(err.Error(), "json: unknown field "); found {
+				if name, err := strconv.Unquote(name); err == nil {
+					return nil, fmt.Errorf("%s: %s: unknown setting", settingPosition(data, decoder.InputOffset(), name), name)
+				}
+			}
+			return nil, fmt.Errorf("%s: %v", configPosition(data, decoder.InputOffset()), err)
+		}
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("%s: unexpected data after the end of the configuration", configPosition(data, decoder.InputOffset()))
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf(": %v", err)
+	}
+	return &config, nil
+}
+
+// settingPosition reports the line and column (as ":line:column") of the
+// named setting, given the offset into the configuration data of a point
+// just past its value. If the name is a dotted path, only the last part of
+// it is used.
+func settingPosition(data []byte, offset int64, name string) string {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if pos := strings.LastIndexByte(name, '.'); pos >= 0 {
+		name = name[pos+1:]
+	}
+	if pos := bytes.LastIndex(data[:offset], []byte(strconv.Quote(name))); pos >= 0 {
+		offset = int64(pos)
+	}
+	return configPosition(data, offset)
+}
+
+// configPosition reports the line and column of a byte offset into
+// the configuration data, as ":line:column".
+func configPosition(data []byte, offset int64) string {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line := 1 + bytes.Count(data[:offset], []byte{'\n'})
+	column := offset - int64(bytes.LastIndexByte(data[:offset], '\n'))
+	return fmt.Sprintf(":%d:%d", line, column)
+}
+
+// validate checks the configuration settings for consistency, returning
+// an error describing the first problem found.
+func (c *ServerConfig) validate() error {
+	if c.Version != ServerConfigVersion {
+		if c.Version == 0 {
+			return fmt.Errorf("Version: required setting is missing (should be %d)", ServerConfigVersion)
+		}
+		return fmt.Errorf("Version: unsupported version %d (this server understands version %d)", c.Version, ServerConfigVersion)
+	}
+
+	for name, endpoint := range map[string]string{
+		"Endpoint":          c.Endpoint,
+		"WebSocketEndpoint": c.WebSocketEndpoint,
+		"MetricsEndpoint":   c.MetricsEndpoint,
+	} {
+		if endpoint != "" {
+			if _, _, err := net.SplitHostPort(endpoint); err != nil {
+				return fmt.Errorf("%s: \"%s\" is not a valid [host]:port value: %v", name, endpoint, err)
+			}
+		}
+	}
+
+	if c.Debug != "" {
+		if _, err := NamedDebugFlags(c.Debug); err != nil {
+			return fmt.Errorf("Debug: %v", err)
+		}
+	}
+
+	if c.SaveInterval != "" {
+		d, err := time.ParseDuration(c.SaveInterval)
+		if err != nil {
+			return fmt.Errorf("SaveInterval: %v", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("SaveInterval: %v may not be negative", d)
+		}
+	}
+
+	if c.UndoLimit != nil && *c.UndoLimit < 0 {
+		return fmt.Errorf("UndoLimit: %d may not be negative", *c.UndoLimit)
+	}
+
+	if c.TLS != nil {
+		if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+			return fmt.Errorf("TLS: Cert and Key must be specified together")
+		}
+		if c.TLS.Cert == "" && (c.TLS.ClientCA != "" || c.TLS.RequireClientCert) {
+			return fmt.Errorf("TLS: client certificate settings require Cert and Key")
+		}
+		if c.TLS.RequireClientCert && c.TLS.ClientCA == "" {
+			return fmt.Errorf("TLS.RequireClientCert: requires ClientCA")
+		}
+	}
+
+	if c.QoS != nil {
+		for name, window := range map[string]string{
+			"QoS.QueryImage.Window":  c.QoS.QueryImage.WindowString,
+			"QoS.MessageRate.Window": c.QoS.MessageRate.WindowString,
+			"QoS.Log.Window":         c.QoS.Log.WindowString,
+		} {
+			if window != "" {
+				if d, err := time.ParseDuration(window); err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				} else if d <= 0 {
+					return fmt.Errorf("%s: %v must be positive", name, d)
+				}
+			}
+		}
+	}
+
+	for i, pkg := range c.AllowedClients {
+		if pkg.Name == "" {
+			return fmt.Errorf("AllowedClients[%d].Name: required setting is missing", i)
+		}
+		if pkg.VersionPattern != "" {
+			re, err := regexp.Compile(pkg.VersionPattern)
+			if err != nil {
+				return fmt.Errorf("AllowedClients[%d].VersionPattern (%s): %v", i, pkg.Name, err)
+			}
+			if n := re.NumSubexp(); n != 1 {
+				return fmt.Errorf("AllowedClients[%d].VersionPattern (%s): must have exactly 1 capturing group; \"%s\" has %d", i, pkg.Name, pkg.VersionPattern, n)
+			}
+		} else if pkg.MinimumVersion != "" {
+			return fmt.Errorf("AllowedClients[%d].MinimumVersion (%s): requires a VersionPattern to match it with", i, pkg.Name)
+		}
+		for j, instance := range pkg.Instances {
+			if instance.Version == "" {
+				return fmt.Errorf("AllowedClients[%d].Instances[%d].Version (%s): required setting is missing", i, j, pkg.Name)
+			}
+		}
+	}
+
+	conditions := make(map[string]int)
+	for i, marker := range c.StatusMarkers {
+		if marker.Condition == "" {
+			return fmt.Errorf("StatusMarkers[%d].Condition: required setting is missing", i)
+		}
+		if previous, seen := conditions[marker.Condition]; seen {
+			return fmt.Errorf("StatusMarkers[%d].Condition: \"%s\" is already defined by StatusMarkers[%d]", i, marker.Condition, previous)
+		}
+		conditions[marker.Condition] = i
+		if marker.Shape != "" && !validStatusMarkerShapes[marker.Shape] {
+			return fmt.Errorf("StatusMarkers[%d].Shape (%s): \"%s\" is not a recognized marker shape", i, marker.Condition, marker.Shape)
+		}
+	}
+
+	return nil
+}
+
+// restartRequired lists the settings which differ between the old and new
+// configurations but which cannot take effect until the server is restarted.
+func (c *ServerConfig) restartRequired(n *ServerConfig) []string {
+	var changed []string
+
+	for _, s := range []struct {
+		name     string
+		old, new any
+	}{
+		{"Endpoint", c.Endpoint, n.Endpoint},
+		{"WebSocketEndpoint", c.WebSocketEndpoint, n.WebSocketEndpoint},
+		{"MetricsEndpoint", c.MetricsEndpoint, n.MetricsEndpoint},
+		{"AdminSocket", c.AdminSocket, n.AdminSocket},
+		{"Database", c.Database, n.Database},
+		{"CoreDatabase", c.CoreDatabase, n.CoreDatabase},
+		{"LogFile", c.LogFile, n.LogFile},
+		{"Journal", c.Journal, n.Journal},
+		{"InitFile", c.InitFile, n.InitFile},
+		{"PasswordFile", c.PasswordFile, n.PasswordFile},
+		{"SaveInterval", c.SaveInterval, n.SaveInterval},
+		{"UndoLimit", c.UndoLimit, n.UndoLimit},
+		{"MapDirectory", c.MapDirectory, n.MapDirectory},
+		{"TLS", c.TLS, n.TLS},
+	} {
+		if !reflect.DeepEqual(s.old, s.new) {
+			changed = append(changed, s.name)
+		}
+	}
+	return changed
+}
+
+// preambleCommands returns the client initialization commands implied by
+// the configuration, as command names and JSON parameters, in the order
+// they should be processed.
+func (c *ServerConfig) preambleCommands() ([][2]string, error) {
+	var commands [][2]string
+
+	add := func(cmd string, data any) error {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		commands = append(commands, [2]string{cmd, string(b)})
+		return nil
+	}
+
+	if c.QoS != nil {
+		if err := add("QOS", c.QoS); err != nil {
+			return nil, err
+		}
+	}
+	if c.AllowedClients != nil {
+		if err := add("UPDATES", mapper.UpdateVersionsMessagePayload{Packages: c.AllowedClients}); err != nil {
+			return nil, err
+		}
+	}
+	for _, marker := range c.StatusMarkers {
+		if err := add("DSM", mapper.UpdateStatusMarkerMessagePayload{StatusMarkerDefinition: marker}); err != nil {
+			return nil, err
+		}
+	}
+	if c.World != nil {
+		if err := add("WORLD", c.World); err != nil {
+			return nil, err
+		}
+	}
+	return commands, nil
+}
+
+// currentConfig returns the configuration file settings currently in effect,
+// or nil if we aren't using a configuration file.
+func (a *Application) currentConfig() *ServerConfig {
+	a.config.lock.Lock()
+	defer a.config.lock.Unlock()
+	return a.config.data
+}
+
+// ReloadConfigFile rereads the server configuration file. If it is valid,
+// all of its settings which may be changed while the server is running are
+// put into effect together, along with fresh copies of the client
+// initialization and password files. If there is a problem with any of
+// those files, the current configuration remains in effect. In either
+// case, connected clients are not disturbed.
+func (a *Application) ReloadConfigFile() error {
+	if a.ConfigFile == "" {
+		return a.ReloadConfiguration()
+	}
+
+	config, err := LoadServerConfig(a.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("unable to reload configuration file (keeping current settings): %v", err)
+	}
+	if err := a.applyConfiguration(config, true); err != nil {
+		return err
+	}
+
+	for _, name := range a.config.loaded.restartRequired(config) {
+		a.Logf("WARNING: change to %s in %s will not take effect until the server is restarted", name, a.ConfigFile)
+	}
+	a.Logf("reloaded configuration from \"%s\"", a.ConfigFile)
+	return nil
+}
+
+// applyConfiguration loads the client initialization and password files
+// to go along with the given configuration settings (which may be nil if
+// we don't have a configuration file), and if all of them are valid,
+// puts them all into effect together. If the configuration was just
+// reloaded from the file, its debugging flags are put into effect as well
+// (unless overridden on the command line).
+func (a *Application) applyConfiguration(config *ServerConfig, reloaded bool) error {
+	preamble, err := a.loadClientPreamble(config)
+	if err != nil {
+		return fmt.Errorf("unable to reload client initialization data (keeping current settings): %v", err)
+	}
+	passwords, err := a.readPasswordFile()
+	if err != nil {
+		return fmt.Errorf("unable to reload password file (keeping current settings): %v", err)
+	}
+
+	a.config.lock.Lock()
+	if reloaded {
+		a.config.data = config
+	}
+	if reloaded && !a.config.explicit["debug"] {
+		var level DebugFlags
+		if config.Debug != "" {
+			level, _ = NamedDebugFlags(config.Debug)
+		}
+		if level != a.currentDebugLevel() {
+			a.setDebugLevel(level)
+			a.Logf("debugging flags set to %#v%s", level, DebugFlagNames(level))
+		}
+	}
+	a.installPasswords(passwords)
+
+	// Only we send to the preamble manager, and we hold the lock, so if we
+	// discard any update it hasn't picked up yet, this can't block.
+	select {
+	case <-a.clientPreamble.reload:
+	default:
+	}
+	a.clientPreamble.reload <- preamble
+	a.config.lock.Unlock()
+
+	a.MessageIDReset <- 0
+	return nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
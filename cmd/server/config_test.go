@@ -0,0 +1,204 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for the server configuration file
+//
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseServerConfigErrors(t *testing.T) {
+	for i, test := range []struct {
+		data     string
+		expected string
+	}{
+		{"", ": file is empty"},
+		{"{\n  \"Version\": 1,\n  \"UndoLimit\": \"ten\"\n}\n", ":3:3: UndoLimit: expected int but found JSON string"},
+		{"{\n  \"Version\": 1,\n  \"TLS\": {\n    \"RequireClientCert\": 1\n  }\n}\n", ":4:5: TLS.RequireClientCert: expected bool but found JSON number"},
+		{"{\n  \"Version\": 1,\n  \"Endpiont\": \":2323\"\n}\n", ":3:3: Endpiont: unknown setting"},
+		{"{\n\t\"Version\": 1,\n\t\"Endpoint\": \":2323\",,\n}\n", ":3:22: invalid character ',' looking for beginning of object key string"},
+		{"{\"Version\": 1 \"Endpoint\": \":2323\"}", ":1:15: invalid character '\"' after object key:value pair"},
+		{"{\n  \"Version\": 1,\n", ":3:1: unexpected end of file"},
+		{"{\"Version\": 1}\n{}\n", ":2:1: unexpected data after the end of the configuration"},
+		{"{}", ": Version: required setting is missing (should be 1)"},
+		{"{\"Version\": 2}", ": Version: unsupported version 2 (this server understands version 1)"},
+		{"{\"Version\": 1, \"Endpoint\": \"2323\"}", ": Endpoint: \"2323\" is not a valid [host]:port value: address 2323: missing port in address"},
+		{"{\"Version\": 1, \"QoS\": {\"Log\": {\"Window\": \"soon\"}}}", ": QoS.Log.Window: time: invalid duration \"soon\""},
+		{"{\"Version\": 1, \"StatusMarkers\": [{\"Condition\": \"a\"}, {\"Condition\": \"a\"}]}", ": StatusMarkers[1].Condition: \"a\" is already defined by StatusMarkers[0]"},
+	} {
+		_, err := ParseServerConfig([]byte(test.data))
+		if err == nil {
+			t.Errorf("test %d: no error, expected %q", i, test.expected)
+		} else if err.Error() != test.expected {
+			t.Errorf("test %d: error %q, expected %q", i, err.Error(), test.expected)
+		}
+	}
+
+	config, err := ParseServerConfig([]byte("{\"Version\": 1, \"Endpoint\": \":2323\", \"UndoLimit\": 0}"))
+	if err != nil {
+		t.Fatalf("valid configuration error %v", err)
+	}
+	if config.Endpoint != ":2323" || config.UndoLimit == nil || *config.UndoLimit != 0 {
+		t.Errorf("valid configuration parsed as %v", config)
+	}
+}
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("unable to write %s: %v", path, err)
+	}
+}
+
+func TestReloadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	a := newTestApplication(t)
+	a.ConfigFile = filepath.Join(dir, "config.json")
+	a.InitFile = filepath.Join(dir, "init")
+	a.PasswordFile = filepath.Join(dir, "passwords")
+
+	writeTestFile(t, a.ConfigFile, `{"Version": 1, "World": {"Calendar": "golarion"}}`)
+	writeTestFile(t, a.InitFile, "// first\n")
+	writeTestFile(t, a.PasswordFile, "first\n")
+	config, err := LoadServerConfig(a.ConfigFile)
+	if err != nil {
+		t.Fatalf("unable to load configuration: %v", err)
+	}
+	a.config.data = config
+	a.config.loaded = config
+	if err := a.refreshAuthenticator(); err != nil {
+		t.Fatalf("unable to load passwords: %v", err)
+	}
+	go generateMessageIDs(a.Logf, a.MessageIDGenerator, a.MessageIDReset)
+	go a.managePreambleData()
+
+	// expectSettings checks that the settings in effect all came from the
+	// same version of our files.
+	expectSettings := func(version, calendar string, debug DebugFlags) {
+		t.Helper()
+		if c := a.currentConfig(); c.World == nil || c.World.Calendar != calendar {
+			t.Errorf("configuration is %v, expected calendar %s", c, calendar)
+		}
+		if level := a.currentDebugLevel(); level != debug {
+			t.Errorf("debug level is %v, expected %v", DebugFlagNames(level), DebugFlagNames(debug))
+		}
+		if secret := string(a.clientAuth.passwords.GroupSecret); secret != version {
+			t.Errorf("password is %q, expected %q", secret, version)
+		}
+		var preamble string
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			preamble = strings.Join(a.GetClientPreamble().Preamble, "\n")
+			if strings.Contains(preamble, "// "+version) {
+				break
+			}
+		}
+		if !strings.Contains(preamble, "// "+version) || !strings.Contains(preamble, `"Calendar":"`+calendar+`"`) {
+			t.Errorf("client preamble is %q, expected %s version with calendar %s", preamble, version, calendar)
+		}
+	}
+	expectSettings("first", "golarion", 0)
+
+	// Any problem with any of the files leaves all of the settings alone.
+	writeTestFile(t, a.ConfigFile, `{"Version": 1, "Debug": "init", "World": {"Calendar": "gregorian"}}`)
+	writeTestFile(t, a.InitFile, "// second\nBOGUS\n")
+	writeTestFile(t, a.PasswordFile, "second\n")
+	if err := a.ReloadConfigFile(); err == nil || !strings.Contains(err.Error(), "BOGUS") {
+		t.Errorf("reload with bad init file gave error %v", err)
+	}
+	expectSettings("first", "golarion", 0)
+
+	writeTestFile(t, a.InitFile, "// second\n")
+	if err := os.Remove(a.PasswordFile); err != nil {
+		t.Fatalf("unable to remove password file: %v", err)
+	}
+	if err := a.ReloadConfigFile(); err == nil || !strings.Contains(err.Error(), "password file") {
+		t.Errorf("reload with missing password file gave error %v", err)
+	}
+	expectSettings("first", "golarion", 0)
+
+	writeTestFile(t, a.PasswordFile, "second\n")
+	writeTestFile(t, a.ConfigFile, `{"Version": 1, "Debug": "nonsense", "World": {"Calendar": "gregorian"}}`)
+	if err := a.ReloadConfigFile(); err == nil || !strings.Contains(err.Error(), "Debug") {
+		t.Errorf("reload with bad configuration file gave error %v", err)
+	}
+	expectSettings("first", "golarion", 0)
+
+	// Once they're all fixed, everything changes together.
+	writeTestFile(t, a.ConfigFile, `{"Version": 1, "Debug": "init", "World": {"Calendar": "gregorian"}}`)
+	if err := a.ReloadConfigFile(); err != nil {
+		t.Errorf("reload error %v", err)
+	}
+	expectSettings("second", "gregorian", DebugInit)
+
+	// Reloading only the other files keeps the configuration (and any
+	// change to the debug level made since).
+	a.setDebugLevel(DebugAuth)
+	writeTestFile(t, a.InitFile, "// third\n")
+	writeTestFile(t, a.PasswordFile, "third\n")
+	if err := a.ReloadConfiguration(); err != nil {
+		t.Errorf("reload error %v", err)
+	}
+	expectSettings("third", "gregorian", DebugAuth)
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,388 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Core database subsystem for the map server. This answers client queries
+// about entries in the GMA core (SRD) database, as populated by the GMA core
+// tools and "gma go coredb". The core database is opened read-only; the
+// server's notion of which entries are hidden from players, and when each
+// entry last changed, is kept in the server's own database.
+//
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	"github.com/MadScienceZone/go-gma/v5/util"
+)
+
+// coreDataType describes where to find the entries of a given type
+// in the core database.
+type coreDataType struct {
+	table     string
+	codeField string
+	nameField string
+}
+
+// coreDataTypes maps the type names clients may ask for to the
+// database tables which hold them. The names are the same as those
+// understood by the coredb -type option.
+var coreDataTypes = map[string]coreDataType{
+	"bestiary": {table: "Monsters", codeField: "Code", nameField: "Species"},
+	"class":    {table: "Classes", codeField: "Code", nameField: "Name"},
+	"feat":     {table: "Feats", codeField: "Code", nameField: "Name"},
+	"language": {table: "Languages", codeField: "Language", nameField: "Language"},
+	"skill":    {table: "Skills", codeField: "Code", nameField: "Name"},
+	"spell":    {table: "Spells", codeField: "Code", nameField: "Name"},
+	"weapon":   {table: "Weapons", codeField: "Code", nameField: "Name"},
+}
+
+// lookupCoreDataType returns the canonical type name and table description
+// for the type named by a client. The name is not case-sensitive, and may
+// be given in singular or plural form.
+func lookupCoreDataType(name string) (string, coreDataType, bool) {
+	name = strings.ToLower(name)
+	switch name {
+	case "monster", "monsters":
+		name = "bestiary"
+	case "classes":
+		name = "class"
+	default:
+		if _, ok := coreDataTypes[name]; !ok {
+			name = strings.TrimSuffix(name, "s")
+		}
+	}
+	t, ok := coreDataTypes[name]
+	return name, t, ok
+}
+
+// coreEntry is what we know about a single core database entry.
+type coreEntry struct {
+	Code     string
+	Name     string
+	IsLocal  bool
+	IsHidden bool
+	Modified time.Time
+}
+
+func (a *Application) coreOpen() error {
+	var err error
+
+	if a.CoreDatabaseName == "" {
+		a.coredb = nil
+		return nil
+	}
+
+	if _, err = os.Stat(a.CoreDatabaseName); err != nil {
+		a.Logf("unable to access core database \"%s\": %v", a.CoreDatabaseName, err)
+		return err
+	}
+	a.coredb, err = sql.Open("sqlite3", "file:"+a.CoreDatabaseName+"?mode=ro")
+	return err
+}
+
+func (a *Application) coreClose() error {
+	if a.coredb == nil {
+		return nil
+	}
+	return a.coredb.Close()
+}
+
+// queryCoreEntries retrieves the entries of the given type from the core database,
+// optionally restricted to those whose code field is equal to the given code,
+// and marks them with the hidden status and modification time recorded for them
+// in the server's database.
+//
+// Any entries not previously known to the server (or whose names or local status
+// have changed since we last saw them) are added to the server's records now,
+// so their modification time is the first time the server noticed them.
+func (a *Application) queryCoreEntries(typeName string, t coreDataType, code string) ([]coreEntry, error) {
+	var rows *sql.Rows
+	var err error
+	var entries []coreEntry
+
+	queryString := "SELECT " + t.codeField + ", " + t.nameField + ", IsLocal FROM " + t.table
+	a.Debugf(DebugDB, "core database query %s (code=%q)", queryString, code)
+	if code == "" {
+		rows, err = a.coredb.Query(queryString)
+	} else {
+		rows, err = a.coredb.Query(queryString+" WHERE "+t.codeField+"=?", code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e coreEntry
+		var c, n sql.NullString
+		var isLocal sql.NullBool
+
+		if err := rows.Scan(&c, &n, &isLocal); err != nil {
+			return nil, err
+		}
+		e.Code = c.String
+		e.Name = n.String
+		e.IsLocal = isLocal.Bool
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	known, err := a.QueryCoreStatus(typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []coreEntry
+	now := time.Now()
+	for i, e := range entries {
+		if status, ok := known[e.Code]; ok && status.Name == e.Name && status.IsLocal == e.IsLocal {
+			entries[i].IsHidden = status.IsHidden
+			entries[i].Modified = status.Modified
+			continue
+		} else if ok {
+			entries[i].IsHidden = status.IsHidden
+		}
+		entries[i].Modified = now
+		changed = append(changed, entries[i])
+	}
+	if len(changed) > 0 {
+		if err := a.StoreCoreStatus(typeName, changed); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// QueryCoreData answers a client's CORE request for a single core database entry.
+func (a *Application) QueryCoreData(p mapper.QueryCoreDataMessagePayload, requester *mapper.ClientConnection) error {
+	response := mapper.UpdateCoreDataMessagePayload{
+		RequestID:   p.RequestID,
+		NoSuchEntry: true,
+	}
+	defer func() {
+		requester.Conn.Send(mapper.UpdateCoreData, response)
+	}()
+
+	if a.coredb == nil {
+		return nil
+	}
+	typeName, t, ok := lookupCoreDataType(p.Type)
+	if !ok {
+		return fmt.Errorf("no such core data type \"%s\"", p.Type)
+	}
+	if p.Code == "" && p.Name == "" {
+		return fmt.Errorf("core data query must include a code or name to search for")
+	}
+
+	entries, err := a.queryCoreEntries(typeName, t, p.Code)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if p.Name != "" && !strings.EqualFold(p.Name, e.Name) {
+			continue
+		}
+		if e.IsHidden && !requesterIsGM(requester) {
+			a.Debugf(DebugDB, "not revealing hidden %s entry %s to %v", typeName, e.Code, requester.IdTag())
+			continue
+		}
+		response = mapper.UpdateCoreDataMessagePayload{
+			IsHidden:  e.IsHidden,
+			IsLocal:   e.IsLocal,
+			Code:      e.Code,
+			Name:      e.Name,
+			Type:      p.Type,
+			RequestID: p.RequestID,
+		}
+		break
+	}
+	return nil
+}
+
+// QueryCoreIndex answers a client's COREIDX request by sending each matching entry in
+// a separate COREIDX= message, numbered by N out of the total number found (Of),
+// followed by a final message with IsDone set. If the query can't be answered,
+// a FAILED message is sent instead of the final message.
+func (a *Application) QueryCoreIndex(p mapper.QueryCoreIndexMessagePayload, requester *mapper.ClientConnection) (err error) {
+	var found []coreEntry
+
+	defer func() {
+		if err != nil {
+			requester.Conn.Send(mapper.Failed, mapper.FailedMessagePayload{
+				IsError:   true,
+				Command:   p.RawMessage(),
+				Reason:    err.Error(),
+				RequestID: p.RequestID,
+			})
+			return
+		}
+		requester.Conn.Send(mapper.UpdateCoreIndex, mapper.UpdateCoreIndexMessagePayload{
+			IsDone:    true,
+			Of:        len(found),
+			Type:      p.Type,
+			RequestID: p.RequestID,
+		})
+	}()
+
+	if a.coredb == nil {
+		return nil
+	}
+	typeName, t, ok := lookupCoreDataType(p.Type)
+	if !ok {
+		return fmt.Errorf("no such core data type \"%s\"", p.Type)
+	}
+
+	var codeRegex, nameRegex *regexp.Regexp
+	if p.CodeRegex != "" {
+		if codeRegex, err = regexp.Compile(p.CodeRegex); err != nil {
+			return err
+		}
+	}
+	if p.NameRegex != "" {
+		if nameRegex, err = regexp.Compile(p.NameRegex); err != nil {
+			return err
+		}
+	}
+
+	entries, err := a.queryCoreEntries(typeName, t, "")
+	if err != nil {
+		return err
+	}
+	isGM := requesterIsGM(requester)
+	for _, e := range entries {
+		if (codeRegex != nil && !codeRegex.MatchString(e.Code)) ||
+			(nameRegex != nil && !nameRegex.MatchString(e.Name)) ||
+			(!p.Since.IsZero() && !e.Modified.After(p.Since)) ||
+			(e.IsHidden && !isGM) {
+			continue
+		}
+		found = append(found, e)
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Name < found[j].Name
+	})
+	a.Debugf(DebugDB, "core index %s code=/%s/ name=/%s/ since %v: %d of %d %s",
+		typeName, p.CodeRegex, p.NameRegex, p.Since, len(found), len(entries), util.PluralizeString("entry", len(entries)))
+
+	for i, e := range found {
+		if err := requester.Conn.Send(mapper.UpdateCoreIndex, mapper.UpdateCoreIndexMessagePayload{
+			N:         i + 1,
+			Of:        len(found),
+			Code:      e.Code,
+			Name:      e.Name,
+			Type:      p.Type,
+			RequestID: p.RequestID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FilterCoreData changes the hidden status of all entries of a type whose codes
+// match (or, if InvertSelection is set, do not match) a regular expression.
+func (a *Application) FilterCoreData(p mapper.FilterCoreDataMessagePayload) error {
+	if a.coredb == nil {
+		return fmt.Errorf("no core database configured")
+	}
+	typeName, t, ok := lookupCoreDataType(p.Type)
+	if !ok {
+		return fmt.Errorf("no such core data type \"%s\"", p.Type)
+	}
+	filter, err := regexp.Compile(p.Filter)
+	if err != nil {
+		return err
+	}
+
+	entries, err := a.queryCoreEntries(typeName, t, "")
+	if err != nil {
+		return err
+	}
+
+	var changed []coreEntry
+	now := time.Now()
+	for _, e := range entries {
+		if filter.MatchString(e.Code) == p.InvertSelection || e.IsHidden == p.IsHidden {
+			continue
+		}
+		e.IsHidden = p.IsHidden
+		e.Modified = now
+		changed = append(changed, e)
+	}
+	a.Debugf(DebugDB, "filter /%s/ (invert=%v) sets hidden=%v on %d %s %s",
+		p.Filter, p.InvertSelection, p.IsHidden, len(changed), typeName, util.PluralizeString("entry", len(changed)))
+	if len(changed) == 0 {
+		return nil
+	}
+	return a.StoreCoreStatus(typeName, changed)
+}
+
+func requesterIsGM(requester *mapper.ClientConnection) bool {
+	return requester != nil && requester.Auth != nil && requester.Auth.GmMode
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
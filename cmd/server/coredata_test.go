@@ -0,0 +1,252 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Unit tests for the server's core database queries
+//
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+func TestLookupCoreDataType(t *testing.T) {
+	for i, test := range []struct {
+		name      string
+		canonical string
+		table     string
+		ok        bool
+	}{
+		{"class", "class", "Classes", true},
+		{"classes", "class", "Classes", true},
+		{"Class", "class", "Classes", true},
+		{"CLASSES", "class", "Classes", true},
+		{"feat", "feat", "Feats", true},
+		{"feats", "feat", "Feats", true},
+		{"monster", "bestiary", "Monsters", true},
+		{"Monsters", "bestiary", "Monsters", true},
+		{"bestiary", "bestiary", "Monsters", true},
+		{"language", "language", "Languages", true},
+		{"languages", "language", "Languages", true},
+		{"spells", "spell", "Spells", true},
+		{"skill", "skill", "Skills", true},
+		{"weapons", "weapon", "Weapons", true},
+		{"clas", "", "", false},
+		{"potions", "", "", false},
+	} {
+		name, ct, ok := lookupCoreDataType(test.name)
+		if ok != test.ok {
+			t.Errorf("test %d: %q ok=%v, expected %v", i, test.name, ok, test.ok)
+			continue
+		}
+		if ok && (name != test.canonical || ct.table != test.table) {
+			t.Errorf("test %d: %q gave %q (table %q), expected %q (table %q)", i, test.name, name, ct.table, test.canonical, test.table)
+		}
+	}
+}
+
+// openTestCoreDatabase gives the application a small core database
+// with a few classes in it.
+func openTestCoreDatabase(t *testing.T, a *Application) {
+	t.Helper()
+	a.CoreDatabaseName = filepath.Join(t.TempDir(), "core.db")
+	db, err := sql.Open("sqlite3", "file:"+a.CoreDatabaseName)
+	if err != nil {
+		t.Fatalf("unable to create core database: %v", err)
+	}
+	if _, err := db.Exec(`
+		create table Classes (Code text primary key, Name text, IsLocal integer(1));
+		insert into Classes values ('wiz', 'Wizard', 0), ('ftr', 'Fighter', 0), ('brd', 'Bard', 0), ('xyz', 'Xylophonist', 1);`); err != nil {
+		t.Fatalf("unable to populate core database: %v", err)
+	}
+	db.Close()
+
+	if err := a.coreOpen(); err != nil {
+		t.Fatalf("unable to open core database: %v", err)
+	}
+	t.Cleanup(func() { a.coreClose() })
+}
+
+func TestQueryCoreData(t *testing.T) {
+	a := newTestApplication(t)
+	openTestCoreDatabase(t, a)
+	c := newTestClient(t, "alice", false)
+
+	for i, test := range []struct {
+		query    mapper.QueryCoreDataMessagePayload
+		expected mapper.UpdateCoreDataMessagePayload
+		err      bool
+	}{
+		{
+			query:    mapper.QueryCoreDataMessagePayload{Type: "class", Code: "wiz", RequestID: "1"},
+			expected: mapper.UpdateCoreDataMessagePayload{Type: "class", Code: "wiz", Name: "Wizard", RequestID: "1"},
+		},
+		{
+			query:    mapper.QueryCoreDataMessagePayload{Type: "classes", Name: "xylophonist", RequestID: "2"},
+			expected: mapper.UpdateCoreDataMessagePayload{Type: "classes", Code: "xyz", Name: "Xylophonist", IsLocal: true, RequestID: "2"},
+		},
+		{
+			query:    mapper.QueryCoreDataMessagePayload{Type: "class", Code: "nope", RequestID: "3"},
+			expected: mapper.UpdateCoreDataMessagePayload{NoSuchEntry: true, RequestID: "3"},
+		},
+		{
+			query:    mapper.QueryCoreDataMessagePayload{Type: "potion", Code: "wiz", RequestID: "4"},
+			expected: mapper.UpdateCoreDataMessagePayload{NoSuchEntry: true, RequestID: "4"},
+			err:      true,
+		},
+	} {
+		err := a.QueryCoreData(test.query, c.ClientConnection)
+		if (err != nil) != test.err {
+			t.Errorf("test %d: error %v, expected error=%v", i, err, test.err)
+		}
+		msgs := c.messages(t)
+		if len(msgs) != 1 {
+			t.Errorf("test %d: received %d messages, expected 1", i, len(msgs))
+			continue
+		}
+		reply, ok := msgs[0].(mapper.UpdateCoreDataMessagePayload)
+		if !ok {
+			t.Errorf("test %d: received %T, expected UpdateCoreDataMessagePayload", i, msgs[0])
+			continue
+		}
+		reply.BaseMessagePayload = mapper.BaseMessagePayload{}
+		if reply != test.expected {
+			t.Errorf("test %d: received %v, expected %v", i, reply, test.expected)
+		}
+	}
+}
+
+func TestQueryCoreIndex(t *testing.T) {
+	a := newTestApplication(t)
+	openTestCoreDatabase(t, a)
+	player := newTestClient(t, "alice", false)
+	gm := newTestClient(t, "GM", true)
+
+	if err := a.FilterCoreData(mapper.FilterCoreDataMessagePayload{Type: "class", Filter: "^brd$", IsHidden: true}); err != nil {
+		t.Fatalf("unable to hide core entry: %v", err)
+	}
+
+	names := func(msgs []mapper.MessagePayload) ([]string, mapper.MessagePayload) {
+		var found []string
+		for i, msg := range msgs {
+			if e, ok := msg.(mapper.UpdateCoreIndexMessagePayload); ok && !e.IsDone {
+				if e.N != i+1 || e.Of != len(msgs)-1 {
+					t.Errorf("entry %s numbered %d of %d, expected %d of %d", e.Code, e.N, e.Of, i+1, len(msgs)-1)
+				}
+				found = append(found, e.Name)
+				continue
+			}
+			return found, msg
+		}
+		return found, nil
+	}
+
+	for i, test := range []struct {
+		client   *testClient
+		query    mapper.QueryCoreIndexMessagePayload
+		expected []string
+	}{
+		{player, mapper.QueryCoreIndexMessagePayload{Type: "class"}, []string{"Fighter", "Wizard", "Xylophonist"}},
+		{gm, mapper.QueryCoreIndexMessagePayload{Type: "classes"}, []string{"Bard", "Fighter", "Wizard", "Xylophonist"}},
+		{player, mapper.QueryCoreIndexMessagePayload{Type: "class", NameRegex: "^[FW]"}, []string{"Fighter", "Wizard"}},
+		{gm, mapper.QueryCoreIndexMessagePayload{Type: "class", CodeRegex: "^b"}, []string{"Bard"}},
+		{player, mapper.QueryCoreIndexMessagePayload{Type: "class", CodeRegex: "^b"}, nil},
+	} {
+		test.query.RequestID = "idx"
+		if err := a.QueryCoreIndex(test.query, test.client.ClientConnection); err != nil {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		}
+		found, last := names(test.client.messages(t))
+		if len(found) != len(test.expected) {
+			t.Errorf("test %d: found %v, expected %v", i, found, test.expected)
+		} else {
+			for j := range found {
+				if found[j] != test.expected[j] {
+					t.Errorf("test %d: found %v, expected %v", i, found, test.expected)
+					break
+				}
+			}
+		}
+		done, ok := last.(mapper.UpdateCoreIndexMessagePayload)
+		if !ok || !done.IsDone || done.Of != len(test.expected) || done.RequestID != "idx" {
+			t.Errorf("test %d: final message %v, expected IsDone with Of=%d", i, last, len(test.expected))
+		}
+	}
+
+	for i, query := range []mapper.QueryCoreIndexMessagePayload{
+		{Type: "class", CodeRegex: "(", RequestID: "bad"},
+		{Type: "class", NameRegex: "[", RequestID: "bad"},
+		{Type: "potions", RequestID: "bad"},
+	} {
+		if err := a.QueryCoreIndex(query, player.ClientConnection); err == nil {
+			t.Errorf("failure test %d: expected error", i)
+		}
+		found, last := names(player.messages(t))
+		if len(found) != 0 {
+			t.Errorf("failure test %d: found %v, expected nothing", i, found)
+		}
+		if f, ok := last.(mapper.FailedMessagePayload); !ok || !f.IsError || f.RequestID != "bad" {
+			t.Errorf("failure test %d: final message %v, expected FAILED", i, last)
+		}
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -17,8 +17,9 @@
 
 //
 // Database subsystem for the map server. This stores the persistent data the server
-// needs to maintain between sessions. Note that the game state is now considered
-// too ephemeral to pay the cost of constantly writing it to the database.
+// needs to maintain between sessions. Note that the game state is considered
+// too ephemeral to pay the cost of constantly writing it to the database, so
+// it is only checkpointed here periodically so it can survive a server restart.
 //
 
 package main
@@ -30,9 +31,11 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/MadScienceZone/go-gma/v5/dice"
 	"github.com/MadScienceZone/go-gma/v5/mapper"
+	"github.com/MadScienceZone/go-gma/v5/util"
 	"golang.org/x/exp/slices"
 )
 
@@ -68,6 +71,12 @@ func (a *Application) dbOpen() error {
 				rollspec    text    not null,
 					primary key (user, name)
 			);
+			create table dicevariables (
+				user        text    not null,
+				name        text    not null,
+				value       text    not null,
+					primary key (user, name)
+			);
 			create table delegates (
 				user        text    not null,
 				delegate    text    not null,
@@ -87,7 +96,30 @@ func (a *Application) dbOpen() error {
 				speed integer not null default 0,
 				loops integer not null default 0,
 					primary key (name,zoom)
-		);`)
+			);
+			create table gamestate (
+				eventkey text   primary key,
+				rawdata  text   not null
+			);
+			create table corestatus (
+				type     text   not null,
+				code     text   not null,
+				name     text   not null,
+				islocal  integer(1) not null,
+				hidden   integer(1) not null default 0,
+				modified integer not null,
+					primary key (type, code)
+			);
+			create table dieaudit (
+				msgid   integer primary key,
+				rawdata text    not null
+			);
+			create table randomtables (
+				name        text    primary key,
+				description text    not null,
+				rollspec    text    not null,
+				entries     text    not null
+			);`)
 
 		if err != nil {
 			a.Logf("unable to create sqlite3 database %s contents: %v", a.DatabaseName, err)
@@ -107,6 +139,7 @@ func (a *Application) dbClose() error {
 }
 
 func (a *Application) StoreImageData(imageName string, img mapper.ImageInstance, anim *mapper.ImageAnimation) error {
+	defer a.observeQuery("StoreImageData", time.Now())
 	if anim == nil {
 		result, err := a.sqldb.Exec(`REPLACE INTO images (name, zoom, location, islocal) VALUES (?, ?, ?, ?);`, imageName, img.Zoom, img.File, img.IsLocalFile)
 		if err != nil {
@@ -139,6 +172,7 @@ func (a *Application) debugDbAffected(result sql.Result, msg string) {
 }
 
 func (a *Application) ClearChatHistory(target int) error {
+	defer a.observeQuery("ClearChatHistory", time.Now())
 	var result sql.Result
 	var err error
 
@@ -160,6 +194,7 @@ func (a *Application) ClearChatHistory(target int) error {
 }
 
 func (a *Application) QueryImageData(img mapper.ImageDefinition) (mapper.ImageDefinition, error) {
+	defer a.observeQuery("QueryImageData", time.Now())
 	var resultSet mapper.ImageDefinition
 
 	a.Debugf(DebugDB, "query of image \"%s\"", img.Name)
@@ -198,6 +233,7 @@ func (a *Application) QueryImageData(img mapper.ImageDefinition) (mapper.ImageDe
 }
 
 func (a *Application) QueryPresetDelegates(user string) ([]string, error) {
+	defer a.observeQuery("QueryPresetDelegates", time.Now())
 	var delegates []string
 
 	a.Debugf(DebugDB, "query of delegates for %s", user)
@@ -220,6 +256,7 @@ func (a *Application) QueryPresetDelegates(user string) ([]string, error) {
 }
 
 func (a *Application) QueryPresetDelegateFor(user string) ([]string, error) {
+	defer a.observeQuery("QueryPresetDelegateFor", time.Now())
 	var delegates []string
 
 	a.Debugf(DebugDB, "query of who %s is a delegate for", user)
@@ -242,6 +279,7 @@ func (a *Application) QueryPresetDelegateFor(user string) ([]string, error) {
 }
 
 func (a *Application) QueryChatHistory(target int, requester *mapper.ClientConnection) error {
+	defer a.observeQuery("QueryChatHistory", time.Now())
 	var rows *sql.Rows
 	var err error
 
@@ -307,6 +345,7 @@ func (a *Application) QueryChatHistory(target int, requester *mapper.ClientConne
 }
 
 func (a *Application) StoreDicePresetDelegates(user string, delegates []string) error {
+	defer a.observeQuery("StoreDicePresetDelegates", time.Now())
 	result, err := a.sqldb.Exec(`delete from delegates where user = ?`, user)
 	if err != nil {
 		return err
@@ -325,6 +364,7 @@ func (a *Application) StoreDicePresetDelegates(user string, delegates []string)
 }
 
 func (a *Application) StoreDicePresets(user string, presets []dice.DieRollPreset, deleteOld bool) error {
+	defer a.observeQuery("StoreDicePresets", time.Now())
 	if deleteOld {
 		a.Debugf(DebugDB, "removing existing die-roll presets for %s", user)
 		result, err := a.sqldb.Exec(`delete from dicepresets where user = ?`, user)
@@ -349,6 +389,7 @@ func (a *Application) StoreDicePresets(user string, presets []dice.DieRollPreset
 }
 
 func (a *Application) FilterDicePresets(user string, f mapper.FilterDicePresetsMessagePayload) error {
+	defer a.observeQuery("FilterDicePresets", time.Now())
 	var namesToDelete []string
 
 	a.Debugf(DebugDB, "removing existing die-roll presets for %s matching /%s/", user, f.Filter)
@@ -388,6 +429,7 @@ func (a *Application) FilterDicePresets(user string, f mapper.FilterDicePresetsM
 }
 
 func (a *Application) SendDicePresets(user string) error {
+	defer a.observeQuery("SendDicePresets", time.Now())
 	delegates, err := a.QueryPresetDelegates(user)
 	if err != nil {
 		return err
@@ -427,7 +469,97 @@ func (a *Application) SendDicePresets(user string) error {
 	return nil
 }
 
+func (a *Application) QueryDiceVariables(user string) (map[string]string, error) {
+	defer a.observeQuery("QueryDiceVariables", time.Now())
+	vars := make(map[string]string)
+
+	a.Debugf(DebugDB, "query of die-roll variables for %s", user)
+	rows, err := a.sqldb.Query(`select name, value from dicevariables where user = ?`, user)
+	if err != nil {
+		return vars, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+
+		if err := rows.Scan(&name, &value); err != nil {
+			return vars, err
+		}
+		vars[name] = value
+		a.Debugf(DebugDB, "result: $%s=%s", name, value)
+	}
+	return vars, rows.Err()
+}
+
+// StoreDiceVariables saves the die-roll variables for a user. If deleteOld
+// is true, they replace all existing variables; otherwise they are merged
+// into them, with any variable given an empty value being removed.
+func (a *Application) StoreDiceVariables(user string, vars map[string]string, deleteOld bool) error {
+	defer a.observeQuery("StoreDiceVariables", time.Now())
+	for name := range vars {
+		if !dice.ValidVariableName(name) {
+			return fmt.Errorf("invalid die-roll variable name \"%s\"", name)
+		}
+	}
+
+	if deleteOld {
+		a.Debugf(DebugDB, "removing existing die-roll variables for %s", user)
+		result, err := a.sqldb.Exec(`delete from dicevariables where user = ?`, user)
+		if err != nil {
+			return err
+		}
+		a.debugDbAffected(result, fmt.Sprintf("clear old variables for %s", user))
+	}
+
+	for name, value := range vars {
+		if value == "" {
+			a.Debugf(DebugDB, "removing variable %s for %s", name, user)
+			result, err := a.sqldb.Exec(`delete from dicevariables where user = ? and name = ?`, user, name)
+			if err != nil {
+				return err
+			}
+			a.debugDbAffected(result, fmt.Sprintf("remove variable %s for %s", name, user))
+			continue
+		}
+		a.Debugf(DebugDB, "setting variable %s for %s", name, user)
+		result, err := a.sqldb.Exec(`
+			replace into dicevariables (user, name, value)
+				values (?, ?, ?)`,
+			user, name, value)
+		if err != nil {
+			return err
+		}
+		a.debugDbAffected(result, fmt.Sprintf("set variable %s for %s", name, user))
+	}
+	return nil
+}
+
+func (a *Application) SendDiceVariables(user string) error {
+	defer a.observeQuery("SendDiceVariables", time.Now())
+	delegates, err := a.QueryPresetDelegates(user)
+	if err != nil {
+		return err
+	}
+
+	vars, err := a.QueryDiceVariables(user)
+	if err != nil {
+		return err
+	}
+
+	for _, peer := range a.GetClients() {
+		if peer.Auth != nil && (peer.Auth.Username == user || slices.Contains(delegates, peer.Auth.Username)) {
+			peer.Conn.Send(mapper.UpdateDiceVariables, mapper.UpdateDiceVariablesMessagePayload{
+				For:       user,
+				Variables: vars,
+			})
+		}
+	}
+	return nil
+}
+
 func (a *Application) AddToChatHistory(id int, chatType mapper.ServerMessage, chatData any) error {
+	defer a.observeQuery("AddToChatHistory", time.Now())
 	var dbMessageType int
 
 	switch chatType {
@@ -454,6 +586,312 @@ func (a *Application) AddToChatHistory(id int, chatType mapper.ServerMessage, ch
 	return nil
 }
 
+// AddDieRollAudit records the audit record for a die roll under each of
+// the message IDs of its results. Unlike the chat history, these are
+// kept when the chat history is cleared.
+func (a *Application) AddDieRollAudit(audit mapper.DieRollAudit) error {
+	defer a.observeQuery("AddDieRollAudit", time.Now())
+	jdata, err := json.Marshal(audit)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.sqldb.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range audit.MessageIDs {
+		if _, err := tx.Exec(`insert into dieaudit (msgid, rawdata) values (?, ?)`, id, string(jdata)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	a.Debugf(DebugDB, "recorded die-roll audit for message %v", audit.MessageIDs)
+	return nil
+}
+
+// QueryDieRollAudit retrieves the audit record for the die roll whose
+// result was sent with the given message ID.
+func (a *Application) QueryDieRollAudit(id int) (mapper.DieRollAudit, error) {
+	defer a.observeQuery("QueryDieRollAudit", time.Now())
+	var audit mapper.DieRollAudit
+	var rawdata string
+
+	err := a.sqldb.QueryRow(`select rawdata from dieaudit where msgid = ?`, id).Scan(&rawdata)
+	if err == sql.ErrNoRows {
+		return audit, fmt.Errorf("there is no die roll with message ID %d in the audit log", id)
+	}
+	if err != nil {
+		return audit, err
+	}
+	if err := json.Unmarshal([]byte(rawdata), &audit); err != nil {
+		return audit, fmt.Errorf("unable to understand audit record for message ID %d: %v", id, err)
+	}
+	return audit, nil
+}
+
+// QueryRandomTables retrieves all of the random tables stored in the database.
+func (a *Application) QueryRandomTables() ([]dice.RandomTable, error) {
+	defer a.observeQuery("QueryRandomTables", time.Now())
+	var tables []dice.RandomTable
+
+	rows, err := a.sqldb.Query(`select name, description, rollspec, entries from randomtables order by name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table dice.RandomTable
+		var entries string
+		if err := rows.Scan(&table.Name, &table.Description, &table.DieRollSpec, &entries); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(entries), &table.Entries); err != nil {
+			return nil, fmt.Errorf("unable to understand entries of random table \"%s\": %v", table.Name, err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// StoreRandomTables adds the given random tables to the database, replacing
+// any already stored under the same names. If deleteOld is true, all other
+// stored tables are removed first. Nothing is changed if any of the tables
+// are invalid.
+func (a *Application) StoreRandomTables(tables []dice.RandomTable, deleteOld bool) error {
+	defer a.observeQuery("StoreRandomTables", time.Now())
+	for _, table := range tables {
+		if err := table.Validate(); err != nil {
+			return err
+		}
+	}
+
+	tx, err := a.sqldb.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if deleteOld {
+		a.Debugf(DebugDB, "removing existing random tables")
+		result, err := tx.Exec(`delete from randomtables`)
+		if err != nil {
+			return err
+		}
+		a.debugDbAffected(result, "clear old random tables")
+	}
+
+	for _, table := range tables {
+		entries, err := json.Marshal(table.Entries)
+		if err != nil {
+			return err
+		}
+		a.Debugf(DebugDB, "adding random table %s", table.Name)
+		result, err := tx.Exec(`
+			replace into randomtables (name, description, rollspec, entries)
+				values (?, ?, ?, ?)`,
+			table.Name, table.Description, table.DieRollSpec, string(entries))
+		if err != nil {
+			return err
+		}
+		a.debugDbAffected(result, fmt.Sprintf("add random table %s", table.Name))
+	}
+	return tx.Commit()
+}
+
+// FilterRandomTables removes all stored random tables whose names match
+// the filter's regular expression.
+func (a *Application) FilterRandomTables(f mapper.FilterRandomTablesMessagePayload) error {
+	defer a.observeQuery("FilterRandomTables", time.Now())
+	var namesToDelete []string
+
+	a.Debugf(DebugDB, "removing existing random tables matching /%s/", f.Filter)
+	filter, err := regexp.Compile(f.Filter)
+	if err != nil {
+		return err
+	}
+
+	rows, err := a.sqldb.Query(`select name from randomtables`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var thisName string
+		if err := rows.Scan(&thisName); err != nil {
+			return err
+		}
+		if filter.MatchString(thisName) {
+			namesToDelete = append(namesToDelete, thisName)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if len(namesToDelete) > 0 {
+		a.Debugf(DebugDB, "--filter pattern matches %v row(s)", len(namesToDelete))
+
+		for _, name := range namesToDelete {
+			_, err := a.sqldb.Exec(`delete from randomtables where name = ?`, name)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		a.Debugf(DebugDB, "--filter matched no random tables")
+	}
+	return nil
+}
+
+// SendRandomTables sends the stored random tables to the requesting client,
+// or to all authenticated clients if requester is nil.
+func (a *Application) SendRandomTables(requester *mapper.ClientConnection) error {
+	tables, err := a.QueryRandomTables()
+	if err != nil {
+		return err
+	}
+
+	update := mapper.UpdateRandomTablesMessagePayload{Tables: tables}
+	if requester != nil {
+		requester.Conn.Send(mapper.UpdateRandomTables, update)
+		return nil
+	}
+	for _, peer := range a.GetClients() {
+		if peer.Auth != nil {
+			peer.Conn.Send(mapper.UpdateRandomTables, update)
+		}
+	}
+	return nil
+}
+
+// StoreGameState replaces the game state checkpoint in the database with
+// the given set of records. Each maps the game state manager's key for that part
+// of the game state to the protocol message which will recreate it.
+func (a *Application) StoreGameState(state map[string]string) error {
+	defer a.observeQuery("StoreGameState", time.Now())
+	tx, err := a.sqldb.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`delete from gamestate`)
+	if err != nil {
+		return err
+	}
+	a.debugDbAffected(result, "clear old game state")
+
+	for key, rawdata := range state {
+		if _, err := tx.Exec(`insert into gamestate (eventkey, rawdata) values (?, ?)`, key, rawdata); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	a.Debugf(DebugDB, "saved %d game state %s", len(state), util.PluralizeString("record", len(state)))
+	return nil
+}
+
+// QueryGameState retrieves the game state checkpoint from the database,
+// as a map of game state keys to the messages which recreate that part
+// of the game state.
+func (a *Application) QueryGameState() (map[string]mapper.MessagePayload, error) {
+	defer a.observeQuery("QueryGameState", time.Now())
+	state := make(map[string]mapper.MessagePayload)
+
+	a.Debug(DebugDB, "query of saved game state")
+	rows, err := a.sqldb.Query(`select eventkey, rawdata from gamestate`)
+	if err != nil {
+		return state, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, rawdata string
+
+		if err := rows.Scan(&key, &rawdata); err != nil {
+			return state, err
+		}
+		p, err := mapper.ParseMessage(rawdata)
+		if err != nil {
+			a.Logf("unable to understand saved game state %s (ignored): %v", key, err)
+			continue
+		}
+		switch p.MessageType() {
+		case mapper.ERROR:
+			a.Logf("error in saved game state %s (ignored): %v", key, p.(mapper.ErrorMessagePayload).Error)
+			continue
+		case mapper.UNKNOWN, mapper.Comment:
+			a.Logf("found invalid saved game state %s (ignored): %s", key, rawdata)
+			continue
+		}
+		state[key] = p
+		a.Debugf(DebugDB, "result: %s=%s", key, rawdata)
+	}
+	return state, rows.Err()
+}
+
+// QueryCoreStatus retrieves what the server has recorded about the core database
+// entries of a given type, as a map of entry codes to their status.
+func (a *Application) QueryCoreStatus(typeName string) (map[string]coreEntry, error) {
+	defer a.observeQuery("QueryCoreStatus", time.Now())
+	status := make(map[string]coreEntry)
+
+	a.Debugf(DebugDB, "query of core data status for type %s", typeName)
+	rows, err := a.sqldb.Query(`select code, name, islocal, hidden, modified from corestatus where type=?`, typeName)
+	if err != nil {
+		return status, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e coreEntry
+		var modified int64
+
+		if err := rows.Scan(&e.Code, &e.Name, &e.IsLocal, &e.IsHidden, &modified); err != nil {
+			return status, err
+		}
+		e.Modified = time.Unix(modified, 0)
+		status[e.Code] = e
+	}
+	return status, rows.Err()
+}
+
+// StoreCoreStatus records the hidden status and modification time of a set of
+// core database entries of a given type.
+func (a *Application) StoreCoreStatus(typeName string, entries []coreEntry) error {
+	defer a.observeQuery("StoreCoreStatus", time.Now())
+	tx, err := a.sqldb.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, e := range entries {
+		if _, err := tx.Exec(`replace into corestatus (type, code, name, islocal, hidden, modified) values (?, ?, ?, ?, ?, ?)`,
+			typeName, e.Code, e.Name, e.IsLocal, e.IsHidden, e.Modified.Unix()); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	a.Debugf(DebugDB, "updated status of %d %s %s", len(entries), typeName, util.PluralizeString("entry", len(entries)))
+	return nil
+}
+
 func (a *Application) LogDatabaseContents() error {
 	a.Log("Database Contents:")
 
@@ -482,12 +920,27 @@ func (a *Application) LogDatabaseContents() error {
 	if err := dumpTable("dice presets", "dicepresets", "user", "name", "description", "rollspec"); err != nil {
 		return err
 	}
+	if err := dumpTable("dice variables", "dicevariables", "user", "name", "value"); err != nil {
+		return err
+	}
 	if err := dumpTable("chat history", "chats", "msgid", "msgtype", "rawdata"); err != nil {
 		return err
 	}
 	if err := dumpTable("images known", "images", "name", "zoom", "location", "islocal"); err != nil {
 		return err
 	}
+	if err := dumpTable("saved game state", "gamestate", "eventkey", "rawdata"); err != nil {
+		return err
+	}
+	if err := dumpTable("core data status", "corestatus", "type", "code", "name", "islocal", "hidden", "modified"); err != nil {
+		return err
+	}
+	if err := dumpTable("die-roll audit log", "dieaudit", "msgid", "rawdata"); err != nil {
+		return err
+	}
+	if err := dumpTable("random tables", "randomtables", "name", "description", "rollspec", "entries"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -495,6 +948,7 @@ func (a *Application) LogDatabaseContents() error {
 // Remove all stored image definitions matching a regular expression
 //
 func (a *Application) FilterImages(f mapper.FilterImagesMessagePayload) error {
+	defer a.observeQuery("FilterImages", time.Now())
 	var namesToDelete []string
 
 	if f.KeepMatching {
@@ -50,8 +50,43 @@ To guard against nuisance or malicious port scans and other superfluous connecti
 (In actual production use, we have observed some automated agents which connected and then sat idle for hours, if we didn’t terminate their connections. This prevents that.)
 
 Usage:
-   server [-cpuprofile path] [−debug flags] [−endpoint [hostname]:port] [-help] [−init−file path]
-          [−log−file path] [−password−file path] −sqlite path [−telemetry−log path] [-telemetry-name name]
+   server [-admin-socket path] [-campaign name=dir[,[hostname]:port]] [-config path] [-coredb path] [-cpuprofile path] [−debug flags] [−endpoint [hostname]:port] [-help] [−init−file path]
+          [-journal path] [−log−file path] [-map-dir path] [-metrics-endpoint [hostname]:port] [−password−file path]
+          [-reset-state] [-save-interval duration]
+          −sqlite path [−telemetry−log path] [-telemetry-name name]
+          [-tls-cert path -tls-key path [-tls-client-ca path] [-tls-require-client-cert]]
+          [-undo-limit n] [-websocket-endpoint [hostname]:port]
+
+   -admin-socket path
+      Accept administrative commands (as sent by the server-admin program) on a
+      Unix-domain socket at the given path. The socket is accessible only to the user
+      running the server.
+
+   -campaign name=dir[,[hostname]:port]
+      Host an additional campaign called name alongside the main one. Each campaign
+      has its own game state, clients, and chat history. The campaign's database is
+      kept in dir/game.db; if dir/init, dir/passwords, or dir/maps exist, they serve
+      as the campaign's -init-file, -password-file, and -map-dir. If an endpoint is
+      given, the server also accepts connections for that campaign there. Clients on
+      the main endpoint may ask to join the campaign by name when they log in, if the
+      main campaign requires a password. This option may be repeated.
+
+   -config path
+      Read the server's settings from the named JSON configuration file. This may
+      give any of the settings otherwise supplied by command-line options (which
+      override the file), as well as the QoS limits, allowed client versions, status
+      markers, and world settings otherwise given in the -init-file. The file is
+      checked for errors when it is loaded. When the server receives a SIGHUP, it
+      rereads this file (along with the -init-file and -password-file) and puts the
+      new settings into effect without disconnecting any clients; if the file has
+      errors, the server keeps its current settings instead.
+
+   -coredb path
+      Answer client CORE and COREIDX queries from the GMA core database in the
+      specified file, which the server opens read-only. Without this option, the server
+      replies to all such queries as though it found nothing. The GM may hide entries
+      from players with the CORE/ command; the server remembers which entries are hidden,
+      and when each entry was last changed, in its own database.
 
    -debug flags
       Add debugging information to the log file. The flags value is a comma-separated
@@ -76,10 +111,26 @@ Usage:
       Initialization file which controls the initial client negotiation upon first
       connection to the server.
 
+   -journal path
+      Append a timestamped journal of the messages passing through the server to the
+      specified file (which may contain the same % tokens as -log-file). The replay
+      program can list the journal or play it back later.
+
    -log-file path
       Write a log of server actions to the specified file. (Default "-", which means
       to send to standard output.)
 
+   -map-dir path
+      Allow the GM to save the current map to map files in this directory, list the
+      maps saved there, and load one of them into the game state (which sends it to
+      all clients). Each map is kept in a file named for it with a .map suffix.
+
+   -metrics-endpoint [hostname]:port
+      Serve operational statistics over HTTP at /metrics on this endpoint, in the
+      Prometheus text format: connected clients, messages received and sent by type,
+      die rolls by user, QoS violations, database latencies, and ping lag. These are
+      served without authentication, so use a local or otherwise private endpoint.
+
    -password-file path
       Enable server authentication with the set of passwords in the specified file.
       Each line of the file holds a plaintext password, in the following format:
@@ -89,6 +140,19 @@ Usage:
           user2:password2
           user3:password3
       Only the first line is required.
+      Alternatively, the file may hold salted hashes of the passwords instead of the
+      passwords themselves. Such files are created and maintained with server-passwd,
+      which can also convert a plaintext password file to the hashed form.
+
+   -reset-state
+      Start with an empty game state instead of restoring the game state which was
+      saved in the database when the server last ran.
+
+   -save-interval duration
+      Save a checkpoint of the current game state to the database this often, so that
+      it can be restored if the server is restarted. The duration is given in a form
+      such as "30s", "5m", or "1h". If this is "0", the game state is only saved when
+      the server shuts down. (Default "1m")
 
    -cpuprofile path
       Enables CPU profiling, saving sampled performance data to the named path, which can
@@ -96,8 +160,8 @@ Usage:
 
    -sqlite path
       Specifies the file name of a sqlite database used to keep persistent data used
-      by the server. If path does not exist, server will create a new database with that
-      name.
+      by the server, including the saved game state. If path does not exist, server
+      will create a new database with that name.
 
    -telemetry-log path
       If server was compiled to send performance telemetry data, a debugging log of that
@@ -110,6 +174,34 @@ Usage:
 	  You can also accomplish this by setting the NEW_RELIC_APP_NAME
 	  environment variable.
 
+   -tls-cert path
+   -tls-key path
+      Accept only TLS-encrypted client connections, using the server certificate and
+      private key stored in the named PEM files. Both options must be given together.
+
+   -tls-client-ca path
+      Accept client certificates issued by the certificate authorities in the named
+      PEM file. A client which presents such a certificate is known to the server by
+      the common name in the certificate; if it also logs in with a password, it must
+      use that same user name (unless logging in as the GM).
+
+   -tls-require-client-cert
+      Refuse clients which don't present a certificate issued by one of the authorities
+      given with -tls-client-ca.
+
+   -undo-limit n
+      Remember the last n changes made to the map (loading, clearing, or placing
+      objects and changing their attributes) so that the GM can undo them with the
+      UNDO command (and redo them with REDO). If n is 0, undo is disabled. (Default 100)
+
+   -websocket-endpoint [hostname]:port
+      In addition to the usual endpoint, accept clients connecting via WebSocket
+      (e.g., browser-based clients) on the specified port. Each WebSocket frame carries
+      one line of the server protocol (without the trailing newline). These clients
+      authenticate and are treated exactly the same as any other. If the server is
+      using TLS, this port will also require TLS (i.e., clients must connect to a
+      wss:// URL).
+
 See the full documentation in the accompanying manual file man/man6/server.6.pdf (or run “gma man go server” if you have the GMA Core package installed as well as Go-GMA).
 
 See also the server protocol specification in the man/man7/mapper-protocol.7.pdf of the GMA-Mapper package (or run “gma man mapper-protocol”). This is also printed in Appendix F of the GMA Game Master's Guide.
@@ -118,8 +210,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime/pprof"
@@ -128,6 +222,7 @@ import (
 
 	"github.com/MadScienceZone/go-gma/v5/mapper"
 	"github.com/newrelic/go-agent/v3/newrelic"
+	"golang.org/x/net/websocket"
 )
 
 //
@@ -136,6 +231,9 @@ import (
 
 const GoVersionNumber="5.26.0" // @@##@@
 
+// How long we give a new client to complete the TLS handshake.
+const tlsHandshakeTimeout = 30 * time.Second
+
 //
 // eventMonitor responds to signals and timers that affect our overall operation
 // independent of client requests.
@@ -150,22 +248,35 @@ func eventMonitor(sigChan chan os.Signal, stopChan chan int, app *Application) {
 			app.Logf("received signal %v", s)
 			switch s {
 			case syscall.SIGHUP:
-				app.Debug(DebugEvents, "SIGHUP; dropping all connected clients")
-				app.DropAllClients()
+				if app.ConfigFile == "" {
+					app.Debug(DebugEvents, "SIGHUP; dropping all connected clients")
+					for _, c := range app.allCampaigns() {
+						c.DropAllClients()
+					}
+					break
+				}
+				app.Debug(DebugEvents, "SIGHUP; reloading configuration file")
+				for _, c := range app.allCampaigns() {
+					if err := c.ReloadConfigFile(); err != nil {
+						c.Logf("ERROR: %v", err)
+					}
+				}
 
 			case syscall.SIGUSR1:
 				app.Debug(DebugEvents, "SIGUSR1; reloading configuration data")
-				app.clientPreamble.reload <- 0
-				if err := app.refreshAuthenticator(); err != nil {
-					app.Logf("WARNING: authenticator initialization file reload failed: %v", err)
-					app.Log("WARNING: client credentials may be incomplete or incorrect now")
+				for _, c := range app.allCampaigns() {
+					if err := c.ReloadConfiguration(); err != nil {
+						c.Logf("WARNING: authenticator initialization file reload failed: %v", err)
+						c.Log("WARNING: client credentials may be incomplete or incorrect now")
+					}
 				}
-				app.MessageIDReset <- 0
 
 			case syscall.SIGUSR2:
 				app.Debug(DebugEvents, "SIGUSR2 (dump database out to logfile)")
-				if err := app.LogDatabaseContents(); err != nil {
-					app.Logf("Error dumping database: %v", err)
+				for _, c := range app.allCampaigns() {
+					if err := c.LogDatabaseContents(); err != nil {
+						c.Logf("Error dumping database: %v", err)
+					}
 				}
 
 			case syscall.SIGINT:
@@ -183,8 +294,10 @@ func eventMonitor(sigChan chan os.Signal, stopChan chan int, app *Application) {
 
 		case <-ping_signal.C:
 			app.Debug(DebugEvents, "ping timer expired")
-			app.LastPing = time.Now()
-			app.SendToAll(mapper.Marco, nil)
+			for _, c := range app.allCampaigns() {
+				c.LastPing = time.Now()
+				c.SendToAll(mapper.Marco, nil)
+			}
 		}
 	}
 }
@@ -250,7 +363,6 @@ func main() {
 	go generateMessageIDs(app.Logf, app.MessageIDGenerator, app.MessageIDReset)
 	go app.managePreambleData()
 	go app.manageClientList()
-	go app.manageGameState()
 	go app.announceClients()
 
 	/* instrumentation */
@@ -292,20 +404,68 @@ func main() {
 		os.Exit(1)
 	}
 	defer app.dbClose()
+	if err := app.coreOpen(); err != nil {
+		app.Logf("unable to open core database: %v", err)
+		os.Exit(1)
+	}
+	defer app.coreClose()
+	go app.manageGameState()
+
+	if err := app.startCampaigns(); err != nil {
+		app.Log(err)
+		os.Exit(2)
+	}
 
 	// start listening to incoming port
-	incoming, err := net.Listen("tcp", app.Endpoint)
+	incoming, err := app.listen(app.Endpoint)
 	if err != nil {
-		app.Logf("unable to open incoming TCP %s: %v", app.Endpoint, err)
+		app.Log(err)
 		os.Exit(2)
 	}
-	app.Logf("Listening on %s", app.Endpoint)
 	defer func() {
 		if err := incoming.Close(); err != nil {
 			app.Logf("failure closing incoming socket: %v", err)
 		}
 	}()
 
+	if app.WebSocketEndpoint != "" {
+		wsIncoming, err := net.Listen("tcp", app.WebSocketEndpoint)
+		if err != nil {
+			app.Logf("unable to open incoming WebSocket endpoint %s: %v", app.WebSocketEndpoint, err)
+			os.Exit(2)
+		}
+		if app.TLSConfig != nil {
+			app.Logf("Listening for WebSocket clients on %s (TLS)", app.WebSocketEndpoint)
+		} else {
+			app.Logf("Listening for WebSocket clients on %s", app.WebSocketEndpoint)
+		}
+		go acceptWebSocketConnections(wsIncoming, &app)
+	}
+
+	if app.MetricsEndpoint != "" {
+		metricsIncoming, err := net.Listen("tcp", app.MetricsEndpoint)
+		if err != nil {
+			app.Logf("unable to open metrics endpoint %s: %v", app.MetricsEndpoint, err)
+			os.Exit(2)
+		}
+		app.Logf("Reporting statistics on %s", app.MetricsEndpoint)
+		go serveMetrics(metricsIncoming, &app)
+	}
+
+	if app.AdminSocket != "" {
+		adminIncoming, err := app.listenForAdmin()
+		if err != nil {
+			app.Log(err)
+			os.Exit(2)
+		}
+		defer func() {
+			if err := adminIncoming.Close(); err != nil {
+				app.Logf("failure closing administrative socket: %v", err)
+			}
+		}()
+		go acceptAdminConnections(adminIncoming, &app)
+	}
+
 	sigChannel := make(chan os.Signal, 1)
 	stopChannel := make(chan int, 1)
 	signal.Notify(sigChannel, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGINT)
@@ -316,6 +476,15 @@ func main() {
 
 	<-stopChannel
 	app.Log("received STOP signal; shutting down")
+	if err := app.SaveGameState(); err != nil {
+		app.Logf("unable to save game state: %v", err)
+	}
+	app.stopCampaigns()
+	if app.Journal != nil {
+		if err := app.Journal.Close(); err != nil {
+			app.Logf("error closing session journal: %v", err)
+		}
+	}
 	app.Log("server shut down")
 }
 
@@ -328,32 +497,80 @@ func acceptIncomingConnections(incoming net.Listener, app *Application) {
 			continue
 		}
 		app.Debugf(DebugIO, "client connected from %v", client.RemoteAddr())
+		go startClientSession(client, app)
+	}
+}
 
-		auth, err := app.newClientAuthenticator("")
-		if err != nil {
-			app.Logf("unable to set up client authentication: %v", err)
-			client.Close()
-			continue
-		}
+// acceptWebSocketConnections serves HTTP (or HTTPS if we're using TLS) on
+// the incoming listener, accepting WebSocket connections from clients and
+// serving them exactly as we do clients connected to our main endpoint.
+// WebSocket clients send and receive one protocol message per frame.
+func acceptWebSocketConnections(incoming net.Listener, app *Application) {
+	server := &http.Server{
+		Handler: websocket.Server{
+			Handler: func(ws *websocket.Conn) {
+				client := mapper.NewWebSocketConn(ws)
+				app.Debugf(DebugIO, "WebSocket client connected from %v", client.RemoteAddr())
+				startClientSession(client, app)
+			},
+		},
+		TLSConfig: app.TLSConfig,
+		// WebSockets can't be carried over HTTP/2, so don't offer it.
+		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler)),
+	}
 
-		ourDebugFlags := DebugFlagNameSlice(app.DebugLevel)
-		debugFlags, _ := mapper.NamedDebugFlags(ourDebugFlags...)
+	var err error
+	if app.TLSConfig != nil {
+		err = server.ServeTLS(incoming, "", "")
+	} else {
+		err = server.Serve(incoming)
+	}
+	app.Logf("WebSocket listener stopped: %v", err)
+}
 
-		newConnection, err := mapper.NewClientConnection(client,
-			mapper.WithServer(app),
-			mapper.WithClientDebuggingLevel(debugFlags),
-			mapper.WithClientAuthenticator(auth),
-			mapper.WithQoSLogWindow(app.QoSLimits.Log.window),
-			mapper.WithQoSMessageRateLimit(app.QoSLimits.MessageRate.Count, app.QoSLimits.MessageRate.window),
-			mapper.WithQoSQueryImageLimit(app.QoSLimits.QueryImage.Count, app.QoSLimits.QueryImage.window),
-		)
+// startClientSession completes the TLS handshake with a newly-connected
+// client (if applicable) and begins serving them. This is done in its own
+// goroutine so a slow client doesn't hold up accepting the others.
+func startClientSession(client net.Conn, app *Application) {
+	if tc, ok := client.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), tlsHandshakeTimeout)
+		err := tc.HandshakeContext(ctx)
+		cancel()
 		if err != nil {
-			app.Logf("unable to initialize client session: %v", err)
+			app.Logf("TLS handshake with %v failed: %v", client.RemoteAddr(), err)
 			client.Close()
-			continue
+			return
 		}
-		go newConnection.ServeToClient(context.Background(), app.ServerStarted, app.LastPing, app.NrApp)
 	}
+
+	auth, err := app.newClientAuthenticator("")
+	if err != nil {
+		app.Logf("unable to set up client authentication: %v", err)
+		client.Close()
+		return
+	}
+
+	ourDebugFlags := DebugFlagNameSlice(app.currentDebugLevel())
+	debugFlags, _ := mapper.NamedDebugFlags(ourDebugFlags...)
+
+	options := []mapper.ClientConnectionOption{
+		mapper.WithServer(app),
+		mapper.WithClientDebuggingLevel(debugFlags),
+		mapper.WithClientAuthenticator(auth),
+		mapper.WithQoSLogWindow(app.QoSLimits.Log.window),
+		mapper.WithQoSMessageRateLimit(app.QoSLimits.MessageRate.Count, app.QoSLimits.MessageRate.window),
+		mapper.WithQoSQueryImageLimit(app.QoSLimits.QueryImage.Count, app.QoSLimits.QueryImage.window),
+	}
+	if app.metrics != nil {
+		options = append(options, mapper.WithClientMetrics(app))
+	}
+	newConnection, err := mapper.NewClientConnection(client, options...)
+	if err != nil {
+		app.Logf("unable to initialize client session: %v", err)
+		client.Close()
+		return
+	}
+	newConnection.ServeToClient(context.Background(), app.ServerStarted, app.LastPing, app.NrApp)
 }
 
 // @[00]@| Go-GMA 5.26.0
@@ -0,0 +1,464 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Saving the game state to map files in the server's own storage area,
+// and loading those files back into the game state, at the GM's request.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	"github.com/MadScienceZone/go-gma/v5/util"
+)
+
+// MapFileSuffix is added to the names of the map files we keep.
+const MapFileSuffix = ".map"
+
+// validMapName matches the names the GM may give to saved maps. Since these
+// become file names in our map directory, they may not include path separators
+// or start with a dot.
+var validMapName = regexp.MustCompile(`^[\p{L}\p{N}_][\p{L}\p{N}_.,+-]*$`)
+
+// mapFilePath returns the pathname for the saved map with the given name,
+// or an error suitable for reporting back to the client.
+func (a *Application) mapFilePath(name string) (string, error) {
+	if a.MapDirectory == "" {
+		return "", fmt.Errorf("This server is not configured to store map files.")
+	}
+	if !validMapName.MatchString(name) {
+		return "", fmt.Errorf("\"%s\" is not a valid map name. Use only letters, digits, and the characters _.,+- (not starting with a dot).", name)
+	}
+	return filepath.Join(a.MapDirectory, name+MapFileSuffix), nil
+}
+
+// mapFileRequestAllowed checks that the requester is the GM, sending them a PRIV
+// response if they are not.
+func (a *Application) mapFileRequestAllowed(requester *mapper.ClientConnection, p mapper.MessagePayload) bool {
+	if requester == nil || requester.Auth == nil || !requester.Auth.GmMode {
+		a.Logf("refusing to execute privileged command %v for non-GM user", p.MessageType())
+		requester.Conn.Send(mapper.Priv, mapper.PrivMessagePayload{
+			Command: p.RawMessage(),
+			Reason:  "You are not the GM.",
+		})
+		return false
+	}
+	return true
+}
+
+// mapFileRequestFailed tells the requester why we could not carry out their request.
+func (a *Application) mapFileRequestFailed(requester *mapper.ClientConnection, p mapper.MessagePayload, requestID string, err error) {
+	a.Logf("unable to complete request %s: %v", p.RawMessage(), err)
+	requester.Conn.Send(mapper.Failed, mapper.FailedMessagePayload{
+		IsError:   true,
+		Command:   p.RawMessage(),
+		Reason:    err.Error(),
+		RequestID: requestID,
+	})
+}
+
+// SaveMapFile writes the map as it currently stands in the game state to the
+// named map file, returning a description of the saved map.
+func (a *Application) SaveMapFile(name, location, comment string) (mapper.SavedMap, error) {
+	path, err := a.mapFilePath(name)
+	if err != nil {
+		return mapper.SavedMap{}, err
+	}
+	state, err := a.DumpGameState()
+	if err != nil {
+		return mapper.SavedMap{}, fmt.Errorf("unable to read the game state: %v", err)
+	}
+	objects, err := a.mapFileObjects(state)
+	if err != nil {
+		return mapper.SavedMap{}, err
+	}
+
+	// Write to a temporary file first so that we don't damage an existing
+	// map of the same name if something goes wrong.
+	meta := mapper.MapMetaData{Location: location, Comment: comment}
+	temp := path + ".new"
+	if err := mapper.WriteMapFile(temp, objects, meta); err != nil {
+		os.Remove(temp)
+		return mapper.SavedMap{}, fmt.Errorf("unable to write map file: %v", err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return mapper.SavedMap{}, fmt.Errorf("unable to write map file: %v", err)
+	}
+	if meta, err = mapper.ReadMapMetaData(path); err != nil {
+		return mapper.SavedMap{}, fmt.Errorf("unable to read back saved map file: %v", err)
+	}
+	a.Logf("saved %d map %s to \"%s\"", len(objects), util.PluralizeString("object", len(objects)), path)
+	return mapper.SavedMap{Name: name, MapMetaData: meta}, nil
+}
+
+// mapFileObjects converts a dump of the game state into the list of objects
+// to be written to a map file. Each object is written as it currently
+// stands, with all the changes made to its attributes since it was placed
+// on the map. The definitions of the images used by map tiles are included
+// so the map file is complete in itself.
+func (a *Application) mapFileObjects(state map[string]string) ([]any, error) {
+	history := make(map[string]*mapper.MessagePayload)
+	for key, line := range state {
+		p, err := mapper.ParseMessage(line)
+		if err != nil {
+			return nil, fmt.Errorf("unable to understand game state entry %s: %v", key, err)
+		}
+		history[key] = &p
+	}
+
+	// Write the objects in a consistent order (files first, then the
+	// objects by ID, just as a SYNC sends them), so that saving the same
+	// map twice produces the same file.
+	keys := make([]string, 0, len(history))
+	for key := range history {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		oi, oj := restoreOrder(keys[i]), restoreOrder(keys[j])
+		if oi != oj {
+			return oi < oj
+		}
+		return keys[i] < keys[j]
+	})
+
+	var objects []any
+	images := make(map[string]bool)
+	for _, key := range keys {
+		e := history[key]
+		switch {
+		case strings.HasPrefix(key, "lsf:"), strings.HasPrefix(key, "llf:"):
+			if p, ok := (*e).(mapper.LoadFromMessagePayload); ok {
+				objects = append(objects, p.FileDefinition)
+			}
+
+		case strings.HasPrefix(key, "new:"):
+			obj, err := currentMapObject(history, strings.TrimPrefix(key, "new:"), *e)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, obj)
+			if tile, ok := obj.(mapper.TileElement); ok && tile.Image != "" {
+				images[tile.Image] = true
+			}
+		}
+	}
+
+	imageNames := make([]string, 0, len(images))
+	for name := range images {
+		imageNames = append(imageNames, name)
+	}
+	sort.Strings(imageNames)
+	for _, name := range imageNames {
+		image, err := a.QueryImageData(mapper.ImageDefinition{Name: name})
+		if err != nil {
+			a.Logf("unable to look up image \"%s\" to save with map: %v", name, err)
+			continue
+		}
+		if len(image.Sizes) > 0 {
+			objects = append(objects, image)
+		}
+	}
+	return objects, nil
+}
+
+// currentMapObject returns the map object created by the message e, updated with
+// any changes made to its attributes since then, as recorded in the game state history.
+func currentMapObject(history map[string]*mapper.MessagePayload, objID string, e mapper.MessagePayload) (any, error) {
+	var obj any
+	switch p := e.(type) {
+	case mapper.LoadArcObjectMessagePayload:
+		obj = &p.ArcElement
+	case mapper.LoadCircleObjectMessagePayload:
+		obj = &p.CircleElement
+	case mapper.LoadLineObjectMessagePayload:
+		obj = &p.LineElement
+	case mapper.LoadPolygonObjectMessagePayload:
+		obj = &p.PolygonElement
+	case mapper.LoadRectangleObjectMessagePayload:
+		obj = &p.RectangleElement
+	case mapper.LoadSpellAreaOfEffectObjectMessagePayload:
+		obj = &p.SpellAreaOfEffectElement
+	case mapper.LoadTextObjectMessagePayload:
+		obj = &p.TextElement
+	case mapper.LoadTileObjectMessagePayload:
+		obj = &p.TileElement
+	case mapper.PlaceSomeoneMessagePayload:
+		obj = &p.CreatureToken
+	default:
+		return nil, fmt.Errorf("game state entry for %s is an unexpected %T", objID, e)
+	}
+
+	// The attribute names used by OA, OA+, and OA- messages are the JSON
+	// field names of the objects, so we apply them by way of JSON.
+	// Clients don't always send values of the right type (e.g., numbers
+	// may be sent as strings), so we're a bit forgiving about that.
+	changes := make(map[string]any)
+	if m, ok := history["mod:"+objID]; ok {
+		if mod, ok := (*m).(mapper.UpdateObjAttributesMessagePayload); ok {
+			for attrName, value := range mod.NewAttrs {
+				changes[attrName] = value
+			}
+		}
+	}
+	for key := range history {
+		if strings.HasPrefix(key, "add:"+objID+":") || strings.HasPrefix(key, "del:"+objID+":") {
+			attrName := key[len("add:"+objID+":"):]
+			changes[attrName], _ = objectListAttribute(history, objID, attrName)
+		}
+	}
+	for attrName, value := range changes {
+		if err := setObjectAttribute(obj, attrName, value); err != nil {
+			return nil, fmt.Errorf("unable to set %s attribute of %s to %v: %v", attrName, objID, value, err)
+		}
+	}
+
+	switch o := obj.(type) {
+	case *mapper.ArcElement:
+		return *o, nil
+	case *mapper.CircleElement:
+		return *o, nil
+	case *mapper.LineElement:
+		return *o, nil
+	case *mapper.PolygonElement:
+		return *o, nil
+	case *mapper.RectangleElement:
+		return *o, nil
+	case *mapper.SpellAreaOfEffectElement:
+		return *o, nil
+	case *mapper.TextElement:
+		return *o, nil
+	case *mapper.TileElement:
+		return *o, nil
+	case *mapper.CreatureToken:
+		return *o, nil
+	}
+	return nil, fmt.Errorf("unable to save %s", objID)
+}
+
+// setObjectAttribute changes the value of the named attribute (JSON field) of obj.
+// If the value is a string but the attribute isn't, we try to interpret the
+// string as a value of the attribute's type.
+func setObjectAttribute(obj any, attrName string, value any) error {
+	data, err := json.Marshal(map[string]any{attrName: value})
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, obj); err == nil {
+		return nil
+	}
+	str, isString := value.(string)
+	if !isString {
+		return err
+	}
+	if b, berr := strconv.ParseBool(str); berr == nil {
+		if data, berr = json.Marshal(map[string]any{attrName: b}); berr == nil && json.Unmarshal(data, obj) == nil {
+			return nil
+		}
+	}
+	if json.Valid([]byte(str)) {
+		data = []byte(fmt.Sprintf("{%q:%s}", attrName, str))
+		if json.Unmarshal(data, obj) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// ListMapFiles describes the maps saved in our map directory, in order by name.
+func (a *Application) ListMapFiles() ([]mapper.SavedMap, error) {
+	if a.MapDirectory == "" {
+		return nil, fmt.Errorf("This server is not configured to store map files.")
+	}
+	entries, err := os.ReadDir(a.MapDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read map directory: %v", err)
+	}
+
+	var maps []mapper.SavedMap
+	for _, entry := range entries {
+		name, isMap := strings.CutSuffix(entry.Name(), MapFileSuffix)
+		if !isMap || entry.IsDir() || !validMapName.MatchString(name) {
+			continue
+		}
+		meta, err := mapper.ReadMapMetaData(filepath.Join(a.MapDirectory, entry.Name()))
+		if err != nil {
+			a.Logf("skipping unreadable map file \"%s\": %v", entry.Name(), err)
+			continue
+		}
+		maps = append(maps, mapper.SavedMap{Name: name, MapMetaData: meta})
+	}
+	sort.Slice(maps, func(i, j int) bool { return maps[i].Name < maps[j].Name })
+	return maps, nil
+}
+
+// LoadMapFile reads the named map file into the game state and sends its
+// contents to all clients. Unless merge is true, the map is cleared first.
+// The changes made to the map are undone together as a single change.
+func (a *Application) LoadMapFile(name string, merge bool) (mapper.SavedMap, error) {
+	path, err := a.mapFilePath(name)
+	if err != nil {
+		return mapper.SavedMap{}, err
+	}
+	objects, meta, err := mapper.ReadMapFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return mapper.SavedMap{}, fmt.Errorf("There is no saved map called \"%s\".", name)
+		}
+		return mapper.SavedMap{}, fmt.Errorf("unable to read map file: %v", err)
+	}
+
+	var images []mapper.AddImageMessagePayload
+	var events []mapper.MessagePayload
+	if !merge {
+		events = append(events, mapper.ClearMessagePayload{ObjID: "*"})
+	}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case mapper.ImageDefinition:
+			images = append(images, mapper.AddImageMessagePayload{ImageDefinition: o})
+		case mapper.FileDefinition:
+			// we're sending everything else in the map ourselves, so the
+			// clients must not clear it when they load this file.
+			events = append(events, mapper.LoadFromMessagePayload{FileDefinition: o, Merge: true})
+		case mapper.ArcElement:
+			events = append(events, mapper.LoadArcObjectMessagePayload{ArcElement: o})
+		case mapper.CircleElement:
+			events = append(events, mapper.LoadCircleObjectMessagePayload{CircleElement: o})
+		case mapper.LineElement:
+			events = append(events, mapper.LoadLineObjectMessagePayload{LineElement: o})
+		case mapper.PolygonElement:
+			events = append(events, mapper.LoadPolygonObjectMessagePayload{PolygonElement: o})
+		case mapper.RectangleElement:
+			events = append(events, mapper.LoadRectangleObjectMessagePayload{RectangleElement: o})
+		case mapper.SpellAreaOfEffectElement:
+			events = append(events, mapper.LoadSpellAreaOfEffectObjectMessagePayload{SpellAreaOfEffectElement: o})
+		case mapper.TextElement:
+			events = append(events, mapper.LoadTextObjectMessagePayload{TextElement: o})
+		case mapper.TileElement:
+			events = append(events, mapper.LoadTileObjectMessagePayload{TileElement: o})
+		case mapper.CreatureToken:
+			events = append(events, mapper.PlaceSomeoneMessagePayload{CreatureToken: o})
+		default:
+			a.Logf("ignoring unsupported %T object in map file \"%s\"", obj, path)
+		}
+	}
+
+	for _, image := range images {
+		for _, instance := range image.Sizes {
+			if err := a.StoreImageData(image.Name, instance, image.Animation); err != nil {
+				a.Logf("error storing image data for \"%s\"@%v: %v", image.Name, instance.Zoom, err)
+			}
+		}
+		if err := a.SendToAll(mapper.AddImage, image); err != nil {
+			a.Logf("error sending AddImage to clients: %v", err)
+		}
+	}
+	a.UpdateGameStateTogether(events)
+	for _, e := range events {
+		if err := a.SendToAll(gameStateMessageType(e), e); err != nil {
+			a.Logf("error sending %v to clients: %v", gameStateMessageType(e), err)
+		}
+	}
+	a.Logf("loaded %d map %s from \"%s\"", len(objects), util.PluralizeString("object", len(objects)), path)
+	return mapper.SavedMap{Name: name, MapMetaData: meta}, nil
+}
+
+// handleMapFileRequest carries out a GM's request to save, list, or load map files,
+// replying with an UpdateMaps message describing the map(s) involved.
+func (a *Application) handleMapFileRequest(requester *mapper.ClientConnection, p mapper.MessagePayload) {
+	if !a.mapFileRequestAllowed(requester, p) {
+		return
+	}
+
+	var reply mapper.UpdateMapsMessagePayload
+	var err error
+	switch r := p.(type) {
+	case mapper.SaveMapMessagePayload:
+		reply.RequestID = r.RequestID
+		var saved mapper.SavedMap
+		if saved, err = a.SaveMapFile(r.Name, r.Location, r.Comment); err == nil {
+			reply.Maps = []mapper.SavedMap{saved}
+		}
+	case mapper.QueryMapsMessagePayload:
+		reply.RequestID = r.RequestID
+		reply.Maps, err = a.ListMapFiles()
+	case mapper.LoadMapMessagePayload:
+		reply.RequestID = r.RequestID
+		var loaded mapper.SavedMap
+		if loaded, err = a.LoadMapFile(r.Name, r.Merge); err == nil {
+			reply.Maps = []mapper.SavedMap{loaded}
+		}
+	default:
+		err = fmt.Errorf("unexpected map file request %T", p)
+	}
+	if err != nil {
+		a.mapFileRequestFailed(requester, p, reply.RequestID, err)
+		return
+	}
+	requester.Conn.Send(mapper.UpdateMaps, reply)
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,112 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for saving the game state to map files
+//
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+func TestMapFileObjectOrder(t *testing.T) {
+	a := startTestGameState(t, 10)
+	for _, e := range []mapper.MessagePayload{
+		mapper.LoadFromMessagePayload{FileDefinition: mapper.FileDefinition{File: "b"}},
+		testCircle("e3", 0, 0),
+		testCreature("c1", "goblin", 1, 1, 2),
+		mapper.LoadFromMessagePayload{FileDefinition: mapper.FileDefinition{File: "a"}},
+		testCircle("e2", 1, 1),
+		testCreature("c2", "Fred", 2, 3, 4),
+		mapper.UpdateObjAttributesMessagePayload{ObjID: "c1", NewAttrs: map[string]any{"Gx": 7.0}},
+	} {
+		a.UpdateGameStateTogether([]mapper.MessagePayload{e})
+	}
+	state := dumpTestGameState(t, a)
+
+	expected := "file a, file b, c1 at 7, c2 at 3, e2, e3"
+	for i := 0; i < 20; i++ {
+		objects, err := a.mapFileObjects(state)
+		if err != nil {
+			t.Fatalf("unable to get map file objects: %v", err)
+		}
+		var order string
+		for _, obj := range objects {
+			if order != "" {
+				order += ", "
+			}
+			switch o := obj.(type) {
+			case mapper.FileDefinition:
+				order += "file " + o.File
+			case mapper.CreatureToken:
+				order += fmt.Sprintf("%s at %v", o.ID, o.Gx)
+			case mapper.CircleElement:
+				order += o.ID
+			default:
+				order += fmt.Sprintf("%T", obj)
+			}
+		}
+		if order != expected {
+			t.Fatalf("map file objects are %s, expected %s", order, expected)
+		}
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,303 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Operational statistics about the server, reported in the Prometheus
+// text exposition format on a local HTTP endpoint.
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+// Upper bounds of the histogram buckets for database query durations
+// and ping lag, in seconds.
+var (
+	databaseLatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+	pingLagBuckets         = []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+)
+
+// metricLabels identifies one time series of a metric. Every metric is
+// labelled with the campaign it pertains to, and most have one more label
+// whose meaning depends on the metric.
+type metricLabels struct {
+	campaign string
+	value    string
+}
+
+// histogram tracks the distribution of observed values.
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// metricsRegistry collects the statistics we report. It is shared by all
+// the campaigns hosted by the server, and is safe for concurrent use.
+type metricsRegistry struct {
+	lock            sync.Mutex
+	started         time.Time
+	received        map[metricLabels]uint64
+	sent            map[metricLabels]uint64
+	dieRolls        map[metricLabels]uint64
+	qosViolations   map[metricLabels]uint64
+	databaseLatency map[metricLabels]*histogram
+	pingLag         map[metricLabels]*histogram
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		started:         time.Now(),
+		received:        make(map[metricLabels]uint64),
+		sent:            make(map[metricLabels]uint64),
+		dieRolls:        make(map[metricLabels]uint64),
+		qosViolations:   make(map[metricLabels]uint64),
+		databaseLatency: make(map[metricLabels]*histogram),
+		pingLag:         make(map[metricLabels]*histogram),
+	}
+}
+
+func (m *metricsRegistry) count(counter map[metricLabels]uint64, labels metricLabels) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	counter[labels]++
+}
+
+func (m *metricsRegistry) observe(h map[metricLabels]*histogram, buckets []float64, labels metricLabels, v float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if h[labels] == nil {
+		h[labels] = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	}
+	h[labels].observe(v)
+}
+
+// messageTypeNames gives the names of the server message types, for
+// labelling the message counters.
+var messageTypeNames = func() map[mapper.ServerMessage]string {
+	names := make(map[mapper.ServerMessage]string)
+	for name, m := range mapper.ServerMessageByName {
+		names[m] = name
+	}
+	return names
+}()
+
+func messageTypeName(m mapper.ServerMessage) string {
+	if name, ok := messageTypeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", m)
+}
+
+// MessageReceived counts a message received from a client. We take the
+// arrival of a POLO as the client's answer to our most recent ping.
+func (a *Application) MessageReceived(m mapper.ServerMessage) {
+	if a.metrics == nil {
+		return
+	}
+	a.metrics.count(a.metrics.received, metricLabels{a.CampaignName, messageTypeName(m)})
+	if m == mapper.Polo {
+		a.metrics.observe(a.metrics.pingLag, pingLagBuckets, metricLabels{campaign: a.CampaignName}, time.Since(a.LastPing).Seconds())
+	}
+}
+
+// MessageSent counts a message sent to a client.
+func (a *Application) MessageSent(m mapper.ServerMessage) {
+	if a.metrics != nil {
+		a.metrics.count(a.metrics.sent, metricLabels{a.CampaignName, messageTypeName(m)})
+	}
+}
+
+// QoSViolation counts a client disconnected for exceeding a QoS limit.
+func (a *Application) QoSViolation(limit string) {
+	if a.metrics != nil {
+		a.metrics.count(a.metrics.qosViolations, metricLabels{a.CampaignName, limit})
+	}
+}
+
+// countDieRoll counts a die roll requested by the user.
+func (a *Application) countDieRoll(user string) {
+	if a.metrics != nil {
+		a.metrics.count(a.metrics.dieRolls, metricLabels{a.CampaignName, user})
+	}
+}
+
+// observeQuery records how long a database operation took, given the time
+// it started. This is intended to be deferred at the start of the operation.
+func (a *Application) observeQuery(operation string, start time.Time) {
+	if a.metrics != nil {
+		a.metrics.observe(a.metrics.databaseLatency, databaseLatencyBuckets, metricLabels{a.CampaignName, operation}, time.Since(start).Seconds())
+	}
+}
+
+// serveMetrics answers HTTP requests for /metrics on the incoming
+// listener until it is closed.
+func serveMetrics(incoming net.Listener, app *Application) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		app.writeMetrics(w)
+	})
+	server := &http.Server{Handler: mux}
+	if err := server.Serve(incoming); err != nil && !errors.Is(err, net.ErrClosed) {
+		app.Logf("metrics listener stopped: %v", err)
+	}
+}
+
+// writeMetrics writes all of our statistics to w.
+func (a *Application) writeMetrics(w io.Writer) {
+	m := a.metrics
+	clients := make(map[metricLabels]uint64)
+	for _, c := range a.allCampaigns() {
+		clients[metricLabels{campaign: c.CampaignName}] = uint64(len(c.GetClients()))
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	writeMetricHeader(w, "gma_server_start_time_seconds", "gauge", "Time the server started, in seconds since the Unix epoch.")
+	fmt.Fprintf(w, "gma_server_start_time_seconds %d\n", m.started.Unix())
+	writeGauges(w, "gma_clients_connected", "Number of clients connected.", "", clients)
+	writeCounters(w, "gma_messages_received_total", "Messages received from clients.", "type", m.received)
+	writeCounters(w, "gma_messages_sent_total", "Messages sent to clients.", "type", m.sent)
+	writeCounters(w, "gma_die_rolls_total", "Die rolls requested.", "user", m.dieRolls)
+	writeCounters(w, "gma_qos_violations_total", "Clients disconnected for exceeding a QoS limit.", "limit", m.qosViolations)
+	writeHistograms(w, "gma_database_query_duration_seconds", "Time taken by database operations.", "operation", m.databaseLatency)
+	writeHistograms(w, "gma_ping_lag_seconds", "Time between sending a ping to the clients and each client's reply.", "", m.pingLag)
+}
+
+func writeMetricHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// formatLabels renders the labels of a time series, given the name
+// of the second label (if it has one) and any extra labels to add.
+func formatLabels(labels metricLabels, valueLabel string, extra ...string) string {
+	list := []string{fmt.Sprintf("campaign=\"%s\"", escapeLabel(labels.campaign))}
+	if valueLabel != "" {
+		list = append(list, fmt.Sprintf("%s=\"%s\"", valueLabel, escapeLabel(labels.value)))
+	}
+	return "{" + strings.Join(append(list, extra...), ",") + "}"
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func sortedLabels[V any](series map[metricLabels]V) []metricLabels {
+	var keys []metricLabels
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].campaign != keys[j].campaign {
+			return keys[i].campaign < keys[j].campaign
+		}
+		return keys[i].value < keys[j].value
+	})
+	return keys
+}
+
+func writeGauges(w io.Writer, name, help, valueLabel string, series map[metricLabels]uint64) {
+	writeMetricHeader(w, name, "gauge", help)
+	for _, labels := range sortedLabels(series) {
+		fmt.Fprintf(w, "%s%s %d\n", name, formatLabels(labels, valueLabel), series[labels])
+	}
+}
+
+func writeCounters(w io.Writer, name, help, valueLabel string, series map[metricLabels]uint64) {
+	writeMetricHeader(w, name, "counter", help)
+	for _, labels := range sortedLabels(series) {
+		fmt.Fprintf(w, "%s%s %d\n", name, formatLabels(labels, valueLabel), series[labels])
+	}
+}
+
+func writeHistograms(w io.Writer, name, help, valueLabel string, series map[metricLabels]*histogram) {
+	writeMetricHeader(w, name, "histogram", help)
+	for _, labels := range sortedLabels(series) {
+		h := series[labels]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(labels, valueLabel, fmt.Sprintf("le=\"%g\"", bound)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(labels, valueLabel, "le=\"+Inf\""), h.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", name, formatLabels(labels, valueLabel), h.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", name, formatLabels(labels, valueLabel), h.count)
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
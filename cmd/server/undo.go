@@ -0,0 +1,357 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Undo and redo support for the game state. The game state manager
+// keeps a bounded history of the changes made to the map, each with the
+// messages needed to reverse it, so that the GM can back out mistakes
+// such as clearing the whole map.
+//
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	"golang.org/x/exp/slices"
+)
+
+// DefaultUndoLimit is the number of changes to the map which the server
+// remembers so they may be undone, unless overridden with -undo-limit.
+const DefaultUndoLimit = 100
+
+// undoRecord describes a single change to the map: the message(s) which made the
+// change and the messages which reverse it. It also holds the game state as it
+// was before the change, so undoing it puts back exactly what we had before
+// rather than whatever the inverse messages would leave us with.
+type undoRecord struct {
+	changes []mapper.MessagePayload
+	inverse []mapper.MessagePayload
+	history map[string]*mapper.MessagePayload
+	view    mapper.AdjustViewMessagePayload
+}
+
+// undoHistory holds the changes which may be undone (most recent last) and
+// those which have been undone and may be redone (most recently undone last).
+type undoHistory struct {
+	limit  int
+	done   []undoRecord
+	undone []undoRecord
+}
+
+// undoRequest is sent to the game state manager to undo (or redo) up to count
+// changes to the map. The manager replies with the result.
+type undoRequest struct {
+	count int
+	redo  bool
+	reply chan undoResult
+}
+
+// undoResult reports how many changes were undone or redone, and the messages
+// which must be sent to the clients so their maps agree with the game state.
+type undoResult struct {
+	changes  int
+	messages []mapper.MessagePayload
+}
+
+// push adds a new record to the list of changes which may be undone, discarding
+// the oldest if we have reached the limit.
+func (h *undoHistory) push(r undoRecord) {
+	if h.limit <= 0 {
+		return
+	}
+	if len(h.done) >= h.limit {
+		h.done = slices.Delete(h.done, 0, len(h.done)-h.limit+1)
+	}
+	h.done = append(h.done, r)
+}
+
+// copyHistory returns a copy of the game state's event history. The entries
+// themselves are never modified once recorded, so they may be shared.
+func copyHistory(history map[string]*mapper.MessagePayload) map[string]*mapper.MessagePayload {
+	c := make(map[string]*mapper.MessagePayload, len(history))
+	for k, e := range history {
+		c[k] = e
+	}
+	return c
+}
+
+// isUndoableChange returns true if the message is one of the kinds of map changes
+// we track in the undo history.
+func isUndoableChange(event mapper.MessagePayload) bool {
+	switch event.(type) {
+	case mapper.AddObjAttributesMessagePayload,
+		mapper.ClearMessagePayload,
+		mapper.ClearFromMessagePayload,
+		mapper.LoadArcObjectMessagePayload,
+		mapper.LoadCircleObjectMessagePayload,
+		mapper.LoadLineObjectMessagePayload,
+		mapper.LoadPolygonObjectMessagePayload,
+		mapper.LoadRectangleObjectMessagePayload,
+		mapper.LoadSpellAreaOfEffectObjectMessagePayload,
+		mapper.LoadTextObjectMessagePayload,
+		mapper.LoadTileObjectMessagePayload,
+		mapper.PlaceSomeoneMessagePayload,
+		mapper.RemoveObjAttributesMessagePayload,
+		mapper.UpdateObjAttributesMessagePayload:
+		return true
+	}
+	return false
+}
+
+// inverseOfChange returns the messages which reverse the effect of the given
+// change, based on the game state as it stands before the change is made.
+// This does not include the entries which the change removes from the
+// game state; see restoredEntries for those.
+func inverseOfChange(history map[string]*mapper.MessagePayload, event mapper.MessagePayload) []mapper.MessagePayload {
+	var inverse []mapper.MessagePayload
+
+	switch p := event.(type) {
+	case mapper.AddObjAttributesMessagePayload:
+		current, _ := objectListAttribute(history, p.ObjID, p.AttrName)
+		var added []string
+		for _, v := range p.Values {
+			if !slices.Contains(current, v) && !slices.Contains(added, v) {
+				added = append(added, v)
+			}
+		}
+		if len(added) > 0 {
+			inverse = append(inverse, mapper.RemoveObjAttributesMessagePayload{
+				ObjID:    p.ObjID,
+				AttrName: p.AttrName,
+				Values:   added,
+			})
+		}
+
+	case mapper.RemoveObjAttributesMessagePayload:
+		current, known := objectListAttribute(history, p.ObjID, p.AttrName)
+		var removed []string
+		for _, v := range p.Values {
+			if (!known || slices.Contains(current, v)) && !slices.Contains(removed, v) {
+				removed = append(removed, v)
+			}
+		}
+		if len(removed) > 0 {
+			inverse = append(inverse, mapper.AddObjAttributesMessagePayload{
+				ObjID:    p.ObjID,
+				AttrName: p.AttrName,
+				Values:   removed,
+			})
+		}
+
+	case mapper.UpdateObjAttributesMessagePayload:
+		oldAttrs := make(map[string]any)
+		for attrName := range p.NewAttrs {
+			if value, ok := objectAttribute(history, p.ObjID, attrName); ok {
+				oldAttrs[attrName] = value
+			}
+		}
+		if len(oldAttrs) > 0 {
+			inverse = append(inverse, mapper.UpdateObjAttributesMessagePayload{
+				ObjID:    p.ObjID,
+				NewAttrs: oldAttrs,
+			})
+		}
+
+	case mapper.LoadArcObjectMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+	case mapper.LoadCircleObjectMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+	case mapper.LoadLineObjectMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+	case mapper.LoadPolygonObjectMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+	case mapper.LoadRectangleObjectMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+	case mapper.LoadSpellAreaOfEffectObjectMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+	case mapper.LoadTextObjectMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+	case mapper.LoadTileObjectMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+	case mapper.PlaceSomeoneMessagePayload:
+		inverse = append(inverse, mapper.ClearMessagePayload{ObjID: p.ID})
+
+	case mapper.ClearFromMessagePayload:
+		inverse = append(inverse, mapper.LoadFromMessagePayload{
+			FileDefinition: p.FileDefinition,
+			Merge:          true,
+		})
+	}
+	return inverse
+}
+
+// restoredEntries compares the game state before and after a change, returning
+// the messages needed to put back the entries which the change removed or replaced,
+// in the same order that a SYNC operation would send them.
+func restoredEntries(before, after map[string]*mapper.MessagePayload) []mapper.MessagePayload {
+	var keys []string
+	for k, e := range before {
+		if e2, ok := after[k]; !ok || (strings.HasPrefix(k, "new:") && e2 != e) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		oi, oj := restoreOrder(keys[i]), restoreOrder(keys[j])
+		if oi != oj {
+			return oi < oj
+		}
+		return keys[i] < keys[j]
+	})
+
+	var restored []mapper.MessagePayload
+	for _, k := range keys {
+		e := *before[k]
+		switch p := e.(type) {
+		case mapper.LoadFromMessagePayload:
+			// don't let the clients erase everything else we're restoring
+			p.Merge = true
+			e = p
+		case mapper.AddObjAttributesMessagePayload:
+			if len(p.Values) == 0 {
+				continue
+			}
+		case mapper.RemoveObjAttributesMessagePayload:
+			if len(p.Values) == 0 {
+				continue
+			}
+		}
+		restored = append(restored, e)
+	}
+	return restored
+}
+
+// restoreOrder gives the relative order in which game state entries are sent
+// to clients, based on their eventHistory key.
+func restoreOrder(key string) int {
+	switch {
+	case strings.HasPrefix(key, "llf:"), strings.HasPrefix(key, "lsf:"):
+		return 0
+	case strings.HasPrefix(key, "ulf:"), strings.HasPrefix(key, "usf:"):
+		return 1
+	case strings.HasPrefix(key, "new:"):
+		return 2
+	case strings.HasPrefix(key, "mod:"):
+		return 3
+	default:
+		return 4
+	}
+}
+
+// objectAttribute returns the current value of the named attribute of the object
+// with the given ID, as set when the object was created or by a later
+// UpdateObjAttributes message (but not including any values added or removed
+// by AddObjAttributes or RemoveObjAttributes). If the value is not known, it
+// returns false.
+func objectAttribute(history map[string]*mapper.MessagePayload, objID, attrName string) (any, bool) {
+	if e, ok := history["mod:"+objID]; ok {
+		if mod, ok := (*e).(mapper.UpdateObjAttributesMessagePayload); ok {
+			if value, ok := mod.NewAttrs[attrName]; ok {
+				return value, true
+			}
+		}
+	}
+	if e, ok := history["new:"+objID]; ok {
+		obj := reflect.ValueOf(*e)
+		if obj.Kind() == reflect.Struct {
+			if field := obj.FieldByName(attrName); field.IsValid() && field.CanInterface() {
+				return field.Interface(), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// objectListAttribute is like objectAttribute but for attributes which hold a
+// list of strings, taking into account any values added or removed since the
+// attribute was last set.
+func objectListAttribute(history map[string]*mapper.MessagePayload, objID, attrName string) ([]string, bool) {
+	var values []string
+	value, known := objectAttribute(history, objID, attrName)
+	switch v := value.(type) {
+	case []string:
+		values = append(values, v...)
+	case []any:
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+
+	if e, ok := history["add:"+objID+":"+attrName]; ok {
+		if add, ok := (*e).(mapper.AddObjAttributesMessagePayload); ok {
+			for _, v := range add.Values {
+				if !slices.Contains(values, v) {
+					values = append(values, v)
+				}
+			}
+		}
+	}
+	if e, ok := history["del:"+objID+":"+attrName]; ok {
+		if del, ok := (*e).(mapper.RemoveObjAttributesMessagePayload); ok {
+			values = slices.DeleteFunc(values, func(v string) bool {
+				return slices.Contains(del.Values, v)
+			})
+		}
+	}
+	return values, known
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,312 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for undoing and redoing changes to the game state
+//
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+// startTestGameState starts a game state manager for a test application
+// which remembers up to limit changes for undo.
+func startTestGameState(t *testing.T, limit int) *Application {
+	t.Helper()
+	a := newTestApplication(t)
+	a.UndoLimit = limit
+	a.ResetGameState = true
+	go a.manageGameState()
+	return a
+}
+
+func dumpTestGameState(t *testing.T, a *Application) map[string]string {
+	t.Helper()
+	state, err := a.DumpGameState()
+	if err != nil {
+		t.Fatalf("unable to dump game state: %v", err)
+	}
+	return state
+}
+
+// gameStateDifferences describes how the game state got differs from the
+// expected one, or returns an empty string if they are the same.
+func gameStateDifferences(got, expected map[string]string) string {
+	var diffs string
+	for k, v := range expected {
+		if g, ok := got[k]; !ok {
+			diffs += fmt.Sprintf("\n  missing %s: %s", k, v)
+		} else if g != v {
+			diffs += fmt.Sprintf("\n  changed %s: %s (expected %s)", k, g, v)
+		}
+	}
+	for k, v := range got {
+		if _, ok := expected[k]; !ok {
+			diffs += fmt.Sprintf("\n  extra %s: %s", k, v)
+		}
+	}
+	return diffs
+}
+
+func testCreature(id, name string, creatureType mapper.CreatureTypeCode, gx, gy float64, status ...string) mapper.PlaceSomeoneMessagePayload {
+	return mapper.PlaceSomeoneMessagePayload{
+		CreatureToken: mapper.CreatureToken{
+			BaseMapObject: mapper.BaseMapObject{ID: id},
+			CreatureType:  creatureType,
+			Name:          name,
+			Gx:            gx,
+			Gy:            gy,
+			Size:          "M",
+			Color:         "red",
+			StatusList:    status,
+		},
+	}
+}
+
+func testCircle(id string, x, y float64) mapper.LoadCircleObjectMessagePayload {
+	return mapper.LoadCircleObjectMessagePayload{
+		CircleElement: mapper.CircleElement{
+			MapElement: mapper.MapElement{
+				BaseMapObject: mapper.BaseMapObject{ID: id},
+				Coordinates:   mapper.Coordinates{X: x, Y: y},
+			},
+		},
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		setup  []mapper.MessagePayload
+		change []mapper.MessagePayload
+	}{
+		{
+			name:   "place creature",
+			change: []mapper.MessagePayload{testCreature("c1", "goblin", 1, 1, 2)},
+		},
+		{
+			name:   "replace creature",
+			setup:  []mapper.MessagePayload{testCreature("c1", "goblin", 1, 1, 2, "blinded")},
+			change: []mapper.MessagePayload{testCreature("c1", "goblin", 1, 5, 6)},
+		},
+		{
+			name:   "draw element",
+			setup:  []mapper.MessagePayload{testCircle("e1", 10, 20)},
+			change: []mapper.MessagePayload{testCircle("e2", 30, 40)},
+		},
+		{
+			name: "clear everything",
+			setup: []mapper.MessagePayload{
+				mapper.AdjustViewMessagePayload{XView: 0.25, YView: 0.5, Grid: "A1"},
+				mapper.LoadFromMessagePayload{FileDefinition: mapper.FileDefinition{File: "dungeon"}},
+				testCreature("c1", "goblin", 1, 1, 2),
+				testCreature("c2", "Fred", 2, 3, 4),
+				testCircle("e1", 10, 20),
+				mapper.AddObjAttributesMessagePayload{ObjID: "c1", AttrName: "StatusList", Values: []string{"prone"}},
+				mapper.UpdateObjAttributesMessagePayload{ObjID: "c2", NewAttrs: map[string]any{"Gx": 7.0}},
+			},
+			change: []mapper.MessagePayload{mapper.ClearMessagePayload{ObjID: "*"}},
+		},
+		{
+			name: "clear monsters",
+			setup: []mapper.MessagePayload{
+				testCreature("c1", "goblin", 1, 1, 2),
+				testCreature("c2", "Fred", 2, 3, 4),
+				testCircle("e1", 10, 20),
+			},
+			change: []mapper.MessagePayload{mapper.ClearMessagePayload{ObjID: "M*"}},
+		},
+		{
+			name: "clear creature by name",
+			setup: []mapper.MessagePayload{
+				testCreature("c1", "goblin", 1, 1, 2),
+				mapper.UpdateObjAttributesMessagePayload{ObjID: "c1", NewAttrs: map[string]any{"Killed": true}},
+				testCreature("c2", "Fred", 2, 3, 4),
+			},
+			change: []mapper.MessagePayload{mapper.ClearMessagePayload{ObjID: "goblin"}},
+		},
+		{
+			name: "clear from server file",
+			setup: []mapper.MessagePayload{
+				mapper.LoadFromMessagePayload{FileDefinition: mapper.FileDefinition{File: "dungeon"}},
+				testCircle("e1", 10, 20),
+			},
+			change: []mapper.MessagePayload{mapper.ClearFromMessagePayload{FileDefinition: mapper.FileDefinition{File: "dungeon"}}},
+		},
+		{
+			name: "clear from local file",
+			setup: []mapper.MessagePayload{
+				mapper.LoadFromMessagePayload{FileDefinition: mapper.FileDefinition{File: "/tmp/cave.map", IsLocalFile: true}, Merge: true},
+			},
+			change: []mapper.MessagePayload{mapper.ClearFromMessagePayload{FileDefinition: mapper.FileDefinition{File: "/tmp/cave.map", IsLocalFile: true}}},
+		},
+		{
+			name:   "add attribute values",
+			setup:  []mapper.MessagePayload{testCreature("c1", "goblin", 1, 1, 2, "blinded")},
+			change: []mapper.MessagePayload{mapper.AddObjAttributesMessagePayload{ObjID: "c1", AttrName: "StatusList", Values: []string{"blinded", "prone", "stunned"}}},
+		},
+		{
+			name: "remove attribute values",
+			setup: []mapper.MessagePayload{
+				testCreature("c1", "goblin", 1, 1, 2, "blinded"),
+				mapper.AddObjAttributesMessagePayload{ObjID: "c1", AttrName: "StatusList", Values: []string{"prone", "stunned"}},
+			},
+			change: []mapper.MessagePayload{mapper.RemoveObjAttributesMessagePayload{ObjID: "c1", AttrName: "StatusList", Values: []string{"blinded", "prone"}}},
+		},
+		{
+			name: "update attributes",
+			setup: []mapper.MessagePayload{
+				testCreature("c1", "goblin", 1, 1, 2),
+				mapper.UpdateObjAttributesMessagePayload{ObjID: "c1", NewAttrs: map[string]any{"Gx": 3.0}},
+			},
+			change: []mapper.MessagePayload{mapper.UpdateObjAttributesMessagePayload{ObjID: "c1", NewAttrs: map[string]any{"Gx": 5.0, "Gy": 6.0, "Dim": true}}},
+		},
+		{
+			name:  "several changes together",
+			setup: []mapper.MessagePayload{testCreature("c1", "goblin", 1, 1, 2)},
+			change: []mapper.MessagePayload{
+				testCreature("c2", "Fred", 2, 3, 4),
+				mapper.UpdateObjAttributesMessagePayload{ObjID: "c1", NewAttrs: map[string]any{"Gx": 5.0}},
+				mapper.ClearMessagePayload{ObjID: "E*"},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a := startTestGameState(t, 10)
+			for _, e := range test.setup {
+				a.UpdateGameStateTogether([]mapper.MessagePayload{e})
+			}
+			before := dumpTestGameState(t, a)
+			a.UpdateGameStateTogether(test.change)
+			after := dumpTestGameState(t, a)
+			if gameStateDifferences(after, before) == "" {
+				t.Fatalf("change made no difference to the game state")
+			}
+
+			if n, messages := a.UndoGameState(1, false); n != 1 || len(messages) == 0 {
+				t.Fatalf("undo reversed %d changes with %d messages; expected 1 change", n, len(messages))
+			}
+			if d := gameStateDifferences(dumpTestGameState(t, a), before); d != "" {
+				t.Errorf("game state after undo differs from before the change:%s", d)
+			}
+
+			if n, messages := a.UndoGameState(1, true); n != 1 || len(messages) != len(test.change) {
+				t.Fatalf("redo re-applied %d changes with %d messages; expected 1 change with %d", n, len(messages), len(test.change))
+			}
+			if d := gameStateDifferences(dumpTestGameState(t, a), after); d != "" {
+				t.Errorf("game state after redo differs from after the change:%s", d)
+			}
+
+			if n, _ := a.UndoGameState(1, true); n != 0 {
+				t.Errorf("redo re-applied %d changes with nothing left to redo", n)
+			}
+		})
+	}
+}
+
+func TestUndoLimit(t *testing.T) {
+	a := startTestGameState(t, 3)
+	var states []map[string]string
+	for i := 0; i < 5; i++ {
+		states = append(states, dumpTestGameState(t, a))
+		a.UpdateGameStateTogether([]mapper.MessagePayload{testCircle(fmt.Sprintf("e%d", i), float64(i), 0)})
+	}
+
+	n, _ := a.UndoGameState(5, false)
+	if n != 3 {
+		t.Fatalf("undid %d changes, expected the limit of 3", n)
+	}
+	if d := gameStateDifferences(dumpTestGameState(t, a), states[2]); d != "" {
+		t.Errorf("game state after undo differs from before the oldest remembered change:%s", d)
+	}
+	if n, _ := a.UndoGameState(1, false); n != 0 {
+		t.Errorf("undid %d changes beyond the limit", n)
+	}
+}
+
+func TestUndoDisabled(t *testing.T) {
+	a := startTestGameState(t, 0)
+	a.UpdateGameStateTogether([]mapper.MessagePayload{testCircle("e1", 0, 0)})
+	if n, messages := a.UndoGameState(1, false); n != 0 || len(messages) != 0 {
+		t.Errorf("undid %d changes with %d messages with undo disabled", n, len(messages))
+	}
+}
+
+func TestNewChangeClearsRedo(t *testing.T) {
+	a := startTestGameState(t, 10)
+	a.UpdateGameStateTogether([]mapper.MessagePayload{testCircle("e1", 0, 0)})
+	a.UpdateGameStateTogether([]mapper.MessagePayload{testCircle("e2", 1, 0)})
+	if n, _ := a.UndoGameState(2, false); n != 2 {
+		t.Fatalf("undid %d changes, expected 2", n)
+	}
+	a.UpdateGameState(&[]mapper.MessagePayload{testCircle("e3", 2, 0)}[0])
+	expected := dumpTestGameState(t, a)
+	if n, _ := a.UndoGameState(1, true); n != 0 {
+		t.Errorf("redid %d changes after a new change was made", n)
+	}
+	if d := gameStateDifferences(dumpTestGameState(t, a), expected); d != "" {
+		t.Errorf("game state changed by redo with nothing to redo:%s", d)
+	}
+	if n, _ := a.UndoGameState(1, false); n != 1 {
+		t.Errorf("undid %d changes, expected the new change to be undone", n)
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -64,13 +64,13 @@ import (
 	"math/rand"
 	"os"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/MadScienceZone/go-gma/v5/tcllist"
-	"github.com/schwarmco/go-cartesian-product"
 )
 
 const MinimumSupportedDieRollPresetFileFormat = 1
@@ -131,6 +131,13 @@ type Dice struct {
 	div      int
 	factor   int
 	desc     string
+	expr     Expr
+
+	// Variables to pass on to a DieRoller (see WithVariables)
+	variables map[string]string
+
+	// Random tables to pass on to a DieRoller (see WithTables)
+	tables map[string]RandomTable
 
 	// The individual components that make up the overall die-roll
 	// operation to be performed.
@@ -173,7 +180,7 @@ type Dice struct {
 //
 // Each die‐roll expression has the general form
 //
-//	[>] [<n>[/<div>]] d <sides> [best|worst of <r>] [<label>]
+//	[>] [<n>[/<div>]] d <sides> [!] [r[o]<m>] [a<a>] [kh|kl<k>] [w<w>] [s<t> [b<b>]] [best|worst of <r>] [<label>]
 //
 // This calls for <n> dice with the given number of <sides> (which  may  be  a
 // number  or the character “%” which means percentile dice or d100).  The
@@ -184,6 +191,36 @@ type Dice struct {
 // best result. (You may also use the word worst in place of best to  take
 // the lowest of the rolls.)
 //
+// The options which come between <sides> and “best of” apply to each individual die,
+// in the order shown:
+//
+//	!        Exploding dice: any die which rolls its maximum value is rolled
+//	         again and the new roll added to it (e.g., “3d6!”).
+//	r<m>     Any die which rolls <m> or less is rerolled until it rolls higher
+//	         (e.g., “d20 r1”). Use “ro<m>” to reroll only once, keeping the second
+//	         roll even if it's still <m> or less.
+//	a<a>     Any die which rolls <a> or more adds another die to the roll, which
+//	         may itself roll <a> or more and add another (e.g., “8d10 a10 s8” for
+//	         dice pools where tens are rolled again). This can't be used with “!”
+//	         or the keep options.
+//	kh<k>    Keep only the highest <k> of the dice (e.g., “4d6kh3”).
+//	kl<k>    Keep only the lowest <k> of the dice (e.g., “2d20kl1”).
+//	w<w>     Roll a “wild die” with <w> sides along with the others, keeping the
+//	         best of them as if “kh<n>” were given (e.g., “d8! w6” rolls a d8
+//	         and a d6, both exploding, and takes the better one).
+//	         This can't be used with “a” or the keep options.
+//	s<t>     Instead of adding up the dice, count how many of them rolled <t> or
+//	         higher (e.g., “10d10 s8”). (The more obvious “>=8” can't be used for
+//	         this since it already means that the value may be no less than 8.)
+//	b<b>     Following “s<t>”, any die which isn't a success but whose natural
+//	         roll is <b> or less is a botch, and takes away one success
+//	         (e.g., “6d10 s6 b1”). The result may be negative.
+//
+// When these options are used, the structured description of the roll
+// reports each die separately, including which dice exploded, were rerolled,
+// were dropped, were added by “a”, or were botched, and which one was the
+// wild die.
+//
 // Arbitrary  text  (<label>) may appear at the end of the expression. It is
 // simply reported back in the result as a label to  describe  that  value
 // (e.g.   “1d10  + 1d6 fire + 2d6 sneak”.)  The <label> must begin with a letter
@@ -296,6 +333,15 @@ func WithGenerator(source rand.Source) func(*Dice) error {
 	}
 }
 
+// withExpression sets up the Dice to roll an expression
+// already parsed by the DieRoller.
+func withExpression(e Expr) func(*Dice) error {
+	return func(o *Dice) error {
+		o.expr = e
+		return nil
+	}
+}
+
 func withSharedGenerator(generator *rand.Rand) func(*Dice) error {
 	return func(o *Dice) error {
 		o.generator = generator
@@ -442,34 +488,40 @@ func (s *evalStack) applyOp() error {
 		return err
 	}
 
+	v, err := applyBinaryOp(op, x, y)
+	if err != nil {
+		return err
+	}
+	s.push(v)
+	return nil
+}
+
+// applyBinaryOp computes the value of x op y for one of our binary operators.
+func applyBinaryOp(op rune, x, y float64) (float64, error) {
 	switch op {
 	case '+':
-		s.push(math.Floor(x + y))
+		return math.Floor(x + y), nil
 	case '-':
-		s.push(math.Floor(x - y))
+		return math.Floor(x - y), nil
 	case '*', '×':
-		s.push(math.Floor(x * y))
+		return math.Floor(x * y), nil
 	case '÷':
 		if y == 0 {
-			return fmt.Errorf("division by zero is not defined")
+			return 0, fmt.Errorf("division by zero is not defined")
 		}
-		s.push(math.Floor(x / y))
+		return math.Floor(x / y), nil
 	case '≤':
 		if x > y {
-			s.push(y)
-		} else {
-			s.push(x)
+			return y, nil
 		}
+		return x, nil
 	case '≥':
 		if x < y {
-			s.push(y)
-		} else {
-			s.push(x)
+			return y, nil
 		}
-	default:
-		return fmt.Errorf("Unknown operator \"%v\"", op)
+		return x, nil
 	}
-	return nil
+	return 0, fmt.Errorf("Unknown operator \"%v\"", op)
 }
 
 func (s *evalStack) nextOp() rune {
@@ -526,6 +578,10 @@ type dieComponent interface {
 	compute(s *evalStack) error
 	computeMaxValue(s *evalStack) error
 
+	// Feed the probability distribution of this value into a
+	// distribution calculation in progress.
+	computeDistribution(s *distStack) error
+
 	// Return the most recently calculated value. (This can be used to
 	// get the random value rolled for diespecs.) This legacy method
 	// is not currently used anymore except in a unit test. For non-
@@ -545,6 +601,10 @@ type dieComponent interface {
 	naturalRoll() (int, int)
 }
 
+// dieOptionOrder describes the order in which the per-die options must
+// appear in a die-roll expression.
+const dieOptionOrder = "[!] [r[o]<n>] [a<n>] [kh|kl<n>] [w<sides>] [s<n> [b<n>]] [best|worst of <n>]"
+
 // dieLabel represents a bare label appearing outside the normal expression context.
 type dieLabel string
 
@@ -756,16 +816,58 @@ type dieSpec struct {
 	// Deprecated: use die-roll expression strings instead.
 	DieBonus int
 
+	// Per-die options. If Explode is true, any die which comes up at its
+	// maximum value is rolled again and the new roll added to it. Dice which
+	// come up at or below RerollBelow are rolled again (only once if RerollOnce
+	// is true, otherwise until they come up higher). If KeepHighest or KeepLowest
+	// are nonzero, only that many of the highest or lowest dice are counted. If
+	// SuccessTarget is nonzero, the value is the number of dice which came up
+	// at or above that target rather than their sum, less the number of
+	// other dice which came up at or below Botch.
+	//
+	// Dice which come up at or above Again add another die to the roll.
+	// If WildSides is nonzero, a wild die with that many sides is rolled
+	// along with the others, and only the highest Numerator dice are kept.
+	Explode       bool
+	RerollOnce    bool
+	RerollBelow   int
+	Again         int
+	KeepHighest   int
+	KeepLowest    int
+	WildSides     int
+	SuccessTarget int
+	Botch         int
+
 	// Label string for this component, if any
 	Label string
 
 	// A record of the actual die rolls performed, per re-roll attempt.
 	History [][]int
 
+	// If per-die options are in effect, the details of each die rolled
+	// (per re-roll attempt), and which attempt was chosen as the result.
+	Details [][]dieResult
+	chosen  int
+
 	_natural  int
 	generator *rand.Rand
 }
 
+// dieResult records how the value of a single die was arrived at
+// when per-die options are in effect.
+type dieResult struct {
+	Value    int   // the value of the die, including any per-die bonus
+	Rolls    []int // the natural rolls which were added together (more than one if the die exploded)
+	Rerolled []int // natural rolls which were discarded because they were rerolled
+	Dropped  bool  // true if the die was not kept
+	Extra    bool  // true if the die was added because another rolled at least Again
+	Wild     bool  // true if this is the wild die
+	Botched  bool  // true if the die took away a success
+}
+
+// maxExplosions limits how many times a single exploding die may be rerolled.
+const maxExplosions = 100
+
 // Assuming the die (and it must be a single die) for this component
 // has already been rolled, return the natural value of that die
 // and the number of sides.
@@ -815,6 +917,9 @@ func intToStrings(a []int) (as []string) {
 }
 
 func (d *dieSpec) compute(s *evalStack) error {
+	if d.hasPerDieOptions() {
+		return d.computePerDie(s, false)
+	}
 	d.History = nil
 	d.WasMaximized = false
 	if d.Sides <= 0 {
@@ -871,7 +976,180 @@ func (d *dieSpec) compute(s *evalStack) error {
 	return nil
 }
 
+// hasPerDieOptions returns true if the dice need to be considered individually
+// rather than just added together.
+func (d *dieSpec) hasPerDieOptions() bool {
+	return d.Explode || d.RerollBelow > 0 || d.Again > 0 || d.KeepHighest > 0 || d.KeepLowest > 0 || d.WildSides > 0 || d.SuccessTarget > 0
+}
+
+// dieValue returns the value of a die given its natural roll, after applying
+// the per-die bonus and fractional die divisor, if any.
+func (d *dieSpec) dieValue(natural int) int {
+	v := natural + d.DieBonus
+	if d.Denominator > 0 {
+		v /= d.Denominator
+		if v < 1 {
+			v = 1
+		}
+	}
+	return v
+}
+
+// rollNatural rolls a single die with the given number of sides, with no options applied.
+func (d *dieSpec) rollNatural(sides int) int {
+	if d.generator == nil {
+		return int(rand.Int31n(int32(sides))) + 1
+	}
+	return int(d.generator.Int31n(int32(sides))) + 1
+}
+
+// rollDie rolls a single die with the given number of sides, applying the
+// reroll and explode options.
+func (d *dieSpec) rollDie(sides int) (die dieResult) {
+	for explosions := 0; ; explosions++ {
+		v := d.rollNatural(sides)
+		for d.RerollBelow > 0 && v <= d.RerollBelow {
+			die.Rerolled = append(die.Rerolled, v)
+			v = d.rollNatural(sides)
+			if d.RerollOnce {
+				break
+			}
+		}
+		die.Rolls = append(die.Rolls, v)
+		if !d.Explode || v < sides || explosions >= maxExplosions {
+			break
+		}
+	}
+	die.Value = d.dieValue(sumOf(die.Rolls))
+	return
+}
+
+// applyKeepAndCount marks which dice are dropped by the keep options and returns
+// the total value of the remaining dice (or the number of them which were successful).
+func (d *dieSpec) applyKeepAndCount(dice []dieResult) (total int) {
+	keepHighest := d.KeepHighest
+	if d.WildSides > 0 {
+		keepHighest = d.Numerator
+	}
+	if keepHighest > 0 || d.KeepLowest > 0 {
+		order := make([]int, len(dice))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			if keepHighest > 0 {
+				return dice[order[i]].Value > dice[order[j]].Value
+			}
+			return dice[order[i]].Value < dice[order[j]].Value
+		})
+		for _, i := range order[keepHighest+d.KeepLowest:] {
+			dice[i].Dropped = true
+		}
+	}
+
+	for i, die := range dice {
+		if die.Dropped {
+			continue
+		}
+		if d.SuccessTarget > 0 {
+			if die.Value >= d.SuccessTarget {
+				total++
+			} else if d.Botch > 0 && sumOf(die.Rolls) <= d.Botch {
+				dice[i].Botched = true
+				total--
+			}
+		} else {
+			total += die.Value
+		}
+	}
+	return
+}
+
+// computePerDie is the equivalent of compute (or, if maximize is true, computeMaxValue)
+// for dice with per-die options.
+func (d *dieSpec) computePerDie(s *evalStack, maximize bool) error {
+	var totals []int
+
+	d.History = nil
+	d.Details = nil
+	d.WasMaximized = maximize
+	if d.Sides <= 0 {
+		return fmt.Errorf("dice cannot have a nonpositive number of sides")
+	}
+
+	attempts := d.Rerolls + 1
+	if maximize {
+		attempts = 1
+	}
+	for i := 0; i < attempts; i++ {
+		var dice []dieResult
+		var values []int
+
+		for j := 0; j < d.Numerator; j++ {
+			var die dieResult
+			if maximize || (d.InitialMax && j == 0) {
+				die = dieResult{Value: d.dieValue(d.Sides), Rolls: []int{d.Sides}}
+			} else {
+				die = d.rollDie(d.Sides)
+			}
+			dice = append(dice, die)
+			values = append(values, die.Value)
+
+			// each die which rolls high enough adds another (unless maximized,
+			// since that would go on forever)
+			for extra := 0; d.Again > 0 && !maximize && die.Rolls[0] >= d.Again && extra < maxExplosions; extra++ {
+				die = d.rollDie(d.Sides)
+				die.Extra = true
+				dice = append(dice, die)
+				values = append(values, die.Value)
+			}
+		}
+		if d.WildSides > 0 {
+			var die dieResult
+			if maximize {
+				die = dieResult{Value: d.dieValue(d.WildSides), Rolls: []int{d.WildSides}}
+			} else {
+				die = d.rollDie(d.WildSides)
+			}
+			die.Wild = true
+			dice = append(dice, die)
+			values = append(values, die.Value)
+		}
+		totals = append(totals, d.applyKeepAndCount(dice))
+		d.Details = append(d.Details, dice)
+		d.History = append(d.History, values)
+	}
+
+	if d.BestReroll {
+		d.Value, d.chosen = maxOf(totals)
+	} else {
+		d.Value, d.chosen = minOf(totals)
+	}
+
+	// The natural roll is only meaningful if we're left with a single die
+	// whose value is simply what was rolled.
+	d._natural = -1
+	if !d.Explode && d.SuccessTarget == 0 && d.Again == 0 && d.WildSides == 0 {
+		kept := 0
+		for _, die := range d.Details[d.chosen] {
+			if !die.Dropped {
+				kept++
+				d._natural = die.Rolls[0]
+			}
+		}
+		if kept != 1 {
+			d._natural = -1
+		}
+	}
+
+	s.push(float64(d.Value))
+	return nil
+}
+
 func (d *dieSpec) computeMaxValue(s *evalStack) error {
+	if d.hasPerDieOptions() {
+		return d.computePerDie(s, true)
+	}
 	d.WasMaximized = true
 	d.History = nil
 	this := []int{}
@@ -905,6 +1183,7 @@ func (d *dieSpec) description() string {
 	} else {
 		desc += fmt.Sprintf("%dd%d", d.Numerator, d.Sides)
 	}
+	desc += d.perDieOptionsDescription()
 	if d.DieBonus > 0 {
 		desc += fmt.Sprintf(" (%+d per die)", d.DieBonus)
 	}
@@ -921,14 +1200,103 @@ func (d *dieSpec) description() string {
 	return desc
 }
 
+// perDieOptionsDescription describes the per-die options as they
+// would appear in a die-roll expression.
+func (d *dieSpec) perDieOptionsDescription() (desc string) {
+	for _, opt := range d.structuredPerDieOptions() {
+		desc += opt.Value
+	}
+	return
+}
+
+// structuredPerDieOptions describes the per-die options as StructuredDescriptions.
+func (d *dieSpec) structuredPerDieOptions() (desc []StructuredDescription) {
+	if d.Explode {
+		desc = append(desc, StructuredDescription{Type: "explode", Value: "!"})
+	}
+	if d.RerollBelow > 0 {
+		if d.RerollOnce {
+			desc = append(desc, StructuredDescription{Type: "reroll", Value: fmt.Sprintf("ro%d", d.RerollBelow)})
+		} else {
+			desc = append(desc, StructuredDescription{Type: "reroll", Value: fmt.Sprintf("r%d", d.RerollBelow)})
+		}
+	}
+	if d.Again > 0 {
+		desc = append(desc, StructuredDescription{Type: "again", Value: fmt.Sprintf("a%d", d.Again)})
+	}
+	if d.KeepHighest > 0 {
+		desc = append(desc, StructuredDescription{Type: "keep", Value: fmt.Sprintf("kh%d", d.KeepHighest)})
+	}
+	if d.KeepLowest > 0 {
+		desc = append(desc, StructuredDescription{Type: "keep", Value: fmt.Sprintf("kl%d", d.KeepLowest)})
+	}
+	if d.WildSides > 0 {
+		desc = append(desc, StructuredDescription{Type: "wild", Value: fmt.Sprintf("w%d", d.WildSides)})
+	}
+	if d.SuccessTarget > 0 {
+		desc = append(desc, StructuredDescription{Type: "target", Value: fmt.Sprintf("s%d", d.SuccessTarget)})
+	}
+	if d.Botch > 0 {
+		desc = append(desc, StructuredDescription{Type: "botch", Value: fmt.Sprintf("b%d", d.Botch)})
+	}
+	return
+}
+
+// structuredDescribeDice reports each die individually, noting which were
+// rerolled, exploded, dropped, added by the again option, or botched, and
+// which was the wild die (and whether it was itself dropped).
+func (d *dieSpec) structuredDescribeDice(dice []dieResult, rollType string) (desc []StructuredDescription) {
+	for _, die := range dice {
+		if len(die.Rerolled) > 0 {
+			desc = append(desc, StructuredDescription{Type: "rerolled", Value: strings.Join(intToStrings(die.Rerolled), ",")})
+		}
+		switch {
+		case die.Wild && die.Dropped:
+			desc = append(desc, StructuredDescription{Type: "wilddropped", Value: strings.Join(intToStrings(die.Rolls), ",")})
+		case die.Dropped:
+			desc = append(desc, StructuredDescription{Type: "dropped", Value: strconv.Itoa(die.Value)})
+		case die.Botched:
+			desc = append(desc, StructuredDescription{Type: "botched", Value: strconv.Itoa(die.Value)})
+		case die.Wild:
+			desc = append(desc, StructuredDescription{Type: "wildroll", Value: strings.Join(intToStrings(die.Rolls), ",")})
+		case die.Extra:
+			desc = append(desc, StructuredDescription{Type: "extra", Value: strconv.Itoa(die.Value)})
+		case len(die.Rolls) > 1:
+			desc = append(desc, StructuredDescription{Type: "exploded", Value: strings.Join(intToStrings(die.Rolls), ",")})
+		default:
+			desc = append(desc, StructuredDescription{Type: rollType, Value: strconv.Itoa(die.Value)})
+		}
+	}
+	return
+}
+
+// Returns true if the result of this component comes from a single
+// natural die roll (such as "d20", or "2d20kh1" which keeps one of two dice).
+func (d *dieSpec) isSingleDie() bool {
+	if !d.hasPerDieOptions() {
+		return d.Numerator == 1
+	}
+	if d.Explode || d.SuccessTarget > 0 || d.Again > 0 || d.WildSides > 0 {
+		return false
+	}
+	keep := d.KeepHighest + d.KeepLowest
+	return keep == 1 || (keep == 0 && d.Numerator == 1)
+}
+
 // Returns true if the value rolled for this component was a 1.
 func (d *dieSpec) isMinRoll() bool {
+	if d.hasPerDieOptions() {
+		return d._natural == 1
+	}
 	return d.Value == 1
 }
 
 // Returns true if the value rolled for this component is the same as
 // the number of sides on the die.
 func (d *dieSpec) isMaxRoll() bool {
+	if d.hasPerDieOptions() {
+		return d._natural == d.Sides
+	}
 	return d.Value == d.Sides
 }
 
@@ -956,6 +1324,46 @@ func (d *dieSpec) structuredDescribeRoll(resultSuppressed bool) []StructuredDesc
 		desc = append(desc, StructuredDescription{Type: "diebonus", Value: fmt.Sprintf("%+d", d.DieBonus)})
 	}
 
+	if d.hasPerDieOptions() {
+		desc = append(desc, d.structuredPerDieOptions()...)
+		if !resultSuppressed {
+			if d.SuccessTarget > 0 {
+				desc = append(desc, StructuredDescription{Type: "successes", Value: strconv.Itoa(d.Value)})
+				if d.Botch > 0 {
+					botches := 0
+					for _, die := range d.Details[d.chosen] {
+						if die.Botched {
+							botches++
+						}
+					}
+					desc = append(desc, StructuredDescription{Type: "botches", Value: strconv.Itoa(botches)})
+				}
+			} else if len(d.History[0]) > 1 {
+				desc = append(desc, StructuredDescription{Type: "subtotal", Value: strconv.Itoa(d.Value)})
+			}
+		}
+		if d.Rerolls > 0 {
+			if d.BestReroll {
+				desc = append(desc, StructuredDescription{Type: "best", Value: strconv.Itoa(d.Rerolls + 1)})
+			} else {
+				desc = append(desc, StructuredDescription{Type: "worst", Value: strconv.Itoa(d.Rerolls + 1)})
+			}
+		}
+		if !resultSuppressed {
+			for i, dice := range d.Details {
+				if i == d.chosen {
+					desc = append(desc, d.structuredDescribeDice(dice, rollType)...)
+				} else {
+					desc = append(desc, StructuredDescription{Type: "discarded", Value: strings.Join(intToStrings(d.History[i]), ",")})
+				}
+			}
+		}
+		if d.Label != "" {
+			desc = append(desc, StructuredDescription{Type: "label", Value: d.Label})
+		}
+		return desc
+	}
+
 	if !resultSuppressed && len(d.History[0]) > 1 {
 		desc = append(desc, StructuredDescription{Type: "subtotal", Value: strconv.Itoa(d.Value)})
 	}
@@ -1025,251 +1433,29 @@ func New(options ...func(*Dice) error) (*Dice, error) {
 
 	if d.desc != "" {
 		//
-		// some up-front error checking
+		// The description is a die-roll expression optionally followed
+		// by min and/or max limits.
 		//
-		usedThreat, err := regexp.MatchString(`\bc(\d+)?([-+]\d+)?\b`, d.desc)
+		expr, modifiers, err := parseDice(d.desc)
 		if err != nil {
 			return nil, err
 		}
-		if usedThreat {
-			return nil, fmt.Errorf("confirmation specifier (c[threat][±bonus]) not allowed in this location. It must be at the end of a full DieRoller description string only")
-		}
-		//
-		// compile regular expressions
-		//
-		reMin := regexp.MustCompile(`^\s*min\s*([+-]?\d+)\s*$`)
-		reMax := regexp.MustCompile(`^\s*max\s*([+-]?\d+)\s*$`)
-		reMinmax := regexp.MustCompile(`\b(min|max)\s*[+-]?\d+`)
-		reOpSplit := regexp.MustCompile(`[-+*×÷()≤≥]|[^-+*×÷()≤≥]+`)
-		reIsOp := regexp.MustCompile(`^[-+*×÷()≤≥]$`)
-		reIsDie := regexp.MustCompile(`\d+\s*[dD]\d*\d+`)
-		reIsWS := regexp.MustCompile(`^\s+$`)
-		reIsBareLabel := regexp.MustCompile(`^\s*([\p{L}_][\p{L}\p{N}_,.]*\s*)+(≡(#[0-9a-fA-F]+|\w+)){0,2}(\s*‖\s*([\p{L}_][\p{L}\p{N}_,.]*\s*)+(≡(#[0-9a-fA-F]+|\w+)){0,2})*\s*$`)
-		reConstant := regexp.MustCompile(`^\s*(\d+(?:\.\d+)?|\.\d+)\s*(.*?)\s*$`)
-		//                                  max?    numerator    denominator       sides          best/worst         rerolls   label
-		//                                   _1_    __2__          __3__            __4___       _____5_____         __6__     __7__
-		reDieSpec := regexp.MustCompile(`^\s*(>)?\s*(\d*)\s*(?:/\s*(\d+))?\s*[Dd]\s*(%|\d+)\s*(?:(best|worst)\s*of\s*(\d+))?\s*(.*?)\s*$`)
-
-		//
-		// break apart the major pieces separated by |
-		// here, the first is the basic die spec. The others may be "min" or "max"
-		//
-		majorPieces := strings.Split(d.desc, "|")
-		if len(majorPieces) == 0 {
-			return nil, fmt.Errorf("apparently empty dice expression")
-		}
-
-		if len(majorPieces) > 1 {
-			d.desc = strings.TrimSpace(majorPieces[0])
-			for _, modifier := range majorPieces[1:] {
-				if m := reMin.FindStringSubmatch(modifier); m != nil {
-					d.MinValue, err = strconv.Atoi(m[1])
-					if err != nil {
-						return nil, err
-					}
-				} else if m := reMax.FindStringSubmatch(modifier); m != nil {
-					d.MaxValue, err = strconv.Atoi(m[1])
-					if err != nil {
-						return nil, err
-					}
-				} else {
-					return nil, fmt.Errorf("invalid global modifier %s", modifier)
-				}
+		for _, m := range modifiers {
+			if m.Kind == MinModifier {
+				d.MinValue = m.Value
+			} else {
+				d.MaxValue = m.Value
 			}
 		}
+		d.expr = expr
+	}
 
+	if d.expr != nil {
 		//
-		// The die spec is a number of basic die rolls or constants separated
-		// by math operators +, -, *, or //. A leading constant 0 is assumed if
-		// the expression starts with an operator.
-		// We'll support the use of the unicode ÷ character in place of the older
-		// ASCII "//" operator as well.
-		//
-		expr := strings.Replace(d.desc, "//", "÷", -1)
-		expr = strings.Replace(expr, ">=", "≥", -1)
-		expr = strings.Replace(expr, "<=", "≤", -1)
-		exprParts := reOpSplit.FindAllString(expr, -1)
-		expectedSyntax := "[<n>[/<d>]] d [<sides>|%] [best|worst of <n>] [+|-|*|×|÷|//|<=|>=|≤|≥ ...] ['|'min <n>] ['|'max <n>]"
-
-		if len(exprParts) == 0 {
-			return nil, fmt.Errorf("syntax error in die roll description \"%s\"; should be \"%s\"", d.desc, expectedSyntax)
-		}
-
-		//
-		// exprParts is a list of alternating operators and values.
-		// We'll run through the list, building up a sequence of dieComponents
-		// to represent the expression we were given.
+		// Build up the sequence of dieComponents which evaluate the
+		// expression, in the order they appear in it.
 		//
-		opExpected := false
-		diceCount := 0
-		for _, part := range exprParts {
-			if opExpected {
-				// we are expecting an operator here; collect it and go to the next part
-				if reIsWS.MatchString(part) {
-					// ignore white space while expecting an operator (issue #19)
-					continue
-				}
-
-				if !reIsOp.MatchString(part) {
-					// If it's a bare label, allow it here
-					if reIsBareLabel.MatchString(part) {
-						if reDieSpec.MatchString(part) {
-							return nil, fmt.Errorf("\"%v\" looks suspiciously like a die-roll specification but appears as a label; did you forget an operator?", part)
-						}
-						var bareLabel dieLabel = (dieLabel)(strings.TrimSpace(part))
-						d.multiDice = append(d.multiDice, &bareLabel)
-						continue
-					}
-					return nil, fmt.Errorf("expected operator before \"%v\" in die-roll expression", part)
-				}
-
-				var thisOp rune
-				for _, r := range part {
-					if r == '*' {
-						thisOp = '×'
-					} else {
-						thisOp = r
-					}
-					break
-				}
-
-				switch thisOp {
-				case '(':
-					return nil, fmt.Errorf("expected operator before '(' in die-roll expression")
-				case ')':
-					d.multiDice = append(d.multiDice, new(dieEndGroup))
-					continue
-				default:
-					var do dieOperator = (dieOperator)(thisOp)
-					d.multiDice = append(d.multiDice, &do)
-				}
-				opExpected = false
-				continue
-			}
-			// we're not expecting to see an operator here, so if we see a + or -, they must
-			// be a leading unary + or - to apply to what comes next.
-			if reIsWS.MatchString(part) {
-				continue
-			}
-			if part == "+" {
-				// and a unary + is essentially a no-op, so we'll just ignore it.
-				continue
-			}
-			if part == "-" {
-				var do dieOperator = (dieOperator)('‾')
-				d.multiDice = append(d.multiDice, &do)
-				continue
-			}
-			if part == "(" {
-				// an open bracket is ok here as well
-				d.multiDice = append(d.multiDice, new(dieBeginGroup))
-				continue
-			}
-			// any other operator here is illegal
-			if reIsOp.MatchString(part) {
-				return nil, fmt.Errorf("unexpected operator \"%v\" in die-roll expression", part)
-			}
-
-			// whatever comes next is a value, so switch to looking for an operator after it.
-			opExpected = true
-
-			xValues := reDieSpec.FindStringSubmatch(part)
-			if xValues == nil {
-				//
-				// If this is just a constant, this will be easy.
-				//
-				xValues = reConstant.FindStringSubmatch(part)
-				if xValues != nil {
-					v, err := strconv.ParseFloat(xValues[1], 64)
-					if err != nil {
-						return nil, fmt.Errorf("value error in die roll subexpression \"%s\" in \"%s\"; %v", part, d.desc, err)
-					}
-					labelText := strings.TrimSpace(xValues[2])
-					if labelText != "" && !reIsBareLabel.MatchString(labelText) {
-						return nil, fmt.Errorf("constant label \"%v\" has illegal characters", labelText)
-					}
-					d.multiDice = append(d.multiDice, &dieConstant{Value: v, Label: labelText})
-					continue
-				}
-				//
-				// Ok, doesn't look valid then.
-				//
-				return nil, fmt.Errorf("syntax error in die roll subexpression \"%s\" in \"%s\"; should be \"%s\"", part, d.desc, expectedSyntax)
-			}
-
-			//
-			// Otherwise, we'll look for a complex die-roll specification,
-			// but up front we'll make sure they didn't try to sneak in an
-			// option that belongs at the end of the expression, not buried
-			// here in one of these.
-			//
-			if reMinmax.MatchString(part) {
-				return nil, fmt.Errorf("syntax error in die roll subexpression \"%s\" in \"%s\"; min/max limits must appear after the final operator in the expression, since they apply to the entire set of dice rolls", part, d.desc)
-			}
-
-			//
-			// Ok, now let's digest the more complex die-roll spec pattern
-			// and construct a dieSpec to describe it.
-			//
-			ds := &dieSpec{generator: d.generator}
-			d._onlydie = ds
-			diceCount++
-			if xValues[1] != "" {
-				ds.InitialMax = true
-			}
-			if xValues[2] != "" {
-				ds.Numerator, err = strconv.Atoi(xValues[2])
-				if err != nil {
-					return nil, fmt.Errorf("value error in die roll subexpression \"%s\": %v", part, err)
-				}
-			} else {
-				ds.Numerator = 1
-			}
-			if xValues[3] != "" {
-				ds.Denominator, err = strconv.Atoi(xValues[3])
-				if err != nil {
-					return nil, fmt.Errorf("value error in die roll subexpression \"%s\": %v", part, err)
-				}
-			}
-			if xValues[4] == "%" {
-				ds.Sides = 100
-			} else {
-				ds.Sides, err = strconv.Atoi(xValues[4])
-				if err != nil {
-					return nil, fmt.Errorf("value error in die roll subexpression \"%s\": %v", part, err)
-				}
-			}
-			if xValues[5] != "" {
-				ds.Rerolls, err = strconv.Atoi(xValues[6])
-				if err != nil {
-					return nil, fmt.Errorf("value error in die roll subexpression \"%s\": %v", part, err)
-				}
-				ds.Rerolls--
-				switch xValues[5] {
-				case "best":
-					ds.BestReroll = true
-				case "worst":
-					ds.BestReroll = false
-				default:
-					return nil, fmt.Errorf("value error in die roll subexpression \"%s\": expecting \"best\" or \"worst\"", part)
-				}
-			}
-			if xValues[7] != "" {
-				if reIsDie.MatchString(xValues[7]) {
-					return nil, fmt.Errorf("label following die roll in \"%s\" looks like another die roll--did you forget an operator?", part)
-				}
-				if !reIsBareLabel.MatchString(xValues[7]) {
-					return nil, fmt.Errorf("label \"%v\" has illegal characters", xValues[7])
-				}
-				ds.Label = strings.TrimSpace(xValues[7])
-			}
-			d.multiDice = append(d.multiDice, ds)
-		}
-
-		if !opExpected {
-			return nil, fmt.Errorf("missing value after last operator in die-roll expression \"%s\"", d.desc)
-		}
-		if diceCount != 1 {
+		if d.appendComponents(d.expr) != 1 {
 			d._onlydie = nil
 		}
 	}
@@ -1302,6 +1488,64 @@ func New(options ...func(*Dice) error) (*Dice, error) {
 	return d, nil
 }
 
+// appendComponents adds the dieComponents which evaluate e to
+// d.multiDice, returning the number of dieSpecs among them.
+func (d *Dice) appendComponents(e Expr) (diceCount int) {
+	switch n := e.(type) {
+	case *BinaryExpr:
+		diceCount = d.appendComponents(n.X)
+		var do dieOperator = (dieOperator)(n.Op)
+		d.multiDice = append(d.multiDice, &do)
+		diceCount += d.appendComponents(n.Y)
+
+	case *UnaryExpr:
+		var do dieOperator = (dieOperator)('‾')
+		d.multiDice = append(d.multiDice, &do)
+		diceCount = d.appendComponents(n.X)
+
+	case *GroupExpr:
+		d.multiDice = append(d.multiDice, new(dieBeginGroup))
+		diceCount = d.appendComponents(n.X)
+		d.multiDice = append(d.multiDice, new(dieEndGroup))
+		if n.Label != "" {
+			var bareLabel dieLabel = (dieLabel)(n.Label)
+			d.multiDice = append(d.multiDice, &bareLabel)
+		}
+
+	case *ConstantTerm:
+		d.multiDice = append(d.multiDice, &dieConstant{Value: n.Value, Label: n.Label})
+
+	case *DiceTerm:
+		ds := &dieSpec{
+			InitialMax:    n.InitialMax,
+			Numerator:     n.Count,
+			Denominator:   n.Denominator,
+			Sides:         n.Sides,
+			Explode:       n.Explode,
+			RerollOnce:    n.RerollOnce,
+			RerollBelow:   n.RerollBelow,
+			Again:         n.Again,
+			KeepHighest:   n.KeepHighest,
+			KeepLowest:    n.KeepLowest,
+			WildSides:     n.WildSides,
+			SuccessTarget: n.SuccessTarget,
+			Botch:         n.Botch,
+			Label:         n.Label,
+			generator:     d.generator,
+		}
+		if n.BestOf > 0 {
+			ds.Rerolls = n.BestOf - 1
+			ds.BestReroll = true
+		} else if n.WorstOf > 0 {
+			ds.Rerolls = n.WorstOf - 1
+		}
+		d._onlydie = ds
+		d.multiDice = append(d.multiDice, ds)
+		diceCount = 1
+	}
+	return diceCount
+}
+
 // Roll rolls the dice which this Dice instance represents. The result is
 // returned as an integer value.  Each time  this  is  called,  the
 // dice are rerolled to get a new result.  The Dice value’s internal
@@ -1526,7 +1770,7 @@ func (d *Dice) StructuredDescribeRoll(options ...func(*sdrOptions)) ([]Structure
 	}
 	if !opts.resultSuppressed {
 		if opts.autoSF {
-			if d._onlydie == nil || d._onlydie.Numerator != 1 {
+			if d._onlydie == nil || !d._onlydie.isSingleDie() {
 				return nil, fmt.Errorf("you can't indicate auto-success/fail (|sf option) because it involves multiple dice")
 			}
 			if d._onlydie.isMinRoll() {
@@ -1601,6 +1845,21 @@ type DieRoller struct {
 	// roll to be "successful".
 	DC int
 
+	// If Degrees > 0, the roll is graded as a critical success, success,
+	// failure, or critical failure depending on whether it beat the DC
+	// (or opposing roll) by at least Degrees, met it, missed it by less
+	// than Degrees, or missed it by Degrees or more.
+	Degrees int
+
+	// Named ranges of results. The first of these which includes the
+	// result of the roll is reported as its outcome.
+	Bands []OutcomeBand
+
+	// If Opposed is not empty, it is a die-roll expression which is
+	// rolled along with each roll and compared against it.
+	Opposed string
+	opposed *Dice // the opposing roll, if any
+
 	critThreat int // --threat threshold (0=default for die type)
 	critBonus  int // --added to confirmation rolls
 
@@ -1626,6 +1885,14 @@ type DieRoller struct {
 	// Postfix expression(s) generated by the most recent roll
 	Postfix []string
 
+	// Named values substituted into die-roll specs (see SetVariables)
+	variables map[string]string
+
+	// Random tables which die-roll specs may roll on (see SetTables),
+	// and the name of the table to roll on for the current spec, if any.
+	tables map[string]RandomTable
+	table  string
+
 	generator *rand.Rand
 	d         *Dice // underlying Dice object
 }
@@ -1660,7 +1927,8 @@ func (d *DieRoller) RandIntn(n int) int {
 //
 // You may pass zero or more option specifiers to this function as already
 // described for the New constructor, although the only ones which apply
-// here are WithSeed(s) and WithGenerator(s).
+// here are WithSeed(s), WithGenerator(s), WithVariables(vars), and
+// WithTables(tables).
 //
 // Initially it is set up to roll a single d20, but this can be changed with
 // each DoRoll call.
@@ -1679,6 +1947,8 @@ func NewDieRoller(options ...func(*Dice) error) (*DieRoller, error) {
 	if opts.generator != nil {
 		dr.generator = opts.generator
 	}
+	dr.variables = opts.variables
+	dr.tables = opts.tables
 
 	dr.d, err = New(ByDieType(1, 20, 0), withSharedGenerator(dr.generator))
 	if err != nil {
@@ -1706,252 +1976,285 @@ func (d *DieRoller) setNewSpecification(spec string) error {
 	d.RepeatFor = 1
 	d.DoMax = false
 	d.DC = 0
+	d.Degrees = 0
+	d.Bands = nil
+	d.Opposed = ""
+	d.opposed = nil
 	d.PctChance = -1
 	d.PctLabel = ""
+	d.table = ""
 
-	reLabel := regexp.MustCompile(`^\s*(.*?)\s*=\s*(.*?)\s*$`)
-	reModMinmax := regexp.MustCompile(`^\s*(min|max)\s*[+-]?\d+`)
-	reModConfirm := regexp.MustCompile(`^\s*c(\d+)?([-+]\d+)?\s*$`)
-	reModUntil := regexp.MustCompile(`^\s*until\s*(-?\d+)\s*$`)
-	reModUntilTotal := regexp.MustCompile(`^\s*total\s*(-?\d+)\s*$`)
-	reModRepeat := regexp.MustCompile(`^\s*repeat\s*(\d+)\s*$`)
-	reModMaximized := regexp.MustCompile(`^\s*(!|maximized)\s*$`)
-	reModDC := regexp.MustCompile(`^\s*[Dd][Cc]\s*(-?\d+)\s*$`)
-	reModSF := regexp.MustCompile(`^\s*sf(?:\s+(\S.*?)(?:/(\S.*?))?)?\s*$`)
-	rePermutations := regexp.MustCompile(`\{(.*?)\}`)
-	rePctRoll := regexp.MustCompile(`^\s*(\d+)%(.*)$`)
-
-	//
-	// Convert <= and >= so we don't confuse them with the = that indicates a title string
-	//
-	spec = strings.Replace(spec, ">=", "≥", -1)
-	spec = strings.Replace(spec, "<=", "≤", -1)
 	//
-	// Look for leading "<label>="
+	// Substitute any $variable references first, since their values
+	// may contain any part of the spec.
 	//
-	fields := reLabel.FindStringSubmatch(spec)
-	if fields != nil {
-		spec = fields[2]
-		d.LabelText = fields[1]
+	if spec, err = ExpandVariables(spec, d.variables); err != nil {
+		return err
+	}
+	r, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	if r.Title != nil {
+		d.LabelText = r.Title.Text
 	}
 
 	//
-	// The remainder of the spec is a die-roll string followed by a number
-	// of global modifiers, separated by vertical bars.
+	// A spec of the form "@<table>" rolls on a random table instead.
+	// The only global modifier which makes sense for these is repeat.
 	//
-	majorPieces := strings.Split(spec, "|")
-	if len(majorPieces) == 0 {
-		return fmt.Errorf("empty dice description")
+	if r.Table != nil {
+		if _, ok := d.tables[r.Table.Name]; !ok {
+			return d.unknownTableError(spec, r.Table)
+		}
+		for _, m := range r.Modifiers {
+			d.RepeatFor = m.Value
+		}
+		d.table = r.Table.Name
+		return nil
 	}
 
 	//
-	// If there are modifiers, process them now.
+	// Parse has already checked that the global modifiers make sense
+	// together, so we just need to note what they ask us to do.
+	// The min and max limits are passed down to the Dice.
 	//
-	if len(majorPieces) > 1 {
-		spec = strings.TrimSpace(majorPieces[0])
-		for i := 1; i < len(majorPieces); i++ {
-			if reModMinmax.MatchString(majorPieces[i]) {
-				// min/max options need to be passed down to the Dice parser,
-				// so append it to the diespec string
-				spec += "|" + majorPieces[i]
-			} else {
-				if fields := reModConfirm.FindStringSubmatch(majorPieces[i]); fields != nil {
-					//
-					// MODIFIER
-					// 	| c[<threat>][{+|-}<bonus>]
-					// critical roll confirmation specifier
-					//
-					d.Confirm = true
-					if fields[1] != "" {
-						d.critThreat, err = strconv.Atoi(fields[1])
-						if err != nil {
-							return fmt.Errorf("value error in die roll confirm expression: %v", err)
-						}
-					}
-					if fields[2] != "" {
-						d.critBonus, err = strconv.Atoi(fields[2])
-						if err != nil {
-							return fmt.Errorf("value error in die roll confirm expression: %v", err)
-						}
-					}
-					//
-					// If there wasn't something more explicitly defined,
-					// a critical confirmation roll uses HIT/MISS as defaults.
-					//
-					if d.SuccessMessage == "" {
-						d.SuccessMessage = "HIT"
-					}
-					if d.FailMessage == "" {
+	var limits []*Modifier
+	for _, m := range r.Modifiers {
+		switch m.Kind {
+		case MinModifier, MaxModifier:
+			limits = append(limits, m)
+
+		case ConfirmModifier:
+			// critical roll confirmation specifier
+			d.Confirm = true
+			d.critThreat = m.Value
+			d.critBonus = m.Bonus
+			//
+			// If there wasn't something more explicitly defined,
+			// a critical confirmation roll uses HIT/MISS as defaults.
+			//
+			if d.SuccessMessage == "" {
+				d.SuccessMessage = "HIT"
+			}
+			if d.FailMessage == "" {
+				d.FailMessage = "MISS"
+			}
+
+		case TotalModifier:
+			// Repeat rolling until the cumulative total is at least <n>
+			d.RepeatUntilTotal = m.Value
+
+		case UntilModifier:
+			// Repeat rolling until reaching limit <n>
+			d.RepeatUntil = m.Value
+
+		case RepeatModifier:
+			// Repeat the die roll <n> times
+			d.RepeatFor = m.Value
+
+		case MaximizedModifier:
+			// Maximize all die rolls
+			d.DoMax = true
+
+		case DCModifier:
+			// Seek a value at least <n>
+			d.DC = m.Value
+
+		case SFModifier:
+			// Set messages for successful and failed rolls.
+			d.sfOpt = spec[m.Start:m.End]
+			if m.Success != "" {
+				d.SuccessMessage = m.Success
+				if m.Fail != "" {
+					d.FailMessage = m.Fail
+				} else {
+					// Guess the failure message based on the success
+					// message.
+					switch strings.ToLower(d.SuccessMessage) {
+					case "hit":
 						d.FailMessage = "MISS"
-					}
-				} else if fields := reModUntilTotal.FindStringSubmatch(majorPieces[i]); fields != nil {
-					//
-					// MODIFIER
-					//  | total <n>
-					// Repeat rolling until the cumulative total is at least <n>
-					//
-					d.RepeatUntilTotal, err = strconv.Atoi(fields[1])
-				} else if fields := reModUntil.FindStringSubmatch(majorPieces[i]); fields != nil {
-					//
-					// MODIFIER
-					//  | until <n>
-					// Repeat rolling until reaching limit <n>
-					//
-					d.RepeatUntil, err = strconv.Atoi(fields[1])
-					if err != nil {
-						return fmt.Errorf("value error in die roll until clause: %v", err)
-					}
-				} else if fields := reModRepeat.FindStringSubmatch(majorPieces[i]); fields != nil {
-					//
-					// MODIFIER
-					//  | repeat <n>
-					// Repeat the die roll <n> times
-					//
-					d.RepeatFor, err = strconv.Atoi(fields[1])
-					if err != nil {
-						return fmt.Errorf("value error in die roll repeat clause: %v", err)
-					}
-				} else if reModMaximized.MatchString(majorPieces[i]) {
-					//
-					// MODIFIER
-					//  | !|maximized
-					// Maximize all die rolls
-					//
-					d.DoMax = true
-				} else if fields := reModDC.FindStringSubmatch(majorPieces[i]); fields != nil {
-					//
-					// MODIFIER
-					//  | DC <n>
-					// Seek a value at least <n>
-					//
-					d.DC, err = strconv.Atoi(fields[1])
-					if err != nil {
-						return fmt.Errorf("value error in die roll DC clause: %v", err)
-					}
-				} else if fields := reModSF.FindStringSubmatch(majorPieces[i]); fields != nil {
-					//
-					// MODIFIER
-					//  | sf [<success>[/<fail>]]
-					// Set messages for successful and failed rolls.
-					//
-					d.sfOpt = fields[0]
-					if fields[1] != "" {
-						d.SuccessMessage = fields[1]
-						if fields[2] != "" {
-							d.FailMessage = fields[2]
-						} else {
-							// Guess the failure message based on the success
-							// message.
-							switch strings.ToLower(d.SuccessMessage) {
-							case "hit":
-								d.FailMessage = "MISS"
-							case "miss":
-								d.FailMessage = "HIT"
-							case "success", "succeed":
-								d.FailMessage = "FAIL"
-							case "fail":
-								d.FailMessage = "SUCCESS"
-							default:
-								d.FailMessage = "NOT " + d.SuccessMessage
-							}
-						}
-					} else {
-						d.SuccessMessage = "SUCCESS"
+					case "miss":
+						d.FailMessage = "HIT"
+					case "success", "succeed":
 						d.FailMessage = "FAIL"
+					case "fail":
+						d.FailMessage = "SUCCESS"
+					default:
+						d.FailMessage = "NOT " + d.SuccessMessage
 					}
-				} else {
-					return fmt.Errorf("global modifier option \"%s\" not understood; must be !, c, dc, min, max, maximized, sf, total, until, or repeat", majorPieces[i])
 				}
+			} else {
+				d.SuccessMessage = "SUCCESS"
+				d.FailMessage = "FAIL"
 			}
-		}
-	}
 
-	//
-	// The global options are all taken care of.
-	// What remains is the die roll spec itself, which may include
-	// permutations that we'll need to expand here.
-	//
-	// If there are one or more patterns like {<a>/<b>/.../<z>} in the
-	// string, make a copy of the spec for each of <a>, <b>, ... <z> in that
-	// position in the string. This will produce the cartesian product
-	// of the sets of values, e.g. "d20+{15/10/5}+2d6+{1/2}" will expand
-	// to:
-	//  "d20+15+2d6+1"
-	//  "d20+10+2d6+1"
-	//  "d20+5+2d6+1"
-	//  "d20+15+2d6+2"
-	//  "d20+10+2d6+2"
-	//  "d20+5+2d6+2"
-	//
-	spec = strings.Replace(spec, "//", "÷", -1)
-	if permList := rePermutations.FindAllStringSubmatch(spec, -1); permList != nil {
-		for _, perm := range permList {
-			valueset := strings.Split(perm[1], "/")
-			if len(valueset) < 2 {
-				return fmt.Errorf("invalid die-roll specification \"%s\": Values in braces must have more than one value separated by slashes", perm[0])
+		case DegreesModifier:
+			// Grade the result against the DC in steps of <n>
+			d.Degrees = 10
+			if m.Value != 0 {
+				d.Degrees = m.Value
 			}
-			plist := make([]any, len(valueset))
-			for i, p := range valueset {
-				plist[i] = p
+
+		case BandModifier:
+			// Report <label> as the outcome for results in the given range
+			d.Bands = append(d.Bands, m.Band)
+
+		case VersusModifier:
+			// Compare against an opposing roll
+			d.Opposed = spec[m.Opposed.Pos().Start:m.Opposed.Pos().End]
+			if d.opposed, err = New(withExpression(m.Opposed), withSharedGenerator(d.generator)); err != nil {
+				return fmt.Errorf("invalid opposing roll \"%s\": %v", d.Opposed, err)
 			}
-			d.Permutations = append(d.Permutations, plist)
 		}
-		//
-		// replace the {...} strings with placeholder tokens {0}, {1}, ... {n}
-		// to form a template into which we'll substitute all of the permuted values
-		// out of d.Permutations.
-		//
-		pos := -1
-		d.Template = rePermutations.ReplaceAllStringFunc(spec, func(_ string) string {
-			pos++
-			return "{" + strconv.Itoa(pos) + "}"
-		})
 	}
 
-	if fields := rePctRoll.FindStringSubmatch(spec); fields != nil {
+	if r.Chance != nil {
 		//
 		// Special case: <n>% rolls percentile dice and
 		// returns true with a probability of n%.
 		//
-		if d.Permutations != nil {
-			return fmt.Errorf("permutations with percentile die rolls are not supported")
-		}
-		if strings.Index(spec, "|") >= 0 {
-			return fmt.Errorf("invalid global modifier for percentile die rolls: \"%s\"", spec)
-		}
-		if d.Confirm {
-			return fmt.Errorf("you can't confirm critical percentile die rolls")
-		}
-		if d.DC != 0 {
-			return fmt.Errorf("you can't have a percentile die roll with a DC")
-		}
 		d.d, err = New(ByDieType(1, 100, 0), withSharedGenerator(d.generator))
 		if err != nil {
 			return err
 		}
-		d.PctChance, err = strconv.Atoi(fields[1])
-		if err != nil {
-			return err
+		d.PctChance = r.Chance.Percent
+		d.PctLabel = r.Chance.Label
+		return nil
+	}
+
+	//
+	// If there are one or more permutations like {<a>/<b>/.../<z>} in the
+	// expression, we'll roll a copy of it for each of <a>, <b>, ... <z> in
+	// that position. This will produce the cartesian product
+	// of the sets of values, e.g. "d20+{15/10/5}+2d6+{1/2}" will expand
+	// to:
+	//  "d20+15+2d6+1"
+	//  "d20+15+2d6+2"
+	//  "d20+10+2d6+1"
+	//  "d20+10+2d6+2"
+	//  "d20+5+2d6+1"
+	//  "d20+5+2d6+2"
+	//
+	// To do this, we replace each permutation with a placeholder token
+	// {0}, {1}, ... {n} to form a template into which we'll substitute all
+	// of the permuted values out of d.Permutations.
+	//
+	var template strings.Builder
+	next := r.Roll.Pos().Start
+	Inspect(r.Roll, func(n Node) bool {
+		perm, ok := n.(*Permutation)
+		if !ok {
+			return true
+		}
+		plist := make([]any, len(perm.Choices))
+		for i, choice := range perm.Choices {
+			plist[i] = choice.String()
+		}
+		template.WriteString(spec[next:perm.Start])
+		fmt.Fprintf(&template, "{%d}", len(d.Permutations))
+		next = perm.End
+		d.Permutations = append(d.Permutations, plist)
+		return false
+	})
+
+	if d.Permutations != nil {
+		template.WriteString(spec[next:r.Roll.Pos().End])
+		for _, m := range limits {
+			template.WriteString(" | " + m.String())
 		}
-		d.PctLabel = fields[2]
-	} else if d.Template == "" {
-		//
-		// Normal case: use the remaining string in spec to define a Dice object
-		// that we will subsequently roll using our local modifiers and such.
-		//
-		d.d, err = New(ByDescription(spec), withSharedGenerator(d.generator))
-		if err != nil {
-			return err
+		d.Template = template.String()
+		return nil
+	}
+
+	//
+	// Normal case: use the expression to define a Dice object
+	// that we will subsequently roll using our local modifiers and such.
+	//
+	d.d, err = New(withExpression(r.Roll), withSharedGenerator(d.generator))
+	if err != nil {
+		return err
+	}
+	for _, m := range limits {
+		if m.Kind == MinModifier {
+			d.d.MinValue = m.Value
+		} else {
+			d.d.MaxValue = m.Value
 		}
 	}
 	return nil
 }
 
+// OutcomeBand is a named range of die-roll results, as given by a
+// “| band” modifier. Low and High are the inclusive limits of the
+// range; a band open at one end has math.MinInt or math.MaxInt there.
+type OutcomeBand struct {
+	Low   int
+	High  int
+	Label string
+}
+
+// newOutcomeBand makes an OutcomeBand from the parts of a “| band”
+// modifier: the low end of the range, the “+” or “-” which follows it
+// (if any), the high end of the range (if any), and the label.
+func newOutcomeBand(low, suffix, high, label string) (OutcomeBand, error) {
+	var err error
+	b := OutcomeBand{Label: label}
+
+	if b.Low, err = strconv.Atoi(low); err != nil {
+		return b, fmt.Errorf("value error in die roll band clause: %v", err)
+	}
+	switch {
+	case suffix == "+":
+		b.High = math.MaxInt
+	case high != "":
+		if b.High, err = strconv.Atoi(high); err != nil {
+			return b, fmt.Errorf("value error in die roll band clause: %v", err)
+		}
+		if b.High < b.Low {
+			return b, fmt.Errorf("the range %d-%d in die roll band clause is backwards", b.Low, b.High)
+		}
+	case suffix == "-":
+		b.High = b.Low
+		b.Low = math.MinInt
+	default:
+		b.High = b.Low
+	}
+	return b, nil
+}
+
+// Contains reports whether the result n falls within the band.
+func (b OutcomeBand) Contains(n int) bool {
+	return n >= b.Low && n <= b.High
+}
+
+// Range describes the range of results included in the band,
+// in the same notation used in die-roll specifications
+// (e.g., "6-", "7-9", "10+", or "12").
+func (b OutcomeBand) Range() string {
+	switch {
+	case b.Low == math.MinInt:
+		return fmt.Sprintf("%d-", b.High)
+	case b.High == math.MaxInt:
+		return fmt.Sprintf("%d+", b.Low)
+	case b.Low == b.High:
+		return strconv.Itoa(b.Low)
+	}
+	return fmt.Sprintf("%d-%d", b.Low, b.High)
+}
+
+// String describes the band as it would appear in a “| band” modifier
+// (without the word “band”).
+func (b OutcomeBand) String() string {
+	return b.Range() + " " + b.Label
+}
+
 // DoRoll rolls dice as described by the specification string. If this string is empty,
 // it re-rolls the previously-used specification. Initially, "1d20" is assumed.
 //
 // Returns the user-specified die-roll label (if any), the result of the roll,
-// and an error if one occurred.
+// and an error if one occurred. If the specification is not valid, the error
+// is a *SyntaxError which gives the location of the problem (see Parse).
 //
 // In this more comprehensive interface, the spec string is a string of
 // the form
@@ -2042,6 +2345,33 @@ func (d *DieRoller) setNewSpecification(spec string) error {
 // the spec "3d6 | maximized" will always return the result 18, as if
 // all three dice rolled sixes.
 //
+//	| vs <expression>
+//
+// This is an opposed roll. Each time the dice are rolled, the
+// <expression> (which is anything that may be given to the New
+// constructor, but without permutations) is rolled as well, and the
+// result reports whether the roll won, lost, or tied against it,
+// and by how much.
+//
+//	| degrees [<n>]
+//
+// Grade the roll against the DC given by a “| dc” option, or against
+// the opposing roll given by a “| vs” option. The roll is a critical
+// success if it beats the DC by <n> or more, a success if it otherwise
+// meets the DC, a critical failure if it misses the DC by <n> or more,
+// and otherwise a failure. If the roll involves a single die, a natural
+// maximum roll improves this by one degree and a natural 1 worsens it
+// by one degree. If <n> is not given, it is 10.
+//
+//	| band <range> <label>
+//
+// Report <label> as the outcome if the result falls within <range>,
+// which may be a single number <n>, an inclusive range <n>-<m>,
+// <n>+ (meaning <n> or more), or <n>- (meaning <n> or less).
+// Any number of these options may be given; the first one whose
+// range includes the result is reported. For example,
+// “2d6+1 | band 6- miss | band 7-9 weak hit | band 10+ strong hit”.
+//
 // To prevent getting caught in an infinite loop, a maximum of  100  rolls
 // will be made regardless of repeat, total, and until options.
 //
@@ -2054,6 +2384,21 @@ func (d *DieRoller) setNewSpecification(spec string) error {
 // “Attack=d20+{17/12/7}”  would  roll  three  attack rolls: d20+17,
 // d20+12, and d20+7.
 //
+// Before any of the above is interpreted, references to named variables
+// such as “$str” or “${bab}” are replaced by their values, if variables
+// were supplied via WithVariables or SetVariables. This allows a spec like
+// “d20+$bab+$str|c” to follow a character's changing ability scores.
+// See ExpandVariables for details.
+//
+// A spec of the form “[<title>=]@<table>[|repeat <n>]” rolls on the named
+// random table, if tables were supplied via WithTables or SetTables. No
+// other die rolls, labels, or global modifiers may be combined with it.
+// Each result's Result field holds the die roll which chose the table entry,
+// and its details report the table's name (as type “table”), the text of
+// the entry chosen with any die rolls and other tables it refers to already
+// rolled (“tableresult”), and each of those rolls (“tableroll”).
+// See RandomTable for details.
+//
 // In the second form for the spec string,
 // <chance> gives the  percentage  chance  of  something
 // occurring,  causing  percentile dice to be rolled. The result will be a
@@ -2115,6 +2460,18 @@ func (d *DieRoller) DoRoll(spec string) (string, []StructuredResult, error) {
 		}
 	}
 
+	if d.table != "" {
+		var results []StructuredResult
+		for i := 0; i < d.RepeatFor; i++ {
+			result, err := d.rollOnTable()
+			if err != nil {
+				return "", nil, err
+			}
+			results = append(results, result)
+		}
+		return d.LabelText, results, nil
+	}
+
 	var overallResults []StructuredResult
 	var results []StructuredResult
 	var result int
@@ -2127,8 +2484,9 @@ func (d *DieRoller) DoRoll(spec string) (string, []StructuredResult, error) {
 			// If we're working with a set of permutations, expand them now
 			// into their Cartesian product so we can then substitute each set
 			// of those values into the template for each roll of the dice.
-			iterlist := cartesian.Iter(d.Permutations...)
-			for iteration := range iterlist {
+			// These are always rolled in the same order so that a roll
+			// repeated with the same seed gives the same results.
+			for _, iteration := range permutationsOf(d.Permutations) {
 				d.d, err = New(
 					ByDescription(substituteTemplateValues(d.Template, iteration)),
 					withSharedGenerator(d.generator))
@@ -2200,6 +2558,16 @@ func (d *DieRoller) ExplainSecretRoll(spec, notice string) (string, StructuredRe
 		}
 	}
 	thisResult = append(thisResult, StructuredDescription{Type: "notice", Value: notice})
+	if d.table != "" {
+		thisResult = append(thisResult, StructuredDescription{Type: "table", Value: d.table})
+		if d.RepeatFor > 1 {
+			thisResult = append(thisResult,
+				StructuredDescription{Type: "moddelim", Value: "|"},
+				StructuredDescription{Type: "repeat", Value: strconv.Itoa(d.RepeatFor)},
+			)
+		}
+		return d.LabelText, StructuredResult{ResultSuppressed: true, Details: thisResult}, nil
+	}
 
 	//
 	// How to report back on the options (aka modifiers) in play for the die roll.
@@ -2251,6 +2619,24 @@ func (d *DieRoller) ExplainSecretRoll(spec, notice string) (string, StructuredRe
 				StructuredDescription{Type: "sf", Value: d.sfOpt},
 			)
 		}
+		if d.opposed != nil {
+			thisResult = append(thisResult,
+				StructuredDescription{Type: "moddelim", Value: "|"},
+				StructuredDescription{Type: "vs", Value: d.Opposed},
+			)
+		}
+		if d.Degrees > 0 {
+			thisResult = append(thisResult,
+				StructuredDescription{Type: "moddelim", Value: "|"},
+				StructuredDescription{Type: "degrees", Value: strconv.Itoa(d.Degrees)},
+			)
+		}
+		for _, band := range d.Bands {
+			thisResult = append(thisResult,
+				StructuredDescription{Type: "moddelim", Value: "|"},
+				StructuredDescription{Type: "band", Value: band.String()},
+			)
+		}
 	}
 
 	//
@@ -2330,6 +2716,23 @@ func (d *DieRoller) ExplainSecretRoll(spec, notice string) (string, StructuredRe
 	return d.LabelText, StructuredResult{ResultSuppressed: true, Details: thisResult}, nil
 }
 
+// permutationsOf returns the Cartesian product of the lists of values,
+// in order, so that for "{17/12}+{1/2}" we get 17,1 then 17,2 then 12,1
+// then 12,2.
+func permutationsOf(lists [][]any) [][]any {
+	product := [][]any{nil}
+	for _, list := range lists {
+		var next [][]any
+		for _, prefix := range product {
+			for _, value := range list {
+				next = append(next, append(slices.Clone(prefix), value))
+			}
+		}
+		product = next
+	}
+	return product
+}
+
 // utility function to replace placeholders {0}, {1}, {2}, ... in an input string
 // with corresponding values taken from a list of substitution values, returning
 // the resulting string.
@@ -2347,7 +2750,7 @@ func substituteTemplateValues(template string, values []any) string {
 func (d *DieRoller) rollDice(repeatIter, repeatCount, repeatTotal int) (int, []StructuredResult, int, error) {
 	var results []StructuredResult
 	var thisResult []StructuredDescription
-	var result int
+	var result, opposedResult int
 	var err error
 
 	//
@@ -2425,6 +2828,37 @@ func (d *DieRoller) rollDice(repeatIter, repeatCount, repeatTotal int) (int, []S
 				StructuredDescription{Type: "sf", Value: d.sfOpt},
 			)
 		}
+		if d.opposed != nil {
+			thisResult = append(thisResult,
+				StructuredDescription{Type: "moddelim", Value: "|"},
+				StructuredDescription{Type: "vs", Value: d.Opposed},
+				StructuredDescription{Type: "opposed", Value: strconv.Itoa(opposedResult)},
+				describeOpposedRoll(opposedResult, result),
+			)
+		}
+		if d.Degrees > 0 {
+			target := d.DC
+			if d.opposed != nil {
+				target = opposedResult
+			}
+			thisResult = append(thisResult,
+				StructuredDescription{Type: "moddelim", Value: "|"},
+				StructuredDescription{Type: "degrees", Value: strconv.Itoa(d.Degrees)},
+				d.describeDegree(target, result),
+			)
+		}
+		for _, band := range d.Bands {
+			thisResult = append(thisResult,
+				StructuredDescription{Type: "moddelim", Value: "|"},
+				StructuredDescription{Type: "band", Value: band.String()},
+			)
+		}
+		for _, band := range d.Bands {
+			if band.Contains(result) {
+				thisResult = append(thisResult, StructuredDescription{Type: "outcome", Value: band.Label})
+				break
+			}
+		}
 	}
 
 	//
@@ -2506,6 +2940,11 @@ func (d *DieRoller) rollDice(repeatIter, repeatCount, repeatTotal int) (int, []S
 		if err != nil {
 			return 0, nil, repeatTotal, err
 		}
+		if d.opposed != nil {
+			if opposedResult, err = d.opposed.MaxRoll(); err != nil {
+				return 0, nil, repeatTotal, err
+			}
+		}
 		repeatTotal += result
 		if d.PctChance >= 0 {
 			reportPctRoll(d.PctChance, d.PctLabel, true)
@@ -2552,6 +2991,11 @@ func (d *DieRoller) rollDice(repeatIter, repeatCount, repeatTotal int) (int, []S
 		if err != nil {
 			return 0, nil, repeatTotal, err
 		}
+		if d.opposed != nil {
+			if opposedResult, err = d.opposed.Roll(); err != nil {
+				return 0, nil, repeatTotal, err
+			}
+		}
 		repeatTotal += result
 		if d.PctChance >= 0 {
 			reportPctRoll(d.PctChance, d.PctLabel, false)
@@ -2591,6 +3035,52 @@ func (d *DieRoller) rollDice(repeatIter, repeatCount, repeatTotal int) (int, []S
 	return result, results, repeatTotal, nil
 }
 
+// describeOpposedRoll describes how a roll with the given result fared
+// against an opposing roll, with the margin by which it won or lost.
+func describeOpposedRoll(opposed, result int) (desc StructuredDescription) {
+	if result > opposed {
+		desc.Type = "won"
+		desc.Value = strconv.Itoa(result - opposed)
+	} else if result == opposed {
+		desc.Type = "tied"
+		desc.Value = "0"
+	} else {
+		desc.Type = "lost"
+		desc.Value = strconv.Itoa(opposed - result)
+	}
+	return
+}
+
+// The degrees of success, from worst to best, as reported by describeDegree.
+var degreesOfSuccess = []StructuredDescription{
+	{Type: "critfail", Value: "critical failure"},
+	{Type: "fail", Value: "failure"},
+	{Type: "success", Value: "success"},
+	{Type: "critsuccess", Value: "critical success"},
+}
+
+// describeDegree grades a roll with the given result against the target
+// number as one of the degreesOfSuccess. A natural maximum or natural 1 on
+// a single die improves or worsens the grade by one step.
+func (d *DieRoller) describeDegree(target, result int) StructuredDescription {
+	var degree int
+
+	switch {
+	case result >= target+d.Degrees:
+		degree = 3
+	case result >= target:
+		degree = 2
+	case result > target-d.Degrees:
+		degree = 1
+	}
+	if d.IsNaturalMax() {
+		degree = min(degree+1, 3)
+	} else if d.IsNatural1() {
+		degree = max(degree-1, 0)
+	}
+	return degreesOfSuccess[degree]
+}
+
 // Roll rolls the dice specified by the specification string, without
 // requiring a separate step to create a DieRoller first.
 //
@@ -2662,7 +3152,7 @@ func (d *DieRoller) IsNatural1() (result bool) {
 }
 
 func (d *DieRoller) isNatural(checkForMax bool) (result bool) {
-	if !d.d.Rolled {
+	if d.d == nil || !d.d.Rolled {
 		return
 	}
 
@@ -2697,6 +3187,9 @@ func (sr StructuredDescriptionSet) Text() (string, error) {
 
 	for _, r := range sr {
 		switch r.Type {
+		case "band":
+			fmt.Fprintf(&t, "band %s ", r.Value)
+
 		case "best":
 			fmt.Fprintf(&t, " (best of %s) ", r.Value)
 
@@ -2716,16 +3209,49 @@ func (sr StructuredDescriptionSet) Text() (string, error) {
 		case "dc":
 			fmt.Fprintf(&t, "DC %s ", r.Value)
 
+		case "degrees":
+			fmt.Fprintf(&t, "degrees %s ", r.Value)
+
 		case "diespec", "maximized", "operator":
 			fmt.Fprintf(&t, "%s", r.Value)
 
 		case "discarded":
 			fmt.Fprintf(&t, "{discarded %s}", r.Value)
 
+		case "dropped":
+			fmt.Fprintf(&t, "{dropped %s}", r.Value)
+
+		case "again", "botch", "explode", "keep", "reroll", "target", "wild":
+			fmt.Fprintf(&t, "%s", r.Value)
+
+		case "botched":
+			fmt.Fprintf(&t, "{botched %s}", r.Value)
+
+		case "botches":
+			fmt.Fprintf(&t, "(%s botches)", r.Value)
+
+		case "extra":
+			fmt.Fprintf(&t, "{+%s}", r.Value)
+
+		case "wildroll":
+			fmt.Fprintf(&t, "{wild %s}", r.Value)
+
+		case "wilddropped":
+			fmt.Fprintf(&t, "{dropped wild %s}", r.Value)
+
+		case "exploded":
+			fmt.Fprintf(&t, "{exploded %s}", r.Value)
+
+		case "rerolled":
+			fmt.Fprintf(&t, "{rerolled %s}", r.Value)
+
+		case "successes":
+			fmt.Fprintf(&t, "(%s successes)", r.Value)
+
 		case "exceeded":
 			fmt.Fprintf(&t, "(EXCEEDED DC by %s) ", r.Value)
 
-		case "fail", "success":
+		case "critfail", "critsuccess", "fail", "success":
 			fmt.Fprintf(&t, "(%s) ", r.Value)
 
 		case "iteration":
@@ -2746,9 +3272,18 @@ func (sr StructuredDescriptionSet) Text() (string, error) {
 		case "min":
 			fmt.Fprintf(&t, " (min %s) ", r.Value)
 
+		case "lost":
+			fmt.Fprintf(&t, "(LOST by %s) ", r.Value)
+
 		case "notice":
 			fmt.Fprintf(&t, "[%s] ", r.Value)
 
+		case "opposed":
+			fmt.Fprintf(&t, "[%s] ", r.Value)
+
+		case "outcome":
+			fmt.Fprintf(&t, "(%s) ", strings.ToUpper(r.Value))
+
 		case "repeat":
 			fmt.Fprintf(&t, "(x%s) ", r.Value)
 
@@ -2764,12 +3299,30 @@ func (sr StructuredDescriptionSet) Text() (string, error) {
 		case "subtotal":
 			fmt.Fprintf(&t, "(%s)", r.Value)
 
+		case "table":
+			fmt.Fprintf(&t, " %s: ", r.Value)
+
+		case "tableresult":
+			fmt.Fprintf(&t, "%s ", r.Value)
+
+		case "tableroll":
+			fmt.Fprintf(&t, "{%s}", r.Value)
+
+		case "tied":
+			fmt.Fprintf(&t, "(TIED) ")
+
 		case "total":
 			fmt.Fprintf(&t, " (until total %s) ", r.Value)
 
 		case "until":
 			fmt.Fprintf(&t, " (until %s) ", r.Value)
 
+		case "vs":
+			fmt.Fprintf(&t, "vs %s ", r.Value)
+
+		case "won":
+			fmt.Fprintf(&t, "(WON by %s) ", r.Value)
+
 		case "worst":
 			fmt.Fprintf(&t, " (worst of %s) ", r.Value)
 
@@ -3015,7 +3568,7 @@ const DieRollExpressionSyntax = `
 ==[Die-Roll Expression Syntax]==
 The general form for die roll expressions is:
 
-[//name//**=**] [//qty//[**/**//div//]] **d** //sides// [**best**|**worst of** //n//] [...] [**|**//modifiers]
+[//name//**=**] [//qty//[**/**//div//]] **d** //sides// [**!**] [**r**[**o**]//n//] [**a**//n//] [**kh**|**kl**//n//] [**w**//n//] [**s**//n//[**b**//n//]] [**best**|**worst of** //n//] [...] [**|**//modifiers]
 
 (Here, **bold** text means to type something literally as shown; //italics// indicates values to substitute, and 
 [square brackets] surround optional components.)
@@ -3049,6 +3602,29 @@ If you put “**best of** //n//” or “**worst of** //n//” after a dice valu
 that may times and take the best (or worst) of all those rolls to use for that set of dice. Note that this is part of the dice,
 not the overall expression so you would say, for example, “**3d6 best of 3 + 12**” and not “**3d6+12 best of 3**”.
 
+==(Individual Dice)==
+These options may be placed right after the number of sides on the dice (in this order) to change how each individual die is counted:
+
+**!** (Exploding dice: whenever a die comes up with its maximum value, roll it again and add the new roll to it. For example, “**3d6!**”.)
+
+**r** //n// (Reroll any die which comes up //n// or less, until it comes up higher. For example, “**d20 r1**”.)
+
+**ro** //n// (As **r** but only reroll once, keeping the new roll no matter what.)
+
+**a** //n// (Roll again: for each die which comes up //n// or higher, roll another die and count it as well. For example, “**8d10 a10 s8**”.)
+
+**kh** //n// (Keep only the highest //n// dice, dropping the rest. For example, “**4d6kh3**”, or “**2d20kh1**” to roll with advantage.)
+
+**kl** //n// (Keep only the lowest //n// dice.)
+
+**w** //n// (Also roll a wild die with //n// sides, which replaces the lowest of the other dice if it rolled higher. For example, “**d8! w6**”.)
+
+**s** //n// (Instead of adding the dice together, count how many of them came up //n// or higher. For example, “**10d10 s8**”.)
+
+**b** //n// (After **s**, subtract one success for each die which came up //n// or less. For example, “**6d10 s6 b1**”.)
+
+The results will show each die rolled, noting which ones exploded, were rerolled, were dropped, were added by rolling again, or botched, and which was the wild die.
+
 ==(Percentile Rolls)==
 You can use “**d%**” to roll a d100 or “percentile” die as part of any die-roll expression.
 To just get a roll to indicate something that is successful //x// percent of the time, the special die-roll form
@@ -3108,6 +3684,10 @@ meaning of the whole thing. Each option begins with a vertical bar (**|**) chara
 
 **|dc** //n// (Indicate that the roll was a “success” if the result was at least //n//.)
 
+**|degrees** [//n//] (Grade the roll against the **dc** or **vs** option: a critical success if it beats the DC by //n// or more, a success if it otherwise meets it, a critical failure if it misses by //n// or more, or otherwise a failure. A natural 20 (or whatever the die's maximum is) or natural 1 on a single die moves this up or down one degree. If //n// is not given, it is 10.)
+
+**|band** //range// //label// (Report //label// as the outcome if the result is within //range//, which may be a single number, a range such as **7-9**, or a number followed by **+** or **--** to mean that number or more (or less). Give as many as you need; the first one which matches is reported. For example, “**2d6+1|band 6- miss|band 7-9 weak hit|band 10+ strong hit**”.)
+
 **|maximized** (All die rolls are forced to their maximum possible values.)
 
 **|repeat** //n// (Roll //n// times.)
@@ -3120,6 +3700,8 @@ meaning of the whole thing. Each option begins with a vertical bar (**|**) chara
 
 **|until** //n// (Continue rolling until the result is at least //n//.)
 
+**|vs** //expression// (This is an opposed roll. Also roll //expression// and report whether this roll won, lost, or tied against it.)
+
 ==(Permutations)==
 If a slash-separated set of values appear between curly braces, the die-roll will be executed once for each of the values in turn.
 For example, the die roll “**d20+{17/12/7/2}+5**” is the same as making the four separate rolls
@@ -3138,6 +3720,22 @@ Any arbitrary text may appear in the braces; the permutations and substitutions
 and evaluated.
 
 The order in which the permuted rolls are performed is not defined.
+
+==(Variables)==
+If you have defined die-roll variables, you can refer to them anywhere in the die-roll string as “**$**//name//”
+or “**${**//name//**}**”. Each reference is replaced by the variable's value before anything else in the string is
+interpreted. For example, if **str** is **4** and **bab** is **6**, then “**d20+$bab+$str|c**” is the same as
+“**d20+6+4|c**”. The value may be any part of a die roll, including dice and labels, and may itself refer to other
+variables. Use “**$$**” if you need a literal dollar sign.
+
+==(Random Tables)==
+If random tables have been defined, you can roll on one by giving its name after an at-sign (**@**) in place of the
+whole die-roll expression, as in “**@Wandering Monsters**” or “**Night 2=@Wandering Monsters**”. The result shows
+the roll and the table entry it chose. Any die rolls in square brackets in that entry are rolled, and any
+table names in square brackets after an at-sign are rolled on in turn, so an entry like
+“**[2d4] orcs carrying [@Orc Treasure]**” might come out as “**5 orcs carrying 12 gold pieces**”.
+A table roll may be followed by “**|repeat** //n//” to roll on the table //n// times, but it can't be combined
+with other die rolls, labels, or global modifiers.
 `
 
 /*
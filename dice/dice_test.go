@@ -27,6 +27,7 @@ import (
 	"log"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -542,8 +543,8 @@ func TestDicePermutations(t *testing.T) {
 			"[2] = 2+4÷6",
 		}},
 		{Roll: "2+{1/2//3/4}", PermutedRolls: []string{
-			"[2] = 2+2÷3",
 			"[3] = 2+1",
+			"[2] = 2+2÷3",
 			"[6] = 2+4",
 		}},
 	}
@@ -564,7 +565,6 @@ func TestDicePermutations(t *testing.T) {
 			}
 			pr = append(pr, s)
 		}
-		slices.Sort(pr)
 		if !slices.Equal(pr, test.PermutedRolls) {
 			t.Fatalf("test case %d results don't match: %q", i, pr)
 		}
@@ -2155,6 +2155,340 @@ func TestDicePrivateRolls(t *testing.T) {
 	}
 }
 
+func TestDicePerDieOptions(t *testing.T) {
+	d, err := NewDieRoller(WithSeed(12345))
+	if err != nil {
+		t.Fatalf("Error creating new DieRoller: %v", err)
+	}
+
+	countTypes := func(details StructuredDescriptionSet) map[string][]string {
+		found := make(map[string][]string)
+		for _, detail := range details {
+			found[detail.Type] = append(found[detail.Type], detail.Value)
+		}
+		return found
+	}
+
+	for i := 0; i < 200; i++ {
+		_, r, err := d.DoRollOnce("4d6kh3")
+		if err != nil {
+			t.Fatalf("4d6kh3: %v", err)
+		}
+		found := countTypes(r.Details)
+		if len(found["roll"]) != 3 || len(found["dropped"]) != 1 {
+			t.Fatalf("4d6kh3 kept %v and dropped %v", found["roll"], found["dropped"])
+		}
+		sum := 0
+		for _, v := range found["roll"] {
+			n, _ := strconv.Atoi(v)
+			sum += n
+			if dropped, _ := strconv.Atoi(found["dropped"][0]); dropped > n {
+				t.Fatalf("4d6kh3 dropped %d but kept %d", dropped, n)
+			}
+		}
+		if sum != r.Result {
+			t.Fatalf("4d6kh3 result %d but kept dice %v", r.Result, found["roll"])
+		}
+
+		_, r, err = d.DoRollOnce("d20 r1")
+		if err != nil {
+			t.Fatalf("d20 r1: %v", err)
+		}
+		if r.Result < 2 || r.Result > 20 {
+			t.Fatalf("d20 r1 result %d out of range", r.Result)
+		}
+
+		_, r, err = d.DoRollOnce("10d10 s8")
+		if err != nil {
+			t.Fatalf("10d10 s8: %v", err)
+		}
+		found = countTypes(r.Details)
+		successes := 0
+		for _, v := range found["roll"] {
+			if n, _ := strconv.Atoi(v); n >= 8 {
+				successes++
+			}
+		}
+		if len(found["roll"]) != 10 || successes != r.Result || found["successes"][0] != strconv.Itoa(successes) {
+			t.Fatalf("10d10 s8 result %d from %v", r.Result, r.Details)
+		}
+
+		_, r, err = d.DoRollOnce("2d4!")
+		if err != nil {
+			t.Fatalf("2d4!: %v", err)
+		}
+		sum = 0
+		for _, detail := range r.Details {
+			switch detail.Type {
+			case "roll":
+				n, _ := strconv.Atoi(detail.Value)
+				if n == 4 {
+					t.Fatalf("2d4! rolled a 4 which did not explode: %v", r.Details)
+				}
+				sum += n
+			case "exploded":
+				rolls := strings.Split(detail.Value, ",")
+				for j, v := range rolls {
+					n, _ := strconv.Atoi(v)
+					if (n == 4) != (j < len(rolls)-1) {
+						t.Fatalf("2d4! explosion %v is incorrect", rolls)
+					}
+					sum += n
+				}
+			}
+		}
+		if sum != r.Result {
+			t.Fatalf("2d4! result %d from %v", r.Result, r.Details)
+		}
+	}
+
+	_, r, err := d.DoRollOnce("4d6kh3 str | maximized")
+	if err != nil {
+		t.Fatalf("maximized: %v", err)
+	}
+	if !compareSingleResult(r, StructuredResult{Result: 18, Details: StructuredDescriptionSet{
+		{Type: "result", Value: "18"},
+		{Type: "separator", Value: "="},
+		{Type: "diespec", Value: "4d6"},
+		{Type: "keep", Value: "kh3"},
+		{Type: "subtotal", Value: "18"},
+		{Type: "maxroll", Value: "6"},
+		{Type: "maxroll", Value: "6"},
+		{Type: "maxroll", Value: "6"},
+		{Type: "dropped", Value: "6"},
+		{Type: "label", Value: "str"},
+		{Type: "moddelim", Value: "|"},
+		{Type: "fullmax", Value: "maximized"},
+	}}) {
+		t.Fatalf("maximized 4d6kh3 gave %v", r)
+	}
+
+	_, results, err := d.DoRoll("2d20kh1+5 | c")
+	if err != nil {
+		t.Fatalf("2d20kh1+5 | c: %v", err)
+	}
+	if len(results) < 1 || len(results) > 2 {
+		t.Fatalf("2d20kh1+5 | c gave %d results", len(results))
+	}
+
+	for _, spec := range []string{"d1!", "d6 r6", "3d6kh4", "3d6kl0", ">3d6kh2", "10d10 s0"} {
+		if _, _, err := d.DoRoll(spec); err == nil {
+			t.Errorf("die roll \"%s\" should have been rejected", spec)
+		}
+	}
+	for _, spec := range []string{"d20 r1 ro1", "2d6kh1 kl1", "d20 s8 r1", "3d6 kh2 !", "d6 fire w6", "4d6 best of 2 kh3"} {
+		if _, _, err := d.DoRoll(spec); err == nil || !strings.Contains(err.Error(), "out of place") {
+			t.Errorf("die roll \"%s\" should have been rejected for an out-of-place option, but got error %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"d6 rapier", "d8 kinetic", "d4 slashing"} {
+		if _, r, err := d.DoRollOnce(spec); err != nil {
+			t.Errorf("die roll \"%s\" failed: %v", spec, err)
+		} else if found := countTypes(r.Details); len(found["label"]) != 1 || len(found["roll"]) != 1 {
+			t.Errorf("die roll \"%s\" label not recognized: %v", spec, r.Details)
+		}
+	}
+}
+
+func TestDicePools(t *testing.T) {
+	d, err := NewDieRoller(WithSeed(12345))
+	if err != nil {
+		t.Fatalf("Error creating new DieRoller: %v", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		_, r, err := d.DoRollOnce("6d10 a10 s8 b1")
+		if err != nil {
+			t.Fatalf("6d10 a10 s8 b1: %v", err)
+		}
+		var dice, tens, successes, botches int
+		for _, detail := range r.Details {
+			switch detail.Type {
+			case "roll", "extra", "botched":
+				n, _ := strconv.Atoi(detail.Value)
+				dice++
+				if n == 10 {
+					tens++
+				}
+				if n >= 8 {
+					successes++
+				} else if n == 1 {
+					botches++
+				}
+				if (detail.Type == "botched") != (n == 1) {
+					t.Fatalf("6d10 a10 s8 b1 die %v marked incorrectly in %v", detail, r.Details)
+				}
+			}
+		}
+		if dice != 6+tens {
+			t.Fatalf("6d10 a10 s8 b1 rolled %d dice for %d tens: %v", dice, tens, r.Details)
+		}
+		if r.Result != successes-botches {
+			t.Fatalf("6d10 a10 s8 b1 result %d for %d successes and %d botches: %v", r.Result, successes, botches, r.Details)
+		}
+
+		_, r, err = d.DoRollOnce("d8 w6")
+		if err != nil {
+			t.Fatalf("d8 w6: %v", err)
+		}
+		var kept, dropped int
+		for _, detail := range r.Details {
+			switch detail.Type {
+			case "roll":
+				kept, _ = strconv.Atoi(detail.Value)
+			case "wildroll":
+				for _, v := range strings.Split(detail.Value, ",") {
+					n, _ := strconv.Atoi(v)
+					kept += n
+				}
+			case "dropped":
+				dropped, _ = strconv.Atoi(detail.Value)
+			}
+		}
+		if kept != r.Result || dropped > kept || r.Result < 1 {
+			t.Fatalf("d8 w6 result %d from %v", r.Result, r.Details)
+		}
+	}
+
+	var wildDropped bool
+	for i := 0; i < 200 && !wildDropped; i++ {
+		_, r, err := d.DoRollOnce("d8! w6")
+		if err != nil {
+			t.Fatalf("d8! w6: %v", err)
+		}
+		var wild, plain []string
+		for _, detail := range r.Details {
+			switch detail.Type {
+			case "wildroll", "wilddropped":
+				wild = append(wild, detail.Value)
+				wildDropped = wildDropped || detail.Type == "wilddropped"
+			case "roll", "exploded", "dropped":
+				plain = append(plain, detail.Value)
+			}
+		}
+		if len(wild) != 1 || len(plain) != 1 {
+			t.Fatalf("d8! w6 should describe one wild die and one other die: %v", r.Details)
+		}
+		if text, err := r.Details.Text(); err != nil || (wildDropped && !strings.Contains(text, "{dropped wild ")) {
+			t.Fatalf("d8! w6 dropped wild die not shown: %s (%v)", text, err)
+		}
+	}
+	if !wildDropped {
+		t.Fatalf("d8! w6 never dropped the wild die")
+	}
+
+	for _, spec := range []string{"d6 a1", "d6 a7", "d6! a6", "3d6 a6 kh2", "3d6 kh2 w6", "d6 r3 w3", "d6 w1", "d6 s3 b3", "d6 s3 b0"} {
+		if _, _, err := d.DoRoll(spec); err == nil {
+			t.Errorf("die roll \"%s\" should have been rejected", spec)
+		}
+	}
+}
+
+func TestDiceOutcomes(t *testing.T) {
+	d, err := NewDieRoller(WithSeed(12345))
+	if err != nil {
+		t.Fatalf("Error creating new DieRoller: %v", err)
+	}
+
+	countTypes := func(details StructuredDescriptionSet) map[string][]string {
+		found := make(map[string][]string)
+		for _, detail := range details {
+			found[detail.Type] = append(found[detail.Type], detail.Value)
+		}
+		return found
+	}
+
+	degrees := []string{"critfail", "fail", "success", "critsuccess"}
+	for i := 0; i < 200; i++ {
+		_, r, err := d.DoRollOnce("d20+7 | dc 18 | degrees")
+		if err != nil {
+			t.Fatalf("degrees: %v", err)
+		}
+		found := countTypes(r.Details)
+		natural, _ := strconv.Atoi(found["roll"][0])
+		expected := 1
+		switch {
+		case r.Result >= 28:
+			expected = 3
+		case r.Result >= 18:
+			expected = 2
+		case r.Result <= 8:
+			expected = 0
+		}
+		if natural == 20 && expected < 3 {
+			expected++
+		} else if natural == 1 && expected > 0 {
+			expected--
+		}
+		for j, degree := range degrees {
+			if (len(found[degree]) == 1) != (j == expected) {
+				t.Fatalf("degrees: result %d (natural %d) reported as %v", r.Result, natural, r.Details)
+			}
+		}
+
+		_, r, err = d.DoRollOnce("2d6+1 | band 6- miss | band 7-9 weak hit | band 10+ strong hit")
+		if err != nil {
+			t.Fatalf("bands: %v", err)
+		}
+		found = countTypes(r.Details)
+		outcome := "strong hit"
+		if r.Result <= 6 {
+			outcome = "miss"
+		} else if r.Result <= 9 {
+			outcome = "weak hit"
+		}
+		if len(found["band"]) != 3 || len(found["outcome"]) != 1 || found["outcome"][0] != outcome {
+			t.Fatalf("bands: result %d reported as %v", r.Result, r.Details)
+		}
+
+		_, r, err = d.DoRollOnce("d20+5 | vs d20+3")
+		if err != nil {
+			t.Fatalf("opposed: %v", err)
+		}
+		found = countTypes(r.Details)
+		opposed, _ := strconv.Atoi(found["opposed"][0])
+		if opposed < 4 || opposed > 23 {
+			t.Fatalf("opposed: opposing roll %d out of range", opposed)
+		}
+		switch {
+		case r.Result > opposed && (len(found["won"]) != 1 || found["won"][0] != strconv.Itoa(r.Result-opposed)),
+			r.Result < opposed && (len(found["lost"]) != 1 || found["lost"][0] != strconv.Itoa(opposed-r.Result)),
+			r.Result == opposed && len(found["tied"]) != 1:
+			t.Fatalf("opposed: result %d reported as %v", r.Result, r.Details)
+		}
+	}
+
+	_, r, err := d.DoRollOnce("d20+2 | vs d6 | degrees 5 | maximized")
+	if err != nil {
+		t.Fatalf("maximized: %v", err)
+	}
+	if !compareSingleResult(r, StructuredResult{Result: 22, Details: StructuredDescriptionSet{
+		{Type: "result", Value: "22"},
+		{Type: "separator", Value: "="},
+		{Type: "diespec", Value: "1d20"},
+		{Type: "maxroll", Value: "20"},
+		{Type: "operator", Value: "+"},
+		{Type: "constant", Value: "2"},
+		{Type: "moddelim", Value: "|"},
+		{Type: "vs", Value: "d6"},
+		{Type: "opposed", Value: "6"},
+		{Type: "won", Value: "16"},
+		{Type: "moddelim", Value: "|"},
+		{Type: "degrees", Value: "5"},
+		{Type: "critsuccess", Value: "critical success"},
+		{Type: "moddelim", Value: "|"},
+		{Type: "fullmax", Value: "maximized"},
+	}}) {
+		t.Fatalf("maximized opposed roll gave %v", r)
+	}
+
+	for _, spec := range []string{"d20 | degrees", "d20 | dc 5 | degrees 0", "d20 | dc 5 | vs d20", "40% | band 1 yes", "d20 | band 5-1 x", "d20 | vs {1/2}"} {
+		if _, _, err := d.DoRoll(spec); err == nil {
+			t.Errorf("die roll \"%s\" should have been rejected", spec)
+		}
+	}
+}
+
 // @[00]@| Go-GMA 5.26.0
 // @[01]@|
 // @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
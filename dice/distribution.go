@@ -0,0 +1,746 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+///////////////////////////////////////////////////////////////////////////////
+//                                                                           //
+//                         Dice Probability Distributions                    //
+//                                                                           //
+// Exact calculation of the odds of each possible outcome of a die roll.     //
+//                                                                           //
+///////////////////////////////////////////////////////////////////////////////
+
+package dice
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Distribution describes the exact probability distribution of the results
+// of a die-roll expression, as calculated by the Distribution methods of
+// the Dice and DieRoller types.
+//
+// For example, the distribution of "2d6" has Min=2, Max=12, Mean=7, and
+// P[5] (the probability of rolling Min+5, or 7) of 1/6.
+type Distribution struct {
+	// The smallest and largest possible results.
+	Min int
+	Max int
+
+	// The expected (average) result, and the variance of the results.
+	Mean     float64
+	Variance float64
+
+	// P[i] is the probability that the result will be Min+i.
+	P []float64
+}
+
+// newDistribution builds a Distribution from a map of possible results to their probabilities.
+func newDistribution(results map[int]float64) Distribution {
+	var dist Distribution
+
+	if len(results) == 0 {
+		return dist
+	}
+	first := true
+	for v := range results {
+		if first || v < dist.Min {
+			dist.Min = v
+		}
+		if first || v > dist.Max {
+			dist.Max = v
+		}
+		first = false
+	}
+	dist.P = make([]float64, dist.Max-dist.Min+1)
+	for v, p := range results {
+		dist.P[v-dist.Min] = p
+		dist.Mean += float64(v) * p
+	}
+	for v, p := range results {
+		dist.Variance += (float64(v) - dist.Mean) * (float64(v) - dist.Mean) * p
+	}
+	return dist
+}
+
+// Probability returns the probability that the result will be exactly n.
+func (dist Distribution) Probability(n int) float64 {
+	if n < dist.Min || n > dist.Max {
+		return 0
+	}
+	return dist.P[n-dist.Min]
+}
+
+// AtLeast returns the probability that the result will be n or greater.
+// This is the chance of success for a roll against a DC of n.
+func (dist Distribution) AtLeast(n int) float64 {
+	var total float64
+	if n < dist.Min {
+		n = dist.Min
+	}
+	for v := n; v <= dist.Max; v++ {
+		total += dist.P[v-dist.Min]
+	}
+	return math.Min(total, 1)
+}
+
+// AtMost returns the probability that the result will be n or less.
+func (dist Distribution) AtMost(n int) float64 {
+	var total float64
+	if n > dist.Max {
+		n = dist.Max
+	}
+	for v := dist.Min; v <= n; v++ {
+		total += dist.P[v-dist.Min]
+	}
+	return math.Min(total, 1)
+}
+
+// StdDev returns the standard deviation of the results.
+func (dist Distribution) StdDev() float64 {
+	return math.Sqrt(dist.Variance)
+}
+
+// Distribution calculates the exact probability distribution of the results
+// of rolling the Dice. This takes into account everything that the Roll
+// method would, including "best of" and "worst of" rerolls, fractional dice,
+// maximized initial dice, the ≤ and ≥ operators, and min/max limits.
+func (d *Dice) Distribution() (Distribution, error) {
+	stack := &distStack{}
+
+	for _, die := range d.multiDice {
+		if err := die.computeDistribution(stack); err != nil {
+			return Distribution{}, err
+		}
+	}
+	values, err := stack.evaluate()
+	if err != nil {
+		return Distribution{}, err
+	}
+
+	results := make(map[int]float64)
+	for x, p := range values {
+		v := int(x)
+		if d.MaxValue > 0 && v > d.MaxValue {
+			v = d.MaxValue
+		}
+		if d.MinValue > 0 && v < d.MinValue {
+			v = d.MinValue
+		}
+		results[v] += p
+	}
+	return newDistribution(results), nil
+}
+
+// RollDistribution describes the probability distribution of the results
+// of one of the die rolls described by a DieRoller specification.
+type RollDistribution struct {
+	Distribution
+
+	// The die-roll expression this distribution describes.
+	Expression string
+
+	// If the specification included a "| dc" option, this is the
+	// DC and the probability that the roll will meet or exceed it.
+	DC      int     `json:",omitempty"`
+	Success float64 `json:",omitempty"`
+}
+
+// Distribution calculates the exact probability distribution of the
+// results of a die-roll specification as accepted by DoRoll, without
+// actually rolling any dice. As with DoRoll, an empty spec string
+// uses the previous specification again.
+//
+// It returns the title from the specification, and a RollDistribution
+// for each roll that the specification calls for. There will usually be
+// only one, but permutations such as "d20+{15/10/5}" produce a separate
+// distribution for each combination of values.
+//
+// Options which only affect how many times the dice are rolled (repeat,
+// until, total, and critical confirmation rolls) do not change the odds
+// of each individual roll, so they are ignored here. For percentile rolls
+// such as "40%", the result is 1 for success and 0 for failure.
+func (d *DieRoller) Distribution(spec string) (string, []RollDistribution, error) {
+	var results []RollDistribution
+
+	if spec != "" {
+		if err := d.setNewSpecification(spec); err != nil {
+			return "", nil, err
+		}
+	}
+	if d.table != "" {
+		return "", nil, fmt.Errorf("can't calculate the distribution of a roll on a random table")
+	}
+
+	if d.Template != "" {
+		defer func() { d.d = nil }()
+		for _, iteration := range permutationsOf(d.Permutations) {
+			expr := substituteTemplateValues(d.Template, iteration)
+			dice, err := New(ByDescription(expr), withSharedGenerator(d.generator))
+			if err != nil {
+				return "", nil, err
+			}
+			d.d = dice
+			dist, err := d.rollDistribution()
+			if err != nil {
+				return "", nil, err
+			}
+			dist.Expression = expr
+			results = append(results, dist)
+		}
+		return d.LabelText, results, nil
+	}
+
+	dist, err := d.rollDistribution()
+	if err != nil {
+		return "", nil, err
+	}
+	if d.PctChance >= 0 {
+		dist.Expression = fmt.Sprintf("%d%%", d.PctChance)
+	} else {
+		dist.Expression = d.d.Description()
+	}
+	return d.LabelText, append(results, dist), nil
+}
+
+// rollDistribution calculates the distribution for the Dice the DieRoller
+// is currently set up to roll, taking into account its global options.
+func (d *DieRoller) rollDistribution() (RollDistribution, error) {
+	var rd RollDistribution
+	var err error
+
+	if d.d == nil {
+		return rd, fmt.Errorf("no defined Dice object to consume")
+	}
+
+	if d.DoMax {
+		result, err := d.d.MaxRoll()
+		if err != nil {
+			return rd, err
+		}
+		if d.PctChance >= 0 {
+			if result <= d.PctChance {
+				result = 1
+			} else {
+				result = 0
+			}
+		}
+		rd.Distribution = newDistribution(map[int]float64{result: 1})
+	} else if d.PctChance >= 0 {
+		chance := float64(max(0, min(100, d.PctChance))) / 100
+		rd.Distribution = newDistribution(map[int]float64{0: 1 - chance, 1: chance})
+	} else if rd.Distribution, err = d.d.Distribution(); err != nil {
+		return rd, err
+	}
+
+	if d.DC != 0 {
+		rd.DC = d.DC
+		rd.Success = rd.AtLeast(d.DC)
+	}
+	return rd, nil
+}
+
+// valueDist is the distribution of an intermediate value while we evaluate
+// a die-roll expression. It maps each possible value to its probability.
+type valueDist map[float64]float64
+
+// A distStack is used to evaluate a die-roll expression in the same way
+// as an evalStack, but operating on the distributions of values rather
+// than on actual die-roll results. Since each die in the expression is
+// independent of the others, we can combine their distributions one
+// operator at a time.
+type distStack struct {
+	stack   []valueDist
+	opStack []rune
+}
+
+func (s *distStack) isOpEmpty() bool {
+	return len(s.opStack) == 0
+}
+
+func (s *distStack) push(v valueDist) {
+	s.stack = append(s.stack, v)
+}
+
+func (s *distStack) pop() (valueDist, error) {
+	stackLen := len(s.stack)
+	if stackLen == 0 {
+		return nil, fmt.Errorf("stack underflow")
+	}
+	poppedValue := s.stack[stackLen-1]
+	s.stack = s.stack[:stackLen-1]
+	return poppedValue, nil
+}
+
+func (s *distStack) pushOp(v rune) {
+	s.opStack = append(s.opStack, v)
+}
+
+func (s *distStack) popOp() (rune, error) {
+	stackLen := len(s.opStack)
+	if stackLen == 0 {
+		return 0, fmt.Errorf("operator stack underflow")
+	}
+	poppedValue := s.opStack[stackLen-1]
+	s.opStack = s.opStack[:stackLen-1]
+	return poppedValue, nil
+}
+
+func (s *distStack) discardOp() {
+	if !s.isOpEmpty() {
+		_, _ = s.popOp()
+	}
+}
+
+func (s *distStack) nextOp() rune {
+	stackLen := len(s.opStack)
+	if stackLen == 0 {
+		return 0
+	}
+	return s.opStack[stackLen-1]
+}
+
+func (s *distStack) applyOp() error {
+	op, err := s.popOp()
+	if err != nil {
+		return err
+	}
+	if op == '(' || op == ')' {
+		return nil
+	}
+
+	if op == '‾' { // unary - (negation)
+		x, err := s.pop()
+		if err != nil {
+			return err
+		}
+		result := make(valueDist)
+		for v, p := range x {
+			result[-v] += p
+		}
+		s.push(result)
+		return nil
+	}
+
+	y, err := s.pop()
+	if err != nil {
+		return err
+	}
+	x, err := s.pop()
+	if err != nil {
+		return err
+	}
+
+	result := make(valueDist)
+	for xv, xp := range x {
+		for yv, yp := range y {
+			v, err := applyBinaryOp(op, xv, yv)
+			if err != nil {
+				return err
+			}
+			result[v] += xp * yp
+		}
+	}
+	s.push(result)
+	return nil
+}
+
+// complete the evaluation of the expression by applying all remaining operators
+func (s *distStack) evaluate() (valueDist, error) {
+	for !s.isOpEmpty() {
+		if s.nextOp() == '(' {
+			return nil, fmt.Errorf("'(' without matching ')' in die-roll expression")
+		}
+		if err := s.applyOp(); err != nil {
+			return nil, err
+		}
+	}
+	value, err := s.pop()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.stack) > 0 {
+		return nil, fmt.Errorf("expression stack not empty at end of evaluation")
+	}
+	return value, nil
+}
+
+func (l dieLabel) computeDistribution(s *distStack) error {
+	return nil
+}
+
+func (o dieOperator) computeDistribution(s *distStack) error {
+	for !s.isOpEmpty() && s.nextOp() != '(' && precedence(dieOperator(s.nextOp())) >= precedence(o) {
+		if err := s.applyOp(); err != nil {
+			return err
+		}
+	}
+	s.pushOp(rune(o))
+	return nil
+}
+
+func (b dieBeginGroup) computeDistribution(s *distStack) error {
+	s.pushOp('(')
+	return nil
+}
+
+func (b dieEndGroup) computeDistribution(s *distStack) error {
+	for s.nextOp() != '(' {
+		if err := s.applyOp(); err != nil {
+			return err
+		}
+	}
+	if s.nextOp() != '(' {
+		return fmt.Errorf("')' with no matching '('")
+	}
+	s.discardOp()
+	return nil
+}
+
+func (d *dieConstant) computeDistribution(s *distStack) error {
+	s.push(valueDist{d.Value: 1})
+	return nil
+}
+
+// computeDistribution works out the distribution of the sum of the dice
+// in the same way compute rolls them, and then, for "best of" and "worst of"
+// rolls, the distribution of the best or worst of several such sums.
+func (d *dieSpec) computeDistribution(s *distStack) error {
+	if d.Sides <= 0 {
+		return fmt.Errorf("dice cannot have a nonpositive number of sides")
+	}
+
+	var sum map[int]float64
+	if d.hasPerDieOptions() {
+		sum = d.perDieDistribution()
+	} else {
+		oneDie := make(map[int]float64)
+		for face := 1; face <= d.Sides; face++ {
+			oneDie[d.dieValue(face)] += 1 / float64(d.Sides)
+		}
+
+		sum = map[int]float64{0: 1}
+		for j := 0; j < d.Numerator; j++ {
+			thisDie := oneDie
+			if d.InitialMax && j == 0 {
+				thisDie = map[int]float64{d.dieValue(d.Sides): 1}
+			}
+			sum = convolve(sum, thisDie)
+		}
+	}
+
+	if d.Rerolls > 0 {
+		sum = bestOrWorstOf(sum, d.Rerolls+1, d.BestReroll)
+	}
+
+	result := make(valueDist)
+	for v, p := range sum {
+		result[float64(v)] = p
+	}
+	s.push(result)
+	return nil
+}
+
+// convolve returns the distribution of the sum of two independent values.
+func convolve(a, b map[int]float64) map[int]float64 {
+	result := make(map[int]float64)
+	for av, ap := range a {
+		for bv, bp := range b {
+			result[av+bv] += ap * bp
+		}
+	}
+	return result
+}
+
+// negligibleProbability is the point at which we stop following ever-less-likely
+// chains of exploding dice.
+const negligibleProbability = 1e-15
+
+// naturalDistribution returns the distribution of the natural roll of a single die
+// with the given number of sides, after applying the reroll and explode options.
+func (d *dieSpec) naturalDistribution(n int) map[int]float64 {
+	faces := make(map[int]float64)
+	sides := float64(n)
+	rerollBelow := min(d.RerollBelow, n)
+	for face := 1; face <= n; face++ {
+		switch {
+		case rerollBelow <= 0:
+			faces[face] = 1 / sides
+		case d.RerollOnce && face <= rerollBelow:
+			faces[face] = float64(rerollBelow) / sides / sides
+		case d.RerollOnce:
+			faces[face] = 1/sides + float64(rerollBelow)/sides/sides
+		case face > rerollBelow:
+			faces[face] = 1 / float64(n-rerollBelow)
+		}
+	}
+	if !d.Explode {
+		return faces
+	}
+
+	result := make(map[int]float64)
+	reached, base := 1.0, 0
+	for explosions := 0; ; explosions++ {
+		again := explosions < maxExplosions && reached*faces[n] > negligibleProbability
+		for face, p := range faces {
+			if face == n && again {
+				continue
+			}
+			result[base+face] += reached * p
+		}
+		if !again {
+			return result
+		}
+		reached *= faces[n]
+		base += n
+	}
+}
+
+// perDieDistribution returns the distribution of the value of a set of
+// dice with per-die options (before any "best of" or "worst of" rerolls).
+func (d *dieSpec) perDieDistribution() map[int]float64 {
+	// Since a die's value never decreases as its natural roll increases,
+	// we work with the natural rolls, which also tell us which dice botched.
+	oneDie := d.naturalDistribution(d.Sides)
+
+	contribution := func(natural int) int {
+		v := d.dieValue(natural)
+		if d.SuccessTarget > 0 {
+			if v >= d.SuccessTarget {
+				return 1
+			}
+			if natural <= d.Botch {
+				return -1
+			}
+			return 0
+		}
+		return v
+	}
+
+	if d.WildSides > 0 {
+		return d.wildDistribution(oneDie, contribution)
+	}
+
+	keep := d.KeepHighest + d.KeepLowest
+	if keep == 0 {
+		// All dice count, so we just add up their contributions.
+		oneContribution := make(map[int]float64)
+		for v, p := range oneDie {
+			oneContribution[contribution(v)] += p
+		}
+		if d.Again > 0 {
+			oneContribution = d.againDistribution(oneDie, contribution)
+		}
+		sum := map[int]float64{0: 1}
+		for j := 0; j < d.Numerator; j++ {
+			if d.InitialMax && j == 0 {
+				first := map[int]float64{contribution(d.Sides): 1}
+				if d.Again > 0 {
+					// the maximized die always rolls again
+					first = convolve(first, oneContribution)
+				}
+				sum = convolve(sum, first)
+			} else {
+				sum = convolve(sum, oneContribution)
+			}
+		}
+		return sum
+	}
+
+	// To keep only some of the dice, consider each possible die value from the
+	// best to the worst, and how many of the dice came up with that value. The
+	// first ones we see are the ones we keep. Each state tracks how many of the
+	// dice we've accounted for so far and the total of those we kept.
+	values := make([]int, 0, len(oneDie))
+	for v := range oneDie {
+		values = append(values, v)
+	}
+	if d.KeepHighest > 0 {
+		sort.Sort(sort.Reverse(sort.IntSlice(values)))
+	} else {
+		sort.Ints(values)
+	}
+
+	type keepState struct {
+		seen, total int
+	}
+	states := map[keepState]float64{{0, 0}: 1}
+	for _, v := range values {
+		p := oneDie[v]
+		next := make(map[keepState]float64)
+		for st, w := range states {
+			remaining := d.Numerator - st.seen
+			for j := 0; j <= remaining; j++ {
+				kept := min(j, max(0, keep-st.seen))
+				next[keepState{st.seen + j, st.total + kept*contribution(v)}] += w * binomial(remaining, j) * math.Pow(p, float64(j))
+			}
+		}
+		states = next
+	}
+
+	result := make(map[int]float64)
+	for st, w := range states {
+		if st.seen == d.Numerator && w > 0 {
+			result[st.total] += w
+		}
+	}
+	return result
+}
+
+// againDistribution returns the distribution of the total contribution of a
+// die along with all of the extra dice added because it (or they) rolled at
+// least d.Again, given the distribution of natural rolls of each die.
+func (d *dieSpec) againDistribution(oneDie map[int]float64, contribution func(int) int) map[int]float64 {
+	result := make(map[int]float64)
+	pending := map[int]float64{0: 1}
+	for extra := 0; len(pending) > 0; extra++ {
+		next := make(map[int]float64)
+		for sum, w := range pending {
+			for natural, p := range oneDie {
+				if natural >= d.Again && extra < maxExplosions && w*p > negligibleProbability {
+					next[sum+contribution(natural)] += w * p
+				} else {
+					result[sum+contribution(natural)] += w * p
+				}
+			}
+		}
+		pending = next
+	}
+	return result
+}
+
+// wildDistribution returns the distribution of the total contribution of
+// a set of dice rolled with a wild die, keeping all but the lowest of them,
+// given the distribution of natural rolls of each of the (non-wild) dice.
+func (d *dieSpec) wildDistribution(oneDie map[int]float64, contribution func(int) int) map[int]float64 {
+	// Each state tracks the total contribution of the dice so far and the
+	// lowest natural roll among them.
+	type wildState struct {
+		total, lowest int
+	}
+	states := map[wildState]float64{{0, math.MaxInt}: 1}
+	for j := 0; j < d.Numerator; j++ {
+		thisDie := oneDie
+		if d.InitialMax && j == 0 {
+			thisDie = map[int]float64{d.Sides: 1}
+		}
+		next := make(map[wildState]float64)
+		for st, w := range states {
+			for natural, p := range thisDie {
+				next[wildState{st.total + contribution(natural), min(st.lowest, natural)}] += w * p
+			}
+		}
+		states = next
+	}
+
+	// The wild die is dropped if it's no better than the lowest of the others
+	// (as when rolling them); otherwise it replaces that one.
+	result := make(map[int]float64)
+	for natural, p := range d.naturalDistribution(d.WildSides) {
+		for st, w := range states {
+			if d.dieValue(natural) <= d.dieValue(st.lowest) {
+				result[st.total] += w * p
+			} else {
+				result[st.total-contribution(st.lowest)+contribution(natural)] += w * p
+			}
+		}
+	}
+	return result
+}
+
+// binomial returns the number of ways to choose k things from n.
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+// bestOrWorstOf takes the distribution of a single roll and returns the
+// distribution of the highest (if best is true) or lowest of n such rolls.
+func bestOrWorstOf(single map[int]float64, n int, best bool) map[int]float64 {
+	values := make([]int, 0, len(single))
+	for v := range single {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+
+	result := make(map[int]float64)
+	if best {
+		// P(best ≤ v) = P(roll ≤ v)ⁿ
+		var cdf, prev float64
+		for _, v := range values {
+			cdf += single[v]
+			this := math.Pow(math.Min(cdf, 1), float64(n))
+			result[v] = this - prev
+			prev = this
+		}
+	} else {
+		// P(worst ≥ v) = P(roll ≥ v)ⁿ
+		var sf, prev float64
+		for i := len(values) - 1; i >= 0; i-- {
+			sf += single[values[i]]
+			this := math.Pow(math.Min(sf, 1), float64(n))
+			result[values[i]] = this - prev
+			prev = this
+		}
+	}
+	return result
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,208 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Unit tests for the dice probability distribution code
+//
+
+package dice
+
+import (
+	"math"
+	"testing"
+)
+
+func closeEnough(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestDiceDistribution(t *testing.T) {
+	type probe struct {
+		n int
+		p float64
+	}
+	for i, test := range []struct {
+		spec     string
+		min, max int
+		mean     float64
+		variance float64
+		probes   []probe
+	}{
+		{spec: "d6", min: 1, max: 6, mean: 3.5, variance: 35.0 / 12.0, probes: []probe{{1, 1.0 / 6}, {6, 1.0 / 6}, {7, 0}}},
+		{spec: "2d6", min: 2, max: 12, mean: 7, variance: 35.0 / 6.0, probes: []probe{{2, 1.0 / 36}, {7, 6.0 / 36}, {12, 1.0 / 36}}},
+		{spec: "d20 best of 2", min: 1, max: 20, mean: 13.825, variance: -1, probes: []probe{{20, 1 - 0.95*0.95}, {1, 1.0 / 400}}},
+		{spec: "d20 worst of 2", min: 1, max: 20, mean: 7.175, variance: -1, probes: []probe{{1, 1 - 0.95*0.95}, {20, 1.0 / 400}}},
+		{spec: "1/2d20", min: 1, max: 10, mean: -1, variance: -1, probes: []probe{{1, 3.0 / 20}, {5, 2.0 / 20}, {10, 1.0 / 20}}},
+		{spec: ">3d6", min: 8, max: 18, mean: 13, variance: 35.0 / 6.0, probes: []probe{{8, 1.0 / 36}, {18, 1.0 / 36}}},
+		{spec: "(d20+5) <= 20", min: 6, max: 20, mean: -1, variance: -1, probes: []probe{{20, 6.0 / 20}, {19, 1.0 / 20}}},
+		{spec: "d20 >= 10", min: 10, max: 20, mean: -1, variance: -1, probes: []probe{{10, 10.0 / 20}, {11, 1.0 / 20}}},
+		{spec: "d6 | max 4", min: 1, max: 4, mean: -1, variance: -1, probes: []probe{{4, 3.0 / 6}}},
+		{spec: "d6 | min 3", min: 3, max: 6, mean: -1, variance: -1, probes: []probe{{3, 3.0 / 6}}},
+		{spec: "d4 // 2", min: 0, max: 2, mean: 1, variance: -1, probes: []probe{{0, 0.25}, {1, 0.5}, {2, 0.25}}},
+		{spec: "-d4", min: -4, max: -1, mean: -2.5, variance: -1, probes: []probe{{-4, 0.25}}},
+		{spec: "(d4+1)*2", min: 4, max: 10, mean: 7, variance: -1, probes: []probe{{5, 0}, {6, 0.25}}},
+		{spec: "12", min: 12, max: 12, mean: 12, variance: 0, probes: []probe{{12, 1}}},
+		{spec: "4d6kh3", min: 3, max: 18, mean: 15869.0 / 1296.0, variance: -1, probes: []probe{{3, 1.0 / 1296}, {18, 21.0 / 1296}}},
+		{spec: "2d20kh1", min: 1, max: 20, mean: 13.825, variance: -1, probes: []probe{{20, 1 - 0.95*0.95}}},
+		{spec: "2d20kl1", min: 1, max: 20, mean: 7.175, variance: -1, probes: []probe{{1, 1 - 0.95*0.95}}},
+		{spec: "d20 r1", min: 2, max: 20, mean: 11, variance: -1, probes: []probe{{1, 0}, {2, 1.0 / 19}}},
+		{spec: "d20 ro1", min: 1, max: 20, mean: 10.975, variance: -1, probes: []probe{{1, 1.0 / 400}, {2, 21.0 / 400}}},
+		{spec: "5d10 s8", min: 0, max: 5, mean: 1.5, variance: 1.05, probes: []probe{{5, 0.3 * 0.3 * 0.3 * 0.3 * 0.3}}},
+		{spec: "4d6kh2 s5", min: 0, max: 2, mean: -1, variance: -1, probes: []probe{{0, 256.0 / 1296}}},
+		{spec: ">3d6 s6", min: 1, max: 3, mean: 4.0 / 3.0, variance: -1, probes: []probe{{1, 25.0 / 36}}},
+		{spec: "3d10 s6 b1", min: -3, max: 3, mean: 1.2, variance: -1, probes: []probe{{-3, 0.001}, {3, 0.125}}},
+		{spec: "d8 w6", min: 1, max: 8, mean: 251.0 / 48.0, variance: -1, probes: []probe{{1, 1.0 / 48}, {8, 1.0 / 8}}},
+	} {
+		d, err := New(ByDescription(test.spec))
+		if err != nil {
+			t.Fatalf("test %d (%s): %v", i, test.spec, err)
+		}
+		dist, err := d.Distribution()
+		if err != nil {
+			t.Fatalf("test %d (%s): %v", i, test.spec, err)
+		}
+		if dist.Min != test.min || dist.Max != test.max {
+			t.Errorf("test %d (%s): range %d-%d, expected %d-%d", i, test.spec, dist.Min, dist.Max, test.min, test.max)
+		}
+		if test.mean >= 0 && !closeEnough(dist.Mean, test.mean) {
+			t.Errorf("test %d (%s): mean %v, expected %v", i, test.spec, dist.Mean, test.mean)
+		}
+		if test.variance >= 0 && !closeEnough(dist.Variance, test.variance) {
+			t.Errorf("test %d (%s): variance %v, expected %v", i, test.spec, dist.Variance, test.variance)
+		}
+		if !closeEnough(dist.AtLeast(dist.Min), 1) || !closeEnough(dist.AtMost(dist.Max), 1) {
+			t.Errorf("test %d (%s): probabilities total %v, expected 1", i, test.spec, dist.AtLeast(dist.Min))
+		}
+		for _, pr := range test.probes {
+			if p := dist.Probability(pr.n); !closeEnough(p, pr.p) {
+				t.Errorf("test %d (%s): P(%d)=%v, expected %v", i, test.spec, pr.n, p, pr.p)
+			}
+		}
+	}
+}
+
+func TestDieRollerDistribution(t *testing.T) {
+	dr, err := NewDieRoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title, dists, err := dr.Distribution("attack=d20+5 | dc 15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "attack" || len(dists) != 1 {
+		t.Fatalf("got title %q and %d distributions", title, len(dists))
+	}
+	if dists[0].DC != 15 || !closeEnough(dists[0].Success, 0.55) {
+		t.Errorf("DC %d success %v, expected DC 15 success 0.55", dists[0].DC, dists[0].Success)
+	}
+	if !closeEnough(dists[0].AtLeast(25), 0.05) || dists[0].AtLeast(26) != 0 || dists[0].AtMost(5) != 0 {
+		t.Errorf("AtLeast/AtMost gave unexpected results for %v", dists[0])
+	}
+
+	_, dists, err = dr.Distribution("d6!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !closeEnough(dists[0].Mean, 4.2) || !closeEnough(dists[0].Probability(6), 0) || !closeEnough(dists[0].Probability(8), 1.0/36) {
+		t.Errorf("exploding die distribution incorrect: mean %v, P(6)=%v, P(8)=%v",
+			dists[0].Mean, dists[0].Probability(6), dists[0].Probability(8))
+	}
+
+	_, dists, err = dr.Distribution("d10 a10 s8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !closeEnough(dists[0].Mean, 1.0/3.0) || !closeEnough(dists[0].Probability(0), 0.7) || !closeEnough(dists[0].Probability(1), 0.27) {
+		t.Errorf("rolling again distribution incorrect: mean %v, P(0)=%v, P(1)=%v",
+			dists[0].Mean, dists[0].Probability(0), dists[0].Probability(1))
+	}
+
+	_, dists, err = dr.Distribution("40% hit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dists) != 1 || !closeEnough(dists[0].Probability(1), 0.4) || !closeEnough(dists[0].Probability(0), 0.6) {
+		t.Errorf("percentile distribution %v incorrect", dists)
+	}
+
+	_, dists, err = dr.Distribution("3d6 | maximized")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dists) != 1 || dists[0].Min != 18 || dists[0].Max != 18 {
+		t.Errorf("maximized distribution %v incorrect", dists)
+	}
+
+	_, dists, err = dr.Distribution("d20+{10/5} | dc 20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dists) != 2 {
+		t.Fatalf("expected 2 distributions for permutations, got %d", len(dists))
+	}
+	if dists[0].Expression != "d20+10" || !closeEnough(dists[0].Success, 0.55) ||
+		dists[1].Expression != "d20+5" || !closeEnough(dists[1].Success, 0.3) {
+		t.Errorf("permutation distributions %v incorrect", dists)
+	}
+
+	if _, _, err = dr.Distribution("d4 // (d4 - 1)"); err == nil {
+		t.Errorf("possible division by zero was not reported")
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
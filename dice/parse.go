@@ -0,0 +1,1448 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+///////////////////////////////////////////////////////////////////////////////
+//                                                                           //
+//                          Die-Roll Syntax Trees                            //
+//                                                                           //
+// A parser which turns a die-roll specification into a syntax tree which   //
+// tools can inspect without rolling any dice, and a printer which turns    //
+// the tree back into a specification.                                       //
+//                                                                           //
+///////////////////////////////////////////////////////////////////////////////
+
+package dice
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position gives the location of a node within the die-roll specification
+// it was parsed from, as the byte offsets of its first character and of the
+// character just past its end.
+type Position struct {
+	Start int
+	End   int
+}
+
+// Pos returns the location of the node.
+func (p Position) Pos() Position {
+	return p
+}
+
+// Node is implemented by every element of the syntax tree returned by Parse.
+// The String method of each node gives it in the die-roll specification
+// syntax, in a canonical form which Parse will turn back into the same tree.
+type Node interface {
+	Pos() Position
+	String() string
+}
+
+// Expr is implemented by the nodes which make up a die-roll expression:
+// *BinaryExpr, *UnaryExpr, *GroupExpr, *DiceTerm, *ConstantTerm,
+// *VariableRef, and *Permutation.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// RollSpec is the root of the syntax tree for a complete die-roll
+// specification, as accepted by DieRoller.DoRoll:
+//
+//	[<title>=] <expression> [|<modifier>...]
+//	[<title>=] <chance>% [<label>] [|<modifier>...]
+//	[<title>=] @<table> [|repeat <n>]
+//
+// Exactly one of Roll, Chance, and Table is non-nil.
+type RollSpec struct {
+	Position
+	Title     *Title
+	Roll      Expr
+	Chance    *ChanceRoll
+	Table     *TableRoll
+	Modifiers []*Modifier
+}
+
+// Title is the title given at the start of a die-roll specification.
+type Title struct {
+	Position
+	Text string
+}
+
+// ChanceRoll is a percentile roll for a chance of something happening,
+// such as "40% hit".
+type ChanceRoll struct {
+	Position
+
+	// The percentage chance of success.
+	Percent int
+
+	// The text describing success (and optionally failure, after a slash).
+	Label string
+}
+
+// TableRoll is a roll on a random table, such as "@Wandering Monsters".
+// Since any text may be part of a table's name, Parse can't tell whether
+// the table exists or where its name ends; see RandomTable.
+type TableRoll struct {
+	Position
+
+	// The name of the table.
+	Name string
+}
+
+// BinaryExpr is an expression of the form X Op Y.
+// Op is one of '+', '-', '×', '÷', '≤', or '≥'.
+type BinaryExpr struct {
+	Position
+	Op rune
+	X  Expr
+	Y  Expr
+}
+
+// UnaryExpr is the negation of X.
+type UnaryExpr struct {
+	Position
+	X Expr
+}
+
+// GroupExpr is an expression enclosed in parentheses, optionally followed
+// by a label.
+type GroupExpr struct {
+	Position
+	X     Expr
+	Label string
+}
+
+// DiceTerm is a roll of one or more dice, such as "3d6", "1/2 d8 fire",
+// or "4d6 kh3".
+type DiceTerm struct {
+	Position
+
+	// True if the first die is assumed to roll its maximum value (">d6").
+	InitialMax bool
+
+	// The number of dice rolled, and the divisor applied to their total
+	// (zero if there is none).
+	Count       int
+	Denominator int
+
+	// The number of sides on each die. Percentile is true if the
+	// sides were given as "%".
+	Sides      int
+	Percentile bool
+
+	// Per-die options: exploding dice, rerolling dice below RerollBelow
+	// (only once if RerollOnce is true), adding a die for each which
+	// rolls at least Again, keeping the highest or lowest dice, rolling
+	// a wild die with WildSides sides, and counting the dice which meet
+	// SuccessTarget less those which roll Botch or less.
+	Explode       bool
+	RerollBelow   int
+	RerollOnce    bool
+	Again         int
+	KeepHighest   int
+	KeepLowest    int
+	WildSides     int
+	SuccessTarget int
+	Botch         int
+
+	// If nonzero, the dice are rolled this many times, keeping the
+	// best or worst result.
+	BestOf  int
+	WorstOf int
+
+	Label string
+}
+
+// ConstantTerm is a constant value, optionally followed by a label.
+type ConstantTerm struct {
+	Position
+	Value float64
+	Label string
+}
+
+// VariableRef is a reference to a die-roll variable such as "$str" or
+// "${bab}" used as a value, optionally followed by a label.
+type VariableRef struct {
+	Position
+	Name  string
+	Label string
+}
+
+// Permutation is a set of alternative values such as "{17/12/7}", each
+// of which is substituted in turn to make a separate roll.
+//
+// If the braces are joined to other text, as in "{1/2}d6" or "{17/12} bonus",
+// each of the Choices is the complete value formed by substituting that
+// choice into the text (here, "1d6" and "2d6", or "17 bonus" and "12 bonus").
+type Permutation struct {
+	Position
+	Choices []Expr
+}
+
+func (*BinaryExpr) exprNode()   {}
+func (*UnaryExpr) exprNode()    {}
+func (*GroupExpr) exprNode()    {}
+func (*DiceTerm) exprNode()     {}
+func (*ConstantTerm) exprNode() {}
+func (*VariableRef) exprNode()  {}
+func (*Permutation) exprNode()  {}
+
+// ModifierKind identifies a global modifier.
+type ModifierKind byte
+
+const (
+	MinModifier       ModifierKind = iota // | min <Value>
+	MaxModifier                           // | max <Value>
+	ConfirmModifier                       // | c[<Value>][±<Bonus>]
+	DCModifier                            // | dc <Value>
+	SFModifier                            // | sf [<Success>[/<Fail>]]
+	UntilModifier                         // | until <Value>
+	TotalModifier                         // | total <Value>
+	RepeatModifier                        // | repeat <Value>
+	MaximizedModifier                     // | maximized
+	DegreesModifier                       // | degrees [<Value>]
+	BandModifier                          // | band <Band>
+	VersusModifier                        // | vs <Opposed>
+)
+
+// Modifier is one of the global modifiers at the end of a die-roll
+// specification.
+type Modifier struct {
+	Position
+	Kind ModifierKind
+
+	// The numeric parameter of the modifier. For ConfirmModifier
+	// this is the critical threat (zero for the die's default), and
+	// for DegreesModifier the steps between degrees (zero for the
+	// default of 10).
+	Value int
+
+	// The confirmation bonus, for ConfirmModifier.
+	Bonus int
+
+	// The success and failure messages, for SFModifier.
+	Success string
+	Fail    string
+
+	// The range of results and their label, for BandModifier.
+	Band OutcomeBand
+
+	// The opposing roll, for VersusModifier.
+	Opposed Expr
+}
+
+// SyntaxError describes a problem found by Parse, with the location
+// in the specification where it was found.
+type SyntaxError struct {
+	// The die-roll specification being parsed.
+	Spec string
+
+	// The byte offset into Spec where the problem was found.
+	Offset int
+
+	// A description of the problem.
+	Message string
+}
+
+// Column returns the position of the error in the specification, counting
+// characters from 1.
+func (e *SyntaxError) Column() int {
+	if e.Offset > len(e.Spec) {
+		return utf8.RuneCountInString(e.Spec) + 1
+	}
+	return utf8.RuneCountInString(e.Spec[:e.Offset]) + 1
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("die-roll syntax error at column %d: %s", e.Column(), e.Message)
+}
+
+// Marker returns the specification with a second line under it which
+// points to where the error was found, such as
+//
+//	d20+*5
+//	    ^
+func (e *SyntaxError) Marker() string {
+	return e.Spec + "\n" + strings.Repeat(" ", e.Column()-1) + "^"
+}
+
+var (
+	reParseMin         = regexp.MustCompile(`^\s*min\s*([+-]?\d+)\s*$`)
+	reParseMax         = regexp.MustCompile(`^\s*max\s*([+-]?\d+)\s*$`)
+	reParseMinmax      = regexp.MustCompile(`^\s*(min|max)\s*[+-]?\d+`)
+	reParseMinmaxLabel = regexp.MustCompile(`\b(min|max)\s*[+-]?\d+`)
+	reParseConfirm     = regexp.MustCompile(`^\s*c(\d+)?([-+]\d+)?\s*$`)
+	reParseConfirmUsed = regexp.MustCompile(`\bc(\d+)?([-+]\d+)?\b`)
+	reParseUntil       = regexp.MustCompile(`^\s*until\s*(-?\d+)\s*$`)
+	reParseTotal       = regexp.MustCompile(`^\s*total\s*(-?\d+)\s*$`)
+	reParseRepeat      = regexp.MustCompile(`^\s*repeat\s*(\d+)\s*$`)
+	reParseMaximized   = regexp.MustCompile(`^\s*(!|maximized)\s*$`)
+	reParseDC          = regexp.MustCompile(`^\s*[Dd][Cc]\s*(-?\d+)\s*$`)
+	reParseSF          = regexp.MustCompile(`^\s*sf(?:\s+(\S.*?)(?:/(\S.*?))?)?\s*$`)
+	reParseDegrees     = regexp.MustCompile(`^\s*degrees(?:\s*(\d+))?\s*$`)
+	reParseBand        = regexp.MustCompile(`^\s*band\s*(-?\d+)(?:(\+)|(-)(-?\d+)?)?\s+(\S.*?)\s*$`)
+	reParseVersus      = regexp.MustCompile(`^\s*vs\s+(\S.*?)\s*$`)
+	reParseChance      = regexp.MustCompile(`^\s*(\d+)%(.*)$`)
+	reParseIsDie       = regexp.MustCompile(`\d+\s*[dD]\d*\d+`)
+	reParseDieOption   = regexp.MustCompile(`(?:^|\s)(!|(?:ro?|a|k[hl]?|w|s|b)\d+)(?:\s|$)`)
+	reParseBareLabel   = regexp.MustCompile(`^\s*([\p{L}_][\p{L}\p{N}_,.]*\s*)+(≡(#[0-9a-fA-F]+|\w+)){0,2}(\s*‖\s*([\p{L}_][\p{L}\p{N}_,.]*\s*)+(≡(#[0-9a-fA-F]+|\w+)){0,2})*\s*$`)
+	reParseConstant    = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?|\.\d+)\s*(.*?)\s*$`)
+	reParseVariable    = regexp.MustCompile(`^\s*\$(?:\{([A-Za-z_]\w*)\}|([A-Za-z_]\w*))\s*(.*?)\s*$`)
+	reParseDieSpec     = regexp.MustCompile(`^\s*(>)?\s*(\d*)\s*(?:/\s*(\d+))?\s*[Dd]\s*(%|\d+)\s*(!)?\s*(?:(ro?)\s*(\d+))?\s*(?:a\s*(\d+))?\s*(?:k([hl]?)\s*(\d+))?\s*(?:w\s*(\d+))?\s*(?:s\s*(\d+)(?:\s*b\s*(\d+))?)?\s*(?:(best|worst)\s*of\s*(\d+))?\s*(.*?)\s*$`)
+)
+
+// Parse parses a die-roll specification in the form accepted by
+// DieRoller.DoRoll (q.v.) and returns its syntax tree. If the specification
+// is not valid, the error returned is a *SyntaxError which gives the location
+// of the problem.
+//
+// Die-roll variables are only recognized where a value is expected, as in
+// "d20+$str". Since variables may hold any text at all, a specification which
+// uses them elsewhere should be given to ExpandVariables before Parse.
+// Likewise, each alternative in a permutation group must be a complete
+// expression, as in "d20+{17/12/7}".
+//
+// The String method of the returned value gives the specification back in
+// a canonical form.
+func Parse(spec string) (*RollSpec, error) {
+	p := &parser{spec: spec}
+	return p.parseRollSpec()
+}
+
+// parser holds the state of a call to Parse.
+type parser struct {
+	spec   string
+	tokens []token
+	next   int
+}
+
+// tokenKind identifies the kinds of token in an expression.
+type tokenKind byte
+
+const (
+	tokenOperator tokenKind = iota
+	tokenValue
+	tokenPermutation
+)
+
+// A token is an operator, the text of a value between operators, or
+// a permutation group, found at the given offsets into the spec.
+// For a permutation group, the braces themselves are found between
+// braceStart and braceEnd; any text around them is part of the value
+// into which each of its choices is substituted.
+type token struct {
+	kind       tokenKind
+	op         rune
+	start      int
+	end        int
+	braceStart int
+	braceEnd   int
+}
+
+func (p *parser) errorf(offset int, format string, args ...any) *SyntaxError {
+	return &SyntaxError{Spec: p.spec, Offset: offset, Message: fmt.Sprintf(format, args...)}
+}
+
+// trimmed returns the offsets of the text between start and end
+// without its leading and trailing space.
+func (p *parser) trimmed(start, end int) (int, int) {
+	text := p.spec[start:end]
+	start += len(text) - len(strings.TrimLeft(text, " \t\r\n"))
+	end -= len(text) - len(strings.TrimRight(text, " \t\r\n"))
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+func (p *parser) parseRollSpec() (*RollSpec, error) {
+	var err error
+	r := &RollSpec{Position: Position{End: len(p.spec)}}
+
+	bodyStart := 0
+	if i := titleEnd(p.spec); i >= 0 {
+		if start, end := p.trimmed(0, i); end > start {
+			r.Title = &Title{Position: Position{start, end}, Text: p.spec[start:end]}
+		}
+		bodyStart = i + 1
+	}
+
+	body, modifiers, err := p.parseModifiers(bodyStart)
+	if err != nil {
+		return nil, err
+	}
+	r.Modifiers = modifiers
+
+	if start, end := p.trimmed(body.Start, body.End); end > start && p.spec[start] == '@' {
+		if r.Table, err = p.parseTableRoll(start, end); err != nil {
+			return nil, err
+		}
+		for _, m := range r.Modifiers {
+			if m.Kind != RepeatModifier {
+				return nil, p.errorf(m.Start, "global modifier option \"%s\" can't be used with random table rolls (only repeat is allowed)", m.String())
+			}
+		}
+		return r, nil
+	}
+
+	//
+	// Degrees of success are measured against a DC or an opposing
+	// roll, but not both.
+	//
+	var dc, versus, degrees *Modifier
+	for _, m := range r.Modifiers {
+		switch m.Kind {
+		case DCModifier:
+			if dc = m; m.Value == 0 {
+				dc = nil
+			}
+		case VersusModifier:
+			versus = m
+		case DegreesModifier:
+			degrees = m
+		}
+	}
+	if degrees != nil && dc == nil && versus == nil {
+		return nil, p.errorf(degrees.Start, "you can't grade degrees of success without a dc or vs option")
+	}
+	if dc != nil && versus != nil {
+		return nil, p.errorf(max(dc.Start, versus.Start), "you can't have both a DC and an opposed roll")
+	}
+
+	if fields := reParseChance.FindStringSubmatchIndex(p.spec[body.Start:body.End]); fields != nil {
+		if r.Chance, err = p.parseChance(body, fields); err != nil {
+			return nil, err
+		}
+		for _, m := range r.Modifiers {
+			switch m.Kind {
+			case MinModifier, MaxModifier:
+				return nil, p.errorf(m.Start, "invalid global modifier for percentile die rolls")
+			case ConfirmModifier:
+				return nil, p.errorf(m.Start, "you can't confirm critical percentile die rolls")
+			case DCModifier:
+				return nil, p.errorf(m.Start, "you can't have a percentile die roll with a DC")
+			case DegreesModifier, BandModifier, VersusModifier:
+				return nil, p.errorf(m.Start, "you can't use degrees of success, outcome bands, or opposed rolls with percentile die rolls")
+			}
+		}
+		return r, nil
+	}
+
+	if r.Roll, err = p.parseExpression(body.Start, body.End); err != nil {
+		return nil, err
+	}
+
+	//
+	// Automatic success or failure (including that implied by
+	// critical confirmation) depends on the natural roll of a single
+	// die, unless the dice aren't really being rolled at all.
+	//
+	var autoSF *Modifier
+	for _, m := range r.Modifiers {
+		switch m.Kind {
+		case ConfirmModifier, SFModifier:
+			if autoSF == nil {
+				autoSF = m
+			}
+		case MaximizedModifier:
+			return r, nil
+		}
+	}
+	if autoSF != nil {
+		if least, most, single := diceIn(r.Roll); least != 1 || most != 1 || !single {
+			return nil, p.errorf(autoSF.Start, "you can't indicate auto-success/fail (|%s option) because it involves multiple dice", strings.Fields(autoSF.String())[0])
+		}
+	}
+	return r, nil
+}
+
+// parseModifiers parses the global modifiers, separated by vertical bars,
+// which follow the body of the specification starting at bodyStart.
+// It returns the position of the body and the modifiers.
+func (p *parser) parseModifiers(bodyStart int) (Position, []*Modifier, error) {
+	var modifiers []*Modifier
+
+	pieces := []Position{}
+	start := bodyStart
+	for i := bodyStart; i <= len(p.spec); i++ {
+		if i == len(p.spec) || p.spec[i] == '|' {
+			pieces = append(pieces, Position{start, i})
+			start = i + 1
+		}
+	}
+	for _, piece := range pieces[1:] {
+		m, err := p.parseModifier(piece)
+		if err != nil {
+			return Position{}, nil, err
+		}
+		modifiers = append(modifiers, m)
+	}
+	return pieces[0], modifiers, nil
+}
+
+// parseDice parses the description given to New, which is an expression
+// followed by nothing but min and max modifiers. By then any variables have
+// been expanded and any permutations substituted by the DieRoller.
+func parseDice(desc string) (Expr, []*Modifier, error) {
+	p := &parser{spec: desc}
+	body, modifiers, err := p.parseModifiers(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, m := range modifiers {
+		if m.Kind != MinModifier && m.Kind != MaxModifier {
+			return nil, nil, p.errorf(m.Start, "invalid global modifier \"%s\"", p.spec[m.Start:m.End])
+		}
+	}
+	x, err := p.parseExpression(body.Start, body.End)
+	if err != nil {
+		return nil, nil, err
+	}
+	Inspect(x, func(n Node) bool {
+		switch n.(type) {
+		case *VariableRef:
+			err = p.errorf(n.Pos().Start, "die-roll variables may only be used with a DieRoller")
+		case *Permutation:
+			err = p.errorf(n.Pos().Start, "permutations may only be used with a DieRoller")
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return x, modifiers, nil
+}
+
+// diceIn returns the least and most number of DiceTerms in the expression
+// (which differ only if it has permutations), and whether each of them
+// rolls a single die.
+func diceIn(e Expr) (least, most int, single bool) {
+	switch n := e.(type) {
+	case *DiceTerm:
+		keep := n.KeepHighest + n.KeepLowest
+		return 1, 1, !n.Explode && n.SuccessTarget == 0 && n.Again == 0 && n.WildSides == 0 && (keep == 1 || (keep == 0 && n.Count == 1))
+	case *BinaryExpr:
+		xl, xm, xs := diceIn(n.X)
+		yl, ym, ys := diceIn(n.Y)
+		return xl + yl, xm + ym, xs && ys
+	case *UnaryExpr:
+		return diceIn(n.X)
+	case *GroupExpr:
+		return diceIn(n.X)
+	case *Permutation:
+		single = true
+		for i, c := range n.Choices {
+			cl, cm, cs := diceIn(c)
+			if i == 0 || cl < least {
+				least = cl
+			}
+			if cm > most {
+				most = cm
+			}
+			single = single && cs
+		}
+		return least, most, single
+	}
+	return 0, 0, true
+}
+
+// titleEnd returns the offset of the = which ends the title in spec,
+// which is the first one not part of <= or >=, or -1 if there is none.
+func titleEnd(spec string) int {
+	for i := 0; i < len(spec); i++ {
+		if spec[i] == '=' && (i == 0 || (spec[i-1] != '<' && spec[i-1] != '>')) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *parser) parseChance(body Position, fields []int) (*ChanceRoll, error) {
+	var err error
+	start, end := p.trimmed(body.Start, body.End)
+	c := &ChanceRoll{Position: Position{start, end}}
+
+	if c.Percent, err = strconv.Atoi(p.spec[body.Start+fields[2] : body.Start+fields[3]]); err != nil {
+		return nil, p.errorf(body.Start+fields[2], "%v", err)
+	}
+	label := p.spec[body.Start+fields[4] : body.Start+fields[5]]
+	if i := strings.IndexByte(label, '{'); i >= 0 {
+		return nil, p.errorf(body.Start+fields[4]+i, "permutations with percentile die rolls are not supported")
+	}
+	c.Label = strings.TrimSpace(label)
+	return c, nil
+}
+
+func (p *parser) parseTableRoll(start, end int) (*TableRoll, error) {
+	nameStart, _ := p.trimmed(start+1, end)
+	t := &TableRoll{Position: Position{start, end}, Name: p.spec[nameStart:end]}
+	if !ValidTableName(t.Name) {
+		return nil, p.errorf(nameStart, "invalid random table name \"%s\"", t.Name)
+	}
+	return t, nil
+}
+
+func (p *parser) parseModifier(piece Position) (*Modifier, error) {
+	start, end := p.trimmed(piece.Start, piece.End)
+	text := p.spec[start:end]
+	m := &Modifier{Position: Position{start, end}}
+
+	number := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, p.errorf(start, "value error in die roll %s clause: %v", strings.Fields(text)[0], err)
+		}
+		return n, nil
+	}
+
+	var err error
+	if fields := reParseMin.FindStringSubmatch(text); fields != nil {
+		m.Kind = MinModifier
+		m.Value, err = number(fields[1])
+	} else if fields := reParseMax.FindStringSubmatch(text); fields != nil {
+		m.Kind = MaxModifier
+		m.Value, err = number(fields[1])
+	} else if reParseMinmax.MatchString(text) {
+		return nil, p.errorf(start, "invalid global modifier \"%s\"", text)
+	} else if fields := reParseConfirm.FindStringSubmatch(text); fields != nil {
+		m.Kind = ConfirmModifier
+		if fields[1] != "" {
+			if m.Value, err = number(fields[1]); err != nil {
+				return nil, err
+			}
+		}
+		if fields[2] != "" {
+			m.Bonus, err = number(fields[2])
+		}
+	} else if fields := reParseTotal.FindStringSubmatch(text); fields != nil {
+		m.Kind = TotalModifier
+		m.Value, err = number(fields[1])
+	} else if fields := reParseUntil.FindStringSubmatch(text); fields != nil {
+		m.Kind = UntilModifier
+		m.Value, err = number(fields[1])
+	} else if fields := reParseRepeat.FindStringSubmatch(text); fields != nil {
+		m.Kind = RepeatModifier
+		m.Value, err = number(fields[1])
+	} else if reParseMaximized.MatchString(text) {
+		m.Kind = MaximizedModifier
+	} else if fields := reParseDC.FindStringSubmatch(text); fields != nil {
+		m.Kind = DCModifier
+		m.Value, err = number(fields[1])
+	} else if fields := reParseSF.FindStringSubmatch(text); fields != nil {
+		m.Kind = SFModifier
+		m.Success, m.Fail = fields[1], fields[2]
+	} else if fields := reParseDegrees.FindStringSubmatch(text); fields != nil {
+		m.Kind = DegreesModifier
+		if fields[1] != "" {
+			if m.Value, err = number(fields[1]); err == nil && m.Value < 1 {
+				return nil, p.errorf(start, "the steps between degrees of success must be at least 1")
+			}
+		}
+	} else if fields := reParseBand.FindStringSubmatch(text); fields != nil {
+		m.Kind = BandModifier
+		if m.Band, err = newOutcomeBand(fields[1], fields[2]+fields[3], fields[4], fields[5]); err != nil {
+			return nil, p.errorf(start, "%v", err)
+		}
+	} else if fields := reParseVersus.FindStringSubmatchIndex(text); fields != nil {
+		m.Kind = VersusModifier
+		if m.Opposed, err = p.parseExpression(start+fields[2], start+fields[3]); err != nil {
+			return nil, err
+		}
+		Inspect(m.Opposed, func(n Node) bool {
+			if _, ok := n.(*Permutation); ok && err == nil {
+				err = p.errorf(n.Pos().Start, "permutations are not allowed in an opposing roll")
+			}
+			return err == nil
+		})
+	} else {
+		return nil, p.errorf(start, "global modifier option \"%s\" not understood; must be !, band, c, dc, degrees, min, max, maximized, sf, total, until, repeat, or vs", text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// tokenize breaks the expression between start and end into tokens,
+// discarding any which are only white space.
+func (p *parser) tokenize(start, end int) ([]token, error) {
+	var tokens []token
+
+	valueStart := -1
+	endValue := func(i int) {
+		if valueStart >= 0 {
+			if s, e := p.trimmed(valueStart, i); e > s {
+				tokens = append(tokens, token{kind: tokenValue, start: s, end: e})
+			}
+			valueStart = -1
+		}
+	}
+
+	for i := start; i < end; {
+		r, width := utf8.DecodeRuneInString(p.spec[i:end])
+		op := rune(0)
+		switch {
+		case strings.HasPrefix(p.spec[i:end], "//"):
+			op, width = '÷', 2
+		case strings.HasPrefix(p.spec[i:end], "<="):
+			op, width = '≤', 2
+		case strings.HasPrefix(p.spec[i:end], ">="):
+			op, width = '≥', 2
+		case r == '*':
+			op = '×'
+		case strings.ContainsRune("+-×÷()≤≥", r):
+			op = r
+		case r == '{' && i > start && p.spec[i-1] == '$':
+			// a variable reference such as ${bab}; this is part of a value
+		case r == '{':
+			endValue(i)
+			close := strings.IndexByte(p.spec[i:end], '}')
+			if close < 0 {
+				return nil, p.errorf(i, "'{' without matching '}'")
+			}
+			tokens = append(tokens, token{kind: tokenPermutation, start: i, end: i + close + 1, braceStart: i, braceEnd: i + close + 1})
+			i += close + 1
+			continue
+		}
+
+		if op != 0 {
+			endValue(i)
+			tokens = append(tokens, token{kind: tokenOperator, op: op, start: i, end: i + width})
+		} else if valueStart < 0 {
+			valueStart = i
+		}
+		i += width
+	}
+	endValue(end)
+
+	//
+	// A permutation group may be joined to the text of a value
+	// before or after it, as in "{1/2}d6" or "{17/12} bonus".
+	// Combine these into a single permutation token.
+	//
+	var merged []token
+	for _, t := range tokens {
+		if len(merged) > 0 && t.kind != tokenOperator {
+			if prev := &merged[len(merged)-1]; prev.kind != tokenOperator {
+				if t.kind == tokenPermutation {
+					if prev.kind == tokenPermutation {
+						return nil, p.errorf(t.start, "only one {...} group may appear in each value")
+					}
+					t.start = prev.start
+					*prev = t
+				} else {
+					prev.end = t.end
+				}
+				continue
+			}
+		}
+		merged = append(merged, t)
+	}
+	return merged, nil
+}
+
+// parseExpression parses the expression between start and end.
+func (p *parser) parseExpression(start, end int) (Expr, error) {
+	var err error
+
+	outer := *p
+	defer func() { p.tokens, p.next = outer.tokens, outer.next }()
+
+	if p.tokens, err = p.tokenize(start, end); err != nil {
+		return nil, err
+	}
+	p.next = 0
+	if len(p.tokens) == 0 {
+		return nil, p.errorf(start, "empty die-roll expression")
+	}
+
+	x, err := p.parseBinary(1, end)
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.tokens) {
+		t := p.tokens[p.next]
+		if t.kind == tokenOperator && t.op == ')' {
+			return nil, p.errorf(t.start, "')' with no matching '('")
+		}
+		return nil, p.errorf(t.start, "expected operator before \"%s\" in die-roll expression", p.spec[t.start:t.end])
+	}
+	return x, nil
+}
+
+// precedenceOf returns the precedence of a binary operator, or 0 if op
+// is not one.
+func precedenceOf(op rune) int {
+	switch op {
+	case '+', '-':
+		return 1
+	case '×', '÷':
+		return 2
+	case '≤', '≥':
+		return 3
+	}
+	return 0
+}
+
+// parseBinary parses a sequence of operands separated by binary operators
+// of at least the given precedence. All operators are left-associative.
+func (p *parser) parseBinary(minPrecedence, end int) (Expr, error) {
+	x, err := p.parseUnary(end)
+	if err != nil {
+		return nil, err
+	}
+	for p.next < len(p.tokens) {
+		t := p.tokens[p.next]
+		prec := precedenceOf(t.op)
+		if t.kind != tokenOperator || prec < minPrecedence {
+			break
+		}
+		p.next++
+		y, err := p.parseBinary(prec+1, end)
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Position: Position{x.Pos().Start, y.Pos().End}, Op: t.op, X: x, Y: y}
+	}
+	return x, nil
+}
+
+// parseUnary parses an operand, with any leading unary + or - signs.
+// (A unary + has no effect, so it is not represented in the tree.)
+func (p *parser) parseUnary(end int) (Expr, error) {
+	negated := -1
+	for p.next < len(p.tokens) && p.tokens[p.next].kind == tokenOperator {
+		t := p.tokens[p.next]
+		if t.op == '-' {
+			if negated >= 0 {
+				return nil, p.errorf(t.start, "unexpected operator \"-\" in die-roll expression")
+			}
+			negated = t.start
+		} else if t.op != '+' {
+			break
+		}
+		p.next++
+	}
+
+	x, err := p.parseOperand(end)
+	if err != nil {
+		return nil, err
+	}
+	if negated >= 0 {
+		return &UnaryExpr{Position: Position{negated, x.Pos().End}, X: x}, nil
+	}
+	return x, nil
+}
+
+// parseOperand parses a single value: a parenthesized expression, a die roll,
+// a constant, a variable, or a permutation group.
+func (p *parser) parseOperand(end int) (Expr, error) {
+	if p.next >= len(p.tokens) {
+		return nil, p.errorf(end, "missing value after last operator in die-roll expression")
+	}
+	t := p.tokens[p.next]
+	p.next++
+
+	switch t.kind {
+	case tokenOperator:
+		if t.op != '(' {
+			return nil, p.errorf(t.start, "unexpected operator \"%s\" in die-roll expression", p.spec[t.start:t.end])
+		}
+		x, err := p.parseBinary(1, end)
+		if err != nil {
+			return nil, err
+		}
+		if p.next >= len(p.tokens) {
+			return nil, p.errorf(t.start, "'(' without matching ')' in die-roll expression")
+		}
+		if closing := p.tokens[p.next]; closing.kind != tokenOperator || closing.op != ')' {
+			return nil, p.errorf(closing.start, "expected operator before \"%s\" in die-roll expression", p.spec[closing.start:closing.end])
+		}
+		g := &GroupExpr{Position: Position{t.start, p.tokens[p.next].end}, X: x}
+		p.next++
+		return g, p.parseTrailingLabel(&g.Position, &g.Label)
+
+	case tokenPermutation:
+		perm := &Permutation{Position: Position{t.start, t.end}}
+		choiceStart := t.braceStart + 1
+		for i := choiceStart; i < t.braceEnd; i++ {
+			if p.spec[i] == '/' && p.spec[i+1] == '/' {
+				i++
+				continue
+			}
+			if p.spec[i] == '/' || p.spec[i] == '}' {
+				choice, err := p.parseChoice(t, choiceStart, i)
+				if err != nil {
+					return nil, err
+				}
+				perm.Choices = append(perm.Choices, choice)
+				choiceStart = i + 1
+			}
+		}
+		if len(perm.Choices) < 2 {
+			return nil, p.errorf(t.braceStart, "values in braces must have more than one value separated by slashes")
+		}
+		return perm, nil
+	}
+
+	return p.parseValue(t.start, t.end)
+}
+
+// parseChoice parses the choice between start and end in the
+// permutation token t. If the group is joined to other text, the choice
+// is the value formed by substituting it into that text, and all the
+// nodes parsed from it are given the position of the whole token.
+func (p *parser) parseChoice(t token, start, end int) (Expr, error) {
+	if t.start == t.braceStart && t.end == t.braceEnd {
+		return p.parseExpression(start, end)
+	}
+
+	sub := &parser{spec: p.spec[t.start:t.braceStart] + p.spec[start:end] + p.spec[t.braceEnd:t.end]}
+	choice, err := sub.parseExpression(0, len(sub.spec))
+	if err != nil {
+		return nil, p.errorf(start, "%s (in \"%s\")", err.(*SyntaxError).Message, sub.spec)
+	}
+	Inspect(choice, func(n Node) bool {
+		pos := Position{t.start, t.end}
+		switch e := n.(type) {
+		case *BinaryExpr:
+			e.Position = pos
+		case *UnaryExpr:
+			e.Position = pos
+		case *GroupExpr:
+			e.Position = pos
+		case *DiceTerm:
+			e.Position = pos
+		case *ConstantTerm:
+			e.Position = pos
+		case *VariableRef:
+			e.Position = pos
+		case *Permutation:
+			e.Position = pos
+		}
+		return true
+	})
+	return choice, nil
+}
+
+// parseTrailingLabel looks for a label following a closing ),
+// and stores it in label if there is one, extending pos to include it.
+func (p *parser) parseTrailingLabel(pos *Position, label *string) error {
+	if p.next >= len(p.tokens) || p.tokens[p.next].kind != tokenValue {
+		return nil
+	}
+	t := p.tokens[p.next]
+	text := p.spec[t.start:t.end]
+	if !reParseBareLabel.MatchString(text) {
+		return p.errorf(t.start, "expected operator before \"%s\" in die-roll expression", text)
+	}
+	if reParseDieSpec.MatchString(text) {
+		return p.errorf(t.start, "\"%s\" looks suspiciously like a die-roll specification but appears as a label; did you forget an operator?", text)
+	}
+	if err := p.checkLabel(t.start, text); err != nil {
+		return err
+	}
+	p.next++
+	*label = text
+	pos.End = t.end
+	return nil
+}
+
+// checkLabel reports an error if the label at offset
+// contains something which isn't allowed there.
+func (p *parser) checkLabel(offset int, label string) error {
+	if loc := reParseConfirmUsed.FindStringIndex(label); loc != nil {
+		return p.errorf(offset+loc[0], "confirmation specifier (c[threat][±bonus]) not allowed in this location. It must be at the end of a full DieRoller description string only")
+	}
+	if loc := reParseMinmaxLabel.FindStringIndex(label); loc != nil {
+		return p.errorf(offset+loc[0], "min/max limits must appear after the final operator in the expression, since they apply to the entire set of dice rolls")
+	}
+	return nil
+}
+
+// parseValue parses the text of a die roll, constant, or variable
+// between start and end.
+func (p *parser) parseValue(start, end int) (Expr, error) {
+	text := p.spec[start:end]
+	pos := Position{start, end}
+
+	// submatch returns the text of a submatch and its offset in the spec.
+	submatch := func(fields []int, n int) (string, int) {
+		if fields[2*n] < 0 {
+			return "", start
+		}
+		return text[fields[2*n]:fields[2*n+1]], start + fields[2*n]
+	}
+	label := func(fields []int, n int) (string, error) {
+		l, offset := submatch(fields, n)
+		if l == "" {
+			return "", nil
+		}
+		if !reParseBareLabel.MatchString(l) {
+			return "", p.errorf(offset, "label \"%s\" has illegal characters", l)
+		}
+		return l, p.checkLabel(offset, l)
+	}
+
+	if fields := reParseDieSpec.FindStringSubmatchIndex(text); fields != nil {
+		d := &DiceTerm{Position: pos, Count: 1}
+		number := func(n int) (int, error) {
+			s, offset := submatch(fields, n)
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				return 0, p.errorf(offset, "value error in die roll subexpression \"%s\": %v", text, err)
+			}
+			return v, nil
+		}
+		var err error
+
+		d.InitialMax = fields[2] >= 0
+		if s, _ := submatch(fields, 2); s != "" {
+			if d.Count, err = number(2); err != nil {
+				return nil, err
+			}
+		}
+		if fields[6] >= 0 {
+			if d.Denominator, err = number(3); err != nil {
+				return nil, err
+			}
+		}
+		if s, _ := submatch(fields, 4); s == "%" {
+			d.Sides, d.Percentile = 100, true
+		} else if d.Sides, err = number(4); err != nil {
+			return nil, err
+		}
+		if s, offset := submatch(fields, 5); s != "" {
+			if d.Sides < 2 {
+				return nil, p.errorf(offset, "dice must have at least 2 sides to explode")
+			}
+			d.Explode = true
+		}
+		if s, offset := submatch(fields, 6); s != "" {
+			d.RerollOnce = s == "ro"
+			if d.RerollBelow, err = number(7); err != nil {
+				return nil, err
+			}
+			if !d.RerollOnce && d.RerollBelow >= d.Sides {
+				return nil, p.errorf(offset, "can't reroll every possible value of the die")
+			}
+		}
+		if s, offset := submatch(fields, 8); s != "" {
+			if d.Again, err = number(8); err != nil {
+				return nil, err
+			}
+			if d.Again < 2 || d.Again > d.Sides {
+				return nil, p.errorf(offset, "can only roll again on values from 2 to %d", d.Sides)
+			}
+			if d.Explode {
+				return nil, p.errorf(offset-1, "dice can't both explode and roll again")
+			}
+		}
+		if fields[20] >= 0 {
+			_, offset := submatch(fields, 9)
+			keep, err := number(10)
+			if err != nil {
+				return nil, err
+			}
+			if keep < 1 || keep > d.Count {
+				return nil, p.errorf(offset-1, "can only keep from 1 to %d dice", d.Count)
+			}
+			if d.InitialMax {
+				return nil, p.errorf(offset-1, "can't keep only some of the dice when the first one is maximized")
+			}
+			if d.Again > 0 {
+				return nil, p.errorf(offset-1, "can't keep only some of the dice when rolling again")
+			}
+			if s, _ := submatch(fields, 9); s == "l" {
+				d.KeepLowest = keep
+			} else {
+				d.KeepHighest = keep
+			}
+		}
+		if s, offset := submatch(fields, 11); s != "" {
+			if d.WildSides, err = number(11); err != nil {
+				return nil, err
+			}
+			if d.WildSides < 2 {
+				return nil, p.errorf(offset, "the wild die must have at least 2 sides")
+			}
+			if !d.RerollOnce && d.RerollBelow >= d.WildSides {
+				return nil, p.errorf(offset, "can't reroll every possible value of the wild die")
+			}
+			if d.Again > 0 || d.KeepHighest > 0 || d.KeepLowest > 0 {
+				return nil, p.errorf(offset-1, "can't use a wild die with the again or keep options")
+			}
+		}
+		if fields[24] >= 0 {
+			if d.SuccessTarget, err = number(12); err != nil {
+				return nil, err
+			}
+			if d.SuccessTarget < 1 {
+				_, offset := submatch(fields, 12)
+				return nil, p.errorf(offset, "success target must be at least 1")
+			}
+		}
+		if s, offset := submatch(fields, 13); s != "" {
+			if d.Botch, err = number(13); err != nil {
+				return nil, err
+			}
+			if d.Botch < 1 || d.Botch >= d.SuccessTarget {
+				return nil, p.errorf(offset, "botches must be at least 1 and less than the success target")
+			}
+		}
+		if s, _ := submatch(fields, 14); s != "" {
+			n, err := number(15)
+			if err != nil {
+				return nil, err
+			}
+			if s == "best" {
+				d.BestOf = n
+			} else {
+				d.WorstOf = n
+			}
+		}
+		if l, offset := submatch(fields, 16); reParseIsDie.MatchString(l) {
+			return nil, p.errorf(offset, "label following die roll looks like another die roll--did you forget an operator?")
+		} else if opt := reParseDieOption.FindStringSubmatchIndex(l); opt != nil {
+			return nil, p.errorf(offset+opt[2], "die-roll option \"%s\" is out of place; options must appear in the order %s", l[opt[2]:opt[3]], dieOptionOrder)
+		}
+		if d.Label, err = label(fields, 16); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+
+	if fields := reParseConstant.FindStringSubmatchIndex(text); fields != nil {
+		var err error
+		c := &ConstantTerm{Position: pos}
+		s, offset := submatch(fields, 1)
+		if c.Value, err = strconv.ParseFloat(s, 64); err != nil {
+			return nil, p.errorf(offset, "value error in die roll subexpression \"%s\": %v", text, err)
+		}
+		if c.Label, err = label(fields, 2); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	if fields := reParseVariable.FindStringSubmatchIndex(text); fields != nil {
+		var err error
+		v := &VariableRef{Position: pos}
+		if v.Name, _ = submatch(fields, 1); v.Name == "" {
+			v.Name, _ = submatch(fields, 2)
+		}
+		if l, offset := submatch(fields, 3); reParseIsDie.MatchString(l) {
+			return nil, p.errorf(offset, "label following variable looks like a die roll--did you forget an operator?")
+		}
+		if v.Label, err = label(fields, 3); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	return nil, p.errorf(start, "syntax error in die roll subexpression \"%s\"; should be \"[<n>[/<d>]] d [<sides>|%%] [!] [r[o]<n>] [a<n>] [kh|kl<n>] [w<sides>] [s<n> [b<n>]] [best|worst of <n>] [<label>]\" or a number", text)
+}
+
+//
+// Printing the syntax tree back out as a die-roll specification.
+//
+
+func (r *RollSpec) String() string {
+	var s strings.Builder
+
+	if r.Chance != nil {
+		s.WriteString(r.Chance.String())
+	} else if r.Table != nil {
+		s.WriteString(r.Table.String())
+	} else if r.Roll != nil {
+		s.WriteString(r.Roll.String())
+	}
+	for _, m := range r.Modifiers {
+		s.WriteString(" | ")
+		s.WriteString(m.String())
+	}
+
+	switch {
+	case r.Title != nil:
+		if strings.HasSuffix(r.Title.Text, "<") || strings.HasSuffix(r.Title.Text, ">") {
+			return r.Title.String() + " =" + s.String()
+		}
+		return r.Title.String() + "=" + s.String()
+	case titleEnd(s.String()) >= 0:
+		// an empty title keeps an = in the rest from being taken as one
+		return "=" + s.String()
+	}
+	return s.String()
+}
+
+func (t *Title) String() string {
+	return t.Text
+}
+
+func (c *ChanceRoll) String() string {
+	return withLabel(strconv.Itoa(c.Percent)+"%", c.Label)
+}
+
+func (t *TableRoll) String() string {
+	return "@" + t.Name
+}
+
+// withLabel returns the text followed by the label, if there is one.
+func withLabel(text, label string) string {
+	if label == "" {
+		return text
+	}
+	return text + " " + label
+}
+
+// operatorText gives the canonical spelling of each binary operator.
+var operatorText = map[rune]string{
+	'+': "+",
+	'-': "-",
+	'×': "*",
+	'÷': "//",
+	'≤': "<=",
+	'≥': ">=",
+}
+
+func (b *BinaryExpr) String() string {
+	x, y := b.X.String(), b.Y.String()
+	// add parentheses if needed for trees which weren't built by Parse
+	if inner, ok := b.X.(*BinaryExpr); ok && precedenceOf(inner.Op) < precedenceOf(b.Op) {
+		x = "(" + x + ")"
+	}
+	if inner, ok := b.Y.(*BinaryExpr); ok && precedenceOf(inner.Op) <= precedenceOf(b.Op) {
+		y = "(" + y + ")"
+	}
+	return x + " " + operatorText[b.Op] + " " + y
+}
+
+func (u *UnaryExpr) String() string {
+	if _, ok := u.X.(*BinaryExpr); ok {
+		return "-(" + u.X.String() + ")"
+	}
+	return "-" + u.X.String()
+}
+
+func (g *GroupExpr) String() string {
+	return withLabel("("+g.X.String()+")", g.Label)
+}
+
+func (d *DiceTerm) String() string {
+	var s strings.Builder
+
+	if d.InitialMax {
+		s.WriteByte('>')
+	}
+	if d.Count != 1 || d.Denominator != 0 {
+		s.WriteString(strconv.Itoa(d.Count))
+	}
+	if d.Denominator != 0 {
+		fmt.Fprintf(&s, "/%d ", d.Denominator)
+	}
+	if d.Percentile {
+		s.WriteString("d%")
+	} else {
+		fmt.Fprintf(&s, "d%d", d.Sides)
+	}
+	if d.Explode {
+		s.WriteByte('!')
+	}
+	if d.RerollBelow != 0 {
+		if d.RerollOnce {
+			fmt.Fprintf(&s, " ro%d", d.RerollBelow)
+		} else {
+			fmt.Fprintf(&s, " r%d", d.RerollBelow)
+		}
+	}
+	if d.Again != 0 {
+		fmt.Fprintf(&s, " a%d", d.Again)
+	}
+	if d.KeepHighest != 0 {
+		fmt.Fprintf(&s, " kh%d", d.KeepHighest)
+	} else if d.KeepLowest != 0 {
+		fmt.Fprintf(&s, " kl%d", d.KeepLowest)
+	}
+	if d.WildSides != 0 {
+		fmt.Fprintf(&s, " w%d", d.WildSides)
+	}
+	if d.SuccessTarget != 0 {
+		fmt.Fprintf(&s, " s%d", d.SuccessTarget)
+		if d.Botch != 0 {
+			fmt.Fprintf(&s, " b%d", d.Botch)
+		}
+	}
+	if d.BestOf != 0 {
+		fmt.Fprintf(&s, " best of %d", d.BestOf)
+	} else if d.WorstOf != 0 {
+		fmt.Fprintf(&s, " worst of %d", d.WorstOf)
+	}
+	return withLabel(s.String(), d.Label)
+}
+
+func (c *ConstantTerm) String() string {
+	return withLabel(strconv.FormatFloat(c.Value, 'f', -1, 64), c.Label)
+}
+
+func (v *VariableRef) String() string {
+	if v.Label == "" {
+		return "$" + v.Name
+	}
+	return "${" + v.Name + "} " + v.Label
+}
+
+func (p *Permutation) String() string {
+	choices := make([]string, len(p.Choices))
+	for i, c := range p.Choices {
+		choices[i] = c.String()
+	}
+	return "{" + strings.Join(choices, "/") + "}"
+}
+
+func (m *Modifier) String() string {
+	switch m.Kind {
+	case MinModifier:
+		return fmt.Sprintf("min %d", m.Value)
+	case MaxModifier:
+		return fmt.Sprintf("max %d", m.Value)
+	case ConfirmModifier:
+		s := "c"
+		if m.Value != 0 {
+			s += strconv.Itoa(m.Value)
+		}
+		if m.Bonus != 0 {
+			s += fmt.Sprintf("%+d", m.Bonus)
+		}
+		return s
+	case DCModifier:
+		return fmt.Sprintf("dc %d", m.Value)
+	case SFModifier:
+		if m.Success == "" {
+			return "sf"
+		}
+		if m.Fail == "" {
+			return "sf " + m.Success
+		}
+		return "sf " + m.Success + "/" + m.Fail
+	case UntilModifier:
+		return fmt.Sprintf("until %d", m.Value)
+	case TotalModifier:
+		return fmt.Sprintf("total %d", m.Value)
+	case RepeatModifier:
+		return fmt.Sprintf("repeat %d", m.Value)
+	case MaximizedModifier:
+		return "maximized"
+	case DegreesModifier:
+		if m.Value == 0 {
+			return "degrees"
+		}
+		return fmt.Sprintf("degrees %d", m.Value)
+	case BandModifier:
+		return "band " + m.Band.String()
+	case VersusModifier:
+		return "vs " + m.Opposed.String()
+	}
+	return ""
+}
+
+// Inspect traverses the syntax tree rooted at node in depth-first order,
+// calling f for each node. If f returns false, Inspect does not descend
+// into that node's children.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *RollSpec:
+		if n.Title != nil {
+			Inspect(n.Title, f)
+		}
+		if n.Roll != nil {
+			Inspect(n.Roll, f)
+		}
+		if n.Chance != nil {
+			Inspect(n.Chance, f)
+		}
+		if n.Table != nil {
+			Inspect(n.Table, f)
+		}
+		for _, m := range n.Modifiers {
+			Inspect(m, f)
+		}
+	case *Modifier:
+		if n.Opposed != nil {
+			Inspect(n.Opposed, f)
+		}
+	case *BinaryExpr:
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+	case *UnaryExpr:
+		Inspect(n.X, f)
+	case *GroupExpr:
+		Inspect(n.X, f)
+	case *Permutation:
+		for _, c := range n.Choices {
+			Inspect(c, f)
+		}
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,451 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for the die-roll specification parser
+//
+
+package dice
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTree(t *testing.T) {
+	r, err := Parse("Attack≡red = d20 + {17/12/7} + (2d6 fire - 1) magic | total 30 | dc 15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Title == nil || r.Title.Text != "Attack≡red" || r.Title.Start != 0 || r.Title.End != len("Attack≡red") {
+		t.Errorf("title %#v", r.Title)
+	}
+	if r.Chance != nil {
+		t.Errorf("unexpected chance roll %#v", r.Chance)
+	}
+
+	sum, ok := r.Roll.(*BinaryExpr)
+	if !ok || sum.Op != '+' {
+		t.Fatalf("top of expression is %#v", r.Roll)
+	}
+	group, ok := sum.Y.(*GroupExpr)
+	if !ok || group.Label != "magic" {
+		t.Fatalf("right side of expression is %#v", sum.Y)
+	}
+	if text := "(2d6 fire - 1) magic"; r.String()[group.Start-2:group.End-2] != text {
+		t.Errorf("group position %v", group.Position)
+	}
+	inner := group.X.(*BinaryExpr)
+	if d, ok := inner.X.(*DiceTerm); !ok || d.Count != 2 || d.Sides != 6 || d.Label != "fire" {
+		t.Errorf("group contains %#v", inner.X)
+	}
+	left := sum.X.(*BinaryExpr)
+	if d, ok := left.X.(*DiceTerm); !ok || d.Count != 1 || d.Sides != 20 || d.Label != "" {
+		t.Errorf("first term is %#v", left.X)
+	}
+	perm, ok := left.Y.(*Permutation)
+	if !ok || len(perm.Choices) != 3 {
+		t.Fatalf("second term is %#v", left.Y)
+	}
+	if c, ok := perm.Choices[1].(*ConstantTerm); !ok || c.Value != 12 {
+		t.Errorf("second permutation choice is %#v", perm.Choices[1])
+	}
+
+	if len(r.Modifiers) != 2 {
+		t.Fatalf("modifiers %v", r.Modifiers)
+	}
+	if m := r.Modifiers[0]; m.Kind != TotalModifier || m.Value != 30 {
+		t.Errorf("first modifier %#v", m)
+	}
+	if m := r.Modifiers[1]; m.Kind != DCModifier || m.Value != 15 {
+		t.Errorf("second modifier %#v", m)
+	}
+
+	var kinds []string
+	Inspect(r, func(n Node) bool {
+		kinds = append(kinds, strings.TrimPrefix(reflect.TypeOf(n).String(), "*dice."))
+		return true
+	})
+	if got := strings.Join(kinds, " "); got != "RollSpec Title BinaryExpr BinaryExpr DiceTerm Permutation ConstantTerm ConstantTerm ConstantTerm GroupExpr BinaryExpr DiceTerm ConstantTerm Modifier Modifier" {
+		t.Errorf("Inspect visited %s", got)
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	for _, test := range []struct {
+		spec, tree string
+	}{
+		{"1+2*3", "(1+(2×3))"},
+		{"1*2+3", "((1×2)+3)"},
+		{"1-2-3", "((1-2)-3)"},
+		{"8//2//2", "((8÷2)÷2)"},
+		{"2*d6<=3", "(2×(d6≤3))"},
+		{"-2*3", "((-2)×3)"},
+		{"-(1+2)", "(-(1+2))"},
+		{"+-+3>=d4", "((-3)≥d4)"},
+		{"(1+2) fire*3", "((1+2) fire×3)"},
+	} {
+		r, err := Parse(test.spec)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		if got := treeString(r.Roll); got != test.tree {
+			t.Errorf("%s: parsed as %s, expected %s", test.spec, got, test.tree)
+		}
+	}
+}
+
+// treeString shows the structure of an expression with every operation
+// in parentheses.
+func treeString(e Expr) string {
+	switch n := e.(type) {
+	case nil:
+		return ""
+	case *BinaryExpr:
+		return "(" + treeString(n.X) + string(n.Op) + treeString(n.Y) + ")"
+	case *UnaryExpr:
+		return "(-" + treeString(n.X) + ")"
+	case *GroupExpr:
+		return withLabel(treeString(n.X), n.Label)
+	default:
+		return e.String()
+	}
+}
+
+var parserTestSpecs = []string{
+	"d20",
+	"3d6",
+	"15d6+15",
+	"1d10+5*10",
+	"1/2 d6",
+	"2d10+3d6+12",
+	"d20+15|c",
+	"d20+15|c19+2",
+	"d20+15 | c-1",
+	"d%",
+	"40%",
+	"40% hit",
+	"13% red/blue | repeat 3",
+	"d20+12|max20",
+	"d20+12 | min 1 | max 20",
+	"d20 best of 2",
+	"d20 worst of 3 + 2",
+	"d20+4|dc 10",
+	"d20+4 | DC -2",
+	"3d6 fire+1d4 acid+2 bonus",
+	"2d10+3|until 19",
+	"1d8+3|total 30",
+	"3d6 | repeat 4",
+	"3d6 | maximized",
+	"3d6 | !",
+	">3d6+2",
+	"d20 | sf",
+	"d20 | sf hit",
+	"d20 | sf yay/boo",
+	"Attack=d20+{17/12/7}",
+	"Attack=d20+{17/12/7}+{1/2} bonus|c",
+	"a≡red‖b≡blue = d8",
+	"1.5*d6",
+	".5 half*d6",
+	"d6 <= 3",
+	"d6 >= 3 + 2",
+	"3d6!",
+	"d20 r1",
+	"d20 ro1",
+	"4d6 kh3",
+	"4d6kh3",
+	"2d20 kl1",
+	"10d10 s8",
+	"3d6! r1 kh2 s4 best of 2 stuff",
+	"(d6+1) fire * 2",
+	"-d6",
+	"--5",
+	"2 - -d6",
+	"0d6",
+	"3d6 (",
+	"3d6 + (2",
+	"3d6 + 2)",
+	"3d6 +",
+	"3d6 + * 2",
+	"d20 | c | dc 5 | sf",
+	"d20 | bogus",
+	"d20 | min 3 foo",
+	"d20 fire 2d6",
+	"d20 x@y",
+	"3d6 c",
+	"3d6 max 5",
+	"d1!",
+	"d6 r6",
+	"d6 ro6",
+	"3d6 kh4",
+	"3d6 kh0",
+	">3d6 kh2",
+	"10d10 s0",
+	"40% | c",
+	"40% | dc 5",
+	"40% | min 5",
+	"40% {a/b}",
+	"d20+{17}",
+	"d20+{17/12",
+	"(d6) 2d6",
+	"title=",
+	"|c",
+	"d20+5 | c20",
+	"{1/2}d6",
+	"d{4/6/8} + 2",
+	"d20 + {17/12} bonus | c",
+	"{1/2}d6 | c",
+	"{1d6/2} | sf",
+	"{d20 + 1/d20 + 2} | c",
+	"(1+d20){1/2}",
+	"d20+7 | dc 18 | degrees",
+	"d20+7 | dc 18 | degrees 5 | c",
+	"d20+7 | degrees",
+	"d20+7 | dc 0 | degrees",
+	"d20+7 | degrees 0 | dc 18",
+	"2d6+1 | band 6- miss | band 7-9 weak hit | band 10+ strong hit",
+	"d20 | band -5--1 bad | band 0 none",
+	"d20 | band 9-3 backwards",
+	"d20 | band 5",
+	"d20+5 | vs d20+3",
+	"d20+5 | vs (d20+3) * 2 | degrees",
+	"d20+5 | vs d20 | dc 10",
+	"d20+5 | vs {1/2}",
+	"d20+5 | vs d20 +",
+	"Attack=d20+{17/12} | vs 2d6 | repeat 2",
+	"40% | degrees",
+	"40% | band 1 yes",
+	"40% | vs d20",
+	"8d10 a10 s8",
+	"6d10 s6 b1",
+	"5d10 r1 a9 s7 b2 hits",
+	"d8! w6",
+	"2d8 ro1 w6 + 2",
+	"d6 a1",
+	"d6 a7",
+	"d6! a6",
+	"3d6 a6 kh2",
+	"3d6 kh2 w6",
+	"d6 r3 w3",
+	"d6 w1",
+	"d6 s3 b3",
+	"d6 s3 b0",
+	"d20 w6 | c",
+	"d20 r1 ro1",
+	"2d6kh1 kl1",
+	"d6 fire w6",
+	"d6 rapier",
+}
+
+func TestDoRollSyntaxErrors(t *testing.T) {
+	for _, spec := range parserTestSpecs {
+		_, parseErr := Parse(spec)
+		if parseErr == nil {
+			continue
+		}
+		dr, err := NewDieRoller(WithSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, rollErr := dr.DoRoll(spec)
+		var se *SyntaxError
+		if !errors.As(rollErr, &se) {
+			t.Errorf("%q: DoRoll error %v is not a SyntaxError", spec, rollErr)
+			continue
+		}
+		if se.Error() != parseErr.Error() {
+			t.Errorf("%q: DoRoll error %v but Parse error %v", spec, se, parseErr)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	for _, spec := range parserTestSpecs {
+		r, err := Parse(spec)
+		if err != nil {
+			continue
+		}
+		canonical := r.String()
+		again, err := Parse(canonical)
+		if err != nil {
+			t.Errorf("%q: canonical form %q does not parse: %v", spec, canonical, err)
+			continue
+		}
+		if again.String() != canonical {
+			t.Errorf("%q: canonical form %q printed again as %q", spec, canonical, again.String())
+		}
+		if treeString(again.Roll) != treeString(r.Roll) {
+			t.Errorf("%q: canonical form %q has a different structure", spec, canonical)
+		}
+
+		// rolling both with the same seed must give the same results
+		dr1, _ := NewDieRoller(WithSeed(42))
+		dr2, _ := NewDieRoller(WithSeed(42))
+		title1, results1, err1 := dr1.DoRoll(spec)
+		title2, results2, err2 := dr2.DoRoll(canonical)
+		if err1 != nil || err2 != nil {
+			t.Errorf("%q: rolled with error %v, canonical %q with error %v", spec, err1, canonical, err2)
+			continue
+		}
+		if title1 != title2 || len(results1) != len(results2) {
+			t.Errorf("%q: rolled %q with %d results, canonical %q rolled %q with %d results", spec, title1, len(results1), canonical, title2, len(results2))
+			continue
+		}
+		for i := range results1 {
+			if results1[i].Result != results2[i].Result {
+				t.Errorf("%q: result #%d was %d, canonical %q gave %d", spec, i, results1[i].Result, canonical, results2[i].Result)
+			}
+		}
+	}
+}
+
+func TestParseCanonicalForm(t *testing.T) {
+	for _, test := range []struct {
+		spec, canonical string
+	}{
+		{"  Hit  =1D20+5|c19+2|dc  15", "Hit=d20 + 5 | c19+2 | dc 15"},
+		{"d20*2//3", "d20 * 2 // 3"},
+		{"d6≤3 ≥ 1", "d6 <= 3 >= 1"},
+		{"4d6kh3", "4d6 kh3"},
+		{"1/2d%", "1/2 d%"},
+		{"3d6 ! ro 1 s 4 best of 2 x", "3d6! ro1 s4 best of 2 x"},
+		{"40%hit/miss", "40% hit/miss"},
+		{"d20+$str+${bab} base", "d20 + $str + ${bab} base"},
+		{"d20 | !", "d20 | maximized"},
+		{"d20|sf   a/b", "d20 | sf a/b"},
+		{" = d20 | sf x=y", "=d20 | sf x=y"},
+		{"a> = d20", "a> =d20"},
+		{"{1/2}d6 + {17/12} bonus", "{d6/2d6} + {17 bonus/12 bonus}"},
+		{"d20|dc 5|degrees10", "d20 | dc 5 | degrees 10"},
+		{"2d6|band6- miss|band 7-9 x|band  10+ y", "2d6 | band 6- miss | band 7-9 x | band 10+ y"},
+		{"2d6|band 7-9 weak  hit", "2d6 | band 7-9 weak  hit"},
+		{"d20+5|vs 1d20+3 bonus", "d20 + 5 | vs d20 + 3 bonus"},
+		{"5d10 r 1 a 9 s 7 b 2 hits", "5d10 r1 a9 s7 b2 hits"},
+		{"d8!w6", "d8! w6"},
+		{"Loot = @ Wandering  Monsters|repeat 3", "Loot=@Wandering  Monsters | repeat 3"},
+	} {
+		r, err := Parse(test.spec)
+		if err != nil {
+			t.Errorf("%q: %v", test.spec, err)
+			continue
+		}
+		if got := r.String(); got != test.canonical {
+			t.Errorf("%q: printed as %q, expected %q", test.spec, got, test.canonical)
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	for _, test := range []struct {
+		spec   string
+		column int
+		msg    string
+	}{
+		{"d20+*5", 5, "unexpected operator \"*\""},
+		{"d20 + (2d6 fire", 7, "'(' without matching ')'"},
+		{"d20 + 2d6 fire)", 15, "')' with no matching '('"},
+		{"d20 + 2d6 | bogus", 13, "global modifier option \"bogus\" not understood"},
+		{"Über=d20 + 5d6 fire x@y", 16, "label \"fire x@y\" has illegal characters"},
+		{"d20 + 3d6 kh4", 11, "can only keep from 1 to 3 dice"},
+		{"d20 +", 6, "missing value after last operator"},
+		{"d20 (1)", 5, "expected operator before \"(\""},
+		{"d20 + {1} + 2", 7, "more than one value"},
+		{"d20 + {1/2", 7, "'{' without matching '}'"},
+		{"d20 + {1/*}", 10, "unexpected operator \"*\""},
+		{"d20 + 2d6 c", 11, "confirmation specifier"},
+		{"d20 2d6", 5, "looks like another die roll"},
+		{"d20 r1 ro1", 8, "die-roll option \"ro1\" is out of place"},
+		{"2d6kh1 kl1 fire", 8, "die-roll option \"kl1\" is out of place"},
+		{"d6 fire w6", 9, "die-roll option \"w6\" is out of place"},
+		{"40% | dc 5", 7, "percentile die roll with a DC"},
+		{"d20 | degrees", 7, "without a dc or vs option"},
+		{"d20 | dc 5 | vs d20", 14, "both a DC and an opposed roll"},
+		{"d20 | vs d20 + {1/2}", 16, "permutations are not allowed"},
+		{"d20 | vs d20 * ", 15, "missing value after last operator"},
+		{"d20 | band 9-3 x", 7, "backwards"},
+		{"d20 + 3d6! a6", 12, "both explode and roll again"},
+		{"d20 + 3d6 s3 b4", 15, "less than the success target"},
+		{"", 1, "empty die-roll expression"},
+		{"@Treasure | dc 5", 13, "can't be used with random table rolls"},
+		{"Loot=@ [x]", 8, "invalid random table name"},
+		{"@", 2, "invalid random table name"},
+	} {
+		_, err := Parse(test.spec)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: error %v is not a SyntaxError", test.spec, err)
+			continue
+		}
+		if se.Column() != test.column || !strings.Contains(se.Message, test.msg) {
+			t.Errorf("%q: error at column %d (%s), expected column %d (%s)\n%s", test.spec, se.Column(), se.Message, test.column, test.msg, se.Marker())
+		}
+	}
+
+	_, err := Parse("Über=d20+*5")
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if m := err.(*SyntaxError).Marker(); m != "Über=d20+*5\n         ^" {
+		t.Errorf("marker %q", m)
+	}
+	if err.Error() != "die-roll syntax error at column 10: unexpected operator \"*\" in die-roll expression" {
+		t.Errorf("error %q", err.Error())
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,664 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+///////////////////////////////////////////////////////////////////////////////
+//                                                                           //
+//                              Random Tables                               //
+//                                                                           //
+// Tables of results which are chosen by rolling dice, such as wandering    //
+// monsters or treasure. A die-roll specification of the form "@name"       //
+// rolls on the named table, and the result chosen may itself contain       //
+// die rolls or rolls on other tables.                                      //
+//                                                                           //
+///////////////////////////////////////////////////////////////////////////////
+
+package dice
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MinimumSupportedRandomTableFileFormat = 1
+const MaximumSupportedRandomTableFileFormat = 1
+
+// MaximumTableNesting is the deepest that references to other tables
+// may be nested inside the results of a table before we give up
+// trying to expand them.
+const MaximumTableNesting = 16
+
+// RandomTable describes a table of results, one of which is chosen at random
+// by rolling dice. Tables are stored on the server or in a file so they can
+// be used throughout a campaign.
+//
+// There are two kinds of table. If the entries give ranges of values (with
+// their Low and High fields), then DieRollSpec is rolled and the entry whose
+// range includes the result is chosen. If DieRollSpec is empty, a single die
+// with as many sides as the highest value in any range is rolled. Thus
+// a table with entries for 1-35, 36-80, and 81-100 rolls a d100.
+// The ranges may not overlap or leave gaps between them.
+//
+// Otherwise, the entries are weighted: each entry is chosen in proportion
+// to its Weight (an entry with no Weight has a weight of 1). Weighted tables
+// do not have a DieRollSpec.
+type RandomTable struct {
+	// The name by which this table is identified. This must be unique
+	// among all tables. Die-roll specifications refer to the table
+	// as "@" followed by this name.
+	Name string
+
+	// A text description of what the table is for.
+	Description string `json:",omitempty"`
+
+	// The die roll used to choose an entry from a table of ranged entries.
+	DieRollSpec string `json:",omitempty"`
+
+	// The possible results.
+	Entries []RandomTableEntry
+}
+
+// RandomTableEntry is one of the possible results from a RandomTable.
+//
+// The Result text may include die rolls in square brackets, which are
+// replaced by the result of rolling them, and references to other tables,
+// which are replaced by the result of rolling on them. For example, the
+// result "[2d4] orcs with [@Orc Treasure]" might produce "5 orcs with
+// 12 gold pieces".
+type RandomTableEntry struct {
+	// The range of rolls which produce this result. If High is zero,
+	// the range is just the single value Low.
+	Low  int `json:",omitempty"`
+	High int `json:",omitempty"`
+
+	// The relative weight of this result in a weighted table.
+	Weight int `json:",omitempty"`
+
+	// The text of the result.
+	Result string
+}
+
+var reTableEntry = regexp.MustCompile(`^\s*(\d+)(?:\s*-\s*(\d+))?\s+(\S.*?)\s*$`)
+var reTableResultRoll = regexp.MustCompile(`\[\s*(@?)\s*([^\[\]]*?)\s*\]`)
+
+// ValidTableName returns true if name may be used as the name of a random table.
+// Names may not be empty, begin with "@", begin or end with spaces, or contain
+// any of the characters "[", "]", "|", or "=".
+func ValidTableName(name string) bool {
+	return name != "" && name == strings.TrimSpace(name) && !strings.HasPrefix(name, "@") && !strings.ContainsAny(name, "[]|=")
+}
+
+// ParseRandomTableEntry interprets a line of text such as "01-35 goblins"
+// or "36 hobgoblins" as an entry in a table of ranged entries. As is
+// customary for tables rolled with percentile dice, a range bound written
+// as "00" means 100, so "96-00 dragon" includes the rolls 96 through 100.
+func ParseRandomTableEntry(text string) (RandomTableEntry, error) {
+	var entry RandomTableEntry
+	var err error
+
+	fields := reTableEntry.FindStringSubmatch(text)
+	if fields == nil {
+		return entry, fmt.Errorf("random table entry \"%s\" is not in the form \"<low>[-<high>] <result>\"", text)
+	}
+	bound := func(s string) (int, error) {
+		if s == "00" {
+			return 100, nil
+		}
+		return strconv.Atoi(s)
+	}
+	if entry.Low, err = bound(fields[1]); err != nil {
+		return entry, fmt.Errorf("value error in random table entry \"%s\": %v", text, err)
+	}
+	if fields[2] != "" {
+		if entry.High, err = bound(fields[2]); err != nil {
+			return entry, fmt.Errorf("value error in random table entry \"%s\": %v", text, err)
+		}
+		if entry.High < entry.Low {
+			return entry, fmt.Errorf("random table entry \"%s\" has its range backwards", text)
+		}
+	}
+	entry.Result = fields[3]
+	return entry, nil
+}
+
+// isRanged returns true if the table's entries are chosen by range
+// rather than by weight.
+func (t RandomTable) isRanged() bool {
+	for _, entry := range t.Entries {
+		if entry.Low != 0 || entry.High != 0 {
+			return true
+		}
+	}
+	return t.DieRollSpec != ""
+}
+
+// bounds returns the lowest and highest values covered by a ranged entry.
+func (e RandomTableEntry) bounds() (int, int) {
+	if e.High == 0 {
+		return e.Low, e.Low
+	}
+	return e.Low, e.High
+}
+
+// weight returns the weight of an entry in a weighted table.
+func (e RandomTableEntry) weight() int {
+	if e.Weight == 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// Validate checks that the table is well-formed, returning an error
+// describing the first problem found.
+func (t RandomTable) Validate() error {
+	if !ValidTableName(t.Name) {
+		return fmt.Errorf("invalid random table name \"%s\"", t.Name)
+	}
+	if len(t.Entries) == 0 {
+		return fmt.Errorf("random table \"%s\" has no entries", t.Name)
+	}
+	if !t.isRanged() {
+		for i, entry := range t.Entries {
+			if entry.Weight < 0 {
+				return fmt.Errorf("random table \"%s\" entry #%d has a negative weight", t.Name, i+1)
+			}
+		}
+		return nil
+	}
+
+	if t.DieRollSpec != "" {
+		if _, err := New(ByDescription(t.DieRollSpec)); err != nil {
+			return fmt.Errorf("random table \"%s\" has an invalid die roll: %v", t.Name, err)
+		}
+	}
+	for i, entry := range t.Entries {
+		if entry.Weight != 0 {
+			return fmt.Errorf("random table \"%s\" entry #%d has a weight, but the table's entries are chosen by range", t.Name, i+1)
+		}
+		low, high := entry.bounds()
+		if high < low {
+			return fmt.Errorf("random table \"%s\" entry #%d has its range backwards", t.Name, i+1)
+		}
+		for j, other := range t.Entries[:i] {
+			if otherLow, otherHigh := other.bounds(); low <= otherHigh && otherLow <= high {
+				return fmt.Errorf("random table \"%s\" entry #%d overlaps entry #%d", t.Name, i+1, j+1)
+			}
+		}
+	}
+	if t.DieRollSpec == "" {
+		for i, entry := range t.Entries {
+			if low, _ := entry.bounds(); low < 1 {
+				return fmt.Errorf("random table \"%s\" entry #%d can't be rolled without a die-roll spec for the table", t.Name, i+1)
+			}
+		}
+	}
+
+	//
+	// Every roll from the lowest entry to the highest must choose one
+	// of them (and without a die-roll spec, the lowest roll is 1).
+	//
+	ranges := make([][2]int, len(t.Entries))
+	for i, entry := range t.Entries {
+		ranges[i][0], ranges[i][1] = entry.bounds()
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	if t.DieRollSpec == "" && ranges[0][0] > 1 {
+		return fmt.Errorf("random table \"%s\" has no entry for rolls of 1-%d", t.Name, ranges[0][0]-1)
+	}
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i][0] > ranges[i-1][1]+1 {
+			if ranges[i][0] == ranges[i-1][1]+2 {
+				return fmt.Errorf("random table \"%s\" has no entry for a roll of %d", t.Name, ranges[i-1][1]+1)
+			}
+			return fmt.Errorf("random table \"%s\" has no entry for rolls of %d-%d", t.Name, ranges[i-1][1]+1, ranges[i][0]-1)
+		}
+	}
+	return nil
+}
+
+// dieRollSpec returns the die roll which chooses an entry from the table.
+func (t RandomTable) dieRollSpec() string {
+	if t.DieRollSpec != "" {
+		return t.DieRollSpec
+	}
+	sides := 0
+	if t.isRanged() {
+		for _, entry := range t.Entries {
+			_, high := entry.bounds()
+			sides = max(sides, high)
+		}
+	} else {
+		for _, entry := range t.Entries {
+			sides += entry.weight()
+		}
+	}
+	return fmt.Sprintf("1d%d", sides)
+}
+
+// Lookup returns the entry chosen from the table by a die roll of n,
+// and whether there was any such entry.
+func (t RandomTable) Lookup(n int) (RandomTableEntry, bool) {
+	if t.isRanged() {
+		for _, entry := range t.Entries {
+			if low, high := entry.bounds(); low <= n && n <= high {
+				return entry, true
+			}
+		}
+		return RandomTableEntry{}, false
+	}
+
+	if n < 1 {
+		return RandomTableEntry{}, false
+	}
+	for _, entry := range t.Entries {
+		if n <= entry.weight() {
+			return entry, true
+		}
+		n -= entry.weight()
+	}
+	return RandomTableEntry{}, false
+}
+
+// WithTables supplies a set of random tables which die-roll specifications
+// given to a DieRoller may roll on. It only has an effect when passed to
+// NewDieRoller.
+func WithTables(tables []RandomTable) func(*Dice) error {
+	return func(o *Dice) error {
+		t, err := tableMap(tables)
+		if err != nil {
+			return err
+		}
+		o.tables = t
+		return nil
+	}
+}
+
+// SetTables replaces the set of random tables which subsequent die-roll
+// specifications given to d may roll on. Passing nil removes all tables.
+func (d *DieRoller) SetTables(tables []RandomTable) error {
+	t, err := tableMap(tables)
+	if err != nil {
+		return err
+	}
+	d.tables = t
+	return nil
+}
+
+// Tables returns the set of random tables currently available to d,
+// sorted by name.
+func (d *DieRoller) Tables() []RandomTable {
+	var tables []RandomTable
+	for _, t := range d.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	return tables
+}
+
+func tableMap(tables []RandomTable) (map[string]RandomTable, error) {
+	if tables == nil {
+		return nil, nil
+	}
+	t := make(map[string]RandomTable)
+	for _, table := range tables {
+		if err := table.Validate(); err != nil {
+			return nil, err
+		}
+		if _, exists := t[table.Name]; exists {
+			return nil, fmt.Errorf("there is more than one random table called \"%s\"", table.Name)
+		}
+		t[table.Name] = table
+	}
+	return t, nil
+}
+
+// unknownTableError explains why the table roll t in spec is not a roll
+// on a known table. If its name starts with the name of a known table,
+// the rest of it is probably something which can't follow a table roll,
+// so we say so.
+func (d *DieRoller) unknownTableError(spec string, t *TableRoll) error {
+	name := t.Name
+	nameStart := t.End - len(name)
+	known := ""
+	for table := range d.tables {
+		if len(table) > len(known) && strings.HasPrefix(name, table+" ") {
+			known = table
+		}
+	}
+	if known == "" {
+		return &SyntaxError{Spec: spec, Offset: nameStart, Message: fmt.Sprintf("there is no random table called \"%s\"", name)}
+	}
+	rest := strings.TrimSpace(name[len(known):])
+	restStart := t.End - len(rest)
+	if strings.IndexAny(rest, "+-*/×÷<>≤≥(") == 0 {
+		return &SyntaxError{Spec: spec, Offset: restStart, Message: fmt.Sprintf("a roll on random table \"%s\" can't be combined with other die rolls (\"%s\")", known, rest)}
+	}
+	return &SyntaxError{Spec: spec, Offset: restStart, Message: fmt.Sprintf("a roll on random table \"%s\" can't have a label (\"%s\"); give it a title before the \"@\" instead", known, rest)}
+}
+
+// rollOnTable rolls on the table named in the die-roll specification,
+// reporting the die roll, the table, the result chosen, and any other
+// rolls which were made to fill in that result.
+func (d *DieRoller) rollOnTable() (StructuredResult, error) {
+	dice, n, entry, err := d.chooseTableEntry(d.table)
+	if err != nil {
+		return StructuredResult{}, err
+	}
+	details, err := dice.StructuredDescribeRoll()
+	if err != nil {
+		return StructuredResult{}, err
+	}
+	text, rolls, err := d.expandTableResult(entry.Result, []string{d.table})
+	if err != nil {
+		return StructuredResult{}, err
+	}
+	details = append(details,
+		StructuredDescription{Type: "table", Value: d.table},
+		StructuredDescription{Type: "tableresult", Value: text},
+	)
+	return StructuredResult{Result: n, Details: append(details, rolls...)}, nil
+}
+
+// chooseTableEntry rolls the dice for the named table, returning the dice
+// rolled, their result, and the entry chosen by that result.
+func (d *DieRoller) chooseTableEntry(name string) (*Dice, int, RandomTableEntry, error) {
+	t, ok := d.tables[name]
+	if !ok {
+		return nil, 0, RandomTableEntry{}, fmt.Errorf("there is no random table called \"%s\"", name)
+	}
+	dice, err := New(ByDescription(t.dieRollSpec()), withSharedGenerator(d.generator))
+	if err != nil {
+		return nil, 0, RandomTableEntry{}, fmt.Errorf("random table \"%s\": %v", name, err)
+	}
+	n, err := dice.Roll()
+	if err != nil {
+		return nil, 0, RandomTableEntry{}, fmt.Errorf("random table \"%s\": %v", name, err)
+	}
+	entry, ok := t.Lookup(n)
+	if !ok {
+		return nil, 0, RandomTableEntry{}, fmt.Errorf("random table \"%s\" has no entry for a roll of %d", name, n)
+	}
+	return dice, n, entry, nil
+}
+
+// expandTableResult replaces the die rolls and table references in a
+// table entry's result text with the results of rolling them. Along with the
+// new text, it returns a "tableroll" description of each roll it made.
+// The tables being rolled on to produce this text are listed in active.
+func (d *DieRoller) expandTableResult(text string, active []string) (string, []StructuredDescription, error) {
+	var result strings.Builder
+	var rolls []StructuredDescription
+
+	previous := 0
+	for _, match := range reTableResultRoll.FindAllStringSubmatchIndex(text, -1) {
+		result.WriteString(text[previous:match[0]])
+		previous = match[1]
+		expr := text[match[4]:match[5]]
+
+		if match[3] > match[2] {
+			if slices.Contains(active, expr) {
+				return "", nil, fmt.Errorf("random table \"%s\" refers to itself", expr)
+			}
+			if len(active) >= MaximumTableNesting {
+				return "", nil, fmt.Errorf("random tables nested too deeply (via %s)", strings.Join(active, ", "))
+			}
+			_, n, entry, err := d.chooseTableEntry(expr)
+			if err != nil {
+				return "", nil, err
+			}
+			expanded, subRolls, err := d.expandTableResult(entry.Result, append(active, expr))
+			if err != nil {
+				return "", nil, err
+			}
+			rolls = append(rolls, StructuredDescription{Type: "tableroll", Value: fmt.Sprintf("@%s=%d", expr, n)})
+			rolls = append(rolls, subRolls...)
+			result.WriteString(expanded)
+			continue
+		}
+
+		dice, err := New(ByDescription(expr), withSharedGenerator(d.generator))
+		if err != nil {
+			return "", nil, fmt.Errorf("random table \"%s\": %v", active[len(active)-1], err)
+		}
+		n, err := dice.Roll()
+		if err != nil {
+			return "", nil, fmt.Errorf("random table \"%s\": %v", active[len(active)-1], err)
+		}
+		rolls = append(rolls, StructuredDescription{Type: "tableroll", Value: fmt.Sprintf("%s=%d", expr, n)})
+		result.WriteString(strconv.Itoa(n))
+	}
+	result.WriteString(text[previous:])
+	return result.String(), rolls, nil
+}
+
+//  _____ ___ _     _____ ____
+// |  ___|_ _| |   | ____/ ___|
+// | |_   | || |   |  _| \___ \
+// |  _|  | || |___| |___ ___) |
+// |_|   |___|_____|_____|____/
+//
+
+type RandomTableMetaData struct {
+	Timestamp   int64  `json:",omitempty"`
+	DateTime    string `json:",omitempty"`
+	Comment     string `json:",omitempty"`
+	FileVersion uint   `json:"-"`
+}
+
+// WriteRandomTableFile writes a slice of random tables to the named file.
+func WriteRandomTableFile(path string, tables []RandomTable, meta RandomTableMetaData) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Printf("WARNING: WriteRandomTableFile was unable to close the output file: %v\n", err)
+		}
+	}()
+
+	return SaveRandomTableFile(file, tables, meta)
+}
+
+// SaveRandomTableFile writes a slice of random tables to an open stream.
+func SaveRandomTableFile(output io.Writer, tables []RandomTable, meta RandomTableMetaData) error {
+	writer := bufio.NewWriter(output)
+	writer.WriteString("__TABLES__:1\n")
+	if meta.Timestamp == 0 {
+		now := time.Now()
+		meta.Timestamp = now.Unix()
+		meta.DateTime = now.String()
+	}
+	data, err := json.MarshalIndent(meta, "", "    ")
+	if err != nil {
+		return err
+	}
+	writer.WriteString("«__META__» ")
+	writer.WriteString(string(data))
+	writer.WriteString("\n")
+
+	tables = slices.Clone(tables)
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+
+	for _, table := range tables {
+		data, err := json.MarshalIndent(table, "", "    ")
+		if err != nil {
+			return fmt.Errorf("unable to serialize random table \"%s\": %v", table.Name, err)
+		}
+
+		writer.WriteString("«TABLE» ")
+		writer.WriteString(string(data))
+		writer.WriteString("\n")
+	}
+	writer.WriteString("«__EOF__»\n")
+	writer.Flush()
+	return nil
+}
+
+// ReadRandomTableFile reads in and returns a slice of random tables from
+// the named file.
+func ReadRandomTableFile(path string) ([]RandomTable, RandomTableMetaData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, RandomTableMetaData{}, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Printf("WARNING: ReadRandomTableFile was unable to close the file: %v", err)
+		}
+	}()
+	return LoadRandomTableFile(file)
+}
+
+// LoadRandomTableFile reads in and returns a slice of random tables from
+// an open stream. Each table is checked with its Validate method as it is read.
+func LoadRandomTableFile(input io.Reader) ([]RandomTable, RandomTableMetaData, error) {
+	var meta RandomTableMetaData
+	var tables []RandomTable
+	var err error
+	var f []string
+	var v uint64
+
+	if input == nil {
+		return nil, meta, nil
+	}
+
+	startPattern := regexp.MustCompile("^__TABLES__:(\\d+)\\s*$")
+	recordPattern := regexp.MustCompile("^«(TABLE|__META__)»\\s(.+)$")
+	eofPattern := regexp.MustCompile("^«__EOF__»$")
+	scanner := bufio.NewScanner(input)
+
+	if !scanner.Scan() {
+		return nil, meta, nil
+	}
+
+	if f = startPattern.FindStringSubmatch(scanner.Text()); f == nil {
+		return nil, meta, fmt.Errorf("invalid random table file format in initial header")
+	}
+	if v, err = strconv.ParseUint(f[1], 10, 64); err != nil {
+		return nil, meta, fmt.Errorf("invalid random table file format: can't parse version \"%v\": %v", f[1], err)
+	}
+	meta.FileVersion = uint(v)
+	if v < MinimumSupportedRandomTableFileFormat || v > MaximumSupportedRandomTableFileFormat {
+		if MinimumSupportedRandomTableFileFormat == MaximumSupportedRandomTableFileFormat {
+			return nil, meta, fmt.Errorf("cannot read random table file format version %d (only version %d is supported)", v, MinimumSupportedRandomTableFileFormat)
+		}
+		return nil, meta, fmt.Errorf("cannot read random table file format version %d (only versions %d-%d are supported)", v, MinimumSupportedRandomTableFileFormat, MaximumSupportedRandomTableFileFormat)
+	}
+
+	for scanner.Scan() {
+	rescan:
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		if eofPattern.MatchString(scanner.Text()) {
+			return tables, meta, nil
+		}
+		if f = recordPattern.FindStringSubmatch(scanner.Text()); f == nil {
+			return nil, meta, fmt.Errorf("invalid random table file format: unexpected data \"%v\"", scanner.Text())
+		}
+
+		// Start of record type f[1] with start of JSON string f[2]
+		// collect more lines of JSON data...
+		var dataPacket strings.Builder
+		dataPacket.WriteString(f[2])
+
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "«") {
+				var err error
+
+				switch f[1] {
+				case "__META__":
+					err = json.Unmarshal([]byte(dataPacket.String()), &meta)
+
+				case "TABLE":
+					var table RandomTable
+					if err = json.Unmarshal([]byte(dataPacket.String()), &table); err == nil {
+						if err = table.Validate(); err == nil {
+							tables = append(tables, table)
+						}
+					}
+
+				default:
+					return nil, meta, fmt.Errorf("invalid random table file: unexpected record type \"%s\"", f[1])
+				}
+				if err != nil {
+					return nil, meta, fmt.Errorf("invalid random table file: %v", err)
+				}
+				goto rescan
+			}
+			dataPacket.WriteString(scanner.Text())
+		}
+	}
+	return nil, meta, fmt.Errorf("invalid random table file format: unexpected end of file")
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,377 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for random tables
+//
+
+package dice
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testTables = []RandomTable{
+	{
+		Name:        "Wandering Monsters",
+		DieRollSpec: "d100",
+		Entries: []RandomTableEntry{
+			{Low: 1, High: 35, Result: "[2d4] goblins"},
+			{Low: 36, High: 80, Result: "[1d3+1] orcs carrying [@Treasure]"},
+			{Low: 81, High: 100, Result: "nothing"},
+		},
+	},
+	{
+		Name: "Treasure",
+		Entries: []RandomTableEntry{
+			{Weight: 3, Result: "[3d6] copper pieces"},
+			{Result: "a [@Gem]"},
+		},
+	},
+	{
+		Name: "Gem",
+		Entries: []RandomTableEntry{
+			{Low: 1, High: 3, Result: "ruby"},
+			{Low: 4, High: 6, Result: "emerald"},
+		},
+	},
+}
+
+func TestParseRandomTableEntry(t *testing.T) {
+	for i, test := range []struct {
+		text      string
+		low, high int
+		result    string
+		ok        bool
+	}{
+		{"01-35 goblins", 1, 35, "goblins", true},
+		{"36 - 80  orcs and  more orcs ", 36, 80, "orcs and  more orcs", true},
+		{"7 owlbear", 7, 0, "owlbear", true},
+		{"96-00 dragon", 96, 100, "dragon", true},
+		{"35-01 backwards", 0, 0, "", false},
+		{"goblins", 0, 0, "", false},
+		{"12", 0, 0, "", false},
+	} {
+		entry, err := ParseRandomTableEntry(test.text)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("test %d (%s): expected error but got %v", i, test.text, entry)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d (%s): unexpected error %v", i, test.text, err)
+		} else if entry.Low != test.low || entry.High != test.high || entry.Result != test.result {
+			t.Errorf("test %d (%s): got %v", i, test.text, entry)
+		}
+	}
+}
+
+func TestRandomTableValidate(t *testing.T) {
+	for _, table := range testTables {
+		if err := table.Validate(); err != nil {
+			t.Errorf("table %s: unexpected error %v", table.Name, err)
+		}
+	}
+	for i, table := range []RandomTable{
+		{Name: "", Entries: []RandomTableEntry{{Result: "x"}}},
+		{Name: "a|b", Entries: []RandomTableEntry{{Result: "x"}}},
+		{Name: "@a", Entries: []RandomTableEntry{{Result: "x"}}},
+		{Name: "empty"},
+		{Name: "negative", Entries: []RandomTableEntry{{Weight: -1, Result: "x"}}},
+		{Name: "mixed", Entries: []RandomTableEntry{{Low: 1, Result: "x"}, {Weight: 2, Result: "y"}}},
+		{Name: "overlap", Entries: []RandomTableEntry{{Low: 1, High: 4, Result: "x"}, {Low: 4, High: 6, Result: "y"}}},
+		{Name: "backwards", Entries: []RandomTableEntry{{Low: 4, High: 1, Result: "x"}}},
+		{Name: "bad dice", DieRollSpec: "d", Entries: []RandomTableEntry{{Low: 1, Result: "x"}}},
+		{Name: "no die", Entries: []RandomTableEntry{{Low: 0, High: 3, Result: "x"}}},
+		{Name: "gap", Entries: []RandomTableEntry{{Low: 1, High: 3, Result: "x"}, {Low: 5, High: 6, Result: "y"}}},
+		{Name: "gaps", DieRollSpec: "2d6", Entries: []RandomTableEntry{{Low: 9, High: 12, Result: "x"}, {Low: 2, High: 5, Result: "y"}}},
+		{Name: "no 1", Entries: []RandomTableEntry{{Low: 3, High: 6, Result: "x"}}},
+	} {
+		if err := table.Validate(); err == nil {
+			t.Errorf("test %d (%s): expected error", i, table.Name)
+		}
+	}
+
+	for msg, table := range map[string]RandomTable{
+		"no entry for a roll of 4":  {Name: "gap", Entries: []RandomTableEntry{{Low: 1, High: 3, Result: "x"}, {Low: 5, High: 6, Result: "y"}}},
+		"no entry for rolls of 6-8": {Name: "gaps", DieRollSpec: "2d6", Entries: []RandomTableEntry{{Low: 9, High: 12, Result: "x"}, {Low: 2, High: 5, Result: "y"}}},
+		"no entry for rolls of 1-2": {Name: "no 1", Entries: []RandomTableEntry{{Low: 3, High: 6, Result: "x"}}},
+	} {
+		if err := table.Validate(); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("table %s: got error %v, expected %q", table.Name, err, msg)
+		}
+	}
+
+	for _, table := range []RandomTable{
+		{Name: "unordered", Entries: []RandomTableEntry{{Low: 4, High: 6, Result: "x"}, {Low: 1, High: 3, Result: "y"}}},
+		{Name: "2d6", DieRollSpec: "2d6", Entries: []RandomTableEntry{{Low: 8, High: 12, Result: "x"}, {Low: 2, High: 7, Result: "y"}}},
+	} {
+		if err := table.Validate(); err != nil {
+			t.Errorf("table %s: unexpected error %v", table.Name, err)
+		}
+	}
+}
+
+func TestRandomTableLookup(t *testing.T) {
+	for i, test := range []struct {
+		table  int
+		n      int
+		result string
+	}{
+		{0, 1, "[2d4] goblins"},
+		{0, 35, "[2d4] goblins"},
+		{0, 36, "[1d3+1] orcs carrying [@Treasure]"},
+		{0, 100, "nothing"},
+		{0, 0, ""},
+		{0, 101, ""},
+		{1, 1, "[3d6] copper pieces"},
+		{1, 3, "[3d6] copper pieces"},
+		{1, 4, "a [@Gem]"},
+		{1, 5, ""},
+		{1, 0, ""},
+	} {
+		entry, ok := testTables[test.table].Lookup(test.n)
+		if ok != (test.result != "") || entry.Result != test.result {
+			t.Errorf("test %d (%s, %d): got %v, %v", i, testTables[test.table].Name, test.n, entry, ok)
+		}
+	}
+	if spec := testTables[1].dieRollSpec(); spec != "1d4" {
+		t.Errorf("weighted table rolls %s, expected 1d4", spec)
+	}
+	if spec := testTables[2].dieRollSpec(); spec != "1d6" {
+		t.Errorf("ranged table rolls %s, expected 1d6", spec)
+	}
+}
+
+func TestDieRollerTables(t *testing.T) {
+	dr, err := NewDieRoller(WithSeed(12345), WithTables(testTables))
+	if err != nil {
+		t.Fatalf("NewDieRoller: %v", err)
+	}
+
+	reGoblins := regexp.MustCompile(`^(\d+) goblins$`)
+	reOrcs := regexp.MustCompile(`^(\d+) orcs carrying (?:(\d+) copper pieces|a (ruby|emerald))$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		title, results, err := dr.DoRoll("Night 2 = @ Wandering Monsters")
+		if err != nil {
+			t.Fatalf("DoRoll: %v", err)
+		}
+		if title != "Night 2" || len(results) != 1 {
+			t.Fatalf("got title %q and %d results", title, len(results))
+		}
+		found := make(map[string][]string)
+		for _, detail := range results[0].Details {
+			found[detail.Type] = append(found[detail.Type], detail.Value)
+		}
+		if len(found["table"]) != 1 || found["table"][0] != "Wandering Monsters" || len(found["tableresult"]) != 1 {
+			t.Fatalf("table roll gave %v", results[0].Details)
+		}
+		text := found["tableresult"][0]
+		n := results[0].Result
+		switch {
+		case n >= 1 && n <= 35:
+			m := reGoblins.FindStringSubmatch(text)
+			if m == nil || len(found["tableroll"]) != 1 || found["tableroll"][0] != "2d4="+m[1] {
+				t.Fatalf("roll of %d gave %q with rolls %v", n, text, found["tableroll"])
+			}
+			seen["goblins"] = true
+		case n >= 36 && n <= 80:
+			m := reOrcs.FindStringSubmatch(text)
+			if m == nil || len(found["tableroll"]) < 3 || found["tableroll"][0] != "1d3+1="+m[1] || !strings.HasPrefix(found["tableroll"][1], "@Treasure=") {
+				t.Fatalf("roll of %d gave %q with rolls %v", n, text, found["tableroll"])
+			}
+			if m[2] != "" {
+				if found["tableroll"][2] != "3d6="+m[2] {
+					t.Fatalf("roll of %d gave %q with rolls %v", n, text, found["tableroll"])
+				}
+				seen["copper"] = true
+			} else {
+				if !strings.HasPrefix(found["tableroll"][2], "@Gem=") {
+					t.Fatalf("roll of %d gave %q with rolls %v", n, text, found["tableroll"])
+				}
+				seen["gem"] = true
+			}
+		case n >= 81 && n <= 100:
+			if text != "nothing" || len(found["tableroll"]) != 0 {
+				t.Fatalf("roll of %d gave %q with rolls %v", n, text, found["tableroll"])
+			}
+			seen["nothing"] = true
+		default:
+			t.Fatalf("table roll result %d out of range", n)
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("in 200 rolls, only saw results %v", seen)
+	}
+
+	_, r, err := dr.ExplainSecretRoll("@Treasure", "secret")
+	if err != nil {
+		t.Fatalf("ExplainSecretRoll: %v", err)
+	}
+	if !r.ResultSuppressed || len(r.Details) != 2 || r.Details[1].Type != "table" || r.Details[1].Value != "Treasure" {
+		t.Errorf("ExplainSecretRoll gave %v", r)
+	}
+
+	title, results, err := dr.DoRoll("Loot = @Treasure | repeat 3")
+	if err != nil {
+		t.Fatalf("DoRoll with repeat: %v", err)
+	}
+	if title != "Loot" || len(results) != 3 {
+		t.Errorf("@Treasure | repeat 3 gave title %q and %d results", title, len(results))
+	}
+	for _, result := range results {
+		if !slices.Contains(result.Details, StructuredDescription{Type: "table", Value: "Treasure"}) {
+			t.Errorf("@Treasure | repeat 3 gave %v", result.Details)
+		}
+	}
+	_, r, err = dr.ExplainSecretRoll("@Treasure|repeat 2", "secret")
+	if err != nil || len(r.Details) != 4 || r.Details[3].Type != "repeat" || r.Details[3].Value != "2" {
+		t.Errorf("ExplainSecretRoll with repeat gave %v, %v", r, err)
+	}
+
+	for _, test := range []struct {
+		spec   string
+		column int
+		msg    string
+	}{
+		{"@Encounters", 2, "there is no random table called \"Encounters\""},
+		{"@Treasure + 2", 11, "a roll on random table \"Treasure\" can't be combined with other die rolls (\"+ 2\")"},
+		{"@Treasure fire", 11, "a roll on random table \"Treasure\" can't have a label (\"fire\")"},
+		{"@Wandering Monsters ≥ 5", 21, "a roll on random table \"Wandering Monsters\" can't be combined"},
+		{"@Treasure | dc 5", 13, "global modifier option \"dc 5\" can't be used with random table rolls"},
+		{"@Treasure | repeat 2 | c", 24, "global modifier option \"c\" can't be used with random table rolls"},
+	} {
+		_, _, err := dr.DoRoll(test.spec)
+		var se *SyntaxError
+		if !errors.As(err, &se) || !strings.Contains(se.Message, test.msg) || se.Column() != test.column {
+			t.Errorf("die roll \"%s\" gave error %v, expected %q at column %d", test.spec, err, test.msg, test.column)
+		}
+	}
+	if _, _, err := dr.Distribution("@Treasure"); err == nil {
+		t.Errorf("distribution of a table roll should have been rejected")
+	}
+
+	if err := dr.SetTables([]RandomTable{
+		{Name: "A", Entries: []RandomTableEntry{{Result: "[@B]"}}},
+		{Name: "B", Entries: []RandomTableEntry{{Result: "[@A]"}}},
+		{Name: "C", Entries: []RandomTableEntry{{Result: "[@D]"}}},
+		{Name: "D", Entries: []RandomTableEntry{{Result: "[3+]"}}},
+	}); err != nil {
+		t.Fatalf("SetTables: %v", err)
+	}
+	for _, spec := range []string{"@A", "@C", "@Treasure"} {
+		if _, _, err := dr.DoRoll(spec); err == nil {
+			t.Errorf("die roll \"%s\" should have been rejected", spec)
+		}
+	}
+	if err := dr.SetTables([]RandomTable{testTables[2], testTables[2]}); err == nil {
+		t.Errorf("duplicate table names should have been rejected")
+	}
+}
+
+func TestParseTableRoll(t *testing.T) {
+	r, err := Parse("Loot = @Wandering Monsters | repeat 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Roll != nil || r.Chance != nil || r.Table == nil || r.Table.Name != "Wandering Monsters" || r.Table.Start != 7 || r.Table.End != 26 {
+		t.Fatalf("table roll parsed as %#v", r)
+	}
+	if len(r.Modifiers) != 1 || r.Modifiers[0].Kind != RepeatModifier || r.Modifiers[0].Value != 2 {
+		t.Errorf("modifiers %v", r.Modifiers)
+	}
+
+	for _, spec := range []string{"@Treasure", "Loot=@Gem", " = @ Wandering Monsters|repeat 3", "@Treasure fire"} {
+		r, err := Parse(spec)
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		canonical := r.String()
+		again, err := Parse(canonical)
+		if err != nil || again.String() != canonical || again.Table == nil || again.Table.Name != r.Table.Name {
+			t.Errorf("%q: canonical form %q parsed again as %v (%v)", spec, canonical, again, err)
+			continue
+		}
+
+		dr1, _ := NewDieRoller(WithSeed(42), WithTables(testTables))
+		dr2, _ := NewDieRoller(WithSeed(42), WithTables(testTables))
+		title1, results1, err1 := dr1.DoRoll(spec)
+		title2, results2, err2 := dr2.DoRoll(canonical)
+		if (err1 == nil) != (err2 == nil) || title1 != title2 || len(results1) != len(results2) {
+			t.Errorf("%q: rolled %q %v (%v), canonical %q rolled %q %v (%v)", spec, title1, results1, err1, canonical, title2, results2, err2)
+			continue
+		}
+		for i := range results1 {
+			if results1[i].Result != results2[i].Result {
+				t.Errorf("%q: result #%d was %d, canonical %q gave %d", spec, i, results1[i].Result, canonical, results2[i].Result)
+			}
+		}
+	}
+}
+
+func TestRandomTableFile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := SaveRandomTableFile(&buf, testTables, RandomTableMetaData{Comment: "test tables"}); err != nil {
+		t.Fatalf("SaveRandomTableFile: %v", err)
+	}
+	if testTables[0].Name != "Wandering Monsters" || testTables[1].Name != "Treasure" || testTables[2].Name != "Gem" {
+		t.Errorf("SaveRandomTableFile reordered the caller's tables")
+	}
+	if !strings.HasPrefix(buf.String(), "__TABLES__:1\n«__META__» {") || !strings.HasSuffix(buf.String(), "«__EOF__»\n") {
+		t.Errorf("unexpected file contents %q", buf.String())
+	}
+	loaded, meta, err := LoadRandomTableFile(&buf)
+	if err != nil {
+		t.Fatalf("LoadRandomTableFile: %v", err)
+	}
+	if meta.FileVersion != 1 || meta.Comment != "test tables" || meta.Timestamp == 0 {
+		t.Errorf("unexpected metadata %v", meta)
+	}
+	if len(loaded) != 3 || loaded[0].Name != "Gem" || loaded[1].Name != "Treasure" || loaded[2].Name != "Wandering Monsters" {
+		t.Fatalf("loaded tables %v", loaded)
+	}
+	if loaded[2].DieRollSpec != "d100" || len(loaded[2].Entries) != 3 || loaded[2].Entries[1] != testTables[0].Entries[1] {
+		t.Errorf("loaded table %v", loaded[2])
+	}
+	if loaded[1].Entries[0].Weight != 3 || loaded[1].Entries[1].Weight != 0 {
+		t.Errorf("loaded table %v", loaded[1])
+	}
+
+	for i, contents := range []string{
+		"__TABLES__:2\n«__EOF__»\n",
+		"__DICE__:2\n«__EOF__»\n",
+		"__TABLES__:1\n«TABLE» {\"Name\":\"x\"}\n«__EOF__»\n",
+		"__TABLES__:1\n«PRESET» {\"Name\":\"x\"}\n«__EOF__»\n",
+		"__TABLES__:1\n«TABLE» {\"Name\":\"x\",\"Entries\":[{\"Result\":\"y\"}]}\n",
+	} {
+		if tables, _, err := LoadRandomTableFile(strings.NewReader(contents)); err == nil {
+			t.Errorf("test %d: expected error but got %v", i, tables)
+		}
+	}
+
+	tables, _, err := LoadRandomTableFile(strings.NewReader("__TABLES__:1\n«TABLE» {\n  \"Name\": \"x\",\n  \"Entries\": [{\"Result\": \"y\"}]\n}\n«__EOF__»\n"))
+	if err != nil || len(tables) != 1 || tables[0].Name != "x" {
+		t.Errorf("loaded %v, %v", tables, err)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+///////////////////////////////////////////////////////////////////////////////
+//                                                                           //
+//                           Die-Roll Variables                              //
+//                                                                           //
+// Named values which are substituted into die-roll specifications before  //
+// they are interpreted, so that a spec like "d20+$bab+$str" can follow a   //
+// character as they advance without editing every preset which uses it.   //
+//                                                                           //
+///////////////////////////////////////////////////////////////////////////////
+
+package dice
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MaximumVariableNesting is the deepest that variable references
+// may be nested inside the values of other variables before we give
+// up trying to expand them.
+const MaximumVariableNesting = 16
+
+var reVariableName = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+var reVariableReference = regexp.MustCompile(`\$(?:\$|\{([A-Za-z_]\w*)\}|([A-Za-z_]\w*))`)
+
+// ValidVariableName returns true if name may be used as the name
+// of a die-roll variable. Names start with a letter or underscore,
+// followed by any number of letters, digits, or underscores.
+func ValidVariableName(name string) bool {
+	return reVariableName.MatchString(name)
+}
+
+// ExpandVariables returns a copy of the die-roll specification spec with
+// all variable references replaced by their values from vars.
+//
+// A reference is a dollar sign followed by the variable name, as in "$str",
+// or the name may be enclosed in braces if it needs to be followed by something
+// that would otherwise look like part of the name, as in "${bab}2". A literal
+// dollar sign may be written as "$$".
+//
+// Variable values are inserted as text, exactly as written. Thus they may
+// hold any part of a die-roll expression, not just a number. For example,
+// if sneak is "3d6 sneak" then "d6+$sneak" becomes "d6+3d6 sneak".
+// Values may in turn refer to other variables (making them act as macros),
+// up to MaximumVariableNesting levels deep.
+//
+// It is an error to refer to a variable which is not defined in vars, or
+// for a variable's value to refer (directly or indirectly) to itself.
+func ExpandVariables(spec string, vars map[string]string) (string, error) {
+	return expandVariables(spec, vars, nil)
+}
+
+func expandVariables(spec string, vars map[string]string, active []string) (string, error) {
+	if !strings.Contains(spec, "$") {
+		return spec, nil
+	}
+	if len(active) > MaximumVariableNesting {
+		return "", fmt.Errorf("die-roll variables nested too deeply (via $%s)", strings.Join(active, ", $"))
+	}
+
+	var result strings.Builder
+	previous := 0
+	for _, match := range reVariableReference.FindAllStringSubmatchIndex(spec, -1) {
+		result.WriteString(spec[previous:match[0]])
+		previous = match[1]
+
+		var name string
+		switch {
+		case match[2] >= 0:
+			name = spec[match[2]:match[3]]
+		case match[4] >= 0:
+			name = spec[match[4]:match[5]]
+		default:
+			result.WriteString("$")
+			continue
+		}
+
+		value, defined := vars[name]
+		if !defined {
+			return "", fmt.Errorf("die-roll variable $%s is not defined", name)
+		}
+		if slices.Contains(active, name) {
+			return "", fmt.Errorf("die-roll variable $%s refers to itself", name)
+		}
+		expanded, err := expandVariables(value, vars, append(active, name))
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(expanded)
+	}
+	result.WriteString(spec[previous:])
+	return result.String(), nil
+}
+
+// WithVariables supplies a set of named variables to be substituted into
+// each die-roll specification given to a DieRoller. It only has an
+// effect when passed to NewDieRoller.
+//
+// See ExpandVariables for details of how they are substituted.
+func WithVariables(vars map[string]string) func(*Dice) error {
+	return func(o *Dice) error {
+		for name := range vars {
+			if !ValidVariableName(name) {
+				return fmt.Errorf("invalid die-roll variable name \"%s\"", name)
+			}
+		}
+		o.variables = vars
+		return nil
+	}
+}
+
+// SetVariables replaces the set of named variables which will be
+// substituted into subsequent die-roll specifications given to d.
+// Passing nil removes all variables.
+//
+// See ExpandVariables for details of how they are substituted.
+func (d *DieRoller) SetVariables(vars map[string]string) error {
+	for name := range vars {
+		if !ValidVariableName(name) {
+			return fmt.Errorf("invalid die-roll variable name \"%s\"", name)
+		}
+	}
+	d.variables = vars
+	return nil
+}
+
+// Variables returns the set of named variables currently in effect for d.
+func (d *DieRoller) Variables() map[string]string {
+	return d.variables
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,142 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Unit tests for die-roll variable substitution
+//
+
+package dice
+
+import (
+	"testing"
+)
+
+func TestExpandVariables(t *testing.T) {
+	vars := map[string]string{
+		"str":    "4",
+		"bab":    "6",
+		"sneak":  "3d6 sneak",
+		"attack": "d20+$bab+$str",
+		"loop":   "1+$loop",
+		"a":      "$b",
+		"b":      "$a",
+	}
+	for i, test := range []struct {
+		spec     string
+		expected string
+		ok       bool
+	}{
+		{"d20+5", "d20+5", true},
+		{"d20+$str", "d20+4", true},
+		{"d20+$bab+$str | c", "d20+6+4 | c", true},
+		{"d6+$sneak", "d6+3d6 sneak", true},
+		{"${bab}0", "60", true},
+		{"$attack|dc 15", "d20+6+4|dc 15", true},
+		{"cost=$$5", "cost=$5", true},
+		{"d20+$dex", "", false},
+		{"$loop", "", false},
+		{"$a", "", false},
+		{"$str$bab", "46", true},
+	} {
+		result, err := ExpandVariables(test.spec, vars)
+		if test.ok {
+			if err != nil {
+				t.Errorf("test %d (%s): unexpected error %v", i, test.spec, err)
+			} else if result != test.expected {
+				t.Errorf("test %d (%s): expected \"%s\", got \"%s\"", i, test.spec, test.expected, result)
+			}
+		} else if err == nil {
+			t.Errorf("test %d (%s): expected error but got \"%s\"", i, test.spec, result)
+		}
+	}
+}
+
+func TestDieRollerVariables(t *testing.T) {
+	dr, err := NewDieRoller(WithSeed(12345), WithVariables(map[string]string{"str": "4", "bab": "6"}))
+	if err != nil {
+		t.Fatalf("NewDieRoller: %v", err)
+	}
+	_, r, err := dr.DoRoll("d20+$bab+$str | maximized")
+	if err != nil {
+		t.Fatalf("DoRoll: %v", err)
+	}
+	if len(r) != 1 || r[0].Result != 30 {
+		t.Errorf("expected maximized result 30, got %v", r)
+	}
+
+	if err := dr.SetVariables(map[string]string{"str": "10"}); err != nil {
+		t.Fatalf("SetVariables: %v", err)
+	}
+	_, r, err = dr.DoRoll("d20+$str | maximized")
+	if err != nil {
+		t.Fatalf("DoRoll: %v", err)
+	}
+	if len(r) != 1 || r[0].Result != 30 {
+		t.Errorf("expected maximized result 30 after changing variables, got %v", r)
+	}
+	if _, _, err = dr.DoRoll("d20+$bab"); err == nil {
+		t.Errorf("expected error rolling with variable no longer defined")
+	}
+
+	if err := dr.SetVariables(map[string]string{"not valid": "1"}); err == nil {
+		t.Errorf("expected error setting invalid variable name")
+	}
+	if _, err := NewDieRoller(WithVariables(map[string]string{"9lives": "1"})); err == nil {
+		t.Errorf("expected error creating DieRoller with invalid variable name")
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -3,7 +3,6 @@ module github.com/MadScienceZone/go-gma/v5
 go 1.21
 
 //	github.com/mattn/goveralls v0.0.9 // indirect
-require github.com/schwarmco/go-cartesian-product v0.0.0-20180515110546-d5ee747a6dc9
 
 require (
 	github.com/hashicorp/go-version v1.6.0
@@ -12,14 +11,15 @@ require (
 	github.com/newrelic/go-agent/v3 v3.24.0
 	github.com/newrelic/go-agent/v3/integrations/nrsqlite3 v1.2.0
 	golang.org/x/exp v0.0.0-20230801115018-d63ba01acd4b
+	golang.org/x/image v0.18.0
+	golang.org/x/net v0.8.0
 )
 
 require (
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	golang.org/x/net v0.8.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
-	golang.org/x/text v0.8.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f // indirect
 	google.golang.org/grpc v1.54.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
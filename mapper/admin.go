@@ -0,0 +1,265 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// The administrative control channel: requests a local administrator
+// may send to a running server, and the server's replies.
+//
+
+package mapper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Values for the Command field of an AdminRequest.
+const (
+	// List the connected clients.
+	AdminListClients = "clients"
+
+	// Disconnect the client(s) matching the Client field.
+	AdminKick = "kick"
+
+	// Stop relaying chat messages and die rolls from the client(s)
+	// matching the Client field, or allow them again.
+	AdminMute   = "mute"
+	AdminUnmute = "unmute"
+
+	// Re-read the server's initialization and password files.
+	AdminReload = "reload"
+
+	// Report the server's debugging flags, first changing them to
+	// DebugFlags if that is given.
+	AdminDebug = "debug"
+
+	// Report the server's QoS limits, first changing them to QoS if
+	// that is given.
+	AdminQoS = "qos"
+
+	// Send the Text to all clients as a chat message.
+	AdminNotice = "notice"
+
+	// Report the current game state.
+	AdminDumpState = "state"
+
+	// Report the audit record for the die roll whose result was sent
+	// with the chat message ID MessageID, verifying it in the process.
+	AdminDieRoll = "roll"
+)
+
+// An AdminRequest is sent to the server over its administrative
+// control channel. Each request is sent as a single line of JSON text,
+// to which the server replies with an AdminResponse in the same form.
+type AdminRequest struct {
+	// What to do (one of the Admin* constants above).
+	Command string
+
+	// The name of the campaign to act upon, if the server hosts
+	// more than one (empty for the server's main campaign).
+	Campaign string `json:",omitempty"`
+
+	// The client(s) to act upon, by network address or user name.
+	Client string `json:",omitempty"`
+
+	// New debugging flags, as a comma-separated list of names.
+	DebugFlags string `json:",omitempty"`
+
+	// New QoS limits, in the same form as the QOS command in the
+	// server's initialization file.
+	QoS json.RawMessage `json:",omitempty"`
+
+	// The text of a notice to send to the clients.
+	Text string `json:",omitempty"`
+
+	// The message ID of a die-roll result, for AdminDieRoll.
+	MessageID int `json:",omitempty"`
+}
+
+// An AdminResponse is the server's reply to an AdminRequest.
+type AdminResponse struct {
+	// If the request failed, this explains why.
+	Error string `json:",omitempty"`
+
+	// A description of what the server did.
+	Message string `json:",omitempty"`
+
+	// The connected clients, for AdminListClients.
+	Clients []AdminClient `json:",omitempty"`
+
+	// The server's debugging flags, for AdminDebug.
+	DebugFlags string `json:",omitempty"`
+
+	// The server's QoS limits, for AdminQoS.
+	QoS json.RawMessage `json:",omitempty"`
+
+	// The game state, for AdminDumpState. The keys are the same
+	// as those used to save the game state in the server's database.
+	GameState map[string]AdminGameStateEntry `json:",omitempty"`
+
+	// The audit record of a die roll, for AdminDieRoll. If the
+	// record could not be verified, Error explains why.
+	DieRoll *DieRollAudit `json:",omitempty"`
+}
+
+// AdminClient describes a client connected to the server.
+type AdminClient struct {
+	Peer
+
+	// True if the user logged in as the GM.
+	GM bool `json:",omitempty"`
+
+	// The user name from the client's TLS certificate, if any.
+	CertificateUser string `json:",omitempty"`
+
+	// True if the client has been muted by the administrator.
+	Muted bool `json:",omitempty"`
+}
+
+// AdminGameStateEntry is one element of the game state, given as
+// the server message which would recreate it.
+type AdminGameStateEntry struct {
+	// The protocol command name (e.g., "LS-CIRC").
+	Command string
+
+	// The command's parameters.
+	Data json.RawMessage `json:",omitempty"`
+}
+
+// NewAdminGameStateEntry converts a line of protocol text into an
+// AdminGameStateEntry.
+func NewAdminGameStateEntry(line string) (AdminGameStateEntry, error) {
+	command, data, _ := strings.Cut(strings.TrimSpace(line), " ")
+	entry := AdminGameStateEntry{Command: command}
+	if data = strings.TrimSpace(data); data != "" {
+		if !json.Valid([]byte(data)) {
+			return entry, fmt.Errorf("invalid JSON data for %s command", command)
+		}
+		entry.Data = json.RawMessage(data)
+	}
+	return entry, nil
+}
+
+// maximumAdminLineSize is the longest request or response we'll accept
+// on the administrative control channel. (A dump of a large game state
+// can be rather long.)
+const maximumAdminLineSize = 64 * 1024 * 1024
+
+// ServeAdminConnection reads AdminRequests from conn, passing each to
+// handler and writing the AdminResponse it returns back to conn, until
+// conn reaches end of file. It returns nil at end of file, or the error
+// which stopped it otherwise.
+func ServeAdminConnection(conn io.ReadWriter, handler func(AdminRequest) AdminResponse) error {
+	input := bufio.NewScanner(conn)
+	input.Buffer(nil, maximumAdminLineSize)
+	output := json.NewEncoder(conn)
+
+	for input.Scan() {
+		var request AdminRequest
+		var response AdminResponse
+
+		if err := json.Unmarshal(input.Bytes(), &request); err != nil {
+			response.Error = fmt.Sprintf("unable to understand request: %v", err)
+		} else {
+			response = handler(request)
+		}
+		if err := output.Encode(response); err != nil {
+			return err
+		}
+	}
+	return input.Err()
+}
+
+// AdminCommand connects to the administrative control channel of the
+// server listening on the Unix-domain socket at the given path, sends it
+// the request, and returns its response. If the server reports that the
+// request failed, the response is returned along with an error describing
+// the failure.
+func AdminCommand(socket string, request AdminRequest) (AdminResponse, error) {
+	var response AdminResponse
+
+	conn, err := net.DialTimeout("unix", socket, 10*time.Second)
+	if err != nil {
+		return response, err
+	}
+	defer conn.Close()
+
+	if err = json.NewEncoder(conn).Encode(request); err != nil {
+		return response, err
+	}
+	input := bufio.NewScanner(conn)
+	input.Buffer(nil, maximumAdminLineSize)
+	if !input.Scan() {
+		if err = input.Err(); err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return response, err
+	}
+	if err = json.Unmarshal(input.Bytes(), &response); err != nil {
+		return response, err
+	}
+	if response.Error != "" {
+		return response, errors.New(response.Error)
+	}
+	return response, nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,163 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for the administrative control channel
+//
+
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeAdminConnection(t *testing.T) {
+	in := strings.NewReader(`{"Command":"kick","Client":"alice"}` + "\n" + "not json\n")
+	var out bytes.Buffer
+	var seen []AdminRequest
+	err := ServeAdminConnection(struct {
+		io.Reader
+		io.Writer
+	}{in, &out}, func(r AdminRequest) AdminResponse {
+		seen = append(seen, r)
+		return AdminResponse{Message: "kicked " + r.Client}
+	})
+	if err != nil {
+		t.Fatalf("serve error %v", err)
+	}
+	if len(seen) != 1 || seen[0].Command != AdminKick || seen[0].Client != "alice" {
+		t.Errorf("handler saw %v", seen)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 responses, got %q", out.String())
+	}
+	if lines[0] != `{"Message":"kicked alice"}` {
+		t.Errorf("first response %s", lines[0])
+	}
+	var r AdminResponse
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil || !strings.HasPrefix(r.Error, "unable to understand request") {
+		t.Errorf("second response %s (%v)", lines[1], err)
+	}
+}
+
+func TestAdminCommand(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "admin")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen error %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			ServeAdminConnection(conn, func(r AdminRequest) AdminResponse {
+				if r.Command == AdminDebug {
+					return AdminResponse{DebugFlags: r.DebugFlags}
+				}
+				return AdminResponse{Error: "no such command"}
+			})
+			conn.Close()
+		}
+	}()
+
+	r, err := AdminCommand(socket, AdminRequest{Command: AdminDebug, DebugFlags: "auth,db"})
+	if err != nil || r.DebugFlags != "auth,db" {
+		t.Errorf("debug response %v, %v", r, err)
+	}
+	r, err = AdminCommand(socket, AdminRequest{Command: "bogus"})
+	if err == nil || err.Error() != "no such command" || r.Error != "no such command" {
+		t.Errorf("bogus response %v, %v", r, err)
+	}
+}
+
+func TestNewAdminGameStateEntry(t *testing.T) {
+	for i, test := range []struct {
+		line    string
+		command string
+		data    string
+		isErr   bool
+	}{
+		{line: `AV {"Grid":"A0","XView":0.5}`, command: "AV", data: `{"Grid":"A0","XView":0.5}`},
+		{line: "MARCO", command: "MARCO"},
+		{line: `CS {bad`, command: "CS", isErr: true},
+	} {
+		e, err := NewAdminGameStateEntry(test.line)
+		if test.isErr {
+			if err == nil {
+				t.Errorf("test %d: expected error, got %v", i, e)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: error %v", i, err)
+			continue
+		}
+		if e.Command != test.command || string(e.Data) != test.data {
+			t.Errorf("test %d: got %v", i, e)
+		}
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,203 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Audit records for die rolls made by the server, which allow any
+// past roll to be checked independently after the fact.
+//
+
+package mapper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/dice"
+)
+
+// A DieRollAudit records everything needed to reproduce a die roll
+// made by the server on behalf of a client.
+//
+// Each roll is made with a newly-created random number generator whose
+// seed is chosen for that roll alone. The Commitment (a hash of the seed)
+// is sent to the clients along with the results, but the seed itself is
+// kept by the server. Revealing it later allows anyone to confirm both
+// that it is the same seed the server committed to when the roll was made,
+// and that rolling RollSpec with that seed yields exactly the Results
+// which were reported.
+type DieRollAudit struct {
+	// The chat message IDs of the results reported for this roll
+	// (there may be several if the RollSpec called for repeated rolls).
+	MessageIDs []int `json:",omitempty"`
+
+	// When the roll was made.
+	Sent time.Time
+
+	// The user who requested the roll.
+	Requester string
+
+	// The ID the requester gave to their request, if any.
+	RequestID string `json:",omitempty"`
+
+	// The die-roll specification as sent by the requester, and the
+	// die-roll variables which were in effect for them at the time.
+	RollSpec  string
+	Variables map[string]string `json:",omitempty"`
+
+	// The random tables which were available to the roll, if it
+	// rolled on any of them.
+	Tables []dice.RandomTable `json:",omitempty"`
+
+	// The random number generator seed used for this roll, and the
+	// hash of it which was reported with the results.
+	Seed       int64
+	Commitment string
+
+	// Who the results were sent to.
+	Recipients []string `json:",omitempty"`
+	ToAll      bool     `json:",omitempty"`
+	ToGM       bool     `json:",omitempty"`
+
+	// The title and results of the roll as reported to the clients.
+	Title   string `json:",omitempty"`
+	Results []dice.StructuredResult
+}
+
+// DieRollCommitment returns the hash which commits to the use of the given
+// seed for a die roll, as a string of hex digits.
+func DieRollCommitment(seed int64) string {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(seed, 10)))
+	return hex.EncodeToString(sum[:])
+}
+
+// RollAudited rolls the dice as described by spec, substituting the die-roll
+// variables in vars and rolling on the random tables in tables as needed,
+// using a new random seed for just this roll. It returns
+// the DieRollAudit describing the roll; the caller is expected to fill in the
+// details of who requested it and where the results were sent.
+func RollAudited(spec string, vars map[string]string, tables []dice.RandomTable) (DieRollAudit, error) {
+	var seedBytes [8]byte
+
+	if _, err := rand.Read(seedBytes[:]); err != nil {
+		return DieRollAudit{}, fmt.Errorf("unable to choose a random seed: %v", err)
+	}
+	audit := DieRollAudit{
+		Sent:      time.Now(),
+		RollSpec:  spec,
+		Variables: vars,
+		Tables:    tables,
+		Seed:      int64(binary.BigEndian.Uint64(seedBytes[:]) &^ (1 << 63)),
+	}
+	audit.Commitment = DieRollCommitment(audit.Seed)
+
+	var err error
+	if audit.Title, audit.Results, err = audit.roll(); err != nil {
+		return DieRollAudit{}, err
+	}
+
+	// Only keep a copy of the tables if we needed them.
+	audit.Tables = nil
+	for _, result := range audit.Results {
+		for _, detail := range result.Details {
+			if detail.Type == "table" {
+				audit.Tables = tables
+			}
+		}
+	}
+	return audit, nil
+}
+
+// roll makes the die roll described by the audit record.
+func (r DieRollAudit) roll() (string, []dice.StructuredResult, error) {
+	roller, err := dice.NewDieRoller(dice.WithSeed(r.Seed), dice.WithVariables(r.Variables), dice.WithTables(r.Tables))
+	if err != nil {
+		return "", nil, err
+	}
+	return roller.DoRoll(r.RollSpec)
+}
+
+// Verify checks the audit record for consistency by confirming that its Seed
+// matches its Commitment, and then rolling its RollSpec again with that seed
+// to confirm that exactly the same Title and Results are produced.
+// It returns an error describing any discrepancy found.
+func (r DieRollAudit) Verify() error {
+	if DieRollCommitment(r.Seed) != r.Commitment {
+		return fmt.Errorf("seed %d does not match the commitment %s", r.Seed, r.Commitment)
+	}
+	title, results, err := r.roll()
+	if err != nil {
+		return fmt.Errorf("unable to roll \"%s\" again: %v", r.RollSpec, err)
+	}
+	if title != r.Title {
+		return fmt.Errorf("rolling again produced title \"%s\" instead of \"%s\"", title, r.Title)
+	}
+	if len(results) != len(r.Results) {
+		return fmt.Errorf("rolling again produced %d results instead of %d", len(results), len(r.Results))
+	}
+	for i := range results {
+		if !reflect.DeepEqual(results[i], r.Results[i]) {
+			return fmt.Errorf("rolling again produced a different result #%d (%d instead of %d)", i+1, results[i].Result, r.Results[i].Result)
+		}
+	}
+	return nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,167 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for die-roll audit records
+//
+
+package mapper
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/MadScienceZone/go-gma/v5/dice"
+)
+
+func TestDieRollCommitment(t *testing.T) {
+	c := DieRollCommitment(42)
+	if c != "73475cb40a568e8da8a045ced110137e159f890ac4da883b6b17dc651b3a8049" {
+		t.Errorf("commitment for 42 was %s", c)
+	}
+	if DieRollCommitment(43) == c {
+		t.Errorf("commitments for 42 and 43 are the same")
+	}
+}
+
+func TestRollAudited(t *testing.T) {
+	for _, spec := range []string{
+		"d20",
+		"attack=d20+$bonus | dc 15",
+		"3d6 best of 2 | repeat 4",
+		"d{4/6/8} | until 5",
+		"loot=@Treasure",
+	} {
+		audit, err := RollAudited(spec, map[string]string{"bonus": "3"}, auditTables)
+		if err != nil {
+			t.Fatalf("RollAudited(%q): %v", spec, err)
+		}
+		if audit.Seed < 0 || audit.Commitment != DieRollCommitment(audit.Seed) {
+			t.Errorf("%q: bad seed %d or commitment %s", spec, audit.Seed, audit.Commitment)
+		}
+		if len(audit.Results) == 0 {
+			t.Errorf("%q: no results", spec)
+		}
+		if (len(audit.Tables) > 0) != strings.Contains(spec, "@") {
+			t.Errorf("%q: recorded tables %v", spec, audit.Tables)
+		}
+		if err := audit.Verify(); err != nil {
+			t.Errorf("%q: %v", spec, err)
+		}
+
+		// the record must still verify once stored and retrieved
+		data, err := json.Marshal(audit)
+		if err != nil {
+			t.Fatalf("%q: %v", spec, err)
+		}
+		var stored DieRollAudit
+		if err := json.Unmarshal(data, &stored); err != nil {
+			t.Fatalf("%q: %v", spec, err)
+		}
+		if err := stored.Verify(); err != nil {
+			t.Errorf("%q (stored): %v", spec, err)
+		}
+	}
+}
+
+func TestDieRollAuditVerifyTampered(t *testing.T) {
+	audit, err := RollAudited("10d100", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := audit
+	tampered.Seed++
+	if err := tampered.Verify(); err == nil || !strings.Contains(err.Error(), "does not match the commitment") {
+		t.Errorf("changed seed: got error %v", err)
+	}
+
+	tampered = audit
+	tampered.Results = append(tampered.Results[:0:0], audit.Results...)
+	tampered.Results[0].Result++
+	if err := tampered.Verify(); err == nil || !strings.Contains(err.Error(), "different result") {
+		t.Errorf("changed result: got error %v", err)
+	}
+
+	tampered = audit
+	tampered.RollSpec = "10d100+1"
+	if err := tampered.Verify(); err == nil {
+		t.Errorf("changed spec: no error")
+	}
+
+	tampered = audit
+	tampered.Variables = map[string]string{"x y": "1"}
+	if err := tampered.Verify(); err == nil || !strings.Contains(err.Error(), "unable to roll") {
+		t.Errorf("invalid variables: got error %v", err)
+	}
+
+	audit, err = RollAudited("@Treasure", nil, auditTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered = audit
+	tampered.Tables = []dice.RandomTable{{Name: "Treasure", Entries: []dice.RandomTableEntry{{Result: "nothing"}}}}
+	if err := tampered.Verify(); err == nil {
+		t.Errorf("changed table: no error")
+	}
+}
+
+var auditTables = []dice.RandomTable{
+	{Name: "Treasure", Entries: []dice.RandomTableEntry{{Weight: 3, Result: "[3d6] gold pieces"}, {Result: "a [@Gem]"}}},
+	{Name: "Gem", Entries: []dice.RandomTableEntry{{Low: 1, High: 3, Result: "ruby"}, {Low: 4, High: 6, Result: "emerald"}}},
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,261 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Session journals: a timestamped record of the map traffic
+// passing through the server, which may be replayed later.
+//
+
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Values for the Direction field of a JournalEntry.
+const (
+	// The message was received by the server from a client.
+	JournalReceived = "recv"
+
+	// The message was sent by the server to all clients.
+	JournalSent = "sent"
+)
+
+// A JournalEntry is a single message recorded in a session journal.
+// These are stored one per line as JSON objects.
+type JournalEntry struct {
+	// When the message passed through the server.
+	Time time.Time
+
+	// JournalReceived or JournalSent.
+	Direction string
+
+	// For received messages, the name of the user who sent it.
+	User string `json:",omitempty"`
+
+	// The message as a line of protocol text (without the newline).
+	Message string
+}
+
+// Payload interprets the recorded message, returning the MessagePayload
+// it represents.
+func (e JournalEntry) Payload() (MessagePayload, error) {
+	return ParseMessage(e.Message)
+}
+
+// A Journal records the messages passing through the server. It is
+// safe for concurrent use by multiple goroutines.
+type Journal struct {
+	lock   sync.Mutex
+	writer *bufio.Writer
+	closer io.Closer
+}
+
+// NewJournal creates a Journal which writes its entries to w. If w is
+// also an io.Closer, it will be closed when the Journal is closed.
+func NewJournal(w io.Writer) *Journal {
+	j := &Journal{writer: bufio.NewWriter(w)}
+	if c, ok := w.(io.Closer); ok {
+		j.closer = c
+	}
+	return j
+}
+
+// CreateJournal opens the named file for writing as a Journal. If the
+// file already exists, the new entries are added to the end of it.
+func CreateJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJournal(f), nil
+}
+
+// Record adds an entry for the given line of protocol text to the journal.
+// The entry is written out immediately so that the journal survives
+// the server being stopped unexpectedly.
+func (j *Journal) Record(direction, user, message string) error {
+	data, err := json.Marshal(JournalEntry{
+		Time:      time.Now(),
+		Direction: direction,
+		User:      user,
+		Message:   message,
+	})
+	if err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if _, err = j.writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return j.writer.Flush()
+}
+
+// RecordMessage adds an entry to the journal for the message which would
+// be sent as the given command and data values.
+func (j *Journal) RecordMessage(direction, user string, command ServerMessage, data any) error {
+	message, err := FormatMessage(command, data)
+	if err != nil {
+		return err
+	}
+	return j.Record(direction, user, message)
+}
+
+// Close flushes any remaining output and closes the journal.
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	err := j.writer.Flush()
+	if j.closer != nil {
+		if cerr := j.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// maximumJournalLineSize is the longest journal entry we'll accept
+// when reading a journal back in.
+const maximumJournalLineSize = 16 * 1024 * 1024
+
+// A JournalReader reads entries from a journal.
+type JournalReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewJournalReader creates a JournalReader which reads entries from r.
+func NewJournalReader(r io.Reader) *JournalReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maximumJournalLineSize)
+	return &JournalReader{scanner: scanner}
+}
+
+// Next returns the next entry in the journal. At the end of the journal,
+// it returns io.EOF. Blank lines are ignored.
+func (r *JournalReader) Next() (JournalEntry, error) {
+	var entry JournalEntry
+
+	for r.scanner.Scan() {
+		r.line++
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(r.scanner.Bytes(), &entry); err != nil {
+			return entry, fmt.Errorf("journal line %d: %v", r.line, err)
+		}
+		return entry, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return entry, err
+	}
+	return entry, io.EOF
+}
+
+// ReplayJournal reads every entry from the journal and calls handle for each,
+// pacing them according to the times they were recorded.
+//
+// If speed is 1, the entries are replayed in real time; larger values speed up
+// the replay by that factor (e.g., 2 replays twice as fast as the original session).
+// If speed is 0, the entries are replayed as fast as possible. If maxPause
+// is nonzero, no more than that amount of time will be spent waiting between
+// any two entries (to skip over breaks in the game).
+//
+// Replay stops early if handle returns an error or the context is cancelled.
+func ReplayJournal(ctx context.Context, r *JournalReader, speed float64, maxPause time.Duration, handle func(JournalEntry) error) error {
+	var previous time.Time
+
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if speed > 0 && !previous.IsZero() && entry.Time.After(previous) {
+			pause := time.Duration(float64(entry.Time.Sub(previous)) / speed)
+			if maxPause > 0 && pause > maxPause {
+				pause = maxPause
+			}
+			timer := time.NewTimer(pause)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		previous = entry.Time
+
+		if err := handle(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,148 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for session journals
+//
+
+package mapper
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	j := NewJournal(&buf)
+	if err := j.Record(JournalReceived, "alice", `TO {"Text":"hello"}`); err != nil {
+		t.Fatalf("record error %v", err)
+	}
+	if err := j.RecordMessage(JournalSent, "", CombatMode, CombatModeMessagePayload{Enabled: true}); err != nil {
+		t.Fatalf("record error %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("close error %v", err)
+	}
+
+	r := NewJournalReader(strings.NewReader(buf.String() + "\n"))
+	e, err := r.Next()
+	if err != nil {
+		t.Fatalf("read error %v", err)
+	}
+	if e.Direction != JournalReceived || e.User != "alice" || e.Message != `TO {"Text":"hello"}` || e.Time.IsZero() {
+		t.Errorf("first entry %v", e)
+	}
+	if p, err := e.Payload(); err != nil || p.MessageType() != ChatMessage {
+		t.Errorf("first payload %v, %v", p, err)
+	}
+
+	e, err = r.Next()
+	if err != nil {
+		t.Fatalf("read error %v", err)
+	}
+	if e.Direction != JournalSent || e.User != "" {
+		t.Errorf("second entry %v", e)
+	}
+	if p, err := e.Payload(); err != nil || p.MessageType() != CombatMode || !p.(CombatModeMessagePayload).Enabled {
+		t.Errorf("second payload %v, %v", p, err)
+	}
+
+	if _, err = r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	if _, err = NewJournalReader(strings.NewReader("{bad\n")).Next(); err == nil {
+		t.Errorf("bad journal line accepted")
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	start := time.Date(2025, 1, 15, 19, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	for i, offset := range []time.Duration{0, 100 * time.Millisecond, time.Hour} {
+		buf.WriteString(`{"Time":"` + start.Add(offset).Format(time.RFC3339Nano) + `","Direction":"sent","Message":"MARK {\"X\":` + string(rune('1'+i)) + `,\"Y\":0}"}` + "\n")
+	}
+
+	var seen []string
+	began := time.Now()
+	err := ReplayJournal(context.Background(), NewJournalReader(&buf), 2, 100*time.Millisecond, func(e JournalEntry) error {
+		seen = append(seen, e.Message)
+		return nil
+	})
+	elapsed := time.Since(began)
+	if err != nil {
+		t.Fatalf("replay error %v", err)
+	}
+	if len(seen) != 3 || seen[2] != `MARK {"X":3,"Y":0}` {
+		t.Errorf("replayed %q", seen)
+	}
+	// 50ms for the first gap at double speed, then the hour-long gap is capped at 100ms.
+	if elapsed < 150*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("replay took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ReplayJournal(ctx, NewJournalReader(strings.NewReader(`{"Message":"MARCO"}`)), 0, 0, func(JournalEntry) error { return nil }); err == nil {
+		t.Errorf("cancelled replay did not report an error")
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -44,6 +44,7 @@ package mapper
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
@@ -189,6 +190,26 @@ type Connection struct {
 	// trying forever.
 	Retries uint
 
+	// How long to wait before retrying a failed connection (or
+	// reconnecting after losing one). This doubles after each
+	// failed attempt, up to MaxRetryDelay (see WithRetryDelay).
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// Are we signing on again after losing our connection?
+	reconnecting bool
+
+	// The highest chat message ID we have seen from the server. If we
+	// need to reconnect, we ask the server for any messages after this.
+	LastMessageID int
+
+	// If nonzero, methods which wait for the server to reply to a
+	// request give up after this long (see WithRequestTimeout).
+	RequestTimeout time.Duration
+
+	// The requests whose replies we're waiting for.
+	requests *pendingRequests
+
 	// The server's protocol version number.
 	Protocol int
 
@@ -203,6 +224,10 @@ type Connection struct {
 	// function.
 	Endpoint string
 
+	// If non-nil, we connect to the server over TLS using this
+	// configuration (see WithTLS).
+	TLSConfig *tls.Config
+
 	// Characters received from the server.
 	Characters map[string]PlayerToken
 
@@ -242,6 +267,10 @@ type Connection struct {
 	// to the server.
 	Authenticator *auth.Authenticator
 
+	// If not empty, we ask to join this campaign on a server which
+	// hosts more than one (see WithCampaign).
+	Campaign string
+
 	// We will log informational messages here as we work.
 	Logger *log.Logger
 
@@ -350,6 +379,18 @@ func WithAuthenticator(a *auth.Authenticator) ConnectionOption {
 	}
 }
 
+// WithCampaign modifies the behavior of the NewConnection function
+// so that the client asks to join the named campaign when it logs in
+// to a server which hosts several campaigns at once. This requires
+// that the server issue an authentication challenge, so an authenticator
+// must also be provided (see WithAuthenticator).
+func WithCampaign(name string) ConnectionOption {
+	return func(c *Connection) error {
+		c.Campaign = name
+		return nil
+	}
+}
+
 // WithLogger modifies the behavior of the NewConnection function
 // by specifying a custom logger instead of the default one for
 // the Connection to use during its operations.
@@ -379,6 +420,18 @@ func WithTimeout(t time.Duration) ConnectionOption {
 	}
 }
 
+// WithTLS modifies the behavior of the NewConnection function so that
+// the connection to the server is made over TLS using the given configuration,
+// which may be created by NewClientTLSConfig. This protects the session
+// (including chat messages and die rolls) from being observed or tampered
+// with in transit. The server must be configured to accept TLS connections.
+func WithTLS(config *tls.Config) ConnectionOption {
+	return func(c *Connection) error {
+		c.TLSConfig = config
+		return nil
+	}
+}
+
 // WithRetries modifies the behavior of the NewConnection function
 // to indicate how many times the Dial method should try to
 // establish a connection to the server before giving up.
@@ -393,6 +446,22 @@ func WithRetries(n uint) ConnectionOption {
 	}
 }
 
+// WithRetryDelay modifies the behavior of the NewConnection function
+// to set how long the Dial method waits before retrying a failed
+// connection attempt (or reconnecting after the connection is lost).
+// After each failure, the delay is doubled, up to a maximum of max
+// (if max is nonzero).
+//
+// The default is to wait one second at first, increasing to
+// at most one minute.
+func WithRetryDelay(initial, max time.Duration) ConnectionOption {
+	return func(c *Connection) error {
+		c.RetryDelay = initial
+		c.MaxRetryDelay = max
+		return nil
+	}
+}
+
 // StayConnected modifies the behavior of the NewConnection call so that
 // when Dial is called on the new Connection, it will
 // continue to try to re-establish connections to the server
@@ -401,6 +470,16 @@ func WithRetries(n uint) ConnectionOption {
 // get inadvertently dropped, since this will allow the client
 // to automatically reconnect and resume operations.
 //
+// Upon reconnecting, the client signs on again with the same
+// Authenticator, asks the server to send the current game state
+// (as if Sync were called) and any chat messages and die rolls
+// since the last one it saw (as if SyncChat were called, if
+// subscribed to ChatMessage or RollResult messages), then sends
+// a ReconnectedMessagePayload to the channel subscribed to
+// Reconnected messages (if any). Messages sent while the
+// connection was down are held and sent after signing on again.
+// (The WhenReady channel is only signalled the first time.)
+//
 // If enable is false (the default), Dial will return as soon
 // as the server connection is dropped for any reason.
 func StayConnected(enable bool) ConnectionOption {
@@ -432,6 +511,8 @@ func WithDebugging(flags DebugFlags) ConnectionOption {
 //	WithContext(ctx)
 //	WithLogger(l)
 //	WithRetries(n)
+//	WithRequestTimeout(t)
+//	WithRetryDelay(initial, max)
 //	WithSubscription(ch, msgs...)
 //	WithTimeout(t)
 //
@@ -458,8 +539,11 @@ func NewConnection(endpoint string, opts ...ConnectionOption) (Connection, error
 	newCon := Connection{
 		Context:  context.Background(),
 		Endpoint: endpoint,
-		Retries:  1,
-		Logger:   log.Default(),
+		Retries:       1,
+		RetryDelay:    time.Second,
+		MaxRetryDelay: time.Minute,
+		Logger:        log.Default(),
+		requests:      &pendingRequests{waiting: make(map[string]*pendingRequest)},
 	}
 	newCon.Reset()
 	newCon.serverConn.debug = newCon.debug
@@ -490,12 +574,18 @@ func (c *Connection) PartialReset() {
 	if c == nil {
 		return
 	}
+	c.resetSessionData()
+	c.serverConn.sendChan = make(chan string, 16)
+}
+
+// resetSessionData clears out the information the server gives us
+// when we sign on, so we can receive it fresh for a new session.
+func (c *Connection) resetSessionData() {
 	c.signedOn = false
 	c.Characters = make(map[string]PlayerToken)
 	c.Conditions = make(map[string]StatusMarkerDefinition)
 	c.Gauges = make(map[string]*UpdateProgressMessagePayload)
 	c.PackageUpdatesAvailable = make(map[string][]PackageVersion)
-	c.serverConn.sendChan = make(chan string, 16)
 	c.Preamble = nil
 	c.ClientSettings = nil
 }
@@ -620,6 +710,7 @@ const (
 	Accept ServerMessage = iota
 	AddCharacter
 	AddDicePresets
+	AddDiceVariables
 	AddImage
 	AddObjAttributes
 	AdjustView
@@ -634,6 +725,7 @@ const (
 	Comment
 	DefineDicePresets
 	DefineDicePresetDelegates
+	DefineDiceVariables
 	Denied
 	Echo
 	Failed
@@ -659,10 +751,12 @@ const (
 	QueryCoreData
 	QueryCoreIndex
 	QueryDicePresets
+	QueryDiceVariables
 	QueryImage
 	QueryPeers
 	Ready
 	Redirect
+	Redo
 	RemoveObjAttributes
 	RollDice
 	RollResult
@@ -671,10 +765,12 @@ const (
 	TimerAcknowledge
 	TimerRequest
 	Toolbar
+	Undo
 	UpdateClock
 	UpdateCoreData
 	UpdateCoreIndex
 	UpdateDicePresets
+	UpdateDiceVariables
 	UpdateInitiative
 	UpdateObjAttributes
 	UpdatePeerList
@@ -685,6 +781,16 @@ const (
 	World
 	UNKNOWN
 	ERROR
+	Reconnected
+	LoadMap
+	QueryMaps
+	SaveMap
+	UpdateMaps
+	AddRandomTables
+	DefineRandomTables
+	FilterRandomTables
+	QueryRandomTables
+	UpdateRandomTables
 	maximumServerMessage
 )
 
@@ -692,6 +798,7 @@ var ServerMessageByName = map[string]ServerMessage{
 	"Accept":                      Accept,
 	"AddCharacter":                AddCharacter,
 	"AddDicePresets":              AddDicePresets,
+	"AddDiceVariables":            AddDiceVariables,
 	"AddImage":                    AddImage,
 	"AddObjAttributes":            AddObjAttributes,
 	"AdjustView":                  AdjustView,
@@ -706,6 +813,7 @@ var ServerMessageByName = map[string]ServerMessage{
 	"Comment":                     Comment,
 	"DefineDicePresets":           DefineDicePresets,
 	"DefineDicePresetDelegates":   DefineDicePresetDelegates,
+	"DefineDiceVariables":         DefineDiceVariables,
 	"Denied":                      Denied,
 	"Echo":                        Echo,
 	"Failed":                      Failed,
@@ -731,10 +839,12 @@ var ServerMessageByName = map[string]ServerMessage{
 	"QueryCoreData":               QueryCoreData,
 	"QueryCoreIndex":              QueryCoreIndex,
 	"QueryDicePresets":            QueryDicePresets,
+	"QueryDiceVariables":          QueryDiceVariables,
 	"QueryImage":                  QueryImage,
 	"QueryPeers":                  QueryPeers,
 	"Ready":                       Ready,
 	"Redirect":                    Redirect,
+	"Redo":                        Redo,
 	"RemoveObjAttributes":         RemoveObjAttributes,
 	"RollDice":                    RollDice,
 	"RollResult":                  RollResult,
@@ -743,10 +853,12 @@ var ServerMessageByName = map[string]ServerMessage{
 	"TimerAcknowledge":            TimerAcknowledge,
 	"TimerRequest":                TimerRequest,
 	"Toolbar":                     Toolbar,
+	"Undo":                        Undo,
 	"UpdateClock":                 UpdateClock,
 	"UpdateCoreData":              UpdateCoreData,
 	"UpdateCoreIndex":             UpdateCoreIndex,
 	"UpdateDicePresets":           UpdateDicePresets,
+	"UpdateDiceVariables":         UpdateDiceVariables,
 	"UpdateInitiative":            UpdateInitiative,
 	"UpdateObjAttributes":         UpdateObjAttributes,
 	"UpdatePeerList":              UpdatePeerList,
@@ -755,6 +867,16 @@ var ServerMessageByName = map[string]ServerMessage{
 	"UpdateTurn":                  UpdateTurn,
 	"UpdateVersions":              UpdateVersions,
 	"World":                       World,
+	"Reconnected":                 Reconnected,
+	"LoadMap":                     LoadMap,
+	"QueryMaps":                   QueryMaps,
+	"SaveMap":                     SaveMap,
+	"UpdateMaps":                  UpdateMaps,
+	"AddRandomTables":             AddRandomTables,
+	"DefineRandomTables":          DefineRandomTables,
+	"FilterRandomTables":          FilterRandomTables,
+	"QueryRandomTables":           QueryRandomTables,
+	"UpdateRandomTables":          UpdateRandomTables,
 }
 
 // BaseMessagePayload is not a payload type that you should ever
@@ -825,6 +947,17 @@ type UnknownMessagePayload struct {
 	BaseMessagePayload
 }
 
+// ReconnectedMessagePayload is not sent by the server, but is sent
+// to the channel subscribed to Reconnected messages when a connection
+// which has StayConnected enabled signs back on to the server after
+// losing its connection.
+type ReconnectedMessagePayload struct {
+	BaseMessagePayload
+
+	// The chat message ID we asked the server to resume from.
+	LastMessageID int
+}
+
 // ProtocolMessagePayload describes the server's statement of
 // what protocol version it implements.
 type ProtocolMessagePayload struct {
@@ -1029,11 +1162,26 @@ type AuthMessagePayload struct {
 	// Client describes the client program (e.g., "mapper 4.0.1")
 	Client string `json:",omitempty"`
 
+	// Protocol gives the protocol version the client implements.
+	// Clients older than protocol 417 don't send this.
+	Protocol int `json:",omitempty"`
+
 	// Response gives the binary response to the server's challenge
 	Response []byte
 
+	// If the server's personal passwords are derived with each user's
+	// own salt, PersonalResponse gives the response computed that way,
+	// which the server uses instead of Response if the user has a
+	// personal password.
+	PersonalResponse []byte `json:",omitempty"`
+
 	// User gives the username requested by the client
 	User string `json:",omitempty"`
+
+	// Campaign names the campaign the client wishes to join, if the server
+	// hosts more than one. If empty, the client joins the campaign served
+	// at the endpoint it connected to.
+	Campaign string `json:",omitempty"`
 }
 
 //   ____           _          _____ _ _
@@ -1069,12 +1217,29 @@ func (c *Connection) CacheFile(serverID string) error {
 type ChallengeMessagePayload struct {
 	BaseMessagePayload
 	Protocol      int
-	Challenge     []byte    `json:",omitempty"`
-	Iterations    int       `json:",omitempty"`
-	ServerStarted time.Time `json:",omitempty"`
-	ServerActive  time.Time `json:",omitempty"`
-	ServerTime    time.Time `json:",omitempty"`
-	ServerVersion string    `json:",omitempty"`
+	Challenge     []byte                `json:",omitempty"`
+	Iterations    int                   `json:",omitempty"`
+	Salt          []byte                `json:",omitempty"`
+	KeyIterations int                   `json:",omitempty"`
+	PersonalSalts bool                  `json:",omitempty"`
+	Campaigns     []CampaignDescription `json:",omitempty"`
+	ServerStarted time.Time             `json:",omitempty"`
+	ServerActive  time.Time             `json:",omitempty"`
+	ServerTime    time.Time             `json:",omitempty"`
+	ServerVersion string                `json:",omitempty"`
+}
+
+// CampaignDescription describes one of the campaigns a client may
+// choose to join when the server hosts more than one of them.
+type CampaignDescription struct {
+	Name string
+
+	// If the campaign's passwords are stored as salted hashes, the
+	// client needs these to derive its key instead of the Salt and
+	// KeyIterations values given for the server's default campaign.
+	Salt          []byte `json:",omitempty"`
+	KeyIterations int    `json:",omitempty"`
+	PersonalSalts bool   `json:",omitempty"`
 }
 
 //   ____ _           _   __  __
@@ -1713,6 +1878,9 @@ type FilterImagesMessagePayload struct {
 type GrantedMessagePayload struct {
 	BaseMessagePayload
 	User string
+
+	// The campaign the client joined, if it chose one.
+	Campaign string `json:",omitempty"`
 }
 
 //  _                    _ _____
@@ -2206,6 +2374,11 @@ type RollResultMessagePayload struct {
 
 	// The die roll result and details behind where it came from.
 	Result dice.StructuredResult
+
+	// The hash of the random seed used to make this roll, which commits
+	// the server to that seed so the roll can be verified later
+	// (see DieRollAudit).
+	Commitment string `json:",omitempty"`
 }
 
 //  ____  _          ____                     _
@@ -2317,6 +2490,95 @@ type QueryDicePresetsMessagePayload struct {
 	For string `json:",omitempty"`
 }
 
+// DefineDiceVariables replaces any existing die-roll variables you have
+// stored on the server with the new set passed as the vars parameter,
+// which maps each variable name to its value. The server substitutes
+// these into your die-roll requests (e.g., "d20+$bab+$str") when it
+// rolls them.
+func (c *Connection) DefineDiceVariables(vars map[string]string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(DefineDiceVariables, DefineDiceVariablesMessagePayload{
+		Variables: vars,
+	})
+}
+
+// DefineDiceVariablesFor is just like DefineDiceVariables but performs the operation
+// for another user (GM or delegate only).
+func (c *Connection) DefineDiceVariablesFor(user string, vars map[string]string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(DefineDiceVariables, DefineDiceVariablesMessagePayload{
+		For:       user,
+		Variables: vars,
+	})
+}
+
+type DefineDiceVariablesMessagePayload struct {
+	BaseMessagePayload
+	For       string            `json:",omitempty"`
+	Variables map[string]string `json:",omitempty"`
+}
+
+// AddDiceVariables is like DefineDiceVariables except that it adds the variables
+// passed in to the existing set rather than replacing them. Any variable already
+// defined with the same name is changed to the new value. Any variable given
+// an empty value is removed.
+func (c *Connection) AddDiceVariables(vars map[string]string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(AddDiceVariables, AddDiceVariablesMessagePayload{
+		Variables: vars,
+	})
+}
+
+// AddDiceVariablesFor is just like AddDiceVariables but performs the operation
+// for another user (GM or delegate only).
+func (c *Connection) AddDiceVariablesFor(user string, vars map[string]string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(AddDiceVariables, AddDiceVariablesMessagePayload{
+		For:       user,
+		Variables: vars,
+	})
+}
+
+type AddDiceVariablesMessagePayload struct {
+	BaseMessagePayload
+	For       string            `json:",omitempty"`
+	Variables map[string]string `json:",omitempty"`
+}
+
+// QueryDiceVariables requests that the server send you the die-roll
+// variables currently stored for you. It will send you an UpdateDiceVariables
+// message.
+func (c *Connection) QueryDiceVariables() error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(QueryDiceVariables, nil)
+}
+
+// QueryDiceVariablesFor is just like QueryDiceVariables but retrieves the
+// variables for another user (GM or delegate only).
+func (c *Connection) QueryDiceVariablesFor(user string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(QueryDiceVariables, QueryDiceVariablesMessagePayload{
+		For: user,
+	})
+}
+
+type QueryDiceVariablesMessagePayload struct {
+	BaseMessagePayload
+	For string `json:",omitempty"`
+}
+
 // UpdateClockMessagePayload holds the information sent by the server's UpdateClock
 // message. This tells the client to update its clock display to the new value.
 type UpdateClockMessagePayload struct {
@@ -2361,6 +2623,15 @@ type UpdateDicePresetsMessagePayload struct {
 	Delegates   []string `json:",omitempty"`
 }
 
+// UpdateDiceVariablesMessagePayload holds the information sent by the server's UpdateDiceVariables
+// message. This tells the client the complete set of die-roll variables
+// now stored for the user named in For, replacing any previous set.
+type UpdateDiceVariablesMessagePayload struct {
+	BaseMessagePayload
+	For       string            `json:",omitempty"`
+	Variables map[string]string `json:",omitempty"`
+}
+
 // UpdateInitiativeMessagePayload holds the information sent by the server's UpdateInitiative
 // message. This tells the client that the initiative order has been changed. Its current
 // notion of the initiative order should be replaced by the one given here.
@@ -2672,6 +2943,227 @@ type SyncChatMessagePayload struct {
 	Target int `json:",omitempty"`
 }
 
+// Undo asks the server to reverse the last count changes made to the
+// map (loading, clearing, or placing objects and changing their
+// attributes). The server sends all clients the messages needed to
+// put the map back the way it was before those changes. If count is
+// less than 1, a single change is undone. (GM only)
+func (c *Connection) Undo(count int) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(Undo, UndoMessagePayload{
+		Count: count,
+	})
+}
+
+// UndoMessagePayload holds the information sent by a client's Undo
+// request. Count is the number of changes to undo (1 if omitted).
+type UndoMessagePayload struct {
+	BaseMessagePayload
+	Count int `json:",omitempty"`
+}
+
+// Redo asks the server to re-apply the last count changes which were
+// reversed by Undo. The history of undone changes is discarded
+// as soon as another change is made to the map. If count is less
+// than 1, a single change is re-applied. (GM only)
+func (c *Connection) Redo(count int) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(Redo, RedoMessagePayload{
+		Count: count,
+	})
+}
+
+// RedoMessagePayload holds the information sent by a client's Redo
+// request. Count is the number of changes to re-apply (1 if omitted).
+type RedoMessagePayload struct {
+	BaseMessagePayload
+	Count int `json:",omitempty"`
+}
+
+// SaveMap asks the server to save the current map, as it stands in the
+// server's game state, to a map file called name in the server's
+// own map storage area, replacing any saved map of that name. The location
+// and comment strings are recorded in the file's metadata. The server
+// replies with an UpdateMaps message describing the saved map. (GM only)
+func (c *Connection) SaveMap(name, location, comment string) error {
+	return c.SaveMapWithID(name, location, comment, "")
+}
+
+// SaveMapWithID is like SaveMap but also sends an arbitrary ID string
+// which will be returned in the server's reply.
+func (c *Connection) SaveMapWithID(name, location, comment, requestID string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(SaveMap, SaveMapMessagePayload{
+		Name:      name,
+		Location:  location,
+		Comment:   comment,
+		RequestID: requestID,
+	})
+}
+
+// SaveMapMessagePayload holds the information sent by a client's SaveMap request.
+type SaveMapMessagePayload struct {
+	BaseMessagePayload
+	Name      string
+	Location  string `json:",omitempty"`
+	Comment   string `json:",omitempty"`
+	RequestID string `json:",omitempty"`
+}
+
+// QueryMaps asks the server for the list of maps saved in its map storage
+// area. The server replies with an UpdateMaps message. (GM only)
+func (c *Connection) QueryMaps() error {
+	return c.QueryMapsWithID("")
+}
+
+// QueryMapsWithID is like QueryMaps but also sends an arbitrary ID string
+// which will be returned in the server's reply.
+func (c *Connection) QueryMapsWithID(requestID string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(QueryMaps, QueryMapsMessagePayload{
+		RequestID: requestID,
+	})
+}
+
+// QueryMapsMessagePayload holds the information sent by a client's QueryMaps request.
+type QueryMapsMessagePayload struct {
+	BaseMessagePayload
+	RequestID string `json:",omitempty"`
+}
+
+// LoadMap asks the server to load the map called name from its map storage
+// area into the game state, sending its contents to all clients. Unless merge
+// is true, the map is cleared first. The server replies with an UpdateMaps
+// message describing the loaded map. (GM only)
+func (c *Connection) LoadMap(name string, merge bool) error {
+	return c.LoadMapWithID(name, merge, "")
+}
+
+// LoadMapWithID is like LoadMap but also sends an arbitrary ID string
+// which will be returned in the server's reply.
+func (c *Connection) LoadMapWithID(name string, merge bool, requestID string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(LoadMap, LoadMapMessagePayload{
+		Name:      name,
+		Merge:     merge,
+		RequestID: requestID,
+	})
+}
+
+// LoadMapMessagePayload holds the information sent by a client's LoadMap request.
+type LoadMapMessagePayload struct {
+	BaseMessagePayload
+	Name      string
+	Merge     bool   `json:",omitempty"`
+	RequestID string `json:",omitempty"`
+}
+
+// SavedMap describes a map file in the server's map storage area.
+type SavedMap struct {
+	// The name by which the map is saved and loaded.
+	Name string
+	MapMetaData
+}
+
+// UpdateMapsMessagePayload holds the server's reply to a SaveMap, QueryMaps,
+// or LoadMap request, describing the map(s) involved.
+type UpdateMapsMessagePayload struct {
+	BaseMessagePayload
+	Maps      []SavedMap `json:",omitempty"`
+	RequestID string     `json:",omitempty"`
+}
+
+// DefineRandomTables replaces all of the random tables stored on the
+// server with the new set passed as the tables parameter. Anyone may then
+// roll on them by sending a die-roll spec such as "@Wandering Monsters".
+// The server sends an UpdateRandomTables message to all clients with the
+// new set of tables. (GM only)
+func (c *Connection) DefineRandomTables(tables []dice.RandomTable) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(DefineRandomTables, DefineRandomTablesMessagePayload{
+		Tables: tables,
+	})
+}
+
+// DefineRandomTablesMessagePayload holds the information sent by a client's
+// DefineRandomTables request.
+type DefineRandomTablesMessagePayload struct {
+	BaseMessagePayload
+	Tables []dice.RandomTable `json:",omitempty"`
+}
+
+// AddRandomTables is like DefineRandomTables except that it adds the tables
+// passed in to the existing set rather than replacing them. Any table already
+// stored with the same name is replaced by the new one. (GM only)
+func (c *Connection) AddRandomTables(tables []dice.RandomTable) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(AddRandomTables, AddRandomTablesMessagePayload{
+		Tables: tables,
+	})
+}
+
+// AddRandomTablesMessagePayload holds the information sent by a client's
+// AddRandomTables request.
+type AddRandomTablesMessagePayload struct {
+	BaseMessagePayload
+	Tables []dice.RandomTable `json:",omitempty"`
+}
+
+// FilterRandomTables asks the server to remove all of the random tables
+// whose names match the given regular expression. (GM only)
+func (c *Connection) FilterRandomTables(re string) error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(FilterRandomTables, FilterRandomTablesMessagePayload{
+		Filter: re,
+	})
+}
+
+// FilterRandomTablesMessagePayload holds the filter expression
+// the client sends to the server.
+type FilterRandomTablesMessagePayload struct {
+	BaseMessagePayload
+	Filter string `json:",omitempty"`
+}
+
+// QueryRandomTables requests that the server send you the random tables
+// it has stored. It will send you an UpdateRandomTables message.
+func (c *Connection) QueryRandomTables() error {
+	if c == nil {
+		return fmt.Errorf("nil Connection")
+	}
+	return c.serverConn.Send(QueryRandomTables, nil)
+}
+
+// QueryRandomTablesMessagePayload holds the information sent by a client's
+// QueryRandomTables request.
+type QueryRandomTablesMessagePayload struct {
+	BaseMessagePayload
+}
+
+// UpdateRandomTablesMessagePayload holds the information sent by the server's
+// UpdateRandomTables message. This tells the client the complete set of random
+// tables now stored on the server, replacing any previous set.
+type UpdateRandomTablesMessagePayload struct {
+	BaseMessagePayload
+	Tables []dice.RandomTable `json:",omitempty"`
+}
+
 type UpdateVersionsMessagePayload struct {
 	BaseMessagePayload
 	Packages []PackageUpdate `json:",omitempty"`
@@ -2831,22 +3323,95 @@ func (c *Connection) Dial() {
 	defer c.Close()
 
 	c.signedOn = false
+	c.reconnecting = false
+	delay := c.RetryDelay
 	for {
+		var pending []string
+		if c.reconnecting {
+			// don't let anything the client sent while we were
+			// disconnected get ahead of the sign-on sequence.
+			pending = c.serverConn.holdPending()
+			c.resetSessionData()
+		}
 		err = c.tryConnect()
 		if err == nil {
+			// we signed on, so start over with short delays
+			// if we need to reconnect.
+			delay = c.RetryDelay
+			c.serverConn.sendBuf = append(pending, c.serverConn.sendBuf...)
 			// interact will set c.signedOn = true when ready
 			if err = c.interact(); err != nil {
 				c.Logf("mapper interact failure: %v", err)
 			}
 			c.signedOn = false
-		} else if errors.Is(err, ErrRetryConnection) {
-			c.Logf("retrying connection...")
-			continue
+			c.reconnecting = c.StayConnected
+			if c.Context.Err() != nil || !c.StayConnected {
+				break
+			}
+			c.Logf("lost connection to server; reconnecting in %v...", delay)
+		} else {
+			c.serverConn.sendBuf = pending
+			if errors.Is(err, ErrRetryConnection) {
+				c.Logf("retrying connection...")
+				continue
+			}
+			if c.Context.Err() != nil || !c.StayConnected {
+				break
+			}
+			c.Logf("unable to connect to server; trying again in %v...", delay)
 		}
 
-		if c.Context.Err() != nil || !c.StayConnected {
+		// each time we wait, the next wait is longer, until we
+		// manage to sign on again.
+		if !c.pause(delay) {
 			break
 		}
+		if delay *= 2; c.MaxRetryDelay > 0 && delay > c.MaxRetryDelay {
+			delay = c.MaxRetryDelay
+		}
+	}
+}
+
+// pause waits for the given time interval, returning false if
+// our context was cancelled in the meantime.
+func (c *Connection) pause(d time.Duration) bool {
+	if d <= 0 {
+		return c.Context.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-c.Context.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// resynchronize brings us back up to date with the server after
+// we sign on again following the loss of our connection.
+func (c *Connection) resynchronize(lastMessageID int) {
+	if err := c.filterSubscriptions(); err != nil {
+		c.reportError(err)
+	}
+	if err := c.Sync(); err != nil {
+		c.reportError(err)
+	}
+	_, chat := c.Subscriptions[ChatMessage]
+	_, rolls := c.Subscriptions[RollResult]
+	if chat || rolls {
+		if err := c.SyncChat(lastMessageID); err != nil {
+			c.reportError(err)
+		}
+	}
+	c.Logf("reconnected to server; resuming chat after message #%d", lastMessageID)
+	if ch, ok := c.Subscriptions[Reconnected]; ok {
+		ch <- ReconnectedMessagePayload{
+			BaseMessagePayload: BaseMessagePayload{
+				messageType: Reconnected,
+			},
+			LastMessageID: lastMessageID,
+		}
 	}
 }
 
@@ -2861,20 +3426,39 @@ func (c *Connection) tryConnect() error {
 	c.debug(DebugIO, "tryConnect() started")
 	defer c.debug(DebugIO, "tryConnect() ended")
 
+	delay := c.RetryDelay
 	for i = 0; c.Retries == 0 || i < c.Retries; i++ {
-		if c.Timeout == 0 {
+		if i > 0 {
+			if !c.pause(delay) {
+				return fmt.Errorf("mapper: connection aborted by termination of context")
+			}
+			if delay *= 2; c.MaxRetryDelay > 0 && delay > c.MaxRetryDelay {
+				delay = c.MaxRetryDelay
+			}
+		}
+		if c.TLSConfig != nil {
+			dialer := tls.Dialer{
+				NetDialer: &net.Dialer{Timeout: c.Timeout},
+				Config:    c.TLSConfig,
+			}
+			conn, err = dialer.DialContext(c.Context, "tcp", c.Endpoint)
+		} else if c.Timeout == 0 {
 			var dialer net.Dialer
 			conn, err = dialer.DialContext(c.Context, "tcp", c.Endpoint)
 		} else {
 			conn, err = net.DialTimeout("tcp", c.Endpoint, c.Timeout)
 		}
 
-		if err != nil {
-			if c.Retries == 0 {
-				c.Logf("attempting connection (try %d): %v", i+1, err)
-			} else {
-				c.Logf("attempting connection (try %d of %d): %v", i+1, c.Retries, err)
-			}
+		if err == nil {
+			break
+		}
+		if c.Retries == 0 {
+			c.Logf("attempting connection (try %d): %v", i+1, err)
+		} else {
+			c.Logf("attempting connection (try %d of %d): %v", i+1, c.Retries, err)
+		}
+		if c.Context.Err() != nil {
+			break
 		}
 	}
 	if err != nil {
@@ -3006,18 +3590,44 @@ func (c *Connection) login(done chan error) {
 					done <- ErrAuthenticationRequired
 					return
 				}
+				salt, keyIterations, personalSalts := response.Salt, response.KeyIterations, response.PersonalSalts
+				if c.Campaign != "" {
+					found := false
+					for _, campaign := range response.Campaigns {
+						if campaign.Name == c.Campaign {
+							salt, keyIterations, personalSalts = campaign.Salt, campaign.KeyIterations, campaign.PersonalSalts
+							found = true
+							break
+						}
+					}
+					if !found {
+						c.Logf("server does not offer campaign \"%s\"", c.Campaign)
+						done <- fmt.Errorf("server does not host campaign \"%s\"", c.Campaign)
+						return
+					}
+				}
 				c.Log("authenticating to server")
 				c.Authenticator.Reset()
-				authResponse, err := c.Authenticator.AcceptChallengeBytesWithIterations(response.Challenge, response.Iterations)
+				c.Authenticator.PersonalSalts = personalSalts
+				authResponse, err := c.Authenticator.AcceptChallengeBytesWithSalt(response.Challenge, response.Iterations, salt, keyIterations)
+				if err != nil {
+					c.Logf("error accepting server's challenge: %v", err)
+					done <- err
+					return
+				}
+				personalResponse, err := c.Authenticator.PersonalResponse()
 				if err != nil {
 					c.Logf("error accepting server's challenge: %v", err)
 					done <- err
 					return
 				}
 				c.serverConn.Send(Auth, AuthMessagePayload{
-					Response: authResponse,
-					Client:   c.Authenticator.Client,
-					User:     c.Authenticator.Username,
+					Response:         authResponse,
+					PersonalResponse: personalResponse,
+					Client:           c.Authenticator.Client,
+					Protocol:         GMAMapperProtocol,
+					User:             c.Authenticator.Username,
+					Campaign:         c.Campaign,
 				})
 				c.Log("authentication sent, awaiting validation.")
 				if err := c.serverConn.Flush(); err != nil {
@@ -3025,6 +3635,11 @@ func (c *Connection) login(done chan error) {
 				}
 				authPending = true
 			} else {
+				if c.Campaign != "" {
+					c.Logf("server did not issue an authentication challenge, so we can't ask to join campaign \"%s\"", c.Campaign)
+					done <- fmt.Errorf("unable to choose campaign \"%s\" on a server which does not authenticate its clients", c.Campaign)
+					return
+				}
 				c.Logf("using protocol %d.", c.Protocol)
 				c.Log("server sync complete. No authentication requested by server.")
 			}
@@ -3104,7 +3719,11 @@ func (c *Connection) login(done chan error) {
 			return
 
 		case GrantedMessagePayload:
-			c.Logf("access granted for %s", response.User)
+			if response.Campaign != "" {
+				c.Logf("access granted for %s in campaign \"%s\"", response.User, response.Campaign)
+			} else {
+				c.Logf("access granted for %s", response.User)
+			}
 			authPending = false
 			if c.Authenticator != nil {
 				c.Authenticator.Username = response.User
@@ -3215,7 +3834,7 @@ waitForReady:
 			c.ClientSettings.ServerHostname,
 		))
 	}
-	if c.ReadySignal != nil {
+	if c.ReadySignal != nil && !c.reconnecting {
 		c.ReadySignal <- 0
 	}
 }
@@ -3327,6 +3946,7 @@ func (c *Connection) listen(done chan error) {
 			}
 
 		case ChatMessageMessagePayload:
+			c.noteMessageID(cmd.MessageID)
 			if ch, ok := c.Subscriptions[ChatMessage]; ok {
 				ch <- cmd
 			}
@@ -3362,6 +3982,9 @@ func (c *Connection) listen(done chan error) {
 			}
 
 		case FailedMessagePayload:
+			if c.routeReply(cmd.RequestID, cmd) {
+				break
+			}
 			if ch, ok := c.Subscriptions[Failed]; ok {
 				ch <- cmd
 			}
@@ -3444,11 +4067,16 @@ func (c *Connection) listen(done chan error) {
 			}
 
 		case RollResultMessagePayload:
+			c.noteMessageID(cmd.MessageID)
+			c.routeReply(cmd.RequestID, cmd)
 			if ch, ok := c.Subscriptions[RollResult]; ok {
 				ch <- cmd
 			}
 
 		case TimerAcknowledgeMessagePayload:
+			if c.routeReply(cmd.RequestID, cmd) {
+				break
+			}
 			if ch, ok := c.Subscriptions[TimerAcknowledge]; ok {
 				ch <- cmd
 			}
@@ -3473,6 +4101,11 @@ func (c *Connection) listen(done chan error) {
 				ch <- cmd
 			}
 
+		case UpdateDiceVariablesMessagePayload:
+			if ch, ok := c.Subscriptions[UpdateDiceVariables]; ok {
+				ch <- cmd
+			}
+
 		case UpdateInitiativeMessagePayload:
 			if ch, ok := c.Subscriptions[UpdateInitiative]; ok {
 				ch <- cmd
@@ -3489,20 +4122,39 @@ func (c *Connection) listen(done chan error) {
 			}
 
 		case UpdateCoreDataMessagePayload:
+			if c.routeReply(cmd.RequestID, cmd) {
+				break
+			}
 			if ch, ok := c.Subscriptions[UpdateCoreData]; ok {
 				ch <- cmd
 			}
 
 		case UpdateCoreIndexMessagePayload:
+			if c.routeReply(cmd.RequestID, cmd) {
+				break
+			}
 			if ch, ok := c.Subscriptions[UpdateCoreIndex]; ok {
 				ch <- cmd
 			}
 
+		case UpdateMapsMessagePayload:
+			if c.routeReply(cmd.RequestID, cmd) {
+				break
+			}
+			if ch, ok := c.Subscriptions[UpdateMaps]; ok {
+				ch <- cmd
+			}
+
 		case UpdateProgressMessagePayload:
 			if ch, ok := c.Subscriptions[UpdateProgress]; ok {
 				ch <- cmd
 			}
 
+		case UpdateRandomTablesMessagePayload:
+			if ch, ok := c.Subscriptions[UpdateRandomTables]; ok {
+				ch <- cmd
+			}
+
 		case UpdateStatusMarkerMessagePayload:
 			c.receiveDSM(cmd)
 			if ch, ok := c.Subscriptions[UpdateStatusMarker]; ok {
@@ -3524,11 +4176,15 @@ func (c *Connection) listen(done chan error) {
 			c.reportError(fmt.Errorf("server has terminated our session: %s", cmd.Reason))
 			return
 
-		case AcceptMessagePayload, AddDicePresetsMessagePayload, AllowMessagePayload,
+		case AcceptMessagePayload, AddDicePresetsMessagePayload, AddDiceVariablesMessagePayload, AllowMessagePayload,
 			AuthMessagePayload, DefineDicePresetsMessagePayload, DefineDicePresetDelegatesMessagePayload,
+			DefineDiceVariablesMessagePayload,
 			FilterDicePresetsMessagePayload, FilterImagesMessagePayload, PoloMessagePayload,
-			QueryDicePresetsMessagePayload, QueryPeersMessagePayload,
-			RollDiceMessagePayload, SyncMessagePayload, SyncChatMessagePayload:
+			QueryDicePresetsMessagePayload, QueryDiceVariablesMessagePayload, QueryPeersMessagePayload,
+			RedoMessagePayload, RollDiceMessagePayload, SyncMessagePayload, SyncChatMessagePayload,
+			UndoMessagePayload, LoadMapMessagePayload, QueryMapsMessagePayload, SaveMapMessagePayload,
+			AddRandomTablesMessagePayload, DefineRandomTablesMessagePayload, FilterRandomTablesMessagePayload,
+			QueryRandomTablesMessagePayload:
 
 			c.reportError(fmt.Errorf("message type %v should not be sent to a client (ignored)", cmd.MessageType()))
 
@@ -3547,6 +4203,13 @@ func (c *Connection) listen(done chan error) {
 	}
 }
 
+// noteMessageID keeps track of the latest chat message we've seen.
+func (c *Connection) noteMessageID(id int) {
+	if id > c.LastMessageID {
+		c.LastMessageID = id
+	}
+}
+
 // report any sort of error to the client
 func (c *Connection) reportError(e error) {
 	if c == nil {
@@ -3580,10 +4243,18 @@ func (c *Connection) interact() error {
 	c.debug(DebugIO, "interact() started")
 	defer c.debug(DebugIO, "interact() ended")
 
+	lastMessageID := c.LastMessageID
 	listenerDone := make(chan error, 1)
 	go c.listen(listenerDone)
 	c.signedOn = true
 	bufferReadable := make(chan byte, 1)
+	if len(c.serverConn.sendBuf) > 0 {
+		// send what was held while we were disconnected
+		bufferReadable <- 0
+	}
+	if c.reconnecting {
+		go c.resynchronize(lastMessageID)
+	}
 
 	for {
 		//
@@ -3641,16 +4312,21 @@ func (c *Connection) filterSubscriptions() error {
 		//Accept (client)
 		//AddCharacter (forbidden)
 		//AddDicePresets (client)
+		//AddDiceVariables (client)
+		//AddRandomTables (client)
 		//Allow (client)
 		//Auth (client)
 		//Challenge (forbidden)
 		//DefineDicePresets (client)
 		//DefineDicePresetDelegates (client)
+		//DefineDiceVariables (client)
+		//DefineRandomTables (client)
 		//Denied (forbidden)
 		//Failed (mandatory)
 		//FilterCoreData (client)
 		//FilterDicePresets (client)
 		//FilterImages (client)
+		//FilterRandomTables (client)
 		//Granted (forbidden)
 		//Marco (mandatory)
 		//Polo (client)
@@ -3658,12 +4334,19 @@ func (c *Connection) filterSubscriptions() error {
 		//Protocol (forbidden)
 		//QueryCoreData (client)
 		//QueryDicePresets (client)
+		//QueryDiceVariables (client)
 		//QueryPeers (client)
+		//QueryRandomTables (client)
 		//Ready (forbidden)
 		//Redirect (forbidden)
+		//LoadMap (client)
+		//QueryMaps (client)
+		//Redo (client)
 		//RollDice (client)
+		//SaveMap (client)
 		//Sync (client)
 		//SyncChat (client)
+		//Undo (client)
 		//UpdateVersions (forbidden)
 		//World (forbidden)
 
@@ -3729,14 +4412,20 @@ func (c *Connection) filterSubscriptions() error {
 			subList = append(subList, "COREIDX=")
 		case UpdateDicePresets:
 			subList = append(subList, "DD=")
+		case UpdateDiceVariables:
+			subList = append(subList, "DV=")
 		case UpdateInitiative:
 			subList = append(subList, "IL")
+		case UpdateMaps:
+			subList = append(subList, "MAP=")
 		case UpdateObjAttributes:
 			subList = append(subList, "OA")
 		case UpdatePeerList:
 			subList = append(subList, "CONN")
 		case UpdateProgress:
 			subList = append(subList, "PROGRESS")
+		case UpdateRandomTables:
+			subList = append(subList, "DT=")
 		case UpdateStatusMarker:
 			subList = append(subList, "DSM")
 		case UpdateTurn:
@@ -0,0 +1,382 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Comparing and merging map files, matching their objects by ID
+// and comparing them attribute by attribute.
+//
+
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MapDifferenceKind describes how an object differs between two maps.
+type MapDifferenceKind byte
+
+const (
+	// The object appears only in the new map.
+	ObjectAdded MapDifferenceKind = iota
+
+	// The object appears only in the old map.
+	ObjectRemoved
+
+	// The object appears in both maps, with different attributes.
+	ObjectChanged
+)
+
+// MapDifference describes an object which differs between two maps.
+type MapDifference struct {
+	Kind MapDifferenceKind
+
+	// The key which identifies the object (see MapObjectKey).
+	Key string
+
+	// The type of map file record which holds the object (e.g., "CREATURE").
+	// If an object changed type, this is its new type.
+	Type string
+
+	// The object as it appears in each map (nil where it does not appear).
+	Old, New any
+
+	// For changed objects, the attributes which differ, in order by name.
+	Changes []MapAttributeChange
+}
+
+// MapAttributeChange describes an attribute which differs between two versions
+// of an object. The attribute names are those used in the map file (and
+// by the OA message), and the values are as decoded from JSON. A nil value means
+// that the attribute is not set in that version of the object.
+type MapAttributeChange struct {
+	Name     string
+	Old, New any
+}
+
+// MapConflict describes an object which was changed in conflicting ways
+// by the two sides of a three-way merge.
+type MapConflict struct {
+	// The key which identifies the object (see MapObjectKey).
+	Key string
+
+	// The attributes which were changed to different values on each side.
+	// If this is empty, the conflict involves the whole object (e.g., it was
+	// deleted on one side but changed on the other).
+	Attributes []string
+
+	// A description of the conflict.
+	Reason string
+}
+
+// MapObjectKey returns the string which identifies an object from a map file
+// when comparing maps. For map elements and creatures, this is their ID.
+// Image and file definitions are identified by their name, prefixed
+// by "IMG:" or "MAP:" respectively.
+func MapObjectKey(obj any) (string, error) {
+	switch o := obj.(type) {
+	case ImageDefinition:
+		return "IMG:" + o.Name, nil
+	case FileDefinition:
+		return "MAP:" + o.File, nil
+	case MapObject:
+		return o.ObjID(), nil
+	}
+	return "", fmt.Errorf("map objects of type %T are not supported", obj)
+}
+
+// indexMapObjects arranges a list of map objects by key.
+func indexMapObjects(objs []any) (map[string]any, error) {
+	index := make(map[string]any, len(objs))
+	for _, obj := range objs {
+		key, err := MapObjectKey(obj)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := index[key]; dup {
+			return nil, fmt.Errorf("more than one object with ID %s", key)
+		}
+		index[key] = obj
+	}
+	return index, nil
+}
+
+// objectAttributes represents a map object as its attributes and their
+// values, as they would be written to a map file.
+func objectAttributes(obj any) (map[string]any, error) {
+	attrs := make(map[string]any)
+	if obj == nil {
+		return attrs, nil
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// sortedKeys returns the keys of the maps given, without duplicates, in order.
+func sortedKeys[T any](maps ...map[string]T) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// changedAttributes compares two versions of an object, returning the attributes
+// which differ between them.
+func changedAttributes(oldObj, newObj any) ([]MapAttributeChange, error) {
+	oldAttrs, err := objectAttributes(oldObj)
+	if err != nil {
+		return nil, err
+	}
+	newAttrs, err := objectAttributes(newObj)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []MapAttributeChange
+	for _, name := range sortedKeys(oldAttrs, newAttrs) {
+		if !reflect.DeepEqual(oldAttrs[name], newAttrs[name]) {
+			changes = append(changes, MapAttributeChange{Name: name, Old: oldAttrs[name], New: newAttrs[name]})
+		}
+	}
+	return changes, nil
+}
+
+// DiffMaps compares two lists of map objects (as returned by ReadMapFile), matching
+// objects by their keys (see MapObjectKey), and returns the differences between them,
+// in order by key.
+func DiffMaps(oldObjs, newObjs []any) ([]MapDifference, error) {
+	oldIndex, err := indexMapObjects(oldObjs)
+	if err != nil {
+		return nil, fmt.Errorf("old map: %v", err)
+	}
+	newIndex, err := indexMapObjects(newObjs)
+	if err != nil {
+		return nil, fmt.Errorf("new map: %v", err)
+	}
+
+	var diffs []MapDifference
+	for _, key := range sortedKeys(oldIndex, newIndex) {
+		oldObj, inOld := oldIndex[key]
+		newObj, inNew := newIndex[key]
+		switch {
+		case !inOld:
+			recordType, _ := MapFileRecordType(newObj)
+			diffs = append(diffs, MapDifference{Kind: ObjectAdded, Key: key, Type: recordType, New: newObj})
+
+		case !inNew:
+			recordType, _ := MapFileRecordType(oldObj)
+			diffs = append(diffs, MapDifference{Kind: ObjectRemoved, Key: key, Type: recordType, Old: oldObj})
+
+		default:
+			changes, err := changedAttributes(oldObj, newObj)
+			if err != nil {
+				return nil, fmt.Errorf("unable to compare %s: %v", key, err)
+			}
+			oldType, _ := MapFileRecordType(oldObj)
+			recordType, _ := MapFileRecordType(newObj)
+			if oldType != recordType {
+				changes = append([]MapAttributeChange{{Name: "(record type)", Old: oldType, New: recordType}}, changes...)
+			}
+			if len(changes) > 0 {
+				diffs = append(diffs, MapDifference{Kind: ObjectChanged, Key: key, Type: recordType, Old: oldObj, New: newObj, Changes: changes})
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// MergeMaps performs a three-way merge of two lists of map objects (ours and theirs)
+// which were each derived from a common ancestor (base). Changes made on either side
+// are combined, attribute by attribute. Where both sides changed the same attribute
+// of an object to different values, or one side deleted an object which the other
+// changed, the merged map keeps our version and the conflict is reported.
+//
+// The merged list of objects is returned in order by key, along with any conflicts.
+func MergeMaps(base, ours, theirs []any) ([]any, []MapConflict, error) {
+	baseIndex, err := indexMapObjects(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("base map: %v", err)
+	}
+	ourIndex, err := indexMapObjects(ours)
+	if err != nil {
+		return nil, nil, fmt.Errorf("our map: %v", err)
+	}
+	theirIndex, err := indexMapObjects(theirs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("their map: %v", err)
+	}
+
+	var merged []any
+	var conflicts []MapConflict
+	for _, key := range sortedKeys(baseIndex, ourIndex, theirIndex) {
+		baseObj, inBase := baseIndex[key]
+		ourObj, inOurs := ourIndex[key]
+		theirObj, inTheirs := theirIndex[key]
+
+		switch {
+		case !inOurs && !inTheirs:
+			// deleted on both sides
+
+		case !inTheirs:
+			if inBase {
+				if changes, err := changedAttributes(baseObj, ourObj); err != nil {
+					return nil, nil, fmt.Errorf("unable to compare %s: %v", key, err)
+				} else if len(changes) == 0 {
+					// they deleted it and we didn't touch it
+					continue
+				}
+				conflicts = append(conflicts, MapConflict{Key: key, Reason: "deleted in their map but changed in ours"})
+			}
+			merged = append(merged, ourObj)
+
+		case !inOurs:
+			if inBase {
+				if changes, err := changedAttributes(baseObj, theirObj); err != nil {
+					return nil, nil, fmt.Errorf("unable to compare %s: %v", key, err)
+				} else if len(changes) == 0 {
+					// we deleted it and they didn't touch it
+					continue
+				}
+				conflicts = append(conflicts, MapConflict{Key: key, Reason: "deleted in our map but changed in theirs"})
+				continue
+			}
+			merged = append(merged, theirObj)
+
+		default:
+			if reflect.TypeOf(ourObj) != reflect.TypeOf(theirObj) {
+				conflicts = append(conflicts, MapConflict{Key: key, Reason: "changed to a different type of object on each side"})
+				merged = append(merged, ourObj)
+				continue
+			}
+			obj, attrs, err := mergeObject(baseObj, ourObj, theirObj)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to merge %s: %v", key, err)
+			}
+			if len(attrs) > 0 {
+				reason := "changed differently on each side"
+				if !inBase {
+					reason = "added differently on each side"
+				}
+				conflicts = append(conflicts, MapConflict{Key: key, Attributes: attrs, Reason: reason})
+			}
+			merged = append(merged, obj)
+		}
+	}
+	return merged, conflicts, nil
+}
+
+// mergeObject combines the changes made to an object on each side of a merge,
+// returning the merged object and the names of any attributes which were changed
+// differently on each side (in which case our value is used). If the object is
+// not in the base map, baseObj is nil.
+func mergeObject(baseObj, ourObj, theirObj any) (any, []string, error) {
+	baseAttrs, err := objectAttributes(baseObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	ourAttrs, err := objectAttributes(ourObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	theirAttrs, err := objectAttributes(theirObj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var conflicts []string
+	mergedAttrs := make(map[string]any)
+	for _, name := range sortedKeys(baseAttrs, ourAttrs, theirAttrs) {
+		b, o, t := baseAttrs[name], ourAttrs[name], theirAttrs[name]
+		value := o
+		switch {
+		case reflect.DeepEqual(o, t), reflect.DeepEqual(t, b):
+		case reflect.DeepEqual(o, b):
+			value = t
+		default:
+			conflicts = append(conflicts, name)
+		}
+		if value != nil {
+			mergedAttrs[name] = value
+		}
+	}
+
+	data, err := json.Marshal(mergedAttrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj := reflect.New(reflect.TypeOf(ourObj))
+	if err := json.Unmarshal(data, obj.Interface()); err != nil {
+		return nil, nil, err
+	}
+	return obj.Elem().Interface(), conflicts, nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
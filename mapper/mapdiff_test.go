@@ -0,0 +1,165 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for comparing and merging map files
+//
+
+package mapper
+
+import (
+	"testing"
+)
+
+func testCreature(id, name string, x, y float64) CreatureToken {
+	return CreatureToken{BaseMapObject: BaseMapObject{ID: id}, Name: name, Gx: x, Gy: y, Color: "red"}
+}
+
+func testRect(id string, x, y float64, fill string) RectangleElement {
+	var r RectangleElement
+	r.ID = id
+	r.X, r.Y = x, y
+	r.Fill = fill
+	return r
+}
+
+func TestDiffMaps(t *testing.T) {
+	oldMap := []any{
+		testCreature("c1", "Fred", 1, 2),
+		testRect("r1", 0, 0, "blue"),
+		testRect("r2", 5, 5, "green"),
+		ImageDefinition{Name: "floor", Sizes: []ImageInstance{{Zoom: 1, File: "abc"}}},
+	}
+	newMap := []any{
+		testCreature("c1", "Fred", 1, 3),
+		testRect("r1", 0, 0, "blue"),
+		testRect("r3", 1, 1, ""),
+		ImageDefinition{Name: "floor", Sizes: []ImageInstance{{Zoom: 1, File: "abc"}}},
+	}
+	diffs, err := DiffMaps(oldMap, newMap)
+	if err != nil {
+		t.Fatalf("DiffMaps: %v", err)
+	}
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 differences, got %d: %v", len(diffs), diffs)
+	}
+	if d := diffs[0]; d.Key != "c1" || d.Kind != ObjectChanged || d.Type != "CREATURE" || len(d.Changes) != 1 ||
+		d.Changes[0].Name != "Gy" || d.Changes[0].Old != 2.0 || d.Changes[0].New != 3.0 {
+		t.Errorf("unexpected difference for c1: %v", d)
+	}
+	if d := diffs[1]; d.Key != "r2" || d.Kind != ObjectRemoved || d.Type != "RECT" {
+		t.Errorf("unexpected difference for r2: %v", d)
+	}
+	if d := diffs[2]; d.Key != "r3" || d.Kind != ObjectAdded || d.Type != "RECT" {
+		t.Errorf("unexpected difference for r3: %v", d)
+	}
+
+	if _, err := DiffMaps([]any{testRect("r1", 0, 0, ""), testRect("r1", 1, 1, "")}, nil); err == nil {
+		t.Errorf("duplicate IDs were not reported as an error")
+	}
+}
+
+func TestMergeMaps(t *testing.T) {
+	base := []any{
+		testCreature("c1", "Fred", 1, 2),
+		testRect("r1", 0, 0, "blue"),
+		testRect("r2", 5, 5, "green"),
+		testRect("r3", 7, 7, "green"),
+	}
+	ours := []any{
+		testCreature("c1", "Fred", 4, 2), // we moved Fred across
+		testRect("r1", 0, 0, "red"),      // we changed r1's fill
+		testRect("r2", 5, 5, "green"),
+		testRect("r3", 7, 7, "black"), // we changed r3, but they deleted it
+	}
+	theirs := []any{
+		testCreature("c1", "Fred", 1, 6), // they moved Fred down
+		testRect("r1", 0, 0, "white"),    // they changed r1's fill too
+		// they deleted r2 and r3
+		testRect("r4", 9, 9, ""), // and added r4
+	}
+
+	merged, conflicts, err := MergeMaps(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("MergeMaps: %v", err)
+	}
+	if len(merged) != 4 {
+		t.Fatalf("expected 4 objects in merged map, got %d: %v", len(merged), merged)
+	}
+	if c, ok := merged[0].(CreatureToken); !ok || c.ID != "c1" || c.Gx != 4 || c.Gy != 6 || c.Name != "Fred" {
+		t.Errorf("expected both moves of c1 to be merged, got %v", merged[0])
+	}
+	if r, ok := merged[1].(RectangleElement); !ok || r.ID != "r1" || r.Fill != "red" {
+		t.Errorf("expected our version of r1, got %v", merged[1])
+	}
+	if r, ok := merged[2].(RectangleElement); !ok || r.ID != "r3" || r.Fill != "black" {
+		t.Errorf("expected our version of r3, got %v", merged[2])
+	}
+	if r, ok := merged[3].(RectangleElement); !ok || r.ID != "r4" {
+		t.Errorf("expected their new r4, got %v", merged[3])
+	}
+
+	if len(conflicts) != 2 {
+		t.Fatalf("expected 2 conflicts, got %v", conflicts)
+	}
+	if c := conflicts[0]; c.Key != "r1" || len(c.Attributes) != 1 || c.Attributes[0] != "Fill" {
+		t.Errorf("unexpected conflict for r1: %v", c)
+	}
+	if c := conflicts[1]; c.Key != "r3" || len(c.Attributes) != 0 {
+		t.Errorf("unexpected conflict for r3: %v", c)
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
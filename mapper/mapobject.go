@@ -1290,32 +1290,11 @@ func SaveMapFile(output io.Writer, objList []any, meta MapMetaData) error {
 			return fmt.Errorf("unable to serialize map object: %v", err)
 		}
 
-		switch obj.(type) {
-		case ArcElement:
-			writer.WriteString("«ARC» ")
-		case CircleElement:
-			writer.WriteString("«CIRC» ")
-		case LineElement:
-			writer.WriteString("«LINE» ")
-		case PolygonElement:
-			writer.WriteString("«POLY» ")
-		case RectangleElement:
-			writer.WriteString("«RECT» ")
-		case SpellAreaOfEffectElement:
-			writer.WriteString("«SAOE» ")
-		case TextElement:
-			writer.WriteString("«TEXT» ")
-		case TileElement:
-			writer.WriteString("«TILE» ")
-		case ImageDefinition:
-			writer.WriteString("«IMG» ")
-		case FileDefinition:
-			writer.WriteString("«MAP» ")
-		case CreatureToken:
-			writer.WriteString("«CREATURE» ")
-		default:
+		recordType, ok := MapFileRecordType(obj)
+		if !ok {
 			return fmt.Errorf("unable to serialize map object: unsupported type")
 		}
+		writer.WriteString("«" + recordType + "» ")
 		writer.WriteString(string(data))
 		writer.WriteString("\n")
 	}
@@ -1324,6 +1303,39 @@ func SaveMapFile(output io.Writer, objList []any, meta MapMetaData) error {
 	return nil
 }
 
+//
+// MapFileRecordType returns the name of the type of record used to store
+// the given object in a map file (e.g., "ARC" for an ArcElement).
+// If the object can't be stored in a map file, it returns false.
+//
+func MapFileRecordType(obj any) (string, bool) {
+	switch obj.(type) {
+	case ArcElement:
+		return "ARC", true
+	case CircleElement:
+		return "CIRC", true
+	case LineElement:
+		return "LINE", true
+	case PolygonElement:
+		return "POLY", true
+	case RectangleElement:
+		return "RECT", true
+	case SpellAreaOfEffectElement:
+		return "SAOE", true
+	case TextElement:
+		return "TEXT", true
+	case TileElement:
+		return "TILE", true
+	case ImageDefinition:
+		return "IMG", true
+	case FileDefinition:
+		return "MAP", true
+	case CreatureToken:
+		return "CREATURE", true
+	}
+	return "", false
+}
+
 //
 // ReadMapFile loads GMA mapper data from the named file, returning the data as three values: a slice of
 // MapObject values (which the caller will want to interpret based on their actual data type), the file
@@ -50,12 +50,18 @@ import (
 // The GMA Mapper Protocol version number current as of this build,
 // and protocol versions supported by this code.
 const (
-	GMAMapperProtocol=416      // @@##@@ auto-configured
+	GMAMapperProtocol=417      // @@##@@ auto-configured
 	GoVersionNumber="5.26.0" // @@##@@ auto-configured
 	MinimumSupportedMapProtocol = 400
-	MaximumSupportedMapProtocol = 416
+	MaximumSupportedMapProtocol = 417
 )
 
+// MinimumSaltedAuthProtocol is the oldest protocol a client may implement
+// and still sign on to a server whose passwords are stored as salted keys,
+// since older clients don't know to derive their keys before answering
+// the server's challenge. Such clients are denied access.
+const MinimumSaltedAuthProtocol = 417
+
 func init() {
 	if MinimumSupportedMapProtocol > GMAMapperProtocol || MaximumSupportedMapProtocol < GMAMapperProtocol {
 		if MinimumSupportedMapProtocol == MaximumSupportedMapProtocol {
@@ -78,6 +84,7 @@ type MapConnection struct {
 	sendChan chan string    // outgoing packets go through this channel
 	debug    func(DebugFlags, string)
 	debugf   func(DebugFlags, string, ...any)
+	sent     func(ServerMessage) // called for each message sent, if not nil
 }
 
 func (m *MapConnection) IsReady() bool {
@@ -112,318 +119,413 @@ func (c *MapConnection) Send(command ServerMessage, data any) error {
 		return fmt.Errorf("nil MapConnection")
 	}
 
+	commandWord, jsonData, err := encodeMessage(command, data)
+	if err != nil {
+		return err
+	}
+	if err = c.sendln(commandWord, jsonData); err != nil {
+		return err
+	}
+	if c.sent != nil {
+		c.sent(command)
+	}
+	return nil
+}
+
+// FormatMessage renders a message as the single line of protocol text
+// (without the trailing newline) which Send would transmit to the peer
+// for the same command and data values. This is useful for recording
+// messages somewhere other than a network connection, such that they
+// may be read back in later via ParseMessage.
+func FormatMessage(command ServerMessage, data any) (string, error) {
+	commandWord, jsonData, err := encodeMessage(command, data)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(jsonData, "\n\r") {
+		return "", fmt.Errorf("protocol error: outgoing data packet may not contain newlines")
+	}
+	if jsonData == "" {
+		return commandWord, nil
+	}
+	return commandWord + " " + jsonData, nil
+}
+
+// encodeMessage determines the protocol command word and JSON-encoded
+// parameters for a message.
+func encodeMessage(command ServerMessage, data any) (string, string, error) {
 	switch command {
 	case Accept:
 		if msgs, ok := data.(AcceptMessagePayload); ok {
-			return c.sendJSON("ACCEPT", msgs)
+			return encodeJSON("ACCEPT", msgs)
 		}
 	case AddCharacter:
 		if ac, ok := data.(AddCharacterMessagePayload); ok {
-			return c.sendJSON("AC", ac)
+			return encodeJSON("AC", ac)
 		}
 	case AddDicePresets:
 		if ad, ok := data.(AddDicePresetsMessagePayload); ok {
-			return c.sendJSON("DD+", ad)
+			return encodeJSON("DD+", ad)
+		}
+	case AddDiceVariables:
+		if av, ok := data.(AddDiceVariablesMessagePayload); ok {
+			return encodeJSON("DV+", av)
 		}
 	case AddImage:
 		if ai, ok := data.(ImageDefinition); ok {
-			return c.sendJSON("AI", ai)
+			return encodeJSON("AI", ai)
 		}
 		if ai, ok := data.(AddImageMessagePayload); ok {
-			return c.sendJSON("AI", ai)
+			return encodeJSON("AI", ai)
 		}
 	case AddObjAttributes:
 		if oa, ok := data.(AddObjAttributesMessagePayload); ok {
-			return c.sendJSON("OA+", oa)
+			return encodeJSON("OA+", oa)
+		}
+	case AddRandomTables:
+		if at, ok := data.(AddRandomTablesMessagePayload); ok {
+			return encodeJSON("DT+", at)
 		}
 	case AdjustView:
 		if av, ok := data.(AdjustViewMessagePayload); ok {
-			return c.sendJSON("AV", av)
+			return encodeJSON("AV", av)
 		}
 	case Allow:
 		if al, ok := data.(AllowMessagePayload); ok {
-			return c.sendJSON("ALLOW", al)
+			return encodeJSON("ALLOW", al)
 		}
 	case Auth:
 		if au, ok := data.(AuthMessagePayload); ok {
-			return c.sendJSON("AUTH", au)
+			return encodeJSON("AUTH", au)
 		}
 	case Challenge:
 		if ch, ok := data.(ChallengeMessagePayload); ok {
-			return c.sendJSON("OK", ch)
+			return encodeJSON("OK", ch)
 		}
 	case ChatMessage:
 		if ch, ok := data.(ChatMessageMessagePayload); ok {
-			return c.sendJSON("TO", ch)
+			return encodeJSON("TO", ch)
 		}
 	case Clear:
 		if cl, ok := data.(ClearMessagePayload); ok {
-			return c.sendJSON("CLR", cl)
+			return encodeJSON("CLR", cl)
 		}
 	case ClearChat:
 		if cc, ok := data.(ClearChatMessagePayload); ok {
-			return c.sendJSON("CC", cc)
+			return encodeJSON("CC", cc)
 		}
 	case ClearFrom:
 		if cf, ok := data.(ClearFromMessagePayload); ok {
-			return c.sendJSON("CLR@", cf)
+			return encodeJSON("CLR@", cf)
 		}
 	case CombatMode:
 		if cm, ok := data.(CombatModeMessagePayload); ok {
-			return c.sendJSON("CO", cm)
+			return encodeJSON("CO", cm)
 		}
 	case Comment:
 		if data == nil {
-			return c.sendln("//", "")
+			return "//", "", nil
 		}
 		if s, ok := data.(string); ok {
-			return c.sendln("//", s)
+			return "//", s, nil
 		}
 	case DefineDicePresets:
 		if dd, ok := data.(DefineDicePresetsMessagePayload); ok {
-			return c.sendJSON("DD", dd)
+			return encodeJSON("DD", dd)
 		}
 	case DefineDicePresetDelegates:
 		if dd, ok := data.(DefineDicePresetDelegatesMessagePayload); ok {
-			return c.sendJSON("DDD", dd)
+			return encodeJSON("DDD", dd)
+		}
+	case DefineDiceVariables:
+		if dv, ok := data.(DefineDiceVariablesMessagePayload); ok {
+			return encodeJSON("DV", dv)
+		}
+	case DefineRandomTables:
+		if dt, ok := data.(DefineRandomTablesMessagePayload); ok {
+			return encodeJSON("DT", dt)
 		}
 	case Denied:
 		if reason, ok := data.(DeniedMessagePayload); ok {
-			return c.sendJSON("DENIED", reason)
+			return encodeJSON("DENIED", reason)
 		}
 	case Echo:
 		if e, ok := data.(EchoMessagePayload); ok {
-			return c.sendJSON("ECHO", e)
+			return encodeJSON("ECHO", e)
 		}
 	case Failed:
 		if fa, ok := data.(FailedMessagePayload); ok {
-			return c.sendJSON("FAILED", fa)
+			return encodeJSON("FAILED", fa)
 		}
 	case FilterCoreData:
 		if fi, ok := data.(FilterCoreDataMessagePayload); ok {
-			return c.sendJSON("CORE/", fi)
+			return encodeJSON("CORE/", fi)
 		}
 	case FilterDicePresets:
 		if fi, ok := data.(FilterDicePresetsMessagePayload); ok {
-			return c.sendJSON("DD/", fi)
+			return encodeJSON("DD/", fi)
 		}
 	case FilterImages:
 		if fi, ok := data.(FilterImagesMessagePayload); ok {
-			return c.sendJSON("AI/", fi)
+			return encodeJSON("AI/", fi)
+		}
+	case FilterRandomTables:
+		if fi, ok := data.(FilterRandomTablesMessagePayload); ok {
+			return encodeJSON("DT/", fi)
 		}
 	case Granted:
 		if reason, ok := data.(GrantedMessagePayload); ok {
-			return c.sendJSON("GRANTED", reason)
+			return encodeJSON("GRANTED", reason)
 		}
 	case LoadFrom:
 		if lf, ok := data.(LoadFromMessagePayload); ok {
-			return c.sendJSON("L", lf)
+			return encodeJSON("L", lf)
+		}
+	case LoadMap:
+		if lm, ok := data.(LoadMapMessagePayload); ok {
+			return encodeJSON("MAP-LOAD", lm)
 		}
 	case LoadArcObject:
 		if ob, ok := data.(ArcElement); ok {
-			return c.sendJSON("LS-ARC", ob)
+			return encodeJSON("LS-ARC", ob)
 		}
 		if ob, ok := data.(LoadArcObjectMessagePayload); ok {
-			return c.sendJSON("LS-ARC", ob)
+			return encodeJSON("LS-ARC", ob)
 		}
 	case LoadCircleObject:
 		if ob, ok := data.(CircleElement); ok {
-			return c.sendJSON("LS-CIRC", ob)
+			return encodeJSON("LS-CIRC", ob)
 		}
 		if ob, ok := data.(LoadCircleObjectMessagePayload); ok {
-			return c.sendJSON("LS-CIRC", ob)
+			return encodeJSON("LS-CIRC", ob)
 		}
 	case LoadLineObject:
 		if ob, ok := data.(LineElement); ok {
-			return c.sendJSON("LS-LINE", ob)
+			return encodeJSON("LS-LINE", ob)
 		}
 		if ob, ok := data.(LoadLineObjectMessagePayload); ok {
-			return c.sendJSON("LS-LINE", ob)
+			return encodeJSON("LS-LINE", ob)
 		}
 	case LoadPolygonObject:
 		if ob, ok := data.(PolygonElement); ok {
-			return c.sendJSON("LS-POLY", ob)
+			return encodeJSON("LS-POLY", ob)
 		}
 		if ob, ok := data.(LoadPolygonObjectMessagePayload); ok {
-			return c.sendJSON("LS-POLY", ob)
+			return encodeJSON("LS-POLY", ob)
 		}
 	case LoadRectangleObject:
 		if ob, ok := data.(RectangleElement); ok {
-			return c.sendJSON("LS-RECT", ob)
+			return encodeJSON("LS-RECT", ob)
 		}
 		if ob, ok := data.(LoadRectangleObjectMessagePayload); ok {
-			return c.sendJSON("LS-RECT", ob)
+			return encodeJSON("LS-RECT", ob)
 		}
 	case LoadSpellAreaOfEffectObject:
 		if ob, ok := data.(SpellAreaOfEffectElement); ok {
-			return c.sendJSON("LS-SAOE", ob)
+			return encodeJSON("LS-SAOE", ob)
 		}
 		if ob, ok := data.(LoadSpellAreaOfEffectObjectMessagePayload); ok {
-			return c.sendJSON("LS-SAOE", ob)
+			return encodeJSON("LS-SAOE", ob)
 		}
 	case LoadTextObject:
 		if ob, ok := data.(TextElement); ok {
-			return c.sendJSON("LS-TEXT", ob)
+			return encodeJSON("LS-TEXT", ob)
 		}
 		if ob, ok := data.(LoadTextObjectMessagePayload); ok {
-			return c.sendJSON("LS-TEXT", ob)
+			return encodeJSON("LS-TEXT", ob)
 		}
 	case LoadTileObject:
 		if ob, ok := data.(TileElement); ok {
-			return c.sendJSON("LS-TEXT", ob)
+			return encodeJSON("LS-TEXT", ob)
 		}
 		if ob, ok := data.(LoadTileObjectMessagePayload); ok {
-			return c.sendJSON("LS-TILE", ob)
+			return encodeJSON("LS-TILE", ob)
 		}
 	case Marco:
-		return c.sendln("MARCO", "")
+		return "MARCO", "", nil
 	case Mark:
 		if mk, ok := data.(MarkMessagePayload); ok {
-			return c.sendJSON("MARK", mk)
+			return encodeJSON("MARK", mk)
 		}
 	case PlaceSomeone:
 		if ps, ok := data.(MonsterToken); ok {
-			return c.sendJSON("PS", ps)
+			return encodeJSON("PS", ps)
 		}
 		if ps, ok := data.(PlayerToken); ok {
-			return c.sendJSON("PS", ps)
+			return encodeJSON("PS", ps)
 		}
 		if ps, ok := data.(CreatureToken); ok {
-			return c.sendJSON("PS", ps)
+			return encodeJSON("PS", ps)
 		}
 		if ps, ok := data.(PlaceSomeoneMessagePayload); ok {
-			return c.sendJSON("PS", ps)
+			return encodeJSON("PS", ps)
 		}
 	case Polo:
-		return c.sendln("POLO", "")
+		return "POLO", "", nil
 	case Priv:
 		if reason, ok := data.(PrivMessagePayload); ok {
-			return c.sendJSON("PRIV", reason)
+			return encodeJSON("PRIV", reason)
 		}
 	case Protocol:
-		return c.sendln("PROTOCOL", fmt.Sprintf("%v", data))
+		return "PROTOCOL", fmt.Sprintf("%v", data), nil
 	case QueryCoreData:
 		if q, ok := data.(QueryCoreDataMessagePayload); ok {
-			return c.sendJSON("CORE", q)
+			return encodeJSON("CORE", q)
 		}
 	case QueryCoreIndex:
 		if q, ok := data.(QueryCoreIndexMessagePayload); ok {
-			return c.sendJSON("COREIDX", q)
+			return encodeJSON("COREIDX", q)
 		}
 	case QueryDicePresets:
-		return c.sendln("DR", "")
+		return "DR", "", nil
+	case QueryDiceVariables:
+		if data == nil {
+			return "DV?", "", nil
+		}
+		if qv, ok := data.(QueryDiceVariablesMessagePayload); ok {
+			return encodeJSON("DV?", qv)
+		}
 	case QueryImage:
 		if qi, ok := data.(ImageDefinition); ok {
-			return c.sendJSON("AI?", qi)
+			return encodeJSON("AI?", qi)
 		}
 		if qi, ok := data.(QueryImageMessagePayload); ok {
-			return c.sendJSON("AI?", qi)
+			return encodeJSON("AI?", qi)
+		}
+	case QueryMaps:
+		if qm, ok := data.(QueryMapsMessagePayload); ok {
+			return encodeJSON("MAP?", qm)
 		}
 	case QueryPeers:
-		return c.sendln("/CONN", "")
+		return "/CONN", "", nil
+	case QueryRandomTables:
+		return "DT?", "", nil
 	case Ready:
-		return c.sendln("READY", "")
+		return "READY", "", nil
 	case Redirect:
 		if red, ok := data.(RedirectMessagePayload); ok {
-			return c.sendJSON("REDIRECT", red)
+			return encodeJSON("REDIRECT", red)
+		}
+	case Redo:
+		if rd, ok := data.(RedoMessagePayload); ok {
+			return encodeJSON("REDO", rd)
 		}
 	case RemoveObjAttributes:
 		if oa, ok := data.(RemoveObjAttributesMessagePayload); ok {
-			return c.sendJSON("OA-", oa)
+			return encodeJSON("OA-", oa)
 		}
 	case RollDice:
 		if rd, ok := data.(RollDiceMessagePayload); ok {
-			return c.sendJSON("D", rd)
+			return encodeJSON("D", rd)
 		}
 	case RollResult:
 		if rd, ok := data.(RollResultMessagePayload); ok {
-			return c.sendJSON("ROLL", rd)
+			return encodeJSON("ROLL", rd)
+		}
+	case SaveMap:
+		if sm, ok := data.(SaveMapMessagePayload); ok {
+			return encodeJSON("MAP-SAVE", sm)
 		}
 	case Sync:
-		return c.sendln("SYNC", "")
+		return "SYNC", "", nil
 	case SyncChat:
 		if sc, ok := data.(SyncChatMessagePayload); ok {
-			return c.sendJSON("SYNC-CHAT", sc)
+			return encodeJSON("SYNC-CHAT", sc)
 		}
 	case TimerAcknowledge:
 		if ta, ok := data.(TimerAcknowledgeMessagePayload); ok {
-			return c.sendJSON("TMACK", ta)
+			return encodeJSON("TMACK", ta)
 		}
 	case TimerRequest:
 		if tr, ok := data.(TimerRequestMessagePayload); ok {
-			return c.sendJSON("TMRQ", tr)
+			return encodeJSON("TMRQ", tr)
 		}
 	case Toolbar:
 		if tb, ok := data.(ToolbarMessagePayload); ok {
-			return c.sendJSON("TB", tb)
+			return encodeJSON("TB", tb)
+		}
+	case Undo:
+		if ud, ok := data.(UndoMessagePayload); ok {
+			return encodeJSON("UNDO", ud)
 		}
 	case UpdateClock:
 		if uc, ok := data.(UpdateClockMessagePayload); ok {
-			return c.sendJSON("CS", uc)
+			return encodeJSON("CS", uc)
 		}
 	case UpdateCoreData:
 		if uc, ok := data.(UpdateCoreDataMessagePayload); ok {
-			return c.sendJSON("CORE=", uc)
+			return encodeJSON("CORE=", uc)
 		}
 	case UpdateCoreIndex:
 		if uc, ok := data.(UpdateCoreIndexMessagePayload); ok {
-			return c.sendJSON("COREIDX=", uc)
+			return encodeJSON("COREIDX=", uc)
 		}
 	case UpdateDicePresets:
 		if dd, ok := data.(UpdateDicePresetsMessagePayload); ok {
-			return c.sendJSON("DD=", dd)
+			return encodeJSON("DD=", dd)
+		}
+	case UpdateDiceVariables:
+		if dv, ok := data.(UpdateDiceVariablesMessagePayload); ok {
+			return encodeJSON("DV=", dv)
 		}
 	case UpdateInitiative:
 		if i, ok := data.(UpdateInitiativeMessagePayload); ok {
-			return c.sendJSON("IL", i)
+			return encodeJSON("IL", i)
+		}
+	case UpdateMaps:
+		if um, ok := data.(UpdateMapsMessagePayload); ok {
+			return encodeJSON("MAP=", um)
 		}
 	case UpdateObjAttributes:
 		if oa, ok := data.(UpdateObjAttributesMessagePayload); ok {
-			return c.sendJSON("OA", oa)
+			return encodeJSON("OA", oa)
 		}
 	case UpdatePeerList:
 		if up, ok := data.(UpdatePeerListMessagePayload); ok {
-			return c.sendJSON("CONN", up)
+			return encodeJSON("CONN", up)
 		}
 	case UpdateProgress:
 		if up, ok := data.(UpdateProgressMessagePayload); ok {
-			return c.sendJSON("PROGRESS", up)
+			return encodeJSON("PROGRESS", up)
+		}
+	case UpdateRandomTables:
+		if ut, ok := data.(UpdateRandomTablesMessagePayload); ok {
+			return encodeJSON("DT=", ut)
 		}
 	case UpdateStatusMarker:
 		if sm, ok := data.(StatusMarkerDefinition); ok {
-			return c.sendJSON("DSM", sm)
+			return encodeJSON("DSM", sm)
 		}
 		if sm, ok := data.(UpdateStatusMarkerMessagePayload); ok {
-			return c.sendJSON("DSM", sm)
+			return encodeJSON("DSM", sm)
 		}
 	case UpdateTurn:
 		if tu, ok := data.(UpdateTurnMessagePayload); ok {
-			return c.sendJSON("I", tu)
+			return encodeJSON("I", tu)
 		}
 	case UpdateVersions:
 		if up, ok := data.(UpdateVersionsMessagePayload); ok {
-			return c.sendJSON("UPDATES", up)
+			return encodeJSON("UPDATES", up)
 		}
 	case World:
 		if wo, ok := data.(WorldMessagePayload); ok {
-			return c.sendJSON("WORLD", wo)
+			return encodeJSON("WORLD", wo)
 		}
 	}
-	return fmt.Errorf("send: invalid command or data type")
+	return "", "", fmt.Errorf("send: invalid command or data type")
 }
 
-func (c *MapConnection) sendJSON(commandWord string, data any) error {
-	var err error
-	if c == nil {
-		return fmt.Errorf("nil MapConnection")
-	}
+// encodeJSON returns the command word and JSON-encoded data for
+// a protocol message.
+func encodeJSON(commandWord string, data any) (string, string, error) {
 	if data == nil {
-		return c.sendln(commandWord, "")
+		return commandWord, "", nil
 	}
-	if j, err := json.Marshal(data); err == nil {
-		return c.sendln(commandWord, string(j))
+	j, err := json.Marshal(data)
+	if err != nil {
+		return "", "", fmt.Errorf("send: %v", err)
 	}
-	return fmt.Errorf("send: %v", err)
+	return commandWord, string(j), nil
 }
 
 func (c *MapConnection) sendln(commandWord, data string) error {
@@ -454,6 +556,21 @@ func (c *MapConnection) sendln(commandWord, data string) error {
 	return nil
 }
 
+// holdPending removes and returns any messages waiting to be sent,
+// so that they may be sent later.
+func (c *MapConnection) holdPending() []string {
+	for {
+		select {
+		case packet := <-c.sendChan:
+			c.sendBuf = append(c.sendBuf, packet)
+		default:
+			held := c.sendBuf
+			c.sendBuf = nil
+			return held
+		}
+	}
+}
+
 // blocking raw data sent to other side
 func (c *MapConnection) sendRaw(data string) error {
 	if c != nil {
@@ -493,18 +610,32 @@ func (c *MapConnection) Receive() (MessagePayload, error) {
 		return nil, nil
 	}
 
+	c.debugf(DebugIO|DebugMessages, "<-%v", c.reader.Text())
+	payload, err := ParseMessage(c.reader.Text())
+	if err != nil {
+		c.debug(DebugIO, "unable to cope with message, returning nil")
+	}
+	return payload, err
+}
+
+// ParseMessage interprets a single line of protocol text (as would be
+// received from the peer, minus the trailing newline) and returns the
+// MessagePayload it represents, just as Receive does for data arriving
+// on the network connection.
+func ParseMessage(line string) (MessagePayload, error) {
+	var err error
+
 	// Comments are anything starting with "//"
 	// The input line is in the form COMMAND-WORD [JSON] \n
-	c.debugf(DebugIO|DebugMessages, "<-%v", c.reader.Text())
 	payload := BaseMessagePayload{
-		rawMessage: c.reader.Text(),
+		rawMessage: line,
 	}
-	commandWord, jsonString, hasJsonPart := strings.Cut(c.reader.Text(), " ")
+	commandWord, jsonString, hasJsonPart := strings.Cut(line, " ")
 	if strings.Index(commandWord, "//") == 0 {
 		payload.messageType = Comment
 		return CommentMessagePayload{
 			BaseMessagePayload: payload,
-			Text:               c.reader.Text()[2:],
+			Text:               line[2:],
 		}, nil
 	}
 
@@ -779,6 +910,96 @@ func (c *MapConnection) Receive() (MessagePayload, error) {
 		p.messageType = QueryDicePresets
 		return p, nil
 
+	case "DV":
+		p := DefineDiceVariablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = DefineDiceVariables
+		return p, nil
+
+	case "DV+":
+		p := AddDiceVariablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = AddDiceVariables
+		return p, nil
+
+	case "DV?":
+		p := QueryDiceVariablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = QueryDiceVariables
+		return p, nil
+
+	case "DV=":
+		p := UpdateDiceVariablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = UpdateDiceVariables
+		return p, nil
+
+	case "DT":
+		p := DefineRandomTablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = DefineRandomTables
+		return p, nil
+
+	case "DT+":
+		p := AddRandomTablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = AddRandomTables
+		return p, nil
+
+	case "DT/":
+		p := FilterRandomTablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = FilterRandomTables
+		return p, nil
+
+	case "DT?":
+		p := QueryRandomTablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = QueryRandomTables
+		return p, nil
+
+	case "DT=":
+		p := UpdateRandomTablesMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = UpdateRandomTables
+		return p, nil
+
 	case "DSM":
 		p := UpdateStatusMarkerMessagePayload{BaseMessagePayload: payload}
 		if hasJsonPart {
@@ -929,6 +1150,46 @@ func (c *MapConnection) Receive() (MessagePayload, error) {
 		p.messageType = LoadTileObject
 		return p, nil
 
+	case "MAP-LOAD":
+		p := LoadMapMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = LoadMap
+		return p, nil
+
+	case "MAP-SAVE":
+		p := SaveMapMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = SaveMap
+		return p, nil
+
+	case "MAP=":
+		p := UpdateMapsMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = UpdateMaps
+		return p, nil
+
+	case "MAP?":
+		p := QueryMapsMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = QueryMaps
+		return p, nil
+
 	case "MARCO":
 		p := MarcoMessagePayload{BaseMessagePayload: payload}
 		p.messageType = Marco
@@ -1048,6 +1309,16 @@ func (c *MapConnection) Receive() (MessagePayload, error) {
 		p.messageType = Redirect
 		return p, nil
 
+	case "REDO":
+		p := RedoMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = Redo
+		return p, nil
+
 	case "ROLL":
 		p := RollResultMessagePayload{BaseMessagePayload: payload}
 		if hasJsonPart {
@@ -1113,6 +1384,16 @@ func (c *MapConnection) Receive() (MessagePayload, error) {
 		p.messageType = ChatMessage
 		return p, nil
 
+	case "UNDO":
+		p := UndoMessagePayload{BaseMessagePayload: payload}
+		if hasJsonPart {
+			if err = json.Unmarshal([]byte(jsonString), &p); err != nil {
+				break
+			}
+		}
+		p.messageType = Undo
+		return p, nil
+
 	case "UPDATES":
 		p := UpdateVersionsMessagePayload{BaseMessagePayload: payload}
 		if hasJsonPart {
@@ -1151,7 +1432,6 @@ func (c *MapConnection) Receive() (MessagePayload, error) {
 		}, nil
 	}
 
-	c.debug(DebugIO, "unable to cope with message, returning nil")
 	return nil, fmt.Errorf("bailing out, unable to cope with received packet")
 }
 
@@ -0,0 +1,172 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Unit tests for the mapper protocol message encoding
+//
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/MadScienceZone/go-gma/v5/dice"
+)
+
+func TestFormatMessage(t *testing.T) {
+	for i, tc := range []struct {
+		cmd      ServerMessage
+		data     any
+		expected string
+	}{
+		{Marco, nil, "MARCO"},
+		{Comment, "hello world", "// hello world"},
+		{CombatMode, CombatModeMessagePayload{Enabled: true}, `CO {"Enabled":true}`},
+		{Clear, ClearMessagePayload{ObjID: "E*"}, `CLR {"ObjID":"E*"}`},
+		{AddObjAttributes, AddObjAttributesMessagePayload{ObjID: "abc", AttrName: "StatusList", Values: []string{"x", "y"}},
+			`OA+ {"ObjID":"abc","AttrName":"StatusList","Values":["x","y"]}`},
+		{DefineDiceVariables, DefineDiceVariablesMessagePayload{Variables: map[string]string{"str": "4", "bab": "6"}},
+			`DV {"Variables":{"bab":"6","str":"4"}}`},
+		{QueryDiceVariables, nil, "DV?"},
+		{UpdateDiceVariables, UpdateDiceVariablesMessagePayload{For: "alice", Variables: map[string]string{"str": "4"}},
+			`DV= {"For":"alice","Variables":{"str":"4"}}`},
+		{Undo, UndoMessagePayload{Count: 3}, `UNDO {"Count":3}`},
+		{Redo, RedoMessagePayload{}, `REDO {}`},
+		{SaveMap, SaveMapMessagePayload{Name: "crypt", Location: "Tomb of Horrors"}, `MAP-SAVE {"Name":"crypt","Location":"Tomb of Horrors"}`},
+		{QueryMaps, QueryMapsMessagePayload{RequestID: "r1"}, `MAP? {"RequestID":"r1"}`},
+		{LoadMap, LoadMapMessagePayload{Name: "crypt", Merge: true}, `MAP-LOAD {"Name":"crypt","Merge":true}`},
+		{UpdateMaps, UpdateMapsMessagePayload{Maps: []SavedMap{{Name: "crypt", MapMetaData: MapMetaData{Timestamp: 42, Comment: "lower level"}}}},
+			`MAP= {"Maps":[{"Name":"crypt","Timestamp":42,"Comment":"lower level"}]}`},
+		{DefineRandomTables, DefineRandomTablesMessagePayload{Tables: []dice.RandomTable{{Name: "Gem", Entries: []dice.RandomTableEntry{{Low: 1, High: 3, Result: "ruby"}}}}},
+			`DT {"Tables":[{"Name":"Gem","Entries":[{"Low":1,"High":3,"Result":"ruby"}]}]}`},
+		{AddRandomTables, AddRandomTablesMessagePayload{Tables: []dice.RandomTable{{Name: "Gem", Entries: []dice.RandomTableEntry{{Weight: 2, Result: "ruby"}}}}},
+			`DT+ {"Tables":[{"Name":"Gem","Entries":[{"Weight":2,"Result":"ruby"}]}]}`},
+		{FilterRandomTables, FilterRandomTablesMessagePayload{Filter: "^G"}, `DT/ {"Filter":"^G"}`},
+		{QueryRandomTables, nil, "DT?"},
+		{UpdateRandomTables, UpdateRandomTablesMessagePayload{}, `DT= {}`},
+	} {
+		actual, err := FormatMessage(tc.cmd, tc.data)
+		if err != nil {
+			t.Errorf("test case %d: unexpected error %v", i, err)
+		} else if actual != tc.expected {
+			t.Errorf("test case %d: expected \"%s\" but got \"%s\"", i, tc.expected, actual)
+		}
+	}
+
+	if _, err := FormatMessage(CombatMode, ClearMessagePayload{}); err == nil {
+		t.Errorf("mismatched command and data type did not produce an error")
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	line, err := FormatMessage(AddObjAttributes, AddObjAttributesMessagePayload{ObjID: "abc", AttrName: "StatusList", Values: []string{"x", "y"}})
+	if err != nil {
+		t.Fatalf("unexpected error formatting message: %v", err)
+	}
+	p, err := ParseMessage(line)
+	if err != nil {
+		t.Fatalf("unexpected error parsing message: %v", err)
+	}
+	if p.MessageType() != AddObjAttributes {
+		t.Errorf("expected message type %v but got %v", AddObjAttributes, p.MessageType())
+	}
+	oa, ok := p.(AddObjAttributesMessagePayload)
+	if !ok {
+		t.Fatalf("expected AddObjAttributesMessagePayload but got %T", p)
+	}
+	if oa.ObjID != "abc" || oa.AttrName != "StatusList" || len(oa.Values) != 2 || oa.Values[0] != "x" || oa.Values[1] != "y" {
+		t.Errorf("payload did not survive round trip: %v", oa)
+	}
+	if p.RawMessage() != line {
+		t.Errorf("raw message \"%s\" does not match input \"%s\"", p.RawMessage(), line)
+	}
+
+	p, err = ParseMessage(`MAP= {"Maps":[{"Name":"crypt","Location":"Tomb of Horrors","Timestamp":42}],"RequestID":"r1"}`)
+	if err != nil {
+		t.Fatalf("unexpected error parsing map list: %v", err)
+	}
+	if um, ok := p.(UpdateMapsMessagePayload); !ok || um.RequestID != "r1" || len(um.Maps) != 1 ||
+		um.Maps[0].Name != "crypt" || um.Maps[0].Location != "Tomb of Horrors" || um.Maps[0].Timestamp != 42 {
+		t.Errorf("map list parsed as %T %v", p, p)
+	}
+
+	p, err = ParseMessage(`DT= {"Tables":[{"Name":"Gem","DieRollSpec":"d6","Entries":[{"Low":1,"High":3,"Result":"ruby"},{"Low":4,"High":6,"Result":"emerald"}]}]}`)
+	if err != nil {
+		t.Fatalf("unexpected error parsing random tables: %v", err)
+	}
+	if ut, ok := p.(UpdateRandomTablesMessagePayload); !ok || len(ut.Tables) != 1 || ut.Tables[0].Name != "Gem" ||
+		ut.Tables[0].DieRollSpec != "d6" || len(ut.Tables[0].Entries) != 2 || ut.Tables[0].Entries[1].Result != "emerald" {
+		t.Errorf("random tables parsed as %T %v", p, p)
+	}
+
+	p, err = ParseMessage("// just a comment")
+	if err != nil {
+		t.Fatalf("unexpected error parsing comment: %v", err)
+	}
+	if c, ok := p.(CommentMessagePayload); !ok || c.Text != " just a comment" {
+		t.Errorf("comment parsed as %T %v", p, p)
+	}
+
+	p, err = ParseMessage("CO {not json")
+	if err != nil {
+		t.Fatalf("unexpected error parsing bad JSON: %v", err)
+	}
+	if p.MessageType() != ERROR {
+		t.Errorf("bad JSON should have yielded an ERROR payload, but got %T %v", p, p)
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
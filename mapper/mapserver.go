@@ -47,6 +47,21 @@ type MapServer interface {
 	GetAllowedClients() []PackageUpdate
 }
 
+// CampaignServer is implemented by a MapServer which hosts several
+// campaigns at once. A client chooses which campaign to join by naming
+// it in its AUTH message, after which its session is served entirely by
+// the MapServer for that campaign.
+type CampaignServer interface {
+	// Campaigns lists the campaigns a client may choose to join.
+	// If this is empty, the client may not choose a campaign.
+	Campaigns() []CampaignDescription
+
+	// SelectCampaign returns the MapServer for the named campaign and
+	// a new authenticator for the client to log in to it (which is nil
+	// if the campaign does not require authentication).
+	SelectCampaign(name string) (MapServer, *auth.Authenticator, error)
+}
+
 // ClientPreamble contains information given to each client upon
 // connection to the server.
 type ClientPreamble struct {
@@ -82,6 +97,11 @@ type ClientConnection struct {
 	// Authentication information for this user
 	Auth *auth.Authenticator
 
+	// If the client connected over TLS with a verified client
+	// certificate, this is the user name (common name) from that
+	// certificate. The user must then log in with that name.
+	CertificateUser string
+
 	// Level of debugging requested for this client
 	DebuggingLevel DebugFlags
 
@@ -90,6 +110,9 @@ type ClientConnection struct {
 	Conn MapConnection
 	D    *dice.DieRoller
 
+	// If not nil, this is told about our traffic with the client.
+	Metrics ClientMetrics
+
 	// Quality of Service tracking
 	QoS struct {
 		QueryImage struct {
@@ -115,8 +138,9 @@ func NewClientConnection(socket net.Conn, opts ...ClientConnectionOption) (Clien
 	var err error
 
 	newCon := ClientConnection{
-		Address: socket.RemoteAddr().String(),
-		Conn:    NewMapConnection(socket),
+		Address:         socket.RemoteAddr().String(),
+		Conn:            NewMapConnection(socket),
+		CertificateUser: certificateUser(socket),
 	}
 	newCon.Conn.debug = newCon.debug
 	newCon.Conn.debugf = newCon.debugf
@@ -210,6 +234,36 @@ func WithDebugFunctions(debugFunc func(DebugFlags, string), debugfFunc func(Debu
 	}
 }
 
+// ClientMetrics is implemented by a server which keeps statistics
+// about its clients. Its methods must be safe for concurrent use, since
+// they are called from the goroutines serving each client.
+type ClientMetrics interface {
+	// MessageReceived is called for each message received from the
+	// client after it has logged in.
+	MessageReceived(ServerMessage)
+
+	// MessageSent is called for each message sent to the client.
+	MessageSent(ServerMessage)
+
+	// QoSViolation is called when the client is disconnected for
+	// exceeding the named QoS limit ("QueryImage" or "MessageRate").
+	QoSViolation(string)
+}
+
+// WithClientMetrics arranges for the client's traffic to be reported
+// to m.
+func WithClientMetrics(m ClientMetrics) ClientConnectionOption {
+	return func(c *ClientConnection) error {
+		c.Metrics = m
+		if m != nil {
+			c.Conn.sent = m.MessageSent
+		} else {
+			c.Conn.sent = nil
+		}
+		return nil
+	}
+}
+
 func WithClientDebuggingLevel(l DebugFlags) ClientConnectionOption {
 	return func(c *ClientConnection) error {
 		c.DebuggingLevel = l
@@ -489,6 +543,7 @@ mainloop:
 			if c.QoS.QueryImage.Threshold > 0 {
 				for q, cnt := range c.QoS.QueryImage.Count {
 					if cnt > c.QoS.QueryImage.Threshold {
+						c.qosViolation("QueryImage")
 						c.Logf("QoS violation: Asked for image \"%s\" %d %s (allowed %d in %s)",
 							q, cnt, util.PluralizeString("time", int(cnt)),
 							c.QoS.QueryImage.Threshold,
@@ -511,6 +566,7 @@ mainloop:
 			// overall message rate limit.
 			if c.QoS.MessageRate.Threshold > 0 {
 				if c.QoS.MessageRate.Count > c.QoS.MessageRate.Threshold {
+					c.qosViolation("MessageRate")
 					c.Logf("QoS violation: Received %d %s within %s",
 						c.QoS.MessageRate.Count,
 						util.PluralizeString("message", int(c.QoS.MessageRate.Count)),
@@ -544,9 +600,13 @@ mainloop:
 				break mainloop
 			}
 			c.debugf(DebugIO, "received packet %v", packet)
+			if c.Metrics != nil {
+				c.Metrics.MessageReceived(packet.MessageType())
+			}
 			if c.QoS.MessageRate.Threshold > 0 {
 				c.QoS.MessageRate.Count++
 				if c.QoS.MessageRate.Count > c.QoS.MessageRate.Threshold {
+					c.qosViolation("MessageRate")
 					c.Logf("QoS violation: Received %d %s within %s",
 						c.QoS.MessageRate.Count,
 						util.PluralizeString("message", int(c.QoS.MessageRate.Count)),
@@ -570,7 +630,8 @@ mainloop:
 				case CommentMessagePayload:
 
 				case AddCharacterMessagePayload, ChallengeMessagePayload, ProtocolMessagePayload,
-					UpdateDicePresetsMessagePayload, DeniedMessagePayload, GrantedMessagePayload,
+					UpdateDicePresetsMessagePayload, UpdateDiceVariablesMessagePayload, UpdateRandomTablesMessagePayload,
+					DeniedMessagePayload, GrantedMessagePayload,
 					MarcoMessagePayload, PrivMessagePayload, ReadyMessagePayload, RedirectMessagePayload,
 					RollResultMessagePayload, UpdateCoreDataMessagePayload, UpdateCoreIndexMessagePayload,
 					UpdatePeerListMessagePayload,
@@ -617,30 +678,13 @@ mainloop:
 					p.ReceivedTime = time.Now()
 					c.Server.HandleServerMessage(p, c)
 
-				case FilterCoreDataMessagePayload:
-					c.Conn.Send(Comment, "FilterCoreData message ignored since the core database is not yet implemented.")
-
-				case QueryCoreDataMessagePayload:
-					c.Conn.Send(UpdateCoreData, UpdateCoreDataMessagePayload{
-						RequestID:   p.RequestID,
-						NoSuchEntry: true,
-					})
-
-				case QueryCoreIndexMessagePayload:
-					c.Conn.Send(UpdateCoreIndex, UpdateCoreIndexMessagePayload{
-						RequestID: p.RequestID,
-						Type:      p.Type,
-						IsDone:    true,
-						N:         0,
-						Of:        0,
-					})
-
 				case QueryImageMessagePayload:
 					if c.QoS.QueryImage.Threshold > 0 {
 						for _, requestedSize := range p.Sizes {
 							id := fmt.Sprintf("%s:%v", p.Name, requestedSize.Zoom)
 							if _, alreadyAnswered := c.QoS.QueryImage.Count[id]; alreadyAnswered {
 								if c.QoS.QueryImage.Count[id]++; c.QoS.QueryImage.Count[id] > c.QoS.QueryImage.Threshold {
+									c.qosViolation("QueryImage")
 									c.Logf("QoS violation: Asked for image \"%s\" %d %s (allowed %d in %s)",
 										id,
 										c.QoS.QueryImage.Count[id],
@@ -670,6 +714,40 @@ mainloop:
 	}
 }
 
+// qosViolation reports that the client is being disconnected for
+// exceeding the named QoS limit.
+func (c *ClientConnection) qosViolation(limit string) {
+	if c.Metrics != nil {
+		c.Metrics.QoSViolation(limit)
+	}
+}
+
+// joinCampaign switches the client over to the named campaign during
+// login, carrying over the authentication challenge already issued
+// to the client.
+func (c *ClientConnection) joinCampaign(name string) error {
+	cs, ok := c.Server.(CampaignServer)
+	if !ok {
+		return fmt.Errorf("this server does not host multiple campaigns")
+	}
+	server, cauth, err := cs.SelectCampaign(name)
+	if err != nil {
+		return err
+	}
+	if cauth != nil && c.Auth != nil {
+		cauth.Challenge = c.Auth.Challenge
+		cauth.Iterations = c.Auth.Iterations
+	}
+	c.Server = server
+	c.Auth = cauth
+	if m, ok := server.(ClientMetrics); ok && c.Metrics != nil {
+		c.Metrics = m
+		c.Conn.sent = m.MessageSent
+	}
+	c.Logf("client joining campaign \"%s\"", name)
+	return nil
+}
+
 func (c *ClientConnection) loginClient(ctx context.Context, done chan error, serverStarted, lastPing time.Time) {
 	defer close(done)
 	if c == nil {
@@ -717,10 +795,18 @@ func (c *ClientConnection) loginClient(ctx context.Context, done chan error, ser
 			done <- fmt.Errorf("error generating authentication challenge: %v", err)
 			return
 		}
+		var campaigns []CampaignDescription
+		if cs, ok := c.Server.(CampaignServer); ok {
+			campaigns = cs.Campaigns()
+		}
 		c.Conn.Send(Challenge, ChallengeMessagePayload{
 			Protocol:      GMAMapperProtocol,
 			Challenge:     challenge,
 			Iterations:    iterations,
+			Salt:          c.Auth.Salt,
+			KeyIterations: c.Auth.KeyIterations,
+			PersonalSalts: c.Auth.PersonalSalts,
+			Campaigns:     campaigns,
 			ServerStarted: serverStarted,
 			ServerActive:  lastPing,
 			ServerTime:    time.Now(),
@@ -820,14 +906,56 @@ func (c *ClientConnection) loginClient(ctx context.Context, done chan error, ser
 					}
 				}
 
+				if packet.Campaign != "" {
+					if err := c.joinCampaign(packet.Campaign); err != nil {
+						c.Logf("unable to join campaign \"%s\": %v", packet.Campaign, err)
+						c.Conn.Send(Denied, DeniedMessagePayload{Reason: err.Error()})
+						_ = c.Conn.Flush()
+						done <- fmt.Errorf("access denied")
+						return
+					}
+					preamble = c.Server.GetClientPreamble()
+					if c.Auth == nil {
+						// This campaign doesn't require authentication, so
+						// we'll accept the client on its word.
+						c.Auth = &auth.Authenticator{Client: packet.Client, Username: packet.User}
+						if c.CertificateUser != "" {
+							c.Auth.Username = c.CertificateUser
+						} else if packet.User == "GM" || packet.User == "" {
+							c.Auth.Username = "unknown"
+						}
+						c.Conn.Send(Granted, GrantedMessagePayload{User: c.Auth.Username, Campaign: packet.Campaign})
+						break awaitUserAuth
+					}
+				}
+
+				if len(c.Auth.Salt) > 0 && packet.Protocol < MinimumSaltedAuthProtocol {
+					c.Logf("client %s (protocol %d) can't answer a challenge for salted password keys", packet.Client, packet.Protocol)
+					c.Conn.Send(Denied, DeniedMessagePayload{Reason: fmt.Sprintf("this server requires a client which implements protocol %d or later", MinimumSaltedAuthProtocol)})
+					_ = c.Conn.Flush()
+					done <- fmt.Errorf("access denied")
+					return
+				}
+
+				response := packet.Response
 				if newSecret := c.Server.GetPersonalCredentials(packet.User); newSecret != nil {
 					c.Auth.SetSecret(newSecret)
+					if c.Auth.PersonalSalts {
+						response = packet.PersonalResponse
+					}
 				}
-				success, err := c.Auth.ValidateResponseBytes(packet.Response)
+				success, err := c.Auth.ValidateResponseBytes(response)
 				if err != nil {
 					c.Logf("error trying to authenticate: %v", err)
 					done <- err
 				}
+				if success && c.CertificateUser != "" && !c.Auth.GmMode && packet.User != c.CertificateUser {
+					c.Logf("client certificate for %s does not match requested user name %s", c.CertificateUser, packet.User)
+					c.Conn.Send(Denied, DeniedMessagePayload{Reason: "user name does not match client certificate"})
+					_ = c.Conn.Flush()
+					done <- fmt.Errorf("access denied")
+					return
+				}
 				if success {
 					c.Auth.Client = packet.Client
 					if c.Auth.GmMode {
@@ -840,7 +968,7 @@ func (c *ClientConnection) loginClient(ctx context.Context, done chan error, ser
 							c.Auth.Username = packet.User
 						}
 					}
-					c.Conn.Send(Granted, GrantedMessagePayload{User: c.Auth.Username})
+					c.Conn.Send(Granted, GrantedMessagePayload{User: c.Auth.Username, Campaign: packet.Campaign})
 					break awaitUserAuth
 				} else {
 					c.Conn.Send(Denied, DeniedMessagePayload{Reason: "login incorrect"})
@@ -852,6 +980,10 @@ func (c *ClientConnection) loginClient(ctx context.Context, done chan error, ser
 		}
 	} else {
 		c.debug(DebugIO, "proceeding without authentication")
+		if c.CertificateUser != "" {
+			c.Logf("client identified as %s by its certificate", c.CertificateUser)
+			c.Auth = &auth.Authenticator{Username: c.CertificateUser}
+		}
 		c.Conn.Send(Challenge, ChallengeMessagePayload{
 			Protocol:      GMAMapperProtocol,
 			ServerStarted: serverStarted,
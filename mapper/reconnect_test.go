@@ -0,0 +1,257 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Unit tests for automatic reconnection to the server
+//
+
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveReconnectTest plays the part of a server which lets a client sign on
+// (without authentication), sends it a chat message, and then drops the
+// connection, signalling on the dropped channel once the client has hung up.
+// When the client reconnects, the lines it sends are reported on the
+// received channel.
+func serveReconnectTest(t *testing.T, listener net.Listener, dropped chan byte, received chan string) {
+	greet := func(conn net.Conn) {
+		fmt.Fprintf(conn, "PROTOCOL %d\n", MaximumSupportedMapProtocol)
+		fmt.Fprintf(conn, "OK {\"Protocol\":%d}\n", MaximumSupportedMapProtocol)
+		fmt.Fprintf(conn, "DSM {\"Condition\":\"dazed\",\"Shape\":\"|v\",\"Color\":\"red\"}\n")
+		fmt.Fprintf(conn, "READY\n")
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Errorf("accept: %v", err)
+		return
+	}
+	greet(conn)
+	fmt.Fprintf(conn, "TO {\"Sender\":\"GM\",\"Text\":\"hello\",\"MessageID\":42}\n")
+	conn.(*net.TCPConn).CloseWrite()
+	io.Copy(io.Discard, conn)
+	conn.Close()
+	dropped <- 0
+
+	conn, err = listener.Accept()
+	if err != nil {
+		t.Errorf("accept: %v", err)
+		return
+	}
+	defer conn.Close()
+	greet(conn)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		received <- scanner.Text()
+	}
+	close(received)
+}
+
+func TestReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	dropped := make(chan byte, 1)
+	received := make(chan string, 100)
+	go serveReconnectTest(t, listener, dropped, received)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan byte, 1)
+	messages := make(chan MessagePayload, 10)
+	server, err := NewConnection(listener.Addr().String(),
+		WithContext(ctx),
+		WithLogger(nil),
+		StayConnected(true),
+		WithRetryDelay(10*time.Millisecond, 100*time.Millisecond),
+		WhenReady(ready),
+		WithSubscription(messages, ChatMessage, Reconnected))
+	if err != nil {
+		t.Fatalf("NewConnection: %v", err)
+	}
+	go server.Dial()
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for client to sign on")
+	}
+
+	var reconnected ReconnectedMessagePayload
+	for seen := false; !seen; {
+		select {
+		case m := <-messages:
+			switch msg := m.(type) {
+			case ChatMessageMessagePayload:
+				if msg.MessageID != 42 {
+					t.Errorf("expected chat message #42, got #%d", msg.MessageID)
+				}
+				// queue up a message while we're disconnected,
+				// to be sent once we reconnect
+				go func() {
+					<-dropped
+					server.ChatMessageToAll("back again")
+				}()
+			case ReconnectedMessagePayload:
+				reconnected = msg
+				seen = true
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for client to reconnect")
+		}
+	}
+	if reconnected.LastMessageID != 42 {
+		t.Errorf("expected reconnection to resume after message #42, got #%d", reconnected.LastMessageID)
+	}
+	if _, ok := server.Conditions["dazed"]; !ok {
+		t.Errorf("expected status markers to be received again after reconnecting")
+	}
+
+	want := map[string]bool{
+		"SYNC":                      false,
+		"SYNC-CHAT {\"Target\":42}": false,
+		"back again":                false,
+	}
+	timeout := time.After(5 * time.Second)
+	for remaining := len(want); remaining > 0; {
+		select {
+		case line, ok := <-received:
+			if !ok {
+				t.Fatalf("connection closed before client resynchronized; still waiting for %v", want)
+			}
+			for text, seen := range want {
+				if !seen && (line == text || (strings.HasPrefix(line, "TO ") && strings.Contains(line, text))) {
+					want[text] = true
+					remaining--
+				}
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for client to resynchronize; saw %v", want)
+		}
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	// hang up on the client every time it tries to sign on,
+	// noting when each attempt was made.
+	attempts := make(chan time.Time, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			attempts <- time.Now()
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	initial := 20 * time.Millisecond
+	limit := 100 * time.Millisecond
+	server, err := NewConnection(listener.Addr().String(),
+		WithContext(ctx),
+		WithLogger(nil),
+		StayConnected(true),
+		WithRetryDelay(initial, limit))
+	if err != nil {
+		t.Fatalf("NewConnection: %v", err)
+	}
+	go server.Dial()
+
+	var times []time.Time
+	for len(times) < 6 {
+		select {
+		case when := <-attempts:
+			times = append(times, when)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %d connection attempts", len(times))
+		}
+	}
+	cancel()
+
+	expected := initial
+	for i := 1; i < len(times); i++ {
+		if waited := times[i].Sub(times[i-1]); waited < expected {
+			t.Errorf("attempt #%d came %v after the previous one; expected at least %v", i+1, waited, expected)
+		}
+		if expected *= 2; expected > limit {
+			expected = limit
+		}
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,341 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Requests which wait for the server's reply: the client generates
+// a request ID, sends the request, and collects the replies which
+// carry that ID back to the caller.
+//
+
+package mapper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RequestFailedError is the error returned by the methods which wait for
+// the server to reply to a request, if the server (or the GM) sent a
+// FAILED message in response to it.
+type RequestFailedError struct {
+	Reply FailedMessagePayload
+}
+
+func (e RequestFailedError) Error() string {
+	if e.Reply.IsDiscretionary {
+		return fmt.Sprintf("request %s declined: %s", e.Reply.Command, e.Reply.Reason)
+	}
+	return fmt.Sprintf("request %s failed: %s", e.Reply.Command, e.Reply.Reason)
+}
+
+// pendingRequests tracks the requests we are waiting for the server to
+// answer, by request ID.
+type pendingRequests struct {
+	lock    sync.Mutex
+	waiting map[string]*pendingRequest
+}
+
+type pendingRequest struct {
+	replies chan MessagePayload
+	done    chan struct{}
+}
+
+// WithRequestTimeout modifies the behavior of the NewConnection function
+// so that the methods which wait for the server to reply to a request
+// (such as RollDiceAndWait) give up if the complete reply hasn't arrived
+// within the given time. Otherwise they wait until their context is
+// cancelled.
+func WithRequestTimeout(t time.Duration) ConnectionOption {
+	return func(c *Connection) error {
+		c.RequestTimeout = t
+		return nil
+	}
+}
+
+// NewRequestID generates a new, unique request ID.
+func NewRequestID() string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("req-%d", time.Now().UnixNano())
+	}
+	return "req-" + hex.EncodeToString(b[:])
+}
+
+// startRequest registers a new request ID whose replies should be
+// sent to us.
+func (c *Connection) startRequest() (string, *pendingRequest, error) {
+	if c == nil {
+		return "", nil, fmt.Errorf("nil Connection")
+	}
+	if c.requests == nil {
+		return "", nil, fmt.Errorf("Connection was not created by NewConnection")
+	}
+	id := NewRequestID()
+	request := &pendingRequest{
+		replies: make(chan MessagePayload, 16),
+		done:    make(chan struct{}),
+	}
+	c.requests.lock.Lock()
+	c.requests.waiting[id] = request
+	c.requests.lock.Unlock()
+	return id, request, nil
+}
+
+// endRequest stops routing replies for the request ID to us.
+func (c *Connection) endRequest(id string, request *pendingRequest) {
+	// Closing done first releases routeReply if it's trying to give
+	// us a reply while holding the lock.
+	close(request.done)
+	c.requests.lock.Lock()
+	delete(c.requests.waiting, id)
+	c.requests.lock.Unlock()
+}
+
+// routeReply sends a reply from the server to the caller waiting for it,
+// if there is one, returning true if it did so.
+func (c *Connection) routeReply(id string, reply MessagePayload) bool {
+	if id == "" || c.requests == nil {
+		return false
+	}
+	c.requests.lock.Lock()
+	defer c.requests.lock.Unlock()
+	request, ok := c.requests.waiting[id]
+	if !ok {
+		return false
+	}
+	select {
+	case request.replies <- reply:
+	case <-request.done:
+	}
+	return true
+}
+
+// awaitReplies sends a request with a newly-generated request ID, then
+// collects the replies of type T bearing that ID until isLast reports
+// that the final one has arrived, the server reports that the request
+// failed, or the context is cancelled.
+func awaitReplies[T MessagePayload](ctx context.Context, c *Connection, send func(requestID string) error, isLast func(T) bool) ([]T, error) {
+	id, request, err := c.startRequest()
+	if err != nil {
+		return nil, err
+	}
+	defer c.endRequest(id, request)
+
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+
+	if err := send(id); err != nil {
+		return nil, err
+	}
+
+	var replies []T
+	for {
+		select {
+		case reply := <-request.replies:
+			switch r := reply.(type) {
+			case T:
+				replies = append(replies, r)
+				if isLast(r) {
+					return replies, nil
+				}
+			case FailedMessagePayload:
+				return replies, RequestFailedError{Reply: r}
+			}
+		case <-ctx.Done():
+			return replies, fmt.Errorf("mapper: gave up waiting for reply to request %s: %w", id, ctx.Err())
+		case <-c.Context.Done():
+			return replies, fmt.Errorf("mapper: connection closed while waiting for reply to request %s: %w", id, c.Context.Err())
+		}
+	}
+}
+
+// RollDiceAndWait is like RollDiceWithID, but generates the request ID
+// itself, then waits for the server to send all of the results of the
+// die roll (until one arrives with MoreResults false), returning them.
+//
+// The results are also sent to the channel subscribed to RollResult
+// messages (if any), just like everyone else's die rolls.
+//
+// If the context is cancelled (or the connection's RequestTimeout
+// expires) before all the results arrive, those received so far are
+// returned along with an error. If the server rejects the request,
+// the error is a RequestFailedError.
+func (c *Connection) RollDiceAndWait(ctx context.Context, to []string, rollspec string) ([]RollResultMessagePayload, error) {
+	return awaitReplies(ctx, c, func(id string) error {
+		return c.RollDiceWithID(to, rollspec, id)
+	}, isLastRollResult)
+}
+
+// RollDiceToAllAndWait is equivalent to RollDiceAndWait, sending the results to all users.
+func (c *Connection) RollDiceToAllAndWait(ctx context.Context, rollspec string) ([]RollResultMessagePayload, error) {
+	return awaitReplies(ctx, c, func(id string) error {
+		return c.RollDiceToAllWithID(rollspec, id)
+	}, isLastRollResult)
+}
+
+// RollDiceToGMAndWait is equivalent to RollDiceAndWait, sending the results only to the GM.
+// (Unless we are the GM, the only result we will see is the notice that the roll was made.)
+func (c *Connection) RollDiceToGMAndWait(ctx context.Context, rollspec string) ([]RollResultMessagePayload, error) {
+	return awaitReplies(ctx, c, func(id string) error {
+		return c.RollDiceToGMWithID(rollspec, id)
+	}, isLastRollResult)
+}
+
+func isLastRollResult(r RollResultMessagePayload) bool {
+	return !r.MoreResults
+}
+
+// QueryCoreDataAndWait is like QueryCoreDataWithID, but generates the
+// request ID itself, then waits for the server's reply and returns it.
+// If no entry was found, the reply's NoSuchEntry field is true.
+//
+// The reply is not sent to the channel subscribed to UpdateCoreData
+// messages.
+func (c *Connection) QueryCoreDataAndWait(ctx context.Context, itemType, code, name string) (UpdateCoreDataMessagePayload, error) {
+	replies, err := awaitReplies(ctx, c, func(id string) error {
+		return c.QueryCoreDataWithID(itemType, code, name, id)
+	}, func(UpdateCoreDataMessagePayload) bool { return true })
+	if err != nil {
+		return UpdateCoreDataMessagePayload{}, err
+	}
+	return replies[0], nil
+}
+
+// QueryCoreIndexAndWait is like QueryCoreIndexSinceWithID, but generates
+// the request ID itself, then collects the entries the server sends
+// (until it indicates that it is done) and returns them. If since is
+// the zero time, all matching entries are returned.
+//
+// The replies are not sent to the channel subscribed to UpdateCoreIndex
+// messages.
+func (c *Connection) QueryCoreIndexAndWait(ctx context.Context, itemType, codeRegex, nameRegex string, since time.Time) ([]UpdateCoreIndexMessagePayload, error) {
+	replies, err := awaitReplies(ctx, c, func(id string) error {
+		return c.QueryCoreIndexSinceWithID(itemType, codeRegex, nameRegex, since, id)
+	}, func(r UpdateCoreIndexMessagePayload) bool { return r.IsDone })
+	if len(replies) > 0 && replies[len(replies)-1].IsDone {
+		// the final message only marks the end of the list
+		replies = replies[:len(replies)-1]
+	}
+	return replies, err
+}
+
+// TimerRequestAndWait is like TimerRequest, but generates the request ID
+// itself, then waits for the GM to accept the request, returning their
+// acknowledgement. If the GM declines, the error is a RequestFailedError.
+//
+// The acknowledgement is not sent to the channel subscribed to
+// TimerAcknowledge messages.
+func (c *Connection) TimerRequestAndWait(ctx context.Context, description, expires string, targets []string, isRunning, showToAll bool) (TimerAcknowledgeMessagePayload, error) {
+	replies, err := awaitReplies(ctx, c, func(id string) error {
+		return c.TimerRequest(id, description, expires, targets, isRunning, showToAll)
+	}, func(TimerAcknowledgeMessagePayload) bool { return true })
+	if err != nil {
+		return TimerAcknowledgeMessagePayload{}, err
+	}
+	return replies[0], nil
+}
+
+// SaveMapAndWait is like SaveMapWithID, but generates the request ID
+// itself, then waits for the server to confirm that the map was saved,
+// returning the description of the saved map.
+func (c *Connection) SaveMapAndWait(ctx context.Context, name, location, comment string) (SavedMap, error) {
+	return awaitMapReply(ctx, c, func(id string) error {
+		return c.SaveMapWithID(name, location, comment, id)
+	})
+}
+
+// QueryMapsAndWait is like QueryMapsWithID, but generates the request ID
+// itself, then waits for the server's reply and returns the list of saved maps.
+//
+// The reply is not sent to the channel subscribed to UpdateMaps messages.
+func (c *Connection) QueryMapsAndWait(ctx context.Context) ([]SavedMap, error) {
+	replies, err := awaitReplies(ctx, c, func(id string) error {
+		return c.QueryMapsWithID(id)
+	}, func(UpdateMapsMessagePayload) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	return replies[0].Maps, nil
+}
+
+// LoadMapAndWait is like LoadMapWithID, but generates the request ID
+// itself, then waits for the server to confirm that the map was loaded,
+// returning the description of the loaded map.
+func (c *Connection) LoadMapAndWait(ctx context.Context, name string, merge bool) (SavedMap, error) {
+	return awaitMapReply(ctx, c, func(id string) error {
+		return c.LoadMapWithID(name, merge, id)
+	})
+}
+
+func awaitMapReply(ctx context.Context, c *Connection, send func(requestID string) error) (SavedMap, error) {
+	replies, err := awaitReplies(ctx, c, send, func(UpdateMapsMessagePayload) bool { return true })
+	if err != nil {
+		return SavedMap{}, err
+	}
+	if len(replies[0].Maps) == 0 {
+		return SavedMap{}, fmt.Errorf("server did not describe the map in its reply")
+	}
+	return replies[0].Maps[0], nil
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
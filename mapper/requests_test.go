@@ -0,0 +1,199 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Unit tests for requests which wait for the server's reply
+//
+
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveRequestTest plays the part of a server which answers die rolls,
+// core index queries, saved map queries, and timer requests, but never
+// answers core data queries.
+func serveRequestTest(t *testing.T, listener net.Listener) {
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Errorf("accept: %v", err)
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "PROTOCOL %d\n", MaximumSupportedMapProtocol)
+	fmt.Fprintf(conn, "OK {\"Protocol\":%d}\n", MaximumSupportedMapProtocol)
+	fmt.Fprintf(conn, "READY\n")
+
+	reply := func(command string, data map[string]any) {
+		b, _ := json.Marshal(data)
+		fmt.Fprintf(conn, "%s %s\n", command, b)
+	}
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		command, params, _ := strings.Cut(scanner.Text(), " ")
+		var request struct{ RequestID string }
+		json.Unmarshal([]byte(params), &request)
+		id := request.RequestID
+
+		switch command {
+		case "D":
+			reply("ROLL", map[string]any{"RequestID": "someone-else", "MessageID": 1, "Title": "other"})
+			reply("ROLL", map[string]any{"RequestID": id, "MessageID": 2, "Title": "first", "MoreResults": true})
+			reply("ROLL", map[string]any{"RequestID": id, "MessageID": 3, "Title": "second"})
+		case "COREIDX":
+			reply("COREIDX=", map[string]any{"RequestID": id, "N": 1, "Of": 2, "Code": "a", "Name": "Alpha"})
+			reply("COREIDX=", map[string]any{"RequestID": id, "N": 2, "Of": 2, "Code": "b", "Name": "Beta"})
+			reply("COREIDX=", map[string]any{"RequestID": id, "Of": 2, "IsDone": true})
+		case "MAP?":
+			reply("MAP=", map[string]any{"RequestID": id, "Maps": []map[string]any{
+				{"Name": "crypt", "Location": "Tomb of Horrors", "Timestamp": 42},
+				{"Name": "tavern"},
+			}})
+		case "TMRQ":
+			reply("FAILED", map[string]any{"RequestID": id, "Command": "TMRQ", "Reason": "not now", "IsDiscretionary": true})
+		}
+	}
+}
+
+func TestRequestsAndWait(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go serveRequestTest(t, listener)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan byte, 1)
+	rolls := make(chan MessagePayload, 10)
+	server, err := NewConnection(listener.Addr().String(),
+		WithContext(ctx),
+		WithLogger(nil),
+		WhenReady(ready),
+		WithSubscription(rolls, RollResult))
+	if err != nil {
+		t.Fatalf("NewConnection: %v", err)
+	}
+	go server.Dial()
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for client to sign on")
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+
+	results, err := server.RollDiceToAllAndWait(waitCtx, "d20")
+	if err != nil {
+		t.Fatalf("RollDiceToAllAndWait: %v", err)
+	}
+	if len(results) != 2 || results[0].Title != "first" || results[1].Title != "second" {
+		t.Errorf("expected results \"first\" and \"second\", got %v", results)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-rolls:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected all die rolls to be sent to the subscribed channel too; only saw %d", i)
+		}
+	}
+
+	entries, err := server.QueryCoreIndexAndWait(waitCtx, "monster", "", "", time.Time{})
+	if err != nil {
+		t.Fatalf("QueryCoreIndexAndWait: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "Alpha" || entries[1].Name != "Beta" {
+		t.Errorf("expected entries Alpha and Beta, got %v", entries)
+	}
+
+	maps, err := server.QueryMapsAndWait(waitCtx)
+	if err != nil {
+		t.Fatalf("QueryMapsAndWait: %v", err)
+	}
+	if len(maps) != 2 || maps[0].Name != "crypt" || maps[0].Location != "Tomb of Horrors" || maps[0].Timestamp != 42 || maps[1].Name != "tavern" {
+		t.Errorf("expected maps crypt and tavern, got %v", maps)
+	}
+
+	_, err = server.TimerRequestAndWait(waitCtx, "test", "1m", nil, true, true)
+	var failed RequestFailedError
+	if !errors.As(err, &failed) {
+		t.Errorf("expected TimerRequestAndWait to fail with RequestFailedError, got %v", err)
+	} else if failed.Reply.Reason != "not now" || !failed.Reply.IsDiscretionary {
+		t.Errorf("unexpected failure reply %v", failed.Reply)
+	}
+
+	server.RequestTimeout = 100 * time.Millisecond
+	if _, err = server.QueryCoreDataAndWait(waitCtx, "monster", "a", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected unanswered QueryCoreDataAndWait to time out, got %v", err)
+	}
+	if n := len(server.requests.waiting); n != 0 {
+		t.Errorf("expected no requests left waiting, found %d", n)
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,212 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+
+//
+// Unit tests for the TLS transport support
+//
+
+package mapper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert creates a certificate for the given common name, signed by
+// parent (or self-signed if parent is nil), and writes it and its key to
+// PEM files in dir.
+func writeTestCert(t *testing.T, dir, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("writing certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+	return cert, key, certFile, keyFile
+}
+
+// handshake connects a client and server over a pipe and returns the
+// user name the server sees from the client certificate, or an error
+// if the handshake failed.
+func handshake(clientConfig, serverConfig *tls.Config) (string, error) {
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	s.SetDeadline(time.Now().Add(5 * time.Second))
+	clientConfig.ServerName = "localhost"
+	client := tls.Client(c, clientConfig)
+	server := tls.Server(s, serverConfig)
+
+	clientErr := make(chan error, 1)
+	go func() {
+		clientErr <- client.Handshake()
+		// keep reading so the server can send us an alert if it
+		// rejects us after we think the handshake is finished
+		io.Copy(io.Discard, c)
+	}()
+	if err := server.Handshake(); err != nil {
+		return "", err
+	}
+	if err := <-clientErr; err != nil {
+		return "", err
+	}
+	return certificateUser(server), nil
+}
+
+func TestTLSClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caFile, _ := writeTestCert(t, dir, "test-ca", true, nil, nil)
+	_, _, serverCert, serverKey := writeTestCert(t, dir, "localhost", false, ca, caKey)
+	_, _, aliceCert, aliceKey := writeTestCert(t, dir, "alice", false, ca, caKey)
+
+	serverConfig, err := NewServerTLSConfig(serverCert, serverKey, caFile, false)
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig: %v", err)
+	}
+
+	anonConfig, err := NewClientTLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatalf("NewClientTLSConfig (anonymous): %v", err)
+	}
+	user, err := handshake(anonConfig, serverConfig)
+	if err != nil {
+		t.Fatalf("anonymous handshake: %v", err)
+	}
+	if user != "" {
+		t.Errorf("expected no certificate user for anonymous client, got \"%s\"", user)
+	}
+
+	aliceConfig, err := NewClientTLSConfig(caFile, aliceCert, aliceKey)
+	if err != nil {
+		t.Fatalf("NewClientTLSConfig (alice): %v", err)
+	}
+	user, err = handshake(aliceConfig, serverConfig)
+	if err != nil {
+		t.Fatalf("alice handshake: %v", err)
+	}
+	if user != "alice" {
+		t.Errorf("expected certificate user \"alice\", got \"%s\"", user)
+	}
+
+	requireConfig, err := NewServerTLSConfig(serverCert, serverKey, caFile, true)
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig (required): %v", err)
+	}
+	anonConfig, _ = NewClientTLSConfig(caFile, "", "")
+	if _, err := handshake(anonConfig, requireConfig); err == nil {
+		t.Errorf("expected handshake without client certificate to fail when one is required")
+	}
+
+	untrustedConfig, _ := NewClientTLSConfig("", "", "")
+	untrustedConfig.RootCAs = x509.NewCertPool()
+	if _, err := handshake(untrustedConfig, serverConfig); err == nil {
+		t.Errorf("expected handshake with untrusted server certificate to fail")
+	}
+
+	if _, err := NewServerTLSConfig(serverCert, serverKey, "", true); err == nil {
+		t.Errorf("expected error requiring client certificates without a CA file")
+	}
+	if _, err := NewClientTLSConfig(caFile, aliceCert, ""); err == nil {
+		t.Errorf("expected error giving client certificate without key")
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
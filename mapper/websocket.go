@@ -0,0 +1,180 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// WebSocket transport support for the mapper protocol.
+//
+
+package mapper
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketConn adapts a WebSocket connection so that it can be used
+// anywhere a mapper protocol stream is expected (e.g., by NewClientConnection).
+// Each protocol line is carried in its own WebSocket frame, without the
+// trailing newline, which makes it simple for a browser-based client to
+// send and receive messages.
+type WebSocketConn struct {
+	ws      *websocket.Conn
+	pending []byte // received data not yet read
+
+	wlock sync.Mutex
+	wbuf  []byte // partial line not yet sent
+}
+
+// NewWebSocketConn wraps a WebSocket connection (typically one accepted by
+// a websocket.Server) as a net.Conn carrying the mapper protocol.
+func NewWebSocketConn(ws *websocket.Conn) *WebSocketConn {
+	return &WebSocketConn{ws: ws}
+}
+
+// Read returns the next protocol data received from the client. Each
+// frame received is terminated with a newline if it didn't already have one.
+func (c *WebSocketConn) Read(b []byte) (int, error) {
+	for len(c.pending) == 0 {
+		var frame []byte
+		if err := websocket.Message.Receive(c.ws, &frame); err != nil {
+			return 0, err
+		}
+		if len(frame) == 0 || frame[len(frame)-1] != '\n' {
+			frame = append(frame, '\n')
+		}
+		c.pending = frame
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write sends each complete line of data as a separate frame. Any
+// partial line at the end is held until the rest of it is written.
+func (c *WebSocketConn) Write(b []byte) (int, error) {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+
+	c.wbuf = append(c.wbuf, b...)
+	for {
+		i := bytes.IndexByte(c.wbuf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := websocket.Message.Send(c.ws, string(c.wbuf[:i])); err != nil {
+			return 0, err
+		}
+		c.wbuf = c.wbuf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Close closes the WebSocket connection.
+func (c *WebSocketConn) Close() error {
+	return c.ws.Close()
+}
+
+// LocalAddr returns the local network address.
+func (c *WebSocketConn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+// RemoteAddr returns the address of the client. (The underlying
+// websocket.Conn reports the client's origin URL instead, which isn't
+// useful for logging which client is which.)
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	if r := c.ws.Request(); r != nil {
+		return webSocketAddr(r.RemoteAddr)
+	}
+	return c.ws.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines for the connection.
+func (c *WebSocketConn) SetDeadline(t time.Time) error {
+	return c.ws.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline for the connection.
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline for the connection.
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
+
+// ConnectionState returns the TLS state of the HTTPS connection over which
+// the WebSocket was established, so that client certificates can be
+// recognized just as they are for TLS connections over plain sockets.
+// If the connection wasn't made over TLS, the zero value is returned.
+func (c *WebSocketConn) ConnectionState() tls.ConnectionState {
+	if r := c.ws.Request(); r != nil && r.TLS != nil {
+		return *r.TLS
+	}
+	return tls.ConnectionState{}
+}
+
+type webSocketAddr string
+
+func (a webSocketAddr) Network() string { return "websocket" }
+func (a webSocketAddr) String() string  { return string(a) }
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
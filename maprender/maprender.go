@@ -0,0 +1,1310 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+////////////////////////////////////////////////////////////////////////////////////////
+//                                                                                    //
+//                                    MapRender                                       //
+//                                                                                    //
+// Draws the contents of GMA map files as SVG or PNG images without needing the Tk    //
+// mapper client.                                                                     //
+//                                                                                    //
+////////////////////////////////////////////////////////////////////////////////////////
+
+// Package maprender draws the objects from a GMA map file (as returned by
+// mapper.ReadMapFile) as an SVG document or PNG image, so that maps may be
+// viewed, printed, or posted without running the mapper client.
+//
+// A Renderer is created from the list of map objects along with any options
+// which control how they are drawn, such as whether the GM or player view
+// of the map is wanted, whether to overlay the 5-foot grid, and which region
+// of the map to include:
+//
+//	r, err := maprender.New(objects, maprender.WithGrid(""), maprender.WithScale(0.5))
+//	if err != nil {
+//	    ...
+//	}
+//	err = r.WritePNG(outputFile)
+//
+// Map elements are drawn in order of their Z coordinates, with creature tokens
+// on top of them. Since the mapper client's fonts and images may not be available,
+// text is drawn with the Go fonts in PNG images (SVG documents name the font family
+// given in the map file), and tiles whose images cannot be found are drawn as
+// placeholders showing their bounding boxes and image names.
+package maprender
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// GridSize is the size of one 5-foot grid square, in map pixel units.
+const GridSize = 50.0
+
+// Region is a rectangular area of the map, in map pixel units.
+type Region struct {
+	X, Y, Width, Height float64
+}
+
+// Renderer draws a set of map objects as an SVG document or a PNG image.
+// Create one with New.
+type Renderer struct {
+	gmView      bool
+	grid        bool
+	gridColor   string
+	background  string
+	region      *Region
+	scale       float64
+	margin      float64
+	threatZones bool
+	imageDir    string
+
+	items  []item
+	bounds Region
+	faces  map[faceKey]font.Face
+}
+
+// WithGMView draws the map as the GM sees it, including hidden elements
+// and creatures (which are drawn partially transparent). Otherwise they
+// are left off the map as they would be for the players, and creatures'
+// health is shown with the amount of blur set for them.
+func WithGMView() func(*Renderer) error {
+	return func(r *Renderer) error {
+		r.gmView = true
+		return nil
+	}
+}
+
+// WithGrid overlays the 5-foot grid on the map in the given color, or
+// in the default grid color if color is empty. The region drawn is extended
+// to the nearest grid lines.
+func WithGrid(color string) func(*Renderer) error {
+	return func(r *Renderer) error {
+		if color != "" {
+			if _, ok := parseColor(color); !ok {
+				return fmt.Errorf("invalid grid color \"%s\"", color)
+			}
+			r.gridColor = color
+		}
+		r.grid = true
+		return nil
+	}
+}
+
+// WithBackground fills the background of the map with the given color
+// instead of white. If color is empty, the background is left transparent.
+func WithBackground(color string) func(*Renderer) error {
+	return func(r *Renderer) error {
+		if color != "" {
+			if _, ok := parseColor(color); !ok {
+				return fmt.Errorf("invalid background color \"%s\"", color)
+			}
+		}
+		r.background = color
+		return nil
+	}
+}
+
+// WithRegion crops the map to the given region (in map pixel units) instead
+// of drawing everything on it.
+func WithRegion(region Region) func(*Renderer) error {
+	return func(r *Renderer) error {
+		if region.Width <= 0 || region.Height <= 0 {
+			return fmt.Errorf("region %gx%g has no area", region.Width, region.Height)
+		}
+		r.region = &region
+		return nil
+	}
+}
+
+// WithScale sets the number of output pixels per map pixel unit (by default, 1).
+func WithScale(scale float64) func(*Renderer) error {
+	return func(r *Renderer) error {
+		if scale <= 0 || math.IsInf(scale, 0) || math.IsNaN(scale) {
+			return fmt.Errorf("invalid scale %g", scale)
+		}
+		r.scale = scale
+		return nil
+	}
+}
+
+// WithMargin sets the space (in map pixel units) left around the objects on the map
+// when the region to be drawn is not given explicitly (by default, 10).
+func WithMargin(margin float64) func(*Renderer) error {
+	return func(r *Renderer) error {
+		if margin < 0 {
+			return fmt.Errorf("invalid margin %g", margin)
+		}
+		r.margin = margin
+		return nil
+	}
+}
+
+// WithThreatZones draws the areas each creature threatens: their natural reach,
+// the extended reach they have with a reach weapon, or both, depending on their
+// Reach attribute.
+func WithThreatZones() func(*Renderer) error {
+	return func(r *Renderer) error {
+		r.threatZones = true
+		return nil
+	}
+}
+
+// WithImageDirectory gives the directory in which to look for the image files
+// for map tiles. Local image files named with relative paths are found relative
+// to this directory, and images known by their server IDs are looked for there
+// under that ID with a .png, .gif, or .jpg suffix.
+func WithImageDirectory(dir string) func(*Renderer) error {
+	return func(r *Renderer) error {
+		r.imageDir = dir
+		return nil
+	}
+}
+
+// New creates a Renderer for the given map objects (as returned by mapper.ReadMapFile),
+// drawn according to the options given. These are WithGMView, WithGrid, WithBackground,
+// WithRegion, WithScale, WithMargin, WithThreatZones, and WithImageDirectory.
+//
+// Objects which are not drawn on the map (such as image and file definitions)
+// are ignored, except that image definitions are used to find the images for
+// tiles.
+func New(objects []any, options ...func(*Renderer) error) (*Renderer, error) {
+	r := &Renderer{
+		gridColor:  "#0000ff",
+		background: "white",
+		scale:      1.0,
+		margin:     10,
+		faces:      make(map[faceKey]font.Face),
+	}
+	for _, o := range options {
+		if err := o(r); err != nil {
+			return nil, err
+		}
+	}
+	r.build(objects)
+	r.bounds = r.visibleRegion()
+	return r, nil
+}
+
+// Region returns the region of the map which will be drawn.
+func (r *Renderer) Region() Region {
+	return r.bounds
+}
+
+// Size returns the width and height of the output image in pixels.
+func (r *Renderer) Size() (int, int) {
+	return int(math.Ceil(r.bounds.Width*r.scale - 1e-9)), int(math.Ceil(r.bounds.Height*r.scale - 1e-9))
+}
+
+//
+// The map objects are translated into a list of simple items which are
+// then drawn by the SVG and PNG back-ends. Shapes are all polygons or
+// polylines in map pixel units, with curves already flattened, so both
+// back-ends draw them the same way.
+//
+
+type itemKind byte
+
+const (
+	shapeItem itemKind = iota
+	textItem
+	imageItem
+)
+
+// The layers items are drawn in, from bottom to top.
+const (
+	elementLayer = iota
+	zoneLayer
+	creatureLayer
+)
+
+type item struct {
+	kind  itemKind
+	layer int
+	z     int
+
+	// shapes
+	points []mapper.Coordinates
+	closed bool
+	fill   string
+	stroke string
+	width  float64
+	dash   []float64
+
+	// fill and stroke opacity (0-1), which apply to text and images too
+	fillOpacity   float64
+	strokeOpacity float64
+
+	// text (drawn in fill color) and images
+	at     mapper.Coordinates
+	text   string
+	font   mapper.TextFont
+	anchor mapper.AnchorDirection
+	w, h   float64
+	img    image.Image
+}
+
+// fade makes an item partially transparent by the given factor.
+func (it item) fade(factor float64) item {
+	it.fillOpacity *= factor
+	it.strokeOpacity *= factor
+	return it
+}
+
+func (r *Renderer) build(objects []any) {
+	images := make(map[string]mapper.ImageDefinition)
+	type drawable struct {
+		layer int
+		z     int
+		id    string
+		obj   any
+	}
+	var drawables []drawable
+
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case mapper.ImageDefinition:
+			images[o.Name] = o
+		case mapper.ArcElement:
+			drawables = append(drawables, drawable{elementLayer, o.Z, o.ID, o})
+		case mapper.CircleElement:
+			drawables = append(drawables, drawable{elementLayer, o.Z, o.ID, o})
+		case mapper.LineElement:
+			drawables = append(drawables, drawable{elementLayer, o.Z, o.ID, o})
+		case mapper.PolygonElement:
+			drawables = append(drawables, drawable{elementLayer, o.Z, o.ID, o})
+		case mapper.RectangleElement:
+			drawables = append(drawables, drawable{elementLayer, o.Z, o.ID, o})
+		case mapper.SpellAreaOfEffectElement:
+			drawables = append(drawables, drawable{elementLayer, o.Z, o.ID, o})
+		case mapper.TextElement:
+			drawables = append(drawables, drawable{elementLayer, o.Z, o.ID, o})
+		case mapper.TileElement:
+			drawables = append(drawables, drawable{elementLayer, o.Z, o.ID, o})
+		case mapper.CreatureToken:
+			drawables = append(drawables, drawable{creatureLayer, 0, o.ID, o})
+		case mapper.PlayerToken:
+			drawables = append(drawables, drawable{creatureLayer, 0, o.ID, o.CreatureToken})
+		case mapper.MonsterToken:
+			drawables = append(drawables, drawable{creatureLayer, 0, o.ID, o.CreatureToken})
+		}
+	}
+
+	// Map files don't necessarily list objects in any particular order,
+	// so we make sure we draw them the same way every time.
+	sort.SliceStable(drawables, func(i, j int) bool {
+		if drawables[i].layer != drawables[j].layer {
+			return drawables[i].layer < drawables[j].layer
+		}
+		if drawables[i].z != drawables[j].z {
+			return drawables[i].z < drawables[j].z
+		}
+		return drawables[i].id < drawables[j].id
+	})
+
+	for _, d := range drawables {
+		switch o := d.obj.(type) {
+		case mapper.ArcElement:
+			r.addElement(o.MapElement, r.arcItems(o))
+		case mapper.CircleElement:
+			r.addElement(o.MapElement, r.circleItems(o))
+		case mapper.LineElement:
+			r.addElement(o.MapElement, r.lineItems(o))
+		case mapper.PolygonElement:
+			r.addElement(o.MapElement, r.polygonItems(o))
+		case mapper.RectangleElement:
+			r.addElement(o.MapElement, r.rectangleItems(o))
+		case mapper.SpellAreaOfEffectElement:
+			r.addElement(o.MapElement, r.spellAreaItems(o))
+		case mapper.TextElement:
+			r.addElement(o.MapElement, r.textItems(o))
+		case mapper.TileElement:
+			r.addElement(o.MapElement, r.tileItems(o, images))
+		case mapper.CreatureToken:
+			r.addCreature(o)
+		}
+	}
+
+	// creature zones go under all the creatures but above the map elements
+	sort.SliceStable(r.items, func(i, j int) bool {
+		return r.items[i].layer < r.items[j].layer
+	})
+}
+
+// addElement adds the items which draw a map element, unless it is hidden from view.
+func (r *Renderer) addElement(e mapper.MapElement, items []item) {
+	if e.Hidden && !r.gmView {
+		return
+	}
+	for _, it := range items {
+		it.layer = elementLayer
+		it.z = e.Z
+		if e.Hidden {
+			it = it.fade(0.5)
+		}
+		r.items = append(r.items, it)
+	}
+}
+
+// newShape creates a shape item with the usual outline and fill
+// colors of a map element.
+func newShape(e mapper.MapElement, points []mapper.Coordinates, closed bool) item {
+	it := item{
+		kind:          shapeItem,
+		points:        points,
+		closed:        closed,
+		stroke:        e.Line,
+		width:         elementWidth(e),
+		dash:          dashPattern(e.Dash, elementWidth(e)),
+		fillOpacity:   1,
+		strokeOpacity: 1,
+	}
+	if closed {
+		it.fill = e.Fill
+		it.fillOpacity = stippleOpacity(e.Stipple)
+	}
+	return it
+}
+
+func elementWidth(e mapper.MapElement) float64 {
+	if e.Width < 1 {
+		return 1
+	}
+	return float64(e.Width)
+}
+
+// dashPattern gives the lengths of the alternating dashes and gaps
+// for each of the dash types, which are proportional to the width
+// of the line.
+func dashPattern(d mapper.DashType, width float64) []float64 {
+	var pattern []float64
+	switch d {
+	case mapper.DashLong:
+		pattern = []float64{6, 4}
+	case mapper.DashMedium:
+		pattern = []float64{4, 4}
+	case mapper.DashShort:
+		pattern = []float64{2, 4}
+	case mapper.DashLongShort:
+		pattern = []float64{6, 4, 2, 4}
+	case mapper.DashLong2Short:
+		pattern = []float64{6, 4, 2, 4, 2, 4}
+	default:
+		return nil
+	}
+	if width < 2 {
+		width = 2
+	}
+	for i := range pattern {
+		pattern[i] *= width
+	}
+	return pattern
+}
+
+// stippleOpacity approximates the stipple patterns used by the mapper to
+// partially fill shapes with the corresponding amount of transparency.
+func stippleOpacity(stipple string) float64 {
+	switch stipple {
+	case "gray12":
+		return 0.125
+	case "gray25":
+		return 0.25
+	case "gray50":
+		return 0.5
+	case "gray75":
+		return 0.75
+	}
+	return 1
+}
+
+// corners returns the reference point and the first additional point of a map element,
+// which are the opposing corners of the rectangle which defines many of them. If the
+// element has no additional points, the rectangle has no area.
+func corners(e mapper.MapElement) (float64, float64, float64, float64) {
+	x1, y1 := e.X, e.Y
+	x2, y2 := x1, y1
+	if len(e.Points) > 0 {
+		x2, y2 = e.Points[0].X, e.Points[0].Y
+	}
+	return math.Min(x1, x2), math.Min(y1, y2), math.Max(x1, x2), math.Max(y1, y2)
+}
+
+// arcPoints flattens the part of the ellipse centered at (cx, cy) with the given
+// radii which starts at the given angle (in degrees counterclockwise from the
+// 3 o'clock position, as the mapper measures them) and runs for the given extent.
+func arcPoints(cx, cy, rx, ry, start, extent float64) []mapper.Coordinates {
+	steps := int(math.Ceil(math.Abs(extent) / 5))
+	if steps < 2 {
+		steps = 2
+	}
+	points := make([]mapper.Coordinates, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		theta := (start + extent*float64(i)/float64(steps)) * math.Pi / 180
+		points = append(points, mapper.Coordinates{X: cx + rx*math.Cos(theta), Y: cy - ry*math.Sin(theta)})
+	}
+	return points
+}
+
+func ellipsePoints(x1, y1, x2, y2 float64) []mapper.Coordinates {
+	points := arcPoints((x1+x2)/2, (y1+y2)/2, (x2-x1)/2, (y2-y1)/2, 0, 360)
+	return points[:len(points)-1]
+}
+
+func pieSlicePoints(cx, cy, rx, ry, start, extent float64) []mapper.Coordinates {
+	return append([]mapper.Coordinates{{X: cx, Y: cy}}, arcPoints(cx, cy, rx, ry, start, extent)...)
+}
+
+func rectanglePoints(x1, y1, x2, y2 float64) []mapper.Coordinates {
+	return []mapper.Coordinates{{X: x1, Y: y1}, {X: x2, Y: y1}, {X: x2, Y: y2}, {X: x1, Y: y2}}
+}
+
+func (r *Renderer) arcItems(o mapper.ArcElement) []item {
+	x1, y1, x2, y2 := corners(o.MapElement)
+	cx, cy, rx, ry := (x1+x2)/2, (y1+y2)/2, (x2-x1)/2, (y2-y1)/2
+	switch o.ArcMode {
+	case mapper.ArcModeArc:
+		return []item{newShape(o.MapElement, arcPoints(cx, cy, rx, ry, o.Start, o.Extent), false)}
+	case mapper.ArcModeChord:
+		return []item{newShape(o.MapElement, arcPoints(cx, cy, rx, ry, o.Start, o.Extent), true)}
+	default:
+		return []item{newShape(o.MapElement, pieSlicePoints(cx, cy, rx, ry, o.Start, o.Extent), true)}
+	}
+}
+
+func (r *Renderer) circleItems(o mapper.CircleElement) []item {
+	return []item{newShape(o.MapElement, ellipsePoints(corners(o.MapElement)), true)}
+}
+
+func (r *Renderer) rectangleItems(o mapper.RectangleElement) []item {
+	return []item{newShape(o.MapElement, rectanglePoints(corners(o.MapElement)), true)}
+}
+
+// Lines are drawn in their fill color, as the mapper does, with any
+// arrowheads added as separate filled triangles. The line is shortened
+// to end at the base of each arrowhead so its end doesn't show around
+// the arrow's point.
+func (r *Renderer) lineItems(o mapper.LineElement) []item {
+	points := append([]mapper.Coordinates{o.Coordinates}, o.Points...)
+	color := o.Fill
+	if color == "" {
+		color = o.Line
+	}
+	if color == "" {
+		color = "black"
+	}
+	line := newShape(o.MapElement, points, false)
+	line.stroke = color
+	items := []item{}
+
+	if len(points) > 1 {
+		width := elementWidth(o.MapElement)
+		arrowhead := func(tip, from mapper.Coordinates) (item, mapper.Coordinates) {
+			length, halfWidth := 10+width, 3+width/2
+			dx, dy := tip.X-from.X, tip.Y-from.Y
+			d := math.Hypot(dx, dy)
+			if d == 0 {
+				return item{}, tip
+			}
+			ux, uy := dx/d, dy/d
+			bx, by := tip.X-ux*length, tip.Y-uy*length
+			return item{
+				kind:   shapeItem,
+				closed: true,
+				points: []mapper.Coordinates{
+					tip,
+					{X: bx - uy*halfWidth, Y: by + ux*halfWidth},
+					{X: bx + uy*halfWidth, Y: by - ux*halfWidth},
+				},
+				fill:          color,
+				fillOpacity:   1,
+				strokeOpacity: 1,
+			}, mapper.Coordinates{
+				X: tip.X - ux*math.Min(length*0.8, d),
+				Y: tip.Y - uy*math.Min(length*0.8, d),
+			}
+		}
+
+		points = append([]mapper.Coordinates(nil), points...)
+		if o.Arrow == mapper.ArrowFirst || o.Arrow == mapper.ArrowBoth {
+			var head item
+			head, points[0] = arrowhead(points[0], points[1])
+			if head.points != nil {
+				items = append(items, head)
+			}
+		}
+		if o.Arrow == mapper.ArrowLast || o.Arrow == mapper.ArrowBoth {
+			var head item
+			n := len(points) - 1
+			head, points[n] = arrowhead(points[n], points[n-1])
+			if head.points != nil {
+				items = append(items, head)
+			}
+		}
+		line.points = points
+	}
+	return append([]item{line}, items...)
+}
+
+func (r *Renderer) polygonItems(o mapper.PolygonElement) []item {
+	points := append([]mapper.Coordinates{o.Coordinates}, o.Points...)
+	if o.Spline > 0 && len(points) > 2 {
+		points = smoothPolygon(points, int(math.Max(o.Spline, 2)))
+	}
+	return []item{newShape(o.MapElement, points, true)}
+}
+
+// smoothPolygon replaces the straight sides of a closed polygon with curves in
+// the same way Tk does for smoothed polygons: a quadratic Bézier curve is drawn
+// between the midpoints of each pair of adjacent sides, using the vertex between
+// them as the control point. Each curve is flattened into the given number of steps.
+func smoothPolygon(points []mapper.Coordinates, steps int) []mapper.Coordinates {
+	n := len(points)
+	mid := func(a, b mapper.Coordinates) mapper.Coordinates {
+		return mapper.Coordinates{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+	}
+	var smooth []mapper.Coordinates
+	for i := 0; i < n; i++ {
+		p0 := mid(points[(i+n-1)%n], points[i])
+		p1 := points[i]
+		p2 := mid(points[i], points[(i+1)%n])
+		for s := 0; s < steps; s++ {
+			t := float64(s) / float64(steps)
+			smooth = append(smooth, mapper.Coordinates{
+				X: (1-t)*(1-t)*p0.X + 2*(1-t)*t*p1.X + t*t*p2.X,
+				Y: (1-t)*(1-t)*p0.Y + 2*(1-t)*t*p1.Y + t*t*p2.Y,
+			})
+		}
+	}
+	return smooth
+}
+
+// Spell areas of effect are drawn as the shapes which bound them:
+// a cone is a 90° pie slice from the reference point (at its apex)
+// centered on the direction toward its additional point, a radius
+// is an ellipse, and a ray is a rectangle (as for CircleElement and
+// RectangleElement).
+func (r *Renderer) spellAreaItems(o mapper.SpellAreaOfEffectElement) []item {
+	switch o.AoEShape {
+	case mapper.AoEShapeCone:
+		if len(o.Points) == 0 {
+			return nil
+		}
+		dx, dy := o.Points[0].X-o.X, o.Points[0].Y-o.Y
+		radius := math.Hypot(dx, dy)
+		direction := math.Atan2(-dy, dx) * 180 / math.Pi
+		return []item{newShape(o.MapElement, pieSlicePoints(o.X, o.Y, radius, radius, direction-45, 90), true)}
+	case mapper.AoEShapeRadius:
+		return []item{newShape(o.MapElement, ellipsePoints(corners(o.MapElement)), true)}
+	default:
+		return []item{newShape(o.MapElement, rectanglePoints(corners(o.MapElement)), true)}
+	}
+}
+
+func (r *Renderer) textItems(o mapper.TextElement) []item {
+	color := o.Fill
+	if color == "" {
+		color = o.Line
+	}
+	if color == "" {
+		color = "black"
+	}
+	return []item{{
+		kind:          textItem,
+		at:            o.Coordinates,
+		text:          o.Text,
+		font:          o.Font,
+		anchor:        o.Anchor,
+		fill:          color,
+		fillOpacity:   1,
+		strokeOpacity: 1,
+	}}
+}
+
+// Tiles are drawn with their image at zoom level 1 (or the closest to it)
+// if it can be found, scaled to the tile's bounding box if it has one.
+// Otherwise a placeholder is drawn in its place.
+func (r *Renderer) tileItems(o mapper.TileElement, images map[string]mapper.ImageDefinition) []item {
+	if img, zoom, ok := r.loadImage(images[o.Image]); ok {
+		w, h := o.BBWidth, o.BBHeight
+		if w <= 0 || h <= 0 {
+			w, h = float64(img.Bounds().Dx())/zoom, float64(img.Bounds().Dy())/zoom
+		}
+		return []item{{
+			kind:          imageItem,
+			at:            o.Coordinates,
+			w:             w,
+			h:             h,
+			img:           img,
+			fillOpacity:   1,
+			strokeOpacity: 1,
+		}}
+	}
+
+	w, h := o.BBWidth, o.BBHeight
+	if w <= 0 || h <= 0 {
+		w, h = GridSize, GridSize
+	}
+	return []item{
+		{
+			kind:          shapeItem,
+			points:        rectanglePoints(o.X, o.Y, o.X+w, o.Y+h),
+			closed:        true,
+			stroke:        "gray50",
+			width:         1,
+			dash:          dashPattern(mapper.DashShort, 1),
+			fillOpacity:   1,
+			strokeOpacity: 1,
+		},
+		{
+			kind:          textItem,
+			at:            mapper.Coordinates{X: o.X + w/2, Y: o.Y + h/2},
+			text:          o.Image,
+			font:          mapper.TextFont{Family: "Helvetica", Size: -10},
+			fill:          "gray50",
+			fillOpacity:   1,
+			strokeOpacity: 1,
+		},
+	}
+}
+
+// loadImage finds and decodes the file for an image, returning the image
+// and the zoom level it was drawn for.
+func (r *Renderer) loadImage(def mapper.ImageDefinition) (image.Image, float64, bool) {
+	instances := append([]mapper.ImageInstance(nil), def.Sizes...)
+	sort.SliceStable(instances, func(i, j int) bool {
+		return math.Abs(instances[i].Zoom-1) < math.Abs(instances[j].Zoom-1)
+	})
+	for _, instance := range instances {
+		if instance.Zoom <= 0 {
+			continue
+		}
+		if len(instance.ImageData) > 0 {
+			if img, _, err := image.Decode(bytes.NewReader(instance.ImageData)); err == nil {
+				return img, instance.Zoom, true
+			}
+			continue
+		}
+
+		var paths []string
+		if instance.IsLocalFile {
+			if filepath.IsAbs(instance.File) || r.imageDir == "" {
+				paths = []string{instance.File}
+			} else {
+				paths = []string{filepath.Join(r.imageDir, instance.File)}
+			}
+		} else if r.imageDir != "" && instance.File != "" {
+			for _, suffix := range []string{".png", ".gif", ".jpg"} {
+				paths = append(paths, filepath.Join(r.imageDir, instance.File+suffix))
+			}
+		}
+		for _, path := range paths {
+			if img, err := decodeImageFile(path); err == nil {
+				return img, instance.Zoom, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+func decodeImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+//________________________________________________________________________________
+//   ____                _
+//  / ___|_ __ ___  __ _| |_ _   _ _ __ ___  ___
+// | |   | '__/ _ \/ _` | __| | | | '__/ _ \/ __|
+// | |___| | |  __/ (_| | |_| |_| | | |  __/\__ \
+//  \____|_|  \___|\__,_|\__|\__,_|_|  \___||___/
+//
+
+var sizeCodeRE = regexp.MustCompile(`^([fFdDtTsSmMlLhHgGcC])(\d+)?(?:->(\d+))?(?:=(\d+))?(?::(\*)?(.*))?$`)
+
+// creatureDimensions works out from a creature's size code how much space it
+// takes up and how far it can reach, in grid squares. This is normally given by
+// the tactical size category, where an upper-case code is a tall creature and
+// lower-case a wide one. The code may explicitly give the creature's natural reach
+// in feet after the category letter, its extended reach (as with a reach weapon)
+// after "->", and the space it takes up after "=", as in "L15->30=10".
+//
+// If the creature has a custom reach defined, that takes precedence.
+func creatureDimensions(c mapper.CreatureToken) (size, natural, extended float64) {
+	code := c.DispSize
+	if code == "" && c.Skin >= 0 && c.Skin < len(c.SkinSize) {
+		code = c.SkinSize[c.Skin]
+	}
+	if code == "" {
+		code = c.Size
+	}
+
+	size, natural = 1, 1
+	fields := sizeCodeRE.FindStringSubmatch(code)
+	if fields == nil {
+		if feet, err := strconv.ParseFloat(code, 64); err == nil && feet > 0 {
+			// deprecated size in feet
+			size = feet / 5
+		}
+		return size, natural, 2 * natural
+	}
+
+	switch fields[1] {
+	case "f", "F", "d", "D", "t", "T":
+		size, natural = 0.5, 0
+	case "s", "S", "m", "M":
+		size, natural = 1, 1
+	case "l":
+		size, natural = 2, 1
+	case "L":
+		size, natural = 2, 2
+	case "h":
+		size, natural = 3, 2
+	case "H":
+		size, natural = 3, 3
+	case "g":
+		size, natural = 4, 3
+	case "G":
+		size, natural = 4, 4
+	case "c":
+		size, natural = 6, 4
+	case "C":
+		size, natural = 6, 6
+	}
+	extended = 2 * natural
+	if natural == 0 {
+		extended = 1
+	}
+	if fields[2] != "" {
+		feet, _ := strconv.Atoi(fields[2])
+		natural = float64(feet) / 5
+	}
+	if fields[3] != "" {
+		feet, _ := strconv.Atoi(fields[3])
+		extended = float64(feet) / 5
+	}
+	if fields[4] != "" {
+		if feet, _ := strconv.Atoi(fields[4]); feet > 0 {
+			size = float64(feet) / 5
+		}
+	}
+	if c.CustomReach.Enabled {
+		natural, extended = float64(c.CustomReach.Natural), float64(c.CustomReach.Extended)
+	}
+	return
+}
+
+// healthRemaining returns the fraction (0-1) of the creature's hit points which remain,
+// blurred as the players would see them unless we're drawing the GM view, and whether
+// the creature is dead.
+func (r *Renderer) healthRemaining(h *mapper.CreatureHealth) (float64, bool) {
+	if h == nil || h.MaxHP <= 0 {
+		return 1, false
+	}
+	remaining := float64(h.MaxHP-h.LethalDamage) / float64(h.MaxHP)
+	dead := h.LethalDamage > h.MaxHP+h.Con || h.Condition == "dead"
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining > 1 {
+		remaining = 1
+	}
+	if h.HPBlur > 0 && !r.gmView && remaining > 0 {
+		blur := float64(h.HPBlur) / 100
+		remaining = math.Min(1, math.Ceil(remaining/blur)*blur)
+	}
+	return remaining, dead
+}
+
+// Creatures are drawn as circles within the space they occupy, outlined in blue
+// for players and red for monsters and filled with a tint of their Color. Their
+// names are written across them, with a health bar along the bottom, an X through
+// them if they are dead, and their elevation (and how they are moving, unless
+// walking) above them. Their threat zones (if requested) and any area of effect
+// around them are drawn beneath all the creatures.
+func (r *Renderer) addCreature(c mapper.CreatureToken) {
+	if c.Hidden && !r.gmView {
+		return
+	}
+	size, natural, extended := creatureDimensions(c)
+	x, y, s := c.Gx*GridSize, c.Gy*GridSize, size*GridSize
+	cx, cy := x+s/2, y+s/2
+	color := c.Color
+	if _, ok := parseColor(color); !ok {
+		color = "gray50"
+	}
+	var items []item
+
+	if r.threatZones {
+		zone := func(reach float64) {
+			if reach <= 0 {
+				return
+			}
+			d := reach * GridSize
+			items = append(items, item{
+				kind:          shapeItem,
+				layer:         zoneLayer,
+				points:        roundedRectanglePoints(x-d, y-d, x+s+d, y+s+d, d),
+				closed:        true,
+				fill:          color,
+				stroke:        color,
+				width:         2,
+				fillOpacity:   0.15,
+				strokeOpacity: 0.6,
+			})
+		}
+		switch c.Reach {
+		case 0:
+			zone(natural)
+		case 1:
+			zone(extended)
+		default:
+			zone(extended)
+			zone(natural)
+		}
+	}
+
+	if c.AoE != nil && c.AoE.Radius > 0 {
+		aoeColor := c.AoE.Color
+		if _, ok := parseColor(aoeColor); !ok {
+			aoeColor = color
+		}
+		rad := c.AoE.Radius
+		items = append(items, item{
+			kind:          shapeItem,
+			layer:         zoneLayer,
+			points:        ellipsePoints(cx-rad, cy-rad, cx+rad, cy+rad),
+			closed:        true,
+			fill:          aoeColor,
+			stroke:        aoeColor,
+			width:         2,
+			fillOpacity:   0.25,
+			strokeOpacity: 0.8,
+		})
+	}
+
+	outline := "black"
+	switch c.CreatureType {
+	case mapper.CreatureTypePlayer:
+		outline = "blue"
+	case mapper.CreatureTypeMonster:
+		outline = "red"
+	}
+	items = append(items, item{
+		kind:          shapeItem,
+		layer:         creatureLayer,
+		points:        ellipsePoints(x+1, y+1, x+s-1, y+s-1),
+		closed:        true,
+		fill:          color,
+		stroke:        outline,
+		width:         2,
+		fillOpacity:   0.35,
+		strokeOpacity: 1,
+	})
+
+	fontSize := math.Max(8, math.Min(14, s/4))
+	items = append(items, item{
+		kind:          textItem,
+		layer:         creatureLayer,
+		at:            mapper.Coordinates{X: cx, Y: cy},
+		text:          c.Name,
+		font:          mapper.TextFont{Family: "Helvetica", Size: -fontSize, Weight: mapper.FontWeightBold},
+		fill:          "black",
+		fillOpacity:   1,
+		strokeOpacity: 1,
+	})
+
+	remaining, dead := r.healthRemaining(c.Health)
+	if c.Health != nil && c.Health.MaxHP > 0 && !c.Killed && !dead {
+		barX, barY, barW, barH := x+s*0.1, y+s*0.85, s*0.8, math.Max(3, s*0.08)
+		barColor := "green"
+		if remaining <= 0.25 {
+			barColor = "red"
+		} else if remaining <= 0.5 {
+			barColor = "yellow"
+		}
+		bar := func(x1, x2 float64, color string, stroke bool) {
+			it := item{
+				kind:          shapeItem,
+				layer:         creatureLayer,
+				points:        rectanglePoints(x1, barY, x2, barY+barH),
+				closed:        true,
+				fill:          color,
+				fillOpacity:   1,
+				strokeOpacity: 1,
+			}
+			if stroke {
+				it.stroke, it.width = "black", 1
+			}
+			items = append(items, it)
+		}
+		bar(barX, barX+barW, "#404040", true)
+		if remaining > 0 {
+			bar(barX, barX+barW*remaining, barColor, false)
+			if c.Health.NonLethalDamage > 0 {
+				nonlethal := math.Min(remaining, float64(c.Health.NonLethalDamage)/float64(c.Health.MaxHP))
+				bar(barX+barW*(remaining-nonlethal), barX+barW*remaining, "#6060ff", false)
+			}
+		}
+	}
+
+	if c.Killed || dead {
+		for _, diagonal := range [][]mapper.Coordinates{
+			{{X: x + s*0.15, Y: y + s*0.15}, {X: x + s*0.85, Y: y + s*0.85}},
+			{{X: x + s*0.85, Y: y + s*0.15}, {X: x + s*0.15, Y: y + s*0.85}},
+		} {
+			items = append(items, item{
+				kind:          shapeItem,
+				layer:         creatureLayer,
+				points:        diagonal,
+				stroke:        "red",
+				width:         math.Max(2, s/15),
+				fillOpacity:   1,
+				strokeOpacity: 1,
+			})
+		}
+	}
+
+	var motion []string
+	switch c.MoveMode {
+	case mapper.MoveModeBurrow:
+		motion = append(motion, "burrow")
+	case mapper.MoveModeClimb:
+		motion = append(motion, "climb")
+	case mapper.MoveModeFly:
+		motion = append(motion, "fly")
+	case mapper.MoveModeSwim:
+		motion = append(motion, "swim")
+	}
+	if c.Elev != 0 {
+		motion = append(motion, fmt.Sprintf("%+d ft", c.Elev))
+	}
+	if len(motion) > 0 {
+		items = append(items, item{
+			kind:          textItem,
+			layer:         creatureLayer,
+			at:            mapper.Coordinates{X: cx, Y: y},
+			text:          strings.Join(motion, " "),
+			font:          mapper.TextFont{Family: "Helvetica", Size: -math.Max(8, fontSize*0.8)},
+			anchor:        mapper.AnchorSouth,
+			fill:          "black",
+			fillOpacity:   1,
+			strokeOpacity: 1,
+		})
+	}
+
+	for _, it := range items {
+		if c.Dim {
+			it = it.fade(0.5)
+		}
+		if c.Hidden {
+			it = it.fade(0.5)
+		}
+		r.items = append(r.items, it)
+	}
+}
+
+// roundedRectanglePoints outlines a rectangle whose corners are rounded with the given radius.
+func roundedRectanglePoints(x1, y1, x2, y2, radius float64) []mapper.Coordinates {
+	var points []mapper.Coordinates
+	points = append(points, arcPoints(x2-radius, y1+radius, radius, radius, 0, 90)...)
+	points = append(points, arcPoints(x1+radius, y1+radius, radius, radius, 90, 90)...)
+	points = append(points, arcPoints(x1+radius, y2-radius, radius, radius, 180, 90)...)
+	points = append(points, arcPoints(x2-radius, y2-radius, radius, radius, 270, 90)...)
+	return points
+}
+
+//________________________________________________________________________________
+//  _____         _
+// |_   _|____  _| |_
+//   | |/ _ \ \/ / __|
+//   | |  __/>  <| |_
+//   |_|\___/_/\_\\__|
+//
+
+type faceKey struct {
+	bold, italic bool
+	size         float64
+}
+
+// fontPixels gives the size of a font in pixels. As with Tk, positive
+// sizes are in points and negative sizes in pixels.
+func fontPixels(f mapper.TextFont) float64 {
+	switch {
+	case f.Size < 0:
+		return -f.Size
+	case f.Size > 0:
+		return f.Size * 4 / 3
+	default:
+		return 13
+	}
+}
+
+// face returns a Go font face matching the weight and slant of a map font,
+// at the given scale. We use these to draw text in PNG images and to
+// measure it.
+func (r *Renderer) face(f mapper.TextFont, scale float64) font.Face {
+	key := faceKey{
+		bold:   f.Weight == mapper.FontWeightBold,
+		italic: f.Slant == mapper.FontSlantItalic,
+		size:   math.Round(fontPixels(f)*scale*4) / 4,
+	}
+	if key.size < 1 {
+		key.size = 1
+	}
+	if face, ok := r.faces[key]; ok {
+		return face
+	}
+	ttf := goregular.TTF
+	switch {
+	case key.bold && key.italic:
+		ttf = gobolditalic.TTF
+	case key.bold:
+		ttf = gobold.TTF
+	case key.italic:
+		ttf = goitalic.TTF
+	}
+	// The Go fonts are built in, so these can't fail.
+	parsed, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: key.size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		panic(err)
+	}
+	r.faces[key] = face
+	return face
+}
+
+// textLine is a line of text positioned on the map, with X being the
+// point it is aligned to (as given by align) and Y its baseline.
+type textLine struct {
+	text  string
+	x, y  float64
+	width float64
+	align int // -1 left, 0 center, 1 right
+}
+
+// layoutText splits a text item into lines and works out where each goes,
+// relative to its anchor point, in map pixel units.
+func (r *Renderer) layoutText(it item) []textLine {
+	face := r.face(it.font, 1)
+	metrics := face.Metrics()
+	ascent := fixedToFloat(metrics.Ascent)
+	lineHeight := fixedToFloat(metrics.Height)
+	lines := strings.Split(it.text, "\n")
+	height := lineHeight * float64(len(lines))
+
+	align := 0
+	switch it.anchor {
+	case mapper.AnchorWest, mapper.AnchorNW, mapper.AnchorSW:
+		align = -1
+	case mapper.AnchorEast, mapper.AnchorNE, mapper.AnchorSE:
+		align = 1
+	}
+	top := it.at.Y - height/2
+	switch it.anchor {
+	case mapper.AnchorNorth, mapper.AnchorNE, mapper.AnchorNW:
+		top = it.at.Y
+	case mapper.AnchorSouth, mapper.AnchorSE, mapper.AnchorSW:
+		top = it.at.Y - height
+	}
+
+	var layout []textLine
+	for i, line := range lines {
+		layout = append(layout, textLine{
+			text:  line,
+			x:     it.at.X,
+			y:     top + ascent + float64(i)*lineHeight,
+			width: fixedToFloat(font.MeasureString(face, line)),
+			align: align,
+		})
+	}
+	return layout
+}
+
+func fixedToFloat(f fixed.Int26_6) float64 {
+	return float64(f) / 64
+}
+
+//________________________________________________________________________________
+//  ____                        _
+// | __ )  ___  _   _ _ __   __| |___
+// |  _ \ / _ \| | | | '_ \ / _` / __|
+// | |_) | (_) | |_| | | | | (_| \__ \
+// |____/ \___/ \__,_|_| |_|\__,_|___/
+//
+
+// itemBounds returns the area of the map covered by an item.
+func (r *Renderer) itemBounds(it item) (x1, y1, x2, y2 float64) {
+	x1, y1, x2, y2 = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	extend := func(x, y float64) {
+		x1, y1, x2, y2 = math.Min(x1, x), math.Min(y1, y), math.Max(x2, x), math.Max(y2, y)
+	}
+	switch it.kind {
+	case shapeItem:
+		pad := 0.0
+		if it.stroke != "" {
+			pad = it.width / 2
+		}
+		for _, p := range it.points {
+			extend(p.X-pad, p.Y-pad)
+			extend(p.X+pad, p.Y+pad)
+		}
+	case textItem:
+		metrics := r.face(it.font, 1).Metrics()
+		for _, line := range r.layoutText(it) {
+			left := line.x - line.width*float64(line.align+1)/2
+			extend(left, line.y-fixedToFloat(metrics.Ascent))
+			extend(left+line.width, line.y+fixedToFloat(metrics.Descent))
+		}
+	case imageItem:
+		extend(it.at.X, it.at.Y)
+		extend(it.at.X+it.w, it.at.Y+it.h)
+	}
+	return
+}
+
+// visibleRegion works out the region of the map to draw.
+func (r *Renderer) visibleRegion() Region {
+	if r.region != nil {
+		return *r.region
+	}
+	x1, y1, x2, y2 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, it := range r.items {
+		ix1, iy1, ix2, iy2 := r.itemBounds(it)
+		x1, y1, x2, y2 = math.Min(x1, ix1), math.Min(y1, iy1), math.Max(x2, ix2), math.Max(y2, iy2)
+	}
+	if math.IsInf(x1, 0) || math.IsInf(y1, 0) {
+		x1, y1, x2, y2 = 0, 0, GridSize, GridSize
+	}
+	x1, y1, x2, y2 = x1-r.margin, y1-r.margin, x2+r.margin, y2+r.margin
+	if r.grid {
+		x1, y1 = math.Floor(x1/GridSize)*GridSize, math.Floor(y1/GridSize)*GridSize
+		x2, y2 = math.Ceil(x2/GridSize)*GridSize, math.Ceil(y2/GridSize)*GridSize
+	}
+	return Region{X: x1, Y: y1, Width: x2 - x1, Height: y2 - y1}
+}
+
+// gridItems returns the lines of the grid overlay across the visible region.
+func (r *Renderer) gridItems() []item {
+	if !r.grid {
+		return nil
+	}
+	var items []item
+	line := func(x1, y1, x2, y2 float64) {
+		items = append(items, item{
+			kind:          shapeItem,
+			points:        []mapper.Coordinates{{X: x1, Y: y1}, {X: x2, Y: y2}},
+			stroke:        r.gridColor,
+			width:         1,
+			fillOpacity:   1,
+			strokeOpacity: 0.4,
+		})
+	}
+	b := r.bounds
+	for x := math.Ceil(b.X/GridSize) * GridSize; x <= b.X+b.Width; x += GridSize {
+		line(x, b.Y, x, b.Y+b.Height)
+	}
+	for y := math.Ceil(b.Y/GridSize) * GridSize; y <= b.Y+b.Height; y += GridSize {
+		line(b.X, y, b.X+b.Width, y)
+	}
+	return items
+}
+
+// drawList returns all the items to draw, in order, with the grid drawn over
+// the map elements but beneath the creatures.
+func (r *Renderer) drawList() []item {
+	var list []item
+	gridDone := false
+	for _, it := range r.items {
+		if !gridDone && it.layer > elementLayer {
+			list = append(list, r.gridItems()...)
+			gridDone = true
+		}
+		list = append(list, it)
+	}
+	if !gridDone {
+		list = append(list, r.gridItems()...)
+	}
+	return list
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
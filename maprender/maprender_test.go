@@ -0,0 +1,265 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for drawing map files
+//
+
+package maprender
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+func testRect(id string, x1, y1, x2, y2 float64, fill string) mapper.RectangleElement {
+	var r mapper.RectangleElement
+	r.ID = id
+	r.X, r.Y = x1, y1
+	r.Points = []mapper.Coordinates{{X: x2, Y: y2}}
+	r.Fill = fill
+	return r
+}
+
+func testText(id string, x, y float64, text string, hidden bool) mapper.TextElement {
+	var t mapper.TextElement
+	t.ID = id
+	t.X, t.Y = x, y
+	t.Text = text
+	t.Hidden = hidden
+	t.Font = mapper.TextFont{Family: "Times", Size: 12}
+	return t
+}
+
+func TestParseColor(t *testing.T) {
+	for i, test := range []struct {
+		name  string
+		color color.NRGBA
+		ok    bool
+	}{
+		{"red", color.NRGBA{0xff, 0, 0, 0xff}, true},
+		{"Light Blue", color.NRGBA{0xad, 0xd8, 0xe6, 0xff}, true},
+		{"#f80", color.NRGBA{0xff, 0x88, 0, 0xff}, true},
+		{"#336699", color.NRGBA{0x33, 0x66, 0x99, 0xff}, true},
+		{"#ffff00000000", color.NRGBA{0xff, 0, 0, 0xff}, true},
+		{"gray50", color.NRGBA{0x80, 0x80, 0x80, 0xff}, true},
+		{"grey100", color.NRGBA{0xff, 0xff, 0xff, 0xff}, true},
+		{"gray101", color.NRGBA{}, false},
+		{"#12345", color.NRGBA{}, false},
+		{"#xyz", color.NRGBA{}, false},
+		{"octarine", color.NRGBA{}, false},
+		{"", color.NRGBA{}, false},
+	} {
+		c, ok := parseColor(test.name)
+		if ok != test.ok || c != test.color {
+			t.Errorf("test %d: parseColor(%q) = %v, %v; expected %v, %v", i, test.name, c, ok, test.color, test.ok)
+		}
+	}
+}
+
+func TestCreatureDimensions(t *testing.T) {
+	for i, test := range []struct {
+		creature                mapper.CreatureToken
+		size, natural, extended float64
+	}{
+		{mapper.CreatureToken{Size: "M"}, 1, 1, 2},
+		{mapper.CreatureToken{Size: "L"}, 2, 2, 4},
+		{mapper.CreatureToken{Size: "l"}, 2, 1, 2},
+		{mapper.CreatureToken{Size: "T"}, 0.5, 0, 1},
+		{mapper.CreatureToken{SkinSize: []string{"M", "H"}, Skin: 1}, 3, 3, 6},
+		{mapper.CreatureToken{Size: "M", DispSize: "L"}, 2, 2, 4},
+		{mapper.CreatureToken{Size: "M15->25=10"}, 2, 3, 5},
+		{mapper.CreatureToken{Size: "M", CustomReach: mapper.CreatureCustomReach{Enabled: true, Natural: 2, Extended: 3}}, 1, 2, 3},
+		{mapper.CreatureToken{Size: "15"}, 3, 1, 2},
+	} {
+		size, natural, extended := creatureDimensions(test.creature)
+		if size != test.size || natural != test.natural || extended != test.extended {
+			t.Errorf("test %d: got %g, %g, %g; expected %g, %g, %g", i, size, natural, extended, test.size, test.natural, test.extended)
+		}
+	}
+}
+
+func TestDashPolyline(t *testing.T) {
+	dashes := dashPolyline([]mapper.Coordinates{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}, []float64{4, 2})
+	expected := [][]mapper.Coordinates{
+		{{X: 0, Y: 0}, {X: 4, Y: 0}},
+		{{X: 6, Y: 0}, {X: 10, Y: 0}},
+		{{X: 10, Y: 2}, {X: 10, Y: 6}},
+		{{X: 10, Y: 8}, {X: 10, Y: 10}},
+	}
+	if len(dashes) != len(expected) {
+		t.Fatalf("got %d dashes %v; expected %v", len(dashes), dashes, expected)
+	}
+	for i, dash := range dashes {
+		if len(dash) != len(expected[i]) {
+			t.Errorf("dash %d is %v; expected %v", i, dash, expected[i])
+			continue
+		}
+		for j := range dash {
+			if dash[j] != expected[i][j] {
+				t.Errorf("dash %d is %v; expected %v", i, dash, expected[i])
+				break
+			}
+		}
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	objects := []any{
+		testRect("r1", 0, 0, 100, 50, "red"),
+		testText("t1", 50, 25, "Room <1>", false),
+		testText("t2", 50, 40, "secret door", true),
+		mapper.CreatureToken{BaseMapObject: mapper.BaseMapObject{ID: "c1"}, Name: "Fred", Size: "M", Gx: 0, Gy: 0, Color: "blue"},
+		mapper.CreatureToken{BaseMapObject: mapper.BaseMapObject{ID: "c2"}, Name: "Lurker", Size: "M", Gx: 1, Gy: 0, Hidden: true},
+	}
+
+	for _, gm := range []bool{false, true} {
+		options := []func(*Renderer) error{WithMargin(0)}
+		if gm {
+			options = append(options, WithGMView())
+		}
+		r, err := New(objects, options...)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		var out bytes.Buffer
+		if err := r.WriteSVG(&out); err != nil {
+			t.Fatalf("WriteSVG: %v", err)
+		}
+		svg := out.String()
+		if !strings.HasPrefix(svg, "<?xml") || !strings.HasSuffix(svg, "</svg>\n") {
+			t.Errorf("not an SVG document: %s", svg)
+		}
+		if !strings.Contains(svg, `viewBox="0 0 100 50"`) {
+			t.Errorf("gm=%v: wrong viewBox in %s", gm, svg)
+		}
+		if !strings.Contains(svg, `<polygon points="0,0 100,0 100,50 0,50" fill="#ff0000"`) {
+			t.Errorf("gm=%v: rectangle not drawn in %s", gm, svg)
+		}
+		if !strings.Contains(svg, ">Room &lt;1&gt;</text>") || !strings.Contains(svg, ">Fred</text>") {
+			t.Errorf("gm=%v: text not drawn in %s", gm, svg)
+		}
+		if hidden := strings.Contains(svg, "secret door") || strings.Contains(svg, "Lurker"); hidden != gm {
+			t.Errorf("gm=%v: hidden objects drawn=%v in %s", gm, hidden, svg)
+		}
+	}
+
+	r, err := New(objects, WithRegion(Region{X: 10, Y: 20, Width: 30, Height: 40}), WithScale(2))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var out bytes.Buffer
+	if err := r.WriteSVG(&out); err != nil {
+		t.Fatalf("WriteSVG: %v", err)
+	}
+	if !strings.Contains(out.String(), `width="60" height="80" viewBox="10 20 30 40"`) {
+		t.Errorf("cropped map has wrong size in %s", out.String())
+	}
+
+	if _, err := New(objects, WithRegion(Region{Width: 0, Height: 10})); err == nil {
+		t.Errorf("empty region was accepted")
+	}
+	if _, err := New(objects, WithScale(-1)); err == nil {
+		t.Errorf("negative scale was accepted")
+	}
+	if _, err := New(objects, WithGrid("octarine")); err == nil {
+		t.Errorf("invalid grid color was accepted")
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	objects := []any{
+		testRect("r1", 0, 0, 100, 50, "red"),
+		testRect("r2", 50, 0, 100, 50, "blue"),
+	}
+	r, err := New(objects, WithMargin(10), WithScale(0.5))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if w, h := r.Size(); w != 60 || h != 35 {
+		t.Errorf("image is %dx%d; expected 60x35", w, h)
+	}
+
+	var out bytes.Buffer
+	if err := r.WritePNG(&out); err != nil {
+		t.Fatalf("WritePNG: %v", err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatalf("unable to read PNG image back: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 60 || b.Dy() != 35 {
+		t.Errorf("image is %v; expected 60x35", b)
+	}
+	for _, test := range []struct {
+		x, y  int
+		color color.RGBA
+	}{
+		{2, 2, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{20, 17, color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{45, 17, color.RGBA{0x00, 0x00, 0xff, 0xff}},
+	} {
+		if c := color.RGBAModel.Convert(img.At(test.x, test.y)).(color.RGBA); c != test.color {
+			t.Errorf("pixel (%d, %d) is %v; expected %v", test.x, test.y, c, test.color)
+		}
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,273 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Drawing the map as a raster image.
+//
+
+package maprender
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// WritePNG writes the map to w as a PNG image.
+func (r *Renderer) WritePNG(w io.Writer) error {
+	return png.Encode(w, r.Image())
+}
+
+// Image draws the map as a raster image.
+func (r *Renderer) Image() *image.RGBA {
+	width, height := r.Size()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if r.background != "" {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(rgbaColor(r.background, 1)), image.Point{}, draw.Src)
+	}
+	for _, it := range r.drawList() {
+		r.drawItem(dst, it)
+	}
+	return dst
+}
+
+// toImage converts a point in map pixel units to image coordinates.
+func (r *Renderer) toImage(p mapper.Coordinates) (float64, float64) {
+	return (p.X - r.bounds.X) * r.scale, (p.Y - r.bounds.Y) * r.scale
+}
+
+func (r *Renderer) drawItem(dst *image.RGBA, it item) {
+	switch it.kind {
+	case shapeItem:
+		if len(it.points) == 0 {
+			return
+		}
+		if it.closed && it.fill != "" {
+			z := vector.NewRasterizer(dst.Bounds().Dx(), dst.Bounds().Dy())
+			r.addPolygon(z, it.points)
+			z.Draw(dst, dst.Bounds(), image.NewUniform(rgbaColor(it.fill, it.fillOpacity)), image.Point{})
+		}
+		if it.stroke != "" {
+			points := it.points
+			if it.closed {
+				points = append(append([]mapper.Coordinates(nil), points...), points[0])
+			}
+			z := vector.NewRasterizer(dst.Bounds().Dx(), dst.Bounds().Dy())
+			width := math.Max(1, it.width*r.scale)
+			for _, dash := range dashPolyline(points, it.dash) {
+				r.addStroke(z, dash, width)
+			}
+			z.Draw(dst, dst.Bounds(), image.NewUniform(rgbaColor(it.stroke, it.strokeOpacity)), image.Point{})
+		}
+
+	case textItem:
+		face := r.face(it.font, r.scale)
+		d := font.Drawer{
+			Dst:  dst,
+			Src:  image.NewUniform(rgbaColor(it.fill, it.fillOpacity)),
+			Face: face,
+		}
+		for _, line := range r.layoutText(it) {
+			x, y := r.toImage(mapper.Coordinates{X: line.x, Y: line.y})
+			width := fixedToFloat(d.MeasureString(line.text))
+			x -= width * float64(line.align+1) / 2
+			d.Dot = fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)}
+			d.DrawString(line.text)
+		}
+
+	case imageItem:
+		x1, y1 := r.toImage(it.at)
+		x2, y2 := r.toImage(mapper.Coordinates{X: it.at.X + it.w, Y: it.at.Y + it.h})
+		target := image.Rect(int(math.Round(x1)), int(math.Round(y1)), int(math.Round(x2)), int(math.Round(y2)))
+		var options *xdraw.Options
+		if it.fillOpacity < 1 {
+			options = &xdraw.Options{DstMask: image.NewUniform(color.Alpha{uint8(math.Round(it.fillOpacity * 255))})}
+		}
+		xdraw.CatmullRom.Scale(dst, target, it.img, it.img.Bounds(), xdraw.Over, options)
+	}
+}
+
+// addPolygon adds a closed polygon (in map coordinates) to the rasterizer.
+func (r *Renderer) addPolygon(z *vector.Rasterizer, points []mapper.Coordinates) {
+	for i, p := range points {
+		x, y := r.toImage(p)
+		if i == 0 {
+			z.MoveTo(float32(x), float32(y))
+		} else {
+			z.LineTo(float32(x), float32(y))
+		}
+	}
+	z.ClosePath()
+}
+
+// addStroke adds the outline of a line of the given width (in image pixels)
+// drawn along a polyline, with round joins and ends, to the rasterizer.
+//
+// The rasterizer accumulates the areas of overlapping polygons with their
+// signs given by the direction they wind in, so we make sure all the pieces
+// of the line wind the same way so they don't cancel each other out where
+// they overlap.
+func (r *Renderer) addStroke(z *vector.Rasterizer, points []mapper.Coordinates, width float64) {
+	half := width / 2
+	var pixels [][2]float64
+	for _, p := range points {
+		x, y := r.toImage(p)
+		pixels = append(pixels, [2]float64{x, y})
+	}
+	add := func(poly [][2]float64) {
+		area := 0.0
+		for i := range poly {
+			j := (i + 1) % len(poly)
+			area += poly[i][0]*poly[j][1] - poly[j][0]*poly[i][1]
+		}
+		if area < 0 {
+			for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+				poly[i], poly[j] = poly[j], poly[i]
+			}
+		}
+		z.MoveTo(float32(poly[0][0]), float32(poly[0][1]))
+		for _, p := range poly[1:] {
+			z.LineTo(float32(p[0]), float32(p[1]))
+		}
+		z.ClosePath()
+	}
+	dot := func(p [2]float64) {
+		var circle [][2]float64
+		steps := int(math.Max(8, math.Ceil(half*2)))
+		for i := 0; i < steps; i++ {
+			theta := 2 * math.Pi * float64(i) / float64(steps)
+			circle = append(circle, [2]float64{p[0] + half*math.Cos(theta), p[1] + half*math.Sin(theta)})
+		}
+		add(circle)
+	}
+
+	for i, p := range pixels {
+		if half > 0.75 {
+			dot(p)
+		}
+		if i == 0 {
+			continue
+		}
+		q := pixels[i-1]
+		dx, dy := p[0]-q[0], p[1]-q[1]
+		d := math.Hypot(dx, dy)
+		if d == 0 {
+			continue
+		}
+		nx, ny := -dy/d*half, dx/d*half
+		add([][2]float64{
+			{q[0] + nx, q[1] + ny},
+			{p[0] + nx, p[1] + ny},
+			{p[0] - nx, p[1] - ny},
+			{q[0] - nx, q[1] - ny},
+		})
+	}
+}
+
+// dashPolyline breaks a polyline into the dashes to be drawn for the given dash
+// pattern (alternating lengths of dashes and gaps). If the pattern is empty,
+// the whole line is returned as a single dash.
+func dashPolyline(points []mapper.Coordinates, pattern []float64) [][]mapper.Coordinates {
+	if len(pattern) == 0 || len(points) < 2 {
+		return [][]mapper.Coordinates{points}
+	}
+	var dashes [][]mapper.Coordinates
+	current := []mapper.Coordinates{points[0]}
+	index, left, drawing := 0, pattern[0], true
+
+	for i := 1; i < len(points); i++ {
+		from, to := points[i-1], points[i]
+		length := math.Hypot(to.X-from.X, to.Y-from.Y)
+		pos := 0.0
+		for length-pos > left {
+			pos += left
+			at := mapper.Coordinates{
+				X: from.X + (to.X-from.X)*pos/length,
+				Y: from.Y + (to.Y-from.Y)*pos/length,
+			}
+			if drawing {
+				if current[len(current)-1] != at {
+					current = append(current, at)
+				}
+				dashes = append(dashes, current)
+				current = nil
+			} else {
+				current = []mapper.Coordinates{at}
+			}
+			drawing = !drawing
+			index = (index + 1) % len(pattern)
+			left = pattern[index]
+		}
+		left -= length - pos
+		if drawing && current[len(current)-1] != to {
+			current = append(current, to)
+		}
+	}
+	if drawing && len(current) > 1 {
+		dashes = append(dashes, current)
+	}
+	return dashes
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -0,0 +1,203 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Writing the map as an SVG document.
+//
+
+package maprender
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+// WriteSVG writes the map to w as an SVG document. The document's
+// coordinates are the map's own pixel units, so it may be scaled
+// further without loss of quality.
+func (r *Renderer) WriteSVG(w io.Writer) error {
+	width, height := r.Size()
+	b := r.bounds
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%s %s %s %s">`+"\n",
+		width, height, svgNumber(b.X), svgNumber(b.Y), svgNumber(b.Width), svgNumber(b.Height))
+	if r.background != "" {
+		fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+			svgNumber(b.X), svgNumber(b.Y), svgNumber(b.Width), svgNumber(b.Height), svgColor(r.background))
+	}
+	for _, it := range r.drawList() {
+		if err := r.writeSVGItem(out, it); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(out, "</svg>")
+	return out.Flush()
+}
+
+func (r *Renderer) writeSVGItem(out *bufio.Writer, it item) error {
+	switch it.kind {
+	case shapeItem:
+		if len(it.points) == 0 {
+			return nil
+		}
+		var points []string
+		for _, p := range it.points {
+			points = append(points, svgNumber(p.X)+","+svgNumber(p.Y))
+		}
+		element := "polyline"
+		if it.closed {
+			element = "polygon"
+		}
+		fmt.Fprintf(out, `<%s points="%s"`, element, strings.Join(points, " "))
+		if it.closed && it.fill != "" {
+			fmt.Fprintf(out, ` fill="%s"`, svgColor(it.fill))
+			if it.fillOpacity < 1 {
+				fmt.Fprintf(out, ` fill-opacity="%s"`, svgNumber(it.fillOpacity))
+			}
+		} else {
+			fmt.Fprint(out, ` fill="none"`)
+		}
+		if it.stroke != "" {
+			fmt.Fprintf(out, ` stroke="%s" stroke-width="%s" stroke-linejoin="round" stroke-linecap="round"`,
+				svgColor(it.stroke), svgNumber(it.width))
+			if it.strokeOpacity < 1 {
+				fmt.Fprintf(out, ` stroke-opacity="%s"`, svgNumber(it.strokeOpacity))
+			}
+			if len(it.dash) > 0 {
+				var dashes []string
+				for _, d := range it.dash {
+					dashes = append(dashes, svgNumber(d))
+				}
+				fmt.Fprintf(out, ` stroke-dasharray="%s"`, strings.Join(dashes, " "))
+			}
+		}
+		fmt.Fprintln(out, "/>")
+
+	case textItem:
+		family := it.font.Family
+		if family == "" {
+			family = "Helvetica"
+		}
+		for _, line := range r.layoutText(it) {
+			anchor := "middle"
+			switch line.align {
+			case -1:
+				anchor = "start"
+			case 1:
+				anchor = "end"
+			}
+			fmt.Fprintf(out, `<text x="%s" y="%s" text-anchor="%s" font-family="%s, sans-serif" font-size="%s"`,
+				svgNumber(line.x), svgNumber(line.y), anchor, svgAttribute(family), svgNumber(fontPixels(it.font)))
+			if it.font.Weight == mapper.FontWeightBold {
+				fmt.Fprint(out, ` font-weight="bold"`)
+			}
+			if it.font.Slant == mapper.FontSlantItalic {
+				fmt.Fprint(out, ` font-style="italic"`)
+			}
+			fmt.Fprintf(out, ` fill="%s"`, svgColor(it.fill))
+			if it.fillOpacity < 1 {
+				fmt.Fprintf(out, ` fill-opacity="%s"`, svgNumber(it.fillOpacity))
+			}
+			fmt.Fprint(out, ` xml:space="preserve">`)
+			if err := xml.EscapeText(out, []byte(line.text)); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "</text>")
+		}
+
+	case imageItem:
+		var data bytes.Buffer
+		if err := png.Encode(&data, it.img); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, `<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="none"`,
+			svgNumber(it.at.X), svgNumber(it.at.Y), svgNumber(it.w), svgNumber(it.h))
+		if it.fillOpacity < 1 {
+			fmt.Fprintf(out, ` opacity="%s"`, svgNumber(it.fillOpacity))
+		}
+		fmt.Fprintf(out, ` href="data:image/png;base64,%s"/>`+"\n", base64.StdEncoding.EncodeToString(data.Bytes()))
+	}
+	return nil
+}
+
+// svgNumber formats a coordinate or other value compactly for the SVG document.
+func svgNumber(f float64) string {
+	v := math.Round(f*100) / 100
+	if v == 0 {
+		// avoid writing "-0"
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// svgAttribute escapes a string for use as an attribute value.
+func svgAttribute(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
@@ -200,6 +200,20 @@ type ServerProfile struct {
 	ScpServer    string `json:"scp_server,omitempty"`
 	ScpProxy     string `json:"scp_proxy,omitempty"`
 	SshPath      string `json:"ssh_path,omitempty"`
+
+	// If TLS is true, connect to the server over TLS. The server's
+	// certificate is verified against the certificate authorities in
+	// TLSCAFile if given (else the system's trusted authorities).
+	// If TLSCertFile and TLSKeyFile are given, they hold a client
+	// certificate and key identifying the user to the server.
+	TLS         bool   `json:"tls,omitempty"`
+	TLSCAFile   string `json:"tls_ca,omitempty"`
+	TLSCertFile string `json:"tls_cert,omitempty"`
+	TLSKeyFile  string `json:"tls_key,omitempty"`
+
+	// If Campaign is set, ask a server hosting several campaigns to
+	// place us in the one by that name.
+	Campaign string `json:"campaign,omitempty"`
 }
 
 //
@@ -588,6 +602,15 @@ func DefaultPreferences() UserPreferences {
 			DieRolls: DieRollStyles{
 				CompactRecents: false,
 				Components: map[string]DieRollComponent{
+					"again": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+					},
+					"band": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+						Format:   "band %s",
+					},
 					"best": DieRollComponent{
 						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
 						FontName: "Special",
@@ -597,9 +620,28 @@ func DefaultPreferences() UserPreferences {
 						FG:       ColorSet{Dark: "#fffb00", Light: "#f05b00"},
 						FontName: "Normal",
 					},
+					"botch": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+					},
+					"botched": DieRollComponent{
+						FG:       ColorSet{Dark: "red", Light: "red"},
+						FontName: "Normal",
+						Format:   "{%s}",
+					},
+					"botches": DieRollComponent{
+						FG:       ColorSet{Dark: "red", Light: "red"},
+						FontName: "Important",
+						Format:   "(%s botches)",
+					},
 					"constant": DieRollComponent{
 						FontName: "Normal",
 					},
+					"critfail": DieRollComponent{
+						FG:       ColorSet{Dark: "red", Light: "red"},
+						FontName: "Important",
+						Format:   "(%s) ",
+					},
 					"critlabel": DieRollComponent{
 						FG:       ColorSet{Dark: "#fffb00", Light: "#f05b00"},
 						FontName: "Special",
@@ -609,11 +651,21 @@ func DefaultPreferences() UserPreferences {
 						FG:       ColorSet{Dark: "#fffb00", Light: "#f05b00"},
 						FontName: "Special",
 					},
+					"critsuccess": DieRollComponent{
+						FG:       ColorSet{Dark: "#00fa92", Light: "green"},
+						FontName: "Important",
+						Format:   "(%s) ",
+					},
 					"dc": DieRollComponent{
 						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
 						FontName: "Special",
 						Format:   "DC %s: ",
 					},
+					"degrees": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+						Format:   "degrees %s: ",
+					},
 					"diebonus": DieRollComponent{
 						FG:       ColorSet{Dark: "red", Light: "red"},
 						FontName: "Special",
@@ -627,6 +679,12 @@ func DefaultPreferences() UserPreferences {
 						FontName: "Normal",
 						Format:   "{%s}",
 					},
+					"dropped": DieRollComponent{
+						FG:         ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName:   "Normal",
+						Format:     "{%s}",
+						Overstrike: true,
+					},
 					"error": DieRollComponent{
 						FG:         ColorSet{Dark: "red", Light: "red"},
 						FontName:   "Normal",
@@ -638,6 +696,20 @@ func DefaultPreferences() UserPreferences {
 						FontName: "Special",
 						Format:   " exceeded DC by %s",
 					},
+					"explode": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+					},
+					"exploded": DieRollComponent{
+						FG:       ColorSet{Dark: "#00fa92", Light: "green"},
+						FontName: "Important",
+						Format:   "{%s}",
+					},
+					"extra": DieRollComponent{
+						FG:       ColorSet{Dark: "#00fa92", Light: "green"},
+						FontName: "Normal",
+						Format:   "{+%s}",
+					},
 					"fail": DieRollComponent{
 						FG:       ColorSet{Dark: "red", Light: "red"},
 						FontName: "Important",
@@ -662,11 +734,20 @@ func DefaultPreferences() UserPreferences {
 						FontName: "Special",
 						Format:   " (roll #%s)",
 					},
+					"keep": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+					},
 					"label": DieRollComponent{
 						FG:       ColorSet{Dark: "cyan", Light: "blue"},
 						FontName: "Special",
 						Format:   " %s",
 					},
+					"lost": DieRollComponent{
+						FG:       ColorSet{Dark: "red", Light: "red"},
+						FontName: "Special",
+						Format:   " lost by %s",
+					},
 					"max": DieRollComponent{
 						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
 						FontName: "Special",
@@ -708,11 +789,31 @@ func DefaultPreferences() UserPreferences {
 					"operator": DieRollComponent{
 						FontName: "Normal",
 					},
+					"opposed": DieRollComponent{
+						FG:       ColorSet{Dark: "#00fa92", Light: "green"},
+						FontName: "Normal",
+						Format:   "{%s}",
+					},
+					"outcome": DieRollComponent{
+						FG:       ColorSet{Dark: "cyan", Light: "blue"},
+						FontName: "Important",
+						Format:   " (%s)",
+					},
 					"repeat": DieRollComponent{
 						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
 						FontName: "Special",
 						Format:   "repeat %s",
 					},
+					"reroll": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+					},
+					"rerolled": DieRollComponent{
+						FG:         ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName:   "Normal",
+						Format:     "{%s}",
+						Overstrike: true,
+					},
 					"result": DieRollComponent{
 						FontName: "Result",
 					},
@@ -744,10 +845,39 @@ func DefaultPreferences() UserPreferences {
 						FontName: "Important",
 						Format:   "(%s) ",
 					},
+					"successes": DieRollComponent{
+						FG:       ColorSet{Dark: "#00fa92", Light: "green"},
+						FontName: "Important",
+						Format:   "(%s successes)",
+					},
 					"system": DieRollComponent{
 						FG:       ColorSet{Dark: "cyan", Light: "blue"},
 						FontName: "System",
 					},
+					"table": DieRollComponent{
+						FG:       ColorSet{Dark: "cyan", Light: "blue"},
+						FontName: "Special",
+						Format:   " %s: ",
+					},
+					"tableresult": DieRollComponent{
+						FG:       ColorSet{Dark: "#00fa92", Light: "green"},
+						FontName: "Important",
+						Format:   "%s ",
+					},
+					"tableroll": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+						Format:   "{%s}",
+					},
+					"target": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+					},
+					"tied": DieRollComponent{
+						FG:       ColorSet{Dark: "#fffb00", Light: "#f05b00"},
+						FontName: "Special",
+						Format:   " tied",
+					},
 					"title": DieRollComponent{
 						FG:       ColorSet{Dark: "#aaaaaa", Light: "#ffffff"},
 						BG:       ColorSet{Dark: "#000044", Light: "#c7c0ae"},
@@ -767,6 +897,31 @@ func DefaultPreferences() UserPreferences {
 						FontName: "Special",
 						Format:   "until %s",
 					},
+					"vs": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+						Format:   "vs %s ",
+					},
+					"wild": DieRollComponent{
+						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
+						FontName: "Special",
+					},
+					"wilddropped": DieRollComponent{
+						FG:         ColorSet{Dark: "#fffb00", Light: "#f05b00"},
+						FontName:   "Normal",
+						Format:     "{%s}",
+						Overstrike: true,
+					},
+					"wildroll": DieRollComponent{
+						FG:       ColorSet{Dark: "#fffb00", Light: "#f05b00"},
+						FontName: "Important",
+						Format:   "{%s}",
+					},
+					"won": DieRollComponent{
+						FG:       ColorSet{Dark: "#00fa92", Light: "green"},
+						FontName: "Special",
+						Format:   " won by %s",
+					},
 					"worst": DieRollComponent{
 						FG:       ColorSet{Dark: "#aaaaaa", Light: "#888888"},
 						FontName: "Special",
@@ -965,6 +1120,18 @@ func (prefs *UserPreferences) UpdateFromSimpleConfig(profileName string, cfg Sim
 			prefs.Profiles[profile].CurlProxy = v
 		case "proxy-host", "X":
 			prefs.Profiles[profile].ScpProxy = v
+		case "tls":
+			prefs.Profiles[profile].TLS = true
+		case "no-tls":
+			prefs.Profiles[profile].TLS = false
+		case "tls-ca":
+			prefs.Profiles[profile].TLSCAFile = v
+		case "tls-cert":
+			prefs.Profiles[profile].TLSCertFile = v
+		case "tls-key":
+			prefs.Profiles[profile].TLSKeyFile = v
+		case "campaign":
+			prefs.Profiles[profile].Campaign = v
 		case "preload", "l":
 			prefs.PreloadImages = true
 		case "button-size":
@@ -0,0 +1,531 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+////////////////////////////////////////////////////////////////////////////////////////
+//                                                                                    //
+//                                       UVTT                                         //
+//                                                                                    //
+// Imports battle maps from Universal VTT files, as exported by Dungeondraft and      //
+// similar map-making tools.                                                          //
+//                                                                                    //
+////////////////////////////////////////////////////////////////////////////////////////
+
+// Package uvtt reads Universal VTT map files (as exported by Dungeondraft
+// with the .dd2vtt suffix, and by other tools as .uvtt or .df2vtt files)
+// and converts them to GMA mapper objects.
+//
+// A Universal VTT file holds the map's background image along with the
+// walls which block line of sight, the doors ("portals") in them, and
+// the light sources on the map, all measured in grid squares. These become
+// a TileElement showing the background image (with an ImageDefinition for
+// it), LineElement walls (or PolygonElement walls where they close on
+// themselves), PolygonElement doors, and SpellAreaOfEffectElement or
+// TextElement markers for the lights, scaled to the mapper's grid. They
+// may then be saved with mapper.WriteMapFile:
+//
+//	m, err := uvtt.ReadFile("cave.dd2vtt")
+//	if err != nil {
+//	    ...
+//	}
+//	objects, err := m.MapObjects(uvtt.WithImage("cave", "cave.png", true))
+//	if err != nil {
+//	    ...
+//	}
+//	err = mapper.WriteMapFile("cave.map", objects, mapper.MapMetaData{Location: "Cave"})
+//
+// The background image itself is extracted with the BackgroundImage method,
+// which scales it to the size the mapper expects.
+package uvtt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// GridSize is the size of one grid square in mapper pixel units.
+const GridSize = 50.0
+
+// Point is a location on a Universal VTT map, in grid squares.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Resolution describes the size of a Universal VTT map and of its image.
+type Resolution struct {
+	// The grid coordinates of the upper-left corner of the map.
+	MapOrigin Point `json:"map_origin"`
+
+	// The width and height of the map in grid squares.
+	MapSize Point `json:"map_size"`
+
+	// The size of each grid square in the map's image, in pixels.
+	PixelsPerGrid float64 `json:"pixels_per_grid"`
+}
+
+// Portal is a door (or window) in a wall.
+type Portal struct {
+	// The center of the portal.
+	Position Point `json:"position"`
+
+	// The endpoints of the portal's opening.
+	Bounds []Point `json:"bounds"`
+
+	// The rotation of the portal in radians.
+	Rotation float64 `json:"rotation"`
+
+	// Is the portal closed (blocking line of sight)?
+	Closed bool `json:"closed"`
+
+	// Does the portal stand on its own rather than within a wall?
+	Freestanding bool `json:"freestanding"`
+}
+
+// Light is a light source on the map.
+type Light struct {
+	// Where the light is.
+	Position Point `json:"position"`
+
+	// How far the light reaches, in grid squares.
+	Range float64 `json:"range"`
+
+	// How bright the light is.
+	Intensity float64 `json:"intensity"`
+
+	// The light's color as hex digits "aarrggbb" or "rrggbb".
+	Color string `json:"color"`
+
+	// Does the light cast shadows?
+	Shadows bool `json:"shadows"`
+}
+
+// Environment describes the overall lighting of the map.
+type Environment struct {
+	BakedLighting bool   `json:"baked_lighting"`
+	AmbientLight  string `json:"ambient_light"`
+}
+
+// Map is the contents of a Universal VTT file.
+type Map struct {
+	// The version of the file format.
+	Format float64 `json:"format"`
+
+	// The size of the map.
+	Resolution Resolution `json:"resolution"`
+
+	// The walls, as polylines through the points in each list.
+	LineOfSight [][]Point `json:"line_of_sight"`
+
+	// Other objects which block line of sight, such as pillars or furniture,
+	// as polylines through the points in each list.
+	ObjectsLineOfSight [][]Point `json:"objects_line_of_sight,omitempty"`
+
+	// The doors and windows in the walls.
+	Portals []Portal `json:"portals"`
+
+	// The light sources on the map.
+	Lights []Light `json:"lights"`
+
+	// The overall lighting of the map.
+	Environment Environment `json:"environment"`
+
+	// The base64-encoded image file (PNG, JPEG, or WebP) for the map's background.
+	Image string `json:"image"`
+}
+
+// Read reads a Universal VTT map from an open data stream.
+func Read(input io.Reader) (*Map, error) {
+	var m Map
+	if err := json.NewDecoder(input).Decode(&m); err != nil {
+		return nil, fmt.Errorf("unable to read Universal VTT data: %v", err)
+	}
+	if m.Resolution.PixelsPerGrid <= 0 {
+		return nil, fmt.Errorf("Universal VTT data has invalid pixels_per_grid value %g", m.Resolution.PixelsPerGrid)
+	}
+	if m.Resolution.MapSize.X <= 0 || m.Resolution.MapSize.Y <= 0 {
+		return nil, fmt.Errorf("Universal VTT data has invalid map_size %gx%g", m.Resolution.MapSize.X, m.Resolution.MapSize.Y)
+	}
+	return &m, nil
+}
+
+// ReadFile reads a Universal VTT map from the named file.
+func ReadFile(path string) (*Map, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// BackgroundImage returns the map's background image as a PNG file,
+// scaled to the size the mapper displays it at the given zoom level
+// (where at zoom level 1 each grid square is 50 pixels).
+//
+// It returns nil if the map has no background image.
+func (m *Map) BackgroundImage(zoom float64) ([]byte, error) {
+	if m.Image == "" {
+		return nil, nil
+	}
+	if zoom <= 0 {
+		return nil, fmt.Errorf("invalid zoom level %g", zoom)
+	}
+	data, err := base64.StdEncoding.DecodeString(m.Image)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode map image: %v", err)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode map image: %v", err)
+	}
+	width := int(math.Round(m.Resolution.MapSize.X * GridSize * zoom))
+	height := int(math.Round(m.Resolution.MapSize.Y * GridSize * zoom))
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("map image would be empty at zoom level %g", zoom)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, dst); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// LightStyle says how light sources are shown on the map.
+type LightStyle byte
+
+const (
+	// Show each light as a spell area of effect covering the area it lights.
+	LightsAsAreas LightStyle = iota
+
+	// Show each light as a text label at its position.
+	LightsAsText
+
+	// Leave the lights off the map.
+	NoLights
+)
+
+// ImportOption is an option which controls how MapObjects converts a map.
+type ImportOption func(*importOptions) error
+
+type importOptions struct {
+	imageName    string
+	imageFile    string
+	imageIsLocal bool
+	origin       mapper.Coordinates
+	lights       LightStyle
+	wallColor    string
+	doorColor    string
+}
+
+// WithImage names the map's background image as it will be known in the mapper, and
+// where the mapper can get it. If isLocal is true, file is the name of the image file
+// on disk; otherwise it is the ID by which the image is known to the server.
+//
+// Without this option (or if file is empty), the image (scaled to zoom level 1) is
+// stored in the map file itself, which works but makes the map file very large.
+func WithImage(name, file string, isLocal bool) ImportOption {
+	return func(o *importOptions) error {
+		if name == "" {
+			return fmt.Errorf("image name may not be empty")
+		}
+		o.imageName = name
+		o.imageFile = file
+		o.imageIsLocal = isLocal
+		return nil
+	}
+}
+
+// WithOrigin places the upper-left corner of the map at the given mapper coordinates
+// instead of at (0, 0).
+func WithOrigin(x, y float64) ImportOption {
+	return func(o *importOptions) error {
+		o.origin = mapper.Coordinates{X: x, Y: y}
+		return nil
+	}
+}
+
+// WithLights says how to show the map's light sources (by default, as areas of effect).
+func WithLights(style LightStyle) ImportOption {
+	return func(o *importOptions) error {
+		if style > NoLights {
+			return fmt.Errorf("invalid light style %d", style)
+		}
+		o.lights = style
+		return nil
+	}
+}
+
+// WithWallColor draws the walls in the given color instead of black.
+func WithWallColor(color string) ImportOption {
+	return func(o *importOptions) error {
+		o.wallColor = color
+		return nil
+	}
+}
+
+// WithDoorColor draws the doors in the given color instead of brown.
+func WithDoorColor(color string) ImportOption {
+	return func(o *importOptions) error {
+		o.doorColor = color
+		return nil
+	}
+}
+
+// Stacking order of the imported elements.
+const (
+	zBackground = iota
+	zLights
+	zWalls
+	zDoors
+)
+
+// MapObjects converts the Universal VTT map into mapper objects, according to the
+// options given. These are WithImage, WithOrigin, WithLights, WithWallColor, and
+// WithDoorColor.
+//
+// The background image becomes a tile covering the map, which is placed beneath
+// everything else. Walls and other objects which block line of sight become lines
+// (or polygons, if they are closed shapes), doors become thin rectangles across
+// their openings (filled if closed, dashed outlines if open), and lights become
+// partially-filled circles or labels at their positions.
+func (m *Map) MapObjects(options ...ImportOption) ([]any, error) {
+	opts := importOptions{
+		imageName: "uvtt-" + newObjectID()[:8],
+		wallColor: "black",
+		doorColor: "saddlebrown",
+	}
+	for _, o := range options {
+		if err := o(&opts); err != nil {
+			return nil, err
+		}
+	}
+
+	toMap := func(p Point) mapper.Coordinates {
+		return mapper.Coordinates{
+			X: opts.origin.X + (p.X-m.Resolution.MapOrigin.X)*GridSize,
+			Y: opts.origin.Y + (p.Y-m.Resolution.MapOrigin.Y)*GridSize,
+		}
+	}
+	element := func(z int, at mapper.Coordinates) mapper.MapElement {
+		return mapper.MapElement{
+			BaseMapObject: mapper.BaseMapObject{ID: newObjectID()},
+			Coordinates:   at,
+			Z:             z,
+		}
+	}
+
+	var objects []any
+
+	if m.Image != "" {
+		instance := mapper.ImageInstance{
+			Zoom:        1,
+			File:        opts.imageFile,
+			IsLocalFile: opts.imageIsLocal,
+		}
+		if opts.imageFile == "" {
+			data, err := m.BackgroundImage(1)
+			if err != nil {
+				return nil, err
+			}
+			instance = mapper.ImageInstance{Zoom: 1, ImageData: data}
+		}
+		objects = append(objects, mapper.ImageDefinition{
+			Name:  opts.imageName,
+			Sizes: []mapper.ImageInstance{instance},
+		})
+		objects = append(objects, mapper.TileElement{
+			MapElement: element(zBackground, opts.origin),
+			Image:      opts.imageName,
+			BBWidth:    m.Resolution.MapSize.X * GridSize,
+			BBHeight:   m.Resolution.MapSize.Y * GridSize,
+		})
+	}
+
+	if opts.lights != NoLights {
+		for _, light := range m.Lights {
+			at := toMap(light.Position)
+			color := lightColor(light.Color)
+			if opts.lights == LightsAsText {
+				e := element(zLights, at)
+				e.Fill = color
+				objects = append(objects, mapper.TextElement{
+					MapElement: e,
+					Text:       fmt.Sprintf("light %g ft", light.Range*5),
+					Font:       mapper.TextFont{Family: "Helvetica", Size: 8},
+				})
+				continue
+			}
+			radius := light.Range * GridSize
+			e := element(zLights, mapper.Coordinates{X: at.X - radius, Y: at.Y - radius})
+			e.Points = []mapper.Coordinates{{X: at.X + radius, Y: at.Y + radius}}
+			e.Line = color
+			e.Fill = color
+			e.Stipple = "gray12"
+			e.Dash = mapper.DashShort
+			objects = append(objects, mapper.SpellAreaOfEffectElement{
+				MapElement: e,
+				AoEShape:   mapper.AoEShapeRadius,
+			})
+		}
+	}
+
+	addWalls := func(walls [][]Point, width int) {
+		for _, wall := range walls {
+			if len(wall) < 2 {
+				continue
+			}
+			e := element(zWalls, toMap(wall[0]))
+			e.Width = width
+			closed := len(wall) > 2 && wall[0] == wall[len(wall)-1]
+			if closed {
+				wall = wall[:len(wall)-1]
+			}
+			for _, p := range wall[1:] {
+				e.Points = append(e.Points, toMap(p))
+			}
+			if closed {
+				e.Line = opts.wallColor
+				objects = append(objects, mapper.PolygonElement{MapElement: e, Join: mapper.JoinMiter})
+			} else {
+				// lines are drawn in their fill color
+				e.Fill = opts.wallColor
+				objects = append(objects, mapper.LineElement{MapElement: e})
+			}
+		}
+	}
+	addWalls(m.LineOfSight, 5)
+	addWalls(m.ObjectsLineOfSight, 3)
+
+	for _, portal := range m.Portals {
+		if len(portal.Bounds) < 2 {
+			continue
+		}
+		a, b := toMap(portal.Bounds[0]), toMap(portal.Bounds[1])
+		dx, dy := b.X-a.X, b.Y-a.Y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		// the door is a rectangle 1 foot thick along the opening
+		nx, ny := -dy/length*5, dx/length*5
+		e := element(zDoors, mapper.Coordinates{X: a.X + nx, Y: a.Y + ny})
+		e.Points = []mapper.Coordinates{
+			{X: b.X + nx, Y: b.Y + ny},
+			{X: b.X - nx, Y: b.Y - ny},
+			{X: a.X - nx, Y: a.Y - ny},
+		}
+		e.Line = opts.doorColor
+		e.Width = 2
+		if portal.Closed {
+			e.Fill = opts.doorColor
+		} else {
+			e.Dash = mapper.DashShort
+		}
+		objects = append(objects, mapper.PolygonElement{MapElement: e, Join: mapper.JoinMiter})
+	}
+
+	return objects, nil
+}
+
+// lightColor converts a light's color from the "aarrggbb" (or "rrggbb")
+// form used in Universal VTT files to the "#rrggbb" form used by the mapper.
+// Colors we can't understand are shown as yellow.
+func lightColor(c string) string {
+	c = strings.TrimPrefix(c, "#")
+	if len(c) == 8 {
+		c = c[2:]
+	}
+	if len(c) != 6 {
+		return "yellow"
+	}
+	if _, err := hex.DecodeString(c); err != nil {
+		return "yellow"
+	}
+	return "#" + strings.ToLower(c)
+}
+
+// newObjectID makes a unique ID for a map object, following
+// the mapper's convention of using a UUID in hex without punctuation.
+func newObjectID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return hex.EncodeToString(b[:])
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.
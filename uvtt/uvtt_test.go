@@ -0,0 +1,274 @@
+/*
+########################################################################################
+#  __                                                                                  #
+# /__ _                                                                                #
+# \_|(_)                                                                               #
+#  _______  _______  _______             _______     _______   ______     _______      #
+# (  ____ \(       )(  ___  ) Game      (  ____ \   / ___   ) / ____ \   (  __   )     #
+# | (    \/| () () || (   ) | Master's  | (    \/   \/   )  |( (    \/   | (  )  |     #
+# | |      | || || || (___) | Assistant | (____         /   )| (____     | | /   |     #
+# | | ____ | |(_)| ||  ___  | (Go Port) (_____ \      _/   / |  ___ \    | (/ /) |     #
+# | | \_  )| |   | || (   ) |                 ) )    /   _/  | (   ) )   |   / | |     #
+# | (___) || )   ( || )   ( | Mapper    /\____) ) _ (   (__/\( (___) ) _ |  (__) |     #
+# (_______)|/     \||/     \| Client    \______/ (_)\_______/ \_____/ (_)(_______)     #
+#                                                                                      #
+########################################################################################
+*/
+//
+// Unit tests for importing Universal VTT files
+//
+
+package uvtt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/MadScienceZone/go-gma/v5/mapper"
+)
+
+// testUVTT makes a 4x2-grid map with 10 pixels per grid square.
+func testUVTT(t *testing.T) string {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			img.Set(x, y, color.RGBA{0, 0x80, 0, 0xff})
+		}
+	}
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		t.Fatalf("unable to make test image: %v", err)
+	}
+	return fmt.Sprintf(`{
+	"format": 0.3,
+	"resolution": {"map_origin": {"x": 1, "y": 1}, "map_size": {"x": 4, "y": 2}, "pixels_per_grid": 10},
+	"line_of_sight": [
+		[{"x": 1, "y": 1}, {"x": 5, "y": 1}, {"x": 5, "y": 3}],
+		[{"x": 2, "y": 2}, {"x": 3, "y": 2}, {"x": 3, "y": 3}, {"x": 2, "y": 2}]
+	],
+	"objects_line_of_sight": [],
+	"portals": [
+		{"position": {"x": 1, "y": 2}, "bounds": [{"x": 1, "y": 1.5}, {"x": 1, "y": 2.5}], "rotation": 1.5708, "closed": true, "freestanding": false},
+		{"position": {"x": 4, "y": 3}, "bounds": [{"x": 3.5, "y": 3}, {"x": 4.5, "y": 3}], "rotation": 0, "closed": false, "freestanding": false}
+	],
+	"lights": [
+		{"position": {"x": 3, "y": 2}, "range": 2, "intensity": 1, "color": "ffeccd8b", "shadows": true}
+	],
+	"environment": {"baked_lighting": true, "ambient_light": "ffffffff"},
+	"image": "%s"
+}`, base64.StdEncoding.EncodeToString(data.Bytes()))
+}
+
+func TestRead(t *testing.T) {
+	m, err := Read(strings.NewReader(testUVTT(t)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if m.Resolution.PixelsPerGrid != 10 || m.Resolution.MapSize != (Point{4, 2}) || len(m.LineOfSight) != 2 ||
+		len(m.Portals) != 2 || !m.Portals[0].Closed || len(m.Lights) != 1 || m.Lights[0].Range != 2 {
+		t.Errorf("map not read correctly: %v", m)
+	}
+
+	for _, bad := range []string{
+		`{"resolution": {"map_size": {"x": 4, "y": 2}}}`,
+		`{"resolution": {"map_size": {"x": 0, "y": 2}, "pixels_per_grid": 10}}`,
+		`{"resolution": `,
+	} {
+		if _, err := Read(strings.NewReader(bad)); err == nil {
+			t.Errorf("invalid data %s was accepted", bad)
+		}
+	}
+}
+
+func TestBackgroundImage(t *testing.T) {
+	m, err := Read(strings.NewReader(testUVTT(t)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	for _, zoom := range []float64{1, 0.5} {
+		data, err := m.BackgroundImage(zoom)
+		if err != nil {
+			t.Fatalf("BackgroundImage(%g): %v", zoom, err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("BackgroundImage(%g) is not a PNG image: %v", zoom, err)
+		}
+		if b := img.Bounds(); b.Dx() != int(200*zoom) || b.Dy() != int(100*zoom) {
+			t.Errorf("BackgroundImage(%g) is %v", zoom, b)
+		}
+		if c := color.RGBAModel.Convert(img.At(10, 10)).(color.RGBA); c != (color.RGBA{0, 0x80, 0, 0xff}) {
+			t.Errorf("BackgroundImage(%g) has wrong color %v", zoom, c)
+		}
+	}
+	if _, err := m.BackgroundImage(0); err == nil {
+		t.Errorf("zoom level 0 was accepted")
+	}
+}
+
+func TestMapObjects(t *testing.T) {
+	m, err := Read(strings.NewReader(testUVTT(t)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	objects, err := m.MapObjects(WithImage("cellar", "cellar.png", true), WithOrigin(100, 50))
+	if err != nil {
+		t.Fatalf("MapObjects: %v", err)
+	}
+
+	var images []mapper.ImageDefinition
+	var tiles []mapper.TileElement
+	var lines []mapper.LineElement
+	var polygons []mapper.PolygonElement
+	var areas []mapper.SpellAreaOfEffectElement
+	ids := make(map[string]bool)
+	for _, obj := range objects {
+		if o, ok := obj.(mapper.MapObject); ok {
+			if ids[o.ObjID()] {
+				t.Errorf("duplicate object ID %s", o.ObjID())
+			}
+			ids[o.ObjID()] = true
+		}
+		switch o := obj.(type) {
+		case mapper.ImageDefinition:
+			images = append(images, o)
+		case mapper.TileElement:
+			tiles = append(tiles, o)
+		case mapper.LineElement:
+			lines = append(lines, o)
+		case mapper.PolygonElement:
+			polygons = append(polygons, o)
+		case mapper.SpellAreaOfEffectElement:
+			areas = append(areas, o)
+		default:
+			t.Errorf("unexpected object %T", obj)
+		}
+	}
+
+	if len(images) != 1 || images[0].Name != "cellar" || len(images[0].Sizes) != 1 ||
+		images[0].Sizes[0].Zoom != 1 || images[0].Sizes[0].File != "cellar.png" || !images[0].Sizes[0].IsLocalFile {
+		t.Errorf("image definitions %v", images)
+	}
+	if len(tiles) != 1 || tiles[0].Image != "cellar" || tiles[0].X != 100 || tiles[0].Y != 50 ||
+		tiles[0].BBWidth != 200 || tiles[0].BBHeight != 100 {
+		t.Errorf("tiles %v", tiles)
+	}
+	if len(lines) != 1 || lines[0].X != 100 || lines[0].Y != 50 || len(lines[0].Points) != 2 ||
+		lines[0].Points[1] != (mapper.Coordinates{X: 300, Y: 150}) || lines[0].Fill != "black" || lines[0].Width != 5 {
+		t.Errorf("walls %v", lines)
+	}
+	// one closed wall and two doors
+	if len(polygons) != 3 {
+		t.Fatalf("polygons %v", polygons)
+	}
+	if p := polygons[0]; p.X != 150 || p.Y != 100 || len(p.Points) != 2 || p.Fill != "" {
+		t.Errorf("closed wall %v", p)
+	}
+	if p := polygons[1]; len(p.Points) != 3 || p.Fill != "saddlebrown" || p.Dash != mapper.DashSolid ||
+		p.X != 95 || p.Y != 75 || p.Points[1] != (mapper.Coordinates{X: 105, Y: 125}) {
+		t.Errorf("closed door %v", p)
+	}
+	if p := polygons[2]; p.Fill != "" || p.Dash != mapper.DashShort {
+		t.Errorf("open door %v", p)
+	}
+	if len(areas) != 1 || areas[0].AoEShape != mapper.AoEShapeRadius || areas[0].X != 100 || areas[0].Y != 0 ||
+		areas[0].Points[0] != (mapper.Coordinates{X: 300, Y: 200}) || areas[0].Fill != "#eccd8b" {
+		t.Errorf("lights %v", areas)
+	}
+
+	objects, err = m.MapObjects(WithLights(LightsAsText))
+	if err != nil {
+		t.Fatalf("MapObjects: %v", err)
+	}
+	var texts int
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case mapper.TextElement:
+			texts++
+			if o.Text != "light 10 ft" || o.X != 100 || o.Y != 50 {
+				t.Errorf("light label %v", o)
+			}
+		case mapper.ImageDefinition:
+			if len(o.Sizes) != 1 || len(o.Sizes[0].ImageData) == 0 {
+				t.Errorf("image was not embedded: %v", o)
+			}
+		case mapper.SpellAreaOfEffectElement:
+			t.Errorf("light drawn as area with LightsAsText")
+		}
+	}
+	if texts != 1 {
+		t.Errorf("%d light labels", texts)
+	}
+
+	objects, err = m.MapObjects(WithLights(NoLights))
+	if err != nil {
+		t.Fatalf("MapObjects: %v", err)
+	}
+	for _, obj := range objects {
+		switch obj.(type) {
+		case mapper.TextElement, mapper.SpellAreaOfEffectElement:
+			t.Errorf("light drawn with NoLights")
+		}
+	}
+}
+
+func TestLightColor(t *testing.T) {
+	for _, test := range [][2]string{
+		{"ffeccd8b", "#eccd8b"},
+		{"ECCD8B", "#eccd8b"},
+		{"#ff102030", "#102030"},
+		{"nonsense", "yellow"},
+		{"", "yellow"},
+	} {
+		if c := lightColor(test[0]); c != test[1] {
+			t.Errorf("lightColor(%q) = %q; expected %q", test[0], c, test[1])
+		}
+	}
+}
+
+// @[00]@| Go-GMA 5.26.0
+// @[01]@|
+// @[10]@| Overall GMA package Copyright © 1992–2024 by Steven L. Willoughby (AKA MadScienceZone)
+// @[11]@| steve@madscience.zone (previously AKA Software Alchemy),
+// @[12]@| Aloha, Oregon, USA. All Rights Reserved. Some components were introduced at different
+// @[13]@| points along that historical time line.
+// @[14]@| Distributed under the terms and conditions of the BSD-3-Clause
+// @[15]@| License as described in the accompanying LICENSE file distributed
+// @[16]@| with GMA.
+// @[17]@|
+// @[20]@| Redistribution and use in source and binary forms, with or without
+// @[21]@| modification, are permitted provided that the following conditions
+// @[22]@| are met:
+// @[23]@| 1. Redistributions of source code must retain the above copyright
+// @[24]@|    notice, this list of conditions and the following disclaimer.
+// @[25]@| 2. Redistributions in binary form must reproduce the above copy-
+// @[26]@|    right notice, this list of conditions and the following dis-
+// @[27]@|    claimer in the documentation and/or other materials provided
+// @[28]@|    with the distribution.
+// @[29]@| 3. Neither the name of the copyright holder nor the names of its
+// @[30]@|    contributors may be used to endorse or promote products derived
+// @[31]@|    from this software without specific prior written permission.
+// @[32]@|
+// @[33]@| THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND
+// @[34]@| CONTRIBUTORS “AS IS” AND ANY EXPRESS OR IMPLIED WARRANTIES,
+// @[35]@| INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// @[36]@| MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// @[37]@| DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS
+// @[38]@| BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY,
+// @[39]@| OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// @[40]@| PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+// @[41]@| PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// @[42]@| THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR
+// @[43]@| TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// @[44]@| THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+// @[45]@| SUCH DAMAGE.
+// @[46]@|
+// @[50]@| This software is not intended for any use or application in which
+// @[51]@| the safety of lives or property would be at risk due to failure or
+// @[52]@| defect of the software.